@@ -0,0 +1,164 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// blockReport collects the stale blocks for -report-json, if set.
+var blockReport *staleReport
+
+// staleReport is the JSON report of the stale blocks written by -report-json,
+// with the last authors of their commands and sources so triage tooling can
+// assign them.
+type staleReport struct {
+	Version int             `json:"version"`
+	Blocks  []reportedBlock `json:"stale"`
+
+	mu sync.Mutex
+}
+
+// reportedBlock is a stale block, with the author who last changed its
+// command.
+type reportedBlock struct {
+	File    string           `json:"file"`
+	Line    int              `json:"line"`
+	Author  *author          `json:"author,omitempty"`
+	Sources []reportedSource `json:"sources"`
+}
+
+// reportedSource is a region of a source embedded in a stale block, with the
+// author who last changed it.
+type reportedSource struct {
+	Path   string  `json:"path"`
+	Line   int     `json:"line"`
+	Start  int     `json:"start,omitempty"`
+	End    int     `json:"end,omitempty"`
+	Author *author `json:"author,omitempty"`
+}
+
+// author is the author of a commit, as found by git blame.
+type author struct {
+	Name   string    `json:"name"`
+	Email  string    `json:"email"`
+	Commit string    `json:"commit"`
+	Time   time.Time `json:"time"`
+}
+
+// collect returns the option recording the stale blocks of the file.
+func (r *staleReport) collect(path string) embedmd.Option {
+	return embedmd.WithStaleBlocks(func(b embedmd.StaleBlock) {
+		block := reportedBlock{
+			File:   filepath.ToSlash(path),
+			Line:   b.Line,
+			Author: lastAuthor(path, b.Line, b.Line),
+		}
+		for _, s := range b.Sources {
+			rs := reportedSource{Path: s.Path, Line: s.Line, Start: s.Start, End: s.End}
+			if !strings.Contains(s.Path, "://") {
+				p := s.Path
+				if !filepath.IsAbs(p) {
+					p = filepath.Join(filepath.Dir(path), filepath.FromSlash(p))
+				}
+				rs.Author = lastAuthor(p, s.Start, s.End)
+			}
+			block.Sources = append(block.Sources, rs)
+		}
+		r.mu.Lock()
+		r.Blocks = append(r.Blocks, block)
+		r.mu.Unlock()
+	})
+}
+
+// write writes the report to the file at path.
+func (r *staleReport) write(path string) error {
+	r.Version = 1
+	if r.Blocks == nil {
+		r.Blocks = []reportedBlock{}
+	}
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// lastAuthor returns the author of the latest commit changing the lines from
+// start to end of the file, or the whole file if they are 0. It returns nil
+// if the file isn't committed in a git repository.
+func lastAuthor(path string, start, end int) *author {
+	args := []string{"blame", "--porcelain"}
+	if start > 0 {
+		args = append(args, "-L", strconv.Itoa(start)+","+strconv.Itoa(end))
+	}
+	out, err := runGit(append(args, "--", path)...)
+	if err != nil {
+		return nil
+	}
+	return parseBlame(string(out))
+}
+
+// uncommitted is the hash git blame gives to lines not committed yet.
+const uncommitted = "0000000000000000000000000000000000000000"
+
+// parseBlame returns the author of the latest commit in the output of git
+// blame --porcelain, ignoring uncommitted lines.
+func parseBlame(out string) *author {
+	commits := map[string]*author{}
+	var cur *author
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "\t") {
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch {
+		case len(key) == 40 && strings.Trim(key, "0123456789abcdef") == "":
+			if cur = commits[key]; cur == nil {
+				cur = &author{Commit: key}
+				commits[key] = cur
+			}
+		case cur == nil:
+		case key == "author":
+			cur.Name = value
+		case key == "author-mail":
+			cur.Email = strings.Trim(value, "<>")
+		case key == "author-time":
+			if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+				cur.Time = time.Unix(sec, 0).UTC()
+			}
+		}
+	}
+	// Commits made at the same time are ordered by hash, so the author
+	// reported doesn't depend on the order of the map.
+	var last *author
+	var lastHash string
+	for hash, a := range commits {
+		if hash == uncommitted {
+			continue
+		}
+		if last == nil || a.Time.After(last.Time) || a.Time.Equal(last.Time) && hash < lastHash {
+			last, lastHash = a, hash
+		}
+	}
+	return last
+}
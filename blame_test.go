@@ -0,0 +1,101 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReportJSON(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	commit := func(name, date string, files map[string]string) {
+		t.Helper()
+		for file, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, args := range [][]string{{"add", "-A"}, {"commit", "-q", "-m", name}} {
+			cmd := exec.Command("git", append([]string{"-c", "user.name=" + name, "-c", "user.email=" + name + "@example.com"}, args...)...)
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %v\n%s", args, err, out)
+			}
+		}
+	}
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	commit("alice", "2026-01-01T00:00:00Z", map[string]string{
+		"code.go": "package main\n\nfunc a() {}\n\nfunc b() {}\n",
+		"docs.md": "# Docs\n\n[embedmd]:# (code.go /func b/ $)\n```go\nfunc b() {}\n```\n",
+	})
+	commit("bob", "2026-02-01T00:00:00Z", map[string]string{"code.go": "package main\n\nfunc a() {}\n\nfunc b(n int) {}\n"})
+	commit("carol", "2026-03-01T00:00:00Z", map[string]string{"code.go": "package main\n\nfunc a(n int) {}\n\nfunc b(n int) {}\n"})
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func(o io.Writer) { stdout = o }(stdout)
+	stdout = new(bytes.Buffer)
+	defer func() { blockReport = nil }()
+	blockReport = &staleReport{}
+
+	stale, err := embed([]string{"docs.md"}, false, true)
+	if err != nil || !stale {
+		t.Fatalf("expected docs.md to be stale; got %v, %v", stale, err)
+	}
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := blockReport.write(path); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got staleReport
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("bad report: %v\n%s", err, b)
+	}
+	if got.Version != 1 || len(got.Blocks) != 1 || len(got.Blocks[0].Sources) != 1 {
+		t.Fatalf("expected a single stale block; got\n%s", b)
+	}
+	block, src := got.Blocks[0], got.Blocks[0].Sources[0]
+	if block.File != "docs.md" || block.Line != 3 || block.Author == nil || block.Author.Name != "alice" {
+		t.Errorf("expected the command at docs.md:3 by alice; got\n%s", b)
+	}
+	// The source region was last changed by bob, not carol who changed
+	// other lines of the file.
+	if src.Path != "code.go" || src.Start != 5 || src.End != 5 || src.Author == nil ||
+		src.Author.Name != "bob" || src.Author.Email != "bob@example.com" ||
+		!src.Author.Time.Equal(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)) || len(src.Author.Commit) != 40 {
+		t.Errorf("expected code.go:5-5 by bob; got\n%s", b)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// changeSummary counts the commands whose blocks would change when -summary
+// prints them instead of the diffs of -d, and is nil otherwise.
+var changeSummary *changeCounter
+
+// changeCounter counts the commands whose blocks would change, and the files
+// they're in.
+type changeCounter struct{ commands, files int }
+
+// collectChanges returns the option recording in changes the blocks that
+// processing changes.
+func collectChanges(changes *[]embedmd.StaleBlock) embedmd.Option {
+	return embedmd.WithStaleBlocks(func(b embedmd.StaleBlock) { *changes = append(*changes, b) })
+}
+
+// print prints a status line for each command of the file at path whose
+// block changes, as in "docs/api.md:37 UPDATED (server.go /func Serve/)".
+func (c *changeCounter) print(path string, changes []embedmd.StaleBlock) {
+	for _, b := range changes {
+		status := "UPDATED"
+		if b.Added {
+			status = "ADDED"
+		}
+		fmt.Fprintf(stdout, "%s:%d %s %s\n", filepath.ToSlash(path), b.Line, status, b.Command)
+	}
+	c.commands += len(changes)
+	c.files++
+}
+
+// total prints the number of commands and files that would change.
+func (c *changeCounter) total() {
+	fmt.Fprintf(stdout, "%d commands would change in %d files\n", c.commands, c.files)
+}
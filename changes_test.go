@@ -0,0 +1,64 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChangeSummary(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"code.go": "package main\n\nfunc main() {\n\tprintln(1)\n}\n",
+		"a.md":    "# Title\n\n[embedmd]:# (code.go /func main/ $)\n```go\nfunc old() {}\n```\n\n[embedmd]:# (code.go /println/)\n",
+		"b.md":    "# Fresh\n\n[embedmd]:# (code.go /println/)\n```go\nprintln\n```\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func(o io.Writer) { stdout = o }(stdout)
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { changeSummary = nil }()
+	changeSummary = &changeCounter{}
+
+	diff, err := embed([]string{"a.md", "b.md"}, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff {
+		t.Errorf("expected a difference to be found")
+	}
+	changeSummary.total()
+	want := "a.md:3 UPDATED (code.go /func main/ $)\n" +
+		"a.md:8 ADDED (code.go /println/)\n" +
+		"2 commands would change in 1 files\n"
+	if got := out.String(); got != want {
+		t.Errorf("expected output\n%s\ngot\n%s", want, got)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// requireClean is set to refuse rewriting files with uncommitted changes,
+// unless force is also set.
+var requireClean, force bool
+
+// gitStatus returns the short git status of the given file, replaced by
+// testing functions.
+var gitStatus = func(path string) (string, error) {
+	cmd := exec.Command("git", "status", "--porcelain", "--", filepath.Base(path))
+	cmd.Dir = filepath.Dir(path)
+	out, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			return "", fmt.Errorf("git status: %s", strings.TrimSpace(string(ee.Stderr)))
+		}
+		return "", fmt.Errorf("git status: %v", err)
+	}
+	return string(out), nil
+}
+
+// checkClean returns an error for the first of the given files with changes
+// that aren't staged or committed, as rewriting it could lose them.
+func checkClean(paths []string) error {
+	for _, path := range paths {
+		status, err := gitStatus(path)
+		if err != nil {
+			return fmt.Errorf("%s:%v", filepath.ToSlash(path), err)
+		}
+		for _, line := range strings.Split(status, "\n") {
+			// The second column is the status in the working tree.
+			if len(line) > 1 && line[1] != ' ' {
+				return fmt.Errorf("%s: has uncommitted changes, use -force to rewrite it anyway", filepath.ToSlash(path))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRequireClean(t *testing.T) {
+	statuses := map[string]string{
+		"clean.md":     "",
+		"staged.md":    "M  staged.md\n",
+		"modified.md":  " M modified.md\n",
+		"both.md":      "MM both.md\n",
+		"untracked.md": "?? untracked.md\n",
+	}
+	defer func(f func(string) (string, error)) { gitStatus = f }(gitStatus)
+	gitStatus = func(path string) (string, error) {
+		s, ok := statuses[path]
+		if !ok {
+			return "", fmt.Errorf("git status: not a git repository")
+		}
+		return s, nil
+	}
+
+	tc := []struct {
+		name  string
+		paths []string
+		err   string
+	}{
+		{name: "clean", paths: []string{"clean.md"}},
+		{name: "staged changes", paths: []string{"clean.md", "staged.md"}},
+		{name: "unstaged changes",
+			paths: []string{"clean.md", "modified.md"},
+			err:   "modified.md: has uncommitted changes, use -force to rewrite it anyway",
+		},
+		{name: "staged and unstaged changes",
+			paths: []string{"both.md"},
+			err:   "both.md: has uncommitted changes, use -force to rewrite it anyway",
+		},
+		{name: "untracked file",
+			paths: []string{"untracked.md"},
+			err:   "untracked.md: has uncommitted changes, use -force to rewrite it anyway",
+		},
+		{name: "git failing",
+			paths: []string{"elsewhere.md"},
+			err:   "elsewhere.md:git status: not a git repository",
+		},
+	}
+
+	for _, tt := range tc {
+		eqErr(t, tt.name, checkClean(tt.paths), tt.err)
+	}
+}
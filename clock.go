@@ -0,0 +1,60 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// setClock sets the time used as the current time from -now or, with
+// -deterministic, SOURCE_DATE_EPOCH. With -deterministic, it also turns off
+// the HTTP cache, the store, and the extract cache, whose content depends on
+// when they were filled.
+func (o *options) setClock() error {
+	name, s := "-now", o.now
+	if o.deterministic {
+		o.noCache, o.store, o.extractCache = true, false, false
+		if s == "" {
+			name, s = "SOURCE_DATE_EPOCH", os.Getenv("SOURCE_DATE_EPOCH")
+		}
+		if s == "" {
+			return errors.New("error: -deterministic needs -now or SOURCE_DATE_EPOCH to set the current time")
+		}
+	}
+	if s == "" {
+		return nil
+	}
+	t, err := parseTime(s)
+	if err != nil {
+		return fmt.Errorf("error: bad %s %q, should be a 2006-01-02 date, an RFC 3339 time, or Unix seconds", name, s)
+	}
+	o.clock = t
+	return nil
+}
+
+// parseTime parses a date, an RFC 3339 time, or a number of seconds since
+// the Unix epoch.
+func parseTime(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
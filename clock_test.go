@@ -0,0 +1,60 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetClock(t *testing.T) {
+	tc := []struct {
+		name  string
+		o     options
+		epoch string
+		want  time.Time
+		err   string
+	}{
+		{name: "unset", o: options{cacheTTL: time.Hour}},
+		{name: "date", o: options{now: "2026-10-15"}, want: time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "RFC 3339", o: options{now: "2026-10-15T13:00:00Z"}, want: time.Date(2026, 10, 15, 13, 0, 0, 0, time.UTC)},
+		{name: "Unix seconds", o: options{now: "1760533200"}, want: time.Date(2025, 10, 15, 13, 0, 0, 0, time.UTC)},
+		{name: "bad time", o: options{now: "today"}, err: `error: bad -now "today", should be a 2006-01-02 date, an RFC 3339 time, or Unix seconds`},
+		{name: "deterministic", o: options{deterministic: true, store: true}, epoch: "1760533200", want: time.Date(2025, 10, 15, 13, 0, 0, 0, time.UTC)},
+		{name: "deterministic with now", o: options{deterministic: true, now: "2026-10-15"}, epoch: "1760533200", want: time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "deterministic with a bad epoch", o: options{deterministic: true}, epoch: "yesterday", err: `error: bad SOURCE_DATE_EPOCH "yesterday", should be a 2006-01-02 date, an RFC 3339 time, or Unix seconds`},
+		{name: "deterministic without time", o: options{deterministic: true}, err: "error: -deterministic needs -now or SOURCE_DATE_EPOCH to set the current time"},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SOURCE_DATE_EPOCH", tt.epoch)
+			err := tt.o.setClock()
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q; got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.o.clock.Equal(tt.want) {
+				t.Errorf("expected clock %v; got %v", tt.want, tt.o.clock)
+			}
+			if tt.o.deterministic && (!tt.o.noCache || tt.o.store) {
+				t.Errorf("expected -deterministic to turn off the caches")
+			}
+		})
+	}
+}
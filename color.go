@@ -0,0 +1,96 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ANSI escape sequences used to colorize the output on terminals.
+const (
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+	ansiReset  = "\x1b[m"
+)
+
+// colorMode is one of auto, always, or never.
+var colorMode = "auto"
+
+func validColorMode(mode string) error {
+	switch mode {
+	case "auto", "always", "never":
+		return nil
+	}
+	return fmt.Errorf("error: -color should be auto, always, or never, got %q", mode)
+}
+
+// useColor reports whether the output written to w should be colorized.
+// In auto mode colors are used on terminals, unless disabled by NO_COLOR or
+// CLICOLOR=0, or forced by CLICOLOR_FORCE. See https://no-color.org and
+// https://bixense.com/clicolors.
+func useColor(w io.Writer) bool {
+	switch colorMode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if v := os.Getenv("CLICOLOR_FORCE"); v != "" && v != "0" {
+		return true
+	}
+	if os.Getenv("CLICOLOR") == "0" {
+		return false
+	}
+	return isTerminal(w)
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// colorDiff colorizes the removed, added, and hunk header lines of a
+// unified diff.
+func colorDiff(d string) string {
+	lines := strings.SplitAfter(d, "\n")
+	for i, l := range lines {
+		color := ""
+		switch {
+		case strings.HasPrefix(l, "@@"):
+			color = ansiCyan
+		case strings.HasPrefix(l, "-"):
+			color = ansiRed
+		case strings.HasPrefix(l, "+"):
+			color = ansiGreen
+		default:
+			continue
+		}
+		text := strings.TrimSuffix(l, "\n")
+		lines[i] = color + text + ansiReset + l[len(text):]
+	}
+	return strings.Join(lines, "")
+}
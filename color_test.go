@@ -0,0 +1,69 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUseColor(t *testing.T) {
+	tc := []struct {
+		name     string
+		mode     string
+		env      map[string]string
+		expected bool
+	}{
+		{name: "auto without terminal", mode: "auto", expected: false},
+		{name: "always", mode: "always", expected: true},
+		{name: "never", mode: "never", env: map[string]string{"CLICOLOR_FORCE": "1"}, expected: false},
+		{name: "forced", mode: "auto", env: map[string]string{"CLICOLOR_FORCE": "1"}, expected: true},
+		{name: "forced with zero", mode: "auto", env: map[string]string{"CLICOLOR_FORCE": "0"}, expected: false},
+		{name: "no color wins", mode: "auto", env: map[string]string{"NO_COLOR": "1", "CLICOLOR_FORCE": "1"}, expected: false},
+		{name: "always ignores no color", mode: "always", env: map[string]string{"NO_COLOR": "1"}, expected: true},
+	}
+
+	defer func(mode string) { colorMode = mode }(colorMode)
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"NO_COLOR", "CLICOLOR_FORCE", "CLICOLOR"} {
+				t.Setenv(k, tt.env[k])
+			}
+			colorMode = tt.mode
+			if got := useColor(new(bytes.Buffer)); got != tt.expected {
+				t.Errorf("case [%s]: expected %v; got %v", tt.name, tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestColorDiff(t *testing.T) {
+	in := "@@ -1 +1 @@\n-a\n+b\n c\n"
+	want := ansiCyan + "@@ -1 +1 @@" + ansiReset + "\n" +
+		ansiRed + "-a" + ansiReset + "\n" +
+		ansiGreen + "+b" + ansiReset + "\n" +
+		" c\n"
+	if got := colorDiff(in); got != want {
+		t.Errorf("expected %q; got %q", want, got)
+	}
+}
+
+func TestValidColorMode(t *testing.T) {
+	if err := validColorMode("sometimes"); err == nil {
+		t.Errorf("expected an error for an invalid color mode")
+	}
+	if err := validColorMode("never"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
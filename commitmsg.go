@@ -0,0 +1,135 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// docChange is a file changed by a run, with the sources that explain it.
+type docChange struct {
+	path    string
+	sources []sourceChange
+}
+
+// sourceChange is a source of a changed file, with the commits changing it
+// since the file was last committed.
+type sourceChange struct {
+	path        string
+	remote      bool
+	uncommitted bool
+	commits     []string
+}
+
+// suggestCommit writes to w a commit message for the changes made to the
+// given files, whose previous content is in before. The message lists, for
+// each changed file, the sources changed since the file was last committed,
+// with the commits changing them, and the remote sources, which could have
+// changed too. It writes nothing if no file changed.
+func suggestCommit(w io.Writer, before map[string][]byte, paths []string, opts ...embedmd.Option) error {
+	var docs []docChange
+	for _, path := range paths {
+		after, err := readFile(path)
+		if err != nil {
+			return fmt.Errorf("%s:%v", filepath.ToSlash(path), err)
+		}
+		if bytes.Equal(after, before[path]) {
+			continue
+		}
+		sources, err := embedmd.Sources(bytes.NewReader(after), opts...)
+		if err != nil {
+			return fmt.Errorf("%s:%v", filepath.ToSlash(path), err)
+		}
+		docs = append(docs, docChange{filepath.ToSlash(path), changedSources(path, sources)})
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	if len(docs) == 1 {
+		fmt.Fprintf(&b, "Refresh embedded code in %s\n\n", docs[0].path)
+	} else {
+		fmt.Fprintf(&b, "Refresh embedded code in %d files\n\n", len(docs))
+	}
+	b.WriteString("Regenerated with embedmd.\n")
+	for _, d := range docs {
+		fmt.Fprintf(&b, "\n%s\n", d.path)
+		for _, s := range d.sources {
+			switch {
+			case s.remote:
+				fmt.Fprintf(&b, "  %s (remote)\n", s.path)
+			case s.uncommitted:
+				fmt.Fprintf(&b, "  %s (uncommitted changes)\n", s.path)
+			default:
+				fmt.Fprintf(&b, "  %s\n", s.path)
+			}
+			for _, c := range s.commits {
+				fmt.Fprintf(&b, "    %s\n", c)
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// changedSources returns the sources of the file at path that changed since
+// it was last committed, and its remote sources.
+func changedSources(path string, sources []embedmd.Source) []sourceChange {
+	base := lastCommit(path)
+	var changed []sourceChange
+	seen := map[string]bool{}
+	for _, src := range sources {
+		if seen[src.Path] {
+			continue
+		}
+		seen[src.Path] = true
+		if strings.HasPrefix(src.Path, "http://") || strings.HasPrefix(src.Path, "https://") {
+			changed = append(changed, sourceChange{path: src.Path, remote: true})
+			continue
+		}
+		p := filepath.Join(filepath.Dir(path), filepath.FromSlash(src.Path))
+		s := sourceChange{path: filepath.ToSlash(p)}
+		if status, err := gitStatus(p); err == nil && status != "" {
+			s.uncommitted = true
+		}
+		if base != "" {
+			if out, err := runGit("log", "--format=%h %s", base+"..HEAD", "--", p); err == nil {
+				if out := strings.TrimSpace(string(out)); out != "" {
+					s.commits = strings.Split(out, "\n")
+				}
+			}
+		}
+		if s.uncommitted || len(s.commits) > 0 {
+			changed = append(changed, s)
+		}
+	}
+	return changed
+}
+
+// lastCommit returns the hash of the last commit changing the file at path,
+// or an empty string if there's none or git can't tell.
+func lastCommit(path string) string {
+	out, err := runGit("log", "-1", "--format=%H", "--", path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
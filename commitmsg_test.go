@@ -0,0 +1,97 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestSuggestCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := "[embedmd]:# (../code.go)\n[embedmd]:# (../util.go)\n[embedmd]:# (https://example.com/x.go)\n"
+	b := "[embedmd]:# (../util.go)\n"
+
+	git("init", "-q")
+	write("code.go", "v1\n")
+	write("util.go", "v1\n")
+	write("docs/a.md", a)
+	write("docs/b.md", b)
+	git("add", "-A")
+	git("commit", "-q", "-m", "Add the docs")
+	write("code.go", "v2\n")
+	git("commit", "-q", "-am", "Change the code")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []string{"docs/a.md", "docs/b.md"}
+	before := map[string][]byte{"docs/a.md": []byte(a), "docs/b.md": []byte(b)}
+	var out bytes.Buffer
+	if err := suggestCommit(&out, before, paths); err != nil {
+		t.Fatalf("could not suggest a commit: %v", err)
+	}
+	if out.Len() > 0 {
+		t.Errorf("expected no message without changes; got\n%s", out.String())
+	}
+
+	write("docs/a.md", a+"```go\nv2\n```\n")
+	write("code.go", "v3\n")
+	if err := suggestCommit(&out, before, paths); err != nil {
+		t.Fatalf("could not suggest a commit: %v", err)
+	}
+	log, err := runGit("log", "-1", "--format=%h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Refresh embedded code in docs/a.md\n\n" +
+		"Regenerated with embedmd.\n\n" +
+		"docs/a.md\n" +
+		"  code.go (uncommitted changes)\n" +
+		"    " + string(bytes.TrimSpace(log)) + " Change the code\n" +
+		"  https://example.com/x.go (remote)\n"
+	if out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+}
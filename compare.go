@@ -0,0 +1,249 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// runCompare implements the compare command, reporting the embeds of the
+// markdown files whose rendered content differs between two revisions of
+// the docs and their sources, checked out in temporary worktrees.
+func runCompare(args []string) int {
+	fs := flag.NewFlagSet("embedmd compare", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd compare -base ref [-head ref] [flags] [path ...]\n")
+		fs.PrintDefaults()
+	}
+	o := newCommandFlags(fs)
+	base := fs.String("base", "", "git ref of the docs and sources compared against, such as the target branch of a pull request")
+	head := fs.String("head", "HEAD", "git ref of the docs and sources compared")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := setup(fs, o); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *base == "" {
+		fmt.Fprintln(stderr, "error: -base is required")
+		return 2
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	opts, err := o.embedOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	prefix, err := runGit("rev-parse", "--show-prefix")
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	var embeds [2]map[string][]renderedEmbed
+	code := 0
+	for i, ref := range []string{*base, *head} {
+		wt, err := addWorktree(ref)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		// Paths are relative to the current directory, which is the same
+		// one in the worktree.
+		dir := filepath.Join(wt, filepath.FromSlash(strings.TrimSpace(string(prefix))))
+		var failed bool
+		embeds[i], failed = renderAll(dir, paths, ref, opts...)
+		if failed {
+			code = 1
+		}
+		if err := removeWorktree(wt); err != nil {
+			fmt.Fprintln(stderr, err)
+			code = 2
+		}
+	}
+
+	changed, added, removed := compareEmbeds(embeds[0], embeds[1])
+	fmt.Fprintf(stdout, "%d embeds changed, %d added, %d removed between %s and %s\n", changed, added, removed, *base, *head)
+	return code
+}
+
+// addWorktree checks out the ref in a temporary worktree, returning its
+// directory.
+func addWorktree(ref string) (string, error) {
+	if _, err := runGit("rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		return "", fmt.Errorf("error: unknown revision %q", ref)
+	}
+	dir, err := os.MkdirTemp("", "embedmd-compare-")
+	if err != nil {
+		return "", err
+	}
+	if _, err := runGit("worktree", "add", "--detach", "--quiet", dir, ref); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// removeWorktree removes the worktree in dir.
+func removeWorktree(dir string) error {
+	_, err := runGit("worktree", "remove", "--force", dir)
+	os.RemoveAll(dir)
+	return err
+}
+
+// A renderedEmbed is the content embedded by a command, as rendered.
+type renderedEmbed struct {
+	line    int
+	command string
+	content string
+}
+
+// renderAll renders the embeds of the markdown files in paths, relative to
+// dir, by the path of the files relative to it, reporting whether some of
+// them failed.
+func renderAll(dir string, paths []string, ref string, opts ...embedmd.Option) (map[string][]renderedEmbed, bool) {
+	embeds := map[string][]renderedEmbed{}
+	failed := false
+	for _, p := range paths {
+		docs, err := markdownFiles([]string{filepath.Join(dir, p)})
+		if os.IsNotExist(err) {
+			// The path is only in one of the revisions.
+			continue
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			failed = true
+			continue
+		}
+		for _, doc := range docs {
+			rel, err := filepath.Rel(dir, doc)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				failed = true
+				continue
+			}
+			name := filepath.ToSlash(rel)
+			if embeds[name], err = renderEmbeds(doc, opts...); err != nil {
+				fmt.Fprintf(stderr, "%s at %s:%v\n", name, ref, err)
+				failed = true
+			}
+		}
+	}
+	return embeds, failed
+}
+
+// renderEmbeds processes the document, returning its embeds in order.
+func renderEmbeds(doc string, opts ...embedmd.Option) ([]renderedEmbed, error) {
+	in, err := readFile(doc)
+	if err != nil {
+		return nil, err
+	}
+	var regions []embedmd.MappedRegion
+	opts = append(opts, embedmd.WithBaseDir(filepath.Dir(doc)), embedmd.WithWarnings(func(int, string) {}),
+		embedmd.WithSourceMap(func(r embedmd.MappedRegion) { regions = append(regions, r) }))
+	var out bytes.Buffer
+	if err := embedmd.Process(&out, bytes.NewReader(in), opts...); err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(in), "\n")
+	outLines := strings.SplitAfter(out.String(), "\n")
+	var embeds []renderedEmbed
+	for _, r := range regions {
+		content := strings.Join(outLines[r.OutStart-1:r.OutEnd], "")
+		if n := len(embeds); n > 0 && embeds[n-1].line == r.Line {
+			// Regions stitched by the same command.
+			embeds[n-1].content += content
+			continue
+		}
+		embeds = append(embeds, renderedEmbed{r.Line, strings.TrimSpace(lines[r.Line-1]), content})
+	}
+	return embeds, nil
+}
+
+// compareEmbeds prints the embeds of head whose content differs from base,
+// and those only in one of them, matching them by document and command.
+func compareEmbeds(base, head map[string][]renderedEmbed) (changed, added, removed int) {
+	docs := map[string]bool{}
+	for doc := range base {
+		docs[doc] = true
+	}
+	for doc := range head {
+		docs[doc] = true
+	}
+	names := make([]string, 0, len(docs))
+	for doc := range docs {
+		names = append(names, doc)
+	}
+	sort.Strings(names)
+	for _, doc := range names {
+		// Commands written several times are matched in order.
+		type key struct {
+			command string
+			n       int
+		}
+		byKey := func(embeds []renderedEmbed) (map[key]renderedEmbed, []key) {
+			m, seen := map[key]renderedEmbed{}, map[string]int{}
+			var keys []key
+			for _, e := range embeds {
+				k := key{e.command, seen[e.command]}
+				seen[e.command]++
+				m[k] = e
+				keys = append(keys, k)
+			}
+			return m, keys
+		}
+		before, baseKeys := byKey(base[doc])
+		after, headKeys := byKey(head[doc])
+		for _, k := range headKeys {
+			e := after[k]
+			old, ok := before[k]
+			switch {
+			case !ok:
+				added++
+				fmt.Fprintf(stdout, "added: %s:%d %s\n", doc, e.line, e.command)
+			case old.content != e.content:
+				changed++
+				d, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+					A:       sourceLines([]byte(old.content)),
+					B:       sourceLines([]byte(e.content)),
+					Context: 3,
+				})
+				if err != nil {
+					d = err.Error() + "\n"
+				}
+				fmt.Fprintf(stdout, "changed: %s:%d %s\n%s", doc, e.line, e.command, d)
+			}
+		}
+		for _, k := range baseKeys {
+			if _, ok := after[k]; !ok {
+				removed++
+				fmt.Fprintf(stdout, "removed: %s:%d %s\n", doc, before[k].line, k.command)
+			}
+		}
+	}
+	return changed, added, removed
+}
@@ -0,0 +1,108 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunCompare(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(files map[string]string) {
+		t.Helper()
+		for name, content := range files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	git("init", "-q", "-b", "main")
+	write(map[string]string{
+		"a.go":      "func A() {}\n",
+		"b.go":      "func B() {}\n",
+		"docs/a.md": "# A\n\n[embedmd]:# (../a.go)\n\n[embedmd]:# (../b.go)\n",
+		"docs/b.md": "[embedmd]:# (../b.go)\n",
+	})
+	git("add", "-A")
+	git("commit", "-q", "-m", "base")
+	git("checkout", "-q", "-b", "feature")
+	write(map[string]string{
+		"a.go":      "func A(ctx context.Context) {}\n",
+		"docs/a.md": "# A\n\nMore prose.\n\n[embedmd]:# (../a.go)\n\n[embedmd]:# (../a.go /func/ /{/)\n",
+	})
+	git("commit", "-q", "-am", "feature")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(filepath.Join(dir, "docs")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, configFile), []byte("version: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	var out bytes.Buffer
+	stdout, stderr = &out, &out
+
+	if code := runCompare([]string{"-base", "main", "-head", "feature"}); code != 0 {
+		t.Errorf("expected exit code 0; got %d\n%s", code, out.String())
+	}
+	want := `changed: a.md:5 [embedmd]:# (../a.go)
+@@ -1 +1 @@
+-func A() {}
++func A(ctx context.Context) {}
+added: a.md:7 [embedmd]:# (../a.go /func/ /{/)
+removed: a.md:5 [embedmd]:# (../b.go)
+1 embeds changed, 1 added, 1 removed between main and feature
+`
+	if out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+
+	out.Reset()
+	if code := runCompare(nil); code != 2 {
+		t.Errorf("expected exit code 2 without -base; got %d", code)
+	}
+	if code := runCompare([]string{"-base", "missing"}); code != 2 {
+		t.Errorf("expected exit code 2 for an unknown revision; got %d", code)
+	}
+	if got, want := out.String(), "error: -base is required\nerror: unknown revision \"missing\"\n"; got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
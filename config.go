@@ -0,0 +1,564 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configFile is the name of the config file looked up by default.
+const configFile = ".embedmd.yaml"
+
+// configVersion is the version of the config schema. It is increased every
+// time a backward incompatible change is made to the schema.
+const configVersion = 1
+
+// The config file sets the default value of the flags: every flag not in
+// cliOnly can be set with a key of the same name. Repeatable flags take a
+// sequence of values. Named profiles, selected with the -profile flag, can
+// override any of those values.
+type config struct {
+	path     string
+	values   []configValue
+	profiles map[string][]configValue
+	// requires is the version constraint declared by the project, if any.
+	requires string
+}
+
+type configValue struct {
+	name   string
+	values []string
+}
+
+// flagEnums lists the accepted values of the flags taking a fixed set of
+// values.
+var flagEnums = map[string][]string{
+	"color": {"auto", "always", "never"},
+}
+
+// schemaField describes a key of the config file.
+type schemaField struct {
+	name, kind, usage string
+	enum              []string
+}
+
+// configSchema returns the keys accepted by the config file, other than
+// version, sorted by name.
+func configSchema(fs *flag.FlagSet) []schemaField {
+	var fields []schemaField
+	fs.VisitAll(func(f *flag.Flag) {
+		if cliOnly[f.Name] {
+			return
+		}
+		fields = append(fields, schemaField{name: f.Name, kind: flagKind(f), usage: f.Usage, enum: flagEnums[f.Name]})
+	})
+	return fields
+}
+
+// flagKind returns the JSON Schema type of the values of f.
+func flagKind(f *flag.Flag) string {
+	if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		return "boolean"
+	}
+	if _, ok := f.Value.(*stringList); ok {
+		return "array"
+	}
+	if g, ok := f.Value.(flag.Getter); ok {
+		switch g.Get().(type) {
+		case int, int64, uint, uint64:
+			return "integer"
+		}
+	}
+	return "string"
+}
+
+// jsonSchema returns the config schema as a JSON Schema document.
+func jsonSchema(fs *flag.FlagSet) ([]byte, error) {
+	fields := map[string]interface{}{}
+	for _, f := range configSchema(fs) {
+		p := map[string]interface{}{"type": f.kind, "description": f.usage}
+		if f.kind == "array" {
+			p["items"] = map[string]string{"type": "string"}
+		}
+		if f.enum != nil {
+			p["enum"] = f.enum
+		}
+		fields[f.name] = p
+	}
+
+	props := map[string]interface{}{
+		"version":  map[string]interface{}{"const": configVersion, "description": "version of the config schema"},
+		"requires": map[string]interface{}{"type": "string", "description": "embedmd versions supported by the project, e.g. >=2.3, <3"},
+		"repos": map[string]interface{}{
+			"type":                 "object",
+			"description":          "git repositories embedded with repo://name/path, by name, e.g. github.com/org/repo@ref",
+			"additionalProperties": map[string]string{"type": "string"},
+		},
+		"versions": map[string]interface{}{
+			"type":                 "object",
+			"description":          "versions of the sources documented, by name, as git revisions, e.g. v1.8.0 or main",
+			"additionalProperties": map[string]string{"type": "string"},
+		},
+		"profiles": map[string]interface{}{
+			"type":        "object",
+			"description": "named sets of values selected with the -profile flag",
+			"additionalProperties": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties":           fields,
+			},
+		},
+	}
+	for name, f := range fields {
+		props[name] = f
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "embedmd configuration",
+		"type":                 "object",
+		"required":             []string{"version"},
+		"additionalProperties": false,
+		"properties":           props,
+	}, "", "  ")
+}
+
+// findConfig loads the config file at path or, if path is empty, the closest
+// config file in the current directory or its parents. It returns nil if
+// path is empty and there's no config file.
+func findConfig(fs *flag.FlagSet, path string) (*config, error) {
+	if path != "" {
+		return loadConfig(fs, path)
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		c, err := loadConfig(fs, filepath.Join(dir, configFile))
+		if !errors.Is(err, os.ErrNotExist) {
+			return c, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// loadConfig reads and validates the config file at path.
+func loadConfig(fs *flag.FlagSet, path string) (*config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := parseConfig(fs, string(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", path, err)
+	}
+	c.path = path
+	return c, nil
+}
+
+func parseConfig(fs *flag.FlagSet, doc string) (*config, error) {
+	root, err := parseYAML(doc)
+	if err != nil {
+		return nil, err
+	}
+	if root.kind != yamlMapping {
+		return nil, errorAt(root, "expected a mapping, found a %v", root.kind)
+	}
+
+	fields := map[string]schemaField{}
+	for _, f := range configSchema(fs) {
+		fields[f.name] = f
+	}
+
+	c := &config{profiles: map[string][]configValue{}}
+	hasVersion := false
+	var rest []yamlPair
+	for _, p := range root.pairs {
+		switch p.key.value {
+		case "version":
+			if p.value.kind != yamlScalar || p.value.value != strconv.Itoa(configVersion) {
+				return nil, errorAt(p.value, "unsupported version, expected %d", configVersion)
+			}
+			hasVersion = true
+		case "profiles":
+			if err := c.parseProfiles(fields, p.value); err != nil {
+				return nil, err
+			}
+		case "repos":
+			repos, err := parseRepos(p.value)
+			if err != nil {
+				return nil, err
+			}
+			rest = append(rest, repos)
+		case "versions":
+			versions, err := parseVersions(p.value)
+			if err != nil {
+				return nil, err
+			}
+			rest = append(rest, versions)
+		case "requires":
+			if err := checkRequires(p.value); err != nil {
+				return nil, err
+			}
+			c.requires = p.value.value
+		default:
+			rest = append(rest, p)
+		}
+	}
+	if !hasVersion {
+		return nil, errorAt(root, "missing version, expected %d", configVersion)
+	}
+
+	values, err := parseValues(fields, rest)
+	if err != nil {
+		return nil, err
+	}
+	c.values = values
+	return c, nil
+}
+
+// parseRepos turns the repos mapping, from names to repositories, into the
+// values of the repo flag.
+func parseRepos(n *yamlNode) (yamlPair, error) {
+	return namedValues(n, "repos", "repository", "repo")
+}
+
+// parseVersions turns the versions mapping, from names to git revisions,
+// into the values of the doc-version flag.
+func parseVersions(n *yamlNode) (yamlPair, error) {
+	return namedValues(n, "versions", "version", "doc-version")
+}
+
+// namedValues turns the mapping n of the given field, from names to items,
+// into the name=item values of the repeatable flag.
+func namedValues(n *yamlNode, field, item, flag string) (yamlPair, error) {
+	if n.kind != yamlMapping {
+		return yamlPair{}, errorAt(n, "%s should be a mapping, found a %v", field, n.kind)
+	}
+	seq := &yamlNode{kind: yamlSequence, line: n.line, col: n.col}
+	for _, p := range n.pairs {
+		if p.value.kind != yamlScalar {
+			return yamlPair{}, errorAt(p.value, "%s %s should be a scalar, found a %v", item, p.key.value, p.value.kind)
+		}
+		seq.items = append(seq.items, &yamlNode{kind: yamlScalar, value: p.key.value + "=" + p.value.value, line: p.value.line, col: p.value.col})
+	}
+	return yamlPair{key: &yamlNode{kind: yamlScalar, value: flag, line: n.line, col: n.col}, value: seq}, nil
+}
+
+func (c *config) parseProfiles(fields map[string]schemaField, n *yamlNode) error {
+	if n.kind != yamlMapping {
+		return errorAt(n, "profiles should be a mapping, found a %v", n.kind)
+	}
+	for _, p := range n.pairs {
+		if p.value.kind == yamlNull {
+			c.profiles[p.key.value] = nil
+			continue
+		}
+		if p.value.kind != yamlMapping {
+			return errorAt(p.value, "profile %s should be a mapping, found a %v", p.key.value, p.value.kind)
+		}
+		values, err := parseValues(fields, p.value.pairs)
+		if err != nil {
+			return err
+		}
+		c.profiles[p.key.value] = values
+	}
+	return nil
+}
+
+func parseValues(fields map[string]schemaField, pairs []yamlPair) ([]configValue, error) {
+	var cv []configValue
+	for _, p := range pairs {
+		f, ok := fields[p.key.value]
+		if !ok && runOnly[p.key.value] && !cliOnly[p.key.value] {
+			// Subcommands don't define the flags of the main command.
+			continue
+		}
+		if !ok {
+			return nil, errorAt(p.key, "unknown field %q", p.key.value)
+		}
+		values, err := checkField(f, p.value)
+		if err != nil {
+			return nil, err
+		}
+		cv = append(cv, configValue{name: f.name, values: values})
+	}
+	return cv, nil
+}
+
+// checkField validates the value of a field, returning the values it sets.
+func checkField(f schemaField, n *yamlNode) ([]string, error) {
+	if f.kind == "array" {
+		if n.kind != yamlSequence {
+			return nil, errorAt(n, "%s should be a sequence, found a %v", f.name, n.kind)
+		}
+		var values []string
+		for _, item := range n.items {
+			if item.kind != yamlScalar {
+				return nil, errorAt(item, "%s items should be scalars, found a %v", f.name, item.kind)
+			}
+			values = append(values, item.value)
+		}
+		return values, nil
+	}
+
+	if n.kind != yamlScalar {
+		return nil, errorAt(n, "%s should be a %s, found a %v", f.name, f.kind, n.kind)
+	}
+	if f.kind == "boolean" {
+		if _, err := strconv.ParseBool(n.value); err != nil {
+			return nil, errorAt(n, "%s should be a boolean, found %q", f.name, n.value)
+		}
+	}
+	if f.kind == "integer" {
+		if _, err := strconv.ParseInt(n.value, 10, 64); err != nil {
+			return nil, errorAt(n, "%s should be an integer, found %q", f.name, n.value)
+		}
+	}
+	if f.enum != nil && !contains(f.enum, n.value) {
+		return nil, errorAt(n, "%s should be one of %s, found %q", f.name, strings.Join(f.enum, ", "), n.value)
+	}
+	return []string{n.value}, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// effective returns the values of the config for the given profile, which
+// replace the top level values of the same name.
+func (c *config) effective(profile string) ([]configValue, error) {
+	if profile == "" {
+		return c.values, nil
+	}
+	override, ok := c.profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown profile %q", c.path, profile)
+	}
+	overridden := map[string]bool{}
+	for _, v := range override {
+		overridden[v.name] = true
+	}
+	var values []configValue
+	for _, v := range c.values {
+		if !overridden[v.name] {
+			values = append(values, v)
+		}
+	}
+	return append(values, override...), nil
+}
+
+// apply sets the flags in fs without a source, using the values of the
+// given profile, and records the config file as their source.
+func (c *config) apply(fs *flag.FlagSet, profile string, source map[string]string) error {
+	values, err := c.effective(profile)
+	if err != nil {
+		return err
+	}
+	profiled := map[string]bool{}
+	for _, v := range c.profiles[profile] {
+		profiled[v.name] = true
+	}
+	for _, v := range values {
+		if source[v.name] != "" {
+			continue
+		}
+		for _, val := range v.values {
+			if err := fs.Set(v.name, val); err != nil {
+				return fmt.Errorf("%s: %s: %v", c.path, v.name, err)
+			}
+		}
+		source[v.name] = c.path
+		if profiled[v.name] {
+			source[v.name] = fmt.Sprintf("%s (profile %s)", c.path, profile)
+		}
+	}
+	return nil
+}
+
+// envName returns the name of the environment variable setting a flag.
+func envName(flag string) string {
+	return "EMBEDMD_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// applyEnv sets the flags in fs without a source from their environment
+// variables, and records those as their source. Repeatable flags take a
+// comma separated list of values.
+func applyEnv(fs *flag.FlagSet, source map[string]string) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		name := envName(f.Name)
+		val, ok := os.LookupEnv(name)
+		if !ok || err != nil || source[f.Name] != "" || noEnv[f.Name] {
+			return
+		}
+		values := []string{val}
+		if _, ok := f.Value.(*stringList); ok {
+			values = strings.Split(val, ",")
+		}
+		for _, v := range values {
+			if e := fs.Set(f.Name, v); e != nil {
+				err = fmt.Errorf("error: %s: %v", name, e)
+				return
+			}
+		}
+		source[f.Name] = "env " + name
+	})
+	return err
+}
+
+// resolveFlags completes the flags parsed in fs with the environment and
+// the config file, in that order of precedence, returning the source of
+// every flag that was set.
+func resolveFlags(fs *flag.FlagSet) (map[string]string, error) {
+	source := map[string]string{}
+	fs.Visit(func(f *flag.Flag) { source[f.Name] = "flag" })
+	if err := applyEnv(fs, source); err != nil {
+		return nil, err
+	}
+
+	profile := fs.Lookup("profile").Value.String()
+	c, err := findConfig(fs, fs.Lookup("config").Value.String())
+	if err != nil {
+		return nil, err
+	}
+	if c == nil && profile != "" {
+		return nil, fmt.Errorf("error: no %s found for profile %q", configFile, profile)
+	}
+	if c != nil {
+		if err := c.apply(fs, profile, source); err != nil {
+			return nil, err
+		}
+		if c.requires != "" {
+			warnExperimental(fs, source)
+		}
+	}
+	return source, nil
+}
+
+// warnExperimental prints a warning for each experimental flag that was set.
+func warnExperimental(fs *flag.FlagSet, source map[string]string) {
+	fs.VisitAll(func(f *flag.Flag) {
+		if from := source[f.Name]; from != "" && experimental[f.Name] {
+			fmt.Fprintf(stderr, "warning: -%s (set by %s) is experimental and may behave differently across embedmd versions\n", f.Name, from)
+		}
+	})
+}
+
+func configUsage() {
+	fmt.Fprintf(os.Stderr, `usage: embedmd config <command> [flags]
+
+commands:
+  validate         validate the config file
+  print-effective  print the configuration merging defaults, config file, environment, and flags
+  schema           print the JSON Schema of the config file
+`)
+}
+
+// runConfig runs the config subcommand.
+func runConfig(args []string) int {
+	if len(args) == 0 {
+		configUsage()
+		return 2
+	}
+	fs := flag.NewFlagSet("embedmd config "+args[0], flag.ContinueOnError)
+	newFlags(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	switch args[0] {
+	case "validate":
+		source := map[string]string{}
+		fs.Visit(func(f *flag.Flag) { source[f.Name] = "flag" })
+		if err := applyEnv(fs, source); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		c, err := findConfig(fs, fs.Lookup("config").Value.String())
+		if err == nil && c == nil {
+			err = fmt.Errorf("no %s found", configFile)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "%s: valid\n", c.path)
+	case "print-effective":
+		if err := printEffective(fs); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	case "schema":
+		b, err := jsonSchema(fs)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "%s\n", b)
+	default:
+		configUsage()
+		return 2
+	}
+	return 0
+}
+
+// printEffective prints the value of every config field, as a config file,
+// with comments telling where each value comes from.
+func printEffective(fs *flag.FlagSet) error {
+	source, err := resolveFlags(fs)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "version: %d\n", configVersion)
+	for _, f := range configSchema(fs) {
+		value := fs.Lookup(f.name).Value
+		var s string
+		switch f.kind {
+		case "boolean", "integer":
+			s = value.String()
+		case "array":
+			var quoted []string
+			for _, v := range *value.(*stringList) {
+				quoted = append(quoted, strconv.Quote(v))
+			}
+			s = "[" + strings.Join(quoted, ", ") + "]"
+		default:
+			s = strconv.Quote(value.String())
+		}
+		from := source[f.name]
+		if from == "" {
+			from = "default"
+		}
+		fmt.Fprintf(stdout, "%s: %s # %s\n", f.name, s, from)
+	}
+	return nil
+}
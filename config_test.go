@@ -0,0 +1,326 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	tc := []struct {
+		name   string
+		in     string
+		values []configValue
+		err    string
+	}{
+		{name: "all kinds of fields",
+			in: "version: 1\ncolor: never\naria-labels: true\nstrip-license: [a, b]\n",
+			values: []configValue{
+				{name: "color", values: []string{"never"}},
+				{name: "aria-labels", values: []string{"true"}},
+				{name: "strip-license", values: []string{"a", "b"}},
+			}},
+		{name: "repos",
+			in: "version: 1\nrepos:\n  examples: github.com/org/examples@main\n  tools: ../tools\n",
+			values: []configValue{
+				{name: "repo", values: []string{"examples=github.com/org/examples@main", "tools=../tools"}},
+			}},
+		{name: "repos not a mapping",
+			in:  "version: 1\nrepos: [a]\n",
+			err: "2:8: repos should be a mapping, found a sequence"},
+		{name: "versions",
+			in: "version: 1\nversions:\n  v1: v1.8.0\n  main: main\n",
+			values: []configValue{
+				{name: "doc-version", values: []string{"v1=v1.8.0", "main=main"}},
+			}},
+		{name: "version not a scalar",
+			in:  "version: 1\nversions:\n  v1: [a]\n",
+			err: "3:7: version v1 should be a scalar, found a sequence"},
+		{name: "missing version",
+			in:  "color: never\n",
+			err: "1:1: missing version, expected 1"},
+		{name: "unsupported version",
+			in:  "version: 2\n",
+			err: "1:10: unsupported version, expected 1"},
+		{name: "unknown field",
+			in:  "version: 1\ncolour: never\n",
+			err: `2:1: unknown field "colour"`},
+		{name: "cli only flag",
+			in:  "version: 1\nw: true\n",
+			err: `2:1: unknown field "w"`},
+		{name: "bad enum",
+			in:  "version: 1\ncolor: sometimes\n",
+			err: `2:8: color should be one of auto, always, never, found "sometimes"`},
+		{name: "bad boolean",
+			in:  "version: 1\naria-labels: maybe\n",
+			err: `2:14: aria-labels should be a boolean, found "maybe"`},
+		{name: "bad integer",
+			in:  "version: 1\ntemp-limit: lots\n",
+			err: `2:13: temp-limit should be an integer, found "lots"`},
+		{name: "scalar instead of sequence",
+			in:  "version: 1\nstrip-license: a\n",
+			err: "2:16: strip-license should be a sequence, found a scalar"},
+		{name: "unknown field in profile",
+			in:  "version: 1\nprofiles:\n  ci:\n    colour: never\n",
+			err: `4:5: unknown field "colour"`},
+		{name: "not a mapping",
+			in:  "- a\n",
+			err: "1:1: expected a mapping, found a sequence"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			newFlags(fs)
+			c, err := parseConfig(fs, tt.in)
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if len(c.values) != len(tt.values) {
+				t.Fatalf("case [%s]: expected %d values; got %d", tt.name, len(tt.values), len(c.values))
+			}
+			for i, v := range tt.values {
+				got := c.values[i]
+				if got.name != v.name || strings.Join(got.values, ",") != strings.Join(v.values, ",") {
+					t.Errorf("case [%s]: expected %v; got %v", tt.name, v, got)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, configFile)
+	if err := os.WriteFile(path, []byte("version: 1\nlint-a11y: true\naria-labels: true\nstrip-license: [a]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := newFlags(fs)
+	if err := fs.Parse([]string{"-config", path, "-aria-labels=false", "-strip-license", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := setup(fs, o); err != nil {
+		t.Fatal(err)
+	}
+	if !o.lintA11y {
+		t.Errorf("expected lint-a11y to be set by the config file")
+	}
+	if o.ariaLabels {
+		t.Errorf("expected aria-labels flag to override the config file")
+	}
+	if got := strings.Join(o.stripLicense, ","); got != "b" {
+		t.Errorf("expected strip-license flag to override the config file, got %q", got)
+	}
+}
+
+func TestConfigSubcommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, configFile)
+	if err := os.WriteFile(path, []byte("version: 1\nsummary: true\naria-labels: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Subcommands ignore the flags of the main command they don't define.
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := newCommandFlags(fs)
+	if fs.Lookup("summary") != nil || fs.Lookup("d") != nil {
+		t.Errorf("expected the subcommand not to define -summary and -d")
+	}
+	if err := fs.Parse([]string{"-config", path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := setup(fs, o); err != nil {
+		t.Fatal(err)
+	}
+	if !o.ariaLabels || o.summary {
+		t.Errorf("expected aria-labels only to be set by the config file, got %v and %v", o.ariaLabels, o.summary)
+	}
+}
+
+func TestConfigProfiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, configFile)
+	doc := "version: 1\n" +
+		"lint-a11y: true\n" +
+		"strip-license: [a]\n" +
+		"profiles:\n" +
+		"  ci:\n" +
+		"    color: never\n" +
+		"    strip-license: [b, c]\n" +
+		"  local:\n"
+	if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tc := []struct {
+		name  string
+		args  []string
+		strip string
+		color string
+		err   string
+	}{
+		{name: "no profile", strip: "a", color: "auto"},
+		{name: "empty profile", args: []string{"-profile", "local"}, strip: "a", color: "auto"},
+		{name: "overriding profile", args: []string{"-profile", "ci"}, strip: "b,c", color: "never"},
+		{name: "flags override profile", args: []string{"-profile", "ci", "-color", "always"}, strip: "b,c", color: "always"},
+		{name: "unknown profile", args: []string{"-profile", "prod"}, err: path + `: unknown profile "prod"`},
+	}
+
+	defer func(mode string) { colorMode = mode }(colorMode)
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			o := newFlags(fs)
+			if err := fs.Parse(append([]string{"-config", path}, tt.args...)); err != nil {
+				t.Fatal(err)
+			}
+			err := setup(fs, o)
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if !o.lintA11y {
+				t.Errorf("case [%s]: expected lint-a11y from the top level values", tt.name)
+			}
+			if got := strings.Join(o.stripLicense, ","); got != tt.strip {
+				t.Errorf("case [%s]: expected strip-license %q; got %q", tt.name, tt.strip, got)
+			}
+			if colorMode != tt.color {
+				t.Errorf("case [%s]: expected color %q; got %q", tt.name, tt.color, colorMode)
+			}
+		})
+	}
+}
+
+func TestConfigEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, configFile)
+	doc := "version: 1\ncolor: never\nstrip-license: [a]\nprofiles:\n  ci:\n    lint-a11y: true\n"
+	if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EMBEDMD_CONFIG", path)
+	t.Setenv("EMBEDMD_PROFILE", "ci")
+	t.Setenv("EMBEDMD_COLOR", "always")
+	t.Setenv("EMBEDMD_STRIP_LICENSE", "b,c")
+	t.Setenv("EMBEDMD_ARIA_LABELS", "true")
+
+	defer func(mode string) { colorMode = mode }(colorMode)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := newFlags(fs)
+	if err := fs.Parse([]string{"-aria-labels=false"}); err != nil {
+		t.Fatal(err)
+	}
+	source, err := resolveFlags(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if colorMode != "always" {
+		t.Errorf("expected the environment to override the config file, got color %q", colorMode)
+	}
+	if got := strings.Join(o.stripLicense, ","); got != "b,c" {
+		t.Errorf("expected strip-license from the environment, got %q", got)
+	}
+	if o.ariaLabels {
+		t.Errorf("expected flags to override the environment")
+	}
+	if !o.lintA11y {
+		t.Errorf("expected the profile from the environment to be used")
+	}
+	for name, want := range map[string]string{
+		"color":       "env EMBEDMD_COLOR",
+		"aria-labels": "flag",
+		"lint-a11y":   path + " (profile ci)",
+	} {
+		if source[name] != want {
+			t.Errorf("expected source of %s to be %q; got %q", name, want, source[name])
+		}
+	}
+
+	t.Setenv("EMBEDMD_LINT_A11Y", "maybe")
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	newFlags(fs)
+	if _, err := resolveFlags(fs); err == nil || !strings.HasPrefix(err.Error(), "error: EMBEDMD_LINT_A11Y:") {
+		t.Errorf("expected an error for an invalid boolean, got %v", err)
+	}
+}
+
+func TestConfigAllowExec(t *testing.T) {
+	t.Setenv("EMBEDMD_ALLOW_EXEC", "sh")
+	t.Setenv("EMBEDMD_EXEC_ENV", "GITHUB_TOKEN")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := newFlags(fs)
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolveFlags(fs); err != nil {
+		t.Fatal(err)
+	}
+	if len(o.allowExec) > 0 || len(o.execEnv) > 0 {
+		t.Errorf("expected programs to be only allowed on the command line, got %q and %q", o.allowExec, o.execEnv)
+	}
+
+	path := filepath.Join(t.TempDir(), configFile)
+	if err := os.WriteFile(path, []byte("version: 1\nallow-exec: [sh]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EMBEDMD_CONFIG", path)
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	o = newFlags(fs)
+	if _, err := resolveFlags(fs); err == nil && len(o.allowExec) > 0 {
+		t.Errorf("expected allow-exec to be refused in the config file, got %q", o.allowExec)
+	}
+}
+
+func TestRunConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, configFile)
+	if err := os.WriteFile(path, []byte("version: 1\ncolor: never\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	defer func(mode string, words bool) { colorMode, wordDiffs = mode, words }(colorMode, wordDiffs)
+	var out bytes.Buffer
+	stdout, stderr = &out, &out
+
+	if code := runConfig([]string{"validate", "-config", path}); code != 0 {
+		t.Errorf("expected validate to succeed, got %d: %s", code, out.String())
+	}
+	out.Reset()
+	if code := runConfig([]string{"print-effective", "-config", path, "-word-diff"}); code != 0 {
+		t.Fatalf("expected print-effective to succeed, got %d: %s", code, out.String())
+	}
+	for _, want := range []string{
+		`color: "never" # ` + path,
+		"word-diff: true # flag",
+		"aria-labels: false # default",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected effective config to contain %q; got\n%s", want, out.String())
+		}
+	}
+	out.Reset()
+	if code := runConfig([]string{"schema"}); code != 0 || !strings.Contains(out.String(), `"strip-license"`) {
+		t.Errorf("expected schema to list strip-license, got %d: %s", code, out.String())
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// interactive is set to ask how to resolve the blocks edited by hand whose
+// source changed too.
+var interactive bool
+
+// answers reads the answers to the questions asked in interactive mode.
+var answers *bufio.Reader
+
+// askConflicts returns an option asking on the standard input how to resolve
+// the conflicts of the file at path: merging the edit with the changes of the
+// source, keeping the edit, or taking the source.
+func askConflicts(path string) embedmd.Option {
+	return embedmd.WithConflictResolver(func(c embedmd.Conflict) ([]byte, error) {
+		if answers == nil {
+			answers = bufio.NewReader(stdin)
+		}
+		fmt.Fprintf(stderr, "%s:%d: block was edited by hand and %s changed:\n", filepath.ToSlash(path), c.Line, c.Path)
+		d, err := diff(string(c.Edited), string(c.Generated))
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprint(stderr, d)
+		choices := "[k]eep the edit, [t]ake the source"
+		if c.Base != nil {
+			choices = "[m]erge, " + choices
+		}
+		for {
+			fmt.Fprintf(stderr, "%s? ", choices)
+			answer, err := answers.ReadString('\n')
+			if err != nil && answer == "" {
+				return nil, errors.New("no answer for the block edited by hand")
+			}
+			switch strings.TrimSpace(answer) {
+			case "m":
+				if c.Base == nil {
+					continue
+				}
+				merged, conflicts := embedmd.Merge3(c.Base, c.Edited, c.Generated)
+				if conflicts {
+					fmt.Fprintf(stderr, "%s:%d: merged with conflicts, marked with <<<<<<< and >>>>>>>\n", filepath.ToSlash(path), c.Line)
+				}
+				return merged, nil
+			case "k":
+				return c.Edited, nil
+			case "t":
+				return c.Generated, nil
+			}
+		}
+	})
+}
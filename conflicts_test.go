@@ -0,0 +1,72 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+func TestAskConflicts(t *testing.T) {
+	dir := t.TempDir()
+	code, doc := filepath.Join(dir, "code.go"), filepath.Join(dir, "doc.md")
+	if err := os.WriteFile(code, []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var generated bytes.Buffer
+	if err := embedmd.Process(&generated, strings.NewReader("[embedmd]:# (code.go)\n"), embedmd.WithBaseDir(dir), embedmd.WithChecksums()); err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(generated.String(), "a\n", "A\n", 1)
+	if err := os.WriteFile(code, []byte("b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(i io.Reader, e io.Writer) { stdin, stderr, answers = i, e, nil }(stdin, stderr)
+	tc := []struct {
+		name, answers, block, err string
+	}{
+		{name: "keep", answers: "k\n", block: "```go\nA\n```\n"},
+		{name: "take", answers: "m\nt\n", block: "```go\nb\n```\n"},
+		{name: "no answer", answers: "x\n", err: "1: no answer for the block edited by hand"},
+	}
+	for _, tt := range tc {
+		if err := os.WriteFile(doc, []byte(edited), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var prompts bytes.Buffer
+		stdin, stderr, answers = strings.NewReader(tt.answers), &prompts, nil
+		_, err := processFile(doc, true, false, embedmd.WithChecksums(), askConflicts(doc))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		b, err := os.ReadFile(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), tt.block) {
+			t.Errorf("case [%s]: expected block %q, got %q", tt.name, tt.block, b)
+		}
+		if !strings.Contains(prompts.String(), "doc.md:1: block was edited by hand and code.go changed") ||
+			!strings.Contains(prompts.String(), "[k]eep the edit, [t]ake the source? ") {
+			t.Errorf("case [%s]: unexpected prompts %q", tt.name, prompts.String())
+		}
+	}
+}
@@ -0,0 +1,371 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// runConfluence implements the confluence command, publishing the given
+// markdown files, or those found in the given directories, to the pages of a
+// Confluence space with the code their commands embed today.
+func runConfluence(args []string) int {
+	fs := flag.NewFlagSet("embedmd confluence", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd confluence -base-url url -space key [flags] [path ...]\n")
+		fs.PrintDefaults()
+	}
+	baseURL := fs.String("base-url", "", "URL of the Confluence site, such as https://example.atlassian.net/wiki")
+	space := fs.String("space", "", "key of the space the pages are published in")
+	parent := fs.String("parent", "", "ID of the page new pages are created under, instead of the home page of the space")
+	user := fs.String("user", "", "user the token belongs to, such as the email of an Atlassian account, to authenticate with basic authentication instead of a bearer token")
+	tokenEnv := fs.String("token-env", "CONFLUENCE_TOKEN", "environment variable holding the API token, or the personal access token of Confluence Data Center")
+	dryRun := fs.Bool("dry-run", false, "report the pages that would be created or updated, without changing them")
+	o := newCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := setup(fs, o); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	switch {
+	case *baseURL == "":
+		fmt.Fprintln(stderr, "error: missing -base-url")
+		return 2
+	case *space == "":
+		fmt.Fprintln(stderr, "error: missing -space")
+		return 2
+	}
+	token := os.Getenv(*tokenEnv)
+	if token == "" {
+		fmt.Fprintf(stderr, "error: environment variable %s of the Confluence token is not set\n", *tokenEnv)
+		return 2
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	docs, err := markdownFiles(paths)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	opts, err := o.embedOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	client, err := o.network().Client()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := &confluence{base: strings.TrimSuffix(*baseURL, "/"), space: *space, parent: *parent, client: client}
+	c.auth = func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	if *user != "" {
+		c.auth = func(r *http.Request) { r.SetBasicAuth(*user, token) }
+	}
+
+	// Pages are published in order, so those created under the same parent
+	// keep the order of their files.
+	results := make([]publishResult, len(docs))
+	for i, doc := range docs {
+		results[i] = c.publish(doc, *dryRun, opts...)
+	}
+	return reportPublished(results, *dryRun)
+}
+
+// confluence publishes pages to a space through the REST API of Confluence.
+type confluence struct {
+	base, space, parent string
+	client              *http.Client
+	auth                func(*http.Request)
+}
+
+// confluencePage is a page of Confluence, as returned by its REST API.
+type confluencePage struct {
+	ID      string `json:"id"`
+	Version struct {
+		Number  int    `json:"number"`
+		Message string `json:"message"`
+	} `json:"version"`
+}
+
+// publishResult is the outcome of publishing a markdown file.
+type publishResult struct {
+	doc, title string
+	// action is what was done to the page: created, updated, or unchanged.
+	action string
+	err    error
+}
+
+// publishMessage prefixes the checksum of the content published, recorded
+// as the message of the versions of the pages, so unchanged pages aren't
+// updated again.
+const publishMessage = "embedmd "
+
+// publish creates or updates the page of doc, titled after its first level
+// one heading, or its name if it has none.
+func (c *confluence) publish(doc string, dryRun bool, opts ...embedmd.Option) publishResult {
+	r := publishResult{doc: doc}
+	in, err := os.ReadFile(doc)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	opts = append([]embedmd.Option{embedmd.WithBaseDir(filepath.Dir(doc)), warnings(doc)}, opts...)
+	var out bytes.Buffer
+	if err := embedmd.Process(&out, bytes.NewReader(in), opts...); err != nil {
+		r.err = fmt.Errorf("%s:%v", filepath.ToSlash(doc), err)
+		return r
+	}
+	title, body := confluenceStorage(out.String())
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(doc), filepath.Ext(doc))
+	}
+	r.title = title
+	sum := sha256.Sum256([]byte(title + "\n" + body))
+	message := publishMessage + hex.EncodeToString(sum[:6])
+
+	page, err := c.find(title)
+	switch {
+	case err != nil:
+		r.err = err
+	case page == nil:
+		r.action = "created"
+		if !dryRun {
+			r.err = c.create(title, body, message)
+		}
+	case page.Version.Message == message:
+		r.action = "unchanged"
+	default:
+		r.action = "updated"
+		if !dryRun {
+			r.err = c.update(page, title, body, message)
+		}
+	}
+	return r
+}
+
+// find returns the page of the space with the given title, or nil if there
+// is none.
+func (c *confluence) find(title string) (*confluencePage, error) {
+	q := url.Values{"spaceKey": {c.space}, "title": {title}, "expand": {"version"}}
+	var found struct {
+		Results []confluencePage `json:"results"`
+	}
+	if err := c.do("GET", "/rest/api/content?"+q.Encode(), nil, &found); err != nil {
+		return nil, fmt.Errorf("could not find page %q: %v", title, err)
+	}
+	if len(found.Results) == 0 {
+		return nil, nil
+	}
+	return &found.Results[0], nil
+}
+
+// create creates a page in the space, under the parent page if set.
+func (c *confluence) create(title, body, message string) error {
+	page := c.content(title, body, 1, message)
+	if c.parent != "" {
+		page["ancestors"] = []map[string]string{{"id": c.parent}}
+	}
+	if err := c.do("POST", "/rest/api/content", page, nil); err != nil {
+		return fmt.Errorf("could not create page %q: %v", title, err)
+	}
+	return nil
+}
+
+// update replaces the content of the page with a new version, so the
+// previous ones are kept in its history.
+func (c *confluence) update(page *confluencePage, title, body, message string) error {
+	content := c.content(title, body, page.Version.Number+1, message)
+	if err := c.do("PUT", "/rest/api/content/"+url.PathEscape(page.ID), content, nil); err != nil {
+		return fmt.Errorf("could not update page %q: %v", title, err)
+	}
+	return nil
+}
+
+// content returns the representation of a page sent to the REST API.
+func (c *confluence) content(title, body string, version int, message string) map[string]any {
+	return map[string]any{
+		"type":    "page",
+		"title":   title,
+		"space":   map[string]string{"key": c.space},
+		"version": map[string]any{"number": version, "message": message},
+		"body": map[string]any{
+			"storage": map[string]string{"value": body, "representation": "storage"},
+		},
+	}
+}
+
+// do sends a request to the REST API with the JSON encoding of in, if not
+// nil, and decodes the response into out, if not nil.
+func (c *confluence) do(method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.auth(req)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("status %s", res.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// reportPublished prints what was done to the page of each file, returning
+// the exit code of the command: 1 if a page couldn't be published.
+func reportPublished(results []publishResult, dryRun bool) int {
+	counts := map[string]int{}
+	failed := 0
+	for _, r := range results {
+		doc := filepath.ToSlash(r.doc)
+		if r.err != nil {
+			failed++
+			fmt.Fprintf(stderr, "%s: could not publish: %v\n", doc, r.err)
+			continue
+		}
+		counts[r.action]++
+		if r.action == "unchanged" {
+			continue
+		}
+		if dryRun {
+			fmt.Fprintf(stdout, "%s: page %q would be %s\n", doc, r.title, r.action)
+		} else {
+			fmt.Fprintf(stdout, "%s: page %q %s\n", doc, r.title, r.action)
+		}
+	}
+	fmt.Fprintf(stdout, "%d pages created, %d updated, %d unchanged, %d failed\n", counts["created"], counts["updated"], counts["unchanged"], failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// confluenceStorage converts the markdown md to the storage format of
+// Confluence, returning the text of its first level one heading, which is
+// removed to title the page, separately. Code blocks become code macros.
+func confluenceStorage(md string) (title, body string) {
+	title, blocks := parseBlocks(md)
+	var b strings.Builder
+	writeStorage(&b, blocks)
+	return title, b.String()
+}
+
+// writeStorage writes the blocks in the storage format of Confluence,
+// grouping consecutive list items in lists.
+func writeStorage(b *strings.Builder, blocks []mdBlock) {
+	for i, bl := range blocks {
+		list := map[mdKind]string{mdBulleted: "ul", mdNumbered: "ol"}[bl.kind]
+		if list != "" && (i == 0 || blocks[i-1].kind != bl.kind) {
+			fmt.Fprintf(b, "<%s>", list)
+		}
+		switch bl.kind {
+		case mdParagraph:
+			fmt.Fprintf(b, "<p>%s</p>", storageText(bl.text))
+		case mdHeading:
+			fmt.Fprintf(b, "<h%d>%s</h%d>", bl.level, storageText(bl.text), bl.level)
+		case mdBulleted, mdNumbered:
+			fmt.Fprintf(b, "<li>%s", storageText(bl.text))
+			writeStorage(b, bl.children)
+			b.WriteString("</li>")
+		case mdQuote:
+			b.WriteString("<blockquote>")
+			writeStorage(b, bl.children)
+			b.WriteString("</blockquote>")
+		case mdRule:
+			b.WriteString("<hr/>")
+		case mdCode:
+			writeCodeMacro(b, bl.lang, bl.code)
+		}
+		if list != "" && (i == len(blocks)-1 || blocks[i+1].kind != bl.kind) {
+			fmt.Fprintf(b, "</%s>", list)
+		}
+	}
+}
+
+// writeCodeMacro writes code as a code macro, highlighted as lang if set.
+func writeCodeMacro(b *strings.Builder, lang, code string) {
+	b.WriteString(`<ac:structured-macro ac:name="code">`)
+	if lang != "" {
+		fmt.Fprintf(b, `<ac:parameter ac:name="language">%s</ac:parameter>`, html.EscapeString(strings.ToLower(lang)))
+	}
+	// CDATA sections can't contain their end marker, so it's split across
+	// two sections.
+	code = strings.ReplaceAll(code, "]]>", "]]]]><![CDATA[>")
+	fmt.Fprintf(b, "<ac:plain-text-body><![CDATA[%s]]></ac:plain-text-body></ac:structured-macro>", code)
+}
+
+// storageText converts the inline markdown of s, escaping the rest.
+func storageText(s string) string {
+	var b strings.Builder
+	for _, sp := range parseInline(s) {
+		text := html.EscapeString(sp.text)
+		switch {
+		case sp.code:
+			text = "<code>" + text + "</code>"
+		case sp.strong:
+			text = "<strong>" + text + "</strong>"
+		case sp.emphasis:
+			text = "<em>" + text + "</em>"
+		}
+		switch {
+		case sp.image:
+			fmt.Fprintf(&b, `<ac:image><ri:url ri:value="%s"/></ac:image>`, html.EscapeString(sp.link))
+		case sp.link != "":
+			fmt.Fprintf(&b, `<a href="%s">%s</a>`, html.EscapeString(sp.link), text)
+		default:
+			b.WriteString(text)
+		}
+	}
+	return b.String()
+}
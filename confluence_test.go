@@ -0,0 +1,203 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestConfluenceStorage(t *testing.T) {
+	code := func(lang, text string) string {
+		param := ""
+		if lang != "" {
+			param = `<ac:parameter ac:name="language">` + lang + `</ac:parameter>`
+		}
+		return `<ac:structured-macro ac:name="code">` + param + "<ac:plain-text-body><![CDATA[" + text + "]]></ac:plain-text-body></ac:structured-macro>"
+	}
+	tc := []struct {
+		name, in, title, body string
+	}{
+		{name: "title", in: "# Usage\n\nRun it.\n\n## Flags\n", title: "Usage", body: "<p>Run it.</p><h2>Flags</h2>"},
+		{name: "second title", in: "# A\n\n# B\n", title: "A", body: "<h1>B</h1>"},
+		{name: "paragraphs", in: "one\ntwo\n\nthree & <four>\n", body: "<p>one two</p><p>three &amp; &lt;four&gt;</p>"},
+		{name: "inline", in: "Use `a<b`, **bold**, *em*, snake_case_name, and [the docs](https://x.dev/?a=1&b=2).\n",
+			body: `<p>Use <code>a&lt;b</code>, <strong>bold</strong>, <em>em</em>, snake_case_name, and <a href="https://x.dev/?a=1&amp;b=2">the docs</a>.</p>`},
+		{name: "autolink and image", in: "See <https://x.dev> ![logo](logo.png)\n",
+			body: `<p>See <a href="https://x.dev">https://x.dev</a> <ac:image><ri:url ri:value="logo.png"/></ac:image></p>`},
+		{name: "command and code", in: "[embedmd]:# (main.go /func main/ $)\n```go {2} showLineNumbers=3\nfunc main() {\n}\n```\n",
+			body: code("go", "func main() {\n}")},
+		{name: "comments", in: "<!-- embedmd block start -->\ntext\n<!--\nmulti\nline -->\n<!-- embedmd block end -->\n", body: "<p>text</p>"},
+		{name: "cdata end", in: "~~~\na]]>b\n~~~\n", body: code("", "a]]]]><![CDATA[>b")},
+		{name: "indented code", in: "text\n\n    x := 1\n\n    y := 2\n\nmore\n", body: "<p>text</p>" + code("", "x := 1\n\ny := 2") + "<p>more</p>"},
+		{name: "lists", in: "- a\n- b\n  continued\n\n1. c\n2. d\n\nafter\n",
+			body: "<ul><li>a</li><li>b continued</li></ul><ol><li>c</li><li>d</li></ol><p>after</p>"},
+		{name: "code in list", in: "1. Run:\n   ```sh\n   make\n   ```\n2. Done\n",
+			body: "<ol><li>Run:" + code("sh", "make") + "</li><li>Done</li></ol>"},
+		{name: "quote", in: "> quoted\n> ```go\n> x\n> ```\n", body: "<blockquote><p>quoted</p>" + code("go", "x") + "</blockquote>"},
+		{name: "rule", in: "a\n\n---\n\nb\n", body: "<p>a</p><hr/><p>b</p>"},
+	}
+	for _, tt := range tc {
+		title, body := confluenceStorage(tt.in)
+		if title != tt.title || body != tt.body {
+			t.Errorf("case [%s]: expected title %q and body\n%q\ngot %q and\n%q", tt.name, tt.title, tt.body, title, body)
+		}
+	}
+}
+
+func TestRunConfluence(t *testing.T) {
+	type page struct {
+		ID      string `json:"id"`
+		Title   string `json:"title"`
+		Version struct {
+			Number  int    `json:"number"`
+			Message string `json:"message"`
+		} `json:"version"`
+		Ancestors []struct {
+			ID string `json:"id"`
+		} `json:"ancestors"`
+		Body struct {
+			Storage struct {
+				Value string `json:"value"`
+			} `json:"storage"`
+		} `json:"body"`
+	}
+	var mu sync.Mutex
+	pages := map[string]*page{}
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if user, pass, ok := r.BasicAuth(); !ok || user != "me@example.com" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/wiki/rest/api/content":
+			if r.URL.Query().Get("spaceKey") != "DOCS" {
+				t.Errorf("unexpected space in %s", r.URL)
+			}
+			res := struct {
+				Results []*page `json:"results"`
+			}{Results: []*page{}}
+			if p, ok := pages[r.URL.Query().Get("title")]; ok {
+				res.Results = append(res.Results, p)
+			}
+			json.NewEncoder(w).Encode(res)
+		case r.Method == "POST" && r.URL.Path == "/wiki/rest/api/content":
+			var p page
+			json.NewDecoder(r.Body).Decode(&p)
+			p.ID = "1" + p.Title
+			pages[p.Title] = &p
+		case r.Method == "PUT" && strings.HasPrefix(r.URL.Path, "/wiki/rest/api/content/"):
+			var p page
+			json.NewDecoder(r.Body).Decode(&p)
+			old := pages[p.Title]
+			if old == nil || r.URL.Path != "/wiki/rest/api/content/"+old.ID || p.Version.Number != old.Version.Number+1 {
+				http.Error(w, "version conflict", http.StatusConflict)
+				return
+			}
+			p.ID = old.ID
+			pages[p.Title] = &p
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"code.go":       "package main\n\nfunc main() {\n\tprintln(\"v1\")\n}\n",
+		"docs/usage.md": "# Usage\n\n[embedmd]:# (../code.go /func main/ $)\n",
+		"docs/faq.md":   "No title.\n",
+		configFile:      "version: 1\n",
+	}
+	write := func(name, content string) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		write(name, content)
+	}
+	t.Setenv("TEST_CONFLUENCE_TOKEN", "secret")
+
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	run := func(extra ...string) (int, string) {
+		var out bytes.Buffer
+		stdout, stderr = &out, &out
+		args := append([]string{"-config", filepath.Join(dir, configFile), "-base-url", server.URL + "/wiki/", "-space", "DOCS", "-parent", "42",
+			"-user", "me@example.com", "-token-env", "TEST_CONFLUENCE_TOKEN", "-no-cache"}, extra...)
+		code := runConfluence(append(args, filepath.Join(dir, "docs")))
+		return code, strings.ReplaceAll(out.String(), filepath.ToSlash(dir)+"/", "")
+	}
+
+	code, out := run("-dry-run")
+	if code != 0 || !strings.Contains(out, "docs/usage.md: page \"Usage\" would be created\n") || len(pages) != 0 {
+		t.Errorf("dry run: expected pages to be reported only, got code %d, %d pages, output\n%s", code, len(pages), out)
+	}
+	code, out = run()
+	if code != 0 || !strings.HasSuffix(out, "2 pages created, 0 updated, 0 unchanged, 0 failed\n") {
+		t.Errorf("first run: got code %d and output\n%s", code, out)
+	}
+	usage := pages["Usage"]
+	if usage == nil || pages["faq"] == nil {
+		t.Fatalf("expected pages Usage and faq, got %v", pages)
+	}
+	if !strings.Contains(usage.Body.Storage.Value, `<ac:parameter ac:name="language">go</ac:parameter><ac:plain-text-body><![CDATA[func main() {`+"\n\tprintln(\"v1\")") {
+		t.Errorf("expected the embedded code in a code macro, got %s", usage.Body.Storage.Value)
+	}
+	if len(usage.Ancestors) != 1 || usage.Ancestors[0].ID != "42" {
+		t.Errorf("expected the page under the parent 42, got %+v", usage.Ancestors)
+	}
+
+	code, out = run()
+	if code != 0 || !strings.HasSuffix(out, "0 pages created, 0 updated, 2 unchanged, 0 failed\n") {
+		t.Errorf("second run: expected no change, got code %d and output\n%s", code, out)
+	}
+
+	write("code.go", "package main\n\nfunc main() {\n\tprintln(\"v2\")\n}\n")
+	mu.Lock()
+	requests = nil
+	mu.Unlock()
+	code, out = run()
+	if code != 0 || !strings.Contains(out, "docs/usage.md: page \"Usage\" updated\n") {
+		t.Errorf("third run: expected the page updated, got code %d and output\n%s", code, out)
+	}
+	if p := pages["Usage"]; p.Version.Number != 2 || !strings.Contains(p.Body.Storage.Value, "v2") {
+		t.Errorf("expected version 2 of the page with the new code, got %d:\n%s", p.Version.Number, p.Body.Storage.Value)
+	}
+	if got := strings.Join(requests, ","); got != "GET /wiki/rest/api/content,GET /wiki/rest/api/content,PUT /wiki/rest/api/content/1Usage" {
+		t.Errorf("expected only the changed page to be updated, got requests %s", got)
+	}
+
+	t.Setenv("TEST_CONFLUENCE_TOKEN", "wrong")
+	code, out = run()
+	if code != 1 || !strings.Contains(out, "docs/faq.md: could not publish: could not find page \"faq\": status 401 Unauthorized\n") {
+		t.Errorf("expected failures with a wrong token, got code %d and output\n%s", code, out)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conformance holds the corpus of documents whose output embedmd
+// guarantees, and runs implementations of embedmd against it, so that
+// alternative implementations and plugins can check they're compatible.
+//
+// Each case of the corpus is a directory holding the document processed,
+// in.md, the files it embeds, and the expected output, out.md. Processing
+// a document must give the same output on every run, and processing the
+// output again must not change it.
+package conformance
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"testing"
+)
+
+// Version is the version of the corpus, incremented when the output
+// expected by cases changes, rather than cases being added.
+const Version = 1
+
+//go:embed testdata/corpus
+var corpus embed.FS
+
+// A Case is a document of the corpus.
+type Case struct {
+	// Name is the name of the case.
+	Name string
+	// Input is the document processed, and Output its expected output.
+	Input, Output []byte
+	// Files holds the files embedded by the document, which resolves
+	// relative paths from its root.
+	Files fs.FS
+}
+
+// A ProcessFunc processes the document in, embedding the files of files,
+// and returns its output.
+type ProcessFunc func(in []byte, files fs.FS) ([]byte, error)
+
+// Cases returns the cases of the corpus, sorted by name.
+func Cases() ([]Case, error) {
+	dirs, err := fs.ReadDir(corpus, "testdata/corpus")
+	if err != nil {
+		return nil, err
+	}
+	var cases []Case
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		dir := path.Join("testdata/corpus", d.Name())
+		c := Case{Name: d.Name()}
+		if c.Input, err = fs.ReadFile(corpus, path.Join(dir, "in.md")); err != nil {
+			return nil, err
+		}
+		if c.Output, err = fs.ReadFile(corpus, path.Join(dir, "out.md")); err != nil {
+			return nil, err
+		}
+		if c.Files, err = fs.Sub(corpus, dir); err != nil {
+			return nil, err
+		}
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
+
+// Check processes the document of the case, returning an error if the
+// output isn't the expected one, changes from one run to the next, or
+// changes when processed again.
+func (c Case) Check(process ProcessFunc) error {
+	got, err := process(c.Input, c.Files)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, c.Output) {
+		return fmt.Errorf("expected output\n%s\ngot\n%s", c.Output, got)
+	}
+	for i := 0; i < 3; i++ {
+		again, err := process(c.Input, c.Files)
+		if err != nil {
+			return fmt.Errorf("run %d: %v", i+2, err)
+		}
+		if !bytes.Equal(again, got) {
+			return fmt.Errorf("output changed on run %d:\n%s", i+2, again)
+		}
+	}
+	again, err := process(got, c.Files)
+	if err != nil {
+		return fmt.Errorf("processing the output: %v", err)
+	}
+	if !bytes.Equal(again, got) {
+		return fmt.Errorf("processing the output changed it:\n%s", again)
+	}
+	return nil
+}
+
+// Run checks every case of the corpus in a subtest of t named after it.
+func Run(t *testing.T, process ProcessFunc) {
+	t.Helper()
+	cases, err := Cases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			if err := c.Check(process); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"bytes"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestCases(t *testing.T) {
+	cases, err := Cases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Fatal("expected cases in the corpus")
+	}
+	for _, c := range cases {
+		if len(c.Input) == 0 || len(c.Output) == 0 {
+			t.Errorf("case %s: expected an input and an output", c.Name)
+		}
+		if _, err := fs.Stat(c.Files, "in.md"); err != nil {
+			t.Errorf("case %s: expected its files to hold in.md: %v", c.Name, err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	c := Case{Name: "test", Input: []byte("in\n"), Output: []byte("out\n")}
+	tc := []struct {
+		name    string
+		process ProcessFunc
+		err     string
+	}{
+		{name: "expected",
+			process: func(in []byte, _ fs.FS) ([]byte, error) { return []byte("out\n"), nil }},
+		{name: "unexpected",
+			process: func(in []byte, _ fs.FS) ([]byte, error) { return in, nil },
+			err:     "expected output\nout\n\ngot\nin\n"},
+		{name: "unstable",
+			process: func() ProcessFunc {
+				n := 0
+				return func(in []byte, _ fs.FS) ([]byte, error) {
+					n++
+					return []byte(strings.Repeat("out\n", min(n, 2))), nil
+				}
+			}(),
+			err: "output changed on run 2:\nout\nout\n"},
+		{name: "not idempotent",
+			process: func(in []byte, _ fs.FS) ([]byte, error) {
+				if bytes.Equal(in, c.Output) {
+					return nil, nil
+				}
+				return c.Output, nil
+			},
+			err: "processing the output changed it:\n"},
+	}
+	for _, tt := range tc {
+		err := c.Check(tt.process)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("case [%s]: expected error %q; got %v", tt.name, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+// SPDX-License-Identifier: MIT
+
+package lib
+
+// Answer is the answer.
+const Answer = 42
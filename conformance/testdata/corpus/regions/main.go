@@ -0,0 +1,8 @@
+package main
+
+func main() {
+	// embedmd:begin setup
+	x := 1
+	// embedmd:end setup
+	_ = x
+}
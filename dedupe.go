@@ -0,0 +1,60 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// includeOptions returns the option replacing the code blocks repeated
+// across the given files with references to shared files, as set with
+// -dedupe. The files are processed a first time, without writing anything,
+// to find the blocks embedded more than once.
+func (o *options) includeOptions(paths []string, opts ...embedmd.Option) ([]embedmd.Option, error) {
+	in := embedmd.Includes{Dialect: o.dedupe, Dir: o.dedupeDir, Path: o.dedupePath}
+	if in.Dir == "" {
+		in.Dir = defaultIncludeDirs[o.dedupe]
+	}
+	seen := map[string]int{}
+	counting := in
+	counting.Shared = func(hash string) bool {
+		seen[hash]++
+		return false
+	}
+	for _, path := range paths {
+		b, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		popts := append([]embedmd.Option{embedmd.WithBaseDir(filepath.Dir(path))}, opts...)
+		popts = append(popts, embedmd.WithIncludes(counting))
+		if err := embedmd.Process(io.Discard, bytes.NewReader(b), popts...); err != nil {
+			return nil, fmt.Errorf("%s:%v", filepath.ToSlash(path), err)
+		}
+	}
+	in.Shared = func(hash string) bool { return seen[hash] > 1 }
+	return append(opts, embedmd.WithIncludes(in)), nil
+}
+
+// defaultIncludeDirs are the directories of the shared files of each
+// dialect, where their renderer finds them by default.
+var defaultIncludeDirs = map[string]string{
+	"jekyll": "_includes/embedmd",
+	"mkdocs": "snippets",
+}
@@ -0,0 +1,86 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDedupe(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"shared.go": "var shared = 1\n",
+		"unique.go": "var unique = 2\n",
+		"docs/a.md": "[embedmd]:# (../shared.go)\n\n[embedmd]:# (../unique.go)\n",
+		"docs/b.md": "[embedmd]:# (../shared.go)\n",
+		"docs/c.md": "No commands here.\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("embedmd", flag.ContinueOnError)
+	o := newFlags(fs)
+	if err := fs.Parse([]string{"-w", "-dedupe", "jekyll", "-no-cache"}); err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{"docs/a.md", "docs/b.md", "docs/c.md"}
+	opts, err := o.embedOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts, err = o.includeOptions(paths, opts...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := embed(paths, true, false, opts...); err != nil {
+		t.Fatal(err)
+	}
+
+	shared, err := filepath.Glob("_includes/embedmd/*.md")
+	if err != nil || len(shared) != 1 {
+		t.Fatalf("expected a single shared file; got %v, %v", shared, err)
+	}
+	b, err := os.ReadFile(shared[0])
+	if err != nil || string(b) != "```go\nvar shared = 1\n```\n" {
+		t.Errorf("unexpected shared file %q, %v", b, err)
+	}
+	include := "<!-- embedmd block start -->\n{% include embedmd/" + filepath.Base(shared[0]) + " %}\n<!-- embedmd block end -->\n"
+	want := map[string]string{
+		"docs/a.md": "[embedmd]:# (../shared.go)\n" + include + "\n[embedmd]:# (../unique.go)\n```go\nvar unique = 2\n```\n",
+		"docs/b.md": "[embedmd]:# (../shared.go)\n" + include,
+	}
+	for path, content := range want {
+		b, err := os.ReadFile(path)
+		if err != nil || string(b) != content {
+			t.Errorf("expected %s to be\n%q\ngot\n%q, %v", path, content, b, err)
+		}
+	}
+}
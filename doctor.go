@@ -0,0 +1,368 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// doctorTimeout is how long a token is given to be accepted by its forge.
+const doctorTimeout = 10 * time.Second
+
+// tokenCheckURLs are the URLs of the API endpoints returning the user
+// authenticated by a token, by host. Forges configured with -forge are added
+// by kind, with the templates of forgeTokenChecks.
+var tokenCheckURLs = map[string]string{
+	"github.com":                "https://api.github.com/user",
+	"api.github.com":            "https://api.github.com/user",
+	"raw.githubusercontent.com": "https://api.github.com/user",
+	"gitlab.com":                "https://gitlab.com/api/v4/user",
+	"codeberg.org":              "https://codeberg.org/api/v1/user",
+	"gitea.com":                 "https://gitea.com/api/v1/user",
+}
+
+var forgeTokenChecks = map[string]string{
+	"github": "https://{host}/api/v3/user",
+	"gitlab": "https://{host}/api/v4/user",
+	"gitea":  "https://{host}/api/v1/user",
+}
+
+// A diagnosis is the outcome of one check of the doctor command.
+type diagnosis struct {
+	level, name, msg string
+	// fix tells how to solve a warning or an error.
+	fix string
+}
+
+func doctorUsage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd doctor [flags]\n")
+		fs.PrintDefaults()
+	}
+}
+
+// runDoctor implements the doctor command, checking that the programs,
+// network, tokens, caches, and config file used with the given flags work,
+// and telling how to fix them when they don't.
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("embedmd doctor", flag.ContinueOnError)
+	fs.Usage = doctorUsage(fs)
+	o := newCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var ds []diagnosis
+	ds = append(ds, checkConfig(fs))
+	ds = append(ds, checkGit()...)
+	ds = append(ds, checkExec(o)...)
+	ds = append(ds, checkNetwork(o)...)
+	ds = append(ds, checkTokens(o)...)
+	ds = append(ds, checkCaches(o)...)
+
+	code := 0
+	for _, d := range ds {
+		fmt.Fprintf(stdout, "%-8s %s: %s\n", d.level, d.name, d.msg)
+		if d.fix != "" {
+			fmt.Fprintf(stdout, "%-8s fix: %s\n", "", d.fix)
+		}
+		if d.level == "error" {
+			code = 1
+		}
+	}
+	return code
+}
+
+// checkConfig checks the config file, applying it and the environment to
+// the flags checked next.
+func checkConfig(fs *flag.FlagSet) diagnosis {
+	d := diagnosis{level: "ok", name: "config"}
+	c, err := findConfig(fs, fs.Lookup("config").Value.String())
+	if err == nil {
+		_, err = resolveFlags(fs)
+	}
+	switch {
+	case err != nil:
+		d.level, d.msg = "error", strings.TrimPrefix(err.Error(), "error: ")
+		d.fix = "correct the config file, whose fields are described by 'embedmd config schema'"
+	case c == nil:
+		d.msg = fmt.Sprintf("no %s found, using the defaults", configFile)
+	default:
+		d.msg = c.path + " is valid"
+	}
+	return d
+}
+
+// checkGit checks that git, needed by git: and repo sources, and Git LFS,
+// needed by the files stored in LFS, are installed.
+func checkGit() []diagnosis {
+	d := diagnosis{level: "ok", name: "git"}
+	out, err := exec.Command("git", "version").Output()
+	if err != nil {
+		d.level, d.msg = "warning", "git is not installed"
+		d.fix = "install git to embed git: and repo sources, and to use -blame, -clean, and -lock"
+		return []diagnosis{d}
+	}
+	d.msg = strings.TrimSpace(string(out))
+	lfs := diagnosis{level: "ok", name: "git lfs"}
+	if out, err := exec.Command("git", "lfs", "version").Output(); err != nil {
+		lfs.level, lfs.msg = "warning", "Git LFS is not installed"
+		lfs.fix = "install Git LFS from https://git-lfs.com to embed files stored in LFS"
+	} else {
+		lfs.msg = strings.TrimSpace(string(out))
+	}
+	return []diagnosis{d, lfs}
+}
+
+// checkExec checks that the programs allowed with -allow-exec are found.
+func checkExec(o *options) []diagnosis {
+	var ds []diagnosis
+	for _, a := range o.allowExec {
+		args := strings.Fields(a)
+		if len(args) == 0 {
+			continue
+		}
+		d := diagnosis{level: "ok", name: "exec " + args[0]}
+		if path, err := exec.LookPath(args[0]); err != nil {
+			d.level, d.msg = "error", fmt.Sprintf("%s is not found", args[0])
+			d.fix = fmt.Sprintf("install %s, or remove %q from -allow-exec", args[0], a)
+		} else {
+			d.msg = path
+		}
+		ds = append(ds, d)
+	}
+	return ds
+}
+
+// checkNetwork checks the connection settings and reports which URLs can be
+// fetched.
+func checkNetwork(o *options) []diagnosis {
+	d := diagnosis{level: "ok", name: "network"}
+	var settings []string
+	switch {
+	case o.ipv4 && o.ipv6:
+		d.level, d.msg = "error", "both -ipv4 and -ipv6 are set"
+		d.fix = "keep only one of -ipv4 and -ipv6"
+		return []diagnosis{d}
+	case o.ipv4:
+		settings = append(settings, "IPv4 only")
+	case o.ipv6:
+		settings = append(settings, "IPv6 only")
+	}
+	if o.unixSocket != "" {
+		settings = append(settings, "unix socket "+o.unixSocket)
+	}
+	if o.proxy != "" && o.socks5 != "" {
+		d.level, d.msg = "error", "both -proxy and -socks5 are set"
+		d.fix = "keep only one of -proxy and -socks5"
+		return []diagnosis{d}
+	}
+	if o.socks5 != "" {
+		settings = append(settings, "SOCKS5 proxy "+o.socks5)
+	} else if o.proxy != "" {
+		settings = append(settings, "proxy "+o.proxy)
+	} else if o.unixSocket == "" {
+		for _, env := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+			if v := os.Getenv(env); v != "" {
+				settings = append(settings, fmt.Sprintf("proxy %s from %s", v, env))
+				break
+			}
+		}
+	}
+	if o.caFile != "" {
+		settings = append(settings, "CA bundle "+o.caFile)
+	}
+	if o.clientCert != "" {
+		settings = append(settings, "client certificate "+o.clientCert)
+	}
+	if o.insecureSkipVerify {
+		settings = append(settings, "server certificates not verified")
+	}
+	if o.httpsOnly {
+		settings = append(settings, "HTTPS only")
+	}
+	if o.publicOnly {
+		settings = append(settings, "public addresses only")
+	}
+	if _, err := o.network().Client(); err != nil {
+		d.level, d.msg = "error", err.Error()
+		d.fix = "check -ipv4, -ipv6, -unix-socket, -socks5, -proxy, -public-only, and the certificate flags"
+		return []diagnosis{d}
+	}
+	if len(settings) == 0 {
+		settings = append(settings, "direct connections")
+	}
+	d.msg = strings.Join(settings, ", ")
+
+	policy := diagnosis{level: "ok", name: "allowed URLs", msg: "any"}
+	if len(o.allowURLs) > 0 {
+		policy.msg = strings.Join(o.allowURLs, ", ")
+	}
+	return []diagnosis{d, policy}
+}
+
+// checkTokens checks that the environment variables of the tokens set with
+// -token are, and that the forges they're sent to accept them, without
+// printing them.
+func checkTokens(o *options) []diagnosis {
+	checks := map[string]string{}
+	for host, u := range tokenCheckURLs {
+		checks[host] = u
+	}
+	forges, err := o.forgeList()
+	if err != nil {
+		return []diagnosis{{level: "error", name: "forges", msg: strings.TrimPrefix(err.Error(), "error: "), fix: "correct -forge"}}
+	}
+	for _, f := range forges {
+		if t, ok := forgeTokenChecks[f.Kind]; ok {
+			checks[f.Host] = strings.ReplaceAll(t, "{host}", f.Host)
+		}
+	}
+	client, err := o.network().Client()
+	if err != nil {
+		return nil // reported by checkNetwork.
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var ds []diagnosis
+	for _, t := range o.tokens {
+		pattern, env, _ := strings.Cut(t, "=")
+		pattern, env = strings.TrimSpace(pattern), strings.TrimSpace(env)
+		d := diagnosis{level: "ok", name: "token " + env}
+		token := os.Getenv(env)
+		host, _, _ := strings.Cut(pattern, "/")
+		switch u, known := checks[host]; {
+		case token == "":
+			d.level, d.msg = "error", fmt.Sprintf("%s, sent to %s, is not set", env, pattern)
+			d.fix = fmt.Sprintf("export %s with a token of %s, or remove -token %s", env, host, t)
+		case !known:
+			d.msg = fmt.Sprintf("set (%d characters), not checked as %s is not a known forge", len(token), host)
+		default:
+			d.msg, d.fix = checkToken(client, u, token)
+			if d.fix != "" {
+				d.level = "error"
+				d.fix = fmt.Sprintf("%s %s", d.fix, env)
+			}
+		}
+		ds = append(ds, d)
+	}
+	return ds
+}
+
+// checkToken asks the API endpoint at u which user token authenticates,
+// returning the outcome and, if the token was rejected, the beginning of the
+// fix.
+func checkToken(client *http.Client, u, token string) (msg, fix string) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err.Error(), "check -forge and"
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	c := *client
+	c.Timeout = doctorTimeout
+	res, err := c.Do(req)
+	if err != nil {
+		return fmt.Sprintf("could not be checked: %v", err), "check the network, then the token in"
+	}
+	res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		return fmt.Sprintf("rejected by %s (%s)", req.URL.Host, res.Status), "renew the token in"
+	case res.StatusCode >= 400:
+		return fmt.Sprintf("could not be checked, %s replied %s", req.URL.Host, res.Status), "check the token in"
+	}
+	return fmt.Sprintf("accepted by %s", req.URL.Host), ""
+}
+
+// checkCaches checks that the HTTP cache and the store are writable, and
+// reports their size.
+func checkCaches(o *options) []diagnosis {
+	var ds []diagnosis
+	if !o.noCache {
+		dir := o.cacheDir
+		if dir == "" {
+			dir, _ = embedmd.DefaultHTTPCacheDir()
+		}
+		if dir == "" {
+			ds = append(ds, diagnosis{level: "warning", name: "HTTP cache", msg: "there is no user cache directory, content is fetched uncached",
+				fix: "set -cache-dir"})
+		} else {
+			ds = append(ds, checkCacheDir("HTTP cache", dir, "-cache-dir"))
+		}
+	}
+	if o.store {
+		dir, err := o.storeDirectory()
+		if err != nil {
+			ds = append(ds, diagnosis{level: "error", name: "store", msg: err.Error(), fix: "set -store-dir"})
+		} else {
+			ds = append(ds, checkCacheDir("store", dir, "-store-dir"))
+		}
+	}
+	return ds
+}
+
+// checkCacheDir checks that the cache directory dir, set by flag, can be
+// written.
+func checkCacheDir(name, dir, flag string) diagnosis {
+	d := diagnosis{level: "ok", name: name}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		d.level, d.msg = "error", err.Error()
+		d.fix = fmt.Sprintf("create %s, or set %s to a writable directory", dir, flag)
+		return d
+	}
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		d.level, d.msg = "error", fmt.Sprintf("%s is not writable", dir)
+		d.fix = fmt.Sprintf("make %s writable, or set %s to a writable directory", dir, flag)
+		return d
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	var files, size int64
+	err = filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if info, err := e.Info(); err == nil && info.Mode().IsRegular() {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		d.level, d.msg = "warning", fmt.Sprintf("%s could not be read: %v", dir, err)
+		d.fix = fmt.Sprintf("remove %s, it is filled again as content is fetched", dir)
+		return d
+	}
+	d.msg = fmt.Sprintf("%s, %d files, %d bytes", dir, files, size)
+	return d
+}
@@ -0,0 +1,144 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunDoctor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer good" {
+			http.Error(w, "bad credentials", http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+	tokenCheckURLs["forge.test"] = server.URL + "/user"
+	defer delete(tokenCheckURLs, "forge.test")
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, configFile)
+	if err := os.WriteFile(config, []byte("version: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCTOR_GOOD", "good")
+	t.Setenv("DOCTOR_BAD", "bad")
+	t.Setenv("DOCTOR_UNSET", "")
+	for _, env := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		t.Setenv(env, "")
+	}
+
+	tc := []struct {
+		name string
+		args []string
+		code int
+		want []string
+		not  []string
+	}{
+		{name: "healthy",
+			args: []string{"-token", "forge.test/**=DOCTOR_GOOD", "-token", "other.test/**=DOCTOR_GOOD"},
+			want: []string{
+				"ok       config: " + config + " is valid\n",
+				"ok       network: direct connections\n",
+				"ok       allowed URLs: any\n",
+				"ok       token DOCTOR_GOOD: accepted by 127.0.0.1",
+				"ok       token DOCTOR_GOOD: set (4 characters), not checked as other.test is not a known forge\n",
+				"ok       HTTP cache: " + filepath.Join(dir, "cache") + ", 0 files, 0 bytes\n",
+			},
+			not: []string{"good"}},
+		{name: "rejected token", code: 1,
+			args: []string{"-token", "forge.test/**=DOCTOR_BAD"},
+			want: []string{
+				"error    token DOCTOR_BAD: rejected by 127.0.0.1",
+				"         fix: renew the token in DOCTOR_BAD\n",
+			}},
+		{name: "unset token", code: 1,
+			args: []string{"-token", "forge.test/**=DOCTOR_UNSET"},
+			want: []string{
+				"error    token DOCTOR_UNSET: DOCTOR_UNSET, sent to forge.test/**, is not set\n",
+				"         fix: export DOCTOR_UNSET with a token of forge.test, or remove -token forge.test/**=DOCTOR_UNSET\n",
+			}},
+		{name: "missing program", code: 1,
+			args: []string{"-allow-exec", "embedmd-no-such-program -help"},
+			want: []string{
+				"error    exec embedmd-no-such-program: embedmd-no-such-program is not found\n",
+				`         fix: install embedmd-no-such-program, or remove "embedmd-no-such-program -help" from -allow-exec` + "\n",
+			}},
+		{name: "network", code: 1,
+			args: []string{"-ipv4", "-ipv6"},
+			want: []string{"error    network: both -ipv4 and -ipv6 are set\n"}},
+		{name: "allowed URLs",
+			args: []string{"-allow-url", "example.com/**", "-socks5", "socks5://localhost:1080"},
+			want: []string{
+				"ok       network: SOCKS5 proxy socks5://localhost:1080\n",
+				"ok       allowed URLs: example.com/**\n",
+			}},
+		{name: "proxy and certificates",
+			args: []string{"-proxy", "http://proxy.corp:3128", "-insecure-skip-verify"},
+			want: []string{"ok       network: proxy http://proxy.corp:3128, server certificates not verified\n"}},
+		{name: "bad client certificate", code: 1,
+			args: []string{"-client-key", "key.pem"},
+			want: []string{"error    network: a client certificate needs both a certificate and a key file\n"}},
+		{name: "unwritable cache", code: 1,
+			args: []string{"-store", "-store-dir", filepath.Join(config, "store")},
+			want: []string{"error    store: "}},
+	}
+
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	for _, tt := range tc {
+		var out bytes.Buffer
+		stdout, stderr = &out, &out
+		args := append([]string{"-config", config, "-cache-dir", filepath.Join(dir, "cache")}, tt.args...)
+		if code := runDoctor(args); code != tt.code {
+			t.Errorf("case [%s]: exit code %d, want %d\n%s", tt.name, code, tt.code, out.String())
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(out.String(), w) {
+				t.Errorf("case [%s]: output doesn't contain %q:\n%s", tt.name, w, out.String())
+			}
+		}
+		for _, n := range tt.not {
+			if strings.Contains(out.String(), n) {
+				t.Errorf("case [%s]: output contains %q:\n%s", tt.name, n, out.String())
+			}
+		}
+	}
+}
+
+func TestRunDoctorBadConfig(t *testing.T) {
+	config := filepath.Join(t.TempDir(), configFile)
+	if err := os.WriteFile(config, []byte("version: 1\nno-such-flag: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	var out bytes.Buffer
+	stdout, stderr = &out, &out
+	if code := runDoctor([]string{"-config", config, "-no-cache"}); code != 1 {
+		t.Errorf("exit code %d, want 1\n%s", code, out.String())
+	}
+	if want := "error    config: "; !strings.Contains(out.String(), want) {
+		t.Errorf("output doesn't contain %q:\n%s", want, out.String())
+	}
+	if strings.Contains(out.String(), "HTTP cache") {
+		t.Errorf("-no-cache still checks the cache:\n%s", out.String())
+	}
+}
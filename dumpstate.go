@@ -0,0 +1,221 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// redacted replaces the values left out of the state dumped.
+const redacted = "<redacted>"
+
+// A stateDump is the state of a run written by -dump-state to be attached
+// to bug reports: the flags set and the commands of the documents, with
+// the paths, URLs, and free-form values anonymized.
+type stateDump struct {
+	Version   string       `json:"version"`
+	Go        string       `json:"go"`
+	Platform  string       `json:"platform"`
+	Flags     []dumpedFlag `json:"flags"`
+	Documents []dumpedDoc  `json:"documents"`
+}
+
+type dumpedFlag struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+type dumpedDoc struct {
+	Name     string          `json:"name"`
+	Commands []dumpedCommand `json:"commands"`
+	Error    string          `json:"error,omitempty"`
+}
+
+type dumpedCommand struct {
+	Line int    `json:"line"`
+	Args string `json:"args"`
+}
+
+// dumpState writes the state of the run on the files in paths to out, or
+// to stdout if out is -.
+func dumpState(out string, fs *flag.FlagSet, source map[string]string, paths []string, opts ...embedmd.Option) error {
+	d := stateDump{
+		Version:   version,
+		Go:        runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Flags:     []dumpedFlag{},
+		Documents: []dumpedDoc{},
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if from := source[f.Name]; from != "" {
+			d.Flags = append(d.Flags, dumpedFlag{f.Name, dumpedValue(f), from})
+		}
+	})
+	a := newAnonymizer()
+	for _, p := range paths {
+		doc := dumpedDoc{Name: a.name("doc", p, path.Ext(p)), Commands: []dumpedCommand{}}
+		b, err := readFile(p)
+		if err != nil {
+			return err
+		}
+		sources, err := embedmd.Sources(bytes.NewReader(b), append([]embedmd.Option{embedmd.WithFormat(embedmd.FormatOf(p))}, opts...)...)
+		if err != nil {
+			doc.Error = err.Error()
+		}
+		for _, s := range sources {
+			doc.Commands = append(doc.Commands, dumpedCommand{s.Line, a.args(s.Args)})
+		}
+		d.Documents = append(d.Documents, doc)
+	}
+
+	// Regular expressions are easier to read without escaped HTML.
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(d); err != nil {
+		return err
+	}
+	if out == "-" {
+		_, err := stdout.Write(b.Bytes())
+		return err
+	}
+	return os.WriteFile(out, b.Bytes(), 0666)
+}
+
+// dumpedValue returns the value of f, unless it is free-form and could hold
+// a path, a URL, or a secret.
+func dumpedValue(f *flag.Flag) string {
+	switch flagKind(f) {
+	case "boolean", "integer":
+		return f.Value.String()
+	}
+	if g, ok := f.Value.(flag.Getter); ok {
+		switch g.Get().(type) {
+		case time.Duration, float64:
+			return f.Value.String()
+		}
+	}
+	for _, v := range flagEnums[f.Name] {
+		if f.Value.String() == v {
+			return v
+		}
+	}
+	return redacted
+}
+
+// An anonymizer replaces names with placeholders, the same ones for the
+// same names.
+type anonymizer struct {
+	names map[string]string
+	count map[string]int
+}
+
+func newAnonymizer() *anonymizer {
+	return &anonymizer{names: map[string]string{}, count: map[string]int{}}
+}
+
+// name returns the placeholder of the name of the given kind, numbered by
+// order of appearance and ending with ext.
+func (a *anonymizer) name(kind, name, ext string) string {
+	key := kind + "\x00" + name
+	if n, ok := a.names[key]; ok {
+		return n
+	}
+	a.count[kind]++
+	n := fmt.Sprintf("%s%d%s", kind, a.count[kind], ext)
+	a.names[key] = n
+	return n
+}
+
+// source returns the placeholder of the path or URL of a source, keeping
+// its extension, its URL scheme, and its line range.
+func (a *anonymizer) source(s string) string {
+	p, lines, hasLines := strings.Cut(s, "#")
+	if u, err := url.Parse(p); err == nil && u.Scheme != "" && u.Host != "" {
+		p = u.Scheme + "://" + a.name("host", u.Host, "") + "/" + a.name("source", u.Path, path.Ext(u.Path))
+	} else {
+		p = a.name("source", p, path.Ext(p))
+	}
+	if hasLines {
+		p += "#" + lines
+	}
+	return p
+}
+
+// args returns the argument list of a command, in parentheses, with the
+// paths of its sources anonymized. The regular expressions and attributes
+// are kept, as most issues can't be reproduced without them.
+func (a *anonymizer) args(s string) string {
+	args := commandFields(strings.TrimSuffix(strings.TrimPrefix(s, "("), ")"))
+	for i := 0; i < len(args); i++ {
+		switch {
+		case i == 0 && args[i] == "basedir" && len(args) > 1:
+			i++
+			args[i] = a.name("dir", args[i], "")
+		case i == 0 && args[i] == "include" && len(args) > 1:
+			i++
+			args[i] = a.source(args[i])
+		case i == 0 || args[i-1] == "+":
+			if !strings.HasPrefix(args[i], "/") {
+				args[i] = a.source(args[i])
+			}
+		}
+	}
+	return "(" + strings.Join(args, " ") + ")"
+}
+
+// commandFields splits the arguments of a command at blanks, except in
+// regular expressions, delimited by slashes, and double quoted strings.
+func commandFields(s string) []string {
+	var args []string
+	for rest := strings.TrimSpace(s); rest != ""; rest = strings.TrimSpace(rest) {
+		n := len(rest)
+		quoted, escaped := false, false
+		for i := 0; i < len(rest); i++ {
+			c := rest[i]
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case i > 0 && rest[0] == '/':
+				if c == '/' {
+					n = i + 1
+				}
+			case c == '"':
+				quoted = !quoted
+			case !quoted && (c == ' ' || c == '\t'):
+				n = i
+			}
+			if n <= i+1 {
+				break
+			}
+		}
+		args, rest = append(args, rest[:n]), rest[n:]
+	}
+	return args
+}
@@ -0,0 +1,121 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestAnonymizeArgs(t *testing.T) {
+	a := newAnonymizer()
+	tc := []struct {
+		args, want string
+	}{
+		{"(code.go /func main/ $)", "(source1.go /func main/ $)"},
+		{"(../internal/code.go /a b/ /c\\/d e/ caption=\"Some text\")", "(source2.go /a b/ /c\\/d e/ caption=\"Some text\")"},
+		{"(code.go#L1-L3)", "(source1.go#L1-L3)"},
+		{"(https://example.com/org/repo/main.go go)", "(https://host1/source3.go go)"},
+		{"(https://example.com/other.py + code.go /x + y/)", "(https://host1/source4.py + source1.go /x + y/)"},
+		{"(include ../shared/header.md)", "(include source5.md)"},
+		{"(basedir ../../private)", "(basedir dir1)"},
+	}
+	for _, tt := range tc {
+		if got := a.args(tt.args); got != tt.want {
+			t.Errorf("args(%q) = %q; want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestDumpState(t *testing.T) {
+	defer func(o io.Writer) { stdout = o }(stdout)
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "secret-plans.md")
+	if err := os.WriteFile(doc, []byte("# Plans\n\n[embedmd]:# (plans/code.go /func main/ $)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad.md")
+	if err := os.WriteFile(bad, []byte("[embedmd]:# (code.go\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("embedmd", flag.ContinueOnError)
+	newFlags(fs)
+	if err := fs.Parse([]string{"-d", "-retries=3", "-timeout=5s", "-color=never", "-base-dir", "/home/someone"}); err != nil {
+		t.Fatal(err)
+	}
+	source := map[string]string{"d": "flag", "retries": "flag", "timeout": "flag", "color": "flag", "base-dir": "EMBEDMD_BASE_DIR"}
+	var out bytes.Buffer
+	stdout = &out
+	if err := dumpState("-", fs, source, []string{doc, bad}); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "version": "` + version + `",
+  "go": "` + runtime.Version() + `",
+  "platform": "` + runtime.GOOS + "/" + runtime.GOARCH + `",
+  "flags": [
+    {
+      "name": "base-dir",
+      "value": "<redacted>",
+      "source": "EMBEDMD_BASE_DIR"
+    },
+    {
+      "name": "color",
+      "value": "never",
+      "source": "flag"
+    },
+    {
+      "name": "d",
+      "value": "true",
+      "source": "flag"
+    },
+    {
+      "name": "retries",
+      "value": "3",
+      "source": "flag"
+    },
+    {
+      "name": "timeout",
+      "value": "5s",
+      "source": "flag"
+    }
+  ],
+  "documents": [
+    {
+      "name": "doc1.md",
+      "commands": [
+        {
+          "line": 3,
+          "args": "(source1.go /func main/ $)"
+        }
+      ]
+    },
+    {
+      "name": "doc2.md",
+      "commands": [],
+      "error": "1: argument list should be in parenthesis"
+    }
+  ]
+}
+`
+	if got := out.String(); got != want {
+		t.Errorf("expected state\n%s\ngot\n%s", want, got)
+	}
+}
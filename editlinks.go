@@ -0,0 +1,109 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// editLinkConfig returns the edit links set by -edit-links, to the
+// repository of the current directory on the forge of its origin remote, at
+// the branch checked out unless -edit-ref is set.
+func (o *options) editLinkConfig() (embedmd.EditLinks, error) {
+	dir, repo, err := originRepo()
+	if err != nil {
+		return embedmd.EditLinks{}, fmt.Errorf("error: -edit-links: %v", err)
+	}
+	ref := o.editRef
+	if ref == "" {
+		// Without a branch, as in CI checkouts, the commit is linked.
+		out, err := runGit("symbolic-ref", "-q", "--short", "HEAD")
+		if err != nil {
+			out, err = runGit("rev-parse", "HEAD")
+		}
+		if err != nil {
+			return embedmd.EditLinks{}, fmt.Errorf("error: -edit-links: %v", err)
+		}
+		ref = strings.TrimSpace(string(out))
+	}
+	return embedmd.EditLinks{Repo: repo, Dir: dir, Ref: ref}, nil
+}
+
+// attributionConfig returns the attributions set by -attribution, linking
+// to the commit checked out in the repository of the current directory, on
+// the forge of its origin remote.
+func attributionConfig() (embedmd.Attribution, error) {
+	dir, repo, err := originRepo()
+	if err != nil {
+		return embedmd.Attribution{}, fmt.Errorf("error: -attribution: %v", err)
+	}
+	rev, err := runGit("rev-parse", "HEAD")
+	if err != nil {
+		return embedmd.Attribution{}, fmt.Errorf("error: -attribution: %v", err)
+	}
+	return embedmd.Attribution{Repo: repo, Dir: dir, Rev: strings.TrimSpace(string(rev))}, nil
+}
+
+// originRepo returns the top directory of the repository of the current
+// directory, and its origin remote as host/owner/repo.
+func originRepo() (dir, repo string, err error) {
+	top, err := runGit("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", "", err
+	}
+	remote, err := runGit("remote", "get-url", "origin")
+	if err != nil {
+		return "", "", err
+	}
+	repo, ok := remoteRepo(strings.TrimSpace(string(remote)))
+	if !ok {
+		return "", "", fmt.Errorf("can't tell the repository of the remote %s", strings.TrimSpace(string(remote)))
+	}
+	return strings.TrimSpace(string(top)), repo, nil
+}
+
+// remoteRepo returns the repository of a git remote as host/owner/repo,
+// from URLs such as https://github.com/org/repo.git or scp-like addresses
+// such as git@github.com:org/repo.git.
+func remoteRepo(remote string) (string, bool) {
+	var host, path string
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return "", false
+		}
+		host, path = u.Hostname(), u.Path
+	} else {
+		addr, p, ok := strings.Cut(remote, ":")
+		if !ok {
+			return "", false
+		}
+		_, host, _ = strings.Cut(addr, "@")
+		if host == "" {
+			host = addr
+		}
+		path = p
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	// Bitbucket Server serves repositories over HTTPS under /scm.
+	path = strings.TrimPrefix(path, "scm/")
+	if host == "" || !strings.Contains(path, "/") {
+		return "", false
+	}
+	return host + "/" + path, true
+}
@@ -0,0 +1,167 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemoteRepo(t *testing.T) {
+	tc := []struct {
+		remote, repo string
+	}{
+		{"https://github.com/org/repo.git", "github.com/org/repo"},
+		{"https://user@gitlab.com/group/sub/repo", "gitlab.com/group/sub/repo"},
+		{"git@github.com:org/repo.git", "github.com/org/repo"},
+		{"ssh://git@git.example.com:7999/key/repo.git", "git.example.com/key/repo"},
+		{"https://git.example.com/scm/key/repo.git", "git.example.com/key/repo"},
+		{"/srv/git/repo.git", ""},
+		{"https://github.com/repo", ""},
+	}
+	for _, tt := range tc {
+		if repo, _ := remoteRepo(tt.remote); repo != tt.repo {
+			t.Errorf("remote %s: expected repository %q; got %q", tt.remote, tt.repo, repo)
+		}
+	}
+}
+
+func TestEditLinksFlag(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "docs")
+	git("remote", "add", "origin", "git@github.com:org/repo.git")
+	files := map[string]string{
+		"code.go":   "package main\n\nfunc main() {}\n",
+		"docs/a.md": "[embedmd]:# (../code.go /func main/ $)\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(filepath.Join(dir, "docs")); err != nil {
+		t.Fatal(err)
+	}
+	defer func(o io.Writer) { stdout = o }(stdout)
+	out := new(bytes.Buffer)
+	stdout = out
+
+	fs := flag.NewFlagSet("embedmd", flag.ContinueOnError)
+	o := newFlags(fs)
+	if err := fs.Parse([]string{"-edit-links", "-no-cache"}); err != nil {
+		t.Fatal(err)
+	}
+	opts, err := o.embedOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := embed([]string{"a.md"}, false, false, opts...); err != nil {
+		t.Fatal(err)
+	}
+	want := "[embedmd]:# (../code.go /func main/ $)\n<!-- embedmd block start -->\n```go\nfunc main() {}\n```\n\n" +
+		"[Edit this example](https://github.com/org/repo/edit/docs/code.go#L3)\n<!-- embedmd block end -->\n"
+	if got := out.String(); got != want {
+		t.Errorf("expected output\n%q\ngot\n%q", want, got)
+	}
+}
+
+func TestAttributionFlag(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q")
+	git("remote", "add", "origin", "https://gitlab.com/group/repo.git")
+	files := map[string]string{
+		"code.go":   "package main\n\nfunc main() {}\n",
+		"docs/a.md": "[embedmd]:# (../code.go /func main/ $ attribution=link)\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("add", ".")
+	git("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-qm", "docs")
+	rev := git("rev-parse", "HEAD")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(filepath.Join(dir, "docs")); err != nil {
+		t.Fatal(err)
+	}
+	defer func(o io.Writer) { stdout = o }(stdout)
+	out := new(bytes.Buffer)
+	stdout = out
+
+	fs := flag.NewFlagSet("embedmd", flag.ContinueOnError)
+	o := newFlags(fs)
+	if err := fs.Parse([]string{"-attribution", "-no-cache"}); err != nil {
+		t.Fatal(err)
+	}
+	opts, err := o.embedOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := embed([]string{"a.md"}, false, false, opts...); err != nil {
+		t.Fatal(err)
+	}
+	want := "[embedmd]:# (../code.go /func main/ $ attribution=link)\n<!-- embedmd block start -->\n```go\nfunc main() {}\n```\n\n" +
+		"_Source: [code.go#L3](https://gitlab.com/group/repo/-/blob/" + rev + "/code.go#L3)_\n<!-- embedmd block end -->\n"
+	if got := out.String(); got != want {
+		t.Errorf("expected output\n%q\ngot\n%q", want, got)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"html"
+	"io"
+)
+
+// WithAriaLabels wraps every embedded code block in an HTML region labeled
+// with its caption, or its source when there's no caption, so screen readers
+// can announce the code blocks. Only use it with renderers that accept raw
+// HTML in markdown.
+func WithAriaLabels() Option {
+	return Option{func(e *embedder) { e.ariaLabels = true }}
+}
+
+// WithA11yLint reports a warning for each command without a caption, since
+// those blocks can only be described by their source path.
+func WithA11yLint() Option {
+	return Option{func(e *embedder) { e.a11yLint = true }}
+}
+
+func ariaLabel(cmd *command) string {
+	if cmd.caption != "" {
+		return cmd.caption
+	}
+	return "Code example from " + cmd.path
+}
+
+// writeAriaStart opens the HTML region describing the code block. The blank
+// line lets markdown renderers parse the code fence inside of the region.
+func writeAriaStart(w io.Writer, cmd *command) {
+	fmt.Fprintf(w, "<div role=\"region\" aria-label=\"%s\">\n\n", html.EscapeString(ariaLabel(cmd)))
+}
+
+func writeAriaEnd(w io.Writer) {
+	fmt.Fprint(w, "\n</div>\n")
+}
@@ -0,0 +1,60 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestAccessibility(t *testing.T) {
+	files := map[string][]byte{"code.go": []byte("package main\n")}
+	in := "[embedmd]:# (code.go)\n\n[embedmd]:# (code.go caption=\"A <main> package\")\n"
+
+	var warns []string
+	var out bytes.Buffer
+	err := Process(&out, strings.NewReader(in),
+		WithFetcher(mixedContentProvider{files: files}),
+		WithAriaLabels(),
+		WithA11yLint(),
+		WithWarnings(func(line int, msg string) { warns = append(warns, fmt.Sprintf("%d: %s", line, msg)) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "[embedmd]:# (code.go)\n" +
+		"<!-- embedmd block start -->\n" +
+		"<div role=\"region\" aria-label=\"Code example from code.go\">\n\n" +
+		"```go\npackage main\n```\n" +
+		"\n</div>\n" +
+		"<!-- embedmd block end -->\n" +
+		"\n" +
+		"[embedmd]:# (code.go caption=\"A <main> package\")\n" +
+		"<!-- embedmd block start -->\n" +
+		"<div role=\"region\" aria-label=\"A &lt;main&gt; package\">\n\n" +
+		"*A <main> package*\n\n" +
+		"```go\npackage main\n```\n" +
+		"\n</div>\n" +
+		"<!-- embedmd block end -->\n"
+	if out.String() != want {
+		t.Errorf("expected output\n%s\ngot\n%s", want, out.String())
+	}
+
+	if len(warns) != 1 || warns[0] != "1: embedded code.go has no caption to describe it" {
+		t.Errorf("unexpected warnings %q", warns)
+	}
+}
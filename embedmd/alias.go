@@ -0,0 +1,66 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WithAlias defines an alias for a path or URL prefix. Aliases start with @
+// and are expanded at the beginning of the paths in commands, so with the
+// alias @examples for ../../examples/go the command
+//
+//	[embedmd]:# (@examples/server/main.go)
+//
+// embeds ../../examples/go/server/main.go. The commands are kept as written.
+func WithAlias(name, target string) Option {
+	return Option{func(e *embedder) {
+		if e.aliases == nil {
+			e.aliases = map[string]string{}
+		}
+		e.aliases[name] = target
+	}}
+}
+
+func (e *embedder) validateAliases() error {
+	names := make([]string, 0, len(e.aliases))
+	for name := range e.aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if len(name) < 2 || name[0] != '@' || strings.Contains(name, "/") {
+			return fmt.Errorf("bad alias %q: aliases start with @ and contain no slashes", name)
+		}
+	}
+	return nil
+}
+
+// expandAlias replaces the alias at the beginning of the path, if any.
+func (e *embedder) expandAlias(path string) (string, error) {
+	if !strings.HasPrefix(path, "@") {
+		return path, nil
+	}
+	name, rest, _ := strings.Cut(path, "/")
+	target, ok := e.aliases[name]
+	if !ok {
+		return "", fmt.Errorf("unknown alias %s", name)
+	}
+	if rest == "" {
+		return target, nil
+	}
+	return strings.TrimSuffix(target, "/") + "/" + rest, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAliases(t *testing.T) {
+	files := map[string][]byte{"examples/go/main.go": []byte("package main\n")}
+	tc := []struct {
+		name    string
+		in      string
+		aliases map[string]string
+		out     string
+		err     string
+	}{
+		{name: "expanded alias",
+			in:      "[embedmd]:# (@ex/main.go)\n",
+			aliases: map[string]string{"@ex": "examples/go"},
+			out:     "[embedmd]:# (@ex/main.go)\n```go\npackage main\n```\n"},
+		{name: "alias with trailing slash",
+			in:      "[embedmd]:# (@ex/go/main.go)\n",
+			aliases: map[string]string{"@ex": "examples/"},
+			out:     "[embedmd]:# (@ex/go/main.go)\n```go\npackage main\n```\n"},
+		{name: "alias to a file",
+			in:      "[embedmd]:# (@main go)\n",
+			aliases: map[string]string{"@main": "examples/go/main.go"},
+			out:     "[embedmd]:# (@main go)\n```go\npackage main\n```\n"},
+		{name: "unknown alias",
+			in:  "[embedmd]:# (@ex/main.go)\n",
+			err: "1: unknown alias @ex"},
+		{name: "bad alias",
+			in:      "[embedmd]:# (main.go)\n",
+			aliases: map[string]string{"ex": "examples"},
+			err:     `bad alias "ex": aliases start with @ and contain no slashes`},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []Option{WithFetcher(mixedContentProvider{files: files})}
+			for name, target := range tt.aliases {
+				opts = append(opts, WithAlias(name, target))
+			}
+			var out bytes.Buffer
+			err := Process(&out, strings.NewReader(tt.in), opts...)
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if out.String() != tt.out {
+				t.Errorf("case [%s]: expected\n%q\ngot\n%q", tt.name, tt.out, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// SuggestAnchors returns the regular expressions a command can use to embed
+// the lines from to of src, numbered from 1, without the blank lines around
+// them, or nil if there are none.
+// Each matches the shortest start of its line, of at least two words, that
+// makes it select the right one, so the command keeps working while the rest
+// changes.
+func SuggestAnchors(src string, from, to int) ([]string, error) {
+	lines := strings.SplitAfter(src, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if to > len(lines) {
+		return nil, fmt.Errorf("line range %d-%d is out of range, the file has %d lines", from, to, len(lines))
+	}
+	for from <= to && strings.TrimSpace(lines[from-1]) == "" {
+		from++
+	}
+	for to >= from && strings.TrimSpace(lines[to-1]) == "" {
+		to--
+	}
+	if from > to {
+		return nil, fmt.Errorf("lines to embed are blank")
+	}
+	offset := func(line int) int { return len(strings.Join(lines[:line-1], "")) }
+
+	// A single line is matched whole, up to its line break.
+	if from == to {
+		start := anchor(src, 0, offset(from), lines[from-1], `.*\n`)
+		if start == "" {
+			return nil, nil
+		}
+		return []string{start}, nil
+	}
+	start := anchor(src, 0, offset(from), lines[from-1], "")
+	if start == "" {
+		return nil, nil
+	}
+	if to == len(lines) {
+		return []string{start, "$"}, nil
+	}
+	// The end is looked for from the start of the selection, up to the end
+	// of the line it matches.
+	end := anchor(src, offset(from), offset(to), lines[to-1], ".*")
+	if end == "" {
+		return nil, nil
+	}
+	return []string{start, end}, nil
+}
+
+// anchor returns the shortest regular expression matching the start of line,
+// followed by suffix, whose first match in src after from is at the offset
+// at, or an empty string if there's none.
+func anchor(src string, from, at int, line, suffix string) string {
+	text := strings.TrimSpace(line)
+	indent := ""
+	if strings.TrimLeft(line, " \t") != line {
+		// Indentation changes with formatting, so it isn't matched exactly.
+		indent = "[[:blank:]]*"
+	}
+	// Starts of lines shorter than two words, such as func, match too many
+	// lines to be robust.
+	words := 0
+	for end := 0; end < len(text); {
+		if i := strings.IndexAny(text[end+1:], " \t"); i >= 0 {
+			end += i + 1
+		} else {
+			end = len(text)
+		}
+		if words++; words < 2 && end < len(text) {
+			continue
+		}
+		expr := "^" + indent + regexp.QuoteMeta(text[:end]) + suffix
+		re, err := regexp.CompilePOSIX(expr)
+		if err != nil {
+			return ""
+		}
+		if loc := re.FindStringIndex(src[from:]); loc != nil && from+loc[0] == at {
+			return "/" + strings.ReplaceAll(expr, "/", `\/`) + "/"
+		}
+	}
+	return ""
+}
+
+// WithAnchorLint reports a warning for each command whose regular
+// expressions could select the wrong lines as its source changes: a start
+// matching several times, or expressions with too little text to tell lines
+// apart, such as /}/. The warning suggests stronger ones when it can.
+func WithAnchorLint() Option {
+	return Option{func(e *embedder) { e.anchorLint = true }}
+}
+
+// lintAnchors reports the weaknesses of the regular expressions of cmd,
+// embedding from src.
+func (e *embedder) lintAnchors(cmd *command, src []byte) {
+	if !e.anchorLint || cmd.start == nil || *cmd.start == "" {
+		return
+	}
+	start, err := compileSlashed(*cmd.start)
+	if err != nil {
+		// Reported when extracting the content.
+		return
+	}
+	var issues []string
+	if n := len(start.FindAllIndex(src, -1)); n > 1 && !cmd.matchAll {
+		issues = append(issues, fmt.Sprintf("%s matches %d times in %s, only the first one is used", *cmd.start, n, cmd.path))
+	}
+	if weakAnchor(*cmd.start, false) {
+		issues = append(issues, fmt.Sprintf("%s has too little text to match the right line", *cmd.start))
+	}
+	if cmd.end != nil && weakAnchor(*cmd.end, true) {
+		issues = append(issues, fmt.Sprintf("%s has too little text to match the right line", *cmd.end))
+	}
+	if len(issues) == 0 {
+		return
+	}
+	msg := strings.Join(issues, ", ")
+	if s := suggestFor(cmd, start, src); s != "" {
+		msg += ", consider " + s
+	}
+	e.warnf(cmd, "%s", msg)
+}
+
+// weakAnchor reports whether the regular expression has too little text to
+// tell lines apart. End expressions are searched from the start one, so
+// those anchored to the start of lines, such as /^}/, are fine.
+func weakAnchor(expr string, end bool) bool {
+	if expr == "$" || end && strings.HasPrefix(expr, "/^") {
+		return false
+	}
+	text := expr
+	for _, class := range []string{"[[:blank:]]", "[[:space:]]", `\n`, `\t`} {
+		text = strings.ReplaceAll(text, class, "")
+	}
+	n := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			n++
+		}
+	}
+	return n < 3
+}
+
+// suggestFor returns stronger regular expressions embedding the same lines
+// as cmd, whose start expression is start, or an empty string if there are
+// none.
+func suggestFor(cmd *command, start *regexp.Regexp, src []byte) string {
+	if cmd.end == nil {
+		return ""
+	}
+	loc := start.FindIndex(src)
+	if loc == nil {
+		return ""
+	}
+	end := len(src)
+	if *cmd.end != "$" {
+		re, err := compileSlashed(*cmd.end)
+		if err != nil {
+			return ""
+		}
+		eloc := re.FindIndex(src[loc[0]:])
+		if eloc == nil || eloc[1] == 0 {
+			return ""
+		}
+		end = loc[0] + eloc[1]
+	}
+	from := bytes.Count(src[:loc[0]], []byte("\n")) + 1
+	to := bytes.Count(src[:end-1], []byte("\n")) + 1
+	anchors, err := SuggestAnchors(string(src), from, to)
+	if err != nil || anchors == nil || anchors[0] == *cmd.start && anchors[len(anchors)-1] == *cmd.end {
+		return ""
+	}
+	return strings.Join(anchors, " ")
+}
+
+// compileSlashed compiles a regular expression written between slashes, as
+// in commands.
+func compileSlashed(s string) (*regexp.Regexp, error) {
+	if len(s) <= 2 || s[0] != '/' || s[len(s)-1] != '/' {
+		return nil, fmt.Errorf("missing slashes (/) around %q", s)
+	}
+	return regexp.CompilePOSIX(s[1 : len(s)-1])
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestAnchorLint(t *testing.T) {
+	src := `package main
+
+func hello() {
+	if true {
+		fmt.Println("hello")
+	}
+}
+
+func main() {
+	hello()
+}
+`
+	tc := []struct {
+		name     string
+		cmd      string
+		warnings []string
+	}{
+		{name: "robust", cmd: "(code.go /^func main/ /^}/)"},
+		{name: "whole file", cmd: "(code.go)"},
+		{
+			name:     "start matching several times",
+			cmd:      "(code.go /func/ /^}/)",
+			warnings: []string{`1: /func/ matches 2 times in code.go, only the first one is used, consider /^func hello\(\)/ /^\}.*/`},
+		},
+		{
+			name:     "bare brace",
+			cmd:      "(code.go /^func hello/ /}/)",
+			warnings: []string{`1: /}/ has too little text to match the right line, consider /^func hello\(\)/ /^[[:blank:]]*\}.*/`},
+		},
+		{
+			name:     "weak start",
+			cmd:      "(code.go /if/ $)",
+			warnings: []string{`1: /if/ has too little text to match the right line, consider /^[[:blank:]]*if true/ $`},
+		},
+		{
+			name:     "no suggestion for a start only",
+			cmd:      `(code.go /ma/)`,
+			warnings: []string{"1: /ma/ matches 2 times in code.go, only the first one is used, /ma/ has too little text to match the right line"},
+		},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		var warnings []string
+		err := Process(&out, strings.NewReader("[embedmd]:# "+tt.cmd+"\n"),
+			WithFetcher(mixedContentProvider{files: map[string][]byte{"code.go": []byte(src)}}),
+			WithAnchorLint(),
+			WithWarnings(func(line int, msg string) { warnings = append(warnings, fmt.Sprintf("%d: %s", line, msg)) }))
+		if err != nil {
+			t.Errorf("case [%s]: %v", tt.name, err)
+		}
+		if fmt.Sprint(warnings) != fmt.Sprint(tt.warnings) {
+			t.Errorf("case [%s]: expected warnings %q; got %q", tt.name, tt.warnings, warnings)
+		}
+	}
+}
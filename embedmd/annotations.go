@@ -0,0 +1,157 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// annotationStyles write the line numbers and highlighted lines of a code
+// block in the info string of its fence, after the language, for each style.
+// start is the number of the first line, or 0 if lines aren't numbered.
+var annotationStyles = map[string]func(start int, hl []lineRange) string{
+	"docusaurus": func(start int, hl []lineRange) string {
+		var parts []string
+		if len(hl) > 0 {
+			parts = append(parts, "{"+joinRanges(hl, ",")+"}")
+		}
+		switch {
+		case start == 1:
+			parts = append(parts, "showLineNumbers")
+		case start > 1:
+			parts = append(parts, fmt.Sprintf("showLineNumbers=%d", start))
+		}
+		return strings.Join(parts, " ")
+	},
+	"hugo": func(start int, hl []lineRange) string {
+		var parts []string
+		if start > 0 {
+			parts = append(parts, "linenos=table")
+		}
+		if start > 1 {
+			parts = append(parts, fmt.Sprintf("linenostart=%d", start))
+		}
+		if len(hl) > 0 {
+			parts = append(parts, `hl_lines=["`+joinRanges(hl, `","`)+`"]`)
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	},
+	"mkdocs": func(start int, hl []lineRange) string {
+		var parts []string
+		if start > 0 {
+			parts = append(parts, fmt.Sprintf("linenums=\"%d\"", start))
+		}
+		if len(hl) > 0 {
+			parts = append(parts, `hl_lines="`+joinRanges(hl, " ")+`"`)
+		}
+		return strings.Join(parts, " ")
+	},
+}
+
+// WithAnnotationStyle sets how the line numbers and highlighted lines set
+// with the linenos and hl attributes are written after the language of the
+// code fence: docusaurus, the default, writes {3-5} showLineNumbers=42, as
+// understood by Docusaurus and rehype-pretty-code; hugo writes
+// {linenos=table,linenostart=42,hl_lines=["3-5"]}; and mkdocs writes
+// linenums="42" hl_lines="3-5", for the highlight extension of Python
+// Markdown.
+func WithAnnotationStyle(style string) Option {
+	return Option{func(e *embedder) { e.annotationStyle = style }}
+}
+
+func (e *embedder) validateAnnotationStyle() error {
+	if e.annotationStyle == "" {
+		return nil
+	}
+	if _, ok := annotationStyles[e.annotationStyle]; !ok {
+		return fmt.Errorf("bad annotation style %q, should be docusaurus, hugo, or mkdocs", e.annotationStyle)
+	}
+	return nil
+}
+
+// annotated reports whether the code block of cmd has its lines numbered or
+// highlighted.
+func (cmd *command) annotated() bool {
+	return cmd.linenos || len(cmd.highlights) > 0
+}
+
+// annotations returns the annotations following the language in the fence of
+// the code block of cmd, if any. Lines are numbered from the first line of
+// the source embedded unless set with the start attribute.
+func (e *embedder) annotations(cmd *command) string {
+	if !cmd.annotated() {
+		return ""
+	}
+	start := 0
+	if cmd.linenos {
+		start = cmd.lineStart
+		if start == 0 {
+			start = max(cmd.region[0], 1)
+		}
+	}
+	style := e.annotationStyle
+	if style == "" {
+		style = "docusaurus"
+	}
+	return annotationStyles[style](start, cmd.highlights)
+}
+
+// checkHighlights fails if the lines highlighted by cmd are past the end of
+// the code block b.
+func checkHighlights(cmd *command, b []byte) error {
+	n := strings.Count(string(b), "\n")
+	for _, r := range cmd.highlights {
+		if r.to > n {
+			return fmt.Errorf("cannot highlight line %d, the code block has %d lines", r.to, n)
+		}
+	}
+	return nil
+}
+
+// parseHighlights parses a comma separated list of lines or ranges of lines,
+// such as 3-5,8.
+func parseHighlights(s string) ([]lineRange, error) {
+	var hl []lineRange
+	for _, f := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(f, "-")
+		r := lineRange{}
+		var err error
+		if r.from, err = strconv.Atoi(from); err != nil || r.from < 1 {
+			return nil, fmt.Errorf("hl should be a comma separated list of lines or ranges, such as 3-5,8, got %q", s)
+		}
+		r.to = r.from
+		if isRange {
+			if r.to, err = strconv.Atoi(to); err != nil || r.to < r.from {
+				return nil, fmt.Errorf("hl should be a comma separated list of lines or ranges, such as 3-5,8, got %q", s)
+			}
+		}
+		hl = append(hl, r)
+	}
+	return hl, nil
+}
+
+// joinRanges writes each range as from-to, or from alone for a single line,
+// separated by sep.
+func joinRanges(hl []lineRange, sep string) string {
+	parts := make([]string, len(hl))
+	for i, r := range hl {
+		parts[i] = strconv.Itoa(r.from)
+		if r.to != r.from {
+			parts[i] += "-" + strconv.Itoa(r.to)
+		}
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,75 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAnnotations(t *testing.T) {
+	files := map[string][]byte{
+		"code.go": []byte("package main\n\nfunc main() {\n\tif true {\n\t\tprintln(1)\n\t}\n}\n"),
+	}
+	const body = "func main() {\n\tif true {\n\t\tprintln(1)\n\t}\n}\n```\n"
+	tc := []struct {
+		name, style, in, out string
+		err                  string
+	}{
+		{name: "highlight", in: "[embedmd]:# (code.go /func/ /^}/ hl=2-4)\n",
+			out: "[embedmd]:# (code.go /func/ /^}/ hl=2-4)\n```go {2-4}\n" + body},
+		{name: "numbered from source", in: "[embedmd]:# (code.go /func/ /^}/ linenos=true)\n",
+			out: "[embedmd]:# (code.go /func/ /^}/ linenos=true)\n```go showLineNumbers=3\n" + body},
+		{name: "numbered from start", in: "[embedmd]:# (code.go /func/ /^}/ linenos=true start=42 hl=1,3-4)\n",
+			out: "[embedmd]:# (code.go /func/ /^}/ linenos=true start=42 hl=1,3-4)\n```go {1,3-4} showLineNumbers=42\n" + body},
+		{name: "numbered from 1", in: "[embedmd]:# (code.go#L3-L7 linenos=true start=1)\n",
+			out: "[embedmd]:# (code.go#L3-L7 linenos=true start=1)\n```go showLineNumbers\n" + body},
+		{name: "hugo", style: "hugo", in: "[embedmd]:# (code.go /func/ /^}/ linenos=true hl=1,3-4)\n",
+			out: "[embedmd]:# (code.go /func/ /^}/ linenos=true hl=1,3-4)\n```go {linenos=table,linenostart=3,hl_lines=[\"1\",\"3-4\"]}\n" + body},
+		{name: "mkdocs", style: "mkdocs", in: "[embedmd]:# (code.go /func/ /^}/ linenos=true hl=1,3-4)\n",
+			out: "[embedmd]:# (code.go /func/ /^}/ linenos=true hl=1,3-4)\n```go linenums=\"3\" hl_lines=\"1 3-4\"\n" + body},
+		{name: "indented block", in: "[embedmd]:# (code.go /func/ /^}/ hl=1)\n    old\n",
+			out: "[embedmd]:# (code.go /func/ /^}/ hl=1)\n```go {1}\n" + body},
+		{name: "past the end", in: "[embedmd]:# (code.go /func/ /^}/ hl=5-6)\n",
+			err: "1: cannot highlight line 6, the code block has 5 lines"},
+		{name: "bad highlight", in: "[embedmd]:# (code.go hl=4-2)\n",
+			err: "1: hl should be a comma separated list of lines or ranges, such as 3-5,8, got \"4-2\""},
+		{name: "bad linenos", in: "[embedmd]:# (code.go linenos=yes)\n",
+			err: "1: linenos should be true or false, got \"yes\""},
+		{name: "start alone", in: "[embedmd]:# (code.go start=4)\n",
+			err: "1: cannot use start without linenos=true"},
+		{name: "bad style", style: "pandoc", in: "[embedmd]:# (code.go hl=1)\n",
+			err: "bad annotation style \"pandoc\", should be docusaurus, hugo, or mkdocs"},
+	}
+	for _, tt := range tc {
+		opts := []Option{WithFetcher(mixedContentProvider{files: files})}
+		if tt.style != "" {
+			opts = append(opts, WithAnnotationStyle(tt.style))
+		}
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), opts...)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+		// Processing the output again must leave it unchanged.
+		var again bytes.Buffer
+		if err := Process(&again, strings.NewReader(tt.out), opts...); err != nil || again.String() != tt.out {
+			t.Errorf("case [%s]: reprocessing changed the output to\n%q\n(%v)", tt.name, again.String(), err)
+		}
+	}
+}
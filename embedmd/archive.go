@@ -0,0 +1,169 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// archiveExts are the extensions of the archives whose files can be embedded
+// with paths such as release.zip!/cmd/main.go.
+var archiveExts = []string{".zip", ".jar", ".tar", ".tar.gz", ".tgz"}
+
+// cutArchive splits a path into an archive into the path of the archive and
+// the path of the file inside of it.
+func cutArchive(p string) (archive, file string, ok bool) {
+	archive, file, ok = strings.Cut(p, "!/")
+	if !ok {
+		return "", "", false
+	}
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(strings.ToLower(archive), ext) {
+			return archive, file, true
+		}
+	}
+	return "", "", false
+}
+
+// readArchived returns the content of the file at the slash separated path
+// file in the archive b, read from the given path.
+func readArchived(b []byte, archive, file string) ([]byte, error) {
+	fsys, err := ArchiveFS(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", archive, err)
+	}
+	file = path.Clean(file)
+	if !fs.ValidPath(file) {
+		return nil, fmt.Errorf("bad path %q in %s", file, archive)
+	}
+	content, err := fs.ReadFile(fsys, file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s not found in %s", file, archive)
+	}
+	return content, err
+}
+
+// ArchiveFS returns the file system of the zip, tar, or gzipped tar archive
+// b, recognized by its content.
+func ArchiveFS(b []byte) (fs.FS, error) {
+	if bytes.HasPrefix(b, []byte("PK\x03\x04")) || bytes.HasPrefix(b, []byte("PK\x05\x06")) {
+		return zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	}
+	var r io.Reader = bytes.NewReader(b)
+	if bytes.HasPrefix(b, []byte("\x1f\x8b")) {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("bad gzip content: %v", err)
+		}
+		r = zr
+	}
+	fsys := memFS{}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return fsys, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bad archive: %v", err)
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("bad archive: %v", err)
+		}
+		fsys[path.Clean(strings.TrimPrefix(h.Name, "/"))] = content
+	}
+}
+
+// memFS is a file system holding the content of regular files by path, as
+// read from a tar archive.
+type memFS map[string][]byte
+
+func (m memFS) Open(name string) (fs.File, error) {
+	b, ok := m[name]
+	if !ok || !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &memFile{Reader: bytes.NewReader(b), name: path.Base(name)}, nil
+}
+
+// memFile is an open file of a memFS.
+type memFile struct {
+	*bytes.Reader
+	name string
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f, nil }
+func (f *memFile) Close() error               { return nil }
+
+func (f *memFile) Name() string       { return f.name }
+func (f *memFile) Size() int64        { return f.Reader.Size() }
+func (f *memFile) Mode() fs.FileMode  { return 0444 }
+func (f *memFile) ModTime() time.Time { return time.Time{} }
+func (f *memFile) IsDir() bool        { return false }
+func (f *memFile) Sys() any           { return nil }
+
+// FSMiddleware fetches the files of fsys, including those in its archives,
+// instead of the files on disk, so docs can be built from a vendored
+// artifact without unpacking it. Relative directories and paths are resolved
+// from the root of fsys. URLs and the paths of git and of repositories are
+// fetched by the next fetcher.
+func FSMiddleware(fsys fs.FS) Middleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherContextFunc(func(ctx context.Context, dir, p string) ([]byte, error) {
+			if isURL(p) || isGitPath(p) || isRepoPath(p) {
+				return FetchContext(ctx, next, dir, p)
+			}
+			if !path.IsAbs(p) {
+				p = path.Join(filepath.ToSlash(dir), p)
+			}
+			archive, file, inArchive := cutArchive(p)
+			if !inArchive {
+				archive = p
+			}
+			if archive = path.Clean(archive); !fs.ValidPath(archive) {
+				return nil, fmt.Errorf("%s is outside of the file system", archive)
+			}
+			b, err := fs.ReadFile(fsys, archive)
+			if err != nil || !inArchive {
+				return b, err
+			}
+			return readArchived(b, archive, file)
+		})
+	}
+}
+
+// NewFSFetcher creates a fetcher of the files of fsys, including those in its
+// archives, resolving relative directories and paths from its root. It
+// can't fetch URLs.
+func NewFSFetcher(fsys fs.FS) Fetcher {
+	return ChainFetcher(FetcherFunc(func(dir, p string) ([]byte, error) {
+		return nil, fmt.Errorf("cannot fetch %s from a file system", p)
+	}), FSMiddleware(fsys))
+}
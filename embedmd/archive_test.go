@@ -0,0 +1,119 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func tgzArchive(t *testing.T, files map[string]string) []byte {
+	var b bytes.Buffer
+	gw := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestFetcher_Archive(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"cmd/main.go": "package main\n"}
+	if err := os.WriteFile(filepath.Join(dir, "release.zip"), zipArchive(t, files), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "release.tar.gz"), tgzArchive(t, files), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tc := []struct {
+		name, path, want string
+		err              string
+	}{
+		{name: "zip", path: "release.zip!/cmd/main.go", want: "package main\n"},
+		{name: "tar.gz", path: "release.tar.gz!/cmd/main.go", want: "package main\n"},
+		{name: "cleaned", path: "release.zip!/cmd/../cmd/main.go", want: "package main\n"},
+		{name: "missing file", path: "release.zip!/main.go", err: "main.go not found in release.zip"},
+		{name: "outside", path: "release.zip!/../main.go", err: "bad path \"../main.go\" in release.zip"},
+	}
+	for _, tt := range tc {
+		b, err := NewFetcher(nil).Fetch(dir, tt.path)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if string(b) != tt.want {
+			t.Errorf("case [%s]: expected %q, got %q", tt.name, tt.want, b)
+		}
+	}
+}
+
+func TestFSFetcher(t *testing.T) {
+	fsys := fstest.MapFS{
+		"src/server.go":   {Data: []byte("package server\n")},
+		"vendor/app.tgz":  {Data: tgzArchive(t, map[string]string{"main.go": "package main\n"})},
+		"docs/readme.txt": {Data: []byte("read me\n")},
+	}
+	tc := []struct {
+		name, dir, path, want string
+		err                   string
+	}{
+		{name: "relative", dir: "docs", path: "../src/server.go", want: "package server\n"},
+		{name: "root", dir: ".", path: "docs/readme.txt", want: "read me\n"},
+		{name: "archive", dir: "docs", path: "../vendor/app.tgz!/main.go", want: "package main\n"},
+		{name: "missing", dir: "docs", path: "server.go", err: "open docs/server.go: file does not exist"},
+		{name: "outside", dir: "docs", path: "../../server.go", err: "../server.go is outside of the file system"},
+		{name: "url", dir: "docs", path: "https://example.com/main.go", err: "cannot fetch https://example.com/main.go from a file system"},
+	}
+	for _, tt := range tc {
+		b, err := NewFSFetcher(fsys).Fetch(tt.dir, tt.path)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if string(b) != tt.want {
+			t.Errorf("case [%s]: expected %q, got %q", tt.name, tt.want, b)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"io"
+)
+
+// Attribution configures the footers added under the blocks embedded with
+// attribution=link, crediting their sources with a link to the lines
+// embedded at a revision of their repository.
+type Attribution struct {
+	// Repo is the repository of the local sources, as host/owner/repo, on
+	// github.com or one of the forges, and Dir its local directory.
+	Repo, Dir string
+	// Rev is the revision linked, usually the commit checked out so links
+	// don't move with the branch.
+	Rev string
+}
+
+// WithAttribution sets the repository and revision linked by the footers
+// of the blocks embedded with attribution=link, which are regenerated on
+// each run as the blocks are.
+func WithAttribution(a Attribution) Option {
+	return Option{func(e *embedder) { e.attribution = &a }}
+}
+
+func (e *embedder) validateAttribution() error {
+	if e.attribution == nil {
+		return nil
+	}
+	if _, _, ok := e.forgeRepo(e.attribution.Repo, e.attribution.Rev); !ok {
+		return fmt.Errorf("bad repository %q of attributions, should be host/owner/repo on a known forge", e.attribution.Repo)
+	}
+	if e.attribution.Rev == "" {
+		return fmt.Errorf("missing revision of the attributions of %s", e.attribution.Repo)
+	}
+	return nil
+}
+
+// sourceLink returns the markdown link to the lines of the local source of
+// cmd embedded, at the revision of the attributions.
+func (e *embedder) sourceLink(cmd *command) (string, error) {
+	if e.attribution == nil {
+		return "", fmt.Errorf("attribution=link needs the repository and revision of the sources")
+	}
+	if isURL(cmd.path) {
+		return "", fmt.Errorf("can't attribute %s, which isn't a local source", cmd.path)
+	}
+	ff, p, ok := e.forgeFile(cmd, e.attribution.Repo, e.attribution.Dir, e.attribution.Rev)
+	if !ok {
+		return "", fmt.Errorf("can't attribute %s, which isn't a file of %s", cmd.path, e.attribution.Repo)
+	}
+	linker, ok := p.(Linker)
+	if !ok {
+		return "", fmt.Errorf("can't attribute %s, as the forge of %s doesn't link to files", cmd.path, e.attribution.Repo)
+	}
+	text := ff.Path
+	if start, end := cmd.region[0], cmd.region[1]; start > 0 {
+		text += fmt.Sprintf("#L%d", start)
+		if end > start {
+			text += fmt.Sprintf("-L%d", end)
+		}
+	}
+	return fmt.Sprintf("[%s](%s)", text, linker.BlobURL(ff, cmd.region[0], cmd.region[1])), nil
+}
+
+// hasSourceLinks reports whether the block of cmd credits the sources of any
+// of its commands.
+func hasSourceLinks(cmd *command) bool {
+	for _, c := range append([]*command{cmd}, cmd.stacked...) {
+		if c.sourceLink != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeSourceLinks writes the footers crediting the sources of cmd and the
+// commands stacked on it.
+func writeSourceLinks(w io.Writer, cmd *command) {
+	for _, c := range append([]*command{cmd}, cmd.stacked...) {
+		if c.sourceLink != "" {
+			fmt.Fprintf(w, "\n_Source: %s_\n", c.sourceLink)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAttribution(t *testing.T) {
+	p := mixedContentProvider{
+		files: map[string][]byte{"pkg/code.go": []byte(content), "other.go": []byte("package other\n")},
+		urls:  map[string][]byte{"https://example.com/x.go": []byte("package x\n")},
+	}
+	repo := &Attribution{Repo: "github.com/org/repo", Dir: ".", Rev: "0123abc"}
+	fenced := "```go\nfunc main() {\n        fmt.Println(\"hello, test\")\n}\n```\n"
+	tc := []struct {
+		name, in, out string
+		attribution   *Attribution
+		err           string
+	}{
+		{
+			name:        "local source",
+			in:          "[embedmd]:# (../pkg/code.go /func main/ $ attribution=link)\n",
+			out:         "[embedmd]:# (../pkg/code.go /func main/ $ attribution=link)\n<!-- embedmd block start -->\n" + fenced + "\n_Source: [pkg/code.go#L6-L8](https://github.com/org/repo/blob/0123abc/pkg/code.go#L6-L8)_\n<!-- embedmd block end -->\n",
+			attribution: repo,
+		},
+		{
+			name:        "regenerated",
+			in:          "[embedmd]:# (../pkg/code.go /func main/ $ attribution=link)\n<!-- embedmd block start -->\n" + fenced + "\n_Source: [pkg/code.go#L1-L2](https://github.com/org/repo/blob/old/pkg/code.go#L1-L2)_\n<!-- embedmd block end -->\n",
+			out:         "[embedmd]:# (../pkg/code.go /func main/ $ attribution=link)\n<!-- embedmd block start -->\n" + fenced + "\n_Source: [pkg/code.go#L6-L8](https://github.com/org/repo/blob/0123abc/pkg/code.go#L6-L8)_\n<!-- embedmd block end -->\n",
+			attribution: repo,
+		},
+		{
+			name:        "not requested",
+			in:          "[embedmd]:# (../pkg/code.go /func main/ $)\n",
+			out:         "[embedmd]:# (../pkg/code.go /func main/ $)\n" + fenced,
+			attribution: repo,
+		},
+		{
+			name: "stacked on gitlab",
+			in:   "[embedmd]:# (../pkg/code.go /func main/ $ attribution=link)\n[embedmd]:# (../other.go attribution=link)\n",
+			out: "[embedmd]:# (../pkg/code.go /func main/ $ attribution=link)\n[embedmd]:# (../other.go attribution=link)\n<!-- embedmd block start -->\n" +
+				strings.TrimSuffix(fenced, "```\n") + "package other\n```\n\n" +
+				"_Source: [pkg/code.go#L6-L8](https://gitlab.com/org/repo/-/blob/0123abc/pkg/code.go#L6-8)_\n\n" +
+				"_Source: [other.go#L1](https://gitlab.com/org/repo/-/blob/0123abc/other.go#L1)_\n<!-- embedmd block end -->\n",
+			attribution: &Attribution{Repo: "gitlab.com/org/repo", Dir: ".", Rev: "0123abc"},
+		},
+		{
+			name:        "remote source",
+			in:          "[embedmd]:# (https://example.com/x.go attribution=link)\n",
+			attribution: repo,
+			err:         "1: can't attribute https://example.com/x.go, which isn't a local source",
+		},
+		{
+			name:        "outside of the repository",
+			in:          "[embedmd]:# (../other.go attribution=link)\n",
+			attribution: &Attribution{Repo: "github.com/org/repo", Dir: "docs", Rev: "0123abc"},
+			err:         "1: can't attribute ../other.go, which isn't a file of github.com/org/repo",
+		},
+		{
+			name:        "overridden",
+			in:          "[embedmd]:# (../other.go attribution=none)\n",
+			out:         "[embedmd]:# (../other.go attribution=none)\n```go\npackage other\n```\n",
+			attribution: repo,
+		},
+		{
+			name:        "bad value",
+			in:          "[embedmd]:# (../other.go attribution=footer)\n",
+			attribution: repo,
+			err:         "1: attribution should be link or none, got \"footer\"",
+		},
+		{
+			name: "not configured",
+			in:   "[embedmd]:# (../other.go attribution=link)\n",
+			err:  "1: attribution=link needs the repository and revision of the sources",
+		},
+		{
+			name:        "unknown forge",
+			in:          "[embedmd]:# (../other.go)\n",
+			attribution: &Attribution{Repo: "git.example.com/org/repo", Rev: "0123abc"},
+			err:         "bad repository \"git.example.com/org/repo\" of attributions, should be host/owner/repo on a known forge",
+		},
+		{
+			name:        "missing revision",
+			in:          "[embedmd]:# (../other.go)\n",
+			attribution: &Attribution{Repo: "github.com/org/repo"},
+			err:         "missing revision of the attributions of github.com/org/repo",
+		},
+	}
+	for _, tt := range tc {
+		opts := []Option{WithFetcher(p), WithBaseDir("docs")}
+		if tt.attribution != nil {
+			opts = append(opts, WithAttribution(*tt.attribution))
+		}
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), opts...)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// resolvePath expands the variables in the path of cmd, and resolves it from
+// its directory if it's relative, inferring again the language from its
+// extension if it changed and wasn't set. The directory of commands is set
+// with the basedir attribute, or by a basedir directive, such as
+//
+//	[embedmd]:# (basedir ../../examples)
+//
+// for the commands after it, until the next one. Both are relative to the
+// base directory.
+func (e *embedder) resolvePath(cmd *command) error {
+	written := cmd.path
+	if err := e.expandVars(cmd); err != nil {
+		return err
+	}
+	p := cmd.path
+	if cmd.dir != "" {
+		// The path is resolved once, even if embedded again.
+		p, cmd.dir = relocate(cmd.dir, p), ""
+	}
+	if p != written && cmd.inferredLang {
+		cmd.lang = strings.TrimPrefix(filepath.Ext(sourceName(p)[1:]), ".")
+	}
+	cmd.path = p
+	return nil
+}
+
+// relocate returns the path p, as written in a command, resolved from dir
+// if it's a relative file path. URLs, aliases, absolute paths, and paths of
+// other schemes but git:// are returned as is.
+func relocate(dir, p string) string {
+	prefix := ""
+	if isGitPath(p) {
+		prefix, p = gitScheme, strings.TrimPrefix(p, gitScheme)
+	}
+	switch {
+	case isURL(p), isExecPath(p), isRepoPath(p), strings.HasPrefix(p, "@"), path.IsAbs(p), filepath.IsAbs(p):
+		return prefix + p
+	}
+	return prefix + path.Join(dir, p)
+}
+
+// gitRoot returns the root of the git repository of the base directory,
+// relative to it.
+func (e *embedder) gitRoot() (string, error) {
+	if e.root != "" {
+		return e.root, nil
+	}
+	out, err := runGit(e.context(), e.baseDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fmt.Errorf("no git repository for {{ .root }}: %v", err)
+	}
+	base, err := filepath.Abs(e.baseDir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(base, strings.TrimSpace(string(out)))
+	if err != nil {
+		return "", err
+	}
+	e.root = filepath.ToSlash(rel)
+	return e.root, nil
+}
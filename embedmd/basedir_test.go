@@ -0,0 +1,115 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBaseDirDirective(t *testing.T) {
+	files := map[string][]byte{
+		"examples/v2/main.go": []byte("package main\n"),
+		"examples/v1/main.go": []byte("package old\n"),
+		"main.go":             []byte("package top\n"),
+	}
+	urls := map[string][]byte{"https://example.com/main.go": []byte("package remote\n")}
+	tc := []struct {
+		name    string
+		in      string
+		aliases map[string]string
+		out     string
+		err     string
+	}{
+		{name: "directive",
+			in:  "[embedmd]:# (basedir examples/v2)\n[embedmd]:# (main.go)\n",
+			out: "[embedmd]:# (basedir examples/v2)\n[embedmd]:# (main.go)\n```go\npackage main\n```\n"},
+		{name: "nearest directive",
+			in:  "[embedmd]:# (basedir examples/v2)\n\n[embedmd]:# (basedir examples/v1)\n[embedmd]:# (main.go)\n\n[embedmd]:# (basedir .)\n[embedmd]:# (main.go)\n",
+			out: "[embedmd]:# (basedir examples/v2)\n\n[embedmd]:# (basedir examples/v1)\n[embedmd]:# (main.go)\n```go\npackage old\n```\n\n[embedmd]:# (basedir .)\n[embedmd]:# (main.go)\n```go\npackage top\n```\n"},
+		{name: "attribute",
+			in:  "[embedmd]:# (basedir examples/v1)\n[embedmd]:# (main.go basedir=examples/v2)\n[embedmd]:# (main.go)\n",
+			out: "[embedmd]:# (basedir examples/v1)\n[embedmd]:# (main.go basedir=examples/v2)\n[embedmd]:# (main.go)\n```go\npackage main\npackage old\n```\n"},
+		{name: "relative to the directory",
+			in:  "[embedmd]:# (basedir examples/v2/cmd)\n[embedmd]:# (../main.go)\n",
+			out: "[embedmd]:# (basedir examples/v2/cmd)\n[embedmd]:# (../main.go)\n```go\npackage main\n```\n"},
+		{name: "urls and aliases",
+			in:      "[embedmd]:# (basedir examples/v1)\n[embedmd]:# (https://example.com/main.go)\n[embedmd]:# (@v2/main.go)\n",
+			aliases: map[string]string{"@v2": "examples/v2"},
+			out:     "[embedmd]:# (basedir examples/v1)\n[embedmd]:# (https://example.com/main.go)\n[embedmd]:# (@v2/main.go)\n```go\npackage remote\npackage main\n```\n"},
+		{name: "code block after the directive",
+			in:  "[embedmd]:# (basedir examples/v2)\n```go\nkept\n```\n",
+			out: "[embedmd]:# (basedir examples/v2)\n```go\nkept\n```\n"},
+		{name: "front matter variable",
+			in:  "---\nexamples: examples/v2\n---\n[embedmd]:# (basedir {{ .examples }})\n[embedmd]:# (main.go)\n",
+			out: "---\nexamples: examples/v2\n---\n[embedmd]:# (basedir {{ .examples }})\n[embedmd]:# (main.go)\n```go\npackage main\n```\n"},
+		{name: "missing directory",
+			in:  "[embedmd]:# (basedir)\n",
+			err: "1: basedir takes a single directory"},
+		{name: "empty attribute",
+			in:  "[embedmd]:# (main.go basedir=)\n",
+			err: `1: basedir should be a directory, got ""`},
+	}
+	for _, tt := range tc {
+		opts := []Option{WithFetcher(mixedContentProvider{files: files, urls: urls})}
+		for name, target := range tt.aliases {
+			opts = append(opts, WithAlias(name, target))
+		}
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), opts...)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if out.String() != tt.out {
+			t.Errorf("case [%s]: expected\n%q\ngot\n%q", tt.name, tt.out, out.String())
+		}
+	}
+}
+
+func TestRootVariable(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "-C", dir, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	docs := filepath.Join(dir, "docs", "guide", "v2")
+	for _, d := range []string{docs, filepath.Join(dir, "examples")} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "examples", "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	in := "[embedmd]:# ({{ .root }}/examples/main.go)\n"
+	var out bytes.Buffer
+	if err := Process(&out, strings.NewReader(in), WithBaseDir(docs)); err != nil {
+		t.Fatal(err)
+	}
+	if want := in + "```go\npackage main\n```\n"; out.String() != want {
+		t.Errorf("expected\n%q\ngot\n%q", want, out.String())
+	}
+
+	err := Process(&out, strings.NewReader(in), WithBaseDir(t.TempDir()))
+	if err == nil || !strings.HasPrefix(err.Error(), "1: no git repository for {{ .root }}: ") {
+		t.Errorf("expected an error outside of git repositories, got %v", err)
+	}
+}
@@ -0,0 +1,421 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Policy expressions are written in a subset of CEL, the Common Expression
+// Language (https://cel.dev): string, bool, and list literals, the operators
+// !, &&, ||, ==, !=, and in, field selection and indexing on maps, and the
+// string methods startsWith, endsWith, contains, and matches.
+
+// celFunc evaluates a compiled expression with the given variables.
+type celFunc func(vars map[string]any) (any, error)
+
+type celToken struct {
+	// kind is 'i' for identifiers, 's' for strings, and 'p' for punctuation.
+	kind byte
+	text string
+}
+
+// compileCEL compiles the expression src, which can use the given variables.
+func compileCEL(src string, vars []string) (celFunc, error) {
+	toks, err := lexCEL(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &celParser{toks: toks, vars: vars}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q", p.toks[p.pos].text)
+	}
+	return f, nil
+}
+
+var celPunct = []string{"&&", "||", "==", "!=", "(", ")", "[", "]", ",", ".", "!"}
+
+func lexCEL(s string) ([]celToken, error) {
+	var toks []celToken
+	isIdent := func(c byte) bool {
+		return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+	}
+next:
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isIdent(c):
+			j := i
+			for j < len(s) && isIdent(s[j]) {
+				j++
+			}
+			toks = append(toks, celToken{'i', s[i:j]})
+			i = j
+		case c == '"' || c == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != c; j++ {
+				if s[j] != '\\' || j+1 == len(s) {
+					b.WriteByte(s[j])
+					continue
+				}
+				j++
+				switch s[j] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case '\\', '"', '\'':
+					b.WriteByte(s[j])
+				default:
+					// Other escapes, as in regular expressions, are kept.
+					b.WriteByte('\\')
+					b.WriteByte(s[j])
+				}
+			}
+			if j == len(s) {
+				return nil, fmt.Errorf("unterminated string at %d", i+1)
+			}
+			toks = append(toks, celToken{'s', b.String()})
+			i = j + 1
+		default:
+			for _, p := range celPunct {
+				if strings.HasPrefix(s[i:], p) {
+					toks = append(toks, celToken{'p', p})
+					i += len(p)
+					continue next
+				}
+			}
+			return nil, fmt.Errorf("unexpected %q at %d", c, i+1)
+		}
+	}
+	return toks, nil
+}
+
+type celParser struct {
+	toks []celToken
+	pos  int
+	vars []string
+}
+
+// accept consumes the next token if it's the given punctuation.
+func (p *celParser) accept(punct string) bool {
+	if p.pos < len(p.toks) && p.toks[p.pos].kind == 'p' && p.toks[p.pos].text == punct {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *celParser) expect(punct string) error {
+	if p.accept(punct) {
+		return nil
+	}
+	if p.pos == len(p.toks) {
+		return fmt.Errorf("expected %q at the end", punct)
+	}
+	return fmt.Errorf("expected %q, got %q", punct, p.toks[p.pos].text)
+}
+
+func (p *celParser) or() (celFunc, error) {
+	return p.logical("||", p.and, true)
+}
+
+func (p *celParser) and() (celFunc, error) {
+	return p.logical("&&", p.relation, false)
+}
+
+// logical parses operands separated by op, whose evaluation stops at the
+// first one equal to stop.
+func (p *celParser) logical(op string, operand func() (celFunc, error), stop bool) (celFunc, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(op) {
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(vars map[string]any) (any, error) {
+			for _, f := range []celFunc{l, right} {
+				b, err := evalBool(f, vars, op)
+				if err != nil || b == stop {
+					return b, err
+				}
+			}
+			return !stop, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *celParser) relation() (celFunc, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	var op string
+	switch {
+	case p.accept("=="):
+		op = "=="
+	case p.accept("!="):
+		op = "!="
+	case p.pos < len(p.toks) && p.toks[p.pos] == celToken{'i', "in"}:
+		p.pos++
+		op = "in"
+	default:
+		return left, nil
+	}
+	right, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return func(vars map[string]any) (any, error) {
+		l, err := left(vars)
+		if err != nil {
+			return nil, err
+		}
+		r, err := right(vars)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "==":
+			return reflect.DeepEqual(l, r), nil
+		case "!=":
+			return !reflect.DeepEqual(l, r), nil
+		}
+		switch r := r.(type) {
+		case []any:
+			for _, v := range r {
+				if reflect.DeepEqual(l, v) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]string:
+			if k, ok := l.(string); ok {
+				_, found := r[k]
+				return found, nil
+			}
+		}
+		return nil, fmt.Errorf("no such overload: %s in %s", celType(l), celType(r))
+	}, nil
+}
+
+func (p *celParser) unary() (celFunc, error) {
+	if !p.accept("!") {
+		return p.member()
+	}
+	f, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return func(vars map[string]any) (any, error) {
+		b, err := evalBool(f, vars, "!")
+		return !b, err
+	}, nil
+}
+
+func (p *celParser) member() (celFunc, error) {
+	f, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("."):
+			if p.pos == len(p.toks) || p.toks[p.pos].kind != 'i' {
+				return nil, fmt.Errorf("expected a name after .")
+			}
+			name := p.toks[p.pos].text
+			p.pos++
+			if p.accept("(") {
+				if f, err = p.method(f, name); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			f = index(f, func(map[string]any) (any, error) { return name, nil })
+		case p.accept("["):
+			key, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			f = index(f, key)
+		default:
+			return f, nil
+		}
+	}
+}
+
+// index returns the value of the map m for the key.
+func index(m, key celFunc) celFunc {
+	return func(vars map[string]any) (any, error) {
+		mv, err := m(vars)
+		if err != nil {
+			return nil, err
+		}
+		kv, err := key(vars)
+		if err != nil {
+			return nil, err
+		}
+		mm, ok := mv.(map[string]string)
+		k, kok := kv.(string)
+		if !ok || !kok {
+			return nil, fmt.Errorf("no such overload: %s[%s]", celType(mv), celType(kv))
+		}
+		v, ok := mm[k]
+		if !ok {
+			return nil, fmt.Errorf("no such key: %s", k)
+		}
+		return v, nil
+	}
+}
+
+// stringMethods are the methods of strings, taking a string argument.
+var stringMethods = map[string]func(s, arg string) (bool, error){
+	"startsWith": func(s, arg string) (bool, error) { return strings.HasPrefix(s, arg), nil },
+	"endsWith":   func(s, arg string) (bool, error) { return strings.HasSuffix(s, arg), nil },
+	"contains":   func(s, arg string) (bool, error) { return strings.Contains(s, arg), nil },
+	"matches":    func(s, arg string) (bool, error) { return regexp.MatchString(arg, s) },
+}
+
+// method parses the arguments of the call of the named method on recv.
+func (p *celParser) method(recv celFunc, name string) (celFunc, error) {
+	m, ok := stringMethods[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown method %s", name)
+	}
+	arg, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return func(vars map[string]any) (any, error) {
+		rv, err := recv(vars)
+		if err != nil {
+			return nil, err
+		}
+		av, err := arg(vars)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := rv.(string)
+		a, aok := av.(string)
+		if !ok || !aok {
+			return nil, fmt.Errorf("no such overload: %s.%s(%s)", celType(rv), name, celType(av))
+		}
+		return m(s, a)
+	}, nil
+}
+
+func (p *celParser) primary() (celFunc, error) {
+	if p.pos == len(p.toks) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	t := p.toks[p.pos]
+	p.pos++
+	constant := func(v any) celFunc { return func(map[string]any) (any, error) { return v, nil } }
+	switch {
+	case t.kind == 's':
+		return constant(t.text), nil
+	case t == celToken{'i', "true"}:
+		return constant(true), nil
+	case t == celToken{'i', "false"}:
+		return constant(false), nil
+	case t.kind == 'i':
+		for _, v := range p.vars {
+			if v == t.text {
+				return func(vars map[string]any) (any, error) { return vars[t.text], nil }, nil
+			}
+		}
+		return nil, fmt.Errorf("undeclared reference to %s", t.text)
+	case t.text == "(":
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return f, p.expect(")")
+	case t.text == "[":
+		var elems []celFunc
+		for !p.accept("]") {
+			if len(elems) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			f, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, f)
+		}
+		return func(vars map[string]any) (any, error) {
+			list := []any{}
+			for _, f := range elems {
+				v, err := f(vars)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			return list, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+// evalBool evaluates f, which must return a bool as operand of op.
+func evalBool(f celFunc, vars map[string]any, op string) (bool, error) {
+	v, err := f(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("no such overload: %s applied to %s", op, celType(v))
+	}
+	return b, nil
+}
+
+// celType returns the CEL name of the type of v.
+func celType(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case []any:
+		return "list"
+	case map[string]string:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,121 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// FetcherOption configures a Fetcher created by NewFetcher.
+type FetcherOption struct{ f func(*fetcher) }
+
+// WithCharset decodes all remote content from the given charset, ignoring
+// the one declared by the server. Use it for servers that declare no charset
+// or a wrong one.
+func WithCharset(charset string) FetcherOption {
+	return FetcherOption{func(f *fetcher) { f.charset = charset }}
+}
+
+// metaCharset matches the charset declared by an HTML meta tag.
+var metaCharset = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?([\w.:-]+)`)
+
+// charsetOf returns the charset of content served with the given content
+// type, as declared by its charset parameter or, for HTML content, by a meta
+// tag in its first kilobyte.
+func charsetOf(contentType string, b []byte) string {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	if cs := params["charset"]; cs != "" {
+		return cs
+	}
+	if mediaType != "text/html" && !isHTMLPage(b) {
+		return ""
+	}
+	if len(b) > 1024 {
+		b = b[:1024]
+	}
+	if m := metaCharset.FindSubmatch(b); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// toUTF8 returns b decoded from the given charset. Only UTF-8, UTF-16, and
+// the Latin-1 charsets are supported.
+func toUTF8(b []byte, charset string) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "", "utf-8", "utf8", "unicode-1-1-utf-8":
+		return b, nil
+	case "us-ascii", "ascii", "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "l1", "cp819",
+		"windows-1252", "cp1252", "x-cp1252":
+		// As browsers do, the Latin-1 labels are decoded as Windows-1252,
+		// its superset that servers often mislabel.
+		return decodeWindows1252(b), nil
+	case "utf-16", "utf-16le":
+		return decodeUTF16(b, false)
+	case "utf-16be":
+		return decodeUTF16(b, true)
+	}
+	return nil, fmt.Errorf("unsupported charset %q", charset)
+}
+
+// windows1252 maps the bytes from 0x80 to 0x9f to the characters they
+// represent in Windows-1252, the undefined ones to the matching C1 control.
+var windows1252 = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '\u008d', 'Ž', '\u008f',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '\u009d', 'ž', 'Ÿ',
+}
+
+func decodeWindows1252(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	for _, c := range b {
+		r := rune(c)
+		if c >= 0x80 && c < 0xa0 {
+			r = windows1252[c-0x80]
+		}
+		out = utf8.AppendRune(out, r)
+	}
+	return out
+}
+
+// decodeUTF16 decodes b from UTF-16, in the byte order given by its byte
+// order mark if any.
+func decodeUTF16(b []byte, bigEndian bool) ([]byte, error) {
+	switch {
+	case len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff:
+		bigEndian, b = true, b[2:]
+	case len(b) >= 2 && b[0] == 0xff && b[1] == 0xfe:
+		bigEndian, b = false, b[2:]
+	}
+	if len(b)%2 != 0 {
+		return nil, fmt.Errorf("truncated UTF-16 content")
+	}
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+		} else {
+			units[i] = uint16(b[2*i+1])<<8 | uint16(b[2*i])
+		}
+	}
+	out := make([]byte, 0, len(b))
+	for _, r := range utf16.Decode(units) {
+		out = utf8.AppendRune(out, r)
+	}
+	return out, nil
+}
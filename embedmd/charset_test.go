@@ -0,0 +1,115 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetcher_Charset(t *testing.T) {
+	tc := []struct {
+		name        string
+		contentType string
+		body        string
+		override    string
+		want        string
+		err         string
+	}{
+		{
+			name:        "utf-8",
+			contentType: "text/plain; charset=utf-8",
+			body:        "// café\n",
+			want:        "// café\n",
+		},
+		{
+			name:        "latin-1",
+			contentType: "text/plain; charset=ISO-8859-1",
+			body:        "// caf\xe9\n",
+			want:        "// café\n",
+		},
+		{
+			name:        "latin-1 as windows-1252",
+			contentType: "text/plain; charset=latin1",
+			body:        "// \x93quoted\x94 \x80\n",
+			want:        "// “quoted” €\n",
+		},
+		{
+			name:        "utf-16 with byte order mark",
+			contentType: "text/plain; charset=utf-16",
+			body:        "\xfe\xff\x00h\x00\xe9\x00\n",
+			want:        "hé\n",
+		},
+		{
+			name:        "utf-16le",
+			contentType: "text/plain; charset=utf-16le",
+			body:        "h\x00\xe9\x00\n",
+			err:         "truncated UTF-16 content",
+		},
+		{
+			name:        "meta charset",
+			contentType: "text/html",
+			body:        "<html><head><meta charset=\"iso-8859-1\"></head>caf\xe9</html>",
+			want:        "<html><head><meta charset=\"iso-8859-1\"></head>café</html>",
+		},
+		{
+			name:        "meta http-equiv",
+			contentType: "text/html",
+			body:        "<meta http-equiv=\"Content-Type\" content=\"text/html; charset=windows-1252\">\x85",
+			want:        "<meta http-equiv=\"Content-Type\" content=\"text/html; charset=windows-1252\">…",
+		},
+		{
+			name:        "meta ignored in code",
+			contentType: "text/plain",
+			body:        "const tag = `<meta charset=\"latin1\">`\n",
+			want:        "const tag = `<meta charset=\"latin1\">`\n",
+		},
+		{
+			name:        "override",
+			contentType: "text/plain; charset=utf-8",
+			body:        "// caf\xe9\n",
+			override:    "iso-8859-1",
+			want:        "// café\n",
+		},
+		{
+			name:        "unsupported charset",
+			contentType: "text/plain; charset=shift_jis",
+			body:        "// x\n",
+			err:         `unsupported charset "shift_jis"`,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			var opts []FetcherOption
+			if tt.override != "" {
+				opts = append(opts, WithCharset(tt.override))
+			}
+			b, err := NewFetcher(nil, opts...).Fetch("", server.URL)
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if string(b) != tt.want {
+				t.Errorf("expected %q; got %q", tt.want, b)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// WithChecksums adds a comment with a short checksum of each generated block
+// after it. When the block is generated again, the checksum is used to tell
+// apart blocks whose source changed from blocks that were edited by hand,
+// reporting each with a different warning.
+func WithChecksums() Option {
+	return Option{func(e *embedder) { e.checksums = true }}
+}
+
+// checksum returns a short hash of the given block.
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:6])
+}
+
+// checkBlock warns when the block previously generated for cmd differs from
+// the new one b, either because it was edited by hand or because its source
+// changed.
+func (e *embedder) checkBlock(cmd *command, b []byte) {
+	if cmd.block == nil || cmd.checksum == "" {
+		return
+	}
+	switch {
+	case checksum(cmd.block) != cmd.checksum:
+		e.warnf(cmd, "block was edited by hand since it was generated")
+	case !bytes.Equal(cmd.block, b):
+		e.warnf(cmd, "%s changed since the block was generated", cmd.path)
+	}
+}
+
+// WithIncremental keeps as they are the blocks whose commands are unchanged
+// since they were generated, without fetching their sources, so only the
+// blocks of the commands edited are generated again, as when a caption is
+// changed. It needs WithChecksums: the checksum comments of the blocks record
+// the fingerprint of their commands, their arguments and those of the
+// commands stacked on them. Changes to the sources, and to the options, are
+// only picked up by runs without WithIncremental.
+func WithIncremental() Option {
+	return Option{func(e *embedder) { e.incremental = true }}
+}
+
+// validateIncremental checks that the fingerprints of incremental runs can
+// be recorded.
+func (e *embedder) validateIncremental() error {
+	if e.incremental && !e.checksums {
+		return errors.New("incremental runs need checksums")
+	}
+	return nil
+}
+
+// fingerprint returns a short hash of the arguments of cmd and of the
+// commands stacked on it.
+func fingerprint(cmd *command) string {
+	h := sha256.New()
+	for _, c := range append([]*command{cmd}, cmd.stacked...) {
+		io.WriteString(h, c.args+"\n") //nolint:errcheck
+	}
+	return hex.EncodeToString(h.Sum(nil)[:6])
+}
+
+// checksumTrailer returns the comment recording the checksum of a block, and
+// the fingerprint of its command if any.
+func checksumTrailer(sum, fingerprint string) string {
+	if fingerprint != "" {
+		sum += " " + fingerprint
+	}
+	return checksumPrefix + sum + " -->\n"
+}
+
+// upToDate reports whether incremental runs keep the block of cmd: it was
+// generated by the same command, and not edited by hand since.
+func (e *embedder) upToDate(cmd *command) bool {
+	return e.incremental && cmd.block != nil && cmd.checksum != "" &&
+		cmd.fingerprint == fingerprint(cmd) && checksum(cmd.block) == cmd.checksum
+}
@@ -0,0 +1,174 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestChecksums(t *testing.T) {
+	block := "```go\n" + content + "```\n"
+	sum := checksum([]byte(block))
+	edited := "```go\n" + content + "// edited\n```\n"
+	oldContent := content[:len(content)-2]
+	oldBlock := "```go\n" + oldContent + "\n```\n"
+
+	tc := []struct {
+		name     string
+		in       string
+		out      string
+		warnings []string
+		err      string
+	}{
+		{
+			name: "first generation",
+			in:   "[embedmd]:# (code.go)\nYay!\n",
+			out:  "[embedmd]:# (code.go)\n" + block + "<!-- embedmd checksum " + sum + " -->\nYay!\n",
+		},
+		{
+			name: "adding a checksum to an existing block",
+			in:   "[embedmd]:# (code.go)\n" + block + "Yay!\n",
+			out:  "[embedmd]:# (code.go)\n" + block + "<!-- embedmd checksum " + sum + " -->\nYay!\n",
+		},
+		{
+			name: "up to date block",
+			in:   "[embedmd]:# (code.go)\n" + block + "<!-- embedmd checksum " + sum + " -->\nYay!\n",
+			out:  "[embedmd]:# (code.go)\n" + block + "<!-- embedmd checksum " + sum + " -->\nYay!\n",
+		},
+		{
+			name: "source changed",
+			in: "[embedmd]:# (code.go)\n" + oldBlock +
+				"<!-- embedmd checksum " + checksum([]byte(oldBlock)) + " -->\n",
+			out:      "[embedmd]:# (code.go)\n" + block + "<!-- embedmd checksum " + sum + " -->\n",
+			warnings: []string{"1: code.go changed since the block was generated"},
+		},
+		{
+			name:     "edited by hand",
+			in:       "[embedmd]:# (code.go)\n" + edited + "<!-- embedmd checksum " + sum + " -->\n",
+			out:      "[embedmd]:# (code.go)\n" + block + "<!-- embedmd checksum " + sum + " -->\n",
+			warnings: []string{"1: block was edited by hand since it was generated"},
+		},
+		{
+			name: "followed by another block",
+			in: "[embedmd]:# (code.go)\n" + block + "<!-- embedmd checksum " + sum + " -->\n" +
+				"```\ntext\n```\n",
+			out: "[embedmd]:# (code.go)\n" + block + "<!-- embedmd checksum " + sum + " -->\n" +
+				"```\ntext\n```\n",
+		},
+		{
+			name: "errors are reported at the command",
+			in:   "\n[embedmd]:# (missing.go)\n" + block + "<!-- embedmd checksum " + sum + " -->\n",
+			err:  "2: could not read missing.go: file does not exist",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var warnings []string
+			err := Process(&out, strings.NewReader(tt.in),
+				WithFetcher(mixedContentProvider{files: map[string][]byte{"code.go": []byte(content)}}),
+				WithChecksums(),
+				WithWarnings(func(line int, msg string) {
+					warnings = append(warnings, fmt.Sprintf("%d: %s", line, msg))
+				}))
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if got := out.String(); got != tt.out {
+				t.Errorf("expected output\n%s\ngot\n%s", tt.out, got)
+			}
+			if fmt.Sprint(warnings) != fmt.Sprint(tt.warnings) {
+				t.Errorf("expected warnings %q; got %q", tt.warnings, warnings)
+			}
+		})
+	}
+}
+
+func TestChecksumsDisabled(t *testing.T) {
+	block := "```go\n" + content + "```\n"
+	in := "[embedmd]:# (code.go)\n" + block + "<!-- embedmd checksum 000000000000 -->\nYay!\n"
+	want := "[embedmd]:# (code.go)\n" + block + "Yay!\n"
+
+	var out bytes.Buffer
+	err := Process(&out, strings.NewReader(in),
+		WithFetcher(mixedContentProvider{files: map[string][]byte{"code.go": []byte(content)}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != want {
+		t.Errorf("expected output\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestIncremental(t *testing.T) {
+	files := map[string][]byte{"a.go": []byte("package a\n"), "b.go": []byte("package b\n")}
+	blockA, blockB := "```go\npackage a\n```\n", "```go\npackage b\n```\n"
+	trailer := func(block, args string) string {
+		return checksumTrailer(checksum([]byte(block)), fingerprint(&command{args: args}))
+	}
+	captioned := "<!-- embedmd block start -->\n*A*\n\n" + blockA + "<!-- embedmd block end -->\n"
+
+	tc := []struct {
+		name, in, out, err string
+		files              map[string][]byte
+		opts               []Option
+	}{
+		{name: "first generation",
+			files: files,
+			in:    "[embedmd]:# (a.go)\n\n[embedmd]:# (b.go)\n",
+			out:   "[embedmd]:# (a.go)\n" + blockA + trailer(blockA, "(a.go)") + "\n[embedmd]:# (b.go)\n" + blockB + trailer(blockB, "(b.go)")},
+		{name: "unchanged commands aren't run",
+			in:  "[embedmd]:# (a.go)\n" + blockA + trailer(blockA, "(a.go)") + "\n[embedmd]:# (b.go)\n" + blockB + trailer(blockB, "(b.go)"),
+			out: "[embedmd]:# (a.go)\n" + blockA + trailer(blockA, "(a.go)") + "\n[embedmd]:# (b.go)\n" + blockB + trailer(blockB, "(b.go)")},
+		{name: "changed command",
+			files: map[string][]byte{"a.go": files["a.go"]},
+			in:    "[embedmd]:# (a.go caption=A)\n" + blockA + trailer(blockA, "(a.go)") + "\n[embedmd]:# (b.go)\n" + blockB + trailer(blockB, "(b.go)"),
+			out:   "[embedmd]:# (a.go caption=A)\n" + captioned + trailer(captioned, "(a.go caption=A)") + "\n[embedmd]:# (b.go)\n" + blockB + trailer(blockB, "(b.go)")},
+		{name: "edited block",
+			files: files,
+			in:    "[embedmd]:# (a.go)\n```go\nedited\n```\n" + trailer(blockA, "(a.go)"),
+			out:   "[embedmd]:# (a.go)\n" + blockA + trailer(blockA, "(a.go)")},
+		{name: "without fingerprint",
+			files: files,
+			in:    "[embedmd]:# (a.go)\n```go\nold\n```\n" + checksumTrailer(checksum([]byte("```go\nold\n```\n")), ""),
+			out:   "[embedmd]:# (a.go)\n" + blockA + trailer(blockA, "(a.go)")},
+		{name: "fingerprints kept by full runs",
+			files: files,
+			opts:  []Option{WithChecksums()},
+			in:    "[embedmd]:# (a.go caption=A)\n" + blockA + trailer(blockA, "(a.go)") + "\n[embedmd]:# (b.go)\n" + blockB + checksumTrailer(checksum([]byte(blockB)), ""),
+			out:   "[embedmd]:# (a.go caption=A)\n" + captioned + trailer(captioned, "(a.go caption=A)") + "\n[embedmd]:# (b.go)\n" + blockB + checksumTrailer(checksum([]byte(blockB)), "")},
+		{name: "without checksums",
+			opts: []Option{WithIncremental()},
+			in:   "[embedmd]:# (a.go)\n",
+			err:  "incremental runs need checksums"},
+	}
+	for _, tt := range tc {
+		opts := tt.opts
+		if opts == nil {
+			opts = []Option{WithChecksums(), WithIncremental()}
+		}
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), append(opts, WithFetcher(mixedContentProvider{files: tt.files}))...)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%s\ngot\n%s", tt.name, tt.out, got)
+		}
+	}
+}
@@ -15,14 +15,151 @@ package embedmd
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
 type command struct {
 	path, lang string
 	start, end *string
 	useFence   bool
+	// line is the line number of the command in the markdown file, and args
+	// its argument list as written.
+	line int
+	args string
+	// lines is the range of lines embedded, set with a #L10-L42 suffix to
+	// the path instead of start and end.
+	lines *lineRange
+	// heading is the anchor of the heading of the markdown section embedded,
+	// set with a #anchor suffix to the path of a markdown file.
+	heading string
+
+	// caption is rendered in italics above the embedded content.
+	caption string
+	// license overrides the license policy for this command, it can be
+	// either "strip" or "keep".
+	license string
+	// tag is the name of the region of the source embedded, delimited by
+	// embedmd:begin and embedmd:end comments, if set.
+	tag string
+	// symbol is the name of the Go symbol embedded, if set, and symbolKind
+	// the kind of declaration it must be, if any.
+	symbol, symbolKind string
+	// maxAge is how long the block can go without being refreshed, if set.
+	maxAge time.Duration
+	// sha256 is the checksum the content fetched must match, if set.
+	sha256 string
+	// transforms are applied in order to the content embedded.
+	transforms []transformStep
+	// selector is the name of the Selector choosing the content embedded,
+	// given selectorArg, if set, and selectWith the Selector, resolved when
+	// embedding.
+	selector, selectorArg string
+	selectWith            Selector
+	// linenos numbers the lines of the code block from lineStart, or from
+	// the first line of the source embedded if 0, and highlights are the
+	// lines of the block highlighted.
+	linenos    bool
+	lineStart  int
+	highlights []lineRange
+	// elision holds the regular expressions matching the lines around those
+	// replaced with ellipsis, if set, and maxLines the number of lines the
+	// content is truncated to, followed by ellipsis, if not 0.
+	elision  [2]*regexp.Regexp
+	maxLines int
+	ellipsis string
+	// omissions are the regular expressions matching the lines left out of
+	// the content, if set.
+	omissions []*regexp.Regexp
+	// split is the number of lines of each of the code blocks the content is
+	// split in, if not 0.
+	split int
+	// fence is the fence of the code block, overriding WithFence, and info
+	// the attributes added to its info string, if set.
+	fence, info string
+	// group is the capture group of the start regular expression embedded,
+	// if not 0, and matchAll is set when every match is embedded.
+	group    int
+	matchAll bool
+
+	// attrs holds the attributes set explicitly in the command.
+	attrs map[string]string
+
+	// block is the content generated by a previous run, if any, and checksum
+	// the checksum recorded after it.
+	block    []byte
+	checksum string
+	// blockLine is the line of the document the block starts at, or would
+	// be added at.
+	blockLine int
+	// fingerprint is that of the command the block was generated by, if
+	// recorded after the checksum.
+	fingerprint string
+	// refreshed is the date the block was last refreshed, if recorded.
+	refreshed string
+	// trailers holds the comments following block, which are only read when
+	// the block is generated by embedmd.
+	trailers []string
+	// looseFence is set when block is a code block fenced differently from
+	// what embedmd generates.
+	looseFence bool
+	// include is the reference to the shared file holding the code block,
+	// if it's shared.
+	include string
+	// splice is set by the include directive, whose content is markdown
+	// spliced in the document rather than code.
+	splice bool
+	// editURL is the URL to edit the source embedded, if it's linked.
+	editURL string
+	// attribution is "link" when the block credits its source in a footer,
+	// and sourceLink the markdown link to the source in the footer.
+	attribution, sourceLink string
+	// inferredLang is set when lang is the extension of path, or empty when
+	// path has none and the language is detected from the content.
+	inferredLang bool
+	// sep is the line separating the regions stitched in the block, which
+	// is blank by default.
+	sep string
+	// frozen is set when block is kept as is, whatever the source.
+	frozen bool
+	// labels are the labels of the command, which select whether it's run.
+	labels []string
+	// goos lists the platforms the command embeds content on, all of them
+	// if nil.
+	goos []string
+	// dir is the directory relative paths are resolved from, relative to
+	// the base directory, set with the basedir attribute or by the last
+	// basedir directive before the command, which dirDirective marks.
+	dir          string
+	dirDirective bool
+	// varName and varValue are the variable set by a define directive,
+	// which varDirective marks, for the commands that follow it.
+	varName, varValue string
+	varDirective      bool
+	// indented is set when block is an indented code block.
+	indented bool
+	// stacked holds the commands on the lines following this one, and those
+	// stitched to them, whose content is added to its block.
+	stacked []*command
+	// parts holds the commands stitched after this one in its argument
+	// list, and stitched is set on them.
+	parts    []*command
+	stitched bool
+	// partArgs are the arguments of the part of the argument list of the
+	// command, when it has several.
+	partArgs string
+	// region holds the first and last lines of the source embedded, when
+	// stale blocks are reported, sources mapped, or lines numbered, and
+	// embeddedLines the number of lines embedded.
+	region        [2]int
+	embeddedLines int
 }
 
 func parseCommand(s string) (*command, error) {
@@ -35,20 +172,82 @@ func parseCommand(s string) (*command, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(args) > 0 && args[0] == "basedir" {
+		if len(args) != 2 {
+			return nil, errors.New("basedir takes a single directory")
+		}
+		return &command{dir: args[1], dirDirective: true}, nil
+	}
+	if len(args) > 0 && args[0] == "include" {
+		return parseInclude(args[1:])
+	}
+	if len(args) > 0 && args[0] == "define" {
+		return parseDefine(args[1:])
+	}
+	// Regions stitched in the same block are separated by +.
+	var cmd *command
+	for {
+		i := slices.Index(args, "+")
+		if i < 0 {
+			i = len(args)
+		}
+		part, err := parseArgs(args[:i])
+		if err != nil {
+			return nil, err
+		}
+		if cmd == nil {
+			cmd = part
+		} else {
+			part.stitched = true
+			cmd.parts = append(cmd.parts, part)
+		}
+		// The arguments of each part are rewritten on their own, as when
+		// pinned.
+		part.partArgs = strings.Join(args[:i], " ")
+		if i == len(args) {
+			if cmd.parts == nil {
+				cmd.partArgs = ""
+			}
+			return cmd, nil
+		}
+		args = args[i+1:]
+	}
+}
+
+// parseArgs parses the arguments of a command embedding a single region.
+func parseArgs(args []string) (*command, error) {
 	if len(args) == 0 {
 		return nil, errors.New("missing file name")
 	}
 
-	cmd := &command{path: args[0]}
-	args = args[1:]
-	if len(args) > 0 && args[0][0] != '/' {
+	var err error
+	cmd := &command{}
+	if cmd.path, cmd.lines, err = cutLineRange(args[0]); err != nil {
+		return nil, err
+	}
+	cmd.path, cmd.heading = cutHeading(cmd.path)
+	if cmd.path == "" {
+		return nil, errors.New("missing file name")
+	}
+	args, err = cmd.parseAttrs(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case cmd.lang != "" && len(args) > 0 && args[0][0] != '/':
+		return nil, errors.New("cannot set the language both as an argument and with lang")
+	case cmd.lang != "":
+	case len(args) > 0 && args[0][0] != '/':
 		cmd.lang, args = args[0], args[1:]
-	} else {
-		ext := filepath.Ext(cmd.path[1:])
-		if len(ext) == 0 {
-			return nil, errors.New("language is required when file has no extension")
-		}
-		cmd.lang = ext[1:]
+	case isExecPath(cmd.path):
+		// The output of programs is mostly plain text.
+		cmd.lang = "text"
+	default:
+		// The language is the extension, without a fragment such as #intro
+		// of page.html#intro. Without an extension, it's detected from the
+		// content once fetched.
+		ext, _, _ := strings.Cut(filepath.Ext(sourceName(cmd.path)[1:]), "#")
+		cmd.lang, cmd.inferredLang = strings.TrimPrefix(ext, "."), true
 	}
 
 	// When language is explicitly set to "none" we won't use fences, otherwise
@@ -63,32 +262,320 @@ func parseCommand(s string) (*command, error) {
 	case len(args) > 2:
 		return nil, errors.New("too many arguments")
 	}
+	if cmd.lines != nil && cmd.start != nil {
+		return nil, errors.New("cannot use both a line range and regular expressions")
+	}
+	if cmd.heading != "" && (cmd.start != nil || cmd.tag != "" || cmd.symbol != "" || cmd.selector != "") {
+		return nil, errors.New("cannot use a heading with a tag, a symbol, a selector, or regular expressions")
+	}
+	if cmd.tag != "" && (cmd.lines != nil || cmd.start != nil) {
+		return nil, errors.New("cannot use a tag with a line range or regular expressions")
+	}
+	if cmd.symbol != "" && (cmd.tag != "" || cmd.lines != nil || cmd.start != nil) {
+		return nil, errors.New("cannot use a symbol with a tag, a line range, or regular expressions")
+	}
+	if cmd.selector != "" && (cmd.symbol != "" || cmd.tag != "" || cmd.lines != nil || cmd.start != nil) {
+		return nil, errors.New("cannot use a selector with a symbol, a tag, a line range, or regular expressions")
+	}
+	if (cmd.group != 0 || cmd.matchAll) && (cmd.start == nil || *cmd.start == "") {
+		return nil, errors.New("cannot use group or match without a start regular expression")
+	}
+	if cmd.ellipsis != "" && cmd.maxLines == 0 && cmd.elision[0] == nil {
+		return nil, errors.New("cannot use ellipsis without maxlines or elide")
+	}
+	if cmd.lineStart != 0 && !cmd.linenos {
+		return nil, errors.New("cannot use start without linenos=true")
+	}
 
 	return cmd, nil
 }
 
+// ownArgs returns the arguments of cmd in its argument list, without those of
+// the commands stitched to it.
+func (cmd *command) ownArgs() string {
+	if cmd.partArgs != "" {
+		return cmd.partArgs
+	}
+	return cmd.args
+}
+
+// sourceName returns the name of the file at path p, without the revision of
+// git paths or the query of URLs.
+func sourceName(p string) string {
+	if file, _, ok := cutRevision(p); isGitPath(p) && ok && file != "" {
+		p = file
+	}
+	// The query of URLs, such as ?plain=1 on forge pages, isn't part of the
+	// name of the file.
+	if u, err := url.Parse(p); err == nil && isURL(p) {
+		p = u.Scheme + "://" + u.Host + u.Path
+	}
+	return p
+}
+
+// parseAttrs extracts the key=value attributes from the given arguments,
+// returning the remaining ones.
+func (cmd *command) parseAttrs(args []string) ([]string, error) {
+	var rest []string
+	for _, arg := range args {
+		key, val, ok := cutAttr(arg)
+		if !ok {
+			rest = append(rest, arg)
+			continue
+		}
+		if err := cmd.setAttr(key, val); err != nil {
+			return nil, err
+		}
+		if cmd.attrs == nil {
+			cmd.attrs = map[string]string{}
+		}
+		cmd.attrs[key] = val
+	}
+	return rest, nil
+}
+
+// cutAttr splits a key=value argument, unquoting the value if needed.
+func cutAttr(arg string) (key, val string, ok bool) {
+	if arg[0] == '/' {
+		return "", "", false
+	}
+	key, val, ok = strings.Cut(arg, "=")
+	if uq, err := strconv.Unquote(val); ok && err == nil {
+		val = uq
+	}
+	return key, val, ok
+}
+
+func (cmd *command) setAttr(key, val string) error {
+	switch key {
+	case "caption":
+		cmd.caption = val
+	case "license":
+		if val != "strip" && val != "keep" {
+			return fmt.Errorf("license should be strip or keep, got %q", val)
+		}
+		cmd.license = val
+	case "tag":
+		if !validTag.MatchString(val) {
+			return fmt.Errorf("tag should be made of letters, digits, '.', '-', and '_', got %q", val)
+		}
+		cmd.tag = val
+	case "symbol", "go:func", "go:type", "go:const", "go:var":
+		if !validSymbol.MatchString(val) {
+			return fmt.Errorf("%s should be a Go name, or Type.Method for methods, got %q", key, val)
+		}
+		cmd.symbol, cmd.symbolKind = val, symbolKinds[key]
+	case "maxage":
+		d, err := parseAge(val)
+		if err != nil {
+			return err
+		}
+		cmd.maxAge = d
+	case "sha256":
+		sum, err := parsePin(val)
+		if err != nil {
+			return err
+		}
+		cmd.sha256 = sum
+	case "select":
+		return parseSelect(cmd, val)
+	case "transform":
+		steps, err := parseTransforms(val)
+		if err != nil {
+			return err
+		}
+		cmd.transforms = steps
+	case "linenos":
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("linenos should be true or false, got %q", val)
+		}
+		cmd.linenos = b
+	case "lang":
+		if val == "" {
+			return fmt.Errorf("lang should be a language, got %q", val)
+		}
+		cmd.lang = val
+	case "sep":
+		if val == "" || strings.Contains(val, "\n") {
+			return fmt.Errorf("sep should be a single line of text, got %q", val)
+		}
+		cmd.sep = val
+	case "basedir":
+		if val == "" {
+			return fmt.Errorf("basedir should be a directory, got %q", val)
+		}
+		cmd.dir = val
+	case "freeze":
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("freeze should be true or false, got %q", val)
+		}
+		cmd.frozen = b
+	case "label":
+		labels, err := parseLabels(val)
+		if err != nil {
+			return err
+		}
+		cmd.labels = labels
+	case "goos":
+		goos, err := parseGOOS(val)
+		if err != nil {
+			return err
+		}
+		cmd.goos = goos
+	case "start":
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 1 {
+			return fmt.Errorf("start should be a line number, got %q", val)
+		}
+		cmd.lineStart = n
+	case "maxlines":
+		n, err := parseMaxLines(val)
+		if err != nil {
+			return err
+		}
+		cmd.maxLines = n
+	case "elide":
+		res, err := parseElision(val)
+		if err != nil {
+			return err
+		}
+		cmd.elision = res
+	case "omit":
+		res, err := parseOmissions(val)
+		if err != nil {
+			return err
+		}
+		cmd.omissions = res
+	case "ellipsis":
+		if val == "" || strings.Contains(val, "\n") {
+			return fmt.Errorf("ellipsis should be a single line of text, got %q", val)
+		}
+		cmd.ellipsis = val
+	case "fence":
+		if !validFence(val) {
+			return fmt.Errorf("fence should be at least three backticks or tildes, or mdx, got %q", val)
+		}
+		cmd.fence = val
+	case "info":
+		cmd.info = val
+	case "attribution":
+		if val != "link" && val != "none" {
+			return fmt.Errorf("attribution should be link or none, got %q", val)
+		}
+		cmd.attribution = val
+	case "split":
+		n, err := parseSplit(val)
+		if err != nil {
+			return err
+		}
+		cmd.split = n
+	case "group":
+		n, err := parseGroup(val)
+		if err != nil {
+			return err
+		}
+		cmd.group = n
+	case "match":
+		if val != "first" && val != "all" {
+			return fmt.Errorf("match should be first or all, got %q", val)
+		}
+		cmd.matchAll = val == "all"
+	case "hl":
+		hl, err := parseHighlights(val)
+		if err != nil {
+			return err
+		}
+		cmd.highlights = hl
+	default:
+		return fmt.Errorf("unknown attribute %q", key)
+	}
+	return nil
+}
+
 // fields returns a list of the groups of text separated by blanks,
-// keeping all text surrounded by / as a group.
+// keeping all text surrounded by / or double quotes as a group.
 func fields(s string) ([]string, error) {
+	args, _, err := fieldsAt(s)
+	return args, err
+}
+
+// fieldsAt works as fields, also returning the offsets of the groups in s.
+// Its errors are offsetErrors, at the offset of the group at fault.
+func fieldsAt(s string) ([]string, []int, error) {
 	var args []string
+	var offsets []int
 
-	for s = strings.TrimSpace(s); len(s) > 0; s = strings.TrimSpace(s) {
-		if s[0] == '/' {
-			sep := nextSlash(s[1:])
+	for rest := strings.TrimLeftFunc(s, unicode.IsSpace); len(rest) > 0; rest = strings.TrimLeftFunc(rest, unicode.IsSpace) {
+		offset, n := len(s)-len(rest), 0
+		if rest[0] == '/' {
+			sep := nextSlash(rest[1:])
 			if sep < 0 {
-				return nil, errors.New("unbalanced /")
+				return nil, nil, &offsetError{offset, errors.New("unbalanced /")}
 			}
-			args, s = append(args, s[:sep+2]), s[sep+2:]
+			n = sep + 2
 		} else {
-			sep := strings.IndexByte(s[1:], ' ')
-			if sep < 0 {
-				return append(args, s), nil
+			sep, err := nextBlank(rest)
+			if err != nil {
+				return nil, nil, &offsetError{offset, err}
+			}
+			n = sep
+		}
+		args, offsets, rest = append(args, rest[:n]), append(offsets, offset), rest[n:]
+	}
+
+	return args, offsets, nil
+}
+
+// offsetError is an error found at an offset of the text parsed.
+type offsetError struct {
+	offset int
+	err    error
+}
+
+func (e *offsetError) Error() string { return e.err.Error() }
+func (e *offsetError) Unwrap() error { return e.err }
+
+// nextBlank returns the index of the first blank in s that is not inside
+// a double quoted string or {{ }}, or len(s) if there is none.
+func nextBlank(s string) (int, error) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case ' ', '\t':
+			return i, nil
+		case '"':
+			end := nextQuote(s[i+1:])
+			if end < 0 {
+				return 0, errors.New("unbalanced \"")
+			}
+			i += end + 1
+		case '{':
+			// References to front matter variables, such as
+			// {{ .code_base }}, have blanks.
+			if strings.HasPrefix(s[i:], "{{") {
+				end := strings.Index(s[i:], "}}")
+				if end < 0 {
+					return 0, errors.New("unbalanced {{")
+				}
+				i += end + 1
 			}
-			args, s = append(args, s[:sep+1]), s[sep+1:]
 		}
 	}
+	return len(s), nil
+}
 
-	return args, nil
+// nextQuote returns the index of the next unescaped double quote in s.
+func nextQuote(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
 }
 
 // nextSlash will find the index of the next unescaped slash in a string.
@@ -104,3 +591,17 @@ func nextSlash(s string) int {
 		}
 	}
 }
+
+// readTrailers sets the checksum and the refresh date recorded in the
+// trailers of the block.
+func (cmd *command) readTrailers() {
+	for _, line := range cmd.trailers {
+		switch {
+		case strings.HasPrefix(line, checksumPrefix):
+			trailer := strings.TrimSuffix(strings.TrimPrefix(line, checksumPrefix), " -->")
+			cmd.checksum, cmd.fingerprint, _ = strings.Cut(trailer, " ")
+		case strings.HasPrefix(line, refreshedPrefix):
+			cmd.refreshed = refreshedDate(line)
+		}
+	}
+}
@@ -13,7 +13,10 @@
 
 package embedmd
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 func TestParseCommand(t *testing.T) {
 	tc := []struct {
@@ -31,9 +34,15 @@ func TestParseCommand(t *testing.T) {
 		{name: "empty list",
 			in:  "()",
 			err: "missing file name"},
+		{name: "line range without file name",
+			in:  "(#L3)",
+			err: "missing file name"},
+		{name: "stacked line range without file name",
+			in:  "(m.go#L1 + #L3)",
+			err: "missing file name"},
 		{name: "file with no extension and no lang",
 			in:  "(test)",
-			err: "language is required when file has no extension"},
+			cmd: command{path: "test"}},
 		{name: "surrounding blanks",
 			in:  "   \t  (code.go)  \t  ",
 			cmd: command{path: "code.go", lang: "go"}},
@@ -72,6 +81,72 @@ func TestParseCommand(t *testing.T) {
 		{name: "file language none (no fencing)",
 			in:  "(test.md none)",
 			cmd: command{path: "test.md", lang: "none"}},
+		{name: "attributes",
+			in:  `(code.go /start/ $ caption="Hello, world" license=strip)`,
+			cmd: command{path: "code.go", lang: "go", start: ptr("/start/"), end: ptr("$"), caption: "Hello, world", license: "strip"}},
+		{name: "attribute after language",
+			in:  `(code.txt go caption=Hello)`,
+			cmd: command{path: "code.txt", lang: "go", caption: "Hello"}},
+		{name: "unknown attribute",
+			in:  "(code.go color=red)",
+			err: `unknown attribute "color"`},
+		{name: "bad license attribute",
+			in:  "(code.go license=drop)",
+			err: `license should be strip or keep, got "drop"`},
+		{name: "line range",
+			in:  "(code.go#L10-L42)",
+			cmd: command{path: "code.go", lang: "go", lines: &lineRange{10, 42}}},
+		{name: "single line of a url",
+			in:  "(https://github.com/o/r/blob/main/code.go#L7 caption=Hi)",
+			cmd: command{path: "https://github.com/o/r/blob/main/code.go", lang: "go", lines: &lineRange{7, 7}, caption: "Hi"}},
+		{name: "selection of a markdown page",
+			in:  "(https://github.com/o/r/blob/main/README.md?plain=1#L3C5-L9C1)",
+			cmd: command{path: "https://github.com/o/r/blob/main/README.md?plain=1", lang: "md", lines: &lineRange{3, 9}}},
+		{name: "line range and regexps",
+			in:  "(code.go#L1-L2 /start/)",
+			err: "cannot use both a line range and regular expressions"},
+		{name: "line range from zero",
+			in:  "(code.go#L0-L2)",
+			err: "bad line range L0-L2, lines are numbered from 1"},
+		{name: "backwards line range",
+			in:  "(code.go#L5-L2)",
+			err: "bad line range L5-L2, it ends before it starts"},
+		{name: "tag",
+			in:  "(main.go tag=config)",
+			cmd: command{path: "main.go", lang: "go", tag: "config"}},
+		{name: "bad tag",
+			in:  `(main.go tag="my config")`,
+			err: `tag should be made of letters, digits, '.', '-', and '_', got "my config"`},
+		{name: "tag and regexps",
+			in:  "(main.go /start/ tag=config)",
+			err: "cannot use a tag with a line range or regular expressions"},
+		{name: "heading",
+			in:  "(../README.md#installation md)",
+			cmd: command{path: "../README.md", lang: "md", heading: "installation"}},
+		{name: "heading of a URL",
+			in:  "(https://example.com/docs/guide.markdown#set-up)",
+			cmd: command{path: "https://example.com/docs/guide.markdown", lang: "markdown", heading: "set-up"}},
+		{name: "fragment of a program",
+			in:  "(page.html#intro)",
+			cmd: command{path: "page.html#intro", lang: "html"}},
+		{name: "heading and regexps",
+			in:  "(README.md#usage /start/)",
+			err: "cannot use a heading with a tag, a symbol, a selector, or regular expressions"},
+		{name: "go func",
+			in:  "(server.go go:func=HandleRequest)",
+			cmd: command{path: "server.go", lang: "go", symbol: "HandleRequest", symbolKind: "func"}},
+		{name: "symbol",
+			in:  "(server.go symbol=Server.Name)",
+			cmd: command{path: "server.go", lang: "go", symbol: "Server.Name"}},
+		{name: "bad symbol",
+			in:  "(server.go go:type=*Server)",
+			err: `go:type should be a Go name, or Type.Method for methods, got "*Server"`},
+		{name: "symbol and regexps",
+			in:  "(server.go /start/ symbol=Server)",
+			err: "cannot use a symbol with a tag, a line range, or regular expressions"},
+		{name: "unbalanced quote",
+			in:  `(code.go caption="Hello)`,
+			err: `unbalanced "`},
 	}
 
 	for _, tt := range tc {
@@ -94,6 +169,21 @@ func TestParseCommand(t *testing.T) {
 			if !eqPtr(want.end, got.end) {
 				t.Errorf("case [%s]: expected end %v; got %v", tt.name, str(want.end), str(got.end))
 			}
+			if want.caption != got.caption {
+				t.Errorf("case [%s]: expected caption %q; got %q", tt.name, want.caption, got.caption)
+			}
+			if fmt.Sprint(want.lines) != fmt.Sprint(got.lines) {
+				t.Errorf("case [%s]: expected lines %v; got %v", tt.name, want.lines, got.lines)
+			}
+			if want.symbol != got.symbol || want.symbolKind != got.symbolKind {
+				t.Errorf("case [%s]: expected symbol %s %q; got %s %q", tt.name, want.symbolKind, want.symbol, got.symbolKind, got.symbol)
+			}
+			if want.tag != got.tag {
+				t.Errorf("case [%s]: expected tag %q; got %q", tt.name, want.tag, got.tag)
+			}
+			if want.license != got.license {
+				t.Errorf("case [%s]: expected license %q; got %q", tt.name, want.license, got.license)
+			}
 		})
 	}
 }
@@ -0,0 +1,229 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// A Conflict is a block edited by hand, as told by its checksum, whose
+// source changed too.
+type Conflict struct {
+	// Line is the line of the command, and Path the path or URL of its
+	// source.
+	Line int
+	Path string
+	// Base is the block as it was generated before being edited, or nil if
+	// it can't be found. Edited is the block as edited, and Generated the
+	// block generated from the source as it is now.
+	Base, Edited, Generated []byte
+}
+
+// A ConflictResolver returns the block written for a Conflict, such as the
+// edited block, the generated one, or their merge with Merge3.
+type ConflictResolver func(Conflict) ([]byte, error)
+
+// WithConflictResolver keeps the blocks edited by hand, as told by the
+// checksums recorded with WithChecksums, instead of generating them again:
+// those whose source is unchanged are kept as is, and those whose source
+// changed too are replaced by the block r returns for them. The checksum of
+// the generated block is recorded, so the block stays edited by hand.
+func WithConflictResolver(r ConflictResolver) Option {
+	return Option{func(e *embedder) { e.conflictResolver = r }}
+}
+
+// WithManagedBlocks wraps every generated block between embedmd block start
+// and end comments, followed by its checksum as with WithChecksums, and keeps
+// the edits made by hand to the blocks instead of generating them again. The
+// edits of the blocks whose source changed too are merged with the changes of
+// the source by Merge3, failing if both changed the same lines, unless
+// WithConflictResolver sets another way to resolve them.
+func WithManagedBlocks() Option {
+	return Option{func(e *embedder) { e.managed, e.checksums = true, true }}
+}
+
+// mergeEdits is the conflict resolver of managed blocks: it merges the edit
+// with the changes of the source, failing with the merge, conflict markers
+// included, if they change the same lines.
+func mergeEdits(c Conflict) ([]byte, error) {
+	merged, conflicts := Merge3(c.Base, c.Edited, c.Generated)
+	switch {
+	case !conflicts:
+		return merged, nil
+	case c.Base == nil:
+		return nil, fmt.Errorf("block was edited by hand and %s changed since a revision that can't be found to merge them:\n%s", c.Path, merged)
+	default:
+		return nil, fmt.Errorf("block was edited by hand and %s changed in the same lines:\n%s", c.Path, merged)
+	}
+}
+
+// editedByHand reports whether the block of cmd was edited since it was
+// generated, according to its checksum.
+func editedByHand(cmd *command) bool {
+	return cmd.block != nil && cmd.checksum != "" && checksum(cmd.block) != cmd.checksum
+}
+
+// resolveConflict writes the block edited by hand of cmd, or the one the
+// conflict resolver returns if the block generated now differs from the one
+// that was edited.
+func (e *embedder) resolveConflict(w io.Writer, cmd *command, generated []byte) error {
+	if checksum(generated) == cmd.checksum {
+		return keepBlock(w, cmd)
+	}
+	resolve := e.conflictResolver
+	if resolve == nil {
+		resolve = mergeEdits
+	}
+	b, err := resolve(Conflict{
+		Line:      cmd.line,
+		Path:      cmd.path,
+		Base:      e.baseBlock(cmd),
+		Edited:    cmd.block,
+		Generated: generated,
+	})
+	if err != nil {
+		return err
+	}
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		b = append(b, '\n')
+	}
+	resolved := *cmd
+	resolved.block, resolved.checksum = b, checksum(generated)
+	return keepBlock(w, &resolved)
+}
+
+// maxBaseRevisions is how many revisions of a source are searched for the
+// one a block edited by hand was generated from.
+const maxBaseRevisions = 50
+
+// baseBlock returns the block generated for cmd from the latest revision of
+// its source in the git history whose checksum is the recorded one, or nil if
+// there's none.
+func (e *embedder) baseBlock(cmd *command) []byte {
+	if len(cmd.stacked) > 0 || atRevision(cmd.path, "HEAD") == cmd.path {
+		return nil
+	}
+	file := filepath.FromSlash(cmd.path)
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(e.baseDir, file)
+	}
+	out, err := runGit(e.context(), filepath.Dir(file), "log", "--format=%H", "-n", strconv.Itoa(maxBaseRevisions), "--", filepath.Base(file))
+	if err != nil {
+		return nil
+	}
+	// Past revisions are embedded silently.
+	warnings := e.warnings
+	e.warnings = nil
+	defer func() { e.warnings = warnings }()
+	for _, rev := range strings.Fields(string(out)) {
+		c := *cmd
+		c.path, c.sha256 = atRevision(cmd.path, rev), ""
+		b, err := e.embedded(&c, &c)
+		if err != nil {
+			continue
+		}
+		var buf bytes.Buffer
+		if e.render(&buf, &c, b) != nil {
+			continue
+		}
+		if checksum(buf.Bytes()) == cmd.checksum {
+			return buf.Bytes()
+		}
+	}
+	return nil
+}
+
+// Merge3 merges the changes made to base in a and in b, line by line. Lines
+// changed differently in both are kept from both, between conflict markers,
+// and reported by the second result.
+func Merge3(base, a, b []byte) ([]byte, bool) {
+	bl, al, ol := splitLines(base), splitLines(a), splitLines(b)
+	inA, inB := matches(bl, al), matches(bl, ol)
+
+	var out bytes.Buffer
+	conflicts := false
+	i, j, k := 0, 0, 0
+	for {
+		// The next base line kept in both, or the end of all of them.
+		n := i
+		for n < len(bl) && (inA[n] < 0 || inB[n] < 0) {
+			n++
+		}
+		ja, kb := len(al), len(ol)
+		if n < len(bl) {
+			ja, kb = inA[n], inB[n]
+		}
+		baseHunk, aHunk, bHunk := bl[i:n], al[j:ja], ol[k:kb]
+		switch {
+		case slices.Equal(aHunk, baseHunk):
+			writeLines(&out, bHunk)
+		case slices.Equal(bHunk, baseHunk), slices.Equal(aHunk, bHunk):
+			writeLines(&out, aHunk)
+		default:
+			conflicts = true
+			out.WriteString("<<<<<<< edited\n")
+			writeLines(&out, aHunk)
+			out.WriteString("=======\n")
+			writeLines(&out, bHunk)
+			out.WriteString(">>>>>>> source\n")
+		}
+		if n == len(bl) {
+			return out.Bytes(), conflicts
+		}
+		writeLines(&out, bl[n:n+1])
+		i, j, k = n+1, ja+1, kb+1
+	}
+}
+
+// splitLines returns the lines of b, with their newline.
+func splitLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// matches returns, for each line of base, the index of the same line in
+// other if it was kept, or -1.
+func matches(base, other []string) []int {
+	m := make([]int, len(base))
+	for i := range m {
+		m[i] = -1
+	}
+	for _, b := range difflib.NewMatcher(base, other).GetMatchingBlocks() {
+		for n := 0; n < b.Size; n++ {
+			m[b.A+n] = b.B + n
+		}
+	}
+	return m
+}
+
+// writeLines writes the given lines, each ending with a newline.
+func writeLines(w *bytes.Buffer, lines []string) {
+	for _, l := range lines {
+		w.WriteString(l)
+		if !strings.HasSuffix(l, "\n") {
+			w.WriteByte('\n')
+		}
+	}
+}
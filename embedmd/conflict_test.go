@@ -0,0 +1,182 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	tc := []struct {
+		name, base, a, b, out string
+		conflicts             bool
+	}{
+		{name: "no changes", base: "1\n2\n3\n", a: "1\n2\n3\n", b: "1\n2\n3\n", out: "1\n2\n3\n"},
+		{name: "changed in a", base: "1\n2\n3\n", a: "1\ntwo\n3\n", b: "1\n2\n3\n", out: "1\ntwo\n3\n"},
+		{name: "changed in b", base: "1\n2\n3\n", a: "1\n2\n3\n", b: "1\n2\nthree\n", out: "1\n2\nthree\n"},
+		{name: "changed in both", base: "1\n2\n3\n4\n5\n", a: "one\n2\n3\n4\n5\n", b: "1\n2\n3\n4\nfive\nsix\n", out: "one\n2\n3\n4\nfive\nsix\n"},
+		{name: "same change", base: "1\n2\n3\n", a: "1\ntwo\n3\n", b: "1\ntwo\n3\n", out: "1\ntwo\n3\n"},
+		{name: "conflict", base: "1\n2\n3\n", a: "1\ntwo\n3\n", b: "1\nTWO\n3\n",
+			out: "1\n<<<<<<< edited\ntwo\n=======\nTWO\n>>>>>>> source\n3\n", conflicts: true},
+		{name: "deleted in a", base: "1\n2\n3\n", a: "1\n3\n", b: "1\n2\n3\n4\n", out: "1\n3\n4\n"},
+		{name: "no final newline", base: "1\n2", a: "0\n1\n2", b: "1\n2", out: "0\n1\n2\n"},
+	}
+	for _, tt := range tc {
+		out, conflicts := Merge3([]byte(tt.base), []byte(tt.a), []byte(tt.b))
+		if string(out) != tt.out || conflicts != tt.conflicts {
+			t.Errorf("case [%s]: expected %q with conflicts %v; got %q with conflicts %v", tt.name, tt.out, tt.conflicts, out, conflicts)
+		}
+	}
+}
+
+func TestConflictResolver(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "code.go"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	process := func(in string, r ConflictResolver) string {
+		t.Helper()
+		var out bytes.Buffer
+		if err := Process(&out, strings.NewReader(in), WithBaseDir(dir), WithChecksums(), WithConflictResolver(r)); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+	git("init", "-q")
+	write("a\nb\nc\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "v1")
+
+	var got []Conflict
+	resolve := func(c Conflict) ([]byte, error) {
+		got = append(got, c)
+		merged, _ := Merge3(c.Base, c.Edited, c.Generated)
+		return merged, nil
+	}
+	doc := process("[embedmd]:# (code.go)\n", resolve)
+	edited := strings.Replace(doc, "a\n", "A\n", 1)
+
+	// Without source changes, the edit is kept.
+	if out := process(edited, resolve); out != edited || len(got) > 0 {
+		t.Errorf("expected edited block to be kept\n%q\ngot\n%q", edited, out)
+	}
+
+	write("a\nb\nc\nd\n")
+	out := process(edited, resolve)
+	if len(got) != 1 {
+		t.Fatalf("expected one conflict, got %d", len(got))
+	}
+	c := got[0]
+	if c.Line != 1 || c.Path != "code.go" || string(c.Base) != "```go\na\nb\nc\n```\n" ||
+		string(c.Edited) != "```go\nA\nb\nc\n```\n" || string(c.Generated) != "```go\na\nb\nc\nd\n```\n" {
+		t.Errorf("unexpected conflict %+v", c)
+	}
+	if want := "[embedmd]:# (code.go)\n```go\nA\nb\nc\nd\n```\n" + checksumPrefix + checksum(c.Generated) + " -->\n"; out != want {
+		t.Errorf("expected merged block\n%q\ngot\n%q", want, out)
+	}
+
+	// The merged block is kept as edited by hand from then on.
+	got = nil
+	if again := process(out, resolve); again != out || len(got) > 0 {
+		t.Errorf("expected merged block to be kept\n%q\ngot\n%q", out, again)
+	}
+}
+
+func TestManagedBlocks(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "code.go"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	process := func(in string) (string, error) {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(in), WithBaseDir(dir), WithManagedBlocks())
+		return out.String(), err
+	}
+	git("init", "-q")
+	write("a\nb\nc\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "v1")
+
+	doc, err := process("[embedmd]:# (code.go)\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := "<!-- embedmd block start -->\n```go\na\nb\nc\n```\n<!-- embedmd block end -->\n"
+	if want := "[embedmd]:# (code.go)\n" + block + checksumPrefix + checksum([]byte(block)) + " -->\n"; doc != want {
+		t.Fatalf("expected managed block\n%q\ngot\n%q", want, doc)
+	}
+	edited := strings.Replace(doc, "a\n", "A\n", 1)
+	if out, err := process(edited); err != nil || out != edited {
+		t.Errorf("expected edited block to be kept\n%q\ngot\n%q, %v", edited, out, err)
+	}
+
+	write("a\nb\nc\nd\n")
+	out, err := process(edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[embedmd]:# (code.go)\n<!-- embedmd block start -->\n```go\nA\nb\nc\nd\n```\n<!-- embedmd block end -->\n"; !strings.HasPrefix(out, want) {
+		t.Errorf("expected merged block\n%q\ngot\n%q", want, out)
+	}
+
+	git("commit", "-q", "-am", "v2")
+	write("alpha\nb\nc\nd\n")
+	_, err = process(out)
+	want := "1: block was edited by hand and code.go changed in the same lines:\n<!-- embedmd block start -->\n```go\n" +
+		"<<<<<<< edited\nA\n=======\nalpha\n>>>>>>> source\nb\nc\nd\n```\n<!-- embedmd block end -->\n"
+	eqErr(t, "conflict", err, want)
+
+	// Blocks can't be merged when the revision they were generated from isn't
+	// in the git history.
+	write("x\ny\n")
+	if doc, err = process("[embedmd]:# (code.go)\n"); err != nil {
+		t.Fatal(err)
+	}
+	write("x\ny\nz\n")
+	_, err = process(strings.Replace(doc, "x\n", "X\n", 1))
+	if err == nil || !strings.HasPrefix(err.Error(), "1: block was edited by hand and code.go changed since a revision that can't be found to merge them:\n") {
+		t.Errorf("expected conflict without base; got %v", err)
+	}
+}
@@ -14,12 +14,18 @@
 package embedmd
 
 import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // Fetcher provides an abstraction on a file system.
@@ -32,46 +38,195 @@ type Fetcher interface {
 	Fetch(dir, path string) ([]byte, error)
 }
 
+// A FetcherContext is a Fetcher that can also fetch with a context, so
+// fetches can be canceled, have deadlines, or carry tracing information.
+// When processing with WithContext, the content is fetched with
+// FetchContext if the Fetcher implements it. The Fetchers returned by
+// NewFetcher and the middleware of this package do.
+type FetcherContext interface {
+	Fetcher
+	FetchContext(ctx context.Context, dir, path string) ([]byte, error)
+}
+
 // fetcher implements the Fetcher interface with an injectable HTTP client.
 type fetcher struct {
 	client *http.Client
+	// authorize, if set, is called to add credentials to every request.
+	authorize func(*http.Request) error
+	// charset, if set, overrides the charset declared for remote content.
+	charset string
+	// cache, if set, caches remote content.
+	cache *HTTPCache
+	// credentials provides the credentials of each host, EnvCredentials by
+	// default.
+	credentials CredentialProvider
+	// retries is the number of times failed requests are retried, after
+	// backoff the first time.
+	retries int
+	backoff time.Duration
+	// timeout, if set, limits the time of each request.
+	timeout time.Duration
+	// limiter, if set, limits the rate of the requests to each host.
+	limiter *hostLimiter
+	// maxSize, if set, is the maximum size of remote content in bytes.
+	maxSize int64
+	// contentTypes, if set, are the media types accepted for remote content.
+	contentTypes []string
 }
 
 // NewFetcher creates a new fetcher with the provided HTTP client.
 // If no client is provided, it defaults to http.DefaultClient.
-func NewFetcher(client *http.Client) Fetcher {
+// Remote content is decoded to UTF-8 from the charset declared by the server.
+func NewFetcher(client *http.Client, opts ...FetcherOption) Fetcher {
 	if client == nil {
 		client = http.DefaultClient
 	}
-	return &fetcher{client: client}
+	f := &fetcher{client: client}
+	for _, opt := range opts {
+		opt.f(f)
+	}
+	if f.credentials == nil {
+		f.credentials = EnvCredentials()
+	}
+	c := *f.client
+	c.CheckRedirect = redirectCredentials(f.credentials, c.CheckRedirect)
+	if f.timeout != 0 {
+		c.Timeout = f.timeout
+	}
+	f.client = &c
+	return f
 }
 
 // Fetch fetches the content of a file or URL.
 func (f *fetcher) Fetch(dir, path string) ([]byte, error) {
-	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+	return f.FetchContext(context.Background(), dir, path)
+}
+
+// FetchContext fetches the content of a file or URL, stopping with the error
+// of ctx once it's done.
+func (f *fetcher) FetchContext(ctx context.Context, dir, path string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if isRepoPath(path) {
+		return nil, fmt.Errorf("no repository configured for %s", path)
+	}
+	if isGitPath(path) {
+		return fetchGit(ctx, dir, path)
+	}
+	if !isURL(path) {
+		archive, file, inArchive := cutArchive(path)
+		if inArchive {
+			path = archive
+		}
 		// Check that path is not absolute
 		if !filepath.IsAbs(path) {
 			path = filepath.Join(dir, filepath.FromSlash(path))
 		}
-		return os.ReadFile(path)
+		b, err := os.ReadFile(path)
+		if err != nil || !inArchive {
+			return b, err
+		}
+		return readArchived(b, archive, file)
 	}
 
-	req, err := http.NewRequest("GET", path, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if val, ok := os.LookupEnv("GITHUB_TOKEN"); ok {
-		req.Header.Add("Authorization", "Bearer "+val)
+	authorizeHost(f.credentials, req)
+	// Compressed responses are decoded below, as the transport only decodes
+	// gzip when it adds this header itself.
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	if f.authorize != nil {
+		if err := f.authorize(req); err != nil {
+			return nil, fmt.Errorf("could not authorize request: %v", err)
+		}
+	}
+
+	var cached *cacheEntry
+	if f.cache != nil {
+		var fresh bool
+		cached, fresh = f.cache.lookup(path)
+		if fresh {
+			return cached.Content, nil
+		}
+		if cached != nil {
+			cached.validate(req)
+		}
 	}
 
-	res, err := f.client.Do(req)
+	res, err := f.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
+	if cached != nil && res.StatusCode == http.StatusNotModified {
+		f.cache.revalidated(path)
+		return cached.Content, nil
+	}
 	if res.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("status %s", res.Status)
 	}
-	return io.ReadAll(res.Body)
+	if err := f.checkResponse(res); err != nil {
+		return nil, err
+	}
+	b, err := readLimited(res.Body, f.maxSize)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = decode(b, res.Header.Get("Content-Encoding"), f.maxSize); err != nil {
+		return nil, err
+	}
+	charset := f.charset
+	if charset == "" {
+		charset = charsetOf(res.Header.Get("Content-Type"), b)
+	}
+	if b, err = toUTF8(b, charset); err != nil || f.cache == nil {
+		return b, err
+	}
+	if err := f.cache.add(path, res.Header, b); err != nil {
+		return nil, fmt.Errorf("could not cache content: %v", err)
+	}
+	return b, nil
+}
+
+// decode returns b decoded from the given content encoding, failing if it
+// decodes to more than limit bytes, unless limit is 0.
+func decode(b []byte, encoding string, limit int64) ([]byte, error) {
+	var r io.Reader
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return b, nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("bad gzip content: %v", err)
+		}
+		r = zr
+	case "deflate":
+		// Deflate content should be wrapped in zlib, but some servers send
+		// raw deflate.
+		zr, err := zlib.NewReader(bytes.NewReader(b))
+		if err != nil {
+			r = flate.NewReader(bytes.NewReader(b))
+		} else {
+			r = zr
+		}
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+	out, err := readLimited(r, limit)
+	if _, ok := err.(errTooLarge); ok {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s content: %v", encoding, err)
+	}
+	return out, nil
+}
+
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
 }
@@ -2,6 +2,8 @@ package embedmd
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -254,19 +256,32 @@ func TestFetcher_URLs(t *testing.T) {
 	}
 }
 
-// TestFetcher_AuthHeader tests that the Authorization header is correctly set when GITHUB_TOKEN is present.
+// TestFetcher_Context tests that fetches stop when their context is done,
+// without waiting for the retries.
+func TestFetcher_Context(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	f := NewFetcher(nil, WithRetries(3, time.Hour)).(FetcherContext)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := f.FetchContext(ctx, "", ts.URL); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v; got %v", context.DeadlineExceeded, err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("expected the fetch to stop at the deadline; took %v", d)
+	}
+}
+
+// TestFetcher_AuthHeader tests that the Authorization header is set with the
+// credential of the host, and that GITHUB_TOKEN isn't sent to other hosts.
 func TestFetcher_AuthHeader(t *testing.T) {
 	expectedContent := "Authorized Content"
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		token, exists := os.LookupEnv("GITHUB_TOKEN")
-		if exists {
-			expectedAuth := fmt.Sprintf("Bearer %s", token)
-			if authHeader != expectedAuth {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-		} else if authHeader != "" {
+		if r.Header.Get("Authorization") != "Bearer testtoken" {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
@@ -276,12 +291,14 @@ func TestFetcher_AuthHeader(t *testing.T) {
 	defer server.Close()
 
 	// Set the GITHUB_TOKEN environment variable
-	os.Setenv("GITHUB_TOKEN", "testtoken")
-	defer os.Unsetenv("GITHUB_TOKEN")
+	t.Setenv("GITHUB_TOKEN", "testtoken")
 
-	// Initialize the fetcher with default client
-	f := NewFetcher(nil)
+	// The token of GitHub isn't sent to other hosts.
+	if _, err := NewFetcher(nil).Fetch("", server.URL); err == nil {
+		t.Errorf("Expected GITHUB_TOKEN not to be sent to %s", server.URL)
+	}
 
+	f := NewFetcher(nil, WithCredentials(HostCredentials{"127.0.0.1": BearerToken("testtoken")}))
 	data, err := f.Fetch("", server.URL)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
@@ -0,0 +1,64 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// WithStrictContentType fails the commands embedding a URL that returns an
+// HTML page, when the command doesn't embed HTML or markdown, rather than
+// only reporting a warning. This is usually the URL of the page showing a
+// file on a code forge instead of the URL of its raw content.
+func WithStrictContentType() Option {
+	return Option{func(e *embedder) { e.strictContentType = true }}
+}
+
+// htmlLangs are the languages of the commands expected to embed HTML.
+var htmlLangs = map[string]bool{
+	"html": true, "htm": true, "xhtml": true, "xml": true, "svg": true,
+	"markdown": true, "md": true, "vue": true,
+}
+
+// checkContentType reports URLs returning an HTML page when the command
+// doesn't expect one.
+func (e *embedder) checkContentType(cmd *command, b []byte) error {
+	if !isURL(cmd.path) || !cmd.useFence || htmlLangs[strings.ToLower(cmd.lang)] || !isHTMLPage(b) {
+		return nil
+	}
+	if e.strictContentType {
+		return fmt.Errorf("%s returned an HTML page instead of %s code", cmd.path, cmd.lang)
+	}
+	e.warnf(cmd, "%s returned an HTML page instead of %s code, use the URL of the raw file", cmd.path, cmd.lang)
+	return nil
+}
+
+// isHTMLPage reports whether b is a complete HTML document, rather than a
+// fragment such as a comment.
+func isHTMLPage(b []byte) bool {
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+	b = bytes.TrimLeft(b, " \t\r\n")
+	if len(b) > 16 {
+		b = b[:16]
+	}
+	s := strings.ToLower(string(b))
+	for _, prefix := range []string{"<!doctype html", "<html", "<head", "<body"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
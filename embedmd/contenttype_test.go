@@ -0,0 +1,192 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestContentType(t *testing.T) {
+	const page = "<!DOCTYPE html>\n<html><body>code.go</body></html>\n"
+	const url = "https://git.example.com/owner/repo/blob/main/code.go"
+
+	tc := []struct {
+		name     string
+		in       string
+		strict   bool
+		out      string
+		warnings []string
+		err      string
+	}{
+		{
+			name:     "HTML page instead of code",
+			in:       "[embedmd]:# (" + url + ")\n",
+			out:      "[embedmd]:# (" + url + ")\n```go\n" + page + "```\n",
+			warnings: []string{"1: " + url + " returned an HTML page instead of go code, use the URL of the raw file"},
+		},
+		{
+			name:   "HTML page instead of code in strict mode",
+			in:     "[embedmd]:# (" + url + ")\n",
+			strict: true,
+			err:    "1: " + url + " returned an HTML page instead of go code",
+		},
+		{
+			name: "HTML expected",
+			in:   "[embedmd]:# (" + url + " html)\n",
+			out:  "[embedmd]:# (" + url + " html)\n```html\n" + page + "```\n",
+		},
+		{
+			name: "no fence",
+			in:   "[embedmd]:# (" + url + " none)\n",
+			out: "[embedmd]:# (" + url + " none)\n<!-- embedmd block start -->\n" + page +
+				"<!-- embedmd block end -->\n",
+		},
+		{
+			name:   "local HTML file",
+			in:     "[embedmd]:# (code.go)\n",
+			strict: true,
+			out:    "[embedmd]:# (code.go)\n```go\n" + page + "```\n",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var warnings []string
+			opts := []Option{
+				WithFetcher(mixedContentProvider{
+					files: map[string][]byte{"code.go": []byte(page)},
+					urls:  map[string][]byte{url: []byte(page)},
+				}),
+				WithWarnings(func(line int, msg string) {
+					warnings = append(warnings, fmt.Sprintf("%d: %s", line, msg))
+				}),
+			}
+			if tt.strict {
+				opts = append(opts, WithStrictContentType())
+			}
+			err := Process(&out, strings.NewReader(tt.in), opts...)
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if got := out.String(); got != tt.out {
+				t.Errorf("expected output\n%s\ngot\n%s", tt.out, got)
+			}
+			if fmt.Sprint(warnings) != fmt.Sprint(tt.warnings) {
+				t.Errorf("expected warnings %q; got %q", tt.warnings, warnings)
+			}
+		})
+	}
+}
+
+func TestIsHTMLPage(t *testing.T) {
+	tc := []struct {
+		in   string
+		want bool
+	}{
+		{"<!DOCTYPE html>\n<html>", true},
+		{"\xef\xbb\xbf\n  <html lang=\"en\">", true},
+		{"<HEAD><title>x</title>", true},
+		{"<!-- a comment -->\n# Title", false},
+		{"<div>fragment</div>", false},
+		{"package main", false},
+	}
+	for _, tt := range tc {
+		if got := isHTMLPage([]byte(tt.in)); got != tt.want {
+			t.Errorf("isHTMLPage(%q) = %v; want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFetcher_ContentEncoding(t *testing.T) {
+	const text = "package main\n"
+	compress := func(w func(io.Writer) io.WriteCloser) []byte {
+		var b bytes.Buffer
+		zw := w(&b)
+		zw.Write([]byte(text))
+		zw.Close()
+		return b.Bytes()
+	}
+
+	tc := []struct {
+		name     string
+		encoding string
+		body     []byte
+		err      string
+	}{
+		{name: "identity", body: []byte(text)},
+		{
+			name:     "gzip",
+			encoding: "gzip",
+			body:     compress(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }),
+		},
+		{
+			name:     "zlib deflate",
+			encoding: "deflate",
+			body:     compress(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }),
+		},
+		{
+			name:     "raw deflate",
+			encoding: "deflate",
+			body: compress(func(w io.Writer) io.WriteCloser {
+				zw, _ := flate.NewWriter(w, flate.DefaultCompression)
+				return zw
+			}),
+		},
+		{
+			name:     "corrupt gzip",
+			encoding: "gzip",
+			body:     []byte(text),
+			err:      "bad gzip content: gzip: invalid header",
+		},
+		{
+			name:     "unsupported encoding",
+			encoding: "zstd",
+			body:     []byte(text),
+			err:      `unsupported content encoding "zstd"`,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Accept-Encoding"); got != "gzip, deflate" {
+					t.Errorf("expected Accept-Encoding gzip, deflate; got %q", got)
+				}
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				w.Write(tt.body)
+			}))
+			defer server.Close()
+
+			b, err := NewFetcher(nil).Fetch("", server.URL)
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if string(b) != text {
+				t.Errorf("expected %q; got %q", text, b)
+			}
+		})
+	}
+}
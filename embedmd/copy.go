@@ -0,0 +1,94 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// CopyButtons describes the HTML wrapped around code blocks for the themes of
+// docs sites to show a button copying them to the clipboard.
+type CopyButtons struct {
+	// Dialect is the markup of the button: clipboardjs, for a button with
+	// the code in its data-clipboard-text attribute, as copied by
+	// clipboard.js, or bootstrap, for the bd-clipboard markup of the
+	// Bootstrap docs and the themes derived from them.
+	Dialect string
+	// StripPrompts copies only the commands of console blocks, without the
+	// "$ " prompts in front of them and without their output.
+	StripPrompts bool
+}
+
+// copyDialects give the HTML opening the wrapper of a code block and holding
+// its button, given the text it copies, for each dialect. The blank line
+// lets markdown renderers parse the code fence inside of the wrapper.
+var copyDialects = map[string]string{
+	"clipboardjs": "<div class=\"embedmd-copy\">\n<button type=\"button\" class=\"embedmd-copy-button\" data-clipboard-text=\"%s\">Copy</button>\n\n",
+	"bootstrap":   "<div class=\"bd-code-snippet\">\n<div class=\"bd-clipboard\"><button type=\"button\" class=\"btn-clipboard\" title=\"Copy to clipboard\" data-clipboard-text=\"%s\">Copy</button></div>\n\n",
+}
+
+// consoleLangs are the languages of the code blocks holding console sessions,
+// whose prompts are stripped from the text copied.
+var consoleLangs = map[string]bool{
+	"console":       true,
+	"shell-session": true,
+	"sh-session":    true,
+}
+
+// WithCopyButtons wraps every embedded code block with the HTML needed by the
+// theme of a docs site to show a copy to clipboard button, as set by c. Only
+// use it with renderers that accept raw HTML in markdown.
+func WithCopyButtons(c CopyButtons) Option {
+	return Option{func(e *embedder) { e.copyButtons = &c }}
+}
+
+func (e *embedder) validateCopyButtons() error {
+	if e.copyButtons == nil {
+		return nil
+	}
+	if _, ok := copyDialects[e.copyButtons.Dialect]; !ok {
+		return fmt.Errorf("bad copy button dialect %q, should be bootstrap or clipboardjs", e.copyButtons.Dialect)
+	}
+	return nil
+}
+
+// writeCopyStart opens the wrapper of the code block b of cmd, with its copy
+// button. Line breaks in the text copied are escaped so the HTML block isn't
+// ended by the blank lines of the code.
+func (e *embedder) writeCopyStart(w io.Writer, cmd *command, b []byte) {
+	text := string(b)
+	if e.copyButtons.StripPrompts && consoleLangs[strings.ToLower(cmd.lang)] {
+		text = stripPrompts(text)
+	}
+	text = strings.ReplaceAll(html.EscapeString(strings.TrimSuffix(text, "\n")), "\n", "&#10;")
+	fmt.Fprintf(w, copyDialects[e.copyButtons.Dialect], text)
+}
+
+func writeCopyEnd(w io.Writer) {
+	fmt.Fprint(w, "\n</div>\n")
+}
+
+// stripPrompts returns the commands of the console session s, without their
+// prompts, leaving out their output. Sessions without prompts are returned as
+// they are.
+func stripPrompts(s string) string {
+	cmds := sessionCommands(s)
+	if len(cmds) == 0 {
+		return s
+	}
+	return strings.Join(cmds, "\n") + "\n"
+}
@@ -0,0 +1,74 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCopyButtons(t *testing.T) {
+	files := map[string][]byte{
+		"code.go":     []byte("package main\n\nfunc main() {}\n"),
+		"session.txt": []byte("$ go run .\nhello\n$ echo \"done\"\ndone\n"),
+	}
+	tc := []struct {
+		name, in, out string
+		buttons       CopyButtons
+		err           string
+	}{
+		{name: "clipboardjs", in: "[embedmd]:# (code.go)\n", buttons: CopyButtons{Dialect: "clipboardjs"},
+			out: "[embedmd]:# (code.go)\n<!-- embedmd block start -->\n" +
+				"<div class=\"embedmd-copy\">\n<button type=\"button\" class=\"embedmd-copy-button\" data-clipboard-text=\"package main&#10;&#10;func main() {}\">Copy</button>\n\n" +
+				"```go\npackage main\n\nfunc main() {}\n```\n\n</div>\n<!-- embedmd block end -->\n"},
+		{name: "bootstrap", in: "[embedmd]:# (code.go /func/)\n", buttons: CopyButtons{Dialect: "bootstrap"},
+			out: "[embedmd]:# (code.go /func/)\n<!-- embedmd block start -->\n" +
+				"<div class=\"bd-code-snippet\">\n<div class=\"bd-clipboard\"><button type=\"button\" class=\"btn-clipboard\" title=\"Copy to clipboard\" data-clipboard-text=\"func\">Copy</button></div>\n\n" +
+				"```go\nfunc\n```\n\n</div>\n<!-- embedmd block end -->\n"},
+		{name: "prompts kept", in: "[embedmd]:# (session.txt console)\n", buttons: CopyButtons{Dialect: "clipboardjs"},
+			out: "[embedmd]:# (session.txt console)\n<!-- embedmd block start -->\n" +
+				"<div class=\"embedmd-copy\">\n<button type=\"button\" class=\"embedmd-copy-button\" data-clipboard-text=\"$ go run .&#10;hello&#10;$ echo &#34;done&#34;&#10;done\">Copy</button>\n\n" +
+				"```console\n$ go run .\nhello\n$ echo \"done\"\ndone\n```\n\n</div>\n<!-- embedmd block end -->\n"},
+		{name: "prompts stripped", in: "[embedmd]:# (session.txt console)\n", buttons: CopyButtons{Dialect: "clipboardjs", StripPrompts: true},
+			out: "[embedmd]:# (session.txt console)\n<!-- embedmd block start -->\n" +
+				"<div class=\"embedmd-copy\">\n<button type=\"button\" class=\"embedmd-copy-button\" data-clipboard-text=\"go run .&#10;echo &#34;done&#34;\">Copy</button>\n\n" +
+				"```console\n$ go run .\nhello\n$ echo \"done\"\ndone\n```\n\n</div>\n<!-- embedmd block end -->\n"},
+		{name: "not a console", in: "[embedmd]:# (session.txt text)\n", buttons: CopyButtons{Dialect: "clipboardjs", StripPrompts: true},
+			out: "[embedmd]:# (session.txt text)\n<!-- embedmd block start -->\n" +
+				"<div class=\"embedmd-copy\">\n<button type=\"button\" class=\"embedmd-copy-button\" data-clipboard-text=\"$ go run .&#10;hello&#10;$ echo &#34;done&#34;&#10;done\">Copy</button>\n\n" +
+				"```text\n$ go run .\nhello\n$ echo \"done\"\ndone\n```\n\n</div>\n<!-- embedmd block end -->\n"},
+		{name: "bad dialect", in: "[embedmd]:# (code.go)\n", buttons: CopyButtons{Dialect: "docsy"},
+			err: "bad copy button dialect \"docsy\", should be bootstrap or clipboardjs"},
+	}
+	for _, tt := range tc {
+		opts := []Option{WithFetcher(mixedContentProvider{files: files}), WithCopyButtons(tt.buttons)}
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), opts...)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+			continue
+		}
+		// Processing the output again leaves it as it is.
+		out.Reset()
+		if err := Process(&out, strings.NewReader(tt.out), opts...); err != nil {
+			t.Errorf("case [%s]: reprocessing: %v", tt.name, err)
+		} else if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected reprocessed output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
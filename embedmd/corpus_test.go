@@ -0,0 +1,64 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seanblong/embedmd/conformance"
+)
+
+var update = flag.Bool("update", false, "update the expected output of the conformance corpus")
+
+// TestCorpus checks Process against the conformance corpus.
+func TestCorpus(t *testing.T) {
+	if *update {
+		// The corpus is embedded in the conformance package, so the tests
+		// only see the new output once built again.
+		updateCorpus(t)
+		t.Skip("corpus updated, run the tests again to check it")
+	}
+	conformance.Run(t, processCorpus)
+}
+
+// processCorpus processes in with the files of files, as conformance runs it.
+func processCorpus(in []byte, files fs.FS) ([]byte, error) {
+	var out bytes.Buffer
+	err := Process(&out, bytes.NewReader(in), WithFetcher(NewFSFetcher(files)))
+	return out.Bytes(), err
+}
+
+// updateCorpus writes the output of each case of the corpus as the expected
+// one.
+func updateCorpus(t *testing.T) {
+	cases, err := conformance.Cases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		out, err := processCorpus(c.Input, c.Files)
+		if err != nil {
+			t.Fatalf("%s: %v", c.Name, err)
+		}
+		path := filepath.Join("..", "conformance", "testdata", "corpus", c.Name, "out.md")
+		if err := os.WriteFile(path, out, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// A Credential is added to the requests to the hosts it's provided for.
+type Credential struct {
+	// Header is the header set, Authorization if empty.
+	Header string
+	// Value is the value of the header, such as "Bearer " and a token.
+	Value string
+}
+
+// BearerToken returns the credential sending token in the Authorization
+// header.
+func BearerToken(token string) Credential {
+	return Credential{Value: "Bearer " + token}
+}
+
+// A CredentialProvider provides the credential for the requests to a host,
+// if any. It is only asked for the host of each request, so credentials are
+// never sent to other hosts.
+type CredentialProvider interface {
+	Credential(host string) (Credential, bool)
+}
+
+// WithCredentials sets the provider of the credentials added to requests,
+// instead of EnvCredentials.
+func WithCredentials(p CredentialProvider) FetcherOption {
+	return FetcherOption{func(f *fetcher) { f.credentials = p }}
+}
+
+// HostCredentials provides credentials by host. Hosts are matched with
+// path.Match, so *.example.com matches the subdomains of example.com.
+type HostCredentials map[string]Credential
+
+// Credential returns the credential of the first pattern matching host, in
+// lexical order so the result doesn't depend on the order of the map.
+func (hc HostCredentials) Credential(host string) (Credential, bool) {
+	if c, ok := hc[host]; ok {
+		return c, true
+	}
+	var match string
+	for pattern := range hc {
+		if ok, _ := path.Match(pattern, host); ok && (match == "" || pattern < match) {
+			match = pattern
+		}
+	}
+	return hc[match], match != ""
+}
+
+// ChainCredentials returns the provider asking each of the given providers
+// in order, until one has a credential for the host.
+func ChainCredentials(providers ...CredentialProvider) CredentialProvider {
+	return credentialChain(providers)
+}
+
+type credentialChain []CredentialProvider
+
+func (cc credentialChain) Credential(host string) (Credential, bool) {
+	for _, p := range cc {
+		if c, ok := p.Credential(host); ok {
+			return c, true
+		}
+	}
+	return Credential{}, false
+}
+
+// envTokens are the environment variables holding the tokens of public
+// forges, with the hosts they're sent to.
+var envTokens = []struct {
+	env    string
+	header string
+	hosts  []string
+}{
+	{"GITHUB_TOKEN", "", []string{"github.com", "api.github.com", "raw.githubusercontent.com", "gist.githubusercontent.com"}},
+	{"GITLAB_TOKEN", "PRIVATE-TOKEN", []string{"gitlab.com"}},
+	{"BITBUCKET_TOKEN", "", []string{"bitbucket.org", "api.bitbucket.org"}},
+}
+
+// EnvCredentials provides the credentials found in the environment:
+// GITHUB_TOKEN for GitHub, GITLAB_TOKEN for GitLab, BITBUCKET_TOKEN for
+// Bitbucket, and for any other host a bearer token in a variable named
+// after it, such as EMBEDMD_TOKEN_ARTIFACTS_EXAMPLE_COM for
+// artifacts.example.com.
+func EnvCredentials() CredentialProvider { return envCredentials{} }
+
+type envCredentials struct{}
+
+func (envCredentials) Credential(host string) (Credential, bool) {
+	if token := os.Getenv(hostTokenEnv(host)); token != "" {
+		return BearerToken(token), true
+	}
+	for _, t := range envTokens {
+		token := os.Getenv(t.env)
+		if token == "" || !slices.Contains(t.hosts, host) {
+			continue
+		}
+		if t.header != "" {
+			return Credential{Header: t.header, Value: token}, true
+		}
+		return BearerToken(token), true
+	}
+	return Credential{}, false
+}
+
+// hostTokenEnv returns the environment variable holding the token of host.
+func hostTokenEnv(host string) string {
+	return "EMBEDMD_TOKEN_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, host)
+}
+
+// Set sets the header of the credential in h.
+func (c Credential) Set(h http.Header) {
+	header := c.Header
+	if header == "" {
+		header = "Authorization"
+	}
+	h.Set(header, c.Value)
+}
+
+// authorizeHost adds the credential of the host of req, if any.
+func authorizeHost(p CredentialProvider, req *http.Request) {
+	if c, ok := p.Credential(req.URL.Hostname()); ok {
+		c.Set(req.Header)
+	}
+}
+
+// redirectCredentials returns the CheckRedirect function of the clients
+// sending the credentials of p, calling check, if not nil, first. The client
+// copies the headers of the first request on redirects, and only drops
+// Authorization for other domains, so the credential of its host is removed
+// and the one of the new host, if any, is added in its place.
+func redirectCredentials(p CredentialProvider, check func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if check != nil {
+			if err := check(req, via); err != nil {
+				return err
+			}
+		} else if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		host := via[0].URL.Hostname()
+		if req.URL.Hostname() == host {
+			return nil
+		}
+		if c, ok := p.Credential(host); ok {
+			c.del(req.Header)
+		}
+		authorizeHost(p, req)
+		return nil
+	}
+}
+
+// del deletes the header of the credential from h.
+func (c Credential) del(h http.Header) {
+	header := c.Header
+	if header == "" {
+		header = "Authorization"
+	}
+	h.Del(header)
+}
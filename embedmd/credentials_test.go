@@ -0,0 +1,107 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEnvCredentials(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "gh")
+	t.Setenv("GITLAB_TOKEN", "gl")
+	t.Setenv("BITBUCKET_TOKEN", "")
+	t.Setenv("EMBEDMD_TOKEN_ARTIFACTS_EXAMPLE_COM", "art")
+	t.Setenv("EMBEDMD_TOKEN_GITHUB_COM", "override")
+
+	tc := []struct {
+		host string
+		want string
+	}{
+		{host: "raw.githubusercontent.com", want: "{ Bearer gh}"},
+		{host: "github.com", want: "{ Bearer override}"},
+		{host: "gitlab.com", want: "{PRIVATE-TOKEN gl}"},
+		{host: "artifacts.example.com", want: "{ Bearer art}"},
+		{host: "bitbucket.org"},
+		{host: "example.com"},
+	}
+	for _, tt := range tc {
+		c, ok := EnvCredentials().Credential(tt.host)
+		got := ""
+		if ok {
+			got = fmt.Sprint(c)
+		}
+		if got != tt.want {
+			t.Errorf("case [%s]: expected %q; got %q", tt.host, tt.want, got)
+		}
+	}
+}
+
+func TestHostCredentials(t *testing.T) {
+	creds := ChainCredentials(HostCredentials{
+		"*.example.com":         BearerToken("sub"),
+		"artifacts.example.com": {Header: "X-Api-Key", Value: "key"},
+	}, HostCredentials{"*": BearerToken("any")})
+
+	tc := []struct {
+		host string
+		want Credential
+	}{
+		{host: "artifacts.example.com", want: Credential{Header: "X-Api-Key", Value: "key"}},
+		{host: "docs.example.com", want: BearerToken("sub")},
+		{host: "example.org", want: BearerToken("any")},
+	}
+	for _, tt := range tc {
+		if c, ok := creds.Credential(tt.host); !ok || c != tt.want {
+			t.Errorf("case [%s]: expected %v; got %v, %v", tt.host, tt.want, c, ok)
+		}
+	}
+	if c, ok := (HostCredentials{}).Credential("example.com"); ok {
+		t.Errorf("expected no credential; got %v", c)
+	}
+}
+
+func TestRedirectCredentials(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%q %q", r.Header.Get("PRIVATE-TOKEN"), r.Header.Get("Authorization"))
+	}))
+	defer target.Close()
+	// The target is reached as localhost, another host than the one
+	// redirecting to it.
+	other := strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other+r.URL.Path, http.StatusFound)
+	}))
+	defer redirect.Close()
+
+	tc := []struct {
+		name  string
+		creds HostCredentials
+		want  string
+	}{
+		{name: "dropped", creds: HostCredentials{"127.0.0.1": {Header: "PRIVATE-TOKEN", Value: "secret"}}, want: `"" ""`},
+		{name: "replaced", creds: HostCredentials{"127.0.0.1": {Header: "PRIVATE-TOKEN", Value: "secret"}, "localhost": BearerToken("other")}, want: `"" "Bearer other"`},
+	}
+	for _, tt := range tc {
+		b, err := NewFetcher(nil, WithCredentials(tt.creds)).Fetch("", redirect.URL+"/file.go")
+		if err != nil {
+			t.Errorf("case [%s]: unexpected error: %v", tt.name, err)
+		} else if string(b) != tt.want {
+			t.Errorf("case [%s]: expected %s; got %s", tt.name, tt.want, b)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import "fmt"
+
+// attrDefaults are the attributes applied by default to the commands
+// embedding sources that match a pattern.
+type attrDefaults struct {
+	pattern string
+	attrs   string
+}
+
+// WithDefaults sets default attributes for the commands embedding a source
+// matching the given pattern. The attributes use the same syntax as in
+// commands, e.g. `license=strip caption="An example"`, and only apply when
+// the command doesn't set them. When several patterns match a source, the
+// defaults given first take precedence.
+func WithDefaults(pattern, attrs string) Option {
+	return Option{func(e *embedder) { e.defaults = append(e.defaults, attrDefaults{pattern, attrs}) }}
+}
+
+// parse returns the attributes as key and value pairs.
+func (d attrDefaults) parse() ([][2]string, error) {
+	args, err := fields(d.attrs)
+	if err != nil {
+		return nil, fmt.Errorf("defaults for %s: %v", d.pattern, err)
+	}
+	var kvs [][2]string
+	for _, arg := range args {
+		key, val, ok := cutAttr(arg)
+		if !ok {
+			return nil, fmt.Errorf("defaults for %s: %q is not a key=value attribute", d.pattern, arg)
+		}
+		if err := new(command).setAttr(key, val); err != nil {
+			return nil, fmt.Errorf("defaults for %s: %v", d.pattern, err)
+		}
+		kvs = append(kvs, [2]string{key, val})
+	}
+	return kvs, nil
+}
+
+// validateDefaults checks the default attributes before processing anything.
+func (e *embedder) validateDefaults() error {
+	for _, d := range e.defaults {
+		if _, err := d.parse(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyDefaults sets the default attributes for the source of cmd which are
+// not already set in the command.
+func (e *embedder) applyDefaults(cmd *command) error {
+	key := sourceKey(cmd.path)
+	for _, d := range e.defaults {
+		if !matchPattern(d.pattern, key) {
+			continue
+		}
+		kvs, err := d.parse()
+		if err != nil {
+			return err
+		}
+		for _, kv := range kvs {
+			if _, ok := cmd.attrs[kv[0]]; ok {
+				continue
+			}
+			if err := cmd.setAttr(kv[0], kv[1]); err != nil {
+				return err
+			}
+			if cmd.attrs == nil {
+				cmd.attrs = map[string]string{}
+			}
+			cmd.attrs[kv[0]] = kv[1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDefaults(t *testing.T) {
+	files := map[string][]byte{
+		"examples/a.go": []byte("// Copyright the authors.\n\npackage a\n"),
+		"b.go":          []byte("// Copyright the authors.\n\npackage b\n"),
+	}
+	tc := []struct {
+		name     string
+		in       string
+		defaults [][2]string
+		out      string
+		err      string
+	}{
+		{name: "matching defaults",
+			in:       "[embedmd]:# (examples/a.go)\n",
+			defaults: [][2]string{{"examples/**", "license=strip"}},
+			out:      "[embedmd]:# (examples/a.go)\n```go\npackage a\n```\n"},
+		{name: "not matching defaults",
+			in:       "[embedmd]:# (b.go)\n",
+			defaults: [][2]string{{"examples/**", "license=strip"}},
+			out:      "[embedmd]:# (b.go)\n```go\n// Copyright the authors.\n\npackage b\n```\n"},
+		{name: "command overrides defaults",
+			in:       "[embedmd]:# (examples/a.go license=keep)\n",
+			defaults: [][2]string{{"examples/**", "license=strip"}},
+			out:      "[embedmd]:# (examples/a.go license=keep)\n```go\n// Copyright the authors.\n\npackage a\n```\n"},
+		{name: "first defaults win",
+			in: "[embedmd]:# (examples/a.go)\n",
+			defaults: [][2]string{
+				{"examples/*.go", `license=strip caption="An example"`},
+				{"**", "license=keep caption=Other"},
+			},
+			out: "[embedmd]:# (examples/a.go)\n<!-- embedmd block start -->\n*An example*\n\n```go\npackage a\n```\n<!-- embedmd block end -->\n"},
+		{name: "unknown attribute",
+			in:       "[embedmd]:# (b.go)\n",
+			defaults: [][2]string{{"**", "colour=red"}},
+			err:      `defaults for **: unknown attribute "colour"`},
+		{name: "not an attribute",
+			in:       "[embedmd]:# (b.go)\n",
+			defaults: [][2]string{{"**", "go"}},
+			err:      `defaults for **: "go" is not a key=value attribute`},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []Option{WithFetcher(mixedContentProvider{files: files})}
+			for _, d := range tt.defaults {
+				opts = append(opts, WithDefaults(d[0], d[1]))
+			}
+			var out bytes.Buffer
+			err := Process(&out, strings.NewReader(tt.in), opts...)
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if out.String() != tt.out {
+				t.Errorf("case [%s]: expected\n%q\ngot\n%q", tt.name, tt.out, out.String())
+			}
+		})
+	}
+}
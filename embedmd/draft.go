@@ -0,0 +1,34 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import "errors"
+
+// WithDraft replaces the content of the blocks whose source can't be found
+// with a visible placeholder, and a warning, rather than failing. It lets
+// authors preview documents embedding code that is still being written.
+func WithDraft() Option {
+	return Option{func(e *embedder) { e.draft = true }}
+}
+
+// placeholder returns the content standing for a source that couldn't be
+// fetched in draft mode, or err otherwise.
+func (e *embedder) placeholder(err error) ([]byte, error) {
+	var fe *fetchError
+	if !e.draft || !errors.As(err, &fe) {
+		return nil, err
+	}
+	e.warnf(fe.cmd, "%v, embedding a placeholder", fe)
+	return []byte("⚠ source not found: " + fe.cmd.path + "\n"), nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDraft(t *testing.T) {
+	const url = "https://example.com/down.go"
+	old := "```go\nold content\n```\n"
+
+	tc := []struct {
+		name     string
+		in       string
+		opts     []Option
+		out      string
+		warnings []string
+		err      string
+	}{
+		{
+			name:     "missing file",
+			in:       "[embedmd]:# (missing.go)\nYay!\n",
+			out:      "[embedmd]:# (missing.go)\n```go\n⚠ source not found: missing.go\n```\nYay!\n",
+			warnings: []string{"1: could not read missing.go: file does not exist, embedding a placeholder"},
+		},
+		{
+			name: "placeholder replaced once the file exists",
+			in:   "[embedmd]:# (code.go)\n```go\n⚠ source not found: code.go\n```\n",
+			out:  "[embedmd]:# (code.go)\n```go\n" + content + "```\n",
+		},
+		{
+			name:     "missing URL",
+			in:       "[embedmd]:# (" + url + " none)\n",
+			out:      "[embedmd]:# (" + url + " none)\n<!-- embedmd block start -->\n⚠ source not found: " + url + "\n<!-- embedmd block end -->\n",
+			warnings: []string{"1: could not read " + url + ": status Not Found, embedding a placeholder"},
+		},
+		{
+			name:     "stacked command",
+			in:       "[embedmd]:# (code.go)\n[embedmd]:# (missing.go)\n",
+			out:      "[embedmd]:# (code.go)\n[embedmd]:# (missing.go)\n```go\n⚠ source not found: missing.go\n```\n",
+			warnings: []string{"2: could not read missing.go: file does not exist, embedding a placeholder"},
+		},
+		{
+			name:     "stale content preferred",
+			in:       "[embedmd]:# (" + url + ")\n" + old,
+			opts:     []Option{WithKeepStaleOnError()},
+			out:      "[embedmd]:# (" + url + ")\n" + old,
+			warnings: []string{"1: could not read " + url + ": status Not Found, keeping the previous content"},
+		},
+		{
+			name: "other errors still fail",
+			in:   "[embedmd]:# (code.go /nope/)\n",
+			err:  "1: could not extract content from code.go: could not match \"/nope/\"",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var warnings []string
+			opts := append([]Option{
+				WithFetcher(mixedContentProvider{files: map[string][]byte{"code.go": []byte(content)}}),
+				WithDraft(),
+				WithWarnings(func(line int, msg string) {
+					warnings = append(warnings, fmt.Sprintf("%d: %s", line, msg))
+				}),
+			}, tt.opts...)
+			err := Process(&out, strings.NewReader(tt.in), opts...)
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if got := out.String(); got != tt.out {
+				t.Errorf("expected output\n%s\ngot\n%s", tt.out, got)
+			}
+			if fmt.Sprint(warnings) != fmt.Sprint(tt.warnings) {
+				t.Errorf("expected warnings %q; got %q", tt.warnings, warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// EditLinks configures the links added under embedded blocks to edit their
+// sources in the web editor of their forge. Sources embedded from the pages
+// of a forge are edited at the ref of the page.
+type EditLinks struct {
+	// Repo is the repository of the local sources, as host/owner/repo, on
+	// github.com or one of the forges, and Dir its local directory.
+	Repo, Dir string
+	// Ref is the branch edited, as forges only edit files on branches.
+	Ref string
+	// Text is the text of the links, "Edit this example" if empty.
+	Text string
+}
+
+// WithEditLinks adds a link after each embedded block to edit its sources,
+// at the lines embedded, so readers can fix examples where they live.
+// Sources outside of the repository, or not on a forge, aren't linked.
+func WithEditLinks(l EditLinks) Option {
+	return Option{func(e *embedder) { e.editLinks = &l }}
+}
+
+func (e *embedder) validateEditLinks() error {
+	if e.editLinks == nil || e.editLinks.Repo == "" {
+		return nil
+	}
+	if _, _, ok := e.forgeRepo(e.editLinks.Repo, e.editLinks.Ref); !ok {
+		return fmt.Errorf("bad repository %q of edit links, should be host/owner/repo on a known forge", e.editLinks.Repo)
+	}
+	if e.editLinks.Ref == "" {
+		return fmt.Errorf("missing branch of the edit links of %s", e.editLinks.Repo)
+	}
+	return nil
+}
+
+// forgeRepo returns the repository, as host/owner/repo, at ref and its
+// forge.
+func (e *embedder) forgeRepo(repo, ref string) (ForgeFile, Forge, bool) {
+	host, rest, _ := strings.Cut(repo, "/")
+	i := strings.LastIndex(rest, "/")
+	f, ok := findForge(host, e.forges)
+	if !ok || i <= 0 || i == len(rest)-1 {
+		return ForgeFile{}, Forge{}, false
+	}
+	return ForgeFile{Host: host, Owner: rest[:i], Repo: strings.TrimSuffix(rest[i+1:], ".git"), Ref: ref}, f, true
+}
+
+// editURL returns the URL to edit the source of cmd at the lines embedded,
+// or "" if it can't be edited on a forge.
+func (e *embedder) editURL(cmd *command) string {
+	ff, p, ok := e.forgeFile(cmd, e.editLinks.Repo, e.editLinks.Dir, e.editLinks.Ref)
+	if !ok {
+		return ""
+	}
+	editor, ok := p.(Editor)
+	if !ok {
+		return ""
+	}
+	return editor.EditURL(ff, cmd.region[0], cmd.region[1])
+}
+
+// forgeFile locates the source of cmd on its forge, and returns its
+// provider: the file shown by the page it's embedded from, or the local
+// file in dir, the directory of repo, at ref.
+func (e *embedder) forgeFile(cmd *command, repo, dir, ref string) (ForgeFile, Provider, bool) {
+	var ff ForgeFile
+	var f Forge
+	switch {
+	case isURL(cmd.path):
+		u, err := url.Parse(cmd.path)
+		if err != nil {
+			return ForgeFile{}, nil, false
+		}
+		var ok bool
+		if f, ok = findForge(u.Hostname(), e.forges); !ok {
+			return ForgeFile{}, nil, false
+		}
+		if ff, ok = parsePage(f.Kind, u); !ok {
+			return ForgeFile{}, nil, false
+		}
+		ff.Host = u.Host
+		// Gitea pages name the kind of their ref, as in branch/main.
+		if f.Kind == "gitea" {
+			_, ff.Ref, _ = strings.Cut(ff.Ref, "/")
+		}
+	case isGitPath(cmd.path), isRepoPath(cmd.path), isExecPath(cmd.path), repo == "":
+		return ForgeFile{}, nil, false
+	default:
+		var ok bool
+		if ff, f, ok = e.forgeRepo(repo, ref); !ok {
+			return ForgeFile{}, nil, false
+		}
+		p := cmd.path
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(e.baseDir, p)
+		}
+		rel, err := filepath.Rel(realPath(dir), realPath(p))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return ForgeFile{}, nil, false
+		}
+		ff.Path = filepath.ToSlash(rel)
+	}
+	p, ok := provider(f.Kind)
+	return ff, p, ok
+}
+
+// realPath returns the absolute path of p, with its symbolic links
+// resolved if it exists, as git does for the directory of repositories.
+func realPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	if real, err := filepath.EvalSymlinks(p); err == nil {
+		p = real
+	}
+	return p
+}
+
+// hasEditLinks reports whether the block of cmd links to the sources of any
+// of its commands.
+func hasEditLinks(cmd *command) bool {
+	for _, c := range append([]*command{cmd}, cmd.stacked...) {
+		if c.editURL != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeEditLinks writes the links to edit the sources of cmd and the
+// commands stacked on it, naming the sources when there are several.
+func (e *embedder) writeEditLinks(w io.Writer, cmd *command) {
+	text := e.editLinks.Text
+	if text == "" {
+		text = "Edit this example"
+	}
+	cmds := append([]*command{cmd}, cmd.stacked...)
+	for _, c := range cmds {
+		if c.editURL == "" {
+			continue
+		}
+		if len(cmds) > 1 {
+			fmt.Fprintf(w, "\n[%s: %s](%s)\n", text, c.path, c.editURL)
+		} else {
+			fmt.Fprintf(w, "\n[%s](%s)\n", text, c.editURL)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEditLinks(t *testing.T) {
+	p := mixedContentProvider{
+		files: map[string][]byte{"code.go": []byte(content), "other.go": []byte("package other\n")},
+		urls: map[string][]byte{
+			"https://gitlab.com/org/repo/-/raw/v1/x.go":  []byte("a\nb\nc\n"),
+			"https://bitbucket.org/org/repo/raw/v1/x.go": []byte("a\nb\nc\n"),
+		},
+	}
+	repo := EditLinks{Repo: "github.com/org/repo", Dir: ".", Ref: "main"}
+	fenced := "```go\nfunc main() {\n        fmt.Println(\"hello, test\")\n}\n```\n"
+	tc := []struct {
+		name, in, out string
+		links         EditLinks
+		err           string
+	}{
+		{
+			name:  "local source",
+			in:    "[embedmd]:# (../code.go /func main/ $)\n",
+			out:   "[embedmd]:# (../code.go /func main/ $)\n<!-- embedmd block start -->\n" + fenced + "\n[Edit this example](https://github.com/org/repo/edit/main/code.go#L6-L8)\n<!-- embedmd block end -->\n",
+			links: repo,
+		},
+		{
+			name:  "outside of the repository",
+			in:    "[embedmd]:# (../code.go /func main/ $)\n",
+			out:   "[embedmd]:# (../code.go /func main/ $)\n" + fenced,
+			links: EditLinks{Repo: "github.com/org/repo", Dir: "docs", Ref: "main"},
+		},
+		{
+			name:  "forge page",
+			in:    "[embedmd]:# (https://gitlab.com/org/repo/-/blob/v1/x.go#L2-L3 txt)\n",
+			out:   "[embedmd]:# (https://gitlab.com/org/repo/-/blob/v1/x.go#L2-L3 txt)\n<!-- embedmd block start -->\n```txt\nb\nc\n```\n\n[Fix it](https://gitlab.com/org/repo/-/edit/v1/x.go#L2-3)\n<!-- embedmd block end -->\n",
+			links: EditLinks{Text: "Fix it"},
+		},
+		{
+			name:  "bitbucket cloud page",
+			in:    "[embedmd]:# (https://bitbucket.org/org/repo/src/v1/x.go#L2-L3 txt)\n",
+			out:   "[embedmd]:# (https://bitbucket.org/org/repo/src/v1/x.go#L2-L3 txt)\n<!-- embedmd block start -->\n```txt\nb\nc\n```\n\n[Fix it](https://bitbucket.org/org/repo/src/v1/x.go?mode=edit#lines-2:3)\n<!-- embedmd block end -->\n",
+			links: EditLinks{Text: "Fix it"},
+		},
+		{
+			name: "stacked",
+			in:   "[embedmd]:# (../code.go /func main/ $)\n[embedmd]:# (../other.go)\n",
+			out: "[embedmd]:# (../code.go /func main/ $)\n[embedmd]:# (../other.go)\n<!-- embedmd block start -->\n" +
+				strings.TrimSuffix(fenced, "```\n") + "package other\n```\n\n" +
+				"[Edit this example: ../code.go](https://github.com/org/repo/edit/main/code.go#L6-L8)\n\n" +
+				"[Edit this example: ../other.go](https://github.com/org/repo/edit/main/other.go#L1)\n<!-- embedmd block end -->\n",
+			links: repo,
+		},
+		{
+			name:  "unknown forge",
+			in:    "[embedmd]:# (../code.go)\n",
+			links: EditLinks{Repo: "git.example.com/org/repo", Ref: "main"},
+			err:   "bad repository \"git.example.com/org/repo\" of edit links, should be host/owner/repo on a known forge",
+		},
+		{
+			name:  "missing branch",
+			in:    "[embedmd]:# (../code.go)\n",
+			links: EditLinks{Repo: "github.com/org/repo"},
+			err:   "missing branch of the edit links of github.com/org/repo",
+		},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(p), WithBaseDir("docs"), WithEditLinks(tt.links))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
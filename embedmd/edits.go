@@ -0,0 +1,69 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// An Edit replaces the bytes of a document from offset Start to End, End
+// excluded, with New. Edits are made of whole lines.
+type Edit struct {
+	Start, End int
+	New        string
+}
+
+// ProcessBytes processes the document src, named as the file it was read
+// from, without reading or writing any file: as ProcessFile, its format is
+// told by the extension of name unless set with WithFormat, and relative
+// paths are resolved from its directory unless set with WithBaseDir. It
+// returns the document rewritten, and the edits turning src into it, sorted
+// and not overlapping, for editors to apply them as incremental changes.
+// Unlike Process, it rewrites the document even with WithDryRun. It is safe
+// to call concurrently, as long as the callbacks set in the options are.
+func (p *Processor) ProcessBytes(name string, src []byte) (out []byte, edits []Edit, err error) {
+	opts := append([]Option{WithBaseDir(filepath.Dir(name)), WithFormat(FormatOf(name))}, p.opts...)
+	opts = append(opts, Option{func(e *embedder) { e.dryRun = false }})
+	var buf bytes.Buffer
+	if err := Process(&buf, bytes.NewReader(src), opts...); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), diffEdits(src, buf.Bytes()), nil
+}
+
+// diffEdits returns the edits turning a into b, replacing the lines that
+// differ.
+func diffEdits(a, b []byte) []Edit {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+	al, bl := splitLines(a), splitLines(b)
+	// offsets holds the offset of each line of a, and that of its end.
+	offsets := make([]int, len(al)+1)
+	for i, l := range al {
+		offsets[i+1] = offsets[i] + len(l)
+	}
+	var edits []Edit
+	for _, op := range difflib.NewMatcherWithJunk(al, bl, false, nil).GetOpCodes() {
+		if op.Tag == 'e' {
+			continue
+		}
+		edits = append(edits, Edit{Start: offsets[op.I1], End: offsets[op.I2], New: strings.Join(bl[op.J1:op.J2], "")})
+	}
+	return edits
+}
@@ -0,0 +1,96 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestProcessBytes(t *testing.T) {
+	files := map[string][]byte{
+		"docs/code.go":  []byte("package main\n\nfunc main() {}\n"),
+		"docs/other.go": []byte("package other\n"),
+	}
+	p, err := NewProcessor(WithFetcher(mixedContentProvider{files: files}), WithDryRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := "# Title\n" +
+		"[embedmd]:# (code.go /func/ $)\n```go\nold\n```\n" +
+		"Text\n" +
+		"[embedmd]:# (other.go)\n```go\npackage other\n```\n" +
+		"[embedmd]:# (other.go)\n"
+	out, edits, err := p.ProcessBytes("docs/guide.md", []byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "# Title\n" +
+		"[embedmd]:# (code.go /func/ $)\n```go\nfunc main() {}\n```\n" +
+		"Text\n" +
+		"[embedmd]:# (other.go)\n```go\npackage other\n```\n" +
+		"[embedmd]:# (other.go)\n```go\npackage other\n```\n"
+	if string(out) != want {
+		t.Errorf("expected output\n%q; got\n%q", want, out)
+	}
+	wantEdits := []Edit{
+		{Start: 45, End: 49, New: "func main() {}\n"},
+		{Start: 128, End: 128, New: "```go\npackage other\n```\n"},
+	}
+	if !reflect.DeepEqual(edits, wantEdits) {
+		t.Errorf("expected edits %+v; got %+v", wantEdits, edits)
+	}
+	if got := applyEdits(src, edits); got != want {
+		t.Errorf("expected the edits to give\n%q; got\n%q", want, got)
+	}
+
+	if _, edits, err := p.ProcessBytes("docs/guide.md", out); err != nil || edits != nil {
+		t.Errorf("expected no edits of the output; got %+v, %v", edits, err)
+	}
+}
+
+func TestProcessBytes_Concurrent(t *testing.T) {
+	files := map[string][]byte{}
+	for i := 0; i < 8; i++ {
+		files[fmt.Sprintf("code%d.go", i)] = []byte(fmt.Sprintf("package p%d\n", i))
+	}
+	p, err := NewProcessor(WithFetcher(mixedContentProvider{files: files}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			src := fmt.Sprintf("[embedmd]:# (code%d.go)\n", i)
+			want := src + fmt.Sprintf("```go\npackage p%d\n```\n", i)
+			out, edits, err := p.ProcessBytes("doc.md", []byte(src))
+			if err != nil || string(out) != want || applyEdits(src, edits) != want {
+				t.Errorf("case %d: expected\n%q; got\n%q, %+v, %v", i, want, out, edits, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// applyEdits returns s with the edits applied.
+func applyEdits(s string, edits []Edit) string {
+	for i := len(edits) - 1; i >= 0; i-- {
+		s = s[:edits[i].Start] + edits[i].New + s[edits[i].End:]
+	}
+	return s
+}
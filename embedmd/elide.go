@@ -0,0 +1,96 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// defaultEllipsis marks the lines left out by maxlines and elide, unless set
+// with the ellipsis attribute.
+const defaultEllipsis = "..."
+
+// parseMaxLines parses the value of the maxlines attribute.
+func parseMaxLines(val string) (int, error) {
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("maxlines should be a positive number, got %q", val)
+	}
+	return n, nil
+}
+
+// parseElision parses the value of the elide attribute, two regular
+// expressions between slashes matching the lines around those collapsed.
+func parseElision(val string) ([2]*regexp.Regexp, error) {
+	var res [2]*regexp.Regexp
+	args, err := fields(val)
+	if err != nil || len(args) != 2 || args[0][0] != '/' || args[1][0] != '/' {
+		return res, fmt.Errorf("elide should be two regular expressions, as in \"/start/ /end/\", got %q", val)
+	}
+	for i, arg := range args {
+		if res[i], err = compileSlashed(arg); err != nil {
+			return res, fmt.Errorf("bad regular expression %s of elide: %v", arg, err)
+		}
+	}
+	return res, nil
+}
+
+// elide replaces the lines of b between a line matching the start of the
+// elision of cmd and the next line matching its end, both kept, with its
+// ellipsis, indented as the first line replaced. It then truncates b to the
+// maximum number of lines of cmd, ending it with the ellipsis.
+func elide(cmd *command, b []byte) []byte {
+	if cmd.elision[0] == nil && cmd.maxLines == 0 {
+		return b
+	}
+	ellipsis := cmd.ellipsis
+	if ellipsis == "" {
+		ellipsis = defaultEllipsis
+	}
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+
+	if start, end := cmd.elision[0], cmd.elision[1]; start != nil {
+		var kept [][]byte
+		for i := 0; i < len(lines); i++ {
+			kept = append(kept, lines[i])
+			if !start.Match(bytes.TrimSuffix(lines[i], []byte("\n"))) {
+				continue
+			}
+			j := i + 1
+			for j < len(lines) && !end.Match(bytes.TrimSuffix(lines[j], []byte("\n"))) {
+				j++
+			}
+			if j == len(lines) || j == i+1 {
+				// Nothing to collapse without an end, or between the lines.
+				continue
+			}
+			first := lines[i+1]
+			indent := first[:len(first)-len(bytes.TrimLeft(first, " \t"))]
+			kept = append(kept, []byte(string(indent)+ellipsis+"\n"))
+			i = j - 1
+		}
+		lines = kept
+	}
+
+	if cmd.maxLines > 0 && len(lines) > cmd.maxLines {
+		lines = append(lines[:cmd.maxLines:cmd.maxLines], []byte(ellipsis+"\n"))
+	}
+	return bytes.Join(lines, nil)
+}
@@ -0,0 +1,58 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestElide(t *testing.T) {
+	files := map[string][]byte{
+		"code.go": []byte("package main\n\nfunc main() {\n\tif err := run(); err != nil {\n\t\tlog.Fatal(err)\n\t}\n\tfor i := 0; i < 3; i++ {\n\t\tprintln(i)\n\t}\n}\n"),
+	}
+	tc := []struct {
+		name, in, out string
+		err           string
+	}{
+		{name: "truncated", in: "[embedmd]:# (code.go /func main/ $ maxlines=2)\n",
+			out: "[embedmd]:# (code.go /func main/ $ maxlines=2)\n```go\nfunc main() {\n\tif err := run(); err != nil {\n...\n```\n"},
+		{name: "custom ellipsis", in: "[embedmd]:# (code.go /func main/ $ maxlines=1 ellipsis=\"// ...\")\n",
+			out: "[embedmd]:# (code.go /func main/ $ maxlines=1 ellipsis=\"// ...\")\n```go\nfunc main() {\n// ...\n```\n"},
+		{name: "short enough", in: "[embedmd]:# (code.go /func main/ $ maxlines=8)\n",
+			out: "[embedmd]:# (code.go /func main/ $ maxlines=8)\n```go\nfunc main() {\n\tif err := run(); err != nil {\n\t\tlog.Fatal(err)\n\t}\n\tfor i := 0; i < 3; i++ {\n\t\tprintln(i)\n\t}\n}\n```\n"},
+		{name: "elided", in: "[embedmd]:# (code.go /func main/ $ elide=\"/err != nil/ /^\\t}/\" ellipsis=\"// ...\")\n",
+			out: "[embedmd]:# (code.go /func main/ $ elide=\"/err != nil/ /^\\t}/\" ellipsis=\"// ...\")\n```go\nfunc main() {\n\tif err := run(); err != nil {\n\t\t// ...\n\t}\n\tfor i := 0; i < 3; i++ {\n\t\tprintln(i)\n\t}\n}\n```\n"},
+		{name: "elided and truncated", in: "[embedmd]:# (code.go /func main/ $ elide=\"/^func/ /^}/\" maxlines=1)\n",
+			out: "[embedmd]:# (code.go /func main/ $ elide=\"/^func/ /^}/\" maxlines=1)\n```go\nfunc main() {\n...\n```\n"},
+		{name: "no end", in: "[embedmd]:# (code.go /func main/ $ elide=\"/^func/ /nowhere/\")\n",
+			out: "[embedmd]:# (code.go /func main/ $ elide=\"/^func/ /nowhere/\")\n```go\nfunc main() {\n\tif err := run(); err != nil {\n\t\tlog.Fatal(err)\n\t}\n\tfor i := 0; i < 3; i++ {\n\t\tprintln(i)\n\t}\n}\n```\n"},
+		{name: "bad maxlines", in: "[embedmd]:# (code.go maxlines=0)\n", err: "1: maxlines should be a positive number, got \"0\""},
+		{name: "bad elide", in: "[embedmd]:# (code.go elide=/a/)\n", err: "1: elide should be two regular expressions, as in \"/start/ /end/\", got \"/a/\""},
+		{name: "bad regexp", in: "[embedmd]:# (code.go elide=\"/a/ /(/\")\n",
+			err: "1: bad regular expression /(/ of elide: error parsing regexp: missing closing ): `(`"},
+		{name: "ellipsis alone", in: "[embedmd]:# (code.go ellipsis=…)\n", err: "1: cannot use ellipsis without maxlines or elide"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
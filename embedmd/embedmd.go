@@ -11,8 +11,9 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package embedmd provides a single function, Process, that parses markdown
-// searching for markdown comments.
+// Package embedmd provides a function, Process, that parses markdown
+// searching for markdown comments, and a Processor doing the same with a set
+// of options, for programs processing many documents.
 //
 // The format of an embedmd command is:
 //
@@ -43,29 +44,186 @@
 //
 //	[embedmd]:# (pathOrURL language)
 //
+// To embed lines by number, add the range to pathOrURL as in GitHub
+// permalinks, #L10 for a single line or #L10-L42 for several:
+//
+//	[embedmd]:# (pathOrURL#L10-L42 language)
+//
+// To embed a file as it was at a revision of its git repository, prefix its
+// path with git:// and append @ and the revision, a tag, branch, or commit:
+//
+//	[embedmd]:# (git://./pkg/foo.go@v1.2.0)
+//
 // You can ommit the language in any of the previous commands, and the extension
 // of the file will be used for the snippet syntax highlighting. Note that while
 // this works Go files, since the file extension .go matches the name of the language
 // go, this will fail with other files like .md whose language name is markdown.
 //
 //	[embedmd]:# (file.ext)
+//
+// Commands accept key=value attributes after the regular expressions, values
+// with blanks can be double quoted:
+//
+//	[embedmd]:# (file.ext caption="An example" license=strip)
+//
+// The caption attribute renders a caption above the embedded code, and the
+// license attribute strips (or keeps) the license header of the source.
+//
+// The tag attribute embeds the region of the source between comments marking
+// its beginning and end, without them, instead of regular expressions:
+//
+//	// embedmd:begin config
+//	...
+//	// embedmd:end config
+//
+// In Go sources, the go:func, go:type, go:const, and go:var attributes embed
+// the declaration of the given name, with its doc comment, and the symbol
+// attribute a declaration of any kind. Methods are named Type.Method:
+//
+//	[embedmd]:# (server.go go:func=Server.Close)
+//
+// The maxage attribute, e.g. maxage=90d, records the date the block was last
+// refreshed in a comment after it, and reports the blocks whose content
+// hasn't changed for longer, until they are refreshed with WithRefresh.
+//
+// Commands on consecutive lines share a single block, containing what each
+// of them embeds in order. The language, caption, and other rendering
+// attributes of the block are those of the first command:
+//
+//	[embedmd]:# (types.go go /type Point/ /^}/)
+//	[embedmd]:# (main.go go /func main/ /^}/)
+//
+// The shared block is replaced as a whole when processing the file again.
 package embedmd
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"regexp"
+	"time"
 )
 
 // Process reads markdown from the given io.Reader searching for an embedmd
 // command. When a command is found, it is executed and the output is written
 // into the given io.Writer with the rest of standard markdown.
 func Process(out io.Writer, in io.Reader, opts ...Option) error {
-	e := embedder{Fetcher: NewFetcher(nil)}
+	e, read, err := newEmbedder(in, opts)
+	if err != nil {
+		return err
+	}
+	e.warnTypos(read)
+	b := read
+	if e.pin {
+		if b, err = e.pinCommands(b); err != nil {
+			return err
+		}
+	}
+	w := out
+	if e.dryRun {
+		// The markdown is written as it was read once commands are run.
+		w = io.Discard
+	}
+	if e.format == NotebookFormat {
+		if err := processNotebook(w, b, opts); err != nil || !e.dryRun {
+			return err
+		}
+		_, err = out.Write(read)
+		return err
+	}
+	if e.sourceMap != nil {
+		e.out = &lineCounter{w: w}
+		w = e.out
+	}
+	parse := e.parse
+	if e.keepGoing {
+		parse = e.parseKeepingGoing
+	}
+	if err := parse(w, bytes.NewReader(b), e.runCommand); err != nil {
+		return err
+	}
+	if err := e.context().Err(); err != nil {
+		// The last command may have run when it was canceled.
+		return err
+	}
+	if len(e.errs) > 0 {
+		return e.errs
+	}
+	if !e.dryRun {
+		return nil
+	}
+	_, err = out.Write(read)
+	return err
+}
+
+// ProcessContext is Process, with the context set as with WithContext.
+func ProcessContext(ctx context.Context, out io.Writer, in io.Reader, opts ...Option) error {
+	return Process(out, in, append(opts[:len(opts):len(opts)], WithContext(ctx))...)
+}
+
+// newEmbedder returns an embedder with the given options for the markdown
+// read from in, which is returned too.
+func newEmbedder(in io.Reader, opts []Option) (*embedder, []byte, error) {
+	e := &embedder{Fetcher: NewFetcher(nil), langConfidence: defaultLanguageConfidence}
 	for _, opt := range opts {
-		opt.f(&e)
+		opt.f(e)
+	}
+	if err := e.validateDefaults(); err != nil {
+		return nil, nil, err
+	}
+	if err := e.validateAliases(); err != nil {
+		return nil, nil, err
+	}
+	if err := e.validateForges(); err != nil {
+		return nil, nil, err
+	}
+	if err := e.validateFence(); err != nil {
+		return nil, nil, err
+	}
+	if err := e.validateIncludes(); err != nil {
+		return nil, nil, err
+	}
+	if err := e.validateAnnotationStyle(); err != nil {
+		return nil, nil, err
+	}
+	if err := e.validateCopyButtons(); err != nil {
+		return nil, nil, err
+	}
+	if err := e.validateSyntaxes(); err != nil {
+		return nil, nil, err
+	}
+	if err := e.validateFormat(); err != nil {
+		return nil, nil, err
+	}
+	if err := e.validateEditLinks(); err != nil {
+		return nil, nil, err
 	}
-	return process(out, in, e.runCommand)
+	if err := e.validateAttribution(); err != nil {
+		return nil, nil, err
+	}
+	if err := e.validateSeverities(); err != nil {
+		return nil, nil, err
+	}
+	if err := e.validateIncremental(); err != nil {
+		return nil, nil, err
+	}
+	if err := e.validatePlatform(); err != nil {
+		return nil, nil, err
+	}
+	if err := e.compilePolicy(); err != nil {
+		return nil, nil, err
+	}
+	b, err := io.ReadAll(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	e.skipped = skippedLines(b, e.templateRegions)
+	e.vars = frontMatterVars(b)
+	if e.suppressed, err = suppressions(b); err != nil {
+		return nil, nil, err
+	}
+	return e, b, nil
 }
 
 // An Option provides a way to adapt the Process function to your needs.
@@ -83,33 +241,546 @@ func WithFetcher(c Fetcher) Option {
 	return Option{func(e *embedder) { e.Fetcher = c }}
 }
 
+// WithContext stops processing with the error of ctx once it's done, and
+// passes ctx to the fetches of the Fetcher if it's a FetcherContext, so
+// long running builds can be canceled or given a deadline.
+func WithContext(ctx context.Context) Option {
+	return Option{func(e *embedder) { e.ctx = ctx }}
+}
+
+// context returns the context set with WithContext, or the background one.
+func (e *embedder) context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+// WithWarnings provides a function called for every non fatal issue found
+// while processing, with the line number of the command causing it.
+func WithWarnings(f func(line int, msg string)) Option {
+	return Option{func(e *embedder) { e.warnings = f }}
+}
+
 type embedder struct {
 	Fetcher
-	baseDir string
+	ctx             context.Context
+	baseDir         string
+	licenseRules    []LicenseRule
+	defaults        []attrDefaults
+	aliases         map[string]string
+	forges          []Forge
+	warnings        func(line int, msg string)
+	staleBlocks     func(StaleBlock)
+	sourceMap       func(MappedRegion)
+	beforeEmbed     func(*Embed) error
+	afterEmbed      func(*Embed) error
+	ariaLabels      bool
+	a11yLint        bool
+	anchorLint      bool
+	checksums       bool
+	incremental     bool
+	normalizeFences bool
+	fence           string
+	annotationStyle string
+	copyButtons     *CopyButtons
+	includes        *Includes
+	strip           bool
+	languages       map[string]string
+	extractCache    *ExtractCache
+	selectionLimit  int
+	// planning is set by Processor.Plan, which reports the regions embedded.
+	planning bool
+	// langConfidence is the minimum confidence of languages detected from
+	// the content of the code.
+	langConfidence float64
+	transforms     map[string]Transform
+	selectors      map[string]Selector
+	renderer       Renderer
+	editLinks      *EditLinks
+	attribution    *Attribution
+	// keepGoing is set to run all the commands, collecting the errors of
+	// those that fail in errs.
+	keepGoing       bool
+	errs            Errors
+	syntaxes        []Syntax
+	format          Format
+	fenceIndented   bool
+	templateRegions bool
+	workspace       *Workspace
+	// strictContentType fails commands embedding HTML pages by mistake.
+	strictContentType bool
+	// keepStale keeps blocks whose remote source can't be fetched, marking
+	// them if markStale is set.
+	keepStale, markStale bool
+	// draft embeds placeholders for the sources that can't be found.
+	draft bool
+	// pin records the checksum of the content fetched by commands.
+	pin bool
+	// versions are those the content of local files is compared across.
+	versions []Version
+	// allowedExec holds the command lines of the programs whose output can
+	// be embedded, and execEnv the environment variables they get besides
+	// those of defaultExecEnv.
+	allowedExec, execEnv []string
+	// execTimeout and execMaxOutput limit the programs run, if set, instead
+	// of the defaults.
+	execTimeout   time.Duration
+	execMaxOutput int64
+	// execDirs are the directories programs can run in, the current one if
+	// empty, and execNetwork lets them use the network.
+	execDirs    []string
+	execNetwork bool
+	// execCPU and execMemory limit the resources of the programs run, if
+	// set, instead of the defaults.
+	execCPU    time.Duration
+	execMemory int64
+	// conflictResolver resolves the blocks edited by hand whose source
+	// changed.
+	conflictResolver ConflictResolver
+	// managed wraps every block with comments and keeps the edits made by
+	// hand to them, merged with the changes of their sources.
+	managed bool
+	// sourcePatches is called with the patches to sources implementing the
+	// edits of their blocks.
+	sourcePatches func(SourcePatch)
+	// runnableCommands wraps console blocks with the commands they run.
+	runnableCommands bool
+	// dryRun writes the markdown as it was read, once commands are run.
+	dryRun bool
+	// refresh records today as the date blocks were refreshed, and
+	// maxAgeErrors fails on the blocks not refreshed within their maxage.
+	refresh, maxAgeErrors bool
+	// check keeps the stale blocks whose severity is lowered by severities
+	// or by the suppression comments in suppressed, by command line.
+	check      bool
+	severities map[string]Severity
+	suppressed map[int][]string
+	// quarantine reports whether the failures of a block, by the
+	// fingerprint of its command, are reported as warnings.
+	quarantine func(fingerprint string) bool
+	// recheckFetcher fetches again the remote content of stale blocks.
+	recheckFetcher Fetcher
+	// policyRules are compiled into policy, which commands must satisfy.
+	policyRules []PolicyRule
+	policy      []compiledRule
+	// now returns the current time, time.Now if nil.
+	now func() time.Time
+	// skipped holds the lines whose commands are left untouched.
+	skipped map[int]bool
+	// skipLabels and onlyLabels select the commands run by their labels.
+	skipLabels, onlyLabels map[string]bool
+	// platform is the platform the docs are rendered for, if any.
+	platform string
+	// vars are the variables of the front matter, referenced in paths, and
+	// root the root of the git repository, once referenced.
+	vars map[string]string
+	root string
+	// env holds the variables referenced as ${NAME} in commands, if set.
+	env map[string]string
+	// reviewNotes is set to write where blocks come from after them.
+	reviewNotes bool
+	// out counts the lines written when sources are mapped.
+	out *lineCounter
+}
+
+func (e *embedder) warnf(cmd *command, format string, args ...interface{}) {
+	if e.warnings != nil {
+		e.warnings(cmd.line, fmt.Sprintf(format, args...))
+	}
 }
 
 func (e *embedder) runCommand(w io.Writer, cmd *command) error {
-	b, err := e.Fetch(e.baseDir, cmd.path)
+	if err := e.context().Err(); err != nil {
+		return err
+	}
+	if cmd.looseFence && e.ownsFence(cmd) {
+		cmd.looseFence = false
+		cmd.readTrailers()
+	}
+	if e.skipped[cmd.line] || frozen(cmd) || e.deselected(cmd) {
+		return keepBlock(w, cmd)
+	}
+	if e.strip || !e.onPlatform(cmd) {
+		return stripBlock(w, cmd)
+	}
+	if err := e.checkFormat(cmd); err != nil {
+		return err
+	}
+	if e.upToDate(cmd) {
+		return keepBlock(w, cmd)
+	}
+	b, err := e.content(cmd)
+	if errors.Is(err, SkipEmbed) {
+		return keepBlock(w, cmd)
+	}
+	failed := err != nil
+	if failed {
+		if e.quarantined(cmd) {
+			e.warnf(cmd, "%v, quarantined", err)
+			return keepBlock(w, cmd)
+		}
+		if kept, kerr := e.keepStaleBlock(w, cmd, err); kept {
+			return kerr
+		}
+		if b, err = e.placeholder(err); err != nil {
+			return err
+		}
+		// The lines of the placeholder aren't those of the source.
+		cmd.linenos, cmd.highlights = false, nil
+	} else {
+		b = e.normalize(cmd, b)
+	}
+
+	if e.a11yLint && cmd.caption == "" {
+		e.warnf(cmd, "embedded %s has no caption to describe it", cmd.path)
+	}
+
+	if !failed {
+		if cmd.include, err = e.include(cmd, b); err != nil {
+			return fmt.Errorf("could not write shared snippet: %v", err)
+		}
+		if e.editLinks != nil {
+			for _, c := range append([]*command{cmd}, cmd.stacked...) {
+				c.editURL = e.editURL(c)
+			}
+		}
+		for _, c := range append([]*command{cmd}, cmd.stacked...) {
+			if c.attribution != "link" {
+				continue
+			}
+			if c.sourceLink, err = e.sourceLink(c); err != nil {
+				return err
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := e.render(&buf, cmd, b); err != nil {
+		return err
+	}
+	changed := !bytes.Equal(buf.Bytes(), cmd.block)
+	if cmd.block == nil && len(cmd.trailers) > 0 {
+		// The block was stripped, keeping its trailers, and its checksum, if
+		// recorded, tells whether its content changed since.
+		changed = cmd.checksum != "" && checksum(buf.Bytes()) != cmd.checksum
+	}
+	if changed && !failed {
+		if fresh, ok := e.recheck(cmd, b); ok {
+			b = fresh
+			if cmd.include, err = e.include(cmd, b); err != nil {
+				return fmt.Errorf("could not write shared snippet: %v", err)
+			}
+			buf.Reset()
+			if err := e.render(&buf, cmd, b); err != nil {
+				return err
+			}
+			changed = !bytes.Equal(buf.Bytes(), cmd.block)
+		}
+	}
+	if changed && !failed && e.staleBlocks != nil {
+		e.reportStale(cmd)
+	}
+	if changed {
+		if kept, err := e.keepDowngraded(w, cmd); kept {
+			return err
+		}
+	}
+	if e.checksums && e.sourcePatches != nil && !failed && editedByHand(cmd) {
+		e.patchSource(cmd, buf.Bytes(), b)
+	}
+	if e.checksums && (e.conflictResolver != nil || e.managed) && !failed && editedByHand(cmd) {
+		return e.resolveConflict(w, cmd, buf.Bytes())
+	}
+	if e.sourceMap != nil && !failed {
+		e.mapSources(cmd, buf.Bytes(), b, e.out.lines)
+	}
+	if e.checksums {
+		e.checkBlock(cmd, buf.Bytes())
+		fp := cmd.fingerprint
+		if e.incremental || fp != "" {
+			fp = fingerprint(cmd)
+		}
+		buf.WriteString(checksumTrailer(checksum(buf.Bytes()), fp))
+	}
+	if err := e.checkAge(&buf, cmd, changed); err != nil {
+		return err
+	}
+	if cmd.looseFence && !e.normalizeFences {
+		// The block isn't recognized as generated by embedmd, so it's kept.
+		e.warnf(cmd, "code block after the command is fenced differently than embedded code, so it is kept")
+		buf.Write(cmd.block)
+		writeTrailers(&buf, cmd) //nolint:errcheck
+	}
+	if e.reviewNotes && !e.literal() {
+		buf.WriteString(reviewNote(cmd, changed, failed))
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// content returns the content of the block of cmd, with that of the
+// commands stacked on it.
+func (e *embedder) content(cmd *command) ([]byte, error) {
+	b, err := e.embedded(cmd, cmd)
+	for _, c := range cmd.stacked {
+		if err != nil {
+			break
+		}
+		if !e.onPlatform(c) {
+			continue
+		}
+		var more []byte
+		if more, err = e.embedded(c, cmd); err != nil {
+			err = &lineError{c.line, err}
+		}
+		if c.stitched {
+			b = append(b, cmd.sep+"\n"...)
+		}
+		b = append(b, more...)
+	}
+	if err == nil {
+		err = checkHighlights(cmd, b)
+	}
+	return b, err
+}
+
+// embedded returns the content embedded by cmd, rendered in the block of the
+// command top, which is cmd itself unless cmd is stacked on it.
+func (e *embedder) embedded(cmd, top *command) ([]byte, error) {
+	if err := e.resolvePath(cmd); err != nil {
+		return nil, err
+	}
+	var err error
+	cmd.path, err = e.expandAlias(cmd.path)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.applyDefaults(cmd); err != nil {
+		return nil, err
+	}
+	e.mapLanguage(cmd)
+	if err := e.checkPolicy(cmd); err != nil {
+		return nil, err
+	}
+	if err := e.runBeforeEmbed(cmd); err != nil {
+		return nil, err
+	}
+	if err := e.resolveSelector(cmd); err != nil {
+		return nil, err
+	}
+
+	b, src, err := e.extract(cmd)
 	if err != nil {
-		return fmt.Errorf("could not read %s: %w", cmd.path, err)
+		return nil, err
+	}
+	if err := e.checkVersions(cmd, top); err != nil {
+		return nil, err
 	}
 
-	b, err = extract(b, cmd.start, cmd.end)
+	// Stacked commands are attributed by the caption of the block.
+	if cmd.caption == "" {
+		cmd.caption = top.caption
+	}
+	b = omit(cmd, b)
+	b, err = e.applyLicensePolicy(cmd, b)
 	if err != nil {
-		return fmt.Errorf("could not extract content from %s: %w", cmd.path, err)
+		return nil, err
+	}
+	b = elide(cmd, b)
+	if b, err = e.transform(cmd, b); err != nil {
+		return nil, err
+	}
+	if b, err = e.runAfterEmbed(cmd, src, b); err != nil {
+		return nil, err
 	}
 
 	if len(b) > 0 && b[len(b)-1] != '\n' {
-		b = append(b, '\n')
+		// Copy b rather than append to the source it may be a part of.
+		b = append(b[:len(b):len(b)], '\n')
+	}
+	cmd.embeddedLines = bytes.Count(b, []byte("\n"))
+	return b, nil
+}
+
+// extract returns the content of the source of cmd that it embeds, and the
+// source itself, or only the content when it's taken from the extract cache
+// as neither the source nor cmd changed.
+func (e *embedder) extract(cmd *command) (b, src []byte, err error) {
+	slot := e.extractSlot(cmd)
+	if slot.unchanged() {
+		if err := checkPin(cmd, slot.entry.Hash); err != nil {
+			return nil, nil, err
+		}
+		return slot.use(cmd), nil, nil
 	}
 
-	if cmd.useFence {
-		fmt.Fprintln(w, "```"+cmd.lang)
-		w.Write(b) //nolint:errcheck
-		fmt.Fprintln(w, "```")
+	b, err = e.fetch(cmd.path)
+	if err != nil {
+		return nil, nil, &fetchError{cmd, fmt.Errorf("could not read %s: %w", cmd.path, err)}
+	}
+	var hash string
+	if slot != nil || cmd.sha256 != "" {
+		hash = sha256Hex(string(b))
+	}
+	if err := checkPin(cmd, hash); err != nil {
+		return nil, nil, err
+	}
+	if slot.same(hash) {
+		// The source was only touched, so the entry is kept with its new
+		// modification time.
+		b = slot.use(cmd)
+		return b, nil, slot.add(cmd, hash, slot.entry.Confidence, b)
+	}
+	var confidence float64
+	if cmd.lang == "" {
+		var lang string
+		lang, confidence = detectLanguage(b)
+		switch {
+		case lang == "":
+			return nil, nil, fmt.Errorf("language is required as %s has no extension and its content doesn't tell it", cmd.path)
+		case confidence < e.langConfidence:
+			return nil, nil, fmt.Errorf("language is required as %s has no extension and its content only suggests %s (confidence %.2f, below %.2f)",
+				cmd.path, lang, confidence, e.langConfidence)
+		}
+		cmd.lang = lang
+	}
+	if err := e.checkContentType(cmd, b); err != nil {
+		return nil, nil, err
+	}
+	// The output uses \n line endings, whatever the platform of the source.
+	b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+	e.lintAnchors(cmd, b)
+	src = b
+
+	if b, err = extractContent(cmd, b); err != nil {
+		return nil, nil, fmt.Errorf("could not extract content from %s: %w", cmd.path, err)
+	}
+	e.checkSelection(cmd, src, b)
+	if slot != nil || e.planning || e.staleBlocks != nil || e.sourceMap != nil || e.editLinks != nil || e.attribution != nil || cmd.linenos || e.reviewNotes {
+		cmd.region = regionLines(src, b)
+	}
+	if slot != nil {
+		if err := slot.add(cmd, hash, confidence, b); err != nil {
+			return nil, nil, fmt.Errorf("could not cache the content extracted from %s: %v", cmd.path, err)
+		}
+	}
+	return b, src, nil
+}
+
+// extractContent returns the content of the source b embedded by cmd.
+func extractContent(cmd *command, b []byte) ([]byte, error) {
+	switch {
+	case cmd.selectWith != nil:
+		return cmd.selectWith.Select(b, cmd.selectorArg)
+	case cmd.symbol != "":
+		return extractSymbol(b, cmd.symbolKind, cmd.symbol)
+	case cmd.tag != "":
+		return extractTag(b, cmd.tag)
+	case cmd.lines != nil:
+		return extractLines(b, *cmd.lines)
+	case cmd.heading != "":
+		return extractHeading(b, cmd.heading)
+	case cmd.group != 0 || cmd.matchAll:
+		return extractMatches(b, cmd)
+	case cmd.splice && cmd.start == nil:
+		return withoutFrontMatter(b), nil
+	case cmd.splice:
+		return extractSection(b, *cmd.start, cmd.end)
+	}
+	return extract(b, cmd.start, cmd.end)
+}
+
+// fetchError is the error fetching the source of a command.
+type fetchError struct {
+	cmd *command
+	err error
+}
+
+func (e *fetchError) Error() string { return e.err.Error() }
+func (e *fetchError) Unwrap() error { return e.err }
+
+// writeFenced writes the embedded content b as a fenced code block.
+func (e *embedder) writeFenced(w io.Writer, cmd *command, b []byte) {
+	// The annotations and the attributes set with info follow the language.
+	meta := e.annotations(cmd)
+	switch {
+	case meta == "":
+		meta = cmd.info
+	case cmd.info != "":
+		meta += " " + cmd.info
+	}
+	info := cmd.lang
+	if meta != "" {
+		info += " " + meta
+	}
+	cmds := e.runnable(cmd, b)
+	if cmds != nil {
+		writeSessionStart(w, cmds)
+	}
+	if e.copyButtons != nil {
+		e.writeCopyStart(w, cmd, b)
+	}
+	if fence := e.codeFence(cmd); fence == mdxFence {
+		writeCodeBlock(w, cmd.lang, meta, b)
 	} else {
+		fmt.Fprintln(w, fence+info)
+		w.Write(b) //nolint:errcheck
+		fmt.Fprintln(w, fence)
+	}
+	if e.copyButtons != nil {
+		writeCopyEnd(w)
+	}
+	if cmds != nil {
+		writeSessionEnd(w)
+	}
+}
+
+// render writes the embedded content b as described by cmd.
+func (e *embedder) render(w io.Writer, cmd *command, b []byte) error {
+	if e.literal() {
+		e.writeLiteral(w, cmd, b)
+		return nil
+	}
+	// Content that is not a single code fence is wrapped with markers, so it
+	// can be found and replaced when processing the file again.
+	split := chunks(cmd, b)
+	wrap := !cmd.useFence || e.managed || e.renderer != nil || cmd.caption != "" || e.ariaLabels || cmd.include != "" || hasEditLinks(cmd) || hasSourceLinks(cmd) || split != nil || e.copyButtons != nil || e.runnable(cmd, b) != nil
+	if cmd.indented && cmd.useFence && !wrap && !e.fenceIndented && !cmd.annotated() {
+		writeIndented(w, b)
+		return nil
+	}
+	if wrap {
 		fmt.Fprintln(w, "<!-- embedmd block start -->")
+	}
+	if e.ariaLabels {
+		writeAriaStart(w, cmd)
+	}
+	if cmd.caption != "" {
+		fmt.Fprintf(w, "*%s*\n\n", cmd.caption)
+	}
+	switch {
+	case cmd.include != "":
+		fmt.Fprintln(w, cmd.include)
+	case split != nil:
+		e.writeChunks(w, cmd, split)
+	case cmd.useFence && e.renderer != nil:
+		if err := e.writeRendered(w, cmd, b); err != nil {
+			return err
+		}
+	case cmd.useFence:
+		e.writeFenced(w, cmd, b)
+	default:
 		w.Write(b) //nolint:errcheck
+	}
+	if e.ariaLabels {
+		writeAriaEnd(w)
+	}
+	writeSourceLinks(w, cmd)
+	if hasEditLinks(cmd) {
+		e.writeEditLinks(w, cmd)
+	}
+	if wrap {
 		fmt.Fprintln(w, "<!-- embedmd block end -->")
 	}
 	return nil
@@ -121,10 +792,7 @@ func extract(b []byte, start, end *string) ([]byte, error) {
 	}
 
 	match := func(s string) ([]int, error) {
-		if len(s) <= 2 || s[0] != '/' || s[len(s)-1] != '/' {
-			return nil, fmt.Errorf("missing slashes (/) around %q", s)
-		}
-		re, err := regexp.CompilePOSIX(s[1 : len(s)-1])
+		re, err := compileSlashed(s)
 		if err != nil {
 			return nil, err
 		}
@@ -142,6 +142,12 @@ func TestExtractFromFile(t *testing.T) {
 			files: map[string][]byte{"code.go": []byte(content)},
 			out:   "<!-- embedmd block start -->\n" + string(content) + "<!-- embedmd block end -->\n",
 		},
+		{
+			name:  "caption",
+			cmd:   command{path: "code.go", lang: "go", useFence: true, caption: "code.go"},
+			files: map[string][]byte{"code.go": []byte(content)},
+			out:   "<!-- embedmd block start -->\n*code.go*\n\n```go\n" + string(content) + "```\n<!-- embedmd block end -->\n",
+		},
 	}
 
 	for _, tt := range tc {
@@ -263,6 +269,21 @@ func TestProcess(t *testing.T) {
 				"```\n" +
 				"Yay!\n",
 		},
+		{
+			name: "replacing existing code with a caption",
+			in: "[embedmd]:# (code.go caption=Code)\n" +
+				"```go\n" +
+				string(content) +
+				"```\n",
+			files: map[string][]byte{"code.go": []byte(content)},
+			out: "[embedmd]:# (code.go caption=Code)\n" +
+				"<!-- embedmd block start -->\n" +
+				"*Code*\n\n" +
+				"```go\n" +
+				string(content) +
+				"```\n" +
+				"<!-- embedmd block end -->\n",
+		},
 		{
 			name: "replacing existing code with no fencing",
 			in: "# This is some markdown\n" +
@@ -277,6 +298,57 @@ func TestProcess(t *testing.T) {
 				string(content) +
 				"<!-- embedmd block end -->\n",
 		},
+		{
+			name: "stacked commands sharing a block",
+			in: "[embedmd]:# (code.go /func main/ $)\n" +
+				"[embedmd]:# (other.go go)\n" +
+				"Yay!\n",
+			files: map[string][]byte{"code.go": []byte(content), "other.go": []byte("var x = 1")},
+			out: "[embedmd]:# (code.go /func main/ $)\n" +
+				"[embedmd]:# (other.go go)\n" +
+				"```go\n" +
+				"func main() {\n        fmt.Println(\"hello, test\")\n}\n" +
+				"var x = 1\n" +
+				"```\n" +
+				"Yay!\n",
+		},
+		{
+			name: "replacing a block shared by stacked commands",
+			in: "[embedmd]:# (code.go /func main/ $)\n" +
+				"[embedmd]:# (other.go go)\n" +
+				"```go\n" +
+				"old content\n" +
+				"```\n" +
+				"Yay!\n",
+			files: map[string][]byte{"code.go": []byte(content), "other.go": []byte("var x = 1")},
+			out: "[embedmd]:# (code.go /func main/ $)\n" +
+				"[embedmd]:# (other.go go)\n" +
+				"```go\n" +
+				"func main() {\n        fmt.Println(\"hello, test\")\n}\n" +
+				"var x = 1\n" +
+				"```\n" +
+				"Yay!\n",
+		},
+		{
+			name: "commands separated by a blank line",
+			in: "[embedmd]:# (other.go)\n" +
+				"\n" +
+				"[embedmd]:# (other.go)\n",
+			files: map[string][]byte{"other.go": []byte("var x = 1")},
+			out: "[embedmd]:# (other.go)\n" +
+				"```go\nvar x = 1\n```\n" +
+				"\n" +
+				"[embedmd]:# (other.go)\n" +
+				"```go\nvar x = 1\n```\n",
+		},
+		{
+			name: "error in a stacked command",
+			in: "[embedmd]:# (other.go)\n" +
+				"[embedmd]:# (missing.go)\n" +
+				"```go\n```\n",
+			files: map[string][]byte{"other.go": []byte("var x = 1")},
+			err:   "2: could not read missing.go: file does not exist",
+		},
 		{
 			name: "embedding code from a URL",
 			in: "# This is some markdown\n" +
@@ -389,3 +461,37 @@ func (c mixedContentProvider) Fetch(dir, path string) ([]byte, error) {
 	}
 	return nil, fmt.Errorf("status Not Found")
 }
+
+func TestExtractLines(t *testing.T) {
+	tc := []struct {
+		name  string
+		lines lineRange
+		out   string
+		err   string
+	}{
+		{name: "single line", lines: lineRange{2, 2}, out: "package main\n"},
+		{name: "several lines", lines: lineRange{6, 8}, out: "func main() {\n        fmt.Println(\"hello, test\")\n}\n"},
+		{name: "whole file", lines: lineRange{1, 8}, out: content},
+		{name: "out of range", lines: lineRange{6, 9}, err: "line range L6-L9 is out of range, the source has 8 lines"},
+	}
+	for _, tt := range tc {
+		b, err := extractLines([]byte(content), tt.lines)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if string(b) != tt.out {
+			t.Errorf("case [%s]: expected extracting %q; got %q", tt.name, tt.out, b)
+		}
+	}
+
+	var out bytes.Buffer
+	err := Process(&out, strings.NewReader("[embedmd]:# (code.go#L2)\n"), WithFetcher(mixedContentProvider{files: map[string][]byte{"code.go": []byte(content)}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[embedmd]:# (code.go#L2)\n```go\npackage main\n```\n"; out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+	err = Process(&out, strings.NewReader("[embedmd]:# (code.go#L20-L30)\n"), WithFetcher(mixedContentProvider{files: map[string][]byte{"code.go": []byte(content)}}))
+	eqErr(t, "out of range", err, "1: could not extract content from code.go: line range L20-L30 is out of range, the source has 8 lines")
+}
@@ -0,0 +1,115 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// WithEnv sets the variables referenced as ${NAME} in the paths and the
+// attributes of commands, which fail if they reference one not set. $${NAME}
+// is kept as ${NAME}. References in regular expressions are left as is, as
+// are all of them without this option.
+func WithEnv(vars map[string]string) Option {
+	return Option{func(e *embedder) {
+		if e.env = vars; e.env == nil {
+			e.env = map[string]string{}
+		}
+	}}
+}
+
+// envRef matches the references to variables, ${NAME} or $NAME, and their
+// escaped forms, $${NAME} and $$NAME.
+var envRef = regexp.MustCompile(`\$?\$(?:\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// validVarName matches the names of the variables defined in documents.
+var validVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseDefine parses the arguments of a define directive, NAME=value, which
+// sets a variable for the commands following it in the document.
+func parseDefine(args []string) (*command, error) {
+	if len(args) != 1 {
+		return nil, errors.New("define takes a single NAME=value")
+	}
+	name, val, ok := cutAttr(args[0])
+	if !ok {
+		return nil, errors.New("define takes a single NAME=value")
+	}
+	if !validVarName.MatchString(name) {
+		return nil, fmt.Errorf("variable name should be made of letters, digits, and '_', not starting with a digit, got %q", name)
+	}
+	return &command{varName: name, varValue: val, varDirective: true}, nil
+}
+
+// expandEnv replaces the references to the variables in the parenthesized
+// argument list s, other than in regular expressions: those to the
+// variables defined in the document, as ${NAME} or $NAME, and those to the
+// variables of env, as ${NAME}. Lists that can't be parsed are returned as
+// is, for parseCommand to report.
+func expandEnv(s string, env, defined map[string]string) (string, error) {
+	if env == nil && len(defined) == 0 || !strings.Contains(s, "$") {
+		return s, nil
+	}
+	t := strings.TrimSpace(s)
+	if len(t) < 2 || t[0] != '(' || t[len(t)-1] != ')' {
+		return s, nil
+	}
+	inner := t[1 : len(t)-1]
+	args, offsets, err := fieldsAt(inner)
+	if err != nil {
+		return s, nil
+	}
+	var b strings.Builder
+	last := 0
+	for i, arg := range args {
+		if _, val, ok := cutAttr(arg); !ok && arg[0] == '/' || ok && strings.HasPrefix(val, "/") {
+			continue
+		}
+		var err error
+		expanded := envRef.ReplaceAllStringFunc(arg, func(ref string) string {
+			m := envRef.FindStringSubmatch(ref)
+			name, braced := m[1], m[1] != ""
+			if !braced {
+				name = m[2]
+			}
+			v, isDefined := defined[name]
+			switch {
+			case !isDefined && (!braced || env == nil):
+				// Other references to $NAME, such as those of shell
+				// commands, are kept.
+				return ref
+			case strings.HasPrefix(ref, "$$"):
+				return ref[1:]
+			case isDefined:
+				return v
+			}
+			v, ok := env[name]
+			if !ok && err == nil {
+				err = fmt.Errorf("undefined variable ${%s} in %s", name, arg)
+			}
+			return v
+		})
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(inner[last:offsets[i]])
+		b.WriteString(expanded)
+		last = offsets[i] + len(arg)
+	}
+	b.WriteString(inner[last:])
+	return "(" + b.String() + ")", nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEnv(t *testing.T) {
+	files := map[string][]byte{"v2/main.go": []byte("package main\n\n// ${NAME}\nfunc main() {}\n")}
+	urls := map[string][]byte{"https://example.com/org/repo/v2.1/main.go": []byte("package main\n")}
+	env := map[string]string{"DOCS_REF": "v2.1", "DIR": "v2", "CAPTION": "From v2"}
+	tc := []struct {
+		name, in, out, err string
+		env                map[string]string
+	}{
+		{name: "url",
+			env: env,
+			in:  "[embedmd]:# (https://example.com/org/repo/${DOCS_REF}/main.go)\n",
+			out: "[embedmd]:# (https://example.com/org/repo/${DOCS_REF}/main.go)\n```go\npackage main\n```\n"},
+		{name: "attributes",
+			env: env,
+			in:  "[embedmd]:# (main.go /func main/ basedir=${DIR} caption=\"${CAPTION}\")\n",
+			out: "[embedmd]:# (main.go /func main/ basedir=${DIR} caption=\"${CAPTION}\")\n<!-- embedmd block start -->\n*From v2*\n\n```go\nfunc main\n```\n<!-- embedmd block end -->\n"},
+		{name: "regular expressions",
+			env: env,
+			in:  "[embedmd]:# (${DIR}/main.go /\\${NAME}/)\n",
+			out: "[embedmd]:# (${DIR}/main.go /\\${NAME}/)\n```go\n${NAME}\n```\n"},
+		{name: "escaped",
+			env: env,
+			in:  "[embedmd]:# (${DIR}/main.go lang=$${DIR})\n",
+			out: "[embedmd]:# (${DIR}/main.go lang=$${DIR})\n```${DIR}\npackage main\n\n// ${NAME}\nfunc main() {}\n```\n"},
+		{name: "undefined",
+			env: env,
+			in:  "[embedmd]:# (${VERSION}/main.go)\n",
+			err: "1: undefined variable ${VERSION} in ${VERSION}/main.go"},
+		{name: "without variables",
+			in:  "[embedmd]:# (${DIR}/main.go)\n",
+			err: "1: could not read ${DIR}/main.go: file does not exist"},
+		{name: "defined",
+			in:  "[embedmd]:# (define SRC=v2)\n\n[embedmd]:# ($SRC/main.go /func main/)\n[embedmd]:# (${SRC}/main.go /func main/)\n",
+			out: "[embedmd]:# (define SRC=v2)\n\n[embedmd]:# ($SRC/main.go /func main/)\n[embedmd]:# (${SRC}/main.go /func main/)\n```go\nfunc main\nfunc main\n```\n"},
+		{name: "defined from others",
+			env: env,
+			in:  "[embedmd]:# (define BASE=\"https://example.com/org/repo\")\n[embedmd]:# (define URL=${BASE}/${DOCS_REF})\n[embedmd]:# ($URL/main.go)\n",
+			out: "[embedmd]:# (define BASE=\"https://example.com/org/repo\")\n[embedmd]:# (define URL=${BASE}/${DOCS_REF})\n[embedmd]:# ($URL/main.go)\n```go\npackage main\n```\n"},
+		{name: "other references kept",
+			in:  "[embedmd]:# (define SRC=v2)\n[embedmd]:# ($SRCDIR/main.go)\n",
+			err: "2: could not read $SRCDIR/main.go: file does not exist"},
+		{name: "defined after",
+			in:  "[embedmd]:# ($SRC/main.go)\n\n[embedmd]:# (define SRC=v2)\n",
+			err: "1: could not read $SRC/main.go: file does not exist"},
+		{name: "bad definition",
+			in:  "[embedmd]:# (define SRC)\n",
+			err: "1: define takes a single NAME=value"},
+		{name: "bad name",
+			in:  "[embedmd]:# (define 2SRC=v2)\n",
+			err: "1: variable name should be made of letters, digits, and '_', not starting with a digit, got \"2SRC\""},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		opts := []Option{WithFetcher(mixedContentProvider{files, urls})}
+		if tt.env != nil {
+			opts = append(opts, WithEnv(tt.env))
+		}
+		err := Process(&out, strings.NewReader(tt.in), opts...)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
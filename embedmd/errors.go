@@ -0,0 +1,128 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// An ErrorKind classifies the errors of commands, so that mistakes in
+// documents can be told from sources that can't be read.
+type ErrorKind int
+
+const (
+	// CommandError is a command that fails to embed its source, as when
+	// its regular expressions match nothing.
+	CommandError ErrorKind = iota
+	// ParseError is a command that can't be parsed, as when it has an
+	// unknown attribute.
+	ParseError
+	// FetchError is a command whose source can't be read or fetched.
+	FetchError
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ParseError:
+		return "parse error"
+	case FetchError:
+		return "fetch error"
+	}
+	return "command error"
+}
+
+// An Error is the error of the command at a line of a document, numbered
+// from 1.
+type Error struct {
+	Line int
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("%d: %v", e.Line, e.Err) }
+func (e *Error) Unwrap() error { return e.Err }
+
+// Errors are the errors of the commands of a document, in its order, as
+// returned with WithKeepGoing.
+type Errors []*Error
+
+func (errs Errors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (errs Errors) Unwrap() []error {
+	unwrapped := make([]error, len(errs))
+	for i, err := range errs {
+		unwrapped[i] = err
+	}
+	return unwrapped
+}
+
+// WithKeepGoing runs all the commands of a document, even when some of them
+// can't be parsed or fail: their blocks are kept as they are, and Process
+// returns the errors of all of them as Errors once the document is read.
+func WithKeepGoing() Option {
+	return Option{func(e *embedder) { e.keepGoing = true }}
+}
+
+// parseError is the error parsing a command, after which a document can be
+// read on.
+type parseError struct{ err error }
+
+func (e *parseError) Error() string { return e.err.Error() }
+func (e *parseError) Unwrap() error { return e.err }
+
+// collect records the error of the command at line.
+func (e *embedder) collect(line int, err error) {
+	kind := CommandError
+	if le := (*lineError)(nil); errors.As(err, &le) {
+		line, err = le.line, le.err
+	}
+	var fe *fetchError
+	var pe *parseError
+	switch {
+	case errors.As(err, &pe):
+		kind, err = ParseError, pe.err
+	case errors.As(err, &fe):
+		kind = FetchError
+	}
+	e.errs = append(e.errs, &Error{Line: line, Kind: kind, Err: err})
+}
+
+// keepGoingRunner returns a runner running the commands with run, and
+// keeping the blocks of those that fail, whose errors are collected.
+func (e *embedder) keepGoingRunner(run commandRunner) commandRunner {
+	return func(w io.Writer, cmd *command) error {
+		// Nothing is written by the commands that fail.
+		var buf bytes.Buffer
+		if err := run(&buf, cmd); err != nil {
+			if e.context().Err() != nil {
+				// Canceled runs stop rather than failing every command.
+				return err
+			}
+			e.collect(cmd.line, err)
+			return keepBlock(w, cmd)
+		}
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+}
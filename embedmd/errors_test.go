@@ -0,0 +1,84 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestKeepGoing(t *testing.T) {
+	p := mixedContentProvider{files: map[string][]byte{"code.go": []byte(content)}}
+	in := "[embedmd]:# (code.go /func main/ $)\n\n" +
+		"[embedmd]:# (code.go captoin=x)\n" +
+		"```go\nold\n```\n" +
+		"[embedmd]:# (missing.go)\n\n" +
+		"[embedmd]:# (code.go /nothing/)\n" +
+		"```go\nkept\n```\n" +
+		"[embedmd]:# (code.go /func main/ $)\n"
+	fenced := "```go\nfunc main() {\n        fmt.Println(\"hello, test\")\n}\n```\n"
+	want := "[embedmd]:# (code.go /func main/ $)\n" + fenced + "\n" +
+		"[embedmd]:# (code.go captoin=x)\n" +
+		"```go\nold\n```\n" +
+		"[embedmd]:# (missing.go)\n\n" +
+		"[embedmd]:# (code.go /nothing/)\n" +
+		"```go\nkept\n```\n" +
+		"[embedmd]:# (code.go /func main/ $)\n" + fenced
+
+	var out bytes.Buffer
+	err := Process(&out, strings.NewReader(in), WithFetcher(p), WithKeepGoing())
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected Errors, got %v", err)
+	}
+	type result struct {
+		line int
+		kind ErrorKind
+		msg  string
+	}
+	var got []result
+	for _, e := range errs {
+		got = append(got, result{e.Line, e.Kind, e.Err.Error()})
+	}
+	expected := []result{
+		{3, ParseError, `unknown attribute "captoin"`},
+		{7, FetchError, "could not read missing.go: file does not exist"},
+		{9, CommandError, "could not extract content from code.go: could not match \"/nothing/\""},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected errors %v; got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("error %d: expected %v; got %v", i, expected[i], got[i])
+		}
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, "3: unknown attribute \"captoin\"\n7: ") {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if out.String() != want {
+		t.Errorf("expected output\n%q\ngot\n%q", want, out.String())
+	}
+
+	// Without it, the first error stops the run.
+	err = Process(&out, strings.NewReader(in), WithFetcher(p))
+	if !eqErr(t, "first error", err, `3: unknown attribute "captoin"`) {
+		return
+	}
+	if errors.As(err, &errs) {
+		t.Errorf("unexpected Errors %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// execScheme prefixes the command lines of the programs whose output is
+// embedded, such as cmd:"kubectl explain deployment".
+const execScheme = "cmd:"
+
+func isExecPath(path string) bool { return strings.HasPrefix(path, execScheme) }
+
+// WithExec allows commands to embed the output of the programs whose command
+// line starts with the words of one of the given ones, e.g. "kubectl explain"
+// allows cmd:"kubectl explain deployment". The program of the command must be
+// the same file as the allowed one, looked up in PATH, or relative to the
+// base directory when its name has a slash. Other programs aren't run, nor
+// any without WithExec.
+func WithExec(allowed ...string) Option {
+	return Option{func(e *embedder) { e.allowedExec = append(e.allowedExec, allowed...) }}
+}
+
+// WithExecEnv passes the given environment variables to the programs run by
+// commands, besides those such as PATH and HOME that they always get. Others,
+// such as the tokens of forges and cloud credentials, are removed.
+func WithExecEnv(names ...string) Option {
+	return Option{func(e *embedder) { e.execEnv = append(e.execEnv, names...) }}
+}
+
+// WithExecLimits stops the programs run by commands after timeout, and fails
+// the commands whose program writes more than maxOutput bytes, instead of
+// the defaults of a minute and 1 MiB. Zero values keep the defaults.
+func WithExecLimits(timeout time.Duration, maxOutput int64) Option {
+	return Option{func(e *embedder) { e.execTimeout, e.execMaxOutput = timeout, maxOutput }}
+}
+
+// WithExecDirs allows commands to run programs only in base directories
+// under one of dirs, instead of under the current directory.
+func WithExecDirs(dirs ...string) Option {
+	return Option{func(e *embedder) { e.execDirs = append(e.execDirs, dirs...) }}
+}
+
+// WithExecNetwork lets the programs run by commands use the network. They
+// are otherwise run in a network namespace of their own, holding only a
+// loopback interface, which is only possible on Linux: elsewhere, commands
+// fail without WithExecNetwork.
+func WithExecNetwork() Option {
+	return Option{func(e *embedder) { e.execNetwork = true }}
+}
+
+// WithExecResources limits the CPU time and the memory, in bytes, of the
+// programs run by commands, instead of the timeout and 2 GiB. Zero values
+// keep the defaults. The limits are set with ulimit, so they aren't on
+// Windows.
+func WithExecResources(cpu time.Duration, memory int64) Option {
+	return Option{func(e *embedder) { e.execCPU, e.execMemory = cpu, memory }}
+}
+
+// The limits of the programs run by commands without WithExecLimits and
+// WithExecResources.
+const (
+	defaultExecTimeout   = time.Minute
+	defaultExecMaxOutput = 1 << 20
+	defaultExecMemory    = 2 << 30
+)
+
+// defaultExecEnv lists the environment variables programs are run with.
+var defaultExecEnv = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "LANG", "LC_ALL", "LC_CTYPE", "LC_MESSAGES",
+	"TZ", "TMPDIR", "TEMP", "TMP", "SYSTEMROOT", "PATHEXT", "COMSPEC",
+}
+
+// fetch returns the source at path: the output of the program for cmd:
+// paths, the file of the gist for gist pages, and the content fetched
+// otherwise.
+func (e *embedder) fetch(path string) ([]byte, error) {
+	if isExecPath(path) {
+		return e.exec(path)
+	}
+	if g, ok := parseGist(path); ok {
+		return e.fetchGist(g)
+	}
+	return FetchContext(e.context(), e.Fetcher, e.baseDir, e.rawURL(path))
+}
+
+// exec runs the program of the cmd: path, in the base directory, returning
+// its standard output, which is also written to the workspace if set.
+func (e *embedder) exec(path string) ([]byte, error) {
+	args, err := execArgs(path)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := execProgram(e.baseDir, args[0])
+	if err != nil || !slices.ContainsFunc(e.allowedExec, func(a string) bool {
+		prefix := strings.Fields(a)
+		if len(prefix) == 0 || len(prefix) > len(args) || !slices.Equal(prefix[1:], args[1:len(prefix)]) {
+			return false
+		}
+		allowed, err := execProgram(e.baseDir, prefix[0])
+		return err == nil && allowed == prog
+	}) {
+		return nil, fmt.Errorf("%s is not in the allowed commands", strings.Join(args, " "))
+	}
+	dirs := e.execDirs
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	if !slices.ContainsFunc(dirs, func(d string) bool { return inRoot(d, "", cmp.Or(e.baseDir, ".")) }) {
+		return nil, fmt.Errorf("%s cannot run in %s, which is not under the allowed directories", args[0], cmp.Or(e.baseDir, "."))
+	}
+
+	timeout := cmp.Or(e.execTimeout, defaultExecTimeout)
+	ctx, cancel := context.WithTimeout(e.context(), timeout)
+	defer cancel()
+	c := exec.CommandContext(ctx, prog, args[1:]...)
+	c.Dir = e.baseDir
+	c.Env = e.execEnviron()
+	limitResources(c, cmp.Or(e.execCPU, timeout), cmp.Or(e.execMemory, defaultExecMemory))
+	if !e.execNetwork {
+		if err := isolateNetwork(c); err != nil {
+			return nil, fmt.Errorf("cannot run %s: %v", args[0], err)
+		}
+	}
+	// Programs leaving children holding their output don't block the run.
+	c.WaitDelay = time.Second
+	limit := cmp.Or(e.execMaxOutput, defaultExecMaxOutput)
+	stdout, stderr := &limitedBuffer{max: limit}, &limitedBuffer{max: limit}
+	c.Stdout, c.Stderr = stdout, stderr
+	if err := c.Start(); err != nil {
+		if !e.execNetwork {
+			return nil, fmt.Errorf("cannot run %s without the network: %v", args[0], err)
+		}
+		return nil, fmt.Errorf("cannot run %s: %v", args[0], err)
+	}
+	err = c.Wait()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded && e.context().Err() == nil:
+		return nil, fmt.Errorf("%s timed out after %v", args[0], timeout)
+	case err != nil:
+		if msg := strings.TrimSpace(stderr.buf.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("%s failed: %v", args[0], err)
+	case stdout.exceeded:
+		return nil, fmt.Errorf("output of %s is more than %d bytes", args[0], limit)
+	}
+	if e.workspace != nil {
+		// The output is staged in the workspace, counting towards its limit,
+		// and left there for debugging when the workspace is kept.
+		name := fmt.Sprintf("exec/%s-%x.out", filepath.Base(prog), sha256.Sum256([]byte(e.baseDir+"\x00"+path)))
+		if _, err := e.workspace.WriteFile(name, stdout.buf.Bytes()); err != nil {
+			return nil, fmt.Errorf("could not stage output of %s: %v", args[0], err)
+		}
+	}
+	return stdout.buf.Bytes(), nil
+}
+
+// execProgram returns the absolute path of the program name, looked up in
+// PATH, or relative to dir if it has a slash.
+func execProgram(dir, name string) (string, error) {
+	if strings.ContainsAny(name, `/\`) && !filepath.IsAbs(name) {
+		name = filepath.Join(dir, name)
+	}
+	p, err := exec.LookPath(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(p)
+}
+
+// limitResources makes c run its program through sh, which limits its CPU
+// time, in seconds, and its data segment, in KiB, before replacing itself
+// with the program. Past the soft CPU limit, programs get SIGXCPU, and a
+// second later SIGKILL. Windows has no such limits.
+func limitResources(c *exec.Cmd, cpu time.Duration, memory int64) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	secs := int64(math.Ceil(cpu.Seconds()))
+	script := fmt.Sprintf(`ulimit -S -t %d && ulimit -H -t %d && ulimit -d %d && exec "$0" "$@"`, secs, secs+1, max(memory>>10, 1))
+	c.Path, c.Args = "/bin/sh", append([]string{"sh", "-c", script}, c.Args...)
+}
+
+// execEnviron returns the environment programs are run with: the variables
+// of defaultExecEnv and those set with WithExecEnv.
+func (e *embedder) execEnviron() []string {
+	// An empty environment, rather than nil, which is that of embedmd.
+	env := []string{}
+	for _, name := range slices.Concat(defaultExecEnv, e.execEnv) {
+		if v, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+v)
+		}
+	}
+	return env
+}
+
+// limitedBuffer keeps up to max bytes of what is written to it, discarding
+// the rest, so programs writing too much can still be told apart from those
+// failing. The buffer isn't embedded, so io.Copy can't bypass Write with
+// its ReadFrom.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	max      int64
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - int64(b.buf.Len()); int64(len(p)) > room {
+		b.buf.Write(p[:max(room, 0)])
+		b.exceeded = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// execArgs returns the arguments of the command line of the cmd: path, which
+// is quoted when it has blanks. Arguments with blanks are in single quotes.
+func execArgs(path string) ([]string, error) {
+	line := strings.TrimPrefix(path, execScheme)
+	if uq, err := strconv.Unquote(line); err == nil {
+		line = uq
+	}
+	var args []string
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimSpace(line) {
+		if line[0] != '\'' {
+			i := strings.IndexAny(line, " \t")
+			if i < 0 {
+				i = len(line)
+			}
+			args, line = append(args, line[:i]), line[i:]
+			continue
+		}
+		end := strings.IndexByte(line[1:], '\'')
+		if end < 0 {
+			return nil, fmt.Errorf("unbalanced ' in %s", path)
+		}
+		args, line = append(args, line[1:end+1]), line[end+2:]
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing command line in %s", path)
+	}
+	return args, nil
+}
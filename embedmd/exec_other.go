@@ -0,0 +1,27 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package embedmd
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// isolateNetwork fails, since programs can only be cut from the network on
+// Linux.
+func isolateNetwork(c *exec.Cmd) error {
+	return errors.New("programs can only be run without the network on Linux, allow it with WithExecNetwork")
+}
@@ -0,0 +1,182 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExec(t *testing.T) {
+	tc := []struct {
+		name, in, out, err string
+		allowed            []string
+	}{
+		{name: "output",
+			in:      "[embedmd]:# (cmd:\"echo hello world\")\n",
+			allowed: []string{"echo hello"},
+			out:     "[embedmd]:# (cmd:\"echo hello world\")\n```text\nhello world\n```\n"},
+		{name: "lang attribute",
+			in:      "[embedmd]:# (cmd:\"echo '{}'\" lang=json)\n",
+			allowed: []string{"echo"},
+			out:     "[embedmd]:# (cmd:\"echo '{}'\" lang=json)\n```json\n{}\n```\n"},
+		{name: "language argument",
+			in:      "[embedmd]:# (cmd:echo sh)\n",
+			allowed: []string{"echo"},
+			out:     "[embedmd]:# (cmd:echo sh)\n```sh\n\n```\n"},
+		{name: "not allowed",
+			in:      "[embedmd]:# (cmd:\"echo hello world\")\n",
+			allowed: []string{"echo hello world again"},
+			err:     "1: could not read cmd:\"echo hello world\": echo hello world is not in the allowed commands"},
+		{name: "not enabled",
+			in:  "[embedmd]:# (cmd:\"echo hello\")\n",
+			err: "1: could not read cmd:\"echo hello\": echo hello is not in the allowed commands"},
+		{name: "failing",
+			in:      "[embedmd]:# (cmd:false)\n",
+			allowed: []string{"false"},
+			err:     "1: could not read cmd:false: false failed: exit status 1"},
+		{name: "both languages",
+			in:  "[embedmd]:# (cmd:echo sh lang=text)\n",
+			err: "1: cannot set the language both as an argument and with lang"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithExec(tt.allowed...))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
+
+func TestExecArgs(t *testing.T) {
+	tc := []struct {
+		in   string
+		args []string
+		err  string
+	}{
+		{in: "cmd:ls", args: []string{"ls"}},
+		{in: `cmd:"kubectl explain  deployment"`, args: []string{"kubectl", "explain", "deployment"}},
+		{in: `cmd:"grep -n 'a b' file.go"`, args: []string{"grep", "-n", "a b", "file.go"}},
+		{in: `cmd:"echo ''"`, args: []string{"echo", ""}},
+		{in: `cmd:"echo 'a"`, err: `unbalanced ' in cmd:"echo 'a"`},
+		{in: `cmd:""`, err: `missing command line in cmd:""`},
+	}
+	for _, tt := range tc {
+		args, err := execArgs(tt.in)
+		if !eqErr(t, tt.in, err, tt.err) {
+			continue
+		}
+		if !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("case [%s]: expected arguments %q; got %q", tt.in, tt.args, args)
+		}
+	}
+}
+
+func TestExecSandbox(t *testing.T) {
+	t.Setenv("EMBEDMD_TEST_SECRET", "secret")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "echo"), []byte("#!/bin/sh\necho fake\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tc := []struct {
+		name, in, out, err string
+		opts               []Option
+	}{
+		{name: "environment removed",
+			in:   "[embedmd]:# (cmd:\"printenv EMBEDMD_TEST_SECRET\")\n",
+			opts: []Option{WithExec("printenv")},
+			err:  "1: could not read cmd:\"printenv EMBEDMD_TEST_SECRET\": printenv failed: exit status 1"},
+		{name: "environment passed",
+			in:   "[embedmd]:# (cmd:\"printenv EMBEDMD_TEST_SECRET\")\n",
+			opts: []Option{WithExec("printenv"), WithExecEnv("EMBEDMD_TEST_SECRET")},
+			out:  "[embedmd]:# (cmd:\"printenv EMBEDMD_TEST_SECRET\")\n```text\nsecret\n```\n"},
+		{name: "timeout",
+			in:   "[embedmd]:# (cmd:\"sleep 5\")\n",
+			opts: []Option{WithExec("sleep"), WithExecLimits(50*time.Millisecond, 0)},
+			err:  "1: could not read cmd:\"sleep 5\": sleep timed out after 50ms"},
+		{name: "output too long",
+			in:   "[embedmd]:# (cmd:\"echo hello world\")\n",
+			opts: []Option{WithExec("echo"), WithExecLimits(0, 5)},
+			err:  "1: could not read cmd:\"echo hello world\": output of echo is more than 5 bytes"},
+		{name: "other program of the same name",
+			in:   "[embedmd]:# (cmd:./echo)\n",
+			opts: []Option{WithExec("echo"), WithBaseDir(dir)},
+			err:  "1: could not read cmd:./echo: ./echo is not in the allowed commands"},
+		{name: "program relative to the base directory",
+			in:   "[embedmd]:# (cmd:./echo)\n",
+			opts: []Option{WithExec("./echo"), WithBaseDir(dir), WithExecDirs(dir)},
+			out:  "[embedmd]:# (cmd:./echo)\n```text\nfake\n```\n"},
+		{name: "directory not allowed",
+			in:   "[embedmd]:# (cmd:./echo)\n",
+			opts: []Option{WithExec("./echo"), WithBaseDir(dir)},
+			err:  "1: could not read cmd:./echo: ./echo cannot run in " + dir + ", which is not under the allowed directories"},
+		{name: "CPU time limited",
+			in:   "[embedmd]:# (cmd:\"sh -c 'while :; do :; done'\")\n",
+			opts: []Option{WithExec("sh"), WithExecResources(time.Second, 0), WithExecLimits(10*time.Second, 0)},
+			err:  "1: could not read cmd:\"sh -c 'while :; do :; done'\": sh failed: signal: CPU time limit exceeded"},
+	}
+	if runtime.GOOS == "linux" {
+		// Without the network, the loopback interface is the only one.
+		tc = append(tc, struct {
+			name, in, out, err string
+			opts               []Option
+		}{name: "network removed",
+			in:   "[embedmd]:# (cmd:\"grep -c : /proc/net/dev\")\n",
+			opts: []Option{WithExec("grep")},
+			out:  "[embedmd]:# (cmd:\"grep -c : /proc/net/dev\")\n```text\n1\n```\n"})
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), tt.opts...)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
+
+func TestExecWorkspace(t *testing.T) {
+	in := "[embedmd]:# (cmd:\"echo hello\")\n"
+	ws := &Workspace{}
+	defer ws.Close()
+	var out bytes.Buffer
+	if err := Process(&out, strings.NewReader(in), WithExec("echo"), WithWorkspace(ws)); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ws.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	staged, err := filepath.Glob(filepath.Join(dir, "exec", "echo-*.out"))
+	if err != nil || len(staged) != 1 {
+		t.Fatalf("expected the output of echo in the workspace, found %q (%v)", staged, err)
+	}
+	if b, err := os.ReadFile(staged[0]); err != nil || string(b) != "hello\n" {
+		t.Errorf("expected the staged output %q; got %q (%v)", "hello\n", b, err)
+	}
+
+	err = Process(&out, strings.NewReader(in), WithExec("echo"), WithWorkspace(&Workspace{MaxBytes: 3}))
+	eqErr(t, "over the workspace limit", err, `1: could not read cmd:"echo hello": could not stage output of echo: writing exec/`+filepath.Base(staged[0])+` would exceed the workspace limit of 3 bytes`)
+}
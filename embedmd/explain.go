@@ -0,0 +1,233 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// An Explanation describes what a command selects in its source.
+type Explanation struct {
+	// Path is the path or URL of the source, resolved as when embedding.
+	Path string
+	// Source is the content of the source, with \n line endings.
+	Source []byte
+	// Matches are those of the regular expressions of the command, in
+	// order. The end one is missing when the start one matches nothing.
+	Matches []Match
+	// FromLine and ToLine are the first and last lines of the source
+	// selected, numbered from 1, or zero if nothing is.
+	FromLine, ToLine int
+	// Content is the content embedded, once the attributes of the command
+	// are applied, and Err the error embedding it, if any.
+	Content []byte
+	Err     error
+}
+
+// A Match describes what a regular expression of a command matches in the
+// source.
+type Match struct {
+	// Expr is the regular expression, between slashes.
+	Expr string
+	// From is the offset of the source it's searched from.
+	From int
+	// Start and End are the offsets of its first match, which is the one
+	// used unless every match is embedded, or -1 if it matches nothing.
+	Start, End int
+	// Lines are the lines of its matches, numbered from 1.
+	Lines []int
+	// Hints tell why it matches nothing, when it doesn't.
+	Hints []string
+}
+
+// Explain describes what the command with the argument list args, as in
+// (file.go /start/ /end/), or the command line holding it, selects in its
+// source. It fails if the command is malformed or its source can't be
+// fetched, while errors selecting the content are those of the explanation.
+func Explain(args string, opts ...Option) (*Explanation, error) {
+	e, _, err := newEmbedder(strings.NewReader(""), opts)
+	if err != nil {
+		return nil, err
+	}
+	syntaxes := e.syntaxes
+	if len(syntaxes) == 0 {
+		syntaxes = []Syntax{LinkSyntax}
+	}
+	args = strings.TrimSpace(args)
+	if a, ok := commandArgs(args, syntaxes); ok {
+		args = strings.TrimSpace(a)
+	}
+	if !strings.HasPrefix(args, "(") {
+		args = "(" + args + ")"
+	}
+	if args, err = expandEnv(args, e.env, nil); err != nil {
+		return nil, err
+	}
+	parse := func() (*command, error) {
+		cmd, err := parseCommand(args)
+		switch {
+		case err != nil:
+			return nil, err
+		case cmd.dirDirective:
+			return nil, errors.New("basedir directives select nothing")
+		case cmd.varDirective:
+			return nil, errors.New("define directives select nothing")
+		case len(cmd.parts) > 0:
+			return nil, errors.New("cannot explain regions stitched with +, explain each one")
+		}
+		cmd.line, cmd.args = 1, args[1:len(args)-1]
+		return cmd, nil
+	}
+
+	cmd, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	if err := e.resolvePath(cmd); err != nil {
+		return nil, err
+	}
+	if cmd.path, err = e.expandAlias(cmd.path); err != nil {
+		return nil, err
+	}
+	if err := e.applyDefaults(cmd); err != nil {
+		return nil, err
+	}
+	src, err := e.fetch(cmd.path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", cmd.path, err)
+	}
+	src = bytes.ReplaceAll(src, []byte("\r\n"), []byte("\n"))
+	x := &Explanation{Path: cmd.path, Source: src, Matches: explainMatches(cmd, src)}
+	if region, err := extractContent(cmd, src); err == nil {
+		r := regionLines(src, region)
+		x.FromLine, x.ToLine = r[0], r[1]
+	}
+
+	// Embedding resolves the path of the command again.
+	full, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	x.Content, x.Err = e.embedded(full, full)
+	return x, nil
+}
+
+// explainMatches returns the matches of the regular expressions of cmd in
+// src.
+func explainMatches(cmd *command, src []byte) []Match {
+	if cmd.start == nil {
+		return nil
+	}
+	var matches []Match
+	from := 0
+	if *cmd.start != "" {
+		start := explainExpr(*cmd.start, src, 0)
+		matches = append(matches, start)
+		if start.Start < 0 {
+			return matches
+		}
+		from = start.Start
+		if cmd.splice {
+			// The end of a section is searched after its first line.
+			if i := bytes.IndexByte(src[from:], '\n'); i >= 0 {
+				from += i + 1
+			}
+		}
+	}
+	if cmd.end != nil && *cmd.end != "$" {
+		matches = append(matches, explainExpr(*cmd.end, src, from))
+	}
+	return matches
+}
+
+// explainExpr returns the matches of the regular expression expr in src
+// after from.
+func explainExpr(expr string, src []byte, from int) Match {
+	m := Match{Expr: expr, From: from, Start: -1, End: -1}
+	re, err := compileSlashed(expr)
+	if err != nil {
+		m.Hints = append(m.Hints, err.Error())
+		if len(expr) <= 2 || expr[0] != '/' || expr[len(expr)-1] != '/' {
+			return m
+		}
+		if _, perr := regexp.Compile(expr[1 : len(expr)-1]); perr == nil {
+			m.Hints = append(m.Hints, `commands use POSIX regular expressions, where \d, \s, and \w are written [[:digit:]], [[:space:]], and [[:alnum:]_]`)
+		}
+		return m
+	}
+	for _, loc := range re.FindAllIndex(src[from:], -1) {
+		if m.Start < 0 {
+			m.Start, m.End = from+loc[0], from+loc[1]
+		}
+		m.Lines = append(m.Lines, lineOf(src, from+loc[0]))
+	}
+	if m.Start < 0 {
+		m.Hints = whyNoMatch(expr[1:len(expr)-1], re, src, from)
+	}
+	return m
+}
+
+// whyNoMatch returns hints telling why the regular expression re, written
+// expr, matches nothing in src after from.
+func whyNoMatch(expr string, re *regexp.Regexp, src []byte, from int) []string {
+	var hints []string
+	if loc := re.FindIndex(src[:from]); from > 0 && loc != nil {
+		hints = append(hints, fmt.Sprintf("it matches before the start, on line %d, but is searched after it", lineOf(src, loc[0])))
+	}
+	if re, err := regexp.Compile("(?i)" + expr); err == nil {
+		if loc := re.FindIndex(src[from:]); loc != nil {
+			hints = append(hints, fmt.Sprintf("it matches ignoring case on line %d: %s", lineOf(src, from+loc[0]), lineText(src, from+loc[0])))
+		}
+	}
+	if strings.Contains(expr, " ") {
+		if re, err := regexp.CompilePOSIX(strings.ReplaceAll(expr, " ", "[[:blank:]]+")); err == nil {
+			if loc := re.FindIndex(src[from:]); loc != nil {
+				hints = append(hints, fmt.Sprintf("it matches with other blanks on line %d: %s", lineOf(src, from+loc[0]), lineText(src, from+loc[0])))
+			}
+		}
+	}
+	// The longest start of the expression matching tells where it stops
+	// matching the source.
+	for n := len(expr) - 1; n >= 3; n-- {
+		re, err := regexp.CompilePOSIX(expr[:n])
+		if err != nil {
+			continue
+		}
+		if loc := re.FindIndex(src[from:]); loc != nil {
+			hints = append(hints, fmt.Sprintf("only its start /%s/ matches, on line %d: %s", expr[:n], lineOf(src, from+loc[0]), lineText(src, from+loc[0])))
+			break
+		}
+	}
+	return hints
+}
+
+// lineOf returns the line of the offset i of src, numbered from 1.
+func lineOf(src []byte, i int) int {
+	return bytes.Count(src[:i], []byte("\n")) + 1
+}
+
+// lineText returns the text of the line holding the offset i of src,
+// without its surrounding blanks.
+func lineText(src []byte, i int) string {
+	start := bytes.LastIndexByte(src[:i], '\n') + 1
+	end := len(src)
+	if j := bytes.IndexByte(src[i:], '\n'); j >= 0 {
+		end = i + j
+	}
+	return strings.TrimSpace(string(src[start:end]))
+}
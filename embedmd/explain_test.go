@@ -0,0 +1,101 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	files := map[string][]byte{"docs/code.go": []byte("package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n")}
+	tc := []struct {
+		name, args        string
+		matches           []Match
+		from, to          int
+		content, embedErr string
+		err               string
+	}{
+		{name: "regions",
+			args: "(code.go /func main/ /^}/)",
+			matches: []Match{
+				{Expr: "/func main/", Start: 28, End: 37, Lines: []int{5}},
+				{Expr: "/^}/", From: 28, Start: 61, End: 62, Lines: []int{7}},
+			},
+			from: 5, to: 7, content: "func main() {\n\tfmt.Println(\"hi\")\n}\n"},
+		{name: "command line",
+			args: "[embedmd]:# (code.go /fmt/)",
+			matches: []Match{
+				{Expr: "/fmt/", Start: 22, End: 25, Lines: []int{3, 6}},
+			},
+			from: 3, to: 3, content: "fmt\n"},
+		{name: "no match",
+			args: "code.go /func main/ /^ }/",
+			matches: []Match{
+				{Expr: "/func main/", Start: 28, End: 37, Lines: []int{5}},
+				{Expr: "/^ }/", From: 28, Start: -1, End: -1},
+			},
+			embedErr: "could not extract content from code.go: could not match \"/^ }/\""},
+		{name: "case",
+			args: "(code.go /Func main/ $)",
+			matches: []Match{
+				{Expr: "/Func main/", Start: -1, End: -1, Hints: []string{"it matches ignoring case on line 5: func main() {"}},
+			},
+			embedErr: "could not extract content from code.go: could not match \"/Func main/\""},
+		{name: "start",
+			args: "(code.go /fmt.Printf/)",
+			matches: []Match{
+				{Expr: "/fmt.Printf/", Start: -1, End: -1, Hints: []string{"only its start /fmt.Print/ matches, on line 6: fmt.Println(\"hi\")"}},
+			},
+			embedErr: "could not extract content from code.go: could not match \"/fmt.Printf/\""},
+		{name: "before the start",
+			args: "(code.go /func/ /import/)",
+			matches: []Match{
+				{Expr: "/func/", Start: 28, End: 32, Lines: []int{5}},
+				{Expr: "/import/", From: 28, Start: -1, End: -1, Hints: []string{"it matches before the start, on line 3, but is searched after it"}},
+			},
+			embedErr: "could not extract content from code.go: could not match \"/import/\""},
+		{name: "perl",
+			args: `(code.go /\s+fmt/)`,
+			matches: []Match{
+				{Expr: `/\s+fmt/`, Start: -1, End: -1, Hints: []string{
+					"error parsing regexp: invalid escape sequence: `\\s`",
+					`commands use POSIX regular expressions, where \d, \s, and \w are written [[:digit:]], [[:space:]], and [[:alnum:]_]`,
+				}},
+			},
+			embedErr: "could not extract content from code.go: error parsing regexp: invalid escape sequence: `\\s`"},
+		{name: "stitched",
+			args: "(code.go /fmt/ + code.go /main/)",
+			err:  "cannot explain regions stitched with +, explain each one"},
+		{name: "missing source",
+			args: "(missing.go)",
+			err:  "could not read missing.go: file does not exist"},
+	}
+	for _, tt := range tc {
+		x, err := Explain(tt.args, WithBaseDir("docs"), WithFetcher(mixedContentProvider{files: files}))
+		if !eqErr(t, tt.name, err, tt.err) || err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(x.Matches, tt.matches) {
+			t.Errorf("case [%s]: expected matches\n%+v\ngot\n%+v", tt.name, tt.matches, x.Matches)
+		}
+		if x.FromLine != tt.from || x.ToLine != tt.to {
+			t.Errorf("case [%s]: expected lines %d-%d; got %d-%d", tt.name, tt.from, tt.to, x.FromLine, x.ToLine)
+		}
+		eqErr(t, tt.name, x.Err, tt.embedErr)
+		if string(x.Content) != tt.content {
+			t.Errorf("case [%s]: expected content %q; got %q", tt.name, tt.content, x.Content)
+		}
+	}
+}
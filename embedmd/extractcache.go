@@ -0,0 +1,172 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// An ExtractCache keeps the code extracted by commands from local files in a
+// directory, keyed by the path of the file and the arguments of the command,
+// so later runs reuse it without reading the file again while its
+// modification time and size are unchanged, or without extracting it again
+// while its content is.
+type ExtractCache struct {
+	// Dir is the directory of the cache, created if needed.
+	Dir string
+	// Refresh extracts the code of every command again, replacing the
+	// entries of the cache, as after changes to the options.
+	Refresh bool
+}
+
+// DefaultExtractCacheDir returns the directory of the extract cache in the
+// user cache directory.
+func DefaultExtractCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "embedmd", "extract"), nil
+}
+
+// WithExtractCache caches the code extracted from local files in c. Sources
+// fetched from URLs, programs, gists, repositories, and archives aren't
+// cached, nor are they when anchors are linted or an AfterEmbed hook is set,
+// as both need the whole source.
+func WithExtractCache(c *ExtractCache) Option {
+	return Option{func(e *embedder) { e.extractCache = c }}
+}
+
+// extractEntry is the code cached for a command.
+type extractEntry struct {
+	Key     string    `json:"key"`
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	// Hash is the SHA-256 hash of the source, as read.
+	Hash       string  `json:"hash"`
+	Lang       string  `json:"lang,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Region     [2]int  `json:"region"`
+	Content    []byte  `json:"content"`
+}
+
+// extractSlot is the place of a command in the extract cache, with the file
+// of its source and the entry cached for it, if any.
+type extractSlot struct {
+	cache *ExtractCache
+	key   string
+	info  fs.FileInfo
+	entry *extractEntry
+}
+
+// extractSlot returns the slot of cmd in the extract cache, or nil if its
+// code isn't cached.
+func (e *embedder) extractSlot(cmd *command) *extractSlot {
+	if e.extractCache == nil || e.anchorLint || e.afterEmbed != nil || cmd.selectWith != nil ||
+		isURL(cmd.path) || isExecPath(cmd.path) || isGitPath(cmd.path) || isRepoPath(cmd.path) {
+		return nil
+	}
+	if _, ok := parseGist(cmd.path); ok {
+		return nil
+	}
+	if _, _, ok := cutArchive(cmd.path); ok {
+		return nil
+	}
+	path := filepath.FromSlash(cmd.path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(e.baseDir, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	s := &extractSlot{cache: e.extractCache, key: path + "\n" + cmd.lang + "\n" + cmd.args, info: info}
+	if !s.cache.Refresh {
+		s.entry = s.cache.lookup(s.key)
+	}
+	if s.entry != nil && cmd.lang == "" && (s.entry.Lang == "" || s.entry.Confidence < e.langConfidence) {
+		// The language is detected again, to fail as it should.
+		s.entry = nil
+	}
+	return s
+}
+
+// unchanged reports whether the source is unchanged since its code was
+// cached, as told by its modification time and size.
+func (s *extractSlot) unchanged() bool {
+	return s != nil && s.entry != nil && s.entry.ModTime.Equal(s.info.ModTime()) && s.entry.Size == s.info.Size()
+}
+
+// same reports whether the source with the given hash is the one whose code
+// was cached.
+func (s *extractSlot) same(hash string) bool {
+	return s != nil && s.entry != nil && s.entry.Hash == hash
+}
+
+// use sets the language and region of cmd from the entry of s, returning
+// the code cached.
+func (s *extractSlot) use(cmd *command) []byte {
+	if cmd.lang == "" {
+		cmd.lang = s.entry.Lang
+	}
+	cmd.region = s.entry.Region
+	return s.entry.Content
+}
+
+// add caches the code b extracted by cmd from the source with the given
+// hash.
+func (s *extractSlot) add(cmd *command, hash string, confidence float64, b []byte) error {
+	entry := extractEntry{
+		Key:     s.key,
+		ModTime: s.info.ModTime(),
+		Size:    s.info.Size(),
+		Hash:    hash,
+		Region:  cmd.region,
+		Content: b,
+	}
+	if confidence > 0 {
+		entry.Lang, entry.Confidence = cmd.lang, confidence
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(s.cache.entryPath(s.key), data, 0644)
+}
+
+func (c *ExtractCache) entryPath(key string) string {
+	hash := sha256Hex(key)
+	return filepath.Join(c.Dir, hash[:2], hash)
+}
+
+// lookup returns the entry cached for the key, if any.
+func (c *ExtractCache) lookup(key string) *extractEntry {
+	b, err := os.ReadFile(c.entryPath(key))
+	if err != nil {
+		return nil
+	}
+	var e extractEntry
+	if json.Unmarshal(b, &e) != nil || e.Key != key {
+		return nil
+	}
+	return &e
+}
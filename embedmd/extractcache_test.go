@@ -0,0 +1,81 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtractCache(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "code.go")
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	write := func(content string, mtime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(src, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(src, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache := &ExtractCache{Dir: t.TempDir()}
+	run := func(in, want string) {
+		t.Helper()
+		var out bytes.Buffer
+		if err := Process(&out, strings.NewReader(in), WithBaseDir(dir), WithExtractCache(cache)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := out.String(); got != in+"```go\n"+want+"```\n" {
+			t.Errorf("expected code %q; got output\n%s", want, got)
+		}
+	}
+	const cmd = "[embedmd]:# (code.go /func/ $)\n"
+
+	write("package main\n\nfunc a() {}\n", mtime)
+	run(cmd, "func a() {}\n")
+	// The same size and modification time tell that the source is unchanged,
+	// without reading it.
+	write("package main\n\nfunc b() {}\n", mtime)
+	run(cmd, "func a() {}\n")
+	// Other commands extract their code.
+	run("[embedmd]:# (code.go /package/ /main/)\n", "package main\n")
+
+	cache.Refresh = true
+	run(cmd, "func b() {}\n")
+	cache.Refresh = false
+
+	// A source only touched is read but its code isn't extracted again.
+	write("package main\n\nfunc b() {}\n", mtime.Add(time.Hour))
+	run(cmd, "func b() {}\n")
+	entry := cache.lookup(src + "\ngo\n(code.go /func/ $)")
+	if entry == nil || !entry.ModTime.Equal(mtime.Add(time.Hour)) {
+		t.Errorf("expected the entry to have the new modification time; got %+v", entry)
+	}
+	write("package main\n\nfunc c() {}\n", mtime.Add(2*time.Hour))
+	run(cmd, "func c() {}\n")
+
+	// Pins are checked against the hash of the source cached.
+	var out bytes.Buffer
+	pin := strings.Repeat("0", 64)
+	err := Process(&out, strings.NewReader("[embedmd]:# (code.go /func/ $ sha256="+pin+")\n"), WithBaseDir(dir), WithExtractCache(cache))
+	if err == nil || !strings.Contains(err.Error(), "doesn't match its pinned sha256 "+pin) {
+		t.Errorf("expected a pin error; got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// mdxFence is the fence that writes code blocks as the <CodeBlock> component
+// of MDX sites, such as Docusaurus ones.
+const mdxFence = "mdx"
+
+// WithFence sets the fence of the code blocks embedmd generates, at least
+// three backticks or tildes, instead of ```, or mdx to write them as
+// <CodeBlock> components. Blocks fenced with it are replaced when processing
+// files again, as are the ones fenced with ```. The fence attribute of a
+// command sets it for its block.
+func WithFence(fence string) Option {
+	return Option{func(e *embedder) { e.fence = fence }}
+}
+
+func (e *embedder) validateFence() error {
+	if e.fence == "" || validFence(e.fence) {
+		return nil
+	}
+	return fmt.Errorf("bad fence %q: should be at least three backticks or tildes, or mdx", e.fence)
+}
+
+// validFence reports whether fence can fence the code blocks generated.
+func validFence(fence string) bool {
+	return fence == mdxFence || len(fence) >= 3 && (fence[0] == '`' || fence[0] == '~') && strings.Trim(fence, fence[:1]) == ""
+}
+
+// codeFence returns the fence of the code block generated for cmd.
+func (e *embedder) codeFence(cmd *command) string {
+	switch {
+	case cmd.fence != "":
+		return cmd.fence
+	case e.fence != "":
+		return e.fence
+	}
+	return "```"
+}
+
+// ownsFence reports whether the loose block of cmd is fenced as set with
+// WithFence or its fence attribute, so it was generated by embedmd.
+func (e *embedder) ownsFence(cmd *command) bool {
+	fence := e.codeFence(cmd)
+	line, _, _ := bytes.Cut(cmd.block, []byte("\n"))
+	rest, ok := bytes.CutPrefix(line, []byte(fence))
+	return ok && fence != "```" && fence != mdxFence && (len(rest) == 0 || rest[0] != fence[0])
+}
+
+// writeCodeBlock writes the embedded content b as an MDX <CodeBlock>
+// component, with the annotations of its info string as its metastring, as
+// Docusaurus reads the title, line numbers, and highlighted lines from it.
+// The content is a template literal, so it's kept as is.
+func writeCodeBlock(w io.Writer, lang, meta string, b []byte) {
+	fmt.Fprintf(w, "<CodeBlock language=%q", lang)
+	if meta != "" {
+		// The metastring is a JavaScript string, as JSON ones are.
+		var m bytes.Buffer
+		enc := json.NewEncoder(&m)
+		enc.SetEscapeHTML(false)
+		enc.Encode(meta) //nolint:errcheck
+		fmt.Fprintf(w, " metastring={%s}", bytes.TrimSuffix(m.Bytes(), []byte("\n")))
+	}
+	fmt.Fprintln(w, ">")
+	code := strings.TrimSuffix(string(b), "\n")
+	code = strings.NewReplacer("\\", "\\\\", "`", "\\`", "${", "\\${").Replace(code)
+	fmt.Fprintf(w, "{`%s`}\n", code)
+	fmt.Fprintln(w, "</CodeBlock>")
+}
+
+// writeTrailers writes the comments that followed the block of cmd as they
+// were, as they aren't recognized after loose fences.
+func writeTrailers(w io.Writer, cmd *command) error {
+	for _, line := range cmd.trailers {
+		// Review notes are written again if still wanted.
+		if strings.HasPrefix(line, reviewPrefix) {
+			continue
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithNormalizedFences replaces the code blocks following commands that are
+// fenced differently from what embedmd generates, with indented fences or
+// fences using tildes. By default those blocks are kept, as they can't be
+// told apart from code written by hand, and a warning is reported.
+func WithNormalizedFences() Option {
+	return Option{func(e *embedder) { e.normalizeFences = true }}
+}
+
+// openingFence returns the fence opening a code block in the given line, as
+// defined by CommonMark, or an empty string if the line doesn't open one.
+func openingFence(line string) string {
+	rest, ok := trimIndent(line)
+	if !ok || len(rest) < 3 || (rest[0] != '`' && rest[0] != '~') {
+		return ""
+	}
+	fence := rest[:len(rest)-len(strings.TrimLeft(rest, rest[:1]))]
+	if len(fence) < 3 || (fence[0] == '`' && strings.Contains(rest[len(fence):], "`")) {
+		return ""
+	}
+	return fence
+}
+
+// closesFence reports whether the given line closes a code block opened with
+// fence.
+func closesFence(line, fence string) bool {
+	rest, ok := trimIndent(line)
+	if !ok {
+		return false
+	}
+	trimmed := strings.TrimLeft(rest, fence[:1])
+	return len(rest)-len(trimmed) >= len(fence) && strings.TrimSpace(trimmed) == ""
+}
+
+// trimIndent removes the up to three spaces of indentation allowed before
+// fences, reporting false when the line is indented further.
+func trimIndent(line string) (string, bool) {
+	rest := strings.TrimLeft(line, " ")
+	return rest, len(line)-len(rest) <= 3
+}
+
+// WithFencedIndentedBlocks replaces the indented code blocks following
+// commands with fenced ones, using the language of the command. By default
+// indented code blocks are kept indented, unless the command has a caption
+// or other attributes requiring the block to be wrapped with markers.
+func WithFencedIndentedBlocks() Option {
+	return Option{func(e *embedder) { e.fenceIndented = true }}
+}
+
+// writeIndented writes b as an indented code block.
+func writeIndented(w io.Writer, b []byte) {
+	writePrefixed(w, b, "    ")
+}
+
+// writePrefixed writes the lines of b that aren't blank after prefix.
+func writePrefixed(w io.Writer, b []byte, prefix string) {
+	for _, line := range strings.SplitAfter(string(b), "\n") {
+		if strings.TrimSpace(line) != "" {
+			line = prefix + line
+		}
+		io.WriteString(w, line) //nolint:errcheck
+	}
+}
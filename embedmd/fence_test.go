@@ -0,0 +1,302 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFences(t *testing.T) {
+	tc := []struct {
+		line   string
+		fence  string
+		closes []string
+		open   []string
+	}{
+		{line: "~~~", fence: "~~~", closes: []string{"~~~", "~~~~", "   ~~~  "}, open: []string{"```", "~~", "~~~ go", "    ~~~"}},
+		{line: "  ~~~~ go", fence: "~~~~", closes: []string{"~~~~", "~~~~~"}, open: []string{"~~~"}},
+		{line: "   ```go", fence: "```", closes: []string{"```", " ````"}, open: []string{"~~~", "```go"}},
+		{line: "    ```"},
+		{line: "``"},
+		{line: "``` a`b"},
+		{line: "text"},
+	}
+
+	for _, tt := range tc {
+		fence := openingFence(tt.line)
+		if fence != tt.fence {
+			t.Errorf("expected fence for %q to be %q; got %q", tt.line, tt.fence, fence)
+			continue
+		}
+		for _, l := range tt.closes {
+			if !closesFence(l, fence) {
+				t.Errorf("expected %q to close %q", l, tt.line)
+			}
+		}
+		for _, l := range tt.open {
+			if closesFence(l, fence) {
+				t.Errorf("expected %q not to close %q", l, tt.line)
+			}
+		}
+	}
+}
+
+func TestNormalizedFences(t *testing.T) {
+	const block = "```go\nvar x = 1\n```\n"
+	tc := []struct {
+		name      string
+		in        string
+		normalize bool
+		out       string
+		warnings  []string
+	}{
+		{
+			name: "canonical fence",
+			in:   "[embedmd]:# (x.go)\n```golang\nold\n```\nYay!\n",
+			out:  "[embedmd]:# (x.go)\n" + block + "Yay!\n",
+		},
+		{
+			name:     "tilde fence kept",
+			in:       "[embedmd]:# (x.go)\n~~~go\nold\n~~~\nYay!\n",
+			out:      "[embedmd]:# (x.go)\n" + block + "~~~go\nold\n~~~\nYay!\n",
+			warnings: []string{"1: code block after the command is fenced differently than embedded code, so it is kept"},
+		},
+		{
+			name:      "tilde fence normalized",
+			in:        "[embedmd]:# (x.go)\n~~~go\nold\n```\n~~~\nYay!\n",
+			normalize: true,
+			out:       "[embedmd]:# (x.go)\n" + block + "Yay!\n",
+		},
+		{
+			name:      "indented fence normalized",
+			in:        "[embedmd]:# (x.go)\n  ```  go \nold\n   ```   \nYay!\n",
+			normalize: true,
+			out:       "[embedmd]:# (x.go)\n" + block + "Yay!\n",
+		},
+		{
+			name:      "indented too far to be a fence",
+			in:        "[embedmd]:# (x.go)\n    ```\nYay!\n",
+			normalize: true,
+			out:       "[embedmd]:# (x.go)\n    var x = 1\nYay!\n",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var warnings []string
+			opts := []Option{
+				WithFetcher(mixedContentProvider{files: map[string][]byte{"x.go": []byte("var x = 1\n")}}),
+				WithWarnings(func(line int, msg string) {
+					warnings = append(warnings, fmt.Sprintf("%d: %s", line, msg))
+				}),
+			}
+			if tt.normalize {
+				opts = append(opts, WithNormalizedFences())
+			}
+			if err := Process(&out, strings.NewReader(tt.in), opts...); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.out {
+				t.Errorf("expected output\n%s\ngot\n%s", tt.out, got)
+			}
+			if fmt.Sprint(warnings) != fmt.Sprint(tt.warnings) {
+				t.Errorf("expected warnings %q; got %q", tt.warnings, warnings)
+			}
+		})
+	}
+}
+
+func TestIndentedBlocks(t *testing.T) {
+	const src = "func f() {\n\n\treturn\n}\n"
+	tc := []struct {
+		name  string
+		in    string
+		fence bool
+		out   string
+	}{
+		{
+			name: "indented block kept indented",
+			in:   "[embedmd]:# (f.go)\n    old\n\n    code\n\nYay!\n",
+			out:  "[embedmd]:# (f.go)\n    func f() {\n\n    \treturn\n    }\n\nYay!\n",
+		},
+		{
+			name: "indented with a tab",
+			in:   "[embedmd]:# (f.go)\n\told\nYay!\n",
+			out:  "[embedmd]:# (f.go)\n    func f() {\n\n    \treturn\n    }\nYay!\n",
+		},
+		{
+			name: "indented block at the end of the file",
+			in:   "[embedmd]:# (f.go)\n    old\n\n",
+			out:  "[embedmd]:# (f.go)\n    func f() {\n\n    \treturn\n    }\n\n",
+		},
+		{
+			name:  "indented block converted to a fence",
+			in:    "[embedmd]:# (f.go)\n    old\nYay!\n",
+			fence: true,
+			out:   "[embedmd]:# (f.go)\n```go\n" + src + "```\nYay!\n",
+		},
+		{
+			name: "indented block with a caption",
+			in:   "[embedmd]:# (f.go caption=f)\n    old\nYay!\n",
+			out: "[embedmd]:# (f.go caption=f)\n<!-- embedmd block start -->\n*f*\n\n" +
+				"```go\n" + src + "```\n<!-- embedmd block end -->\nYay!\n",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			opts := []Option{WithFetcher(mixedContentProvider{files: map[string][]byte{"f.go": []byte(src)}})}
+			if tt.fence {
+				opts = append(opts, WithFencedIndentedBlocks())
+			}
+			if err := Process(&out, strings.NewReader(tt.in), opts...); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.out {
+				t.Errorf("expected output\n%q\ngot\n%q", tt.out, got)
+			}
+		})
+	}
+}
+
+func TestFence(t *testing.T) {
+	const src = "var s = \"```\"\n"
+	tc := []struct {
+		name      string
+		fence     string
+		in        string
+		checksums bool
+		out       string
+		err       string
+	}{
+		{
+			name:  "tildes",
+			fence: "~~~",
+			in:    "[embedmd]:# (x.go)\n~~~go\nold\n~~~\nYay!\n",
+			out:   "[embedmd]:# (x.go)\n~~~go\n" + src + "~~~\nYay!\n",
+		},
+		{
+			name:  "backticks replaced with tildes",
+			fence: "~~~",
+			in:    "[embedmd]:# (x.go)\n```go\nold\n```\nYay!\n",
+			out:   "[embedmd]:# (x.go)\n~~~go\n" + src + "~~~\nYay!\n",
+		},
+		{
+			name:  "longer backticks",
+			fence: "````",
+			in:    "[embedmd]:# (x.go)\n````go\n```\nold\n````\nYay!\n",
+			out:   "[embedmd]:# (x.go)\n````go\n" + src + "````\nYay!\n",
+		},
+		{
+			name:      "trailers after tildes",
+			fence:     "~~~",
+			in:        "[embedmd]:# (x.go)\n~~~go\nold\n~~~\n<!-- embedmd checksum 1234 -->\nYay!\n",
+			checksums: true,
+			out:       "[embedmd]:# (x.go)\n~~~go\n" + src + "~~~\n<!-- embedmd checksum 44154e5551ec -->\nYay!\n",
+		},
+		{
+			name:      "trailers after other fences kept",
+			in:        "[embedmd]:# (x.go)\n~~~go\nold\n~~~\n<!-- embedmd checksum 1234 -->\nYay!\n",
+			checksums: true,
+			out: "[embedmd]:# (x.go)\n```go\n" + src + "```\n<!-- embedmd checksum 3ed224ced2c4 -->\n" +
+				"~~~go\nold\n~~~\n<!-- embedmd checksum 1234 -->\nYay!\n",
+		},
+		{name: "too short", fence: "~~", err: `bad fence "~~": should be at least three backticks or tildes, or mdx`},
+		{name: "mixed", fence: "~~`", err: "bad fence \"~~`\": should be at least three backticks or tildes, or mdx"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []Option{WithFetcher(mixedContentProvider{files: map[string][]byte{"x.go": []byte(src)}})}
+			if tt.fence != "" {
+				opts = append(opts, WithFence(tt.fence))
+			}
+			if tt.checksums {
+				opts = append(opts, WithChecksums())
+			}
+			var out bytes.Buffer
+			err := Process(&out, strings.NewReader(tt.in), opts...)
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if got := out.String(); got != tt.out {
+				t.Errorf("expected output\n%q\ngot\n%q", tt.out, got)
+			}
+			// Generated blocks are recognized when processing them again.
+			var again bytes.Buffer
+			if err := Process(&again, strings.NewReader(tt.out), opts...); err != nil {
+				t.Fatal(err)
+			}
+			if tt.fence != "" && again.String() != tt.out {
+				t.Errorf("expected processing again to keep\n%q\ngot\n%q", tt.out, again.String())
+			}
+		})
+	}
+}
+
+func TestFenceAttributes(t *testing.T) {
+	files := map[string][]byte{"x.go": []byte("s := `${a}` + \"\\n\"\n"), "y.go": []byte("func f() {}\n")}
+	tc := []struct {
+		name, fence, in, out, err string
+	}{
+		{name: "tildes for a command",
+			in:  "[embedmd]:# (y.go fence=~~~)\n",
+			out: "[embedmd]:# (y.go fence=~~~)\n~~~go\nfunc f() {}\n~~~\n"},
+		{name: "backticks over the option",
+			fence: "~~~",
+			in:    "[embedmd]:# (y.go fence=````)\n",
+			out:   "[embedmd]:# (y.go fence=````)\n````go\nfunc f() {}\n````\n"},
+		{name: "info",
+			in:  "[embedmd]:# (y.go info=\"title=\\\"main.go\\\" showLineNumbers\")\n",
+			out: "[embedmd]:# (y.go info=\"title=\\\"main.go\\\" showLineNumbers\")\n```go title=\"main.go\" showLineNumbers\nfunc f() {}\n```\n"},
+		{name: "info after annotations",
+			in:  "[embedmd]:# (y.go hl=1 info=title=f.go)\n",
+			out: "[embedmd]:# (y.go hl=1 info=title=f.go)\n```go {1} title=f.go\nfunc f() {}\n```\n"},
+		{name: "mdx",
+			fence: "mdx",
+			in:    "[embedmd]:# (x.go)\n",
+			out:   "[embedmd]:# (x.go)\n<CodeBlock language=\"go\">\n{`s := \\`\\${a}\\` + \"\\\\n\"`}\n</CodeBlock>\n"},
+		{name: "mdx with metastring",
+			in:  "[embedmd]:# (y.go fence=mdx linenos=true info=\"title=\\\"main.go\\\"\")\n",
+			out: "[embedmd]:# (y.go fence=mdx linenos=true info=\"title=\\\"main.go\\\"\")\n<CodeBlock language=\"go\" metastring={\"showLineNumbers title=\\\"main.go\\\"\"}>\n{`func f() {}`}\n</CodeBlock>\n"},
+		{name: "bad fence",
+			in:  "[embedmd]:# (y.go fence=~~)\n",
+			err: `1: fence should be at least three backticks or tildes, or mdx, got "~~"`},
+	}
+	for _, tt := range tc {
+		opts := []Option{WithFetcher(mixedContentProvider{files: files})}
+		if tt.fence != "" {
+			opts = append(opts, WithFence(tt.fence))
+		}
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), opts...)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%s\ngot\n%s", tt.name, tt.out, got)
+			continue
+		}
+		// Generated blocks are recognized when processing them again.
+		var again bytes.Buffer
+		if err := Process(&again, strings.NewReader(tt.out), opts...); err != nil || again.String() != tt.out {
+			t.Errorf("case [%s]: expected processing again to keep the output; got %v\n%s", tt.name, err, again.String())
+		}
+	}
+}
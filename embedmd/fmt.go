@@ -0,0 +1,154 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FormatCommands writes the markdown read from in to w with its commands in
+// canonical form: [embedmd]:# and a single space before the argument list,
+// arguments separated by single spaces, the source and its language and regular expressions first, then
+// the attributes sorted by name, values only quoted when they must be, and
+// local paths cleaned, as ./docs//code.go becomes docs/code.go. Nothing else
+// is changed, not even the blocks embedded, so the commands are rewritten
+// whether their blocks are up to date or not.
+func FormatCommands(w io.Writer, in io.Reader, opts ...Option) error {
+	e, b, err := newEmbedder(in, opts)
+	if err != nil {
+		return err
+	}
+	if e.format == NotebookFormat {
+		return fmt.Errorf("can only format the commands of markdown, reStructuredText, and AsciiDoc documents, not %s ones", e.format)
+	}
+	args := map[int]string{}
+	err = e.parse(io.Discard, bytes.NewReader(b), func(_ io.Writer, cmd *command) error {
+		for _, c := range append([]*command{cmd}, cmd.stacked...) {
+			args[c.line] = c.args
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	for n, list := range args {
+		formatted, ok := formatArgs(list)
+		if !ok {
+			continue
+		}
+		if line := lines[n-1]; bytes.HasPrefix(line, []byte(linkPrefix)) {
+			// The whole line is rewritten, keeping its line ending.
+			text := bytes.TrimRight(line, "\r\n")
+			lines[n-1] = slices.Concat([]byte(linkPrefix+" "+formatted), line[len(text):])
+			continue
+		}
+		if formatted == list {
+			continue
+		}
+		if i := bytes.Index(lines[n-1], []byte(list)); i >= 0 {
+			lines[n-1] = slices.Concat(lines[n-1][:i], []byte(formatted), lines[n-1][i+len(list):])
+		}
+	}
+	_, err = w.Write(bytes.Join(lines, nil))
+	return err
+}
+
+// formatArgs returns the argument list of a command, in parenthesis, in
+// canonical form, or false if it can't be split into arguments.
+func formatArgs(list string) (string, bool) {
+	list = strings.TrimSpace(list)
+	args, err := fields(list[1 : len(list)-1])
+	if err != nil {
+		return "", false
+	}
+	if len(args) > 0 && args[0] == "include" {
+		return "(" + strings.Join(args, " ") + ")", true
+	}
+	// Each of the regions stitched with + is formatted on its own.
+	var parts []string
+	for {
+		i := slices.Index(args, "+")
+		if i < 0 {
+			i = len(args)
+		}
+		parts = append(parts, formatPart(args[:i]))
+		if i == len(args) {
+			break
+		}
+		args = args[i+1:]
+	}
+	return "(" + strings.Join(parts, " + ") + ")", true
+}
+
+// formatPart returns the arguments of a region in canonical form.
+func formatPart(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	positional := []string{cleanSourcePath(args[0])}
+	var attrs []string
+	for _, arg := range args[1:] {
+		key, val, ok := cutAttr(arg)
+		switch {
+		case !ok:
+			positional = append(positional, arg)
+		case strings.Contains(val, "{{") || strings.Contains(val, "$"):
+			// References to variables are kept as written, as they're
+			// expanded before the arguments are split.
+			attrs = append(attrs, arg)
+		default:
+			attrs = append(attrs, key+"="+quoteAttr(val))
+		}
+	}
+	slices.SortStableFunc(attrs, func(a, b string) int {
+		ka, _, _ := strings.Cut(a, "=")
+		kb, _, _ := strings.Cut(b, "=")
+		return strings.Compare(ka, kb)
+	})
+	return strings.Join(append(positional, attrs...), " ")
+}
+
+// quoteAttr returns the value of an attribute, quoted if it's empty or has
+// blanks or quotes.
+func quoteAttr(val string) string {
+	if val == "" || strings.ContainsAny(val, " \t\"") {
+		return strconv.Quote(val)
+	}
+	return val
+}
+
+// cleanSourcePath returns the path of a local source cleaned, keeping the
+// line range or heading following it. Other sources, such as URLs and git
+// paths, and paths referencing variables are kept as written.
+func cleanSourcePath(p string) string {
+	if strings.ContainsAny(p, ":\"$\\") || strings.Contains(p, "{{") {
+		return p
+	}
+	file, fragment, found := strings.Cut(p, "#")
+	if file == "" {
+		return p
+	}
+	file = path.Clean(file)
+	if found {
+		return file + "#" + fragment
+	}
+	return file
+}
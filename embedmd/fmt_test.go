@@ -0,0 +1,95 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFormatCommands(t *testing.T) {
+	tc := []struct {
+		name, in, out string
+		opts          []Option
+		err           string
+	}{
+		{
+			name: "spacing",
+			in:   "[embedmd]:# (  code.go   go\t/func main/  $ )\n",
+			out:  "[embedmd]:# (code.go go /func main/ $)\n",
+		},
+		{
+			name: "spacing around the argument list",
+			in:   "[embedmd]:#   (code.go /func main/ $)  \r\n[embedmd]:#(code.go)\n",
+			out:  "[embedmd]:# (code.go /func main/ $)\r\n[embedmd]:# (code.go)\n",
+		},
+		{
+			name: "attributes sorted after the regular expressions",
+			in:   "[embedmd]:# (code.go linenos=true /func main/ caption=\"Main function\" hl=\"2\" $)\n",
+			out:  "[embedmd]:# (code.go /func main/ $ caption=\"Main function\" hl=2 linenos=true)\n",
+		},
+		{
+			name: "quoting",
+			in:   "[embedmd]:# (code.go info=title=\"x\" lang=\"go\" caption=\"\" maxlines=3)\n",
+			out:  "[embedmd]:# (code.go caption=\"\" info=\"title=\\\"x\\\"\" lang=go maxlines=3)\n",
+		},
+		{
+			name: "paths",
+			in:   "[embedmd]:# (./docs//../code.go#L2-L4)\n[embedmd]:# (https://example.com/a/../x.go)\n",
+			out:  "[embedmd]:# (code.go#L2-L4)\n[embedmd]:# (https://example.com/a/../x.go)\n",
+		},
+		{
+			name: "stitched regions",
+			in:   "[embedmd]:# (a.go  tag=x  +  ./b.go   /y/)\n",
+			out:  "[embedmd]:# (a.go tag=x + b.go /y/)\n",
+		},
+		{
+			name: "variables",
+			in:   "[embedmd]:# (${DIR}/a.go caption={{ .title }} lang=go)\n",
+			out:  "[embedmd]:# (${DIR}/a.go caption={{ .title }} lang=go)\n",
+			opts: []Option{WithEnv(map[string]string{"DIR": "src"})},
+		},
+		{
+			name: "include directive",
+			in:   "[embedmd]:# (include   ./shared//intro.md)\n",
+			out:  "[embedmd]:# (include ./shared//intro.md)\n",
+		},
+		{
+			name: "blocks kept",
+			in:   "[embedmd]:# (code.go  /func/)\n```go\nstale\n```\n\n```go\n[embedmd]:# (in  code)\n```\n",
+			out:  "[embedmd]:# (code.go /func/)\n```go\nstale\n```\n\n```go\n[embedmd]:# (in  code)\n```\n",
+		},
+		{
+			name: "bad command",
+			in:   "[embedmd]:# (code.go captoin=x)\n",
+			err:  "1: unknown attribute \"captoin\"",
+		},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := FormatCommands(&out, strings.NewReader(tt.in), tt.opts...)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+		// Formatting is idempotent.
+		var again bytes.Buffer
+		if err := FormatCommands(&again, strings.NewReader(tt.out), tt.opts...); err != nil || again.String() != tt.out {
+			t.Errorf("case [%s]: formatting again gave %q, %v", tt.name, again.String(), err)
+		}
+	}
+}
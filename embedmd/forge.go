@@ -0,0 +1,186 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// A Forge describes the URLs of a code hosting service, such as GitHub
+// Enterprise or Gitea, so that commands can embed the links to the pages
+// showing files, which are fetched from the raw URLs of the files, and
+// repositories can be named by host/owner/repo.
+type Forge struct {
+	// Host is the host name of the forge, e.g. ghe.example.com.
+	Host string
+	// Kind is the software of the forge: github, gitlab, gitea, bitbucket
+	// for Bitbucket Server, bitbucket-cloud for bitbucket.org, or the kind
+	// of a Provider registered with RegisterProvider. It sets the layout of
+	// the pages showing files, the default URL templates, and how tokens are
+	// sent.
+	Kind string
+	// RawURL is the template of the URLs of raw files, and CloneURL that of
+	// the URLs of repositories, with the placeholders {host}, {owner},
+	// {repo}, {ref}, and {path}, and {project} and {file}, the escaped
+	// owner/repo and path, for APIs such as GitLab's.
+	RawURL, CloneURL string
+	// WikiURL is the template of the URLs of the raw markdown of wiki pages,
+	// with {path} the file of the page, such as Home.md. Wiki pages are
+	// only embedded from forges of kind github with this template, which
+	// github.com has by default.
+	WikiURL string
+}
+
+// publicForges are the forges known without being configured.
+var publicForges = []Forge{
+	{Host: "github.com", Kind: "github", RawURL: "https://raw.githubusercontent.com/{owner}/{repo}/{ref}/{path}",
+		WikiURL: "https://raw.githubusercontent.com/wiki/{owner}/{repo}/{path}"},
+	{Host: "gitlab.com", Kind: "gitlab"},
+	{Host: "codeberg.org", Kind: "gitea"},
+	{Host: "gitea.com", Kind: "gitea"},
+	{Host: "bitbucket.org", Kind: "bitbucket-cloud"},
+}
+
+// WithForges configures self-hosted forges, or overrides the templates of
+// github.com, gitlab.com, codeberg.org, gitea.com, and bitbucket.org. Links
+// to the pages showing files on a forge, such as
+// https://github.com/org/repo/blob/main/x.go, are fetched from the raw URL
+// of the file.
+func WithForges(forges ...Forge) Option {
+	return Option{func(e *embedder) { e.forges = append(e.forges, forges...) }}
+}
+
+func (e *embedder) validateForges() error {
+	for _, f := range e.forges {
+		if err := f.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f Forge) validate() error {
+	if _, ok := provider(f.Kind); !ok {
+		return fmt.Errorf("bad kind %q of forge %s, should be %s", f.Kind, f.Host, providerKinds())
+	}
+	if f.Host == "" || strings.ContainsAny(f.Host, "/:") {
+		return fmt.Errorf("bad host %q of forge, should be a host name", f.Host)
+	}
+	return nil
+}
+
+// findForge returns the forge of the host, among forges and then the public
+// ones, with the default templates of its kind.
+func findForge(host string, forges []Forge) (Forge, bool) {
+	for _, list := range [][]Forge{forges, publicForges} {
+		for _, f := range list {
+			if !strings.EqualFold(f.Host, host) {
+				continue
+			}
+			return f.withDefaults(), true
+		}
+	}
+	return Forge{}, false
+}
+
+// withDefaults returns f with the templates it doesn't set from its provider.
+func (f Forge) withDefaults() Forge {
+	p, ok := provider(f.Kind)
+	if !ok {
+		return f
+	}
+	t := p.Templates()
+	if f.RawURL == "" {
+		f.RawURL = t.RawURL
+	}
+	if f.CloneURL == "" {
+		f.CloneURL = t.CloneURL
+	}
+	if f.WikiURL == "" {
+		f.WikiURL = t.WikiURL
+	}
+	return f
+}
+
+// A ForgeFile locates a file in a repository of a forge. Owner can have
+// several path elements, as GitLab groups do, and Ref can be qualified by
+// its kind, as in Gitea pages such as branch/main.
+type ForgeFile struct {
+	Host, Owner, Repo, Ref, Path string
+}
+
+// expand returns the template with the placeholders replaced by the fields
+// of the file. {project} is the escaped owner/repo, and {file} the escaped
+// path, as in the paths of the GitLab API.
+func (ff ForgeFile) expand(template string) string {
+	s := strings.NewReplacer("{host}", ff.Host, "{owner}", ff.Owner, "{repo}", ff.Repo, "{ref}", ff.Ref, "{path}", ff.Path,
+		"{project}", url.PathEscape(ff.Owner+"/"+ff.Repo), "{file}", url.PathEscape(ff.Path)).Replace(template)
+	// Bitbucket URLs without a ref show the default branch.
+	return strings.TrimSuffix(s, "?at=")
+}
+
+// rawURL returns the raw URL of the file shown by a page of a forge or of
+// the Go Playground, or the path unchanged if it's not such a page.
+func (e *embedder) rawURL(path string) string {
+	if !isURL(path) {
+		return path
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return path
+	}
+	if raw, ok := playgroundRawURL(u); ok {
+		return raw
+	}
+	f, ok := findForge(u.Hostname(), e.forges)
+	if !ok {
+		return path
+	}
+	if ff, ok := parseWikiPage(f.Kind, u); ok && f.WikiURL != "" {
+		ff.Host = u.Host
+		return ff.expand(f.WikiURL)
+	}
+	ff, ok := parsePage(f.Kind, u)
+	if !ok {
+		return path
+	}
+	ff.Host = u.Host
+	return ff.expand(f.RawURL)
+}
+
+// parseWikiPage locates the markdown file of a page of the wiki of a
+// repository on a forge of the given kind, as rendered at
+// /owner/repo/wiki/Page, or /owner/repo/wiki for the Home page.
+func parseWikiPage(kind string, u *url.URL) (ForgeFile, bool) {
+	segs := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	if kind != "github" || len(segs) < 3 || len(segs) > 4 || segs[2] != "wiki" {
+		return ForgeFile{}, false
+	}
+	page := "Home"
+	if len(segs) == 4 {
+		page = segs[3]
+	}
+	return ForgeFile{Owner: segs[0], Repo: segs[1], Path: page + ".md"}, true
+}
+
+// parsePage locates the file shown by a page of a forge of the given kind.
+func parsePage(kind string, u *url.URL) (ForgeFile, bool) {
+	p, ok := provider(kind)
+	if !ok {
+		return ForgeFile{}, false
+	}
+	return p.ParsePage(u)
+}
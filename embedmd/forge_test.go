@@ -0,0 +1,109 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRawURL(t *testing.T) {
+	e := &embedder{forges: []Forge{
+		{Host: "ghe.example.com", Kind: "github"},
+		{Host: "git.example.com", Kind: "gitea"},
+		{Host: "bitbucket.example.com", Kind: "bitbucket"},
+		{Host: "gitlab.example.com", Kind: "gitlab", RawURL: "https://cdn.example.com/{owner}/{repo}/{ref}/{path}"},
+		{Host: "wiki.example.com", Kind: "github", WikiURL: "https://{host}/raw/wiki/{owner}/{repo}/{path}"},
+		{Host: "api.example.com", Kind: "gitlab", RawURL: "https://{host}/api/v4/projects/{project}/repository/files/{file}/raw?ref={ref}"},
+	}}
+	tc := []struct{ in, out string }{
+		{"https://github.com/org/repo/blob/v1.0.0/cmd/main.go",
+			"https://raw.githubusercontent.com/org/repo/v1.0.0/cmd/main.go"},
+		{"https://ghe.example.com/org/repo/blob/main/main.go",
+			"https://ghe.example.com/raw/org/repo/main/main.go"},
+		{"https://gitlab.com/group/sub/repo/-/blob/main/lib/a%20b.rb",
+			"https://gitlab.com/group/sub/repo/-/raw/main/lib/a%20b.rb"},
+		{"https://gitlab.example.com/group/repo/-/blob/main/a.rb",
+			"https://cdn.example.com/group/repo/main/a.rb"},
+		{"https://git.example.com/org/repo/src/tag/v2/main.go",
+			"https://git.example.com/org/repo/raw/tag/v2/main.go"},
+		{"https://codeberg.org/org/repo/src/branch/main/main.go",
+			"https://codeberg.org/org/repo/raw/branch/main/main.go"},
+		{"https://bitbucket.example.com/projects/DOC/repos/repo/browse/src/Main.java?at=refs/heads/main",
+			"https://bitbucket.example.com/projects/DOC/repos/repo/raw/src/Main.java?at=refs%2Fheads%2Fmain"},
+		{"https://bitbucket.example.com/projects/DOC/repos/repo/browse/src/Main.java",
+			"https://bitbucket.example.com/projects/DOC/repos/repo/raw/src/Main.java"},
+		{"https://bitbucket.org/org/repo/src/main/src/Main.java",
+			"https://bitbucket.org/org/repo/raw/main/src/Main.java"},
+		{"https://api.example.com/group/sub/repo/-/blob/main/lib/a.rb",
+			"https://api.example.com/api/v4/projects/group%2Fsub%2Frepo/repository/files/lib%2Fa.rb/raw?ref=main"},
+		// Wiki pages are embedded from their markdown, on forges with a
+		// template for them.
+		{"https://github.com/org/repo/wiki/Getting-Started",
+			"https://raw.githubusercontent.com/wiki/org/repo/Getting-Started.md"},
+		{"https://github.com/org/repo/wiki", "https://raw.githubusercontent.com/wiki/org/repo/Home.md"},
+		{"https://ghe.example.com/org/repo/wiki/Setup", "https://ghe.example.com/org/repo/wiki/Setup"},
+		{"https://wiki.example.com/org/repo/wiki/Setup", "https://wiki.example.com/raw/wiki/org/repo/Setup.md"},
+		// Raw URLs, other pages, other hosts and files are fetched as is.
+		{"https://raw.githubusercontent.com/org/repo/main/main.go",
+			"https://raw.githubusercontent.com/org/repo/main/main.go"},
+		{"https://github.com/org/repo/tree/main/cmd", "https://github.com/org/repo/tree/main/cmd"},
+		{"https://example.com/org/repo/blob/main/main.go", "https://example.com/org/repo/blob/main/main.go"},
+		{"github.com/org/repo/blob/main/main.go", "github.com/org/repo/blob/main/main.go"},
+	}
+	for _, tt := range tc {
+		if got := e.rawURL(tt.in); got != tt.out {
+			t.Errorf("%s: expected %s; got %s", tt.in, tt.out, got)
+		}
+	}
+}
+
+func TestForges(t *testing.T) {
+	urls := map[string][]byte{"https://ghe.example.com/raw/org/repo/main/main.go": []byte(content)}
+	in := "[embedmd]:# (https://ghe.example.com/org/repo/blob/main/main.go /func main/ $)\n"
+	var out bytes.Buffer
+	err := Process(&out, strings.NewReader(in), WithFetcher(mixedContentProvider{urls: urls}),
+		WithForges(Forge{Host: "ghe.example.com", Kind: "github"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := in + "```go\nfunc main() {\n        fmt.Println(\"hello, test\")\n}\n```\n"
+	if out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+
+	// Links to lines of pages are embedded from the raw file.
+	in = "[embedmd]:# (https://github.com/org/repo/blob/main/main.go?plain=1#L6-L8)\n"
+	out.Reset()
+	urls = map[string][]byte{"https://raw.githubusercontent.com/org/repo/main/main.go": []byte(content)}
+	if err := Process(&out, strings.NewReader(in), WithFetcher(mixedContentProvider{urls: urls})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = in + "```go\nfunc main() {\n        fmt.Println(\"hello, test\")\n}\n```\n"
+	if out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+
+	for _, tt := range []struct {
+		forge Forge
+		err   string
+	}{
+		{Forge{Host: "git.example.com", Kind: "gogs"}, `bad kind "gogs" of forge git.example.com, should be bitbucket, bitbucket-cloud, gitea, github, or gitlab`},
+		{Forge{Host: "https://git.example.com", Kind: "gitea"}, `bad host "https://git.example.com" of forge, should be a host name`},
+	} {
+		err := Process(&out, strings.NewReader(""), WithForges(tt.forge))
+		eqErr(t, tt.forge.Host, err, tt.err)
+	}
+}
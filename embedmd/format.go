@@ -0,0 +1,136 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// A Format is the markup language of the documents processed.
+type Format string
+
+const (
+	// MarkdownFormat documents embed code in fenced code blocks.
+	MarkdownFormat Format = "markdown"
+	// RSTFormat documents, in reStructuredText, have their commands in
+	// comments, .. embedmd: file.go /start/ /end/, and embed code in
+	// code-block directives.
+	RSTFormat Format = "rst"
+	// AsciiDocFormat documents have their commands in comments,
+	// // embedmd: file.go /start/ /end/, and embed code in source blocks.
+	AsciiDocFormat Format = "asciidoc"
+	// NotebookFormat documents are Jupyter notebooks, with their commands in
+	// markdown cells, each embedding its code in the code cell following
+	// the cell of the command.
+	NotebookFormat Format = "notebook"
+)
+
+// FormatOf returns the format of the document at path, told by its
+// extension: .rst files are in reStructuredText, .adoc and .asciidoc files
+// in AsciiDoc, .ipynb files are notebooks, and the others in markdown.
+func FormatOf(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".rst":
+		return RSTFormat
+	case ".adoc", ".asciidoc":
+		return AsciiDocFormat
+	case ".ipynb":
+		return NotebookFormat
+	}
+	return MarkdownFormat
+}
+
+// WithFormat sets the format of the documents processed, MarkdownFormat by
+// default. Code embedded in other formats is rendered as their literal
+// blocks, with the caption, linenos, start, and hl attributes as their
+// options, and the features relying on markdown, such as checksums, copy
+// buttons, or shared snippets, can't be used.
+func WithFormat(f Format) Option {
+	return Option{func(e *embedder) { e.format = f }}
+}
+
+func (e *embedder) validateFormat() error {
+	switch e.format {
+	case "", MarkdownFormat:
+		return nil
+	case RSTFormat, AsciiDocFormat, NotebookFormat:
+	default:
+		return fmt.Errorf("bad format %q, should be markdown, rst, asciidoc, or notebook", e.format)
+	}
+	for _, o := range []struct {
+		set  bool
+		name string
+	}{
+		{e.checksums, "checksums"},
+		{e.ariaLabels, "aria labels"},
+		{e.copyButtons != nil, "copy buttons"},
+		{e.includes != nil, "shared snippets"},
+		{e.editLinks != nil, "edit links"},
+		{e.runnableCommands, "runnable commands"},
+		{e.markStale, "stale markers"},
+	} {
+		if o.set {
+			return fmt.Errorf("%s can only be used in markdown documents, not %s ones", o.name, e.format)
+		}
+	}
+	return nil
+}
+
+// literal reports whether the documents processed aren't in markdown, so
+// code is embedded in their literal blocks.
+func (e *embedder) literal() bool {
+	return e.format == RSTFormat || e.format == AsciiDocFormat
+}
+
+// parse writes the document read from in to out, running the commands it
+// holds with run.
+func (e *embedder) parse(out io.Writer, in io.Reader, run commandRunner) error {
+	if e.literal() {
+		return processLiteral(out, in, run, e.format, e.env)
+	}
+	return process(out, in, run, nil, e.env, e.syntaxes...)
+}
+
+// parseKeepingGoing works as parse, collecting the errors of the commands
+// that can't be parsed or fail instead of stopping at the first one. Only
+// the commands of markdown documents are parsed on after an error.
+func (e *embedder) parseKeepingGoing(out io.Writer, in io.Reader, run commandRunner) error {
+	run = e.keepGoingRunner(run)
+	if e.literal() {
+		return processLiteral(out, in, run, e.format, e.env)
+	}
+	skip := func(line int, err error) bool {
+		e.collect(line, err)
+		return true
+	}
+	return process(out, in, run, skip, e.env, e.syntaxes...)
+}
+
+// checkFormat fails if the block of cmd can only be rendered in markdown.
+func (e *embedder) checkFormat(cmd *command) error {
+	switch {
+	case !e.literal():
+		return nil
+	case !cmd.useFence:
+		return fmt.Errorf("content without a code block can only be embedded in markdown documents, not %s ones", e.format)
+	case cmd.split > 0:
+		return fmt.Errorf("split can only be used in markdown documents, not %s ones", e.format)
+	case cmd.maxAge > 0:
+		return fmt.Errorf("maxage can only be used in markdown documents, not %s ones", e.format)
+	}
+	return nil
+}
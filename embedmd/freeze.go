@@ -0,0 +1,67 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// frozen reports whether the block of cmd is frozen, by its freeze attribute
+// or by the one of a command stacked on it.
+func frozen(cmd *command) bool {
+	for _, c := range append([]*command{cmd}, cmd.stacked...) {
+		if c.frozen {
+			return true
+		}
+	}
+	return false
+}
+
+// Freeze writes the markdown read from in to w with the freeze attribute of
+// the command on the given line set, so its block is kept as is until it's
+// unfrozen, or removed if frozen is false.
+func Freeze(w io.Writer, in io.Reader, line int, frozen bool, opts ...Option) error {
+	e, b, err := newEmbedder(in, opts)
+	if err != nil {
+		return err
+	}
+	var found *command
+	err = e.parse(io.Discard, bytes.NewReader(b), func(_ io.Writer, cmd *command) error {
+		if e.skipped[cmd.line] {
+			return nil
+		}
+		for _, c := range append([]*command{cmd}, cmd.stacked...) {
+			if c.line == line {
+				found = c
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if found == nil {
+		return fmt.Errorf("no command on line %d", line)
+	}
+	val := ""
+	if frozen {
+		val = "true"
+	}
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	lines[line-1] = attrLine(lines[line-1], found.ownArgs(), "freeze", val)
+	_, err = w.Write(bytes.Join(lines, nil))
+	return err
+}
@@ -0,0 +1,82 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFrozen(t *testing.T) {
+	files := map[string][]byte{"code.go": []byte("new\n")}
+	tc := []struct {
+		name, in, out, err string
+	}{
+		{name: "frozen",
+			in:  "[embedmd]:# (code.go freeze=true)\n```go\nold\n```\n",
+			out: "[embedmd]:# (code.go freeze=true)\n```go\nold\n```\n"},
+		{name: "unfrozen",
+			in:  "[embedmd]:# (code.go freeze=false)\n```go\nold\n```\n",
+			out: "[embedmd]:# (code.go freeze=false)\n```go\nnew\n```\n"},
+		{name: "missing source",
+			in:  "[embedmd]:# (gone.go freeze=true)\n```go\nold\n```\n",
+			out: "[embedmd]:# (gone.go freeze=true)\n```go\nold\n```\n"},
+		{name: "stacked",
+			in:  "[embedmd]:# (code.go)\n[embedmd]:# (code.go freeze=true)\n```go\nold\n```\n",
+			out: "[embedmd]:# (code.go)\n[embedmd]:# (code.go freeze=true)\n```go\nold\n```\n"},
+		{name: "bad value",
+			in:  "[embedmd]:# (code.go freeze=yes)\n",
+			err: `1: freeze should be true or false, got "yes"`},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	const doc = "# Doc\n\n[embedmd]:# (code.go /start/ caption=\"Old\")\n[embedmd]:# (more.go freeze=true)\n```go\nold\n```\n"
+	tc := []struct {
+		name   string
+		line   int
+		frozen bool
+		out    string
+		err    string
+	}{
+		{name: "freeze", line: 3, frozen: true,
+			out: "# Doc\n\n[embedmd]:# (code.go /start/ caption=\"Old\" freeze=true)\n[embedmd]:# (more.go freeze=true)\n```go\nold\n```\n"},
+		{name: "unfreeze", line: 4,
+			out: "# Doc\n\n[embedmd]:# (code.go /start/ caption=\"Old\")\n[embedmd]:# (more.go)\n```go\nold\n```\n"},
+		{name: "already frozen", line: 4, frozen: true, out: doc},
+		{name: "not frozen", line: 3, out: doc},
+		{name: "no command", line: 1, err: "no command on line 1"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Freeze(&out, strings.NewReader(doc), tt.line, tt.frozen)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// frontMatterEnd returns the index of the line closing the front matter, or
+// zero if there's none. As --- is also a thematic break, YAML front matter
+// must have keys.
+func frontMatterEnd(lines []string) int {
+	if len(lines) == 0 {
+		return 0
+	}
+	switch strings.TrimSpace(lines[0]) {
+	case "---":
+		end := findLine(lines, 0, func(l string) bool { l = strings.TrimSpace(l); return l == "---" || l == "..." })
+		if end == 0 || !hasYAMLKeys(lines[1:end]) {
+			return 0
+		}
+		return end
+	case "+++":
+		return findLine(lines, 0, func(l string) bool { return strings.TrimSpace(l) == "+++" })
+	}
+	return 0
+}
+
+// yamlKey matches the lines starting a key of a YAML mapping.
+var yamlKey = regexp.MustCompile(`^("[^"]*"|'[^']*'|[A-Za-z_][\w.-]*)\s*:(\s|$)`)
+
+// hasYAMLKeys reports whether any of the lines starts a key of a YAML
+// mapping.
+func hasYAMLKeys(lines []string) bool {
+	for _, l := range lines {
+		if yamlKey.MatchString(l) {
+			return true
+		}
+	}
+	return false
+}
+
+// markdownLines splits the markdown in b into lines, without their line
+// endings.
+func markdownLines(b []byte) []string {
+	lines := strings.Split(string(b), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+	return lines
+}
+
+var (
+	yamlVar = regexp.MustCompile(`^([A-Za-z_][\w-]*)\s*:\s+(.*)$`)
+	tomlVar = regexp.MustCompile(`^([A-Za-z_][\w-]*)\s*=\s*(.*)$`)
+)
+
+// frontMatterVars returns the variables set in the YAML or TOML front matter
+// of the markdown in b: its top level keys with a string, number, or boolean
+// value. Other keys are ignored.
+func frontMatterVars(b []byte) map[string]string {
+	lines := markdownLines(b)
+	end := frontMatterEnd(lines)
+	if end == 0 {
+		return nil
+	}
+	toml := strings.TrimSpace(lines[0]) == "+++"
+	vars := map[string]string{}
+	for _, line := range lines[1:end] {
+		if toml && strings.HasPrefix(strings.TrimSpace(line), "[") {
+			// Keys after a table header belong to the table.
+			break
+		}
+		re := yamlVar
+		if toml {
+			re = tomlVar
+		}
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if v, ok := scalarValue(m[2], toml); ok {
+			vars[m[1]] = v
+		}
+	}
+	return vars
+}
+
+// scalarValue returns the value of a key of front matter, unquoted, if it's
+// a single line scalar.
+func scalarValue(v string, toml bool) (string, bool) {
+	v = strings.TrimSpace(v)
+	switch {
+	case v == "":
+		return "", false
+	case strings.HasPrefix(v, `"""`), strings.HasPrefix(v, "'''"):
+		return "", false
+	case v[0] == '"':
+		end := nextQuote(v[1:])
+		if end < 0 {
+			return "", false
+		}
+		s, err := strconv.Unquote(v[:end+2])
+		return s, err == nil
+	case v[0] == '\'':
+		end := strings.Index(v[1:], "'")
+		if !toml {
+			// YAML escapes quotes in single quoted strings by doubling them.
+			for end >= 0 && strings.HasPrefix(v[end+2:], "'") {
+				next := strings.Index(v[end+3:], "'")
+				if next < 0 {
+					end = -1
+					break
+				}
+				end += next + 2
+			}
+		}
+		if end < 0 {
+			return "", false
+		}
+		s := v[1 : end+1]
+		if !toml {
+			s = strings.ReplaceAll(s, "''", "'")
+		}
+		return s, true
+	case strings.ContainsRune("[{|>&*!#", rune(v[0])):
+		// Collections, multi-line strings, anchors, aliases, and tags.
+		return "", false
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = strings.TrimSpace(v[:i])
+	}
+	return v, true
+}
+
+// frontMatterRef matches the references to variables in paths, such as
+// {{ .code_base }}.
+var frontMatterRef = regexp.MustCompile(`\{\{\s*\.([A-Za-z_][\w-]*)\s*\}\}`)
+
+// expandVars replaces the references to variables in the path and the base
+// directory of cmd.
+func (e *embedder) expandVars(cmd *command) error {
+	var err error
+	if cmd.path, err = e.expandRefs(cmd.path); err != nil {
+		return err
+	}
+	cmd.dir, err = e.expandRefs(cmd.dir)
+	return err
+}
+
+// expandRefs replaces the references to variables in s: those of the front
+// matter, root, the root of the git repository, and goos, that of the
+// platform.
+func (e *embedder) expandRefs(s string) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	var err error
+	expanded := frontMatterRef.ReplaceAllStringFunc(s, func(ref string) string {
+		name := frontMatterRef.FindStringSubmatch(ref)[1]
+		v, ok := e.vars[name]
+		switch {
+		case err != nil || ok:
+		case name == "root":
+			v, err = e.gitRoot()
+		case name == "goos" && e.platform != "":
+			v = e.goos()
+		case name == "goos":
+			err = fmt.Errorf("no platform set for {{ .goos }} in %s", s)
+		default:
+			err = fmt.Errorf("unknown front matter variable %s in %s", name, s)
+		}
+		return v
+	})
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(expanded, "{{") {
+		return "", fmt.Errorf("bad reference to a front matter variable in %s, should be {{ .name }}", s)
+	}
+	return expanded, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFrontMatterVars(t *testing.T) {
+	tc := []struct {
+		name string
+		in   string
+		vars map[string]string
+	}{
+		{name: "yaml",
+			in:   "---\ntitle: \"Hello \\\"world\\\"\"\ncode_base: ../examples/v2 # latest\nquote: 'it''s'\nweight: 3\ntags: [a, b]\nparams:\n  nested: x\ndesc: |\n  text\n---\n# Hello\n",
+			vars: map[string]string{"title": `Hello "world"`, "code_base": "../examples/v2", "quote": "it's", "weight": "3"}},
+		{name: "toml",
+			in:   "+++\ntitle = 'Hello'\ncode_base = \"../examples/v2\"\ndraft = false\n[params]\nnested = \"x\"\n+++\n",
+			vars: map[string]string{"title": "Hello", "code_base": "../examples/v2", "draft": "false"}},
+		{name: "no front matter",
+			in: "# Hello\ncode_base: x\n"},
+		{name: "unclosed",
+			in: "---\ncode_base: x\n"},
+		{name: "thematic breaks",
+			in: "---\n\nSome text.\n\n---\n"},
+	}
+	for _, tt := range tc {
+		vars := frontMatterVars([]byte(tt.in))
+		if len(vars) == 0 && len(tt.vars) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(vars, tt.vars) {
+			t.Errorf("case [%s]: got %v; want %v", tt.name, vars, tt.vars)
+		}
+	}
+}
+
+func TestFrontMatter(t *testing.T) {
+	files := map[string][]byte{"examples/v2/main.go": []byte("package main\n")}
+	tc := []struct {
+		name string
+		in   string
+		out  string
+		err  string
+	}{
+		{name: "variable in path",
+			in:  "---\ncode_base: examples/v2\n---\n[embedmd]:# ({{ .code_base }}/main.go)\n",
+			out: "---\ncode_base: examples/v2\n---\n[embedmd]:# ({{ .code_base }}/main.go)\n```go\npackage main\n```\n"},
+		{name: "variables without blanks",
+			in:  "+++\ndir = \"examples\"\nversion = \"v2\"\n+++\n[embedmd]:# ({{.dir}}/{{.version}}/main.go /package.*/)\n",
+			out: "+++\ndir = \"examples\"\nversion = \"v2\"\n+++\n[embedmd]:# ({{.dir}}/{{.version}}/main.go /package.*/)\n```go\npackage main\n```\n"},
+		{name: "language of the expanded path",
+			in:  "---\nfile: examples/v2/main.go\n---\n[embedmd]:# ({{ .file }})\n",
+			out: "---\nfile: examples/v2/main.go\n---\n[embedmd]:# ({{ .file }})\n```go\npackage main\n```\n"},
+		{name: "unknown variable",
+			in:  "---\ncode_base: examples/v2\n---\n[embedmd]:# ({{ .base }}/main.go)\n",
+			err: "4: unknown front matter variable base in {{ .base }}/main.go"},
+		{name: "bad reference",
+			in:  "---\ncode_base: examples/v2\n---\n[embedmd]:# ({{ code_base }}/main.go)\n",
+			err: "4: bad reference to a front matter variable in {{ code_base }}/main.go, should be {{ .name }}"},
+		{name: "unbalanced braces",
+			in:  "[embedmd]:# ({{ .code_base/main.go)\n",
+			err: "1: unbalanced {{"},
+		{name: "code in front matter",
+			in:  "+++\nsnippet = \"\"\"\n[embedmd]:# (examples/v2/main.go)\n```go\n\"\"\"\n+++\n[embedmd]:# (examples/v2/main.go)\n",
+			out: "+++\nsnippet = \"\"\"\n[embedmd]:# (examples/v2/main.go)\n```go\n\"\"\"\n+++\n[embedmd]:# (examples/v2/main.go)\n```go\npackage main\n```\n"},
+		{name: "thematic break",
+			in:  "---\n[embedmd]:# (examples/v2/main.go)\n",
+			out: "---\n[embedmd]:# (examples/v2/main.go)\n```go\npackage main\n```\n"},
+		{name: "thematic breaks around a command",
+			in:  "---\n\n[embedmd]:# (examples/v2/main.go)\n\n---\n",
+			out: "---\n\n[embedmd]:# (examples/v2/main.go)\n```go\npackage main\n```\n\n---\n"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if out.String() != tt.out {
+			t.Errorf("case [%s]: expected\n%q\ngot\n%q", tt.name, tt.out, out.String())
+		}
+	}
+}
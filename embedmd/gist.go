@@ -0,0 +1,123 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// gistAPI is the URL of the GitHub API serving gists.
+const gistAPI = "https://api.github.com/gists/"
+
+// gistPage matches the path of the page of a GitHub gist, with or without
+// its owner, and optionally at a revision, capturing its ID and revision.
+var gistPage = regexp.MustCompile(`^/(?:[\w-]+/)?([0-9a-fA-F]+)(?:/([0-9a-fA-F]+))?/?$`)
+
+// A gist is a GitHub gist, at a revision or the latest, and the name or
+// anchor of the file of it to embed, if any.
+type gist struct {
+	id, rev, file string
+}
+
+// parseGist returns the gist shown by the page at path, as in
+// https://gist.github.com/owner/ID#file-main-go, if it's such a page.
+func parseGist(path string) (gist, bool) {
+	if !isURL(path) {
+		return gist{}, false
+	}
+	u, err := url.Parse(path)
+	if err != nil || u.Hostname() != "gist.github.com" {
+		return gist{}, false
+	}
+	m := gistPage.FindStringSubmatch(u.Path)
+	if m == nil {
+		return gist{}, false
+	}
+	return gist{id: m[1], rev: m[2], file: u.Fragment}, true
+}
+
+// apiURL returns the URL of g in the GitHub API.
+func (g gist) apiURL() string {
+	if g.rev != "" {
+		return gistAPI + g.id + "/" + g.rev
+	}
+	return gistAPI + g.id
+}
+
+// gistFile is a file of a gist, as returned by the GitHub API. The content
+// of large files is truncated, and fetched from their raw URL instead.
+type gistFile struct {
+	Content   string `json:"content"`
+	Truncated bool   `json:"truncated"`
+	RawURL    string `json:"raw_url"`
+}
+
+// fetchGist fetches the content of the file of g, or of its only file, with
+// the GitHub API.
+func (e *embedder) fetchGist(g gist) ([]byte, error) {
+	b, err := FetchContext(e.context(), e.Fetcher, e.baseDir, g.apiURL())
+	if err != nil {
+		return nil, err
+	}
+	var v struct {
+		Files map[string]gistFile `json:"files"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("bad gist %s: %v", g.id, err)
+	}
+	var names []string
+	for name := range v.Files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var name string
+	switch {
+	case len(names) == 0:
+		return nil, fmt.Errorf("gist %s has no files", g.id)
+	case g.file != "":
+		i := slices.IndexFunc(names, func(n string) bool { return n == g.file || gistAnchor(n) == g.file })
+		if i < 0 {
+			return nil, fmt.Errorf("gist %s has no file %s, only %s", g.id, g.file, strings.Join(names, ", "))
+		}
+		name = names[i]
+	case len(names) > 1:
+		return nil, fmt.Errorf("gist %s has several files, select one with its anchor, as in #%s", g.id, gistAnchor(names[0]))
+	default:
+		name = names[0]
+	}
+	f := v.Files[name]
+	if f.Truncated {
+		return FetchContext(e.context(), e.Fetcher, e.baseDir, f.RawURL)
+	}
+	return []byte(f.Content), nil
+}
+
+// gistAnchor returns the anchor of the file with the given name on the page
+// of its gist, as in file-main-go for main.go.
+func gistAnchor(name string) string {
+	return "file-" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, name)
+}
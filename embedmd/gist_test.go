@@ -0,0 +1,110 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGist(t *testing.T) {
+	urls := map[string][]byte{
+		"https://api.github.com/gists/aa11":      []byte(`{"files": {"main.go": {"content": "package main\n"}}}`),
+		"https://api.github.com/gists/aa11/ff00": []byte(`{"files": {"main.go": {"content": "package old\n"}}}`),
+		"https://api.github.com/gists/bb22": []byte(`{"files": {
+			"README.md": {"content": "# Example\n"},
+			"Main_Test.go": {"content": "package big\n", "truncated": true, "raw_url": "https://gist.githubusercontent.com/owner/bb22/raw/Main_Test.go"}
+		}}`),
+		"https://gist.githubusercontent.com/owner/bb22/raw/Main_Test.go": []byte("package main_test\n"),
+		"https://api.github.com/gists/cc33":                              []byte(`{"files": {}}`),
+	}
+	tc := []struct {
+		name, path, out, err string
+	}{
+		{name: "only file", path: "https://gist.github.com/owner/aa11", out: "package main\n"},
+		{name: "without owner", path: "https://gist.github.com/aa11", out: "package main\n"},
+		{name: "revision", path: "https://gist.github.com/owner/aa11/ff00", out: "package old\n"},
+		{name: "anchor", path: "https://gist.github.com/owner/bb22#file-readme-md", out: "# Example\n"},
+		{name: "file name", path: "https://gist.github.com/owner/bb22#README.md", out: "# Example\n"},
+		{name: "truncated", path: "https://gist.github.com/owner/bb22#file-main_test-go", out: "package main_test\n"},
+		{name: "several files", path: "https://gist.github.com/owner/bb22",
+			err: "gist bb22 has several files, select one with its anchor, as in #file-main_test-go"},
+		{name: "missing file", path: "https://gist.github.com/owner/bb22#file-main-go",
+			err: "gist bb22 has no file file-main-go, only Main_Test.go, README.md"},
+		{name: "no files", path: "https://gist.github.com/cc33", err: "gist cc33 has no files"},
+		{name: "missing gist", path: "https://gist.github.com/dd44", err: "status Not Found"},
+	}
+	for _, tt := range tc {
+		e := &embedder{Fetcher: mixedContentProvider{urls: urls}}
+		g, ok := parseGist(tt.path)
+		if !ok {
+			t.Errorf("case [%s]: %s is not a gist", tt.name, tt.path)
+			continue
+		}
+		b, err := e.fetchGist(g)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if string(b) != tt.out {
+			t.Errorf("case [%s]: expected %q; got %q", tt.name, tt.out, b)
+		}
+	}
+}
+
+func TestParseGist(t *testing.T) {
+	for _, path := range []string{
+		"gist.go",
+		"https://github.com/owner/aa11",
+		"https://gist.github.com/owner",
+		"https://gist.github.com/owner/aa11/raw/main.go",
+	} {
+		if g, ok := parseGist(path); ok {
+			t.Errorf("%s is not a gist; got %+v", path, g)
+		}
+	}
+}
+
+func TestEmbedGistAndPlayground(t *testing.T) {
+	urls := map[string][]byte{
+		"https://api.github.com/gists/aa11":   []byte(`{"files": {"main.go": {"content": "package main\n"}}}`),
+		"https://go.dev/play/p/AbC123.go":     []byte("package play\n"),
+		"https://go.dev/play/p/Old-_1.go":     []byte("package old\n"),
+		"https://go.dev/play/p/AbC123":        []byte("<html>"),
+		"https://play.golang.org/p/Old-_1.go": []byte("<html>"),
+	}
+	tc := []struct {
+		name, in, out string
+	}{
+		{name: "gist",
+			in:  "[embedmd]:# (https://gist.github.com/owner/aa11 go)\n",
+			out: "[embedmd]:# (https://gist.github.com/owner/aa11 go)\n```go\npackage main\n```\n"},
+		{name: "playground",
+			in:  "[embedmd]:# (https://go.dev/play/p/AbC123 go)\n",
+			out: "[embedmd]:# (https://go.dev/play/p/AbC123 go)\n```go\npackage play\n```\n"},
+		{name: "former playground",
+			in:  "[embedmd]:# (https://play.golang.org/p/Old-_1 go)\n",
+			out: "[embedmd]:# (https://play.golang.org/p/Old-_1 go)\n```go\npackage old\n```\n"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		if err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{urls: urls})); err != nil {
+			t.Errorf("case [%s]: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q; got\n%q", tt.name, tt.out, got)
+		}
+	}
+}
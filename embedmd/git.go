@@ -0,0 +1,85 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitScheme prefixes the paths of files at a given revision of the git
+// repository containing them, such as git://./pkg/foo.go@v1.2.0.
+const gitScheme = "git://"
+
+func isGitPath(path string) bool { return strings.HasPrefix(path, gitScheme) }
+
+// cutRevision splits a git:// path into the path of the file and the
+// revision, which follows the last @.
+func cutRevision(path string) (file, rev string, ok bool) {
+	path = strings.TrimPrefix(path, gitScheme)
+	i := strings.LastIndex(path, "@")
+	if i < 0 {
+		return path, "", false
+	}
+	return path[:i], path[i+1:], true
+}
+
+// fetchGit returns the content of the file named by a git:// path, relative
+// to dir, at its revision.
+func fetchGit(ctx context.Context, dir, path string) ([]byte, error) {
+	file, rev, ok := cutRevision(path)
+	if !ok || rev == "" || file == "" {
+		return nil, fmt.Errorf("missing revision in %s, as in git://./main.go@v1.0.0", path)
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, filepath.FromSlash(file))
+	}
+	wd := filepath.Dir(file)
+	if _, err := runGit(ctx, wd, "rev-parse", "--verify", "--quiet", rev+"^{commit}"); err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			return nil, fmt.Errorf("unknown revision %q", rev)
+		}
+		return nil, err
+	}
+	b, err := runGit(ctx, wd, "show", rev+":./"+filepath.Base(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s does not exist at revision %s", filepath.ToSlash(file), rev)
+	}
+	return b, nil
+}
+
+// runGit runs git in dir, returning its output or, if it fails, its error
+// message when it printed one, or the error of ctx once it's done.
+func runGit(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
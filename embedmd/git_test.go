@@ -0,0 +1,87 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitPath(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(dir, "pkg"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "pkg", "foo.go"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	write("func Foo() {}\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "v1")
+	git("tag", "v1.2.0")
+	write("func Foo(n int) {}\n")
+	git("commit", "-q", "-am", "v2")
+
+	docs := filepath.Join(dir, "docs")
+	tc := []struct {
+		name, in, out, err string
+	}{
+		{name: "tag",
+			in:  "[embedmd]:# (git://../pkg/foo.go@v1.2.0)\n",
+			out: "[embedmd]:# (git://../pkg/foo.go@v1.2.0)\n```go\nfunc Foo() {}\n```\n"},
+		{name: "relative revision",
+			in:  "[embedmd]:# (git://../pkg/foo.go@HEAD~1 /func/ $)\n",
+			out: "[embedmd]:# (git://../pkg/foo.go@HEAD~1 /func/ $)\n```go\nfunc Foo() {}\n```\n"},
+		{name: "head",
+			in:  "[embedmd]:# (git://../pkg/foo.go@HEAD)\n",
+			out: "[embedmd]:# (git://../pkg/foo.go@HEAD)\n```go\nfunc Foo(n int) {}\n```\n"},
+		{name: "unknown revision",
+			in:  "[embedmd]:# (git://../pkg/foo.go@v9)\n",
+			err: `1: could not read git://../pkg/foo.go@v9: unknown revision "v9"`},
+		{name: "missing file",
+			in:  "[embedmd]:# (git://../pkg/bar.go@v1.2.0)\n",
+			err: "1: could not read git://../pkg/bar.go@v1.2.0: " + filepath.ToSlash(filepath.Join(dir, "pkg", "bar.go")) + " does not exist at revision v1.2.0"},
+		{name: "missing revision",
+			in:  "[embedmd]:# (git://../pkg/foo.go go)\n",
+			err: "1: could not read git://../pkg/foo.go: missing revision in git://../pkg/foo.go, as in git://./main.go@v1.0.0"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithBaseDir(docs))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if out.String() != tt.out {
+			t.Errorf("case [%s]: expected\n%s\ngot\n%s", tt.name, tt.out, out.String())
+		}
+	}
+}
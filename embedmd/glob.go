@@ -0,0 +1,62 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"path"
+	"strings"
+)
+
+// matchPattern reports whether name matches the given slash separated
+// pattern. Each element of the pattern follows the syntax of path.Match,
+// and the special element ** matches zero or more path elements.
+func matchPattern(pattern, name string) bool {
+	return matchElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// sourceKey returns the name used to match a source against patterns: the
+// host and path for URLs, and the slash separated path for files.
+func sourceKey(src string) string {
+	for _, scheme := range []string{"http://", "https://"} {
+		if strings.HasPrefix(src, scheme) {
+			src = strings.TrimPrefix(src, scheme)
+			if i := strings.IndexAny(src, "?#"); i >= 0 {
+				src = src[:i]
+			}
+			return src
+		}
+	}
+	return path.Clean(src)
+}
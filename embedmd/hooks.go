@@ -0,0 +1,94 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import "errors"
+
+// An Embed is the content embedded by a command, passed to the hooks set
+// with WithBeforeEmbed and WithAfterEmbed. Commands stacked or stitched in a
+// block each have their own.
+type Embed struct {
+	// Line is the line of the command in the document, and Command its
+	// argument list, in parentheses, as written.
+	Line    int
+	Command string
+	// Path is the path or URL of the source, with variables and aliases
+	// expanded, relative to BaseDir, and Lang the language of the block.
+	// Hooks called before embedding can change them.
+	Path, Lang string
+	BaseDir    string
+	// Source is the content of the source, and Content the content
+	// embedded, after the regions are selected, and the lines omitted,
+	// elided, and transformed. Both are only set after embedding, and hooks
+	// can change Content.
+	Source, Content []byte
+}
+
+// SkipEmbed is returned by the hooks called before embedding to keep the
+// block of the command as it is, with those of the commands stacked on it.
+var SkipEmbed = errors.New("skip this embed")
+
+// WithBeforeEmbed calls f before fetching the source of every command, so
+// it can check the command, change its source or language, or veto it: an
+// error fails the command, unless it's SkipEmbed.
+func WithBeforeEmbed(f func(*Embed) error) Option {
+	return Option{func(e *embedder) { e.beforeEmbed = f }}
+}
+
+// WithAfterEmbed calls f with the content embedded by every command, so it
+// can check it or change it before it's written. An error fails the
+// command.
+func WithAfterEmbed(f func(*Embed) error) Option {
+	return Option{func(e *embedder) { e.afterEmbed = f }}
+}
+
+// newEmbed returns the Embed of cmd.
+func (e *embedder) newEmbed(cmd *command) *Embed {
+	return &Embed{Line: cmd.line, Command: cmd.args, Path: cmd.path, Lang: cmd.lang, BaseDir: e.baseDir}
+}
+
+// runBeforeEmbed calls the hook set with WithBeforeEmbed for cmd, applying
+// the changes it makes.
+func (e *embedder) runBeforeEmbed(cmd *command) error {
+	if e.beforeEmbed == nil {
+		return nil
+	}
+	emb := e.newEmbed(cmd)
+	if err := e.beforeEmbed(emb); err != nil {
+		return err
+	}
+	if emb.Path == cmd.path && emb.Lang == cmd.lang {
+		return nil
+	}
+	if emb.Lang != cmd.lang {
+		cmd.lang, cmd.inferredLang = emb.Lang, false
+	}
+	cmd.path = emb.Path
+	// The new source must be allowed too.
+	return e.checkPolicy(cmd)
+}
+
+// runAfterEmbed calls the hook set with WithAfterEmbed with the content b
+// embedded by cmd from src, returning the content it leaves.
+func (e *embedder) runAfterEmbed(cmd *command, src, b []byte) ([]byte, error) {
+	if e.afterEmbed == nil {
+		return b, nil
+	}
+	emb := e.newEmbed(cmd)
+	emb.Source, emb.Content = src, b
+	if err := e.afterEmbed(emb); err != nil {
+		return nil, err
+	}
+	return emb.Content, nil
+}
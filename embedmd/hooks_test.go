@@ -0,0 +1,99 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	files := map[string][]byte{
+		"code.go":   []byte("package main\n\nfunc main() {}\n"),
+		"other.go":  []byte("package other\n"),
+		"secret.go": []byte("package secret\n"),
+	}
+	veto := errors.New("secrets are not embedded")
+	before := func(emb *Embed) error {
+		switch emb.Path {
+		case "secret.go":
+			return veto
+		case "kept.go":
+			return SkipEmbed
+		case "alias.go":
+			emb.Path, emb.Lang = "other.go", "golang"
+		}
+		return nil
+	}
+	after := func(emb *Embed) error {
+		if emb.Path == "code.go" {
+			emb.Content = bytes.ToUpper(emb.Content)
+		}
+		return nil
+	}
+
+	tc := []struct {
+		name, in, out, err string
+	}{
+		{name: "changed content",
+			in:  "[embedmd]:# (code.go /func/)\n",
+			out: "[embedmd]:# (code.go /func/)\n```go\nFUNC\n```\n"},
+		{name: "changed path and language",
+			in:  "[embedmd]:# (alias.go)\n",
+			out: "[embedmd]:# (alias.go)\n```golang\npackage other\n```\n"},
+		{name: "skipped",
+			in:  "[embedmd]:# (kept.go)\n```go\nold\n```\n",
+			out: "[embedmd]:# (kept.go)\n```go\nold\n```\n"},
+		{name: "vetoed",
+			in:  "[embedmd]:# (secret.go)\n",
+			err: "1: secrets are not embedded"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}),
+			WithBeforeEmbed(before), WithAfterEmbed(after))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q; got\n%q", tt.name, tt.out, got)
+		}
+	}
+}
+
+func TestHooksEmbed(t *testing.T) {
+	files := map[string][]byte{"code.go": []byte("package main\n\nfunc main() {}\n")}
+	var got []Embed
+	hook := func(emb *Embed) error {
+		got = append(got, *emb)
+		return nil
+	}
+	in := "# Title\n[embedmd]:# (code.go /func/)\n"
+	err := Process(new(bytes.Buffer), strings.NewReader(in), WithFetcher(mixedContentProvider{files: files}),
+		WithBeforeEmbed(hook), WithAfterEmbed(hook))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Embed{
+		{Line: 2, Command: "(code.go /func/)", Path: "code.go", Lang: "go"},
+		{Line: 2, Command: "(code.go /func/)", Path: "code.go", Lang: "go",
+			Source: files["code.go"], Content: []byte("func")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v; got %+v", want, got)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// An HTTPCache keeps the content fetched from URLs in a directory, with the
+// ETag and Last-Modified validators sent by the server, so later fetches
+// send a conditional request and reuse the cached content when the server
+// replies that it didn't change.
+type HTTPCache struct {
+	// Dir is the directory of the cache, created if needed.
+	Dir string
+	// TTL is how long the cached content is used without asking the server
+	// whether it changed. If 0, the server is asked on every fetch.
+	TTL time.Duration
+}
+
+// DefaultHTTPCacheDir returns the directory of the HTTP cache in the user
+// cache directory.
+func DefaultHTTPCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "embedmd", "http"), nil
+}
+
+// WithHTTPCache caches the content fetched from URLs in c.
+func WithHTTPCache(c *HTTPCache) FetcherOption {
+	return FetcherOption{func(f *fetcher) { f.cache = c }}
+}
+
+// cacheEntry is the content cached for a URL.
+type cacheEntry struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Content      []byte `json:"content"`
+}
+
+func (c *HTTPCache) entryPath(url string) string {
+	hash := sha256Hex(url)
+	return filepath.Join(c.Dir, hash[:2], hash)
+}
+
+// lookup returns the entry cached for the URL, if any, and whether it's
+// fresh enough to be used without asking the server.
+func (c *HTTPCache) lookup(url string) (e *cacheEntry, fresh bool) {
+	path := c.entryPath(url)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	b, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(b, &e) != nil || e.URL != url {
+		return nil, false
+	}
+	return e, time.Since(info.ModTime()) < c.TTL
+}
+
+// validate adds the conditional headers of the entry to the request.
+func (e *cacheEntry) validate(req *http.Request) {
+	if e.ETag != "" {
+		req.Header.Set("If-None-Match", e.ETag)
+	}
+	if e.LastModified != "" {
+		req.Header.Set("If-Modified-Since", e.LastModified)
+	}
+}
+
+// revalidated records that the server confirmed the entry is unchanged, so
+// it's fresh for another TTL.
+func (c *HTTPCache) revalidated(url string) {
+	now := time.Now()
+	os.Chtimes(c.entryPath(url), now, now)
+}
+
+// add caches the content fetched from the URL, if the response has
+// validators or the content can be used without them for a while.
+func (c *HTTPCache) add(url string, h http.Header, b []byte) error {
+	e := cacheEntry{URL: url, ETag: h.Get("ETag"), LastModified: h.Get("Last-Modified"), Content: b}
+	if e.ETag == "" && e.LastModified == "" && c.TTL == 0 {
+		return nil
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(c.entryPath(url), data, 0644)
+}
@@ -0,0 +1,86 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHTTPCache(t *testing.T) {
+	version, requests, notModified := "v1", 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/etag.go":
+			etag := `"` + version + `"`
+			if r.Header.Get("If-None-Match") == etag {
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+		case "/modified.go":
+			const date = "Mon, 02 Jan 2006 15:04:05 GMT"
+			if r.Header.Get("If-Modified-Since") == date {
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Last-Modified", date)
+		}
+		w.Write([]byte(version + " " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	cache := &HTTPCache{Dir: t.TempDir()}
+	f := NewFetcher(nil, WithHTTPCache(cache))
+	fetch := func(path, want string, wantRequests, wantNotModified int) {
+		t.Helper()
+		b, err := f.Fetch("", server.URL+path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(b) != want {
+			t.Errorf("expected %q; got %q", want, b)
+		}
+		if requests != wantRequests || notModified != wantNotModified {
+			t.Errorf("expected %d requests and %d not modified; got %d and %d", wantRequests, wantNotModified, requests, notModified)
+		}
+	}
+
+	fetch("/etag.go", "v1 /etag.go", 1, 0)
+	fetch("/etag.go", "v1 /etag.go", 2, 1)
+	fetch("/modified.go", "v1 /modified.go", 3, 1)
+	fetch("/modified.go", "v1 /modified.go", 4, 2)
+	version = "v2"
+	fetch("/etag.go", "v2 /etag.go", 5, 2)
+	fetch("/etag.go", "v2 /etag.go", 6, 3)
+
+	// Responses without validators aren't cached without a TTL.
+	fetch("/plain.go", "v2 /plain.go", 7, 3)
+	if _, err := os.Stat(cache.entryPath(server.URL + "/plain.go")); !os.IsNotExist(err) {
+		t.Errorf("expected /plain.go not to be cached; got %v", err)
+	}
+
+	// Fresh content is used without asking the server.
+	cache.TTL = time.Hour
+	fetch("/etag.go", "v2 /etag.go", 7, 3)
+	fetch("/plain.go", "v2 /plain.go", 8, 3)
+	version = "v3"
+	fetch("/plain.go", "v2 /plain.go", 8, 3)
+}
@@ -0,0 +1,108 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Includes replaces the code blocks embedded by commands with references to
+// shared files holding them, so site generators include the snippets
+// repeated across pages rather than copying them into every page. Shared
+// files are named after a hash of their content, so identical snippets share
+// the same file.
+type Includes struct {
+	// Dialect is the syntax of the references: jekyll, for the include tag
+	// of Jekyll and other Liquid renderers, or mkdocs, for the snippets
+	// extension of Python Markdown.
+	Dialect string
+	// Dir is the directory the shared files are written to.
+	Dir string
+	// Path is the slash separated path of Dir in references, as resolved by
+	// the renderer. It defaults to Dir without the leading _includes/ for
+	// jekyll, and to Dir for mkdocs.
+	Path string
+	// Shared reports whether the snippet with the given hash is shared, as
+	// when it's repeated across pages. Every snippet is shared if nil.
+	Shared func(hash string) bool
+}
+
+// includeDialects give the reference to a shared file for each dialect.
+var includeDialects = map[string]string{
+	"jekyll": "{%% include %s %%}",
+	"mkdocs": "--8<-- %q",
+}
+
+// WithIncludes replaces the code blocks embedded by commands with
+// references to shared files, as set by in. Blocks wrapped with markers,
+// such as those with a caption, keep them around the reference.
+func WithIncludes(in Includes) Option {
+	return Option{func(e *embedder) { e.includes = &in }}
+}
+
+func (e *embedder) validateIncludes() error {
+	if e.includes == nil {
+		return nil
+	}
+	if _, ok := includeDialects[e.includes.Dialect]; !ok {
+		return fmt.Errorf("bad include dialect %q, should be jekyll or mkdocs", e.includes.Dialect)
+	}
+	if e.includes.Dir == "" {
+		return fmt.Errorf("missing directory of the %s includes", e.includes.Dialect)
+	}
+	return nil
+}
+
+// snippetHash returns the hash naming the shared file of a code block.
+func snippetHash(block []byte) string {
+	sum := sha256.Sum256(block)
+	return hex.EncodeToString(sum[:6])
+}
+
+// include writes the code block of cmd with the content b to its shared
+// file, returning the reference to it, or an empty string if the block
+// isn't shared.
+func (e *embedder) include(cmd *command, b []byte) (string, error) {
+	if e.includes == nil || !cmd.useFence {
+		return "", nil
+	}
+	var block bytes.Buffer
+	e.writeFenced(&block, cmd, b)
+	hash := snippetHash(block.Bytes())
+	if e.includes.Shared != nil && !e.includes.Shared(hash) {
+		return "", nil
+	}
+	name := hash + ".md"
+	if err := os.MkdirAll(e.includes.Dir, 0777); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(e.includes.Dir, name), block.Bytes(), 0666); err != nil {
+		return "", err
+	}
+	ref := e.includes.Path
+	if ref == "" {
+		ref = filepath.ToSlash(e.includes.Dir)
+		if e.includes.Dialect == "jekyll" {
+			ref = strings.TrimPrefix(strings.TrimPrefix(ref, "./"), "_includes/")
+		}
+	}
+	return fmt.Sprintf(includeDialects[e.includes.Dialect], path.Join(ref, name)), nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIncludes(t *testing.T) {
+	files := map[string][]byte{"a.go": []byte("var a = 1\n"), "b.go": []byte("var b = 2\n")}
+	block := "```go\nvar a = 1\n```\n"
+	hash := snippetHash([]byte(block))
+
+	tc := []struct {
+		name string
+		in   Includes
+		doc  string
+		out  string
+		err  string
+	}{
+		{
+			name: "jekyll",
+			in:   Includes{Dialect: "jekyll", Dir: "_includes/snippets"},
+			doc:  "[embedmd]:# (a.go)\n",
+			out: "[embedmd]:# (a.go)\n<!-- embedmd block start -->\n{% include snippets/" + hash + ".md %}\n" +
+				"<!-- embedmd block end -->\n",
+		},
+		{
+			name: "mkdocs with a caption",
+			in:   Includes{Dialect: "mkdocs", Dir: "snippets", Path: "docs/snippets"},
+			doc:  "[embedmd]:# (a.go caption=A)\n",
+			out: "[embedmd]:# (a.go caption=A)\n<!-- embedmd block start -->\n*A*\n\n--8<-- \"docs/snippets/" + hash + ".md\"\n" +
+				"<!-- embedmd block end -->\n",
+		},
+		{
+			name: "only shared snippets",
+			in:   Includes{Dialect: "mkdocs", Dir: "snippets", Path: "snippets", Shared: func(h string) bool { return h == hash }},
+			doc:  "[embedmd]:# (a.go)\n\n[embedmd]:# (b.go)\n",
+			out: "[embedmd]:# (a.go)\n<!-- embedmd block start -->\n--8<-- \"" + "snippets/" + hash + ".md\"\n" +
+				"<!-- embedmd block end -->\n\n[embedmd]:# (b.go)\n```go\nvar b = 2\n```\n",
+		},
+		{name: "bad dialect", in: Includes{Dialect: "hugo", Dir: "snippets"}, err: `bad include dialect "hugo", should be jekyll or mkdocs`},
+		{name: "missing dir", in: Includes{Dialect: "jekyll"}, err: "missing directory of the jekyll includes"},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.in.Dir != "" {
+				tt.in.Dir = filepath.Join(dir, tt.in.Dir)
+				if tt.in.Path == "" && tt.in.Dialect == "jekyll" {
+					tt.in.Path = "snippets"
+				}
+			}
+			opts := []Option{WithFetcher(mixedContentProvider{files: files}), WithIncludes(tt.in)}
+			var out bytes.Buffer
+			err := Process(&out, strings.NewReader(tt.doc), opts...)
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if got := out.String(); got != tt.out {
+				t.Errorf("expected output\n%q\ngot\n%q", tt.out, got)
+			}
+			var again bytes.Buffer
+			if err := Process(&again, strings.NewReader(tt.out), opts...); err != nil || again.String() != tt.out {
+				t.Errorf("expected processing again to keep the output; got\n%q, %v", again.String(), err)
+			}
+			b, err := os.ReadFile(filepath.Join(tt.in.Dir, hash+".md"))
+			if err != nil || string(b) != block {
+				t.Errorf("expected shared file with\n%q\ngot\n%q, %v", block, b, err)
+			}
+		})
+	}
+}
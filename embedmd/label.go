@@ -0,0 +1,72 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithSkipLabels keeps as is the blocks of commands carrying any of the
+// given labels, set with the label attribute, so expensive commands can be
+// left out of quick runs.
+func WithSkipLabels(labels ...string) Option {
+	return Option{func(e *embedder) { e.skipLabels = addLabels(e.skipLabels, labels) }}
+}
+
+// WithOnlyLabels keeps as is the blocks of commands carrying none of the
+// given labels.
+func WithOnlyLabels(labels ...string) Option {
+	return Option{func(e *embedder) { e.onlyLabels = addLabels(e.onlyLabels, labels) }}
+}
+
+func addLabels(set map[string]bool, labels []string) map[string]bool {
+	if set == nil {
+		set = map[string]bool{}
+	}
+	for _, l := range labels {
+		set[l] = true
+	}
+	return set
+}
+
+// parseLabels parses the comma separated labels of the label attribute.
+func parseLabels(val string) ([]string, error) {
+	var labels []string
+	for _, l := range strings.Split(val, ",") {
+		if !validTag.MatchString(l) {
+			return nil, fmt.Errorf("label should be a comma separated list of letters, digits, '.', '-', and '_', got %q", val)
+		}
+		labels = append(labels, l)
+	}
+	return labels, nil
+}
+
+// deselected reports whether the block of cmd is left out of the run by its
+// labels, or those of the commands stacked on it.
+func (e *embedder) deselected(cmd *command) bool {
+	if e.skipLabels == nil && e.onlyLabels == nil {
+		return false
+	}
+	selected := e.onlyLabels == nil
+	for _, c := range append([]*command{cmd}, cmd.stacked...) {
+		for _, l := range c.labels {
+			if e.skipLabels[l] {
+				return true
+			}
+			selected = selected || e.onlyLabels[l]
+		}
+	}
+	return !selected
+}
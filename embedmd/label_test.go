@@ -0,0 +1,70 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLabels(t *testing.T) {
+	files := map[string][]byte{"code.go": []byte("new\n")}
+	tc := []struct {
+		name, in, out, err string
+		opts               []Option
+	}{
+		{name: "no selection",
+			in:  "[embedmd]:# (code.go label=slow)\n```go\nold\n```\n",
+			out: "[embedmd]:# (code.go label=slow)\n```go\nnew\n```\n"},
+		{name: "skipped",
+			in:   "[embedmd]:# (code.go label=slow)\n```go\nold\n```\n",
+			out:  "[embedmd]:# (code.go label=slow)\n```go\nold\n```\n",
+			opts: []Option{WithSkipLabels("slow")}},
+		{name: "other label",
+			in:   "[embedmd]:# (code.go label=fast)\n```go\nold\n```\n",
+			out:  "[embedmd]:# (code.go label=fast)\n```go\nnew\n```\n",
+			opts: []Option{WithSkipLabels("slow")}},
+		{name: "one of several",
+			in:   "[embedmd]:# (code.go label=net,slow)\n```go\nold\n```\n",
+			out:  "[embedmd]:# (code.go label=net,slow)\n```go\nold\n```\n",
+			opts: []Option{WithSkipLabels("slow")}},
+		{name: "only",
+			in:   "[embedmd]:# (code.go label=fast)\n```go\nold\n```\n[embedmd]:# (code.go)\n```go\nold\n```\n",
+			out:  "[embedmd]:# (code.go label=fast)\n```go\nnew\n```\n[embedmd]:# (code.go)\n```go\nold\n```\n",
+			opts: []Option{WithOnlyLabels("fast")}},
+		{name: "skip wins",
+			in:   "[embedmd]:# (code.go label=fast,slow)\n```go\nold\n```\n",
+			out:  "[embedmd]:# (code.go label=fast,slow)\n```go\nold\n```\n",
+			opts: []Option{WithOnlyLabels("fast"), WithSkipLabels("slow")}},
+		{name: "stacked",
+			in:   "[embedmd]:# (code.go)\n[embedmd]:# (gone.go label=slow)\n```go\nold\n```\n",
+			out:  "[embedmd]:# (code.go)\n[embedmd]:# (gone.go label=slow)\n```go\nold\n```\n",
+			opts: []Option{WithSkipLabels("slow")}},
+		{name: "bad label",
+			in:  "[embedmd]:# (code.go label=slow,)\n",
+			err: `1: label should be a comma separated list of letters, digits, '.', '-', and '_', got "slow,"`},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		opts := append([]Option{WithFetcher(mixedContentProvider{files: files})}, tt.opts...)
+		err := Process(&out, strings.NewReader(tt.in), opts...)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
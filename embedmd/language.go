@@ -0,0 +1,279 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// WithLanguage sets the language of the code embedded from files with the
+// given extension, e.g. "tsx", when commands don't set it, instead of the
+// extension itself. The extension can also be the whole name of the file,
+// e.g. "Dockerfile", which takes precedence over its extension. The language
+// "none" embeds the code without fences.
+func WithLanguage(ext, lang string) Option {
+	return Option{func(e *embedder) {
+		if e.languages == nil {
+			e.languages = map[string]string{}
+		}
+		e.languages[strings.TrimPrefix(ext, ".")] = lang
+	}}
+}
+
+// defaultLanguageConfidence is the minimum confidence of the languages
+// detected from content unless WithLanguageConfidence sets another.
+const defaultLanguageConfidence = 0.5
+
+// WithLanguageConfidence sets the minimum confidence, from 0 to 1, of the
+// language detected from the content of files without an extension for it
+// to be used: a shebang line or a package clause are certain, while a
+// Makefile rule or an SQL statement are only likely. Commands embedding code
+// whose language is detected with a lower confidence fail, asking for it to
+// be set. It defaults to 0.5.
+func WithLanguageConfidence(min float64) Option {
+	return Option{func(e *embedder) { e.langConfidence = min }}
+}
+
+// mapLanguage replaces the language inferred from the name of the file
+// embedded by cmd with the one set for it, if any, or known for well-known
+// file names.
+func (e *embedder) mapLanguage(cmd *command) {
+	if !cmd.inferredLang {
+		return
+	}
+	name := path.Base(sourceName(cmd.path))
+	lang, ok := e.languages[name]
+	if !ok {
+		cmd.lang = nameLanguage(name, cmd.lang)
+		if cmd.lang != "" {
+			lang, ok = e.languages[cmd.lang]
+		}
+	}
+	if ok {
+		cmd.lang, cmd.useFence = lang, lang != "none"
+	}
+}
+
+// knownNames maps the names of files that usually have no extension to the
+// language of their code.
+var knownNames = map[string]string{
+	"Dockerfile":     "dockerfile",
+	"Containerfile":  "dockerfile",
+	"Jenkinsfile":    "groovy",
+	"Makefile":       "makefile",
+	"GNUmakefile":    "makefile",
+	"makefile":       "makefile",
+	"Gemfile":        "ruby",
+	"Rakefile":       "ruby",
+	"Vagrantfile":    "ruby",
+	"Brewfile":       "ruby",
+	"Podfile":        "ruby",
+	"Fastfile":       "ruby",
+	"BUILD":          "starlark",
+	"WORKSPACE":      "starlark",
+	"Tiltfile":       "starlark",
+	"Justfile":       "just",
+	"justfile":       "just",
+	"CMakeLists.txt": "cmake",
+	".bashrc":        "bash",
+	".bash_profile":  "bash",
+	".zshrc":         "zsh",
+	".profile":       "sh",
+	".gitignore":     "gitignore",
+	".dockerignore":  "gitignore",
+	".editorconfig":  "ini",
+	".gitconfig":     "ini",
+}
+
+// genericExts are the extensions of templates, examples and backups, which
+// tell nothing about the language of their code but may follow the
+// extension that does, as in config.yaml.example.
+var genericExts = map[string]bool{
+	"in":       true,
+	"tmpl":     true,
+	"tpl":      true,
+	"template": true,
+	"example":  true,
+	"sample":   true,
+	"dist":     true,
+	"orig":     true,
+	"bak":      true,
+}
+
+// nameLanguage returns the language of the code in the file with the given
+// name and extension, without its dot: the one known for the name, also
+// when it is capitalized and followed by a variant as in Dockerfile.dev, the
+// extension before a generic one, or the extension itself. It returns ""
+// when the language is to be detected from the content.
+func nameLanguage(name, ext string) string {
+	if lang, ok := knownNames[name]; ok {
+		return lang
+	}
+	if stem, _, _ := strings.Cut(name, "."); stem != "" && unicode.IsUpper(rune(stem[0])) && ext != "lock" {
+		if lang, ok := knownNames[stem]; ok {
+			return lang
+		}
+	}
+	if genericExts[ext] {
+		name = strings.TrimSuffix(name, "."+ext)
+		return nameLanguage(name, strings.TrimPrefix(path.Ext(name), "."))
+	}
+	return ext
+}
+
+// interpreters maps the interpreters of shebang lines, without their
+// version, to the language of their scripts.
+var interpreters = map[string]string{
+	"sh":      "sh",
+	"bash":    "bash",
+	"zsh":     "zsh",
+	"ksh":     "sh",
+	"dash":    "sh",
+	"fish":    "fish",
+	"python":  "python",
+	"ruby":    "ruby",
+	"perl":    "perl",
+	"php":     "php",
+	"node":    "javascript",
+	"nodejs":  "javascript",
+	"deno":    "typescript",
+	"lua":     "lua",
+	"Rscript": "r",
+	"pwsh":    "powershell",
+	"awk":     "awk",
+}
+
+var (
+	interpreterVersion = regexp.MustCompile(`[0-9.]+$`)
+	goPackage          = regexp.MustCompile(`^package [A-Za-z_][A-Za-z0-9_]*$`)
+	javaPackage        = regexp.MustCompile(`^package [A-Za-z_][A-Za-z0-9_.]*;$`)
+	dockerInstruction  = regexp.MustCompile(`^(FROM|ARG) \S`)
+)
+
+// contentRules tell the language of code from lines that are typical of it,
+// with the confidence that they do.
+var contentRules = []struct {
+	re         *regexp.Regexp
+	lang       string
+	confidence float64
+}{
+	{regexp.MustCompile(`(?i)^<!DOCTYPE html`), "html", 1},
+	{regexp.MustCompile(`^<html[\s>]`), "html", 0.9},
+	{regexp.MustCompile(`^pipeline\s*\{$`), "groovy", 0.9},
+	{regexp.MustCompile(`^node\s*(\(.*\))?\s*\{$`), "groovy", 0.7},
+	{regexp.MustCompile(`^\.PHONY\s*:`), "makefile", 0.9},
+	{regexp.MustCompile(`^[A-Za-z0-9_.$()/-]+\s*:=\s*`), "makefile", 0.6},
+	{regexp.MustCompile(`^(RUN|COPY|ADD|ENTRYPOINT|CMD|WORKDIR|EXPOSE) \S`), "dockerfile", 0.6},
+	{regexp.MustCompile(`^source ['"]https://rubygems\.org`), "ruby", 0.9},
+	{regexp.MustCompile(`^(gem|require) ['"][\w/.-]+['"]`), "ruby", 0.6},
+	{regexp.MustCompile(`^def \w+\(.*\)( -> .+)?:$`), "python", 0.8},
+	{regexp.MustCompile(`^(import [\w.]+( as \w+)?|from [\w.]+ import [\w*, ]+)$`), "python", 0.6},
+	{regexp.MustCompile(`^(const|let|var) \w+ = require\(['"]`), "javascript", 0.8},
+	{regexp.MustCompile(`^set -[euxo]+( pipefail)?$`), "bash", 0.7},
+	{regexp.MustCompile(`^(if|while) \[\[? .* \]\]?; then$`), "bash", 0.7},
+	{regexp.MustCompile(`^export [A-Za-z_][A-Za-z0-9_]*=`), "sh", 0.5},
+	{regexp.MustCompile(`(?i)^(CREATE (TABLE|INDEX|VIEW)|INSERT INTO|ALTER TABLE|SELECT .* FROM)\b`), "sql", 0.7},
+	{regexp.MustCompile(`^\[\[?[\w.-]+\]\]?$`), "toml", 0.4},
+	{regexp.MustCompile(`^[\w-]+ = ("|\[)`), "toml", 0.6},
+}
+
+// maxDetectedLines is the number of lines of code that detectLanguage reads
+// to match contentRules.
+const maxDetectedLines = 100
+
+// detectLanguage returns the language of the code in b, told from its
+// content, and the confidence, from 0 to 1, that it is, or "" if it can't be
+// told.
+func detectLanguage(b []byte) (string, float64) {
+	if lang := shebangLanguage(b); lang != "" {
+		return lang, 1
+	}
+	switch {
+	case bytes.HasPrefix(b, []byte("<?php")):
+		return "php", 1
+	case bytes.HasPrefix(b, []byte("<?xml")):
+		return "xml", 1
+	case bytes.HasPrefix(b, []byte("---\n")), bytes.HasPrefix(b, []byte("---\r\n")):
+		return "yaml", 0.9
+	}
+	if t := bytes.TrimSpace(b); len(t) > 0 && (t[0] == '{' || t[0] == '[') && json.Valid(t) {
+		return "json", 1
+	}
+	// Otherwise the first line of code, after comments, tells Go and Java
+	// from their package clause, and Dockerfiles from their first
+	// instruction, while any of the lines that follow can match the rules,
+	// the most confident one winning.
+	var (
+		lang       string
+		confidence float64
+		n          int
+	)
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() && n < maxDetectedLines {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "//") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if n++; n == 1 {
+			switch {
+			case goPackage.MatchString(line):
+				return "go", 1
+			case javaPackage.MatchString(line):
+				return "java", 1
+			case dockerInstruction.MatchString(line):
+				return "dockerfile", 0.9
+			}
+		}
+		for _, r := range contentRules {
+			if r.confidence > confidence && r.re.MatchString(line) {
+				lang, confidence = r.lang, r.confidence
+			}
+		}
+	}
+	return lang, confidence
+}
+
+// shebangLanguage returns the language of the script in b, told from its
+// shebang line, if any.
+func shebangLanguage(b []byte) string {
+	if !bytes.HasPrefix(b, []byte("#!")) {
+		return ""
+	}
+	line, _, _ := bytes.Cut(b[2:], []byte("\n"))
+	f := strings.Fields(string(line))
+	if len(f) == 0 {
+		return ""
+	}
+	name := path.Base(f[0])
+	if name == "env" {
+		// The interpreter follows the options of env, such as -S.
+		name = ""
+		for _, arg := range f[1:] {
+			if !strings.HasPrefix(arg, "-") {
+				name = arg
+				break
+			}
+		}
+	}
+	if lang, ok := interpreters[name]; ok {
+		return lang
+	}
+	return interpreters[interpreterVersion.ReplaceAllString(name, "")]
+}
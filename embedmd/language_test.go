@@ -0,0 +1,155 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLanguage(t *testing.T) {
+	files := map[string][]byte{"app.tsx": []byte("let x = 1;\n"), "notes.txt": []byte("notes\n")}
+	tc := []struct {
+		name, in, out string
+	}{
+		{name: "mapped", in: "[embedmd]:# (app.tsx)\n", out: "[embedmd]:# (app.tsx)\n```typescript\nlet x = 1;\n```\n"},
+		{name: "set by the command", in: "[embedmd]:# (app.tsx tsx)\n", out: "[embedmd]:# (app.tsx tsx)\n```tsx\nlet x = 1;\n```\n"},
+		{name: "none", in: "[embedmd]:# (notes.txt)\n", out: "[embedmd]:# (notes.txt)\n<!-- embedmd block start -->\nnotes\n<!-- embedmd block end -->\n"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}),
+			WithLanguage("tsx", "typescript"), WithLanguage(".txt", "none"))
+		if err != nil {
+			t.Errorf("case [%s]: %v", tt.name, err)
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
+
+func TestLanguageByName(t *testing.T) {
+	files := map[string][]byte{
+		"Dockerfile":          []byte("FROM alpine\n"),
+		"main.tf":             []byte("variable \"x\" {}\n"),
+		"deploy":              []byte("#!/usr/bin/env bash\necho hi\n"),
+		"unknown":             []byte("some text\n"),
+		"Jenkinsfile":         []byte("node {\n}\n"),
+		"config.yaml.example": []byte("a: 1\n"),
+		"schema":              []byte("CREATE TABLE t (id int);\n"),
+		"settings":            []byte("[server]\n"),
+	}
+	urls := map[string][]byte{"https://example.com/raw/main?plain=1": []byte("package main\n")}
+	tc := []struct {
+		name, in, out, err string
+	}{
+		{name: "file name", in: "[embedmd]:# (Dockerfile)\n", out: "[embedmd]:# (Dockerfile)\n```docker\nFROM alpine\n```\n"},
+		{name: "extension with a dot", in: "[embedmd]:# (main.tf)\n", out: "[embedmd]:# (main.tf)\n```hcl\nvariable \"x\" {}\n```\n"},
+		{name: "detected", in: "[embedmd]:# (deploy)\n", out: "[embedmd]:# (deploy)\n```bash\n#!/usr/bin/env bash\necho hi\n```\n"},
+		{name: "detected from URL", in: "[embedmd]:# (https://example.com/raw/main?plain=1)\n",
+			out: "[embedmd]:# (https://example.com/raw/main?plain=1)\n```go\npackage main\n```\n"},
+		{name: "undetected", in: "[embedmd]:# (unknown)\n",
+			err: "1: language is required as unknown has no extension and its content doesn't tell it"},
+		{name: "known file name", in: "[embedmd]:# (Jenkinsfile)\n", out: "[embedmd]:# (Jenkinsfile)\n```groovy\nnode {\n}\n```\n"},
+		{name: "generic extension", in: "[embedmd]:# (config.yaml.example)\n", out: "[embedmd]:# (config.yaml.example)\n```yaml\na: 1\n```\n"},
+		{name: "detected by a rule", in: "[embedmd]:# (schema)\n", out: "[embedmd]:# (schema)\n```sql\nCREATE TABLE t (id int);\n```\n"},
+		{name: "not confident", in: "[embedmd]:# (settings)\n",
+			err: "1: language is required as settings has no extension and its content only suggests toml (confidence 0.40, below 0.50)"},
+		{name: "undetected with language", in: "[embedmd]:# (unknown text)\n", out: "[embedmd]:# (unknown text)\n```text\nsome text\n```\n"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files, urls: urls}),
+			WithLanguage("Dockerfile", "docker"), WithLanguage(".tf", "hcl"))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tc := []struct {
+		name, in, lang string
+		confidence     float64
+	}{
+		{name: "shebang", in: "#!/bin/bash\necho hi\n", lang: "bash", confidence: 1},
+		{name: "shebang with env", in: "#!/usr/bin/env -S python3 -u\nprint(1)\n", lang: "python", confidence: 1},
+		{name: "shebang with version", in: "#!/usr/bin/ruby2.7\n", lang: "ruby", confidence: 1},
+		{name: "unknown interpreter", in: "#!/usr/bin/frobnicate\n", lang: ""},
+		{name: "php", in: "<?php echo 1;\n", lang: "php", confidence: 1},
+		{name: "xml", in: "<?xml version=\"1.0\"?>\n<a/>\n", lang: "xml", confidence: 1},
+		{name: "yaml", in: "---\na: 1\n", lang: "yaml", confidence: 0.9},
+		{name: "json", in: "\n{\"a\": [1, 2]}\n", lang: "json", confidence: 1},
+		{name: "invalid json", in: "{a: 1}\n", lang: ""},
+		{name: "go", in: "// Copyright\n\npackage main\n", lang: "go", confidence: 1},
+		{name: "java", in: "package com.example;\n", lang: "java", confidence: 1},
+		{name: "dockerfile", in: "# syntax=docker/dockerfile:1\nFROM alpine\n", lang: "dockerfile", confidence: 0.9},
+		{name: "text", in: "hello\npackage main\n", lang: ""},
+		{name: "jenkinsfile", in: "pipeline {\n  agent any\n}\n", lang: "groovy", confidence: 0.9},
+		{name: "makefile", in: "GO := go\n\n.PHONY: test\ntest:\n\t$(GO) test ./...\n", lang: "makefile", confidence: 0.9},
+		{name: "python", in: "import os\n\ndef main():\n    print(os.getcwd())\n", lang: "python", confidence: 0.8},
+		{name: "shell without shebang", in: "set -euo pipefail\necho hi\n", lang: "bash", confidence: 0.7},
+		{name: "sql", in: "-- users\nCREATE TABLE users (id int);\n", lang: "sql", confidence: 0.7},
+		{name: "html", in: "<!doctype html>\n<p>hi</p>\n", lang: "html", confidence: 1},
+		{name: "toml section only", in: "[server]\nport: 80\n", lang: "toml", confidence: 0.4},
+		{name: "toml", in: "[server]\nhost = \"localhost\"\n", lang: "toml", confidence: 0.6},
+	}
+	for _, tt := range tc {
+		lang, confidence := detectLanguage([]byte(tt.in))
+		if lang != tt.lang || confidence != tt.confidence {
+			t.Errorf("case [%s]: expected language %q with confidence %v; got %q with %v", tt.name, tt.lang, tt.confidence, lang, confidence)
+		}
+	}
+}
+
+func TestNameLanguage(t *testing.T) {
+	tc := []struct {
+		name, ext, lang string
+	}{
+		{name: "main.go", ext: "go", lang: "go"},
+		{name: "Jenkinsfile", ext: "", lang: "groovy"},
+		{name: "Dockerfile.dev", ext: "dev", lang: "dockerfile"},
+		{name: "BUILD.bazel", ext: "bazel", lang: "starlark"},
+		{name: "Gemfile.lock", ext: "lock", lang: "lock"},
+		{name: "makefile.go", ext: "go", lang: "go"},
+		{name: "config.yaml.example", ext: "example", lang: "yaml"},
+		{name: "Makefile.in", ext: "in", lang: "makefile"},
+		{name: "setup.tmpl", ext: "tmpl", lang: ""},
+		{name: "deploy", ext: "", lang: ""},
+	}
+	for _, tt := range tc {
+		if got := nameLanguage(tt.name, tt.ext); got != tt.lang {
+			t.Errorf("case [%s]: expected language %q; got %q", tt.name, tt.lang, got)
+		}
+	}
+}
+
+func TestLanguageConfidence(t *testing.T) {
+	files := map[string][]byte{"settings": []byte("[server]\n")}
+	var out bytes.Buffer
+	err := Process(&out, strings.NewReader("[embedmd]:# (settings)\n"), WithFetcher(mixedContentProvider{files: files}),
+		WithLanguageConfidence(0.4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "[embedmd]:# (settings)\n```toml\n[server]\n```\n"; got != want {
+		t.Errorf("expected output\n%q\ngot\n%q", want, got)
+	}
+}
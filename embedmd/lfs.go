@@ -0,0 +1,277 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// lfsPointer matches the pointer files Git LFS stores in place of the files
+// it tracks.
+var lfsPointer = regexp.MustCompile(`^version https://git-lfs\.github\.com/spec/v1\n(?:[a-z0-9.-]+ .*\n)*?oid sha256:([0-9a-f]{64})\nsize ([0-9]+)\n$`)
+
+// parseLFSPointer returns the object of the Git LFS pointer b, if it's one.
+func parseLFSPointer(b []byte) (oid string, size int64, ok bool) {
+	if len(b) > 1024 {
+		return "", 0, false
+	}
+	m := lfsPointer.FindSubmatch(bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n")))
+	if m == nil {
+		return "", 0, false
+	}
+	size, err := strconv.ParseInt(string(m[2]), 10, 64)
+	return string(m[1]), size, err == nil
+}
+
+// LFSMiddleware replaces the Git LFS pointers fetched by the wrapped Fetcher
+// with the content of the objects they point to, if they're at most maxBytes
+// long, or of any size if maxBytes is 0. The objects of local files, and of
+// git:// paths, are read from the LFS store of their repository, or fetched
+// with git lfs. Those of URLs of raw files on one of the given forges, or on
+// a public one, are downloaded with client, http.DefaultClient if nil, from
+// the LFS API of their repository.
+func LFSMiddleware(client *http.Client, maxBytes int64, forges ...Forge) Middleware {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(next Fetcher) Fetcher {
+		return FetcherContextFunc(func(ctx context.Context, dir, path string) ([]byte, error) {
+			b, err := FetchContext(ctx, next, dir, path)
+			if err != nil {
+				return b, err
+			}
+			oid, size, ok := parseLFSPointer(b)
+			if !ok {
+				return b, nil
+			}
+			if maxBytes > 0 && size > maxBytes {
+				return nil, fmt.Errorf("%s is a Git LFS object of %d bytes, more than the limit of %d", path, size, maxBytes)
+			}
+			switch {
+			case isURL(path):
+				b, err = fetchLFSObject(ctx, client, path, oid, size, forges)
+			case isRepoPath(path):
+				err = errors.New("Git LFS objects of other repositories aren't supported")
+			default:
+				b, err = readLFSObject(ctx, dir, path, b, oid)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("could not fetch the Git LFS object of %s: %v", path, err)
+			}
+			if sum := sha256.Sum256(b); hex.EncodeToString(sum[:]) != oid || int64(len(b)) != size {
+				return nil, fmt.Errorf("Git LFS object of %s doesn't match its pointer", path)
+			}
+			return b, nil
+		})
+	}
+}
+
+// readLFSObject returns the object oid of the pointer b of the local file,
+// or git:// path, from the LFS store of its repository, or with git lfs
+// smudge if it isn't there, which fetches it.
+func readLFSObject(ctx context.Context, dir, path string, pointer []byte, oid string) ([]byte, error) {
+	file := path
+	if isGitPath(path) {
+		file, _, _ = cutRevision(path)
+	} else if archive, _, ok := cutArchive(path); ok {
+		file = archive
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, filepath.FromSlash(file))
+	}
+	wd := filepath.Dir(file)
+	if gitDir, err := runGit(ctx, wd, "rev-parse", "--git-common-dir"); err == nil {
+		store := strings.TrimSpace(string(gitDir))
+		if !filepath.IsAbs(store) {
+			store = filepath.Join(wd, store)
+		}
+		if b, err := os.ReadFile(filepath.Join(store, "lfs", "objects", oid[:2], oid[2:4], oid)); err == nil {
+			return b, nil
+		}
+	}
+	cmd := exec.CommandContext(ctx, "git", "-C", wd, "lfs", "smudge", "--", filepath.Base(file))
+	cmd.Stdin = bytes.NewReader(pointer)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// fetchLFSObject downloads the object oid of the pointer at the raw URL of a
+// file on a forge, from the LFS API of its repository.
+func fetchLFSObject(ctx context.Context, client *http.Client, rawURL, oid string, size int64, forges []Forge) ([]byte, error) {
+	endpoint, ok := lfsEndpoint(rawURL, forges)
+	if !ok {
+		return nil, errors.New("the repository of the URL is unknown, as it's not the raw URL of a file on a forge")
+	}
+	body, err := json.Marshal(map[string]any{
+		"operation": "download",
+		"transfers": []string{"basic"},
+		"objects":   []map[string]any{{"oid": oid, "size": size}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.git-lfs+json")
+	req.Header.Set("Content-Type", "application/vnd.git-lfs+json")
+	var batch struct {
+		Objects []struct {
+			Actions struct {
+				Download *struct {
+					Href   string            `json:"href"`
+					Header map[string]string `json:"header"`
+				} `json:"download"`
+			} `json:"actions"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"objects"`
+	}
+	if err := lfsRequest(client, req, func(r io.Reader) error { return json.NewDecoder(r).Decode(&batch) }); err != nil {
+		return nil, err
+	}
+	if len(batch.Objects) != 1 {
+		return nil, errors.New("bad response of the LFS API")
+	}
+	obj := batch.Objects[0]
+	switch {
+	case obj.Error != nil:
+		return nil, errors.New(obj.Error.Message)
+	case obj.Actions.Download == nil:
+		return nil, errors.New("no download of the object in the response of the LFS API")
+	}
+	if req, err = http.NewRequestWithContext(ctx, "GET", obj.Actions.Download.Href, nil); err != nil {
+		return nil, err
+	}
+	for k, v := range obj.Actions.Download.Header {
+		req.Header.Set(k, v)
+	}
+	var b []byte
+	err = lfsRequest(client, req, func(r io.Reader) (err error) {
+		b, err = io.ReadAll(io.LimitReader(r, size+1))
+		return err
+	})
+	return b, err
+}
+
+// lfsRequest sends req with client, reading the body of the response with
+// read if it succeeds.
+func lfsRequest(client *http.Client, req *http.Request, read func(io.Reader) error) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL.Redacted(), res.Status)
+	}
+	return read(res.Body)
+}
+
+// lfsEndpoint returns the URL of the batch endpoint of the LFS API of the
+// repository of the file at the raw URL of a file on one of the forges, or
+// a public one.
+func lfsEndpoint(rawURL string, forges []Forge) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	for _, list := range [][]Forge{forges, publicForges} {
+		for _, f := range list {
+			f = f.withDefaults()
+			ff, ok := parseRawURL(f.RawURL, rawURL)
+			if !ok || (ff.Host != "" && !strings.EqualFold(u.Hostname(), f.Host)) {
+				continue
+			}
+			if ff.Host == "" {
+				ff.Host = f.Host
+			}
+			repo := strings.TrimSuffix(ff.expand(f.CloneURL), "/")
+			if !strings.HasSuffix(repo, ".git") {
+				repo += ".git"
+			}
+			return repo + "/info/lfs/objects/batch", true
+		}
+	}
+	return "", false
+}
+
+// rawURLPlaceholder matches the placeholders of the templates of raw URLs,
+// once quoted as regular expressions.
+var rawURLPlaceholder = regexp.MustCompile(`\\\{(host|owner|repo|ref|path)\\\}`)
+
+// parseRawURL locates the file at rawURL, if it matches the template of the
+// raw URLs of a forge.
+func parseRawURL(template, rawURL string) (ForgeFile, bool) {
+	re := rawURLPlaceholder.ReplaceAllStringFunc(regexp.QuoteMeta(template), func(p string) string {
+		name := strings.Trim(p, `\{}`)
+		switch name {
+		case "owner", "path":
+			return "(?P<" + name + ">.+)"
+		}
+		return "(?P<" + name + ">[^/?]+)"
+	})
+	r, err := regexp.Compile("^" + re + "$")
+	if err != nil {
+		return ForgeFile{}, false
+	}
+	m := r.FindStringSubmatch(rawURL)
+	if m == nil {
+		return ForgeFile{}, false
+	}
+	var ff ForgeFile
+	for i, name := range r.SubexpNames() {
+		switch name {
+		case "host":
+			ff.Host = m[i]
+		case "owner":
+			ff.Owner = m[i]
+		case "repo":
+			ff.Repo = m[i]
+		case "ref":
+			ff.Ref = m[i]
+		case "path":
+			ff.Path = m[i]
+		}
+	}
+	return ff, true
+}
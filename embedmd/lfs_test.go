@@ -0,0 +1,157 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func lfsPointerOf(b []byte) (string, []byte) {
+	sum := sha256.Sum256(b)
+	oid := hex.EncodeToString(sum[:])
+	return oid, []byte(fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", oid, len(b)))
+}
+
+func TestParseLFSPointer(t *testing.T) {
+	oid, pointer := lfsPointerOf([]byte("hello\n"))
+	tc := []struct {
+		name string
+		in   string
+		size int64
+		ok   bool
+	}{
+		{name: "pointer", in: string(pointer), size: 6, ok: true},
+		{name: "crlf", in: "version https://git-lfs.github.com/spec/v1\r\noid sha256:" + oid + "\r\nsize 6\r\n", size: 6, ok: true},
+		{name: "extension", in: "version https://git-lfs.github.com/spec/v1\next-0-foo sha256:" + oid + "\noid sha256:" + oid + "\nsize 6\n", size: 6, ok: true},
+		{name: "code", in: "package main\n"},
+		{name: "no size", in: "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\n"},
+		{name: "trailing text", in: string(pointer) + "more\n"},
+	}
+	for _, tt := range tc {
+		gotOID, size, ok := parseLFSPointer([]byte(tt.in))
+		if ok != tt.ok || size != tt.size || (ok && gotOID != oid) {
+			t.Errorf("case [%s]: got %q, %d, %v; want %d, %v", tt.name, gotOID, size, ok, tt.size, tt.ok)
+		}
+	}
+}
+
+func TestLFSMiddlewareAPI(t *testing.T) {
+	object := []byte("big data\n")
+	oid, pointer := lfsPointerOf(object)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/o/r.git/info/lfs/objects/batch":
+			if r.Method != "POST" || r.Header.Get("Accept") != "application/vnd.git-lfs+json" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"objects": []any{map[string]any{
+				"oid": oid, "size": len(object),
+				"actions": map[string]any{"download": map[string]any{
+					"href": server.URL + "/objects/" + oid, "header": map[string]string{"Authorization": "token"},
+				}},
+			}}})
+		case "/objects/" + oid:
+			if r.Header.Get("Authorization") != "token" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			w.Write(object)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	forge := Forge{Host: "forge.example.com", Kind: "gitea", RawURL: server.URL + "/{owner}/{repo}/raw/{ref}/{path}", CloneURL: server.URL + "/{owner}/{repo}"}
+
+	raw := server.URL + "/o/r/raw/main/data.txt"
+	other := server.URL + "/o/other/raw/main/data.txt"
+	elsewhere := "https://example.com/data.txt"
+	next := mixedContentProvider{urls: map[string][]byte{raw: pointer, other: pointer, elsewhere: pointer}}
+
+	tc := []struct {
+		name, path string
+		max        int64
+		out, err   string
+	}{
+		{name: "download", path: raw, out: string(object)},
+		{name: "within the limit", path: raw, max: int64(len(object)), out: string(object)},
+		{name: "over the limit", path: raw, max: 4,
+			err: raw + " is a Git LFS object of 9 bytes, more than the limit of 4"},
+		{name: "unknown repository", path: other,
+			err: "could not fetch the Git LFS object of " + other + ": POST " + server.URL + "/o/other.git/info/lfs/objects/batch: 404 Not Found"},
+		{name: "not on a forge", path: elsewhere,
+			err: "could not fetch the Git LFS object of " + elsewhere + ": the repository of the URL is unknown, as it's not the raw URL of a file on a forge"},
+	}
+	for _, tt := range tc {
+		b, err := ChainFetcher(next, LFSMiddleware(server.Client(), tt.max, forge)).Fetch("", tt.path)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if string(b) != tt.out {
+			t.Errorf("case [%s]: got %q; want %q", tt.name, b, tt.out)
+		}
+	}
+}
+
+func TestLFSMiddlewareLocal(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "-C", dir, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	object := []byte("stored data\n")
+	oid, pointer := lfsPointerOf(object)
+	store := filepath.Join(dir, ".git", "lfs", "objects", oid[:2], oid[2:4])
+	if err := os.MkdirAll(store, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(store, oid), object, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data.txt"), pointer, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "code.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := ChainFetcher(NewFetcher(nil), LFSMiddleware(nil, 0))
+	for path, want := range map[string]string{"data.txt": string(object), "code.go": "package main\n"} {
+		b, err := f.Fetch(dir, path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		} else if string(b) != want {
+			t.Errorf("%s: got %q; want %q", path, b, want)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(store, oid), []byte("corrupted\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := f.Fetch(dir, "data.txt")
+	eqErr(t, "corrupted", err, "Git LFS object of data.txt doesn't match its pointer")
+}
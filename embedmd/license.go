@@ -0,0 +1,137 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// A LicenseRule describes how license headers are handled for the sources
+// matching Pattern. Patterns are matched against the host and path of URLs,
+// or the slash separated path of files, and support ** to match any number
+// of directories.
+type LicenseRule struct {
+	Pattern string
+	// Strip removes recognized license headers from the embedded content.
+	Strip bool
+	// RequireCaption fails any command embedding a matching source that has
+	// no caption attribute, so third party code is always attributed.
+	RequireCaption bool
+}
+
+// WithLicenseRules sets the rules used to handle license headers.
+// All rules matching a source apply.
+func WithLicenseRules(rules ...LicenseRule) Option {
+	return Option{func(e *embedder) { e.licenseRules = append(e.licenseRules, rules...) }}
+}
+
+// applyLicensePolicy strips the license header of b if required by the rules
+// or the command, and checks that attribution requirements are met.
+func (e *embedder) applyLicensePolicy(cmd *command, b []byte) ([]byte, error) {
+	strip := false
+	key := sourceKey(cmd.path)
+	for _, r := range e.licenseRules {
+		if !matchPattern(r.Pattern, key) {
+			continue
+		}
+		strip = strip || r.Strip
+		if r.RequireCaption && cmd.caption == "" {
+			return nil, fmt.Errorf("%s requires an attribution caption", cmd.path)
+		}
+	}
+	switch cmd.license {
+	case "strip":
+		strip = true
+	case "keep":
+		strip = false
+	}
+	if !strip {
+		return b, nil
+	}
+	return stripLicense(b), nil
+}
+
+// licenseMarkers are the words identifying a comment as a license header.
+var licenseMarkers = [][]byte{
+	[]byte("copyright"),
+	[]byte("license"),
+	[]byte("spdx-license-identifier"),
+}
+
+// stripLicense removes the leading comment block from b if it looks like a
+// license header, together with the blank lines following it. A leading
+// shebang line is preserved.
+func stripLicense(b []byte) []byte {
+	var shebang []byte
+	if bytes.HasPrefix(b, []byte("#!")) {
+		i := bytes.IndexByte(b, '\n') + 1
+		if i == 0 {
+			return b
+		}
+		shebang, b = b[:i], b[i:]
+	}
+
+	rest := bytes.TrimLeft(b, "\n")
+	n := commentLen(rest)
+	if n == 0 || !isLicense(rest[:n]) {
+		return b
+	}
+	rest = bytes.TrimLeft(rest[n:], "\n")
+	return append(shebang, rest...)
+}
+
+func isLicense(comment []byte) bool {
+	comment = bytes.ToLower(comment)
+	for _, m := range licenseMarkers {
+		if bytes.Contains(comment, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// commentLen returns the length of the comment at the beginning of b,
+// including its trailing newline, or 0 if b doesn't start with a comment.
+func commentLen(b []byte) int {
+	for _, block := range [][2]string{{"/*", "*/"}, {"<!--", "-->"}} {
+		if !bytes.HasPrefix(b, []byte(block[0])) {
+			continue
+		}
+		end := bytes.Index(b, []byte(block[1]))
+		if end < 0 {
+			return 0
+		}
+		end += len(block[1])
+		if i := bytes.IndexByte(b[end:], '\n'); i >= 0 {
+			return end + i + 1
+		}
+		return len(b)
+	}
+
+	for _, prefix := range []string{"//", "#", "--", ";"} {
+		n := 0
+		for n < len(b) && bytes.HasPrefix(b[n:], []byte(prefix)) {
+			i := bytes.IndexByte(b[n:], '\n')
+			if i < 0 {
+				return len(b)
+			}
+			n += i + 1
+		}
+		if n > 0 {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import "testing"
+
+func TestStripLicense(t *testing.T) {
+	tc := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{name: "line comments",
+			in:  "// Copyright 2016 Google Inc.\n// Apache License.\n\npackage main\n",
+			out: "package main\n"},
+		{name: "block comment",
+			in:  "/*\n * SPDX-License-Identifier: MIT\n */\n\nint main;\n",
+			out: "int main;\n"},
+		{name: "hash comments with shebang",
+			in:  "#!/bin/sh\n# Copyright the authors.\n\necho hi\n",
+			out: "#!/bin/sh\necho hi\n"},
+		{name: "not a license",
+			in:  "// Package main does things.\npackage main\n",
+			out: "// Package main does things.\npackage main\n"},
+		{name: "no comment",
+			in:  "package main\n",
+			out: "package main\n"},
+		{name: "unclosed block comment",
+			in:  "/* Copyright\npackage main\n",
+			out: "/* Copyright\npackage main\n"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(stripLicense([]byte(tt.in))); got != tt.out {
+				t.Errorf("case [%s]: expected %q; got %q", tt.name, tt.out, got)
+			}
+		})
+	}
+}
+
+func TestLicensePolicy(t *testing.T) {
+	const src = "// Copyright the authors.\n\npackage main\n"
+	tc := []struct {
+		name  string
+		cmd   command
+		rules []LicenseRule
+		out   string
+		err   string
+	}{
+		{name: "no rules",
+			cmd: command{path: "a/b.go"}, out: src},
+		{name: "strip by pattern",
+			cmd:   command{path: "third_party/x/b.go"},
+			rules: []LicenseRule{{Pattern: "third_party/**", Strip: true}},
+			out:   "package main\n"},
+		{name: "keep overrides rule",
+			cmd:   command{path: "third_party/b.go", license: "keep"},
+			rules: []LicenseRule{{Pattern: "third_party/**", Strip: true}},
+			out:   src},
+		{name: "strip from command",
+			cmd: command{path: "b.go", license: "strip"}, out: "package main\n"},
+		{name: "missing caption",
+			cmd:   command{path: "https://example.com/x/b.go"},
+			rules: []LicenseRule{{Pattern: "example.com/**", RequireCaption: true}},
+			err:   "https://example.com/x/b.go requires an attribution caption"},
+		{name: "caption given",
+			cmd:   command{path: "https://example.com/x/b.go", caption: "From example.com"},
+			rules: []LicenseRule{{Pattern: "example.com/**", RequireCaption: true}},
+			out:   src},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			e := embedder{licenseRules: tt.rules}
+			b, err := e.applyLicensePolicy(&tt.cmd, []byte(src))
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if string(b) != tt.out {
+				t.Errorf("case [%s]: expected %q; got %q", tt.name, tt.out, b)
+			}
+		})
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tc := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"**/*.go", "cmd/main.go", true},
+		{"**/*.go", "main.go", true},
+		{"examples/**", "examples/a/b.sh", true},
+		{"examples/**", "other/a.sh", false},
+		{"raw.githubusercontent.com/org/**", "raw.githubusercontent.com/org/repo/main/x.go", true},
+	}
+	for _, tt := range tc {
+		if got := matchPattern(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v; want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
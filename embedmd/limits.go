@@ -0,0 +1,81 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// WithMaxSize fails to fetch the URLs whose content is larger than n bytes,
+// once decoded, without reading more than that. A size of 0 or less sets no
+// limit.
+func WithMaxSize(n int64) FetcherOption {
+	return FetcherOption{func(f *fetcher) { f.maxSize = max(n, 0) }}
+}
+
+// WithContentTypes fails to fetch the URLs returning content whose media type
+// matches none of the given ones, such as text/plain or application/json.
+// A type can end with /* to match all its subtypes, as in text/*. Responses
+// without a Content-Type header are accepted.
+func WithContentTypes(types ...string) FetcherOption {
+	return FetcherOption{func(f *fetcher) { f.contentTypes = types }}
+}
+
+// errTooLarge is returned when content is larger than the size limit.
+type errTooLarge int64
+
+func (e errTooLarge) Error() string {
+	return fmt.Sprintf("content is larger than the limit of %d bytes", int64(e))
+}
+
+// readLimited reads r until EOF, failing once more than limit bytes are
+// read. A limit of 0 reads everything.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	if limit == 0 {
+		return io.ReadAll(r)
+	}
+	b, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err == nil && int64(len(b)) > limit {
+		return nil, errTooLarge(limit)
+	}
+	return b, err
+}
+
+// checkResponse checks the size and content type announced by the headers of
+// res against the limits of the fetcher, before its body is read.
+func (f *fetcher) checkResponse(res *http.Response) error {
+	encoding := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding")))
+	if f.maxSize > 0 && res.ContentLength > f.maxSize && (encoding == "" || encoding == "identity") {
+		return errTooLarge(f.maxSize)
+	}
+	v := res.Header.Get("Content-Type")
+	if len(f.contentTypes) == 0 || v == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(v)
+	if err != nil {
+		return fmt.Errorf("bad content type %q: %v", v, err)
+	}
+	for _, t := range f.contentTypes {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == mediaType || strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*")) {
+			return nil
+		}
+	}
+	return fmt.Errorf("content type %s is not one of %s", mediaType, strings.Join(f.contentTypes, ", "))
+}
@@ -0,0 +1,89 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetcher_Limits(t *testing.T) {
+	var zipped bytes.Buffer
+	zw := gzip.NewWriter(&zipped)
+	zw.Write([]byte(strings.Repeat("a", 1000)))
+	zw.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small.go":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte("package main"))
+		case "/large.bin":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(bytes.Repeat([]byte{0}, 1000))
+		case "/chunked.go":
+			w.Header().Set("Content-Type", "text/plain")
+			for range 10 {
+				w.Write(bytes.Repeat([]byte("a"), 100))
+				w.(http.Flusher).Flush()
+			}
+		case "/zipped.go":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(zipped.Bytes())
+		case "/untyped.go":
+			w.Header()["Content-Type"] = nil
+			w.Write([]byte("package main"))
+		}
+	}))
+	defer server.Close()
+
+	tc := []struct {
+		name string
+		path string
+		opts []FetcherOption
+		want string
+		err  string
+	}{
+		{name: "under the limit", path: "/small.go", opts: []FetcherOption{WithMaxSize(100)}, want: "package main"},
+		{name: "announced too large", path: "/large.bin", opts: []FetcherOption{WithMaxSize(100)}, err: "content is larger than the limit of 100 bytes"},
+		{name: "read too large", path: "/chunked.go", opts: []FetcherOption{WithMaxSize(100)}, err: "content is larger than the limit of 100 bytes"},
+		{name: "decoded too large", path: "/zipped.go", opts: []FetcherOption{WithMaxSize(100)}, err: "content is larger than the limit of 100 bytes"},
+		{name: "no limit", path: "/large.bin", want: strings.Repeat("\x00", 1000)},
+		{name: "content type", path: "/small.go", opts: []FetcherOption{WithContentTypes("text/plain")}, want: "package main"},
+		{name: "content type wildcard", path: "/small.go", opts: []FetcherOption{WithContentTypes("application/json", "text/*")}, want: "package main"},
+		{
+			name: "refused content type",
+			path: "/large.bin",
+			opts: []FetcherOption{WithContentTypes("text/*", "application/json")},
+			err:  "content type application/octet-stream is not one of text/*, application/json",
+		},
+		{name: "no content type", path: "/untyped.go", opts: []FetcherOption{WithContentTypes("text/*")}, want: "package main"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewFetcher(nil, tt.opts...).Fetch("", server.URL+tt.path)
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if string(b) != tt.want {
+				t.Errorf("expected %q; got %q", tt.want, b)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// lineRangeSuffix matches the line range at the end of a path, written as in
+// GitHub permalinks: #L10 for a single line or #L10-L42 for several. The
+// columns of selections, as in #L10C5-L42C12, are ignored.
+var lineRangeSuffix = regexp.MustCompile(`#L(\d+)(?:C\d+)?(?:-L(\d+)(?:C\d+)?)?$`)
+
+// A lineRange selects the lines from and to of a source, both included and
+// numbered from 1.
+type lineRange struct{ from, to int }
+
+func (r lineRange) String() string {
+	if r.from == r.to {
+		return fmt.Sprintf("L%d", r.from)
+	}
+	return fmt.Sprintf("L%d-L%d", r.from, r.to)
+}
+
+// cutLineRange removes the line range at the end of path, if any, returning
+// it too.
+func cutLineRange(path string) (string, *lineRange, error) {
+	m := lineRangeSuffix.FindStringSubmatch(path)
+	if m == nil {
+		return path, nil, nil
+	}
+	r := &lineRange{}
+	var err error
+	if r.from, err = strconv.Atoi(m[1]); err != nil {
+		return "", nil, fmt.Errorf("bad line range %q", m[0])
+	}
+	r.to = r.from
+	if m[2] != "" {
+		if r.to, err = strconv.Atoi(m[2]); err != nil {
+			return "", nil, fmt.Errorf("bad line range %q", m[0])
+		}
+	}
+	switch {
+	case r.from == 0:
+		return "", nil, fmt.Errorf("bad line range %s, lines are numbered from 1", r)
+	case r.to < r.from:
+		return "", nil, fmt.Errorf("bad line range %s, it ends before it starts", r)
+	}
+	return path[:len(path)-len(m[0])], r, nil
+}
+
+// extractLines returns the lines of b in the range r.
+func extractLines(b []byte, r lineRange) ([]byte, error) {
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	if r.to > len(lines) {
+		return nil, fmt.Errorf("line range %s is out of range, the source has %d lines", r, len(lines))
+	}
+	return bytes.Join(lines[r.from-1:r.to], nil), nil
+}
@@ -0,0 +1,204 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// A Diagnostic is a problem found by Lint in a command, at a line and a
+// column of the document, both numbered from 1. Columns count bytes.
+type Diagnostic struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+// Lint checks the commands of the document read from in without running
+// them, so nothing is fetched: it reports the commands that can't be
+// parsed, such as those with unknown attributes or unbalanced regular
+// expressions, the regular expressions that don't compile, and the local
+// files that don't exist, resolved as Process would, and the lines that
+// look like mistyped commands. The diagnostics are in the order of the
+// document.
+func Lint(in io.Reader, opts ...Option) ([]Diagnostic, error) {
+	e, b, err := newEmbedder(in, opts)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n")
+	var diags []Diagnostic
+	var cmds []*command
+	reported := map[int]bool{}
+	for {
+		cmds = nil
+		err := e.parse(io.Discard, bytes.NewReader(b), func(_ io.Writer, cmd *command) error {
+			if !e.skipped[cmd.line] {
+				cmds = append(cmds, cmd)
+			}
+			return nil
+		})
+		var le *lineError
+		if err == nil || !errors.As(err, &le) || reported[le.line] || le.line > len(lines) {
+			break
+		}
+		reported[le.line] = true
+		diags = append(diags, e.lintError(le.line, lines[le.line-1], le.err))
+		// The line is blanked so the rest of the document is checked.
+		lines[le.line-1] = ""
+		b = []byte(strings.Join(lines, "\n"))
+	}
+	for _, t := range e.typos(b) {
+		diags = append(diags, Diagnostic{t.line, t.column, typoMessage(t)})
+	}
+	for _, cmd := range cmds {
+		diags = append(diags, e.lintCommands(lines, append([]*command{cmd}, cmd.stacked...))...)
+	}
+	slices.SortStableFunc(diags, func(a, b Diagnostic) int {
+		return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+	return diags, nil
+}
+
+// commandSyntaxes returns the syntaxes of the commands in the documents.
+func (e *embedder) commandSyntaxes() []Syntax {
+	switch {
+	case e.literal():
+		return []Syntax{literalSyntaxes[e.format]}
+	case len(e.syntaxes) == 0:
+		return []Syntax{LinkSyntax}
+	}
+	return e.syntaxes
+}
+
+// lintArgs returns the arguments of the command in line with their
+// columns, and the column of the argument list.
+func (e *embedder) lintArgs(line string) (args []string, cols []int, col int, err error) {
+	list, offset, _ := commandArgsAt(line, e.commandSyntaxes())
+	trimmed := strings.TrimSpace(list)
+	offset += strings.Index(list, trimmed)
+	if len(trimmed) < 2 || trimmed[0] != '(' || trimmed[len(trimmed)-1] != ')' {
+		return nil, nil, offset + 1, errors.New("argument list should be in parenthesis")
+	}
+	args, offsets, err := fieldsAt(trimmed[1 : len(trimmed)-1])
+	if oe := (*offsetError)(nil); errors.As(err, &oe) {
+		return nil, nil, offset + 1, &offsetError{offset + 1 + oe.offset, oe.err}
+	}
+	for _, o := range offsets {
+		cols = append(cols, offset+1+o+1)
+	}
+	return args, cols, offset + 1, nil
+}
+
+// lintError returns the diagnostic of the error found parsing the command
+// in the given line, at the argument at fault if it's known.
+func (e *embedder) lintError(n int, line string, err error) Diagnostic {
+	args, cols, col, aerr := e.lintArgs(line)
+	d := Diagnostic{Line: n, Column: col, Message: err.Error()}
+	if oe := (*offsetError)(nil); errors.As(aerr, &oe) {
+		d.Column = oe.offset + 1
+		return d
+	}
+	// Attributes are parsed again on their own to find the one failing.
+	for i, arg := range args {
+		if key, val, ok := cutAttr(arg); ok {
+			if aerr := (&command{}).setAttr(key, val); aerr != nil && aerr.Error() == d.Message {
+				d.Column = cols[i]
+				break
+			}
+		}
+	}
+	return d
+}
+
+// lintCommands returns the diagnostics of the commands of a block, which
+// were parsed, finding their sources and compiling their regular
+// expressions.
+func (e *embedder) lintCommands(lines []string, cmds []*command) []Diagnostic {
+	var diags []Diagnostic
+	part := 0
+	for i, cmd := range cmds {
+		if i > 0 && cmd.line != cmds[i-1].line {
+			part = 0
+		}
+		args, cols, _, err := e.lintArgs(lines[cmd.line-1])
+		if err != nil || len(args) == 0 {
+			continue
+		}
+		// Each part of the argument list follows a +.
+		first := 0
+		for p := part; p > 0 && first < len(args); first++ {
+			if args[first] == "+" {
+				p--
+			}
+		}
+		part++
+		at := func(arg string) int {
+			for j := first; j < len(args) && args[j] != "+"; j++ {
+				if args[j] == arg {
+					return cols[j]
+				}
+			}
+			return cols[first]
+		}
+		for _, re := range []*string{cmd.start, cmd.end} {
+			if re == nil || *re == "" || *re == "$" {
+				continue
+			}
+			compiled, err := compileSlashed(*re)
+			switch {
+			case err != nil:
+				diags = append(diags, Diagnostic{cmd.line, at(*re), err.Error()})
+			case re == cmd.start && cmd.group > compiled.NumSubexp():
+				diags = append(diags, Diagnostic{cmd.line, at(*re), fmt.Sprintf("%s has no group %d", *re, cmd.group)})
+			}
+		}
+		if err := e.lintSource(cmd); err != nil {
+			diags = append(diags, Diagnostic{cmd.line, cols[first], err.Error()})
+		}
+	}
+	return diags
+}
+
+// lintSource fails if the source of cmd is a local file that doesn't exist.
+func (e *embedder) lintSource(cmd *command) error {
+	if err := e.resolvePath(cmd); err != nil {
+		return err
+	}
+	path, err := e.expandAlias(cmd.path)
+	if err != nil {
+		return err
+	}
+	if isURL(path) || isExecPath(path) || isGitPath(path) || isRepoPath(path) {
+		return nil
+	}
+	if archive, _, ok := cutArchive(path); ok {
+		path = archive
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(e.baseDir, filepath.FromSlash(path))
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("could not find %s", cmd.path)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "code.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tc := []struct {
+		name, in string
+		opts     []Option
+		want     []Diagnostic
+	}{
+		{name: "valid",
+			in: "[embedmd]:# (code.go /package/ $)\n[embedmd]:# (https://example.com/x.go)\n"},
+		{name: "unknown attribute",
+			in:   "[embedmd]:# (code.go captoin=x)\n",
+			want: []Diagnostic{{1, 22, `unknown attribute "captoin"`}}},
+		{name: "bad value",
+			in:   "[embedmd]:# (code.go hl=x)\n",
+			want: []Diagnostic{{1, 22, `hl should be a comma separated list of lines or ranges, such as 3-5,8, got "x"`}}},
+		{name: "unbalanced regexp",
+			in:   "[embedmd]:# (code.go /package)\n",
+			want: []Diagnostic{{1, 22, "unbalanced /"}}},
+		{name: "bad regexp",
+			in:   "[embedmd]:# (code.go /package/ /[a-/)\n",
+			want: []Diagnostic{{1, 32, "error parsing regexp: invalid character class range: `-`"}}},
+		{name: "missing group",
+			in:   "[embedmd]:# (code.go /package (.*)/ group=2)\n",
+			want: []Diagnostic{{1, 22, "/package (.*)/ has no group 2"}}},
+		{name: "typo",
+			in:   "[embedmd]: # (code.go)\n",
+			want: []Diagnostic{{1, 1, `not an embedmd command, so it is ignored; did you mean "[embedmd]:# (code.go)"?`}}},
+		{name: "no parenthesis",
+			in:   "[embedmd]:# code.go\n",
+			want: []Diagnostic{{1, 13, "argument list should be in parenthesis"}}},
+		{name: "missing file name",
+			in:   "[embedmd]:# ()\n",
+			want: []Diagnostic{{1, 13, "missing file name"}}},
+		{name: "missing files",
+			in:   "[embedmd]:# (gone.go)\n[embedmd]:# (code.go + other.go)\n",
+			want: []Diagnostic{{1, 14, "could not find gone.go"}, {2, 24, "could not find other.go"}}},
+		{name: "basedir",
+			in:   "[embedmd]:# (basedir sub)\n[embedmd]:# (code.go)\n",
+			want: []Diagnostic{{2, 14, "could not find sub/code.go"}}},
+		{name: "unknown alias",
+			in:   "[embedmd]:# (@lib/code.go)\n",
+			want: []Diagnostic{{1, 14, "unknown alias @lib"}}},
+		{name: "all errors",
+			in:   "[embedmd]:# (code.go foo=1)\n```go\n```\n\n[embedmd]:# (gone.go)\n\n[embedmd]:# (code.go bar=1)\n",
+			want: []Diagnostic{{1, 22, `unknown attribute "foo"`}, {5, 14, "could not find gone.go"}, {7, 22, `unknown attribute "bar"`}}},
+		{name: "code blocks",
+			in: "```Markdown\n[embedmd]:# (gone.go foo=1)\n```\n"},
+		{name: "comments",
+			in:   "<!-- embedmd: code.go captoin=x -->\n",
+			opts: []Option{WithSyntaxes(CommentSyntax)},
+			want: []Diagnostic{{1, 23, `unknown attribute "captoin"`}}},
+		{name: "rst",
+			in:   ".. embedmd: gone.go\n",
+			opts: []Option{WithFormat(RSTFormat)},
+			want: []Diagnostic{{1, 13, "could not find gone.go"}}},
+	}
+	for _, tt := range tc {
+		got, err := Lint(strings.NewReader(tt.in), append([]Option{WithBaseDir(dir)}, tt.opts...)...)
+		if err != nil {
+			t.Errorf("case [%s]: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("case [%s]: expected %v; got %v", tt.name, tt.want, got)
+		}
+	}
+}
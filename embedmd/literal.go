@@ -0,0 +1,280 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// The syntaxes of the commands in the formats other than markdown, which
+// are comments.
+const (
+	rstSyntax      Syntax = "rst"
+	asciidocSyntax Syntax = "asciidoc"
+)
+
+var literalSyntaxes = map[Format]Syntax{RSTFormat: rstSyntax, AsciiDocFormat: asciidocSyntax}
+
+var (
+	rstCommand      = regexp.MustCompile(`^\.\.\s+embedmd:\s+(.*?)\s*$`)
+	asciidocCommand = regexp.MustCompile(`^//\s*embedmd:\s+(.*?)\s*$`)
+)
+
+// processLiteral works as process for the documents in format f, other
+// than markdown, whose commands are followed by literal blocks.
+func processLiteral(out io.Writer, in io.Reader, run commandRunner, f Format, env map[string]string) error {
+	s := &countingScanner{Scanner: bufio.NewScanner(in), syntaxes: []Syntax{literalSyntaxes[f]}, env: env}
+	run = withDirectives(run)
+	more := s.Scan()
+	for more {
+		var err error
+		if isCommand(s, s.Text()) {
+			more, err = literalCmd(out, s, run, f)
+		} else {
+			more = passLiteral(out, s, f)
+		}
+		if _, ok := err.(*lineError); ok {
+			return err
+		}
+		if err != nil {
+			return &lineError{s.line, err}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return &lineError{s.line, err}
+	}
+	return nil
+}
+
+// passLiteral prints the current line, and the literal block it opens, if
+// any, as commands in them are ignored. It reports whether there is a line
+// after them, which is the current one.
+func passLiteral(out io.Writer, s textScanner, f Format) bool {
+	line := s.Text()
+	fmt.Fprintln(out, line)
+	if f == RSTFormat && strings.HasSuffix(strings.TrimSpace(line), "::") {
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		for s.Scan() {
+			l := s.Text()
+			if strings.TrimSpace(l) != "" && len(l)-len(strings.TrimLeft(l, " \t")) <= indent {
+				return true
+			}
+			fmt.Fprintln(out, l)
+		}
+		return false
+	}
+	if f == AsciiDocFormat && isDelimiter(line) {
+		for s.Scan() {
+			fmt.Fprintln(out, s.Text())
+			if s.Text() == line {
+				break
+			}
+		}
+	}
+	return s.Scan()
+}
+
+// isDelimiter reports whether the line delimits an AsciiDoc block whose
+// content is kept verbatim: a listing, literal, passthrough, or comment
+// block, or a fenced code block.
+func isDelimiter(line string) bool {
+	if line == "```" {
+		return true
+	}
+	return len(line) >= 4 && strings.Trim(line, line[:1]) == "" && strings.Contains("-.+/", line[:1])
+}
+
+// literalCmd runs the command in the current line, as parsingCmd does in
+// markdown, reporting whether there is a line after its block, which is the
+// current one.
+func literalCmd(out io.Writer, s textScanner, run commandRunner, f Format) (bool, error) {
+	cmd, err := scanCommand(out, s)
+	if err != nil {
+		return false, err
+	}
+	if cmd.varDirective {
+		return s.Scan(), nil
+	}
+	if cmd.dirDirective {
+		return s.Scan(), run(out, cmd)
+	}
+
+	cmd.stacked = cmd.parts
+	more := s.Scan()
+	for more && isCommand(s, s.Text()) {
+		c, err := scanCommand(out, s)
+		if err != nil {
+			return false, err
+		}
+		cmd.stacked = append(append(cmd.stacked, c), c.parts...)
+		more = s.Scan()
+	}
+
+	var rest []string
+	if more {
+		if cmd.block, rest, more, err = readLiteral(s, f); err != nil {
+			return false, err
+		}
+	}
+	if err := run(out, cmd); err != nil {
+		if _, ok := err.(*lineError); ok {
+			return false, err
+		}
+		return false, &lineError{cmd.line, err}
+	}
+	for _, l := range rest {
+		fmt.Fprintln(out, l)
+	}
+	// Text right after an indented block would continue it.
+	if f == RSTFormat && more && len(rest) == 0 {
+		fmt.Fprintln(out)
+	}
+	return more, nil
+}
+
+// readLiteral returns the literal block generated by a previous run starting
+// at the current line, if any, the lines read that don't belong to it, and
+// whether there is a line after them, which is the current one.
+func readLiteral(s textScanner, f Format) (block []byte, rest []string, more bool, err error) {
+	var b bytes.Buffer
+	if f == RSTFormat {
+		// Blocks are separated from commands by a blank line, and are made
+		// of the following indented lines.
+		for more = true; more && strings.TrimSpace(s.Text()) == ""; more = s.Scan() {
+			rest = append(rest, s.Text())
+		}
+		if !more || !strings.HasPrefix(s.Text(), ".. code-block::") {
+			return nil, rest, more, nil
+		}
+		for _, l := range rest {
+			fmt.Fprintln(&b, l)
+		}
+		fmt.Fprintln(&b, s.Text())
+		rest = nil
+		for more = s.Scan(); more; more = s.Scan() {
+			switch l := s.Text(); {
+			case strings.TrimSpace(l) == "":
+				rest = append(rest, l)
+			case l[0] == ' ' || l[0] == '\t':
+				for _, l := range rest {
+					fmt.Fprintln(&b, l)
+				}
+				rest = nil
+				fmt.Fprintln(&b, l)
+			default:
+				return b.Bytes(), rest, true, nil
+			}
+		}
+		return b.Bytes(), rest, false, nil
+	}
+
+	// Blocks may have a title, then their attributes and delimiters.
+	line := s.Text()
+	if len(line) > 1 && line[0] == '.' && line[1] != '.' && line[1] != ' ' {
+		rest = append(rest, line)
+		if !s.Scan() {
+			return nil, rest, false, nil
+		}
+		line = s.Text()
+	}
+	if !strings.HasPrefix(line, "[source") {
+		return nil, rest, true, nil
+	}
+	rest = append(rest, line)
+	if !s.Scan() {
+		return nil, rest, false, nil
+	}
+	delim := s.Text()
+	if !isDelimiter(delim) || delim[0] != '-' {
+		return nil, rest, true, nil
+	}
+	for _, l := range rest {
+		fmt.Fprintln(&b, l)
+	}
+	fmt.Fprintln(&b, delim)
+	for {
+		if !s.Scan() {
+			return nil, nil, false, fmt.Errorf("unbalanced code section")
+		}
+		fmt.Fprintln(&b, s.Text())
+		if s.Text() == delim {
+			return b.Bytes(), nil, s.Scan(), nil
+		}
+	}
+}
+
+// writeLiteral writes the embedded content b as the literal block of the
+// format of the documents, as described by cmd.
+func (e *embedder) writeLiteral(w io.Writer, cmd *command, b []byte) {
+	start := 0
+	if cmd.linenos {
+		start = cmd.lineStart
+		if start == 0 {
+			start = max(cmd.region[0], 1)
+		}
+	}
+	if e.format == RSTFormat {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, strings.TrimSpace(".. code-block:: "+cmd.lang))
+		if cmd.caption != "" {
+			fmt.Fprintf(w, "   :caption: %s\n", cmd.caption)
+		}
+		if cmd.linenos {
+			fmt.Fprintln(w, "   :linenos:")
+		}
+		if start > 1 {
+			fmt.Fprintf(w, "   :lineno-start: %d\n", start)
+		}
+		if len(cmd.highlights) > 0 {
+			fmt.Fprintf(w, "   :emphasize-lines: %s\n", joinRanges(cmd.highlights, ","))
+		}
+		fmt.Fprintln(w)
+		writePrefixed(w, b, "   ")
+		return
+	}
+
+	if cmd.caption != "" {
+		fmt.Fprintf(w, ".%s\n", cmd.caption)
+	}
+	attrs := []string{"source"}
+	if cmd.lang != "" || cmd.annotated() {
+		attrs = append(attrs, cmd.lang)
+	}
+	if cmd.linenos {
+		attrs = append(attrs, "linenums")
+	}
+	if start > 1 {
+		attrs = append(attrs, fmt.Sprintf("start=%d", start))
+	}
+	if len(cmd.highlights) > 0 {
+		attrs = append(attrs, fmt.Sprintf("highlight=%q", joinRanges(cmd.highlights, ",")))
+	}
+	fmt.Fprintf(w, "[%s]\n", strings.Join(attrs, ","))
+	// The delimiter is longer than the lines of the content that would
+	// close the block.
+	delim := "----"
+	for _, l := range strings.Split(string(b), "\n") {
+		if len(l) >= len(delim) && strings.Trim(l, "-") == "" {
+			delim = l + "-"
+		}
+	}
+	fmt.Fprintln(w, delim)
+	w.Write(b) //nolint:errcheck
+	fmt.Fprintln(w, delim)
+}
@@ -0,0 +1,114 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLiteralFormats(t *testing.T) {
+	files := map[string][]byte{
+		"code.go":   []byte("package main\n\nfunc main() {}\n"),
+		"notes.txt": []byte("a\n----\n"),
+	}
+	const rstBlock = "\n.. code-block:: go\n\n   package main\n\n   func main() {}\n"
+	const adocBlock = "[source,go]\n----\npackage main\n\nfunc main() {}\n----\n"
+	tc := []struct {
+		name, in, out, err string
+		format             Format
+		opts               []Option
+	}{
+		{name: "rst", format: RSTFormat,
+			in:  "Title\n=====\n\n.. embedmd: code.go\n\nText.\n",
+			out: "Title\n=====\n\n.. embedmd: code.go\n" + rstBlock + "\nText.\n"},
+		{name: "rst replaced", format: RSTFormat,
+			in:  ".. embedmd: code.go\n\n.. code-block:: go\n\n   old\n\n\nText.\n",
+			out: ".. embedmd: code.go\n" + rstBlock + "\n\nText.\n"},
+		{name: "rst text right after", format: RSTFormat,
+			in:  ".. embedmd: code.go\nText.\n",
+			out: ".. embedmd: code.go\n" + rstBlock + "\nText.\n"},
+		{name: "rst end of file", format: RSTFormat,
+			in:  ".. embedmd: (code.go)",
+			out: ".. embedmd: (code.go)\n" + rstBlock},
+		{name: "rst options", format: RSTFormat,
+			in:  ".. embedmd: code.go caption=Main linenos=true start=10 hl=3\n",
+			out: ".. embedmd: code.go caption=Main linenos=true start=10 hl=3\n\n.. code-block:: go\n   :caption: Main\n   :linenos:\n   :lineno-start: 10\n   :emphasize-lines: 3\n\n   package main\n\n   func main() {}\n"},
+		{name: "rst literal block", format: RSTFormat,
+			in:  "Example::\n\n   .. embedmd: code.go\n\n.. embedmd: code.go\n",
+			out: "Example::\n\n   .. embedmd: code.go\n\n.. embedmd: code.go\n" + rstBlock},
+		{name: "asciidoc", format: AsciiDocFormat,
+			in:  "= Title\n\n// embedmd: code.go\n\nText.\n",
+			out: "= Title\n\n// embedmd: code.go\n" + adocBlock + "\nText.\n"},
+		{name: "asciidoc replaced", format: AsciiDocFormat,
+			in:  "// embedmd: code.go\n.Old\n[source,go]\n----\nold\n----\nText.\n",
+			out: "// embedmd: code.go\n" + adocBlock + "Text.\n"},
+		{name: "asciidoc options", format: AsciiDocFormat,
+			in:  "// embedmd: code.go caption=Main linenos=true start=10 hl=1-2\n",
+			out: "// embedmd: code.go caption=Main linenos=true start=10 hl=1-2\n.Main\n[source,go,linenums,start=10,highlight=\"1-2\"]\n----\npackage main\n\nfunc main() {}\n----\n"},
+		{name: "asciidoc title of something else", format: AsciiDocFormat,
+			in:  "// embedmd: code.go\n.Table\n|===\n",
+			out: "// embedmd: code.go\n" + adocBlock + ".Table\n|===\n"},
+		{name: "asciidoc listing block", format: AsciiDocFormat,
+			in:  "----\n// embedmd: code.go\n----\n",
+			out: "----\n// embedmd: code.go\n----\n"},
+		{name: "asciidoc delimiter in content", format: AsciiDocFormat,
+			in:  "// embedmd: notes.txt\n",
+			out: "// embedmd: notes.txt\n[source,txt]\n-----\na\n----\n-----\n"},
+		{name: "asciidoc unbalanced", format: AsciiDocFormat,
+			in:  "// embedmd: code.go\n[source,go]\n----\nold\n",
+			err: "4: unbalanced code section"},
+		{name: "markdown commands ignored", format: RSTFormat,
+			in:  "[embedmd]:# (code.go)\n",
+			out: "[embedmd]:# (code.go)\n"},
+		{name: "split", format: RSTFormat,
+			in:  ".. embedmd: code.go split=1\n",
+			err: "1: split can only be used in markdown documents, not rst ones"},
+		{name: "checksums", format: AsciiDocFormat, opts: []Option{WithChecksums()},
+			err: "checksums can only be used in markdown documents, not asciidoc ones"},
+		{name: "bad format", format: "html",
+			err: `bad format "html", should be markdown, rst, asciidoc, or notebook`},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		opts := append([]Option{WithFetcher(mixedContentProvider{files: files}), WithFormat(tt.format)}, tt.opts...)
+		err := Process(&out, strings.NewReader(tt.in), opts...)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+			continue
+		}
+		var again bytes.Buffer
+		if err := Process(&again, strings.NewReader(tt.out), opts...); err != nil || again.String() != tt.out {
+			t.Errorf("case [%s]: expected output to be stable; got\n%q, %v", tt.name, again.String(), err)
+		}
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	for path, want := range map[string]Format{
+		"docs/index.rst":  RSTFormat,
+		"docs/index.adoc": AsciiDocFormat,
+		"README.asciidoc": AsciiDocFormat,
+		"README.md":       MarkdownFormat,
+		"notes.txt":       MarkdownFormat,
+	} {
+		if got := FormatOf(path); got != want {
+			t.Errorf("FormatOf(%q) = %q; want %q", path, got, want)
+		}
+	}
+}
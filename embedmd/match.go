@@ -0,0 +1,101 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// parseGroup parses the value of the group attribute, the number of a
+// capture group of the start regular expression.
+func parseGroup(val string) (int, error) {
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("group should be the number of a capture group, from 1, got %q", val)
+	}
+	return n, nil
+}
+
+// extractMatches returns the content embedded by cmd when it selects a
+// capture group, with group, or every match, with match=all: the group, or
+// the span of the start regular expression, or from it to the next match of
+// end, of each match. Matches are concatenated, each starting on a new line
+// and separated by the sep line if it's set.
+func extractMatches(b []byte, cmd *command) ([]byte, error) {
+	start, err := compileSlashed(*cmd.start)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.group > start.NumSubexp() {
+		return nil, fmt.Errorf("%s has no group %d", *cmd.start, cmd.group)
+	}
+	var end *regexp.Regexp
+	if cmd.end != nil && *cmd.end != "$" {
+		if end, err = compileSlashed(*cmd.end); err != nil {
+			return nil, err
+		}
+	}
+
+	n := 1
+	if cmd.matchAll {
+		n = -1
+	}
+	var spans [][]byte
+	next := 0
+	for _, loc := range start.FindAllSubmatchIndex(b, n) {
+		from, to := loc[2*cmd.group], loc[2*cmd.group+1]
+		switch {
+		case loc[0] < next:
+			// The match is inside the previous span.
+			continue
+		case from < 0:
+			// The group isn't part of this match.
+			continue
+		case cmd.end == nil:
+		case end == nil:
+			to = len(b)
+		default:
+			e := end.FindIndex(b[from:])
+			if e == nil {
+				return nil, fmt.Errorf("could not match %q", *cmd.end)
+			}
+			to = from + e[1]
+		}
+		spans = append(spans, b[from:to])
+		next = to
+	}
+	if len(spans) == 0 {
+		if cmd.group > 0 {
+			return nil, fmt.Errorf("could not match group %d of %q", cmd.group, *cmd.start)
+		}
+		return nil, fmt.Errorf("could not match %q", *cmd.start)
+	}
+
+	var out []byte
+	for i, s := range spans {
+		if i > 0 {
+			if !bytes.HasSuffix(out, []byte("\n")) {
+				out = append(out, '\n')
+			}
+			if cmd.sep != "" {
+				out = append(out, cmd.sep+"\n"...)
+			}
+		}
+		out = append(out, s...)
+	}
+	return out, nil
+}
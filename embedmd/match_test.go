@@ -0,0 +1,60 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExtractMatches(t *testing.T) {
+	files := map[string][]byte{
+		"code.go": []byte("package main\n\nconst version = \"1.2.0\"\n\nfunc kind(n int) string {\n\tswitch n {\n\tcase 0:\n\t\treturn \"zero\"\n\tcase 1:\n\t\treturn \"one\"\n\t}\n\treturn \"many\"\n}\n"),
+	}
+	tc := []struct {
+		name, in, out string
+		err           string
+	}{
+		{name: "group", in: "[embedmd]:# (code.go text /const version = (.*)/ group=1)\n",
+			out: "[embedmd]:# (code.go text /const version = (.*)/ group=1)\n```text\n\"1.2.0\"\n```\n"},
+		{name: "all matches", in: "[embedmd]:# (code.go /\\tcase .*:/ match=all)\n",
+			out: "[embedmd]:# (code.go /\\tcase .*:/ match=all)\n```go\n\tcase 0:\n\tcase 1:\n```\n"},
+		{name: "all regions", in: "[embedmd]:# (code.go /\\tcase/ /return.*/ match=all)\n",
+			out: "[embedmd]:# (code.go /\\tcase/ /return.*/ match=all)\n```go\n\tcase 0:\n\t\treturn \"zero\"\n\tcase 1:\n\t\treturn \"one\"\n```\n"},
+		{name: "all groups", in: "[embedmd]:# (code.go text /return \"([a-z]*)\"/ group=1 match=all sep=--)\n",
+			out: "[embedmd]:# (code.go text /return \"([a-z]*)\"/ group=1 match=all sep=--)\n```text\nzero\n--\none\n--\nmany\n```\n"},
+		{name: "first match", in: "[embedmd]:# (code.go /\\tcase .*:/ match=first)\n",
+			out: "[embedmd]:# (code.go /\\tcase .*:/ match=first)\n```go\n\tcase 0:\n```\n"},
+		{name: "to the end", in: "[embedmd]:# (code.go /\\tcase 1/ $ match=all)\n",
+			out: "[embedmd]:# (code.go /\\tcase 1/ $ match=all)\n```go\n\tcase 1:\n\t\treturn \"one\"\n\t}\n\treturn \"many\"\n}\n```\n"},
+		{name: "no match", in: "[embedmd]:# (code.go /default:/ match=all)\n", err: "1: could not extract content from code.go: could not match \"/default:/\""},
+		{name: "no end", in: "[embedmd]:# (code.go /case/ /default/ match=all)\n", err: "1: could not extract content from code.go: could not match \"/default/\""},
+		{name: "missing group", in: "[embedmd]:# (code.go /const (.*)/ group=2)\n", err: "1: could not extract content from code.go: /const (.*)/ has no group 2"},
+		{name: "bad group", in: "[embedmd]:# (code.go /const (.*)/ group=name)\n",
+			err: "1: group should be the number of a capture group, from 1, got \"name\""},
+		{name: "bad match", in: "[embedmd]:# (code.go /case/ match=some)\n", err: "1: match should be first or all, got \"some\""},
+		{name: "no regexp", in: "[embedmd]:# (code.go match=all)\n", err: "1: cannot use group or match without a start regular expression"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
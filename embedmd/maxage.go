@@ -0,0 +1,109 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithRefresh records today as the date the blocks with a maxage attribute
+// were refreshed, even if their content didn't change, once they have been
+// reviewed.
+func WithRefresh() Option {
+	return Option{func(e *embedder) { e.refresh = true }}
+}
+
+// WithMaxAgeErrors fails on the blocks not refreshed within their maxage,
+// rather than only reporting a warning.
+func WithMaxAgeErrors() Option {
+	return Option{func(e *embedder) { e.maxAgeErrors = true }}
+}
+
+// WithNow uses t as the current time, as when checking the age of blocks, so
+// runs are reproducible.
+func WithNow(t time.Time) Option {
+	return Option{func(e *embedder) { e.now = func() time.Time { return t } }}
+}
+
+// refreshedPrefix starts the comment recording the date a block with a
+// maxage attribute was last refreshed.
+const refreshedPrefix = "<!-- embedmd refreshed "
+
+const dateLayout = "2006-01-02"
+
+// parseAge parses a maximum age such as 90d, 12w, or 36h.
+func parseAge(s string) (time.Duration, error) {
+	day := 24 * time.Hour
+	var d time.Duration
+	var err error
+	switch unit := s[max(len(s)-1, 0):]; unit {
+	case "d", "w":
+		var n int
+		n, err = strconv.Atoi(s[:len(s)-1])
+		d = time.Duration(n) * day
+		if unit == "w" {
+			d *= 7
+		}
+	default:
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("maxage should be a positive number of days (d), weeks (w), or a duration such as 36h, got %q", s)
+	}
+	return d, nil
+}
+
+// checkAge writes the date the block of cmd was refreshed, which is today if
+// its content changed, and reports the blocks not refreshed within their
+// maxage.
+func (e *embedder) checkAge(w io.Writer, cmd *command, changed bool) error {
+	if cmd.maxAge == 0 {
+		return nil
+	}
+	now := time.Now
+	if e.now != nil {
+		now = e.now
+	}
+	today := now().UTC().Truncate(24 * time.Hour)
+	refreshed, err := time.Parse(dateLayout, cmd.refreshed)
+	switch {
+	case err != nil || changed || e.refresh:
+		refreshed = today
+	case today.Sub(refreshed) > cmd.maxAge:
+		sev := e.severity(cmd, FindingMaxAge)
+		if sev == SeverityIgnore {
+			break
+		}
+		msg := fmt.Sprintf("block not refreshed within maxage=%s, since %s, review it and refresh it", cmd.attrs["maxage"], cmd.refreshed)
+		if e.maxAgeErrors && sev == SeverityError {
+			if !e.quarantined(cmd) {
+				return errors.New(msg)
+			}
+			msg += ", quarantined"
+		}
+		e.warnf(cmd, "%s", msg)
+	}
+	_, err = fmt.Fprintf(w, "%s%s -->\n", refreshedPrefix, refreshed.Format(dateLayout))
+	return err
+}
+
+// refreshedDate returns the date recorded in a refreshed comment.
+func refreshedDate(line string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(line, refreshedPrefix), " -->")
+}
@@ -0,0 +1,122 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxAge(t *testing.T) {
+	now := time.Date(2026, 10, 15, 13, 0, 0, 0, time.UTC)
+	cmd := "[embedmd]:# (code.go maxage=90d)\n"
+	block := "```go\n" + content + "```\n"
+	refreshed := func(date string) string { return "<!-- embedmd refreshed " + date + " -->\n" }
+
+	tc := []struct {
+		name     string
+		in       string
+		opts     []Option
+		out      string
+		warnings []string
+		err      string
+	}{
+		{
+			name: "first generation",
+			in:   cmd + "Yay!\n",
+			out:  cmd + block + refreshed("2026-10-15") + "Yay!\n",
+		},
+		{
+			name: "unchanged content keeps the date",
+			in:   cmd + block + refreshed("2026-08-01") + "Yay!\n",
+			out:  cmd + block + refreshed("2026-08-01") + "Yay!\n",
+		},
+		{
+			name: "changed content is refreshed",
+			in:   cmd + "```go\nold\n```\n" + refreshed("2026-01-01"),
+			out:  cmd + block + refreshed("2026-10-15"),
+		},
+		{
+			name:     "expired",
+			in:       cmd + block + refreshed("2026-07-01"),
+			out:      cmd + block + refreshed("2026-07-01"),
+			warnings: []string{"1: block not refreshed within maxage=90d, since 2026-07-01, review it and refresh it"},
+		},
+		{
+			name: "expired in check mode",
+			in:   cmd + block + refreshed("2026-07-01"),
+			opts: []Option{WithMaxAgeErrors()},
+			err:  "1: block not refreshed within maxage=90d, since 2026-07-01, review it and refresh it",
+		},
+		{
+			name: "refreshed after review",
+			in:   cmd + block + refreshed("2026-07-01"),
+			opts: []Option{WithRefresh()},
+			out:  cmd + block + refreshed("2026-10-15"),
+		},
+		{
+			name: "with a checksum",
+			in:   cmd + block + refreshed("2026-08-01"),
+			opts: []Option{WithChecksums()},
+			out:  cmd + block + "<!-- embedmd checksum " + checksum([]byte(block)) + " -->\n" + refreshed("2026-08-01"),
+		},
+		{
+			name: "removed with the attribute",
+			in:   "[embedmd]:# (code.go)\n" + block + refreshed("2026-08-01"),
+			out:  "[embedmd]:# (code.go)\n" + block,
+		},
+		{
+			name: "in weeks",
+			in:   "[embedmd]:# (code.go maxage=2w)\n" + block + refreshed("2026-10-01"),
+			out:  "[embedmd]:# (code.go maxage=2w)\n" + block + refreshed("2026-10-01"),
+		},
+		{
+			name: "bad age",
+			in:   "[embedmd]:# (code.go maxage=soon)\n",
+			err:  `1: maxage should be a positive number of days (d), weeks (w), or a duration such as 36h, got "soon"`,
+		},
+		{
+			name: "empty age",
+			in:   "[embedmd]:# (code.go maxage=)\n",
+			err:  `1: maxage should be a positive number of days (d), weeks (w), or a duration such as 36h, got ""`,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var warnings []string
+			opts := append([]Option{
+				WithFetcher(mixedContentProvider{files: map[string][]byte{"code.go": []byte(content)}}),
+				WithNow(now),
+				WithWarnings(func(line int, msg string) {
+					warnings = append(warnings, fmt.Sprintf("%d: %s", line, msg))
+				}),
+			}, tt.opts...)
+			err := Process(&out, strings.NewReader(tt.in), opts...)
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if got := out.String(); got != tt.out {
+				t.Errorf("expected output\n%s\ngot\n%s", tt.out, got)
+			}
+			if fmt.Sprint(warnings) != fmt.Sprint(tt.warnings) {
+				t.Errorf("expected warnings %q; got %q", tt.warnings, warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// A Middleware wraps a Fetcher, adding behavior such as logging or caching
+// to it.
+type Middleware func(Fetcher) Fetcher
+
+// FetcherFunc is an adapter to use ordinary functions as Fetchers.
+type FetcherFunc func(dir, path string) ([]byte, error)
+
+// Fetch calls f(dir, path).
+func (f FetcherFunc) Fetch(dir, path string) ([]byte, error) { return f(dir, path) }
+
+// FetcherContextFunc is an adapter to use ordinary functions as
+// FetcherContexts. Fetch calls it with the background context.
+type FetcherContextFunc func(ctx context.Context, dir, path string) ([]byte, error)
+
+// Fetch calls f(context.Background(), dir, path).
+func (f FetcherContextFunc) Fetch(dir, path string) ([]byte, error) {
+	return f(context.Background(), dir, path)
+}
+
+// FetchContext calls f(ctx, dir, path).
+func (f FetcherContextFunc) FetchContext(ctx context.Context, dir, path string) ([]byte, error) {
+	return f(ctx, dir, path)
+}
+
+// FetchContext fetches path with f, passing it ctx if it's a FetcherContext.
+// It fails with the error of ctx if it's done, so Fetchers unaware of
+// contexts aren't called once it's canceled. Middleware call it to pass
+// the context to the Fetcher they wrap.
+func FetchContext(ctx context.Context, f Fetcher, dir, path string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if fc, ok := f.(FetcherContext); ok {
+		return fc.FetchContext(ctx, dir, path)
+	}
+	return f.Fetch(dir, path)
+}
+
+// ChainFetcher returns f wrapped with the given middleware. The first
+// middleware is the outermost one, seeing every fetch first.
+func ChainFetcher(f Fetcher, mw ...Middleware) Fetcher {
+	for i := len(mw) - 1; i >= 0; i-- {
+		f = mw[i](f)
+	}
+	return f
+}
+
+// ChainMiddleware combines several middleware into one, the first one being
+// the outermost.
+func ChainMiddleware(mw ...Middleware) Middleware {
+	return func(f Fetcher) Fetcher { return ChainFetcher(f, mw...) }
+}
+
+// LoggingMiddleware logs every fetch with logf, with its duration and size
+// or error.
+func LoggingMiddleware(logf func(format string, args ...interface{})) Middleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherContextFunc(func(ctx context.Context, dir, path string) ([]byte, error) {
+			start := time.Now()
+			b, err := FetchContext(ctx, next, dir, path)
+			if err != nil {
+				logf("fetch %s: %v (%v)", path, err, time.Since(start))
+			} else {
+				logf("fetch %s: %d bytes (%v)", path, len(b), time.Since(start))
+			}
+			return b, err
+		})
+	}
+}
+
+// CachingMiddleware fetches every path only once, returning the same content
+// or error for the following fetches.
+func CachingMiddleware() Middleware {
+	type result struct {
+		b   []byte
+		err error
+	}
+	return func(next Fetcher) Fetcher {
+		var mu sync.Mutex
+		cache := map[[2]string]result{}
+		return FetcherContextFunc(func(ctx context.Context, dir, path string) ([]byte, error) {
+			key := [2]string{dir, path}
+			if isURL(path) {
+				key[0] = ""
+			}
+			mu.Lock()
+			r, ok := cache[key]
+			mu.Unlock()
+			if ok {
+				return r.b, r.err
+			}
+			b, err := FetchContext(ctx, next, dir, path)
+			if ctx.Err() != nil {
+				// The fetch may have been canceled, and should be done
+				// again with another context.
+				return b, err
+			}
+			mu.Lock()
+			cache[key] = result{b, err}
+			mu.Unlock()
+			return b, err
+		})
+	}
+}
+
+// FetchMetrics holds the metrics recorded by MetricsMiddleware.
+type FetchMetrics struct {
+	mu       sync.Mutex
+	fetches  int
+	errors   int
+	bytes    int64
+	duration time.Duration
+}
+
+// Fetches returns the number of fetches and how many of them failed.
+func (m *FetchMetrics) Fetches() (total, failed int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.fetches, m.errors
+}
+
+// Bytes returns the number of bytes fetched.
+func (m *FetchMetrics) Bytes() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bytes
+}
+
+// Duration returns the total time spent fetching.
+func (m *FetchMetrics) Duration() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.duration
+}
+
+// MetricsMiddleware records the number, size, and duration of the fetches
+// in m.
+func MetricsMiddleware(m *FetchMetrics) Middleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherContextFunc(func(ctx context.Context, dir, path string) ([]byte, error) {
+			start := time.Now()
+			b, err := FetchContext(ctx, next, dir, path)
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.fetches++
+			if err != nil {
+				m.errors++
+			}
+			m.bytes += int64(len(b))
+			m.duration += time.Since(start)
+			return b, err
+		})
+	}
+}
+
+// AuthMiddleware fetches the URLs whose host and path match pattern with the
+// given client, calling authorize on every request so it can add
+// credentials to it. Other paths are fetched by the wrapped Fetcher. The
+// pattern is matched as in LicenseRule. The options are those of the
+// wrapped Fetcher, so its limits, retries, and cache apply to the URLs
+// matched too.
+func AuthMiddleware(pattern string, client *http.Client, authorize func(*http.Request) error, opts ...FetcherOption) Middleware {
+	return func(next Fetcher) Fetcher {
+		f := NewFetcher(client, opts...).(*fetcher)
+		f.authorize = authorize
+		return FetcherContextFunc(func(ctx context.Context, dir, path string) ([]byte, error) {
+			if isURL(path) && matchPattern(pattern, sourceKey(path)) {
+				return f.FetchContext(ctx, dir, path)
+			}
+			return FetchContext(ctx, next, dir, path)
+		})
+	}
+}
+
+// AllowMiddleware fails to fetch the URLs whose host and path match none of
+// the given patterns, which are matched as in LicenseRule. Other paths are
+// fetched by the wrapped Fetcher.
+func AllowMiddleware(patterns ...string) Middleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherContextFunc(func(ctx context.Context, dir, path string) ([]byte, error) {
+			if !isURL(path) || slices.ContainsFunc(patterns, func(p string) bool { return matchPattern(p, sourceKey(path)) }) {
+				return FetchContext(ctx, next, dir, path)
+			}
+			return nil, fmt.Errorf("%s is not in the allowed URLs", path)
+		})
+	}
+}
+
+// RootMiddleware fails to fetch the local files outside of root, those of
+// git paths and archives included, following symbolic links, so documents
+// that aren't trusted can't read the rest of the disk. URLs and the paths of
+// repositories are fetched by the wrapped Fetcher.
+func RootMiddleware(root string) Middleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherContextFunc(func(ctx context.Context, dir, path string) ([]byte, error) {
+			if !isURL(path) && !isRepoPath(path) && !inRoot(root, dir, path) {
+				return nil, fmt.Errorf("%s is outside of %s", path, root)
+			}
+			return FetchContext(ctx, next, dir, path)
+		})
+	}
+}
+
+// inRoot reports whether the local file at path, relative to dir, is under
+// root once symbolic links are followed.
+func inRoot(root, dir, path string) bool {
+	file := path
+	if isGitPath(path) {
+		file, _, _ = cutRevision(path)
+	}
+	if archive, _, ok := cutArchive(file); ok {
+		file = archive
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, filepath.FromSlash(file))
+	}
+	root, err := resolvePath(root)
+	if err != nil {
+		return false
+	}
+	if file, err = resolvePath(file); err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, file)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// resolvePath returns the absolute path of p with its symbolic links
+// followed, those of its longest existing parent if it doesn't exist.
+func resolvePath(p string) (string, error) {
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	rest := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(p), rest)
+		p = parent
+	}
+}
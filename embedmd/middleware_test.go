@@ -0,0 +1,267 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recorder is a Fetcher recording the paths it fetches.
+type recorder struct {
+	name  string
+	paths *[]string
+	next  Fetcher
+}
+
+func (r recorder) Fetch(dir, path string) ([]byte, error) {
+	*r.paths = append(*r.paths, r.name+":"+path)
+	return r.next.Fetch(dir, path)
+}
+
+func TestChainFetcher(t *testing.T) {
+	var paths []string
+	mw := func(name string) Middleware {
+		return func(next Fetcher) Fetcher { return recorder{name, &paths, next} }
+	}
+	f := ChainFetcher(fakeFileProvider{"code.go": []byte("code")}, mw("outer"), mw("inner"))
+	b, err := f.Fetch("", "code.go")
+	if err != nil || string(b) != "code" {
+		t.Fatalf("expected code; got %q, %v", b, err)
+	}
+	if got := strings.Join(paths, " "); got != "outer:code.go inner:code.go" {
+		t.Errorf("unexpected order of middleware: %s", got)
+	}
+}
+
+func TestCachingMiddleware(t *testing.T) {
+	calls := 0
+	f := ChainFetcher(FetcherFunc(func(dir, path string) ([]byte, error) {
+		calls++
+		if path == "missing.go" {
+			return nil, fmt.Errorf("file does not exist")
+		}
+		return []byte(dir + "/" + path), nil
+	}), CachingMiddleware())
+
+	for i := 0; i < 2; i++ {
+		if b, _ := f.Fetch("a", "code.go"); string(b) != "a/code.go" {
+			t.Errorf("expected a/code.go; got %s", b)
+		}
+		if b, _ := f.Fetch("b", "code.go"); string(b) != "b/code.go" {
+			t.Errorf("expected b/code.go; got %s", b)
+		}
+		f.Fetch("a", "https://example.com/x.go") //nolint:errcheck
+		f.Fetch("b", "https://example.com/x.go") //nolint:errcheck
+		if _, err := f.Fetch("a", "missing.go"); err == nil {
+			t.Errorf("expected an error for missing.go")
+		}
+	}
+	if calls != 4 {
+		t.Errorf("expected 4 calls to the wrapped fetcher; got %d", calls)
+	}
+}
+
+func TestFetchContext(t *testing.T) {
+	type key struct{}
+	var got []any
+	base := FetcherContextFunc(func(ctx context.Context, dir, path string) ([]byte, error) {
+		got = append(got, ctx.Value(key{}))
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return []byte(path), nil
+	})
+	var m FetchMetrics
+	f := ChainFetcher(base, LoggingMiddleware(func(string, ...interface{}) {}), CachingMiddleware(), MetricsMiddleware(&m), AllowMiddleware("example.com/**"))
+
+	ctx := context.WithValue(context.Background(), key{}, "traced")
+	if b, err := FetchContext(ctx, f, "", "code.go"); err != nil || string(b) != "code.go" {
+		t.Fatalf("expected code.go; got %q, %v", b, err)
+	}
+	if len(got) != 1 || got[0] != "traced" {
+		t.Errorf("expected the context to be passed through the middleware; got %v", got)
+	}
+
+	// Canceled fetches aren't cached, and unaware fetchers aren't called.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := FetchContext(canceled, f, "", "other.go"); err != context.Canceled {
+		t.Errorf("expected %v; got %v", context.Canceled, err)
+	}
+	if b, err := f.Fetch("", "other.go"); err != nil || string(b) != "other.go" {
+		t.Errorf("expected other.go fetched again; got %q, %v", b, err)
+	}
+	called := false
+	unaware := FetcherFunc(func(dir, path string) ([]byte, error) { called = true; return nil, nil })
+	if _, err := FetchContext(canceled, unaware, "", "code.go"); err != context.Canceled || called {
+		t.Errorf("expected %v without fetching; got %v, called: %v", context.Canceled, err, called)
+	}
+}
+
+func TestLoggingAndMetricsMiddleware(t *testing.T) {
+	var logs []string
+	var m FetchMetrics
+	f := ChainFetcher(fakeFileProvider{"code.go": []byte("code")},
+		LoggingMiddleware(func(format string, args ...interface{}) {
+			logs = append(logs, strings.SplitN(fmt.Sprintf(format, args...), " (", 2)[0])
+		}),
+		MetricsMiddleware(&m))
+
+	f.Fetch("", "code.go")    //nolint:errcheck
+	f.Fetch("", "missing.go") //nolint:errcheck
+
+	want := []string{"fetch code.go: 4 bytes", "fetch missing.go: file does not exist"}
+	if fmt.Sprint(logs) != fmt.Sprint(want) {
+		t.Errorf("expected logs %q; got %q", want, logs)
+	}
+	if total, failed := m.Fetches(); total != 2 || failed != 1 {
+		t.Errorf("expected 2 fetches with 1 failure; got %d and %d", total, failed)
+	}
+	if m.Bytes() != 4 {
+		t.Errorf("expected 4 bytes; got %d", m.Bytes())
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("X-Token"))
+	}))
+	defer ts.Close()
+
+	authorize := func(r *http.Request) error {
+		if r.URL.Path == "/fail" {
+			return fmt.Errorf("no token")
+		}
+		r.Header.Set("X-Token", "secret")
+		return nil
+	}
+	host := strings.TrimPrefix(ts.URL, "http://")
+	authorized := ChainFetcher(NewFetcher(nil), AuthMiddleware(host+"/private/**", ts.Client(), authorize))
+
+	tc := []struct {
+		path, out, err string
+	}{
+		{path: ts.URL + "/private/a.go", out: "secret"},
+		{path: ts.URL + "/public/a.go", out: ""},
+		{path: ts.URL + "/fail", out: ""},
+	}
+	for _, tt := range tc {
+		b, err := authorized.Fetch("", tt.path)
+		if !eqErr(t, tt.path, err, tt.err) {
+			continue
+		}
+		if string(b) != tt.out {
+			t.Errorf("case [%s] expected %q; got %q", tt.path, tt.out, b)
+		}
+	}
+
+	_, err := ChainFetcher(NewFetcher(nil), AuthMiddleware(host+"/**", ts.Client(), authorize)).Fetch("", ts.URL+"/fail")
+	eqErr(t, "failing authorization", err, "could not authorize request: no token")
+
+	// The options of the wrapped fetcher apply to the URLs matched.
+	limited := AuthMiddleware(host+"/**", ts.Client(), authorize, WithMaxSize(3))
+	_, err = ChainFetcher(NewFetcher(nil), limited).Fetch("", ts.URL+"/private/a.go")
+	if err == nil {
+		t.Errorf("expected the maximum size to apply to the authorized URLs")
+	}
+}
+
+func TestAllowMiddleware(t *testing.T) {
+	fetched := FetcherFunc(func(dir, path string) ([]byte, error) { return []byte(path), nil })
+	allowed := ChainFetcher(fetched, AllowMiddleware("raw.githubusercontent.com/org/**", "*.example.com/**"))
+
+	tc := []struct {
+		path, err string
+	}{
+		{path: "https://raw.githubusercontent.com/org/repo/main/a.go"},
+		{path: "https://docs.example.com/a.go"},
+		{path: "main.go"},
+		{path: "https://raw.githubusercontent.com/other/repo/main/a.go",
+			err: "https://raw.githubusercontent.com/other/repo/main/a.go is not in the allowed URLs"},
+		{path: "http://example.com/a.go", err: "http://example.com/a.go is not in the allowed URLs"},
+	}
+	for _, tt := range tc {
+		b, err := allowed.Fetch("", tt.path)
+		if eqErr(t, tt.path, err, tt.err) && string(b) != tt.path {
+			t.Errorf("case [%s] expected %q; got %q", tt.path, tt.path, b)
+		}
+	}
+}
+
+func TestRootMiddleware(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "docs", "link")); err != nil {
+		t.Skipf("could not create symbolic link: %v", err)
+	}
+	fetched := FetcherFunc(func(dir, path string) ([]byte, error) { return []byte(path), nil })
+	confined := ChainFetcher(fetched, RootMiddleware(root))
+	docs := filepath.Join(root, "docs")
+
+	tc := []struct {
+		path, err string
+	}{
+		{path: "main.go"},
+		{path: "../main.go"},
+		{path: "missing/dir/main.go"},
+		{path: "git://./main.go@v1"},
+		{path: "https://example.com/main.go"},
+		{path: "../../main.go", err: "../../main.go is outside of " + root},
+		{path: filepath.Join(outside, "main.go"), err: filepath.Join(outside, "main.go") + " is outside of " + root},
+		{path: "link/main.go", err: "link/main.go is outside of " + root},
+		{path: "git://../../main.go@v1", err: "git://../../main.go@v1 is outside of " + root},
+		{path: "../../src.zip!/main.go", err: "../../src.zip!/main.go is outside of " + root},
+	}
+	for _, tt := range tc {
+		b, err := confined.Fetch(docs, tt.path)
+		if eqErr(t, tt.path, err, tt.err) && string(b) != tt.path {
+			t.Errorf("case [%s] expected %q; got %q", tt.path, tt.path, b)
+		}
+	}
+}
+
+func ExampleChainFetcher() {
+	var metrics FetchMetrics
+	fetcher := ChainFetcher(FetcherFunc(func(dir, path string) ([]byte, error) {
+		return []byte("fmt.Println(\"hello\")\n"), nil
+	}), CachingMiddleware(), MetricsMiddleware(&metrics))
+
+	doc := "[embedmd]:# (hello.go)\n\n[embedmd]:# (hello.go)\n"
+	if err := Process(os.Stdout, strings.NewReader(doc), WithFetcher(fetcher)); err != nil {
+		fmt.Println(err)
+	}
+	total, _ := metrics.Fetches()
+	fmt.Println("fetches:", total)
+	// Output:
+	// [embedmd]:# (hello.go)
+	// ```go
+	// fmt.Println("hello")
+	// ```
+	//
+	// [embedmd]:# (hello.go)
+	// ```go
+	// fmt.Println("hello")
+	// ```
+	// fetches: 1
+}
@@ -0,0 +1,152 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// containerMarker matches the start of a list item or blockquote, after up
+// to three spaces of indentation.
+var containerMarker = regexp.MustCompile(`^ {0,3}(?:> ?|(?:[-*+]|\d{1,9}[.)]) {1,4})`)
+
+// containerPrefix returns the prefix of line made of list item or
+// blockquote markers, if any, and the prefix of the following lines in the
+// same containers. A line can start several containers, as in "> - item".
+func containerPrefix(line string) (prefix, cont string) {
+	rest := line
+	for {
+		m := containerMarker.FindString(rest)
+		if m == "" {
+			break
+		}
+		if strings.HasSuffix(strings.TrimRight(m, " "), ">") {
+			cont += m
+		} else {
+			// The content of list items is aligned after the marker.
+			cont += strings.Repeat(" ", len(m))
+		}
+		rest = rest[len(m):]
+	}
+	if indented, ok := trimIndent(rest); ok {
+		cont += rest[:len(rest)-len(indented)]
+		rest = indented
+	}
+	return line[:len(line)-len(rest)], cont
+}
+
+// nestedCommand returns the prefixes of a command nested in list items or
+// blockquotes, as containerPrefix does, or ok false if the line isn't such
+// a command.
+func nestedCommand(s textScanner, line string) (prefix, cont string, ok bool) {
+	prefix, cont = containerPrefix(line)
+	if prefix == "" || !isCommand(s, line[len(prefix):]) {
+		return "", "", false
+	}
+	return prefix, cont, true
+}
+
+// nestedFence returns the fence opening a code block nested in list items or
+// blockquotes at line, and the prefix of its lines, or "" if there is none.
+func nestedFence(line string) (fence, cont string) {
+	prefix, cont := containerPrefix(line)
+	if prefix == "" {
+		return "", ""
+	}
+	return openingFence(line[len(prefix):]), cont
+}
+
+// nestedScanner scans the lines of a container starting with prefix,
+// without it. It stops at the first line outside of the container, which is
+// left as the current line of the underlying scanner.
+type nestedScanner struct {
+	textScanner
+	prefix string
+	line   string
+	// left is set when the current line is outside the container.
+	left bool
+}
+
+func (n *nestedScanner) Text() string { return n.line }
+
+func (n *nestedScanner) Scan() bool {
+	if n.left || !n.textScanner.Scan() {
+		return false
+	}
+	line := n.textScanner.Text()
+	switch {
+	case strings.HasPrefix(line, n.prefix):
+		n.line = line[len(n.prefix):]
+	case line == strings.TrimRight(n.prefix, " "):
+		// Blank lines in the container, such as ">" in blockquotes.
+		n.line = ""
+	default:
+		n.left = true
+		return false
+	}
+	return true
+}
+
+// prefixWriter writes lines to w starting with prefix, or with first for
+// the first one. Blank lines are written without trailing spaces.
+type prefixWriter struct {
+	w             io.Writer
+	first, prefix string
+	midLine       bool
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	for n := 0; n < len(b); {
+		if !p.midLine {
+			lead := p.prefix
+			if p.first != "" {
+				lead, p.first = p.first, ""
+			}
+			if b[n] == '\n' {
+				lead = strings.TrimRight(lead, " ")
+			}
+			if _, err := io.WriteString(p.w, lead); err != nil {
+				return n, err
+			}
+		}
+		end := bytes.IndexByte(b[n:], '\n') + 1
+		if end == 0 {
+			end = len(b) - n
+		}
+		if _, err := p.w.Write(b[n : n+end]); err != nil {
+			return n, err
+		}
+		p.midLine = b[n+end-1] != '\n'
+		n += end
+	}
+	return len(b), nil
+}
+
+// parsingNestedCmd parses the command in the current line, nested in a list
+// item or blockquote, writing the block generated with the prefix of the
+// container so it stays in it.
+func parsingNestedCmd(prefix, cont string) state {
+	return func(out io.Writer, s textScanner, run commandRunner) (state, error) {
+		ns := &nestedScanner{textScanner: s, prefix: cont, line: s.Text()[len(prefix):]}
+		next, err := parsingCmd(&prefixWriter{w: out, first: prefix, prefix: cont}, ns, run)
+		if err != nil || !ns.left {
+			return next, err
+		}
+		// The current line is the first one after the container.
+		return parsingLine, nil
+	}
+}
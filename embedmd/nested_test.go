@@ -0,0 +1,70 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNestedCommands(t *testing.T) {
+	files := map[string][]byte{"code.go": []byte(content), "blank.go": []byte("a := 1\n\nb := 2\n")}
+	tc := []struct {
+		name, in, out string
+	}{
+		{
+			name: "in list item",
+			in:   "- Run it:\n  [embedmd]:# (code.go /func main/ $)\n- Done.\n",
+			out:  "- Run it:\n  [embedmd]:# (code.go /func main/ $)\n  ```go\n  func main() {\n          fmt.Println(\"hello, test\")\n  }\n  ```\n- Done.\n",
+		},
+		{
+			name: "after list marker",
+			in:   "1. [embedmd]:# (code.go /func main/ $)\n   ```go\n   old\n   ```\n2. Done.\n",
+			out:  "1. [embedmd]:# (code.go /func main/ $)\n   ```go\n   func main() {\n           fmt.Println(\"hello, test\")\n   }\n   ```\n2. Done.\n",
+		},
+		{
+			name: "in blockquote",
+			in:   "> [embedmd]:# (blank.go)\n> ```go\n> old\n> ```\n>\n> Text\n",
+			out:  "> [embedmd]:# (blank.go)\n> ```go\n> a := 1\n>\n> b := 2\n> ```\n>\n> Text\n",
+		},
+		{
+			name: "in list item in blockquote",
+			in:   "> - [embedmd]:# (blank.go)\n\nText\n",
+			out:  "> - [embedmd]:# (blank.go)\n>   ```go\n>   a := 1\n>\n>   b := 2\n>   ```\n\nText\n",
+		},
+		{
+			name: "stacked",
+			in:   "- [embedmd]:# (blank.go)\n  [embedmd]:# (blank.go /b/)\n",
+			out:  "- [embedmd]:# (blank.go)\n  [embedmd]:# (blank.go /b/)\n  ```go\n  a := 1\n\n  b := 2\n  b\n  ```\n",
+		},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}))
+		if err != nil {
+			t.Errorf("case [%s]: %v", tt.name, err)
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+			continue
+		}
+		// The nested blocks are replaced by the next runs.
+		out.Reset()
+		if err := Process(&out, strings.NewReader(tt.out), WithFetcher(mixedContentProvider{files: files})); err != nil || out.String() != tt.out {
+			t.Errorf("case [%s]: processing the output again gave\n%q, %v", tt.name, out.String(), err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// Network configures how the HTTP client returned by its Client method
+// connects to servers. The zero value connects as http.DefaultClient does.
+type Network struct {
+	// IPVersion restricts connections to IPv4 if 4 or IPv6 if 6.
+	IPVersion int
+	// UnixSocket, if set, is the path of the unix socket every request is
+	// sent to, whatever the host of its URL, e.g. to reach a local daemon.
+	UnixSocket string
+	// Proxy, if set, is the URL of the proxy requests go through, as
+	// http://, https://, or socks5://[user:password@]host:port, instead of
+	// the one set in the environment.
+	Proxy string
+	// CAFile, if set, is a PEM bundle of the certificate authorities
+	// trusted in addition to those of the system, e.g. an internal CA.
+	CAFile string
+	// CertFile and KeyFile, if set, are the PEM files of the client
+	// certificate and key presented to the servers asking for one.
+	CertFile, KeyFile string
+	// InsecureSkipVerify accepts any server certificate. It should only be
+	// used for testing.
+	InsecureSkipVerify bool
+	// HTTPSOnly refuses the requests to http:// URLs, including redirects.
+	HTTPSOnly bool
+	// PublicOnly refuses to connect to loopback, private, link-local, and
+	// other non-public addresses, checked once the host is resolved. It
+	// can't be used with a unix socket or a proxy.
+	PublicOnly bool
+}
+
+// Client returns an HTTP client connecting as configured, or nil for the
+// zero value so the default client is used.
+func (n Network) Client() (*http.Client, error) {
+	if n == (Network{}) {
+		return nil, nil
+	}
+	network := "tcp"
+	switch n.IPVersion {
+	case 0:
+	case 4, 6:
+		network = fmt.Sprintf("tcp%d", n.IPVersion)
+	default:
+		return nil, fmt.Errorf("bad IP version %d, must be 4 or 6", n.IPVersion)
+	}
+	if n.UnixSocket != "" && (n.IPVersion != 0 || n.Proxy != "") {
+		return nil, fmt.Errorf("a unix socket can't be used with an IP version or a proxy")
+	}
+	if n.PublicOnly && (n.UnixSocket != "" || n.Proxy != "") {
+		return nil, fmt.Errorf("public addresses can't be enforced through a unix socket or a proxy")
+	}
+
+	var err error
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if n.PublicOnly {
+		d.Control = func(_, addr string, _ syscall.RawConn) error {
+			host, _, _ := net.SplitHostPort(addr)
+			if ip := net.ParseIP(host); ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+				return fmt.Errorf("%s is not a public address", host)
+			}
+			return nil
+		}
+	}
+	t.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		if n.UnixSocket != "" {
+			return d.DialContext(ctx, "unix", n.UnixSocket)
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+	if n.UnixSocket != "" {
+		t.Proxy = nil
+	}
+	if n.Proxy != "" {
+		u, err := url.Parse(n.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("bad proxy URL: %v", err)
+		}
+		if !slices.Contains([]string{"http", "https", "socks5", "socks5h"}, u.Scheme) || u.Host == "" {
+			return nil, fmt.Errorf("bad proxy URL %q, must be http://, https://, or socks5://host:port", n.Proxy)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	if n.CAFile != "" || n.CertFile != "" || n.KeyFile != "" || n.InsecureSkipVerify {
+		if t.TLSClientConfig, err = n.tlsConfig(); err != nil {
+			return nil, err
+		}
+	}
+	if n.HTTPSOnly {
+		return &http.Client{Transport: httpsOnly{t}}, nil
+	}
+	return &http.Client{Transport: t}, nil
+}
+
+// tlsConfig returns the TLS configuration of the certificates of the network.
+func (n Network) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: n.InsecureSkipVerify}
+	if n.CAFile != "" {
+		b, err := os.ReadFile(n.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA bundle: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no PEM certificate found in %s", n.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if (n.CertFile == "") != (n.KeyFile == "") {
+		return nil, fmt.Errorf("a client certificate needs both a certificate and a key file")
+	}
+	if n.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(n.CertFile, n.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// httpsOnly is a transport refusing the requests that aren't sent over
+// HTTPS.
+type httpsOnly struct{ next http.RoundTripper }
+
+func (t httpsOnly) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("%s is not an https:// URL", req.URL.Redacted())
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,163 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// processNotebook writes the notebook b with the code cell following each
+// markdown cell holding a command set to the content it embeds, inserting
+// the cell if there's none. The outputs of the code cells changed are
+// cleared. The notebook is written as it was read if no cell changed, and
+// laid out as Jupyter does otherwise.
+func processNotebook(out io.Writer, b []byte, opts []Option) error {
+	var nb map[string]json.RawMessage
+	if err := json.Unmarshal(b, &nb); err != nil {
+		return fmt.Errorf("could not parse notebook: %v", err)
+	}
+	var cells []map[string]json.RawMessage
+	if err := json.Unmarshal(nb["cells"], &cells); err != nil {
+		return fmt.Errorf("could not parse the cells of the notebook: %v", err)
+	}
+
+	changed := false
+	for i := 0; i < len(cells); i++ {
+		if cellString(cells[i], "cell_type") != "markdown" {
+			continue
+		}
+		content, ok, err := cellContent(cells[i], i+1, opts)
+		if err != nil {
+			return fmt.Errorf("cell %d: %w", i+1, err)
+		}
+		if !ok {
+			continue
+		}
+		code := codeCell(content)
+		switch {
+		case i+1 < len(cells) && cellString(cells[i+1], "cell_type") == "code":
+			if strings.TrimSuffix(cellSource(cells[i+1]), "\n") == cellSource(code) {
+				continue
+			}
+			// The metadata of the cell is kept.
+			for _, k := range []string{"source", "outputs", "execution_count"} {
+				cells[i+1][k] = code[k]
+			}
+		default:
+			if id := cellString(cells[i], "id"); id != "" {
+				code["id"] = rawJSON(id[:min(len(id), 56)] + "-embedmd")
+			}
+			cells = slices.Insert(cells, i+1, code)
+		}
+		changed = true
+	}
+	if !changed {
+		_, err := out.Write(b)
+		return err
+	}
+
+	nb["cells"] = rawJSON(cells)
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", " ")
+	return enc.Encode(nb)
+}
+
+// cellContent returns the content embedded by the command of the markdown
+// cell n, or false if it has none.
+func cellContent(cell map[string]json.RawMessage, n int, opts []Option) ([]byte, bool, error) {
+	e, b, err := newEmbedder(strings.NewReader(cellSource(cell)), append(slices.Clip(opts), WithFormat(MarkdownFormat)))
+	if err != nil {
+		return nil, false, err
+	}
+	if warn := e.warnings; warn != nil {
+		e.warnings = func(line int, msg string) { warn(line, fmt.Sprintf("cell %d: %s", n, msg)) }
+	}
+	e.warnTypos(b)
+	var cmds []*command
+	err = e.parse(io.Discard, bytes.NewReader(b), func(_ io.Writer, cmd *command) error {
+		if !e.skipped[cmd.line] && !frozen(cmd) && !e.deselected(cmd) && e.onPlatform(cmd) {
+			cmds = append(cmds, cmd)
+		}
+		return nil
+	})
+	switch {
+	case err != nil || len(cmds) == 0:
+		return nil, false, err
+	case len(cmds) > 1:
+		return nil, false, &lineError{cmds[1].line, errors.New("a markdown cell of a notebook can only hold one command, embedding its code in the next cell")}
+	}
+
+	cmd := cmds[0]
+	content, err := e.content(cmd)
+	switch {
+	case errors.Is(err, SkipEmbed):
+		return nil, false, nil
+	case err != nil:
+		if content, err = e.placeholder(err); err != nil {
+			return nil, false, &lineError{cmd.line, err}
+		}
+	default:
+		content = e.normalize(cmd, content)
+	}
+	return content, true, nil
+}
+
+// codeCell returns a code cell, without outputs, with the given content.
+func codeCell(content []byte) map[string]json.RawMessage {
+	lines := []string{}
+	if s := strings.TrimSuffix(string(content), "\n"); s != "" {
+		lines = strings.SplitAfter(s, "\n")
+	}
+	return map[string]json.RawMessage{
+		"cell_type":       rawJSON("code"),
+		"execution_count": rawJSON(nil),
+		"metadata":        rawJSON(map[string]any{}),
+		"outputs":         rawJSON([]any{}),
+		"source":          rawJSON(lines),
+	}
+}
+
+// cellSource returns the source of a cell, written either as a string or
+// as a list of lines.
+func cellSource(cell map[string]json.RawMessage) string {
+	var lines []string
+	if json.Unmarshal(cell["source"], &lines) == nil {
+		return strings.Join(lines, "")
+	}
+	return cellString(cell, "source")
+}
+
+// cellString returns the string value of the key of a cell, or "".
+func cellString(cell map[string]json.RawMessage, key string) string {
+	var s string
+	json.Unmarshal(cell[key], &s) //nolint:errcheck
+	return s
+}
+
+// rawJSON returns v in JSON, without escaping HTML characters as Jupyter
+// doesn't.
+func rawJSON(v any) json.RawMessage {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v) //nolint:errcheck
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
@@ -0,0 +1,137 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNotebook(t *testing.T) {
+	files := map[string][]byte{
+		"code.go":  []byte("package main\n\nfunc main() {\n\tprintln(\"<hi>\")\n}\n"),
+		"other.py": []byte("print(1)\n"),
+	}
+	const generated = ` {
+   "cell_type": "code",
+   "execution_count": null,
+   "metadata": {},
+   "outputs": [],
+   "source": [
+    "func main() {\n",
+    "\tprintln(\"<hi>\")\n",
+    "}"
+   ]
+  }`
+	tc := []struct {
+		name, in, out, err string
+	}{
+		{name: "code cell inserted",
+			in: `{"cells": [{"cell_type": "markdown", "metadata": {}, "source": ["# Title\n", "[embedmd]:# (code.go /func main/ $)"]}], "metadata": {}, "nbformat": 4, "nbformat_minor": 4}`,
+			out: `{
+ "cells": [
+  {
+   "cell_type": "markdown",
+   "metadata": {},
+   "source": [
+    "# Title\n",
+    "[embedmd]:# (code.go /func main/ $)"
+   ]
+  },
+ ` + generated + `
+ ],
+ "metadata": {},
+ "nbformat": 4,
+ "nbformat_minor": 4
+}
+`},
+		{name: "code cell refreshed",
+			in: `{"cells": [{"cell_type": "markdown", "id": "intro", "metadata": {}, "source": "[embedmd]:# (other.py)"}, ` +
+				`{"cell_type": "code", "execution_count": 3, "id": "run", "metadata": {"tags": ["keep"]}, "outputs": [{"output_type": "stream", "name": "stdout", "text": ["0\n"]}], "source": ["print(0)"]}], "metadata": {}, "nbformat": 4, "nbformat_minor": 5}`,
+			out: `{
+ "cells": [
+  {
+   "cell_type": "markdown",
+   "id": "intro",
+   "metadata": {},
+   "source": "[embedmd]:# (other.py)"
+  },
+  {
+   "cell_type": "code",
+   "execution_count": null,
+   "id": "run",
+   "metadata": {
+    "tags": [
+     "keep"
+    ]
+   },
+   "outputs": [],
+   "source": [
+    "print(1)"
+   ]
+  }
+ ],
+ "metadata": {},
+ "nbformat": 4,
+ "nbformat_minor": 5
+}
+`},
+		{name: "inserted with an id",
+			in:  `{"cells": [{"cell_type": "markdown", "id": "intro", "metadata": {}, "source": "[embedmd]:# (other.py)"}, {"cell_type": "markdown", "id": "end", "metadata": {}, "source": "The end"}], "nbformat": 4, "nbformat_minor": 5}`,
+			out: "{\n \"cells\": [\n  {\n   \"cell_type\": \"markdown\",\n   \"id\": \"intro\",\n   \"metadata\": {},\n   \"source\": \"[embedmd]:# (other.py)\"\n  },\n  {\n   \"cell_type\": \"code\",\n   \"execution_count\": null,\n   \"id\": \"intro-embedmd\",\n   \"metadata\": {},\n   \"outputs\": [],\n   \"source\": [\n    \"print(1)\"\n   ]\n  },\n  {\n   \"cell_type\": \"markdown\",\n   \"id\": \"end\",\n   \"metadata\": {},\n   \"source\": \"The end\"\n  }\n ],\n \"nbformat\": 4,\n \"nbformat_minor\": 5\n}\n"},
+		{name: "up to date, kept as written",
+			in:  `{"cells": [{"cell_type": "markdown", "source": ["[embedmd]:# (other.py)"]}, {"cell_type": "code", "outputs": [1], "source": "print(1)\n"}]}`,
+			out: `{"cells": [{"cell_type": "markdown", "source": ["[embedmd]:# (other.py)"]}, {"cell_type": "code", "outputs": [1], "source": "print(1)\n"}]}`},
+		{name: "commands in code cells ignored",
+			in:  `{"cells": [{"cell_type": "code", "source": "# [embedmd]:# (other.py)"}]}`,
+			out: `{"cells": [{"cell_type": "code", "source": "# [embedmd]:# (other.py)"}]}`},
+		{name: "two commands in a cell",
+			in:  `{"cells": [{"cell_type": "markdown", "source": "[embedmd]:# (other.py)\n\n[embedmd]:# (code.go)"}]}`,
+			err: "cell 1: 3: a markdown cell of a notebook can only hold one command, embedding its code in the next cell"},
+		{name: "missing source",
+			in:  `{"cells": [{"cell_type": "markdown", "source": "Text\n[embedmd]:# (missing.go)"}]}`,
+			err: "cell 1: 2: could not read missing.go: file does not exist"},
+		{name: "not a notebook",
+			in:  "# Title\n",
+			err: "could not parse notebook: invalid character '#' looking for beginning of value"},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}), WithFormat(NotebookFormat))
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if got := out.String(); got != tt.out {
+				t.Errorf("expected output\n%s\ngot\n%s", tt.out, got)
+			}
+		})
+	}
+}
+
+func TestNotebookWarnings(t *testing.T) {
+	var warnings []string
+	in := `{"cells": [{"cell_type": "markdown", "source": "[embedmd]: # (code.go)"}]}`
+	err := Process(new(bytes.Buffer), strings.NewReader(in), WithFormat(NotebookFormat), WithWarnings(func(line int, msg string) {
+		warnings = append(warnings, fmt.Sprintf("%d: %s", line, msg))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0], "1: cell 1: ") {
+		t.Errorf("expected a warning for the typo in cell 1; got %q", warnings)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+// parseOmissions parses the value of the omit attribute, one or more
+// regular expressions between slashes matching the lines left out.
+func parseOmissions(val string) ([]*regexp.Regexp, error) {
+	args, err := fields(val)
+	if err != nil || len(args) == 0 || slices.ContainsFunc(args, func(a string) bool { return a[0] != '/' }) {
+		return nil, fmt.Errorf("omit should be regular expressions, as in \"/nolint/ /go:build/\", got %q", val)
+	}
+	res := make([]*regexp.Regexp, len(args))
+	for i, arg := range args {
+		if res[i], err = compileSlashed(arg); err != nil {
+			return nil, fmt.Errorf("bad regular expression %s of omit: %v", arg, err)
+		}
+	}
+	return res, nil
+}
+
+// omit removes the lines of b matching any of the omissions of cmd. It runs
+// on the content extracted, so the regular expressions selecting it match
+// the lines omitted too.
+func omit(cmd *command, b []byte) []byte {
+	if len(cmd.omissions) == 0 {
+		return b
+	}
+	var kept []byte
+	for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+		if !slices.ContainsFunc(cmd.omissions, func(re *regexp.Regexp) bool {
+			return re.Match(bytes.TrimSuffix(line, []byte("\n")))
+		}) {
+			kept = append(kept, line...)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,51 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestOmit(t *testing.T) {
+	files := map[string][]byte{
+		"code.go": []byte("//go:build linux\n\n// Copyright 2024 Example Authors. Licensed under the Apache License.\n\npackage main\n\nfunc main() {\n\tx := run() //nolint:errcheck\n\tprintln(x)\n}\n"),
+	}
+	tc := []struct {
+		name, in, out string
+		err           string
+	}{
+		{name: "with regexps", in: "[embedmd]:# (code.go /func main/ /^}/ omit=/nolint/)\n",
+			out: "[embedmd]:# (code.go /func main/ /^}/ omit=/nolint/)\n```go\nfunc main() {\n\tprintln(x)\n}\n```\n"},
+		{name: "build tag and license", in: "[embedmd]:# (code.go omit=\"/go:build/ /nolint/\" license=strip)\n",
+			out: "[embedmd]:# (code.go omit=\"/go:build/ /nolint/\" license=strip)\n```go\npackage main\n\nfunc main() {\n\tprintln(x)\n}\n```\n"},
+		{name: "nothing matches", in: "[embedmd]:# (code.go /func main/ /^}/ omit=/nowhere/)\n",
+			out: "[embedmd]:# (code.go /func main/ /^}/ omit=/nowhere/)\n```go\nfunc main() {\n\tx := run() //nolint:errcheck\n\tprintln(x)\n}\n```\n"},
+		{name: "not a regexp", in: "[embedmd]:# (code.go omit=nolint)\n",
+			err: "1: omit should be regular expressions, as in \"/nolint/ /go:build/\", got \"nolint\""},
+		{name: "bad regexp", in: "[embedmd]:# (code.go omit=\"/a/ /(/\")\n",
+			err: "1: bad regular expression /(/ of omit: error parsing regexp: missing closing ): `(`"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
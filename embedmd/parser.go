@@ -15,6 +15,7 @@ package embedmd
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"strings"
@@ -22,27 +23,96 @@ import (
 
 type commandRunner func(io.Writer, *command) error
 
-func process(out io.Writer, in io.Reader, run commandRunner) error {
-	s := &countingScanner{bufio.NewScanner(in), 0}
+// process writes the markdown read from in to out, running its commands with
+// run. The commands that can't be parsed are passed to skip, if set, and the
+// document is read on after them when it returns true.
+func process(out io.Writer, in io.Reader, run commandRunner, skip func(line int, err error) bool, env map[string]string, syntaxes ...Syntax) error {
+	if len(syntaxes) == 0 {
+		syntaxes = []Syntax{LinkSyntax}
+	}
+	b, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	s := &countingScanner{Scanner: bufio.NewScanner(bytes.NewReader(b)), syntaxes: syntaxes, env: env}
+	run = withDirectives(run)
 
 	state := parsingText
-	var err error
+	// Front matter is printed as is, whatever its content looks like.
+	if end := frontMatterEnd(markdownLines(b)); end > 0 {
+		state = passingLines(end + 1)
+	}
 	for state != nil {
 		state, err = state(out, s, run)
+		if _, ok := err.(*parseError); ok && skip != nil && skip(s.line, err) {
+			continue
+		}
+		if _, ok := err.(*lineError); ok {
+			return err
+		}
 		if err != nil {
-			return fmt.Errorf("%d: %v", s.line, err)
+			return &lineError{s.line, err}
 		}
 	}
 
 	if err := s.Err(); err != nil {
-		return fmt.Errorf("%d: %v", s.line, err)
+		return &lineError{s.line, err}
 	}
 	return nil
 }
 
+// withDirectives wraps run to apply the basedir directives to the commands
+// following them, which are the only ones run sees.
+func withDirectives(run commandRunner) commandRunner {
+	dir := ""
+	return func(out io.Writer, cmd *command) error {
+		if cmd.dirDirective {
+			dir = cmd.dir
+			return nil
+		}
+		for _, c := range append([]*command{cmd}, cmd.stacked...) {
+			if c.dir == "" {
+				c.dir = dir
+			}
+		}
+		return run(out, cmd)
+	}
+}
+
+// lineError is an error found at a line, other than the current one when
+// returned by states.
+type lineError struct {
+	line int
+	err  error
+}
+
+func (e *lineError) Error() string { return fmt.Sprintf("%d: %v", e.line, e.err) }
+func (e *lineError) Unwrap() error { return e.err }
+
 type countingScanner struct {
 	*bufio.Scanner
-	line int
+	line     int
+	syntaxes []Syntax
+	env      map[string]string
+	// vars holds the variables defined by the directives read so far.
+	vars map[string]string
+}
+
+func (c *countingScanner) Line() int { return c.line }
+
+func (c *countingScanner) commandArgs(line string) (string, bool) {
+	return commandArgs(line, c.syntaxes)
+}
+
+func (c *countingScanner) expandArgs(args string) (string, error) {
+	return expandEnv(args, c.env, c.vars)
+}
+
+func (c *countingScanner) define(name, value string) {
+	if c.vars == nil {
+		c.vars = map[string]string{}
+	}
+	c.vars[name] = value
 }
 
 func (c *countingScanner) Scan() bool {
@@ -56,51 +126,293 @@ func (c *countingScanner) Scan() bool {
 type textScanner interface {
 	Text() string
 	Scan() bool
+	Line() int
+	// commandArgs returns the parenthesized arguments of the command in
+	// line, in one of the syntaxes recognized, if it's one.
+	commandArgs(line string) (args string, ok bool)
+	// expandArgs replaces the references to variables in the arguments of a
+	// command.
+	expandArgs(args string) (string, error)
+	// define sets a variable referenced by the commands that follow.
+	define(name, value string)
+}
+
+// isCommand reports whether the line is a command recognized by s.
+func isCommand(s textScanner, line string) bool {
+	_, ok := s.commandArgs(line)
+	return ok
 }
 
 type state func(io.Writer, textScanner, commandRunner) (state, error)
 
+// checksumPrefix starts the comment recording the checksum of a generated
+// block, right after it.
+const checksumPrefix = "<!-- embedmd checksum "
+
 func parsingText(out io.Writer, s textScanner, run commandRunner) (state, error) {
 	if !s.Scan() {
 		return nil, nil // end of file, which is fine.
 	}
+	return parsingLine(out, s, run)
+}
+
+// parsingLine handles the line that was just scanned.
+func parsingLine(out io.Writer, s textScanner, run commandRunner) (state, error) {
+	if prefix, cont, ok := nestedCommand(s, s.Text()); ok {
+		return parsingNestedCmd(prefix, cont), nil
+	}
 	switch line := s.Text(); {
-	case strings.HasPrefix(line, "[embedmd]:#"):
+	case isCommand(s, line):
 		return parsingCmd, nil
-	case strings.HasPrefix(line, "```"):
+	case isTrailer(line), suppressionComment.MatchString(line):
+		fmt.Fprintln(out, line)
+		return parsingText, nil
+	case strings.HasPrefix(line, "```") && openingFence(line) != "":
 		return codeParser{print: true, delimiter: "```"}.parse, nil
 	case strings.HasPrefix(line, "<!-- embedmd"):
 		return codeParser{print: true, delimiter: "<!-- embedmd"}.parse, nil
 	default:
 		fmt.Fprintln(out, s.Text())
+		// Commands in other code blocks and in raw HTML blocks are ignored.
+		if fence := openingFence(line); fence != "" {
+			return passingBlock(func(l string) bool { return closesFence(l, fence) }), nil
+		}
+		if fence, cont := nestedFence(line); fence != "" {
+			return passingBlock(func(l string) bool { return closesFence(strings.TrimPrefix(l, cont), fence) }), nil
+		}
+		if closes := htmlBlockEnd(line); closes != nil && !closes(line) {
+			return passingBlock(closes), nil
+		}
+		return parsingText, nil
+	}
+}
+
+// passingBlock returns a state printing the lines up to the one closing the
+// block, or the end of the file, as CommonMark closes unbalanced blocks
+// there.
+func passingBlock(closes func(string) bool) state {
+	return func(out io.Writer, s textScanner, run commandRunner) (state, error) {
+		for s.Scan() {
+			fmt.Fprintln(out, s.Text())
+			if closes(s.Text()) {
+				return parsingText, nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+// passingLines returns a state printing the next n lines.
+func passingLines(n int) state {
+	return func(out io.Writer, s textScanner, run commandRunner) (state, error) {
+		for i := 0; i < n && s.Scan(); i++ {
+			fmt.Fprintln(out, s.Text())
+		}
 		return parsingText, nil
 	}
 }
 
+// htmlBlockEnd returns a function reporting whether a line closes the raw
+// HTML block starting at the given line, or nil if it doesn't start one.
+// Only comments and the blocks whose content is kept verbatim, such as pre
+// or script, are recognized.
+func htmlBlockEnd(line string) func(string) bool {
+	rest, ok := trimIndent(line)
+	if !ok {
+		return nil
+	}
+	if strings.HasPrefix(rest, "<!--") {
+		return func(l string) bool { return strings.Contains(l, "-->") }
+	}
+	rest = strings.ToLower(rest)
+	for _, tag := range []string{"pre", "script", "style", "textarea"} {
+		after, ok := strings.CutPrefix(rest, "<"+tag)
+		if ok && (after == "" || after[0] == ' ' || after[0] == '\t' || after[0] == '>') {
+			return func(l string) bool { return strings.Contains(strings.ToLower(l), "</"+tag+">") }
+		}
+	}
+	return nil
+}
+
 func parsingCmd(out io.Writer, s textScanner, run commandRunner) (state, error) {
+	cmd, err := scanCommand(out, s)
+	if err != nil {
+		return parsingText, &parseError{err}
+	}
+	// Directives have no block, nor commands stacked on them.
+	if cmd.varDirective {
+		return parsingText, nil
+	}
+	if cmd.dirDirective {
+		return parsingText, run(out, cmd)
+	}
+
+	// Commands on the following lines are stacked on this one, sharing its
+	// block, as do the regions stitched to them.
+	cmd.stacked = cmd.parts
+	more := s.Scan()
+	for more && isCommand(s, s.Text()) {
+		c, err := scanCommand(out, s)
+		if err != nil {
+			return parsingText, &parseError{err}
+		}
+		cmd.stacked = append(append(cmd.stacked, c), c.parts...)
+		more = s.Scan()
+	}
+
+	// The block generated by a previous run, if any, is read before running
+	// the command so they can be compared.
+	var closes func(string) bool
+	var blanks []string
+	cmd.blockLine = s.Line()
+	if !more {
+		cmd.blockLine++
+	}
+	if more {
+		closes, cmd.looseFence = blockEnd(s.Text())
+	}
+	switch {
+	case closes != nil:
+		if cmd.block, err = readBlock(s, closes); err != nil {
+			return nil, err
+		}
+		more = s.Scan()
+	case more && isIndented(s.Text()):
+		cmd.indented = true
+		cmd.block, blanks, more = readIndentedBlock(s)
+	}
+	// Trailers right after commands are those of stripped blocks.
+	replaced := closes != nil || cmd.indented
+	for more && len(blanks) == 0 && isTrailer(s.Text()) {
+		cmd.trailers = append(cmd.trailers, s.Text())
+		more = s.Scan()
+	}
+	if !cmd.looseFence {
+		cmd.readTrailers()
+	}
+
+	if err := run(out, cmd); err != nil {
+		if _, ok := err.(*lineError); ok {
+			return nil, err
+		}
+		return nil, &lineError{cmd.line, err}
+	}
+	for _, l := range blanks {
+		fmt.Fprintln(out, l)
+	}
+
+	switch {
+	case !more:
+		return nil, nil // end of file, which is fine.
+	case replaced || len(cmd.trailers) > 0:
+		return parsingLine, nil
+	}
+	fmt.Fprintln(out, s.Text())
+	return parsingText, nil
+}
+
+// scanCommand prints and parses the command in the current line.
+func scanCommand(out io.Writer, s textScanner) (*command, error) {
 	line := s.Text()
 	fmt.Fprintln(out, line)
-	args := line[strings.Index(line, "#")+1:]
-	cmd, err := parseCommand(args)
+	args, _ := s.commandArgs(line)
+	expanded, err := s.expandArgs(args)
 	if err != nil {
 		return nil, err
 	}
-	if err := run(out, cmd); err != nil {
+	// The arguments are kept as written, to be rewritten.
+	cmd, err := parseCommand(expanded)
+	if err != nil {
 		return nil, err
 	}
+	cmd.line, cmd.args = s.Line(), strings.TrimSpace(args)
+	if cmd.varDirective {
+		s.define(cmd.varName, cmd.varValue)
+	}
+	for _, c := range cmd.parts {
+		c.line, c.args = cmd.line, cmd.args
+	}
+	return cmd, nil
+}
 
-	if !s.Scan() {
-		return nil, nil // end of file, which is fine.
+// blockEnd returns a function reporting whether a line closes the block
+// starting at the given line, or nil if it doesn't start one. Fences not in
+// the form embedmd generates are reported as loose.
+func blockEnd(line string) (closes func(string) bool, loose bool) {
+	switch {
+	case strings.HasPrefix(line, "```"):
+		// Longer fences, as set with WithFence, are only closed by fences
+		// at least as long.
+		if fence := openingFence(line); len(fence) > 3 {
+			return func(l string) bool { return closesFence(l, fence) }, false
+		}
+		return hasPrefix("```"), false
+	case strings.HasPrefix(line, "<CodeBlock"):
+		return hasPrefix("</CodeBlock>"), false
+	case strings.HasPrefix(line, "<!-- embedmd") && !isTrailer(line) && !suppressionComment.MatchString(line):
+		return hasPrefix("<!-- embedmd"), false
 	}
-	if strings.HasPrefix(s.Text(), "```") {
-		return codeParser{print: false, delimiter: "```"}.parse, nil
+	if fence := openingFence(line); fence != "" {
+		return func(l string) bool { return closesFence(l, fence) }, true
 	}
-	if strings.HasPrefix(s.Text(), "<!-- embedmd") {
-		return codeParser{print: false, delimiter: "<!-- embedmd"}.parse, nil
+	return nil, false
+}
+
+// isTrailer reports whether the line is one of the comments embedmd adds
+// after a generated block: its checksum, the date it was refreshed, or the
+// stale marker.
+func isTrailer(line string) bool {
+	return strings.HasPrefix(line, checksumPrefix) || strings.HasPrefix(line, refreshedPrefix) || line == staleMarker ||
+		strings.HasPrefix(line, reviewPrefix)
+}
+
+func hasPrefix(prefix string) func(string) bool {
+	return func(s string) bool { return strings.HasPrefix(s, prefix) }
+}
+
+// readBlock returns the lines of the block starting at the current line, up
+// to the one closing it.
+func readBlock(s textScanner, closes func(string) bool) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, s.Text())
+	for {
+		if !s.Scan() {
+			return nil, fmt.Errorf("unbalanced code section")
+		}
+		fmt.Fprintln(&b, s.Text())
+		if closes(s.Text()) {
+			return b.Bytes(), nil
+		}
 	}
+}
 
-	fmt.Fprintln(out, s.Text())
-	return parsingText, nil
+// isIndented reports whether the line belongs to an indented code block.
+func isIndented(line string) bool {
+	return (strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")) && strings.TrimSpace(line) != ""
+}
+
+// readIndentedBlock returns the lines of the indented code block starting at
+// the current line, the blank lines following it, and whether there is a
+// line after them, which is the current one.
+func readIndentedBlock(s textScanner) (block []byte, blanks []string, more bool) {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, s.Text())
+	for s.Scan() {
+		switch line := s.Text(); {
+		case strings.TrimSpace(line) == "":
+			blanks = append(blanks, line)
+		case isIndented(line):
+			// Blank lines between indented lines are part of the block.
+			for _, l := range blanks {
+				fmt.Fprintln(&b, l)
+			}
+			blanks = nil
+			fmt.Fprintln(&b, line)
+		default:
+			return b.Bytes(), blanks, true
+		}
+	}
+	return b.Bytes(), blanks, false
 }
 
 type codeParser struct {
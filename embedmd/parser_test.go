@@ -17,6 +17,8 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -93,6 +95,11 @@ func TestParser(t *testing.T) {
 			in:   "```go\nhello\n\n```go\nbye\n```\n```\n",
 			out:  "```go\nhello\n\n```go\nbye\n```\n```\n",
 		},
+		{
+			name: "a checksum comment not following a command",
+			in:   "one\n<!-- embedmd checksum 0123456789ab -->\ntwo\n",
+			out:  "one\n<!-- embedmd checksum 0123456789ab -->\ntwo\n",
+		},
 		{
 			name: "embedded code in none section",
 			in:   "<!-- embedmd block start -->\n```go\nhello\n<!-- embedmd block end -->\n",
@@ -103,7 +110,7 @@ func TestParser(t *testing.T) {
 	for _, tt := range tc {
 		t.Run(tt.name, func(t *testing.T) {
 			var out bytes.Buffer
-			err := process(&out, strings.NewReader(tt.in), tt.run)
+			err := process(&out, strings.NewReader(tt.in), tt.run, nil, nil)
 			if !eqErr(t, tt.name, err, tt.err) {
 				return
 			}
@@ -113,3 +120,31 @@ func TestParser(t *testing.T) {
 		})
 	}
 }
+
+// TestCommonMark checks that the constructs in testdata/commonmark, derived
+// from the CommonMark spec examples, hide the commands in them: processing
+// the files must not run any command nor change them.
+func TestCommonMark(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "commonmark", "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			in, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			err = process(&out, bytes.NewReader(in), func(w io.Writer, cmd *command) error {
+				return fmt.Errorf("unexpected command at line %d", cmd.line)
+			}, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != string(in) {
+				t.Errorf("expected output\n%s\ngot\n%s", in, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// A PinMismatch is a command whose source no longer matches the checksum
+// pinned with its sha256 attribute.
+type PinMismatch struct {
+	// Line is the line of the command.
+	Line int
+	// Path is the path or URL of the source, with variables and aliases
+	// expanded.
+	Path string
+	// Pinned is the checksum of the command, and Got the one of the content
+	// fetched.
+	Pinned, Got string
+}
+
+// WithPin records the SHA-256 checksum of the content fetched by every
+// command embedding a URL, and by those already pinned, in their sha256
+// attribute, so later runs fail if the content changes.
+func WithPin() Option {
+	return Option{func(e *embedder) { e.pin = true }}
+}
+
+// parsePin parses the value of the sha256 attribute.
+func parsePin(val string) (string, error) {
+	if _, err := hex.DecodeString(val); err != nil || len(val) != 64 {
+		return "", fmt.Errorf("sha256 should be 64 hexadecimal digits, got %q", val)
+	}
+	return strings.ToLower(val), nil
+}
+
+// checkPin fails if the content fetched by cmd, whose SHA-256 hash is sum,
+// doesn't match the checksum pinned with its sha256 attribute.
+func checkPin(cmd *command, sum string) error {
+	if cmd.sha256 != "" && sum != cmd.sha256 {
+		return fmt.Errorf("content of %s doesn't match its pinned sha256 %s, got %s", cmd.path, cmd.sha256, sum)
+	}
+	return nil
+}
+
+// VerifyPins returns the commands in the markdown read from in whose source
+// doesn't match the checksum pinned with their sha256 attribute. Only the
+// sources of pinned commands are fetched.
+func VerifyPins(in io.Reader, opts ...Option) ([]PinMismatch, error) {
+	e, b, err := newEmbedder(in, opts)
+	if err != nil {
+		return nil, err
+	}
+	var mismatches []PinMismatch
+	err = e.eachCommand(b, func(c *command) error {
+		if c.sha256 == "" {
+			return nil
+		}
+		content, err := e.fetch(c.path)
+		if err != nil {
+			return fmt.Errorf("could not read %s: %w", c.path, err)
+		}
+		if sum := sha256Hex(string(content)); sum != c.sha256 {
+			mismatches = append(mismatches, PinMismatch{Line: c.line, Path: c.path, Pinned: c.sha256, Got: sum})
+		}
+		return nil
+	})
+	return mismatches, err
+}
+
+// pinCommands returns the markdown b with the sha256 attribute of the
+// commands pinned with WithPin set to the checksum of their content.
+func (e *embedder) pinCommands(b []byte) ([]byte, error) {
+	sums := map[*command]string{}
+	err := e.eachCommand(b, func(c *command) error {
+		if c.sha256 == "" && !isURL(c.path) {
+			return nil
+		}
+		content, err := e.fetch(c.path)
+		if err != nil {
+			return fmt.Errorf("could not read %s: %w", c.path, err)
+		}
+		if sum := sha256Hex(string(content)); sum != c.sha256 {
+			sums[c] = sum
+		}
+		return nil
+	})
+	if err != nil || len(sums) == 0 {
+		return b, err
+	}
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	for c, sum := range sums {
+		lines[c.line-1] = attrLine(lines[c.line-1], c.ownArgs(), "sha256", sum)
+	}
+	return bytes.Join(lines, nil), nil
+}
+
+// attrLine returns the line of a command, whose argument list is args, with
+// its attribute key set to val, or removed if val is empty.
+func attrLine(line []byte, args, key, val string) []byte {
+	args = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(args, "("), ")"))
+	i := bytes.Index(line, []byte(args))
+	if i < 0 {
+		return line
+	}
+	attr := regexp.MustCompile(`(^|\s)` + regexp.QuoteMeta(key) + `=\S*`)
+	set := args
+	switch {
+	case attr.MatchString(args) && val == "":
+		set = attr.ReplaceAllString(args, "")
+	case attr.MatchString(args):
+		set = attr.ReplaceAllString(args, "${1}"+key+"="+val)
+	case val != "":
+		set = args + " " + key + "=" + val
+	}
+	return append(append(append([]byte{}, line[:i]...), set...), line[i+len(args):]...)
+}
+
+// eachCommand calls f with every command of the markdown b, stacked ones
+// included, with variables and aliases expanded. Commands left untouched,
+// such as those in front matter, frozen, or left out by their labels, are
+// ignored.
+func (e *embedder) eachCommand(b []byte, f func(*command) error) error {
+	return e.parse(io.Discard, bytes.NewReader(b), func(_ io.Writer, cmd *command) error {
+		if e.skipped[cmd.line] || frozen(cmd) || e.deselected(cmd) {
+			return nil
+		}
+		for _, c := range append([]*command{cmd}, cmd.stacked...) {
+			if err := e.resolvePath(c); err != nil {
+				return &lineError{c.line, err}
+			}
+			var err error
+			if c.path, err = e.expandAlias(c.path); err != nil {
+				return &lineError{c.line, err}
+			}
+			if err := f(c); err != nil {
+				return &lineError{c.line, err}
+			}
+		}
+		return nil
+	})
+}
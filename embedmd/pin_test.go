@@ -0,0 +1,84 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	installURL = "https://example.com/install.sh"
+	installSum = "504a29a17b8c9fb7aaf8a7b7d68ae65c5b7ec12515cc7e088637f6c08d9caa36"
+	mainSum    = "df1d036cbbf3df46e2045071e082245ece204c7f53ecf0a4e022bff9bb228f47"
+	zeroSum    = "0000000000000000000000000000000000000000000000000000000000000000"
+)
+
+func TestPin(t *testing.T) {
+	provider := mixedContentProvider{
+		files: map[string][]byte{"code.go": []byte("package main\n")},
+		urls:  map[string][]byte{installURL: []byte("echo install\n")},
+	}
+	tc := []struct {
+		name, in, out string
+		pin           bool
+		err           string
+	}{
+		{name: "matching", in: "[embedmd]:# (" + installURL + " sh sha256=" + strings.ToUpper(installSum) + ")\n",
+			out: "[embedmd]:# (" + installURL + " sh sha256=" + strings.ToUpper(installSum) + ")\n```sh\necho install\n```\n"},
+		{name: "changed", in: "[embedmd]:# (" + installURL + " sh sha256=" + zeroSum + ")\n",
+			err: "1: content of " + installURL + " doesn't match its pinned sha256 " + zeroSum + ", got " + installSum},
+		{name: "bad checksum", in: "[embedmd]:# (code.go sha256=abc)\n", err: "1: sha256 should be 64 hexadecimal digits, got \"abc\""},
+		{name: "pinned", in: "[embedmd]:# (" + installURL + " sh)\n\n[embedmd]:# (code.go)\n", pin: true,
+			out: "[embedmd]:# (" + installURL + " sh sha256=" + installSum + ")\n```sh\necho install\n```\n\n[embedmd]:# (code.go)\n```go\npackage main\n```\n"},
+		{name: "repinned", in: "<!-- embedmd: code.go sha256=" + zeroSum + " caption=Main -->\n", pin: true,
+			out: "<!-- embedmd: code.go sha256=" + mainSum + " caption=Main -->\n<!-- embedmd block start -->\n*Main*\n\n```go\npackage main\n```\n<!-- embedmd block end -->\n"},
+		{name: "nested", in: "* Install:\n  [embedmd]:# (" + installURL + " sh)\n", pin: true,
+			out: "* Install:\n  [embedmd]:# (" + installURL + " sh sha256=" + installSum + ")\n  ```sh\n  echo install\n  ```\n"},
+	}
+	for _, tt := range tc {
+		opts := []Option{WithFetcher(provider), WithSyntaxes(LinkSyntax, CommentSyntax)}
+		if tt.pin {
+			opts = append(opts, WithPin())
+		}
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), opts...)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
+
+func TestVerifyPins(t *testing.T) {
+	provider := mixedContentProvider{
+		files: map[string][]byte{"code.go": []byte("package main\n")},
+		urls:  map[string][]byte{installURL: []byte("echo install\n")},
+	}
+	in := "[embedmd]:# (" + installURL + " sh sha256=" + installSum + ")\n\n" +
+		"[embedmd]:# (code.go sha256=" + zeroSum + ")\n\n" +
+		"[embedmd]:# (missing.go)\n"
+	got, err := VerifyPins(strings.NewReader(in), WithFetcher(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PinMismatch{{Line: 3, Path: "code.go", Pinned: zeroSum, Got: mainSum}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// A ChangeSet holds the changes processing a document would make to the
+// blocks of its commands, in order.
+type ChangeSet struct {
+	Changes []BlockChange
+}
+
+// A BlockChange is the change processing a document would make to the block
+// of a command.
+type BlockChange struct {
+	// Line is the line of the command, and Command its argument list, in
+	// parentheses, as written.
+	Line    int
+	Command string
+	// Start and End are the offsets of the block in the document, End
+	// excluded, and StartLine and EndLine its first and last lines. When the
+	// command has no block yet, Start is End, at the start of StartLine, and
+	// EndLine is the line before it.
+	Start, End         int
+	StartLine, EndLine int
+	// Old is the block in the document, with the comments following it, and
+	// New the one processing the document would write instead.
+	Old, New string
+	// Added is set when the command had no block yet.
+	Added bool
+	// Sources are the regions embedded in the block, one per command, in
+	// order.
+	Sources []SourceRegion
+}
+
+// Plan runs the commands of the markdown document doc with the options of p,
+// without rendering the document, and returns the changes to the blocks
+// that processing it would make, for bots and editors to present them as
+// they see fit. Relative paths are resolved as set with WithBaseDir. Plan
+// stops with the error of ctx once it's done, and is safe to call
+// concurrently, as long as the callbacks set in the options are.
+func (p *Processor) Plan(ctx context.Context, doc []byte) (*ChangeSet, error) {
+	e, b, err := newEmbedder(bytes.NewReader(doc), p.opts)
+	if err != nil {
+		return nil, err
+	}
+	if e.literal() || e.format == NotebookFormat {
+		return nil, fmt.Errorf("can only plan markdown documents, not %s ones", e.format)
+	}
+	e.warnTypos(b)
+	e.planning = true
+	e.ctx = ctx
+	if e.sourceMap != nil {
+		e.out = &lineCounter{w: io.Discard}
+	}
+	offsets := lineOffsets(doc)
+	offset := func(line int) int { return offsets[min(line-1, len(offsets)-1)] }
+
+	set := &ChangeSet{}
+	run := func(w io.Writer, cmd *command) error {
+		var buf bytes.Buffer
+		if err := e.runCommand(&buf, cmd); err != nil {
+			return err
+		}
+		lines := bytes.Count(cmd.block, []byte("\n")) + len(cmd.trailers)
+		start, end := offset(cmd.blockLine), offset(cmd.blockLine+lines)
+		if old := string(doc[start:end]); old != buf.String() {
+			change := BlockChange{
+				Line: cmd.line, Command: cmd.args,
+				Start: start, End: end,
+				StartLine: cmd.blockLine, EndLine: cmd.blockLine + lines - 1,
+				Old: old, New: buf.String(),
+				Added: lines == 0,
+			}
+			for _, c := range append([]*command{cmd}, cmd.stacked...) {
+				change.Sources = append(change.Sources, SourceRegion{c.source(), c.region[0], c.region[1]})
+			}
+			set.Changes = append(set.Changes, change)
+		}
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+	if err := e.parse(io.Discard, bytes.NewReader(doc), run); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// lineOffsets returns the offset of each line of b, and that of its end.
+func lineOffsets(b []byte) []int {
+	offsets := []int{0}
+	for i, c := range b {
+		if c == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		offsets = append(offsets, len(b))
+	}
+	return offsets
+}
@@ -0,0 +1,71 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	files := map[string][]byte{"a.go": []byte("package a\n\nfunc A() {}\n"), "b.go": []byte("func B() {}\n")}
+	doc := "# Doc\n\n[embedmd]:# (a.go /func/ $)\n```go\nfunc Old() {}\n```\n\n[embedmd]:# (b.go)\n```go\nfunc B() {}\n```\n\n[embedmd]:# (b.go)\nText.\n"
+	p, err := NewProcessor(WithFetcher(mixedContentProvider{files: files}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := p.Plan(context.Background(), []byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BlockChange{
+		{
+			Line: 3, Command: "(a.go /func/ $)",
+			Start: 35, End: 59, StartLine: 4, EndLine: 6,
+			Old: "```go\nfunc Old() {}\n```\n", New: "```go\nfunc A() {}\n```\n",
+			Sources: []SourceRegion{{Source{3, "a.go", "(a.go /func/ $)", "/func/ $"}, 3, 3}},
+		},
+		{
+			Line: 13, Command: "(b.go)",
+			Start: 121, End: 121, StartLine: 14, EndLine: 13,
+			New:     "```go\nfunc B() {}\n```\n",
+			Added:   true,
+			Sources: []SourceRegion{{Source{13, "b.go", "(b.go)", ""}, 1, 1}},
+		},
+	}
+	if !reflect.DeepEqual(set.Changes, want) {
+		t.Errorf("expected changes\n%+v\ngot\n%+v", want, set.Changes)
+	}
+	for _, c := range set.Changes {
+		if got := doc[c.Start:c.End]; got != c.Old {
+			t.Errorf("expected the offsets of %s to hold the old block %q; got %q", c.Command, c.Old, got)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Plan(ctx, []byte(doc)); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the plan to be canceled; got %v", err)
+	}
+
+	rst, err := NewProcessor(WithFormat(RSTFormat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rst.Plan(context.Background(), nil); err == nil || err.Error() != "can only plan markdown documents, not rst ones" {
+		t.Errorf("expected an error planning an rst document; got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// unixGOOS lists the values of GOOS of Unix-like systems, rendered with the
+// posix platform.
+var unixGOOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true, "illumos": true,
+	"ios": true, "linux": true, "netbsd": true, "openbsd": true, "solaris": true,
+}
+
+// WithPlatform renders the docs for the given platform, so a single tree
+// renders a variant for each: posix for Unix-like systems, or windows, or
+// the GOOS of a given system, such as darwin. Commands with a goos
+// attribute not matching the platform embed nothing, and their block is
+// removed unless they're stacked on a command that matches. {{ .goos }} in
+// paths expands to its GOOS, linux for posix, and the relative paths of
+// shell sessions and scripts use its path separator. Content embedded for
+// windows ends its lines with \r\n.
+func WithPlatform(name string) Option {
+	return Option{func(e *embedder) { e.platform = name }}
+}
+
+// validatePlatform checks the platform set with WithPlatform.
+func (e *embedder) validatePlatform() error {
+	if e.platform != "" && e.platform != "posix" && e.platform != "windows" && !unixGOOS[e.platform] {
+		return fmt.Errorf("bad platform %q, should be posix, windows, or the GOOS of a Unix-like system", e.platform)
+	}
+	return nil
+}
+
+// goos returns the GOOS of the platform.
+func (e *embedder) goos() string {
+	if e.platform == "posix" {
+		return "linux"
+	}
+	return e.platform
+}
+
+// parseGOOS parses the value of the goos attribute, a comma separated list
+// of GOOS values, or unix for all the Unix-like systems as in build
+// constraints.
+func parseGOOS(val string) ([]string, error) {
+	var goos []string
+	for _, s := range strings.Split(val, ",") {
+		if s != "windows" && s != "unix" && !unixGOOS[s] {
+			return nil, fmt.Errorf("goos should be a comma separated list of GOOS values, or unix, got %q", val)
+		}
+		goos = append(goos, s)
+	}
+	return goos, nil
+}
+
+// onPlatform reports whether cmd embeds content on the platform, always
+// true without one.
+func (e *embedder) onPlatform(cmd *command) bool {
+	if e.platform == "" || cmd.goos == nil {
+		return true
+	}
+	goos := e.goos()
+	for _, s := range cmd.goos {
+		if s == goos || s == "unix" && goos != "windows" {
+			return true
+		}
+	}
+	return false
+}
+
+// shellLangs lists the languages of shell sessions and scripts, whose
+// relative paths are written with the path separator of the platform.
+var shellLangs = map[string]bool{
+	"console": true, "shell-session": true, "sh-session": true, "sh": true, "shell": true, "bash": true,
+	"zsh": true, "powershell": true, "ps1": true, "bat": true, "batch": true, "cmd": true,
+}
+
+// relativePath matches the relative paths starting with ./ or ../, after a
+// blank, a quote, or an =.
+var relativePath = regexp.MustCompile(`(?m)(?:^|[\s"'=])\.\.?[/\\][^\s"']*`)
+
+// normalize returns the content b embedded by cmd with the line endings and
+// path separators of the platform.
+func (e *embedder) normalize(cmd *command, b []byte) []byte {
+	if e.platform == "" {
+		return b
+	}
+	sep, other := []byte("/"), []byte(`\`)
+	if e.platform == "windows" {
+		sep, other = other, sep
+	}
+	if shellLangs[strings.ToLower(cmd.lang)] {
+		b = relativePath.ReplaceAllFunc(b, func(p []byte) []byte {
+			return bytes.ReplaceAll(p, other, sep)
+		})
+	}
+	if e.platform == "windows" {
+		b = bytes.ReplaceAll(b, []byte("\n"), []byte("\r\n"))
+	}
+	return b
+}
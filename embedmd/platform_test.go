@@ -0,0 +1,85 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPlatform(t *testing.T) {
+	files := map[string][]byte{
+		"install.sh":      []byte("$ ./bin/install --config=./etc/app.conf\n"),
+		"install.ps1":     []byte("PS> .\\bin\\install.exe\n"),
+		"main_linux.go":   []byte("package linux\n"),
+		"main_darwin.go":  []byte("package darwin\n"),
+		"main_windows.go": []byte("package windows\n"),
+	}
+	tc := []struct {
+		name, platform, in, out, err string
+	}{
+		{name: "no platform",
+			in:  "[embedmd]:# (install.sh console goos=unix)\n",
+			out: "[embedmd]:# (install.sh console goos=unix)\n```console\n$ ./bin/install --config=./etc/app.conf\n```\n"},
+		{name: "posix",
+			platform: "posix",
+			in:       "[embedmd]:# (install.sh console goos=unix)\n\n[embedmd]:# (install.ps1 console goos=windows)\n",
+			out:      "[embedmd]:# (install.sh console goos=unix)\n```console\n$ ./bin/install --config=./etc/app.conf\n```\n\n[embedmd]:# (install.ps1 console goos=windows)\n"},
+		{name: "windows",
+			platform: "windows",
+			in:       "[embedmd]:# (install.sh console goos=unix)\n```console\nold\n```\n[embedmd]:# (install.sh console)\n",
+			out:      "[embedmd]:# (install.sh console goos=unix)\n[embedmd]:# (install.sh console)\n```console\n$ .\\bin\\install --config=.\\etc\\app.conf\r\n```\n"},
+		{name: "separators to posix",
+			platform: "darwin",
+			in:       "[embedmd]:# (install.ps1 powershell)\n",
+			out:      "[embedmd]:# (install.ps1 powershell)\n```powershell\nPS> ./bin/install.exe\n```\n"},
+		{name: "goos variable",
+			platform: "posix",
+			in:       "[embedmd]:# (main_{{ .goos }}.go)\n",
+			out:      "[embedmd]:# (main_{{ .goos }}.go)\n```go\npackage linux\n```\n"},
+		{name: "goos variable of a system",
+			platform: "darwin",
+			in:       "[embedmd]:# (main_{{ .goos }}.go)\n",
+			out:      "[embedmd]:# (main_{{ .goos }}.go)\n```go\npackage darwin\n```\n"},
+		{name: "stacked",
+			platform: "darwin",
+			in:       "[embedmd]:# (main_darwin.go goos=darwin,windows)\n[embedmd]:# (main_linux.go goos=linux)\n",
+			out:      "[embedmd]:# (main_darwin.go goos=darwin,windows)\n[embedmd]:# (main_linux.go goos=linux)\n```go\npackage darwin\n```\n"},
+		{name: "stacked on a command of another platform",
+			platform: "linux",
+			in:       "[embedmd]:# (main_darwin.go goos=darwin,windows)\n[embedmd]:# (main_linux.go goos=linux)\n```go\nold\n```\n",
+			out:      "[embedmd]:# (main_darwin.go goos=darwin,windows)\n[embedmd]:# (main_linux.go goos=linux)\n"},
+		{name: "goos variable without platform",
+			in:  "[embedmd]:# (main_{{ .goos }}.go)\n",
+			err: "1: no platform set for {{ .goos }} in main_{{ .goos }}.go"},
+		{name: "bad goos",
+			in:  "[embedmd]:# (main.go goos=beos)\n",
+			err: "1: goos should be a comma separated list of GOOS values, or unix, got \"beos\""},
+		{name: "bad platform",
+			platform: "beos",
+			in:       "[embedmd]:# (main.go)\n",
+			err:      "bad platform \"beos\", should be posix, windows, or the GOOS of a Unix-like system"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}), WithPlatform(tt.platform))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q; got\n%q", tt.name, tt.out, got)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// playgroundPage matches the path of the pages of snippets shared on the Go
+// Playground, capturing their ID.
+var playgroundPage = regexp.MustCompile(`^/(?:play/)?p/([\w-]+)$`)
+
+// playgroundRawURL returns the URL of the source of the snippet shared on
+// the Go Playground at u, as in https://go.dev/play/p/AbC123, if it's such a
+// link.
+func playgroundRawURL(u *url.URL) (string, bool) {
+	switch u.Hostname() {
+	case "go.dev", "play.golang.org", "play.golang.com":
+	default:
+		return "", false
+	}
+	// The Playground is at /play on go.dev, and at the root elsewhere.
+	m := playgroundPage.FindStringSubmatch(u.Path)
+	if m == nil || (u.Hostname() == "go.dev") != strings.HasPrefix(u.Path, "/play/") {
+		return "", false
+	}
+	return "https://go.dev/play/p/" + m[1] + ".go", true
+}
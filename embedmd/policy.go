@@ -0,0 +1,132 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// A PolicyRule allows or denies the commands for which Expr is true. Expr is
+// written in a subset of CEL, the Common Expression Language, and can use
+// these variables:
+//
+//	source  the path or URL embedded, with variables and aliases expanded
+//	scheme  "http" or "https" for URLs, "repo" or "git" for repo:// and git://
+//	        paths, "file" for files
+//	host    the host of URLs, the repository of repo:// paths, empty for files
+//	path    the path of URLs, or the slash separated path of files, without
+//	        the revision of git:// paths
+//	lang    the language of the embedded code
+//	attrs   the attributes of the command, including defaults, as a map
+//
+// For instance:
+//
+//	scheme == "https" && host in ["github.com", "raw.githubusercontent.com"]
+//	"caption" in attrs || !source.contains("third_party/")
+//
+// Expressions support string, bool, and list literals, the operators !, &&,
+// ||, ==, !=, and in, attrs.name and attrs["name"], and the string methods
+// startsWith, endsWith, contains, and matches.
+type PolicyRule struct {
+	Expr string
+	// Deny denies the matching commands, rather than allowing them.
+	Deny bool
+}
+
+// WithPolicy sets rules allowing or denying commands. A command is denied if
+// any rule denying commands matches it, or if there are rules allowing
+// commands and none of them matches it.
+func WithPolicy(rules ...PolicyRule) Option {
+	return Option{func(e *embedder) { e.policyRules = append(e.policyRules, rules...) }}
+}
+
+// policyVars are the variables of policy expressions.
+var policyVars = []string{"source", "scheme", "host", "path", "lang", "attrs"}
+
+// compiledRule is a PolicyRule with its compiled expression.
+type compiledRule struct {
+	PolicyRule
+	eval celFunc
+}
+
+// compilePolicy compiles the expressions of the policy rules.
+func (e *embedder) compilePolicy() error {
+	for _, r := range e.policyRules {
+		f, err := compileCEL(r.Expr, policyVars)
+		if err != nil {
+			return fmt.Errorf("bad policy %q: %v", r.Expr, err)
+		}
+		e.policy = append(e.policy, compiledRule{r, f})
+	}
+	return nil
+}
+
+// checkPolicy returns an error if cmd is denied by the policy.
+func (e *embedder) checkPolicy(cmd *command) error {
+	if len(e.policy) == 0 {
+		return nil
+	}
+	vars := map[string]any{
+		"source": cmd.path,
+		"scheme": "file",
+		"host":   "",
+		"path":   cmd.path,
+		"lang":   cmd.lang,
+		"attrs":  map[string]string{},
+	}
+	if isGitPath(cmd.path) {
+		file, _, _ := cutRevision(cmd.path)
+		vars["scheme"], vars["path"] = "git", file
+	} else if isExecPath(cmd.path) {
+		args, err := execArgs(cmd.path)
+		if err != nil {
+			return err
+		}
+		vars["scheme"], vars["path"] = "cmd", strings.Join(args, " ")
+	} else if isURL(cmd.path) || isRepoPath(cmd.path) {
+		u, err := url.Parse(cmd.path)
+		if err != nil {
+			return err
+		}
+		vars["scheme"], vars["host"], vars["path"] = u.Scheme, u.Hostname(), u.Path
+	}
+	for k, v := range cmd.attrs {
+		vars["attrs"].(map[string]string)[k] = v
+	}
+
+	allowed, allowRules := false, false
+	for _, r := range e.policy {
+		v, err := r.eval(vars)
+		if err != nil {
+			return fmt.Errorf("policy %q: %v", r.Expr, err)
+		}
+		match, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("policy %q: should be a bool, got %s", r.Expr, celType(v))
+		}
+		switch {
+		case r.Deny && match:
+			return fmt.Errorf("%s is denied by policy %q", cmd.path, r.Expr)
+		case !r.Deny:
+			allowRules = true
+			allowed = allowed || match
+		}
+	}
+	if allowRules && !allowed {
+		return fmt.Errorf("%s is not allowed by any policy", cmd.path)
+	}
+	return nil
+}
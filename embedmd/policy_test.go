@@ -0,0 +1,118 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCEL(t *testing.T) {
+	vars := map[string]any{
+		"source": "https://github.com/org/repo/main.go",
+		"host":   "github.com",
+		"attrs":  map[string]string{"caption": "An example"},
+	}
+	tc := []struct {
+		expr string
+		want any
+		err  string
+	}{
+		{expr: `host == "github.com"`, want: true},
+		{expr: `host != 'github.com'`, want: false},
+		{expr: `host in ["gitlab.com", "github.com"]`, want: true},
+		{expr: `"caption" in attrs && !("license" in attrs)`, want: true},
+		{expr: `attrs.caption == attrs["caption"]`, want: true},
+		{expr: `source.startsWith("https://") && source.endsWith(".go")`, want: true},
+		{expr: `source.contains("/org/") || false`, want: true},
+		{expr: `source.matches("^https://[a-z]+\.com/")`, want: true},
+		{expr: `false && attrs.missing == ""`, want: false},
+		{expr: `true || attrs.missing == ""`, want: true},
+		{expr: `attrs.missing == ""`, err: "no such key: missing"},
+		{expr: `!host`, err: "no such overload: ! applied to string"},
+		{expr: `host in "github.com"`, err: "no such overload: string in string"},
+		{expr: `host.size()`, err: "unknown method size"},
+		{expr: `hots == "github.com"`, err: "undeclared reference to hots"},
+		{expr: `host == "github.com`, err: "unterminated string at 9"},
+		{expr: `host == `, err: "unexpected end of expression"},
+		{expr: `(host == "a"`, err: `expected ")" at the end`},
+		{expr: `host = "a"`, err: `unexpected '=' at 6`},
+		{expr: `host "a"`, err: `unexpected "a"`},
+	}
+	for _, tt := range tc {
+		f, err := compileCEL(tt.expr, []string{"source", "host", "attrs"})
+		var got any
+		if err == nil {
+			got, err = f(vars)
+		}
+		if !eqErr(t, tt.expr, err, tt.err) {
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("case [%s]: expected %v; got %v", tt.expr, tt.want, got)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	files := map[string][]byte{"code.go": []byte(content), "third_party/lib.go": []byte(content)}
+	urls := map[string][]byte{"https://github.com/org/repo/main.go": []byte(content)}
+	tc := []struct {
+		name  string
+		in    string
+		rules []PolicyRule
+		err   string
+	}{
+		{
+			name:  "allowed host",
+			in:    "[embedmd]:# (https://github.com/org/repo/main.go)\n[embedmd]:# (code.go)\n",
+			rules: []PolicyRule{{Expr: `scheme == "file"`}, {Expr: `host == "github.com"`}},
+		},
+		{
+			name:  "host not allowed",
+			in:    "[embedmd]:# (code.go)\n[embedmd]:# (https://example.com/main.go)\n",
+			rules: []PolicyRule{{Expr: `scheme == "file"`}, {Expr: `host == "github.com"`}},
+			err:   "2: https://example.com/main.go is not allowed by any policy",
+		},
+		{
+			name:  "denied",
+			in:    "[embedmd]:# (third_party/lib.go)\n",
+			rules: []PolicyRule{{Expr: `path.startsWith("third_party/") && !("caption" in attrs)`, Deny: true}},
+			err:   `1: third_party/lib.go is denied by policy "path.startsWith(\"third_party/\") && !(\"caption\" in attrs)"`,
+		},
+		{
+			name:  "not denied",
+			in:    "[embedmd]:# (third_party/lib.go caption=\"From lib\")\n[embedmd]:# (code.go go)\n",
+			rules: []PolicyRule{{Expr: `path.startsWith("third_party/") && !("caption" in attrs)`, Deny: true}, {Expr: `lang == "markdown"`, Deny: true}},
+		},
+		{
+			name:  "not a bool",
+			in:    "[embedmd]:# (code.go)\n",
+			rules: []PolicyRule{{Expr: `source`}},
+			err:   `1: policy "source": should be a bool, got string`,
+		},
+		{
+			name:  "bad expression",
+			in:    "[embedmd]:# (code.go)\n",
+			rules: []PolicyRule{{Expr: `lang ==`}},
+			err:   `bad policy "lang ==": unexpected end of expression`,
+		},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files, urls}), WithPolicy(tt.rules...))
+		eqErr(t, tt.name, err, tt.err)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// previewLines is the number of lines quoted from the start and the end of
+// the code selected in the warnings about suspicious selections.
+const previewLines = 3
+
+// maxPreviewWidth is the number of characters quoted from each line.
+const maxPreviewWidth = 100
+
+// WithSelectionLimit warns about the commands selecting more than n lines of
+// their source, quoting the first and last of them, as when the end regular
+// expression of a command matches much further than meant. Selections that
+// are empty, and those spanning the whole source with regular expressions,
+// are always reported so. A limit of 0 or less sets none.
+func WithSelectionLimit(n int) Option {
+	return Option{func(e *embedder) { e.selectionLimit = max(n, 0) }}
+}
+
+// selects reports whether cmd selects a part of its source, rather than
+// embedding all of it.
+func selects(cmd *command) bool {
+	return cmd.start != nil || cmd.tag != "" || cmd.symbol != "" || cmd.heading != "" || cmd.selectWith != nil
+}
+
+// checkSelection warns about the code b selected by cmd from src if it looks
+// like a mistake: nothing but blank lines, more lines than the selection
+// limit, or all the source although regular expressions delimit it. The
+// warnings quote the code selected, to tell what went wrong from CI logs.
+func (e *embedder) checkSelection(cmd *command, src, b []byte) {
+	if !selects(cmd) {
+		return
+	}
+	n := bytes.Count(b, []byte("\n"))
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		n++
+	}
+	switch {
+	case len(bytes.TrimSpace(b)) == 0:
+		e.warnf(cmd, "selection of %s is empty%s", cmd.path, preview(b, 0))
+	case cmd.start != nil && *cmd.start != "" && cmd.end != nil && *cmd.end != "$" && n > previewLines &&
+		bytes.Equal(bytes.TrimSpace(b), bytes.TrimSpace(src)):
+		e.warnf(cmd, "selection of %s is all of its %d lines, as the regular expressions match the first and the last one:\n%s",
+			cmd.path, n, preview(b, 1))
+	case e.selectionLimit > 0 && n > e.selectionLimit:
+		region := regionLines(src, b)
+		e.warnf(cmd, "selection of %s has %d lines, more than %d, from line %d to %d:\n%s",
+			cmd.path, n, e.selectionLimit, region[0], region[1], preview(b, region[0]))
+	}
+}
+
+// preview returns the first and last lines of the code b, numbered from
+// start, or from 1 if it's 0, with an ellipsis for those in between.
+func preview(b []byte, start int) string {
+	if len(bytes.TrimSpace(b)) == 0 {
+		if len(b) == 0 {
+			return ""
+		}
+		return fmt.Sprintf(", only %d blank lines", bytes.Count(b, []byte("\n")))
+	}
+	start = max(start, 1)
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	width := len(fmt.Sprint(start + len(lines) - 1))
+	var sb strings.Builder
+	quote := func(i int) {
+		line := lines[i]
+		if r := []rune(line); len(r) > maxPreviewWidth {
+			line = string(r[:maxPreviewWidth]) + "…"
+		}
+		fmt.Fprintf(&sb, "\t%*d | %s\n", width, start+i, line)
+	}
+	for i := range lines {
+		switch {
+		case i < previewLines || i >= len(lines)-previewLines:
+			quote(i)
+		case i == previewLines:
+			fmt.Fprintf(&sb, "\t%*s | …\n", width, "")
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
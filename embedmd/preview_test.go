@@ -0,0 +1,61 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCheckSelection(t *testing.T) {
+	src := "package main\n\nimport \"fmt\"\n\n// begin\n\n\n// end\n\nfunc main() {\n\tfmt.Println(1)\n\tfmt.Println(2)\n\tfmt.Println(3)\n\tfmt.Println(4)\n}\n"
+	tc := []struct {
+		name, cmd string
+		warnings  []string
+	}{
+		{name: "fine", cmd: "(code.go /func main/ /Println.1./)"},
+		{name: "whole file", cmd: "(code.go)"},
+		{name: "blank lines", cmd: "(code.go /\\n\\n\\n/)",
+			warnings: []string{"1: selection of code.go is empty, only 3 blank lines"}},
+		{name: "too long", cmd: "(code.go /func main/ $)",
+			warnings: []string{"1: selection of code.go has 6 lines, more than 5, from line 10 to 15:\n" +
+				"\t10 | func main() {\n\t11 | \tfmt.Println(1)\n\t12 | \tfmt.Println(2)\n\t13 | \tfmt.Println(3)\n\t14 | \tfmt.Println(4)\n\t15 | }"}},
+		{name: "all of the source", cmd: "(code.go /package/ /^}/)",
+			warnings: []string{"1: selection of code.go is all of its 15 lines, as the regular expressions match the first and the last one:\n" +
+				"\t 1 | package main\n\t 2 | \n\t 3 | import \"fmt\"\n\t   | …\n\t13 | \tfmt.Println(3)\n\t14 | \tfmt.Println(4)\n\t15 | }"}},
+	}
+	for _, tt := range tc {
+		var warnings []string
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader("[embedmd]:# "+tt.cmd+"\n"),
+			WithFetcher(mixedContentProvider{files: map[string][]byte{"code.go": []byte(src)}}),
+			WithSelectionLimit(5),
+			WithWarnings(func(line int, msg string) { warnings = append(warnings, fmt.Sprintf("%d: %s", line, msg)) }))
+		if err != nil {
+			t.Errorf("case [%s]: %v", tt.name, err)
+		}
+		if fmt.Sprint(warnings) != fmt.Sprint(tt.warnings) {
+			t.Errorf("case [%s]: expected warnings\n%s\ngot\n%s", tt.name, strings.Join(tt.warnings, "\n"), strings.Join(warnings, "\n"))
+		}
+	}
+}
+
+func TestPreview(t *testing.T) {
+	long := strings.Repeat("x", maxPreviewWidth+5)
+	if got, want := preview([]byte("a\n"+long+"\n"), 99), "\t 99 | a\n\t100 | "+strings.Repeat("x", maxPreviewWidth)+"…"; got != want {
+		t.Errorf("expected preview\n%s\ngot\n%s", want, got)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A Processor embeds code in markdown documents with the options it was
+// created with, so programs processing many documents, such as static site
+// generators and linters, set them once:
+//
+//	p, err := embedmd.NewProcessor(embedmd.WithFence("~~~"), embedmd.WithDryRun())
+//	if err != nil {
+//		return err
+//	}
+//	changed, err := p.ProcessFile("docs/usage.md")
+type Processor struct {
+	opts   []Option
+	dryRun bool
+}
+
+// NewProcessor returns a Processor with the given options, failing if they
+// are invalid.
+func NewProcessor(opts ...Option) (*Processor, error) {
+	e, _, err := newEmbedder(strings.NewReader(""), opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Processor{opts: opts, dryRun: e.dryRun}, nil
+}
+
+// WithDryRun runs the commands, reporting their errors and warnings, without
+// changing the markdown: Process writes it as it was read, and
+// Processor.ProcessFile reports whether the file would change without
+// rewriting it.
+func WithDryRun() Option {
+	return Option{func(e *embedder) { e.dryRun = true }}
+}
+
+// Process works as the Process function, with the options of p.
+func (p *Processor) Process(out io.Writer, in io.Reader) error {
+	return Process(out, in, p.opts...)
+}
+
+// ProcessContext works as the ProcessContext function, with the options of
+// p, so each document can be processed with the context of its request.
+func (p *Processor) ProcessContext(ctx context.Context, out io.Writer, in io.Reader) error {
+	return ProcessContext(ctx, out, in, p.opts...)
+}
+
+// ProcessFile works as the ProcessFile function, with the options of p,
+// reporting whether the content changed.
+func (p *Processor) ProcessFile(path string) (changed bool, err error) {
+	r, err := ProcessFile(path, p.opts...)
+	return r.Changed, err
+}
+
+// A Result is the outcome of processing a markdown file.
+type Result struct {
+	// Changed is set when the content of the file changed, so it was
+	// rewritten, unless WithDryRun is set.
+	Changed bool
+}
+
+// ProcessFile processes the document at path, in the format told by its
+// extension unless set with WithFormat, resolving relative paths from its
+// directory unless set with WithBaseDir, and rewrites it with WriteFile if
+// its content changed, unless WithDryRun is set.
+func ProcessFile(path string, opts ...Option) (Result, error) {
+	p, err := NewProcessor(opts...)
+	if err != nil {
+		return Result{}, err
+	}
+	in, err := os.ReadFile(path)
+	if err != nil {
+		return Result{}, err
+	}
+	opts = append([]Option{WithBaseDir(filepath.Dir(path)), WithFormat(FormatOf(path))}, opts...)
+	// The output is compared with the input, so it's needed even on dry runs.
+	opts = append(opts, Option{func(e *embedder) { e.dryRun = false }})
+	var out bytes.Buffer
+	if err := Process(&out, bytes.NewReader(in), opts...); err != nil {
+		return Result{}, err
+	}
+	if bytes.Equal(in, out.Bytes()) {
+		return Result{}, nil
+	}
+	if !p.dryRun {
+		if _, err := WriteFile(path, out.Bytes()); err != nil {
+			return Result{}, err
+		}
+	}
+	return Result{Changed: true}, nil
+}
+
+// WriteFile replaces the content of the file at path with b, unless it's
+// already b, so its modification time is only updated when it changes. The
+// file is replaced atomically, keeping its permissions, and files behind
+// symbolic links are replaced rather than the links. It reports whether the
+// file changed.
+func WriteFile(path string, b []byte) (changed bool, err error) {
+	if path, err = filepath.EvalSymlinks(path); err != nil {
+		return false, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	old, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(old, b) {
+		return false, nil
+	}
+	return true, writeAtomic(path, b, fi.Mode().Perm())
+}
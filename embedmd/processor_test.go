@@ -0,0 +1,140 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessor(t *testing.T) {
+	if _, err := NewProcessor(WithFence("~~")); err == nil || err.Error() != "bad fence \"~~\": should be at least three backticks or tildes, or mdx" {
+		t.Errorf("expected an error for the bad fence, got %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	doc := filepath.Join(dir, "doc.md")
+	in := "# Doc\n\n[embedmd]:# (main.go /func main/ $)\n"
+	want := "# Doc\n\n[embedmd]:# (main.go /func main/ $)\n~~~go\nfunc main() {\n        fmt.Println(\"hello, test\")\n}\n~~~\n"
+	if err := os.WriteFile(doc, []byte(in), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	dry, err := NewProcessor(WithFence("~~~"), WithDryRun(), WithBaseDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := dry.Process(&out, strings.NewReader(in)); err != nil || out.String() != in {
+		t.Errorf("dry run: expected the markdown unchanged, got %q (%v)", out.String(), err)
+	}
+	if err := dry.Process(&out, strings.NewReader("[embedmd]:# (missing.go)\n")); err == nil {
+		t.Errorf("dry run: expected the error of the command")
+	}
+	changed, err := dry.ProcessFile(doc)
+	if b, _ := os.ReadFile(doc); err != nil || !changed || string(b) != in {
+		t.Errorf("dry run: expected the file to be reported changed but kept, got %v (%v) and\n%q", changed, err, b)
+	}
+
+	p, err := NewProcessor(WithFence("~~~"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err = p.ProcessFile(doc)
+	if b, _ := os.ReadFile(doc); err != nil || !changed || string(b) != want {
+		t.Errorf("expected the file to be rewritten, got %v (%v) and\n%q", changed, err, b)
+	}
+	if fi, err := os.Stat(doc); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("expected the mode of the file to be kept, got %v (%v)", fi.Mode(), err)
+	}
+	if changed, err = p.ProcessFile(doc); err != nil || changed {
+		t.Errorf("expected the file up to date, got %v (%v)", changed, err)
+	}
+}
+
+func TestProcessFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	doc := filepath.Join(dir, "doc.md")
+	if err := os.WriteFile(doc, []byte("[embedmd]:# (main.go)\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.md")
+	if err := os.Symlink("doc.md", link); err != nil {
+		t.Skipf("symbolic links not supported: %v", err)
+	}
+
+	r, err := ProcessFile(link)
+	if err != nil || !r.Changed {
+		t.Fatalf("expected the file to change, got %+v (%v)", r, err)
+	}
+	if b, _ := os.ReadFile(doc); string(b) != "[embedmd]:# (main.go)\n```go\npackage main\n```\n" {
+		t.Errorf("expected the file behind the link to be rewritten, got\n%q", b)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("expected the link to be kept, got %v (%v)", fi.Mode(), err)
+	}
+	fi, err := os.Stat(doc)
+	if err != nil || fi.Mode().Perm() != 0640 {
+		t.Fatalf("expected the mode of the file to be kept, got %v (%v)", fi.Mode(), err)
+	}
+	// Unchanged files aren't written.
+	old := fi.ModTime().Add(-time.Hour)
+	if err := os.Chtimes(doc, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if r, err = ProcessFile(doc); err != nil || r.Changed {
+		t.Errorf("expected the file up to date, got %+v (%v)", r, err)
+	}
+	if fi, err := os.Stat(doc); err != nil || !fi.ModTime().Equal(old) {
+		t.Errorf("expected the modification time to be kept, got %v (%v)", fi.ModTime(), err)
+	}
+}
+
+func TestProcessContext(t *testing.T) {
+	fetched := false
+	f := FetcherFunc(func(dir, path string) ([]byte, error) {
+		fetched = true
+		return []byte("package main\n"), nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	in := "# Doc\n[embedmd]:# (main.go)\n"
+	var out bytes.Buffer
+	err := ProcessContext(ctx, &out, strings.NewReader(in), WithFetcher(f))
+	if err == nil || err.Error() != "2: context canceled" || fetched {
+		t.Errorf("expected the processing to stop without fetching; got %v, fetched: %v", err, fetched)
+	}
+	p, err := NewProcessor(WithFetcher(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ProcessContext(ctx, &out, strings.NewReader(in)); err == nil || err.Error() != "2: context canceled" {
+		t.Errorf("expected the processor to stop too; got %v", err)
+	}
+	err = Process(&out, strings.NewReader(in), WithFetcher(f), WithContext(ctx), WithKeepGoing())
+	if err == nil || err.Error() != "2: context canceled" {
+		t.Errorf("expected -keep-going to stop too; got %v", err)
+	}
+}
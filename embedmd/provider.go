@@ -0,0 +1,244 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Provider supports a kind of forge, the Kind of the Forges it handles:
+// it has the default templates of their URLs, locates the files shown by
+// their pages, so they are fetched from their raw URLs, and tells how
+// tokens are sent to them. Providers of other kinds than the built-in ones
+// are added with RegisterProvider.
+type Provider interface {
+	// Templates returns the default RawURL, CloneURL, and WikiURL templates
+	// of the forges.
+	Templates() Forge
+	// ParsePage locates the file shown by the page at u, without its host,
+	// or returns false if u doesn't show a file.
+	ParsePage(u *url.URL) (ForgeFile, bool)
+	// Credential returns the credential sending token to the forges.
+	Credential(token string) Credential
+}
+
+// An Editor is a Provider whose forges edit files in their web interface,
+// which WithEditLinks links to.
+type Editor interface {
+	// EditURL returns the URL editing the file on its branch, at the lines
+	// from start to end if start isn't 0.
+	EditURL(ff ForgeFile, start, end int) string
+}
+
+// A Linker is a Provider whose forges show files at a commit, which the
+// footers of WithAttribution link to.
+type Linker interface {
+	// BlobURL returns the URL showing the file at its ref, at the lines
+	// from start to end if start isn't 0.
+	BlobURL(ff ForgeFile, start, end int) string
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]Provider{
+		"github": &builtinProvider{
+			templates: Forge{RawURL: "https://{host}/raw/{owner}/{repo}/{ref}/{path}", CloneURL: "https://{host}/{owner}/{repo}"},
+			// /owner/repo/blob/ref/path
+			parse: func(segs []string, _ *url.URL) (ForgeFile, bool) {
+				if len(segs) < 5 || segs[2] != "blob" {
+					return ForgeFile{}, false
+				}
+				return ForgeFile{Owner: segs[0], Repo: segs[1], Ref: segs[3], Path: strings.Join(segs[4:], "/")}, true
+			},
+			scheme:  "Bearer",
+			edit:    "https://{host}/{owner}/{repo}/edit/{ref}/{path}",
+			blob:    "https://{host}/{owner}/{repo}/blob/{ref}/{path}",
+			anchors: [2]string{"#L%d", "-L%d"},
+		},
+		"gitlab": &builtinProvider{
+			templates: Forge{RawURL: "https://{host}/{owner}/{repo}/-/raw/{ref}/{path}", CloneURL: "https://{host}/{owner}/{repo}"},
+			// /group/subgroup/repo/-/blob/ref/path
+			parse: func(segs []string, _ *url.URL) (ForgeFile, bool) {
+				i := slices.Index(segs, "-")
+				if i < 2 || len(segs) < i+4 || segs[i+1] != "blob" {
+					return ForgeFile{}, false
+				}
+				return ForgeFile{Owner: strings.Join(segs[:i-1], "/"), Repo: segs[i-1], Ref: segs[i+2], Path: strings.Join(segs[i+3:], "/")}, true
+			},
+			header:  "PRIVATE-TOKEN",
+			edit:    "https://{host}/{owner}/{repo}/-/edit/{ref}/{path}",
+			blob:    "https://{host}/{owner}/{repo}/-/blob/{ref}/{path}",
+			anchors: [2]string{"#L%d", "-%d"},
+		},
+		"gitea": &builtinProvider{
+			templates: Forge{RawURL: "https://{host}/{owner}/{repo}/raw/{ref}/{path}", CloneURL: "https://{host}/{owner}/{repo}"},
+			// /owner/repo/src/branch/ref/path, where branch can be tag or
+			// commit
+			parse: func(segs []string, _ *url.URL) (ForgeFile, bool) {
+				if len(segs) < 6 || segs[2] != "src" {
+					return ForgeFile{}, false
+				}
+				return ForgeFile{Owner: segs[0], Repo: segs[1], Ref: segs[3] + "/" + segs[4], Path: strings.Join(segs[5:], "/")}, true
+			},
+			scheme:  "token",
+			edit:    "https://{host}/{owner}/{repo}/_edit/{ref}/{path}",
+			blob:    "https://{host}/{owner}/{repo}/src/commit/{ref}/{path}",
+			anchors: [2]string{"#L%d", "-L%d"},
+		},
+		// Bitbucket Server has no editor URL, so its files are linked to
+		// their page.
+		"bitbucket": &builtinProvider{
+			templates: Forge{RawURL: "https://{host}/projects/{owner}/repos/{repo}/raw/{path}?at={ref}", CloneURL: "https://{host}/scm/{owner}/{repo}.git"},
+			// /projects/KEY/repos/repo/browse/path?at=ref
+			parse: func(segs []string, u *url.URL) (ForgeFile, bool) {
+				if len(segs) < 6 || segs[0] != "projects" || segs[2] != "repos" || segs[4] != "browse" {
+					return ForgeFile{}, false
+				}
+				return ForgeFile{Owner: segs[1], Repo: segs[3], Ref: url.QueryEscape(u.Query().Get("at")), Path: strings.Join(segs[5:], "/")}, true
+			},
+			scheme:  "Bearer",
+			edit:    "https://{host}/projects/{owner}/repos/{repo}/browse/{path}?at={ref}",
+			blob:    "https://{host}/projects/{owner}/repos/{repo}/browse/{path}?at={ref}",
+			anchors: [2]string{"#%d", "-%d"},
+		},
+		"bitbucket-cloud": &builtinProvider{
+			templates: Forge{RawURL: "https://{host}/{owner}/{repo}/raw/{ref}/{path}", CloneURL: "https://{host}/{owner}/{repo}.git"},
+			// /owner/repo/src/ref/path
+			parse: func(segs []string, _ *url.URL) (ForgeFile, bool) {
+				if len(segs) < 5 || segs[2] != "src" {
+					return ForgeFile{}, false
+				}
+				return ForgeFile{Owner: segs[0], Repo: segs[1], Ref: segs[3], Path: strings.Join(segs[4:], "/")}, true
+			},
+			scheme:  "Bearer",
+			edit:    "https://{host}/{owner}/{repo}/src/{ref}/{path}?mode=edit",
+			blob:    "https://{host}/{owner}/{repo}/src/{ref}/{path}",
+			anchors: [2]string{"#lines-%d", ":%d"},
+		},
+	}
+)
+
+// RegisterProvider adds the provider of the forges of the given kind, or
+// replaces a built-in one. It must be called before processing, usually
+// from an init function.
+func RegisterProvider(kind string, p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[kind] = p
+}
+
+// provider returns the provider of the forges of the given kind.
+func provider(kind string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[kind]
+	return p, ok
+}
+
+// providerKinds returns the kinds of forges supported, as a list for
+// messages.
+func providerKinds() string {
+	providersMu.RLock()
+	var kinds []string
+	for kind := range providers {
+		kinds = append(kinds, kind)
+	}
+	providersMu.RUnlock()
+	sort.Strings(kinds)
+	return strings.Join(kinds[:len(kinds)-1], ", ") + ", or " + kinds[len(kinds)-1]
+}
+
+// builtinProvider is the provider of the built-in kinds of forges.
+type builtinProvider struct {
+	templates Forge
+	// parse locates the file shown by the page at u, split in segs.
+	parse func(segs []string, u *url.URL) (ForgeFile, bool)
+	// header is the header sending tokens, or scheme the scheme of the
+	// Authorization header they're sent in.
+	header, scheme string
+	// edit is the template of the URLs of the web editor, blob that of the
+	// pages showing files, and anchors the format of the anchors of a line,
+	// and of the end of a range of lines.
+	edit, blob string
+	anchors    [2]string
+}
+
+func (p *builtinProvider) Templates() Forge { return p.templates }
+
+func (p *builtinProvider) ParsePage(u *url.URL) (ForgeFile, bool) {
+	return p.parse(strings.Split(strings.Trim(u.EscapedPath(), "/"), "/"), u)
+}
+
+func (p *builtinProvider) Credential(token string) Credential {
+	if p.header != "" {
+		return Credential{Header: p.header, Value: token}
+	}
+	return Credential{Value: p.scheme + " " + token}
+}
+
+func (p *builtinProvider) EditURL(ff ForgeFile, start, end int) string {
+	return p.lines(ff.expand(p.edit), start, end)
+}
+
+func (p *builtinProvider) BlobURL(ff ForgeFile, start, end int) string {
+	return p.lines(ff.expand(p.blob), start, end)
+}
+
+// lines adds the anchor of the lines from start to end to link.
+func (p *builtinProvider) lines(link string, start, end int) string {
+	if start > 0 {
+		link += fmt.Sprintf(p.anchors[0], start)
+		if end > start {
+			link += fmt.Sprintf(p.anchors[1], end)
+		}
+	}
+	return link
+}
+
+// TokenCredential returns the credential sending token to host: as its
+// provider expects it if it's one of the forges or a public one, and as a
+// bearer token otherwise.
+func TokenCredential(host, token string, forges ...Forge) Credential {
+	if f, ok := findForge(host, forges); ok {
+		if p, ok := provider(f.Kind); ok {
+			return p.Credential(token)
+		}
+	}
+	return BearerToken(token)
+}
+
+// ForgeCredentials sends the bearer tokens provided by p to the forges, or
+// the public ones, as their providers expect them, such as in the
+// PRIVATE-TOKEN header for GitLab. Other credentials are kept as is.
+func ForgeCredentials(p CredentialProvider, forges ...Forge) CredentialProvider {
+	return forgeCredentials{p, forges}
+}
+
+type forgeCredentials struct {
+	CredentialProvider
+	forges []Forge
+}
+
+func (fc forgeCredentials) Credential(host string) (Credential, bool) {
+	c, ok := fc.CredentialProvider.Credential(host)
+	if token, bearer := strings.CutPrefix(c.Value, "Bearer "); ok && bearer && c.Header == "" {
+		return TokenCredential(host, token, fc.forges...), true
+	}
+	return c, ok
+}
@@ -0,0 +1,110 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestTokenCredential(t *testing.T) {
+	forges := []Forge{{Host: "gitlab.example.com", Kind: "gitlab"}, {Host: "git.example.com", Kind: "gitea"}}
+	tc := []struct{ host, want string }{
+		{"github.com", "{ Bearer t}"},
+		{"gitlab.com", "{PRIVATE-TOKEN t}"},
+		{"gitlab.example.com", "{PRIVATE-TOKEN t}"},
+		{"git.example.com", "{ token t}"},
+		{"codeberg.org", "{ token t}"},
+		{"bitbucket.org", "{ Bearer t}"},
+		{"example.com", "{ Bearer t}"},
+	}
+	for _, tt := range tc {
+		if got := fmt.Sprint(TokenCredential(tt.host, "t", forges...)); got != tt.want {
+			t.Errorf("case [%s]: expected credential %s; got %s", tt.host, tt.want, got)
+		}
+	}
+}
+
+func TestForgeCredentials(t *testing.T) {
+	hosts := HostCredentials{
+		"gitlab.example.com": BearerToken("t"),
+		"git.example.com":    {Header: "X-Token", Value: "t"},
+		"example.com":        BearerToken("t"),
+	}
+	p := ForgeCredentials(hosts, Forge{Host: "gitlab.example.com", Kind: "gitlab"}, Forge{Host: "git.example.com", Kind: "gitea"})
+	tc := []struct{ host, want string }{
+		{"gitlab.example.com", "{PRIVATE-TOKEN t}"},
+		// Headers set explicitly are kept.
+		{"git.example.com", "{X-Token t}"},
+		{"example.com", "{ Bearer t}"},
+		{"other.example.com", ""},
+	}
+	for _, tt := range tc {
+		c, ok := p.Credential(tt.host)
+		got := ""
+		if ok {
+			got = fmt.Sprint(c)
+		}
+		if got != tt.want {
+			t.Errorf("case [%s]: expected credential %q; got %q", tt.host, tt.want, got)
+		}
+	}
+}
+
+// sourcehut is a provider of forges with pages at /~owner/repo/tree/ref/item/path.
+type sourcehut struct{}
+
+func (sourcehut) Templates() Forge {
+	return Forge{RawURL: "https://{host}/{owner}/{repo}/blob/{ref}/{path}", CloneURL: "https://{host}/{owner}/{repo}"}
+}
+
+func (sourcehut) ParsePage(u *url.URL) (ForgeFile, bool) {
+	segs := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	if len(segs) < 6 || segs[2] != "tree" || segs[4] != "item" {
+		return ForgeFile{}, false
+	}
+	return ForgeFile{Owner: segs[0], Repo: segs[1], Ref: segs[3], Path: strings.Join(segs[5:], "/")}, true
+}
+
+func (sourcehut) Credential(token string) Credential { return Credential{Value: "token " + token} }
+
+func TestRegisterProvider(t *testing.T) {
+	RegisterProvider("sourcehut", sourcehut{})
+	defer func() {
+		providersMu.Lock()
+		delete(providers, "sourcehut")
+		providersMu.Unlock()
+	}()
+
+	page := "https://git.sr.ht/~org/repo/tree/main/item/main.go"
+	raw := "https://git.sr.ht/~org/repo/blob/main/main.go"
+	var out bytes.Buffer
+	err := Process(&out, strings.NewReader("[embedmd]:# ("+page+")\n"),
+		WithForges(Forge{Host: "git.sr.ht", Kind: "sourcehut"}),
+		WithEditLinks(EditLinks{}),
+		WithFetcher(mixedContentProvider{urls: map[string][]byte{raw: []byte("package main\n")}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Providers that aren't editors get no edit links.
+	if want := "[embedmd]:# (" + page + ")\n```go\npackage main\n```\n"; out.String() != want {
+		t.Errorf("expected output\n%q\ngot\n%q", want, out.String())
+	}
+	if got := fmt.Sprint(TokenCredential("git.sr.ht", "t", Forge{Host: "git.sr.ht", Kind: "sourcehut"})); got != "{ token t}" {
+		t.Errorf("expected the credential of the provider; got %s", got)
+	}
+}
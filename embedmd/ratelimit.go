@@ -0,0 +1,214 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A RateBudget keeps the requests sent by its clients within the rate limits
+// announced by servers, such as the GitHub API, in their X-RateLimit or
+// RateLimit headers. Requests to a host are counted as they are sent, so
+// concurrent fetches share what is left, and wait for the limit to reset
+// once nothing is. A request refused with a 403 Forbidden or 429 Too Many
+// Requests status as the limit is exhausted is sent again after the reset.
+type RateBudget struct {
+	// Reserve is the number of requests to each host left unused.
+	Reserve int
+	// MaxWait is how long requests wait at most for a limit to reset. Those
+	// needing longer fail, as do all of them if MaxWait is 0, rather than
+	// stall the run.
+	MaxWait time.Duration
+
+	mu     sync.Mutex
+	limits map[string]*RateLimit
+}
+
+// A RateLimit is the rate limit of a host, as last announced.
+type RateLimit struct {
+	Host string
+	// Limit is the number of requests allowed until Reset, and Remaining the
+	// number of those left. Limit is 0 when unknown.
+	Limit, Remaining int
+	Reset            time.Time
+}
+
+// RateLimitError reports that the rate limit of a host is exhausted.
+type RateLimitError struct {
+	RateLimit
+}
+
+func (e *RateLimitError) Error() string {
+	msg := fmt.Sprintf("rate limit of %s exhausted until %s", e.Host, e.Reset.Format(time.TimeOnly))
+	if e.Limit > 0 {
+		msg += fmt.Sprintf(", after %d requests", e.Limit)
+	}
+	return msg
+}
+
+// Client returns a copy of c, or of the default client if nil, sending its
+// requests within the budget.
+func (b *RateBudget) Client(c *http.Client) *http.Client {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	cc := *c
+	base := cc.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	cc.Transport = &budgetTransport{b, base}
+	return &cc
+}
+
+// Limits returns the rate limits announced by the hosts, sorted by host.
+func (b *RateBudget) Limits() []RateLimit {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var limits []RateLimit
+	for _, l := range b.limits {
+		limits = append(limits, *l)
+	}
+	slices.SortFunc(limits, func(a, b RateLimit) int { return strings.Compare(a.Host, b.Host) })
+	return limits
+}
+
+// take counts a request to host, waiting for its limit to reset if no
+// request is left.
+func (b *RateBudget) take(host string) error {
+	for {
+		b.mu.Lock()
+		l := b.limits[host]
+		if l == nil || l.Remaining > b.Reserve {
+			if l != nil {
+				l.Remaining--
+			}
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Until(l.Reset)
+		if wait <= 0 {
+			// The next response announces the new limit.
+			delete(b.limits, host)
+			b.mu.Unlock()
+			return nil
+		}
+		exhausted := *l
+		b.mu.Unlock()
+		if wait > b.MaxWait {
+			return &RateLimitError{exhausted}
+		}
+		time.Sleep(wait)
+	}
+}
+
+// update records the rate limit announced by the response res of host,
+// returning it and whether res refused the request as it's exhausted.
+func (b *RateBudget) update(host string, res *http.Response) (RateLimit, bool) {
+	remaining, announced := rateHeader(res.Header, "Remaining")
+	limit, _ := rateHeader(res.Header, "Limit")
+	reset, hasReset := rateReset(res.Header)
+	refused := res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusTooManyRequests
+	if d, ok := retryAfter(res.Header.Get("Retry-After")); refused && ok {
+		reset, hasReset = time.Now().Add(d), true
+	} else {
+		refused = refused && announced && remaining == 0
+	}
+	if !announced && !refused {
+		return RateLimit{}, false
+	}
+	if !hasReset {
+		reset = time.Now().Add(time.Minute)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limits == nil {
+		b.limits = map[string]*RateLimit{}
+	}
+	l := b.limits[host]
+	switch {
+	case l == nil:
+		l = &RateLimit{Host: host, Limit: limit, Remaining: remaining, Reset: reset}
+		b.limits[host] = l
+	case l.Reset.Equal(reset):
+		// Responses to concurrent requests may come in any order.
+		l.Remaining = min(l.Remaining, remaining)
+	default:
+		l.Limit, l.Remaining, l.Reset = limit, remaining, reset
+	}
+	if refused {
+		l.Remaining, l.Reset = 0, reset
+	}
+	return *l, refused
+}
+
+// rateHeader returns the value of the X-RateLimit or RateLimit header with
+// the given suffix.
+func rateHeader(h http.Header, suffix string) (int, bool) {
+	for _, name := range []string{"X-RateLimit-" + suffix, "RateLimit-" + suffix} {
+		if n, err := strconv.Atoi(strings.TrimSpace(h.Get(name))); err == nil && n >= 0 {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// rateReset returns the time the rate limit announced in h resets, given as
+// a Unix time, as GitHub does, or as a number of seconds.
+func rateReset(h http.Header) (time.Time, bool) {
+	n, ok := rateHeader(h, "Reset")
+	switch {
+	case !ok:
+		return time.Time{}, false
+	case n > 1e9:
+		return time.Unix(int64(n), 0), true
+	default:
+		return time.Now().Add(time.Duration(n) * time.Second), true
+	}
+}
+
+// budgetTransport sends requests with base within the budget b.
+type budgetTransport struct {
+	b    *RateBudget
+	base http.RoundTripper
+}
+
+func (t *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	for attempt := 0; ; attempt++ {
+		if err := t.b.take(host); err != nil {
+			return nil, err
+		}
+		res, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		l, refused := t.b.update(host, res)
+		if !refused {
+			return res, nil
+		}
+		res.Body.Close()
+		// Requests with a body can't be sent again.
+		if attempt > 0 || req.Body != nil && req.Body != http.NoBody {
+			return nil, &RateLimitError{l}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateBudget(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	requests := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("X-RateLimit-Limit", "3")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(3-requests))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		w.Write([]byte("content"))
+	}))
+	defer s.Close()
+
+	b := &RateBudget{MaxWait: time.Minute}
+	f := NewFetcher(b.Client(s.Client()))
+	for i := 0; i < 3; i++ {
+		if _, err := f.Fetch("", s.URL); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	_, err := f.Fetch("", s.URL)
+	var rerr *RateLimitError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("expected a rate limit error; got %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+	host := s.Listener.Addr().String()
+	want := []RateLimit{{Host: host, Limit: 3, Remaining: 0, Reset: reset}}
+	if got := b.Limits(); len(got) != 1 || got[0] != want[0] {
+		t.Errorf("expected limits %+v; got %+v", want, got)
+	}
+	if msg := rerr.Error(); msg != "rate limit of "+host+" exhausted until "+reset.Format(time.TimeOnly)+", after 3 requests" {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestRateBudget_Refused(t *testing.T) {
+	tc := []struct {
+		name     string
+		header   http.Header
+		status   int
+		maxWait  time.Duration
+		requests int
+		err      bool
+	}{
+		{name: "retried after the reset",
+			header: http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"0"}},
+			status: http.StatusForbidden, maxWait: time.Minute, requests: 2},
+		{name: "secondary limit",
+			header: http.Header{"Retry-After": {"0"}},
+			status: http.StatusTooManyRequests, maxWait: time.Minute, requests: 2},
+		{name: "reset too late",
+			header: http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"3600"}},
+			status: http.StatusForbidden, maxWait: time.Minute, requests: 1, err: true},
+		{name: "forbidden",
+			header: http.Header{"X-Ratelimit-Remaining": {"10"}},
+			status: http.StatusForbidden, maxWait: time.Minute, requests: 1},
+	}
+	for _, tt := range tc {
+		requests := 0
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests == 1 {
+				for k, v := range tt.header {
+					w.Header()[k] = v
+				}
+				w.WriteHeader(tt.status)
+				return
+			}
+			w.Write([]byte("content"))
+		}))
+		b := &RateBudget{MaxWait: tt.maxWait}
+		res, err := b.Client(s.Client()).Get(s.URL)
+		s.Close()
+		if requests != tt.requests {
+			t.Errorf("case [%s]: expected %d requests, got %d", tt.name, tt.requests, requests)
+		}
+		var rerr *RateLimitError
+		if tt.err != errors.As(err, &rerr) {
+			t.Errorf("case [%s]: expected a rate limit error: %v; got %v", tt.name, tt.err, err)
+		}
+		if err == nil {
+			res.Body.Close()
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+// WithRecheck fetches again with f the remote content of the blocks found
+// stale when checking files, with WithCheck, and compares them again before
+// reporting them, so content served from a stale cache doesn't make blocks
+// look out of date. f should fetch the content without any cache.
+func WithRecheck(f Fetcher) Option {
+	return Option{func(e *embedder) { e.recheckFetcher = f }}
+}
+
+// recheck returns the content of the block of cmd fetched again with the
+// recheck fetcher, if its content is stale and remote, and whether it did.
+func (e *embedder) recheck(cmd *command, b []byte) ([]byte, bool) {
+	if !e.check || e.recheckFetcher == nil || !remoteBlock(cmd) {
+		return nil, false
+	}
+	f := e.Fetcher
+	e.Fetcher = e.recheckFetcher
+	defer func() { e.Fetcher = f }()
+	fresh, err := e.content(cmd)
+	if err != nil {
+		e.warnf(cmd, "could not fetch %s again without cache: %v", cmd.path, err)
+		return nil, false
+	}
+	if string(fresh) != string(b) {
+		e.warnf(cmd, "cached content of %s was out of date, fetched it again", cmd.path)
+	}
+	return fresh, true
+}
+
+// remoteBlock reports whether any of the commands embedded in the block of
+// cmd fetches a URL.
+func remoteBlock(cmd *command) bool {
+	for _, c := range append([]*command{cmd}, cmd.stacked...) {
+		if isURL(c.path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRecheck(t *testing.T) {
+	const url = "https://example.com/code.go"
+	cached := mixedContentProvider{
+		files: map[string][]byte{"code.go": []byte("new\n")},
+		urls:  map[string][]byte{url: []byte("cached\n")},
+	}
+	tc := []struct {
+		name, in, out, warning string
+		fresh                  string
+		again                  bool
+		opts                   []Option
+	}{
+		{name: "cache out of date", again: true,
+			in:      "[embedmd]:# (" + url + ")\n```go\nfresh\n```\n",
+			out:     "[embedmd]:# (" + url + ")\n```go\nfresh\n```\n",
+			fresh:   "fresh\n",
+			warning: "1: cached content of " + url + " was out of date, fetched it again",
+			opts:    []Option{WithCheck()}},
+		{name: "really stale", again: true,
+			in:    "[embedmd]:# (" + url + ")\n```go\nold\n```\n",
+			out:   "[embedmd]:# (" + url + ")\n```go\ncached\n```\n",
+			fresh: "cached\n",
+			opts:  []Option{WithCheck()}},
+		{name: "stale and changed again", again: true,
+			in:      "[embedmd]:# (" + url + ")\n```go\nold\n```\n",
+			out:     "[embedmd]:# (" + url + ")\n```go\nfresh\n```\n",
+			fresh:   "fresh\n",
+			warning: "1: cached content of " + url + " was out of date, fetched it again",
+			opts:    []Option{WithCheck()}},
+		{name: "not checking",
+			in:    "[embedmd]:# (" + url + ")\n```go\nfresh\n```\n",
+			out:   "[embedmd]:# (" + url + ")\n```go\ncached\n```\n",
+			fresh: "fresh\n"},
+		{name: "local file",
+			in:    "[embedmd]:# (code.go)\n```go\nold\n```\n",
+			out:   "[embedmd]:# (code.go)\n```go\nnew\n```\n",
+			fresh: "fresh\n",
+			opts:  []Option{WithCheck()}},
+		{name: "up to date",
+			in:   "[embedmd]:# (" + url + ")\n```go\ncached\n```\n",
+			out:  "[embedmd]:# (" + url + ")\n```go\ncached\n```\n",
+			opts: []Option{WithCheck()}},
+	}
+	for _, tt := range tc {
+		fetches := 0
+		fresh := FetcherFunc(func(dir, path string) ([]byte, error) {
+			fetches++
+			return []byte(tt.fresh), nil
+		})
+		var warnings []string
+		opts := append([]Option{
+			WithFetcher(cached),
+			WithRecheck(fresh),
+			WithWarnings(func(line int, msg string) { warnings = append(warnings, fmt.Sprintf("%d: %s", line, msg)) }),
+		}, tt.opts...)
+		var out bytes.Buffer
+		if err := Process(&out, strings.NewReader(tt.in), opts...); err != nil {
+			t.Errorf("case [%s]: %v", tt.name, err)
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+		if got := strings.Join(warnings, "\n"); got != tt.warning {
+			t.Errorf("case [%s]: expected warnings %q; got %q", tt.name, tt.warning, got)
+		}
+		if (fetches > 0) != tt.again {
+			t.Errorf("case [%s]: expected fetching again %v; fetched %d times", tt.name, tt.again, fetches)
+		}
+	}
+}
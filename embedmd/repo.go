@@ -0,0 +1,179 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// repoScheme prefixes the paths of files in other repositories, such as
+// repo://examples/cmd/main.go for cmd/main.go in the repository named
+// examples.
+const repoScheme = "repo://"
+
+func isRepoPath(path string) bool { return strings.HasPrefix(path, repoScheme) }
+
+// A Repo is a git repository whose files can be embedded with paths such as
+// repo://name/path/to/file.
+type Repo struct {
+	Name string
+	// URL is the URL of the repository, as accepted by git fetch, and Ref
+	// the branch, tag, or commit whose files are embedded, HEAD if empty.
+	URL, Ref string
+}
+
+var validRepoName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ParseRepo returns the repository with the given name described by spec, a
+// URL or a path such as github.com/org/examples, followed by @ and a ref,
+// such as github.com/org/examples@main. Paths starting with a host name are
+// fetched over HTTPS, from the clone URL of the forge of the host if it's
+// among forges or is a public one.
+func ParseRepo(name, spec string, forges ...Forge) (Repo, error) {
+	if !validRepoName.MatchString(name) {
+		return Repo{}, fmt.Errorf("bad repository name %q", name)
+	}
+	r := Repo{Name: name, URL: spec}
+	if i := strings.LastIndex(spec, "@"); i > strings.LastIndexAny(spec, "/:") {
+		r.URL, r.Ref = spec[:i], spec[i+1:]
+	}
+	if r.URL == "" {
+		return Repo{}, fmt.Errorf("missing URL of repository %s", name)
+	}
+	host, rest, _ := strings.Cut(r.URL, "/")
+	if strings.Contains(r.URL, "://") || strings.Contains(r.URL, "@") || !strings.Contains(host, ".") {
+		return r, nil
+	}
+	i := strings.LastIndex(rest, "/")
+	f, ok := findForge(host, forges)
+	if !ok || i < 0 {
+		r.URL = "https://" + r.URL
+		return r, nil
+	}
+	ff := ForgeFile{Host: host, Owner: rest[:i], Repo: strings.TrimSuffix(rest[i+1:], ".git")}
+	r.URL = ff.expand(f.CloneURL)
+	return r, nil
+}
+
+// RepoMiddleware serves the repo:// paths from the given repositories, which
+// are fetched once per run into bare repositories in dir, kept between runs
+// so only the new commits are fetched. They are partial clones: only the
+// commit and its trees are fetched, and the files are fetched when they are
+// first embedded, so huge repositories stay cheap to embed from. Other paths
+// are passed through.
+func RepoMiddleware(dir string, repos ...Repo) Middleware {
+	byName := map[string]*fetchedRepo{}
+	for _, r := range repos {
+		byName[r.Name] = &fetchedRepo{Repo: r, dir: filepath.Join(dir, sha256Hex(r.URL)[:16])}
+	}
+	return func(next Fetcher) Fetcher {
+		return FetcherContextFunc(func(ctx context.Context, dir, p string) ([]byte, error) {
+			if !isRepoPath(p) {
+				return FetchContext(ctx, next, dir, p)
+			}
+			name, file, _ := strings.Cut(strings.TrimPrefix(p, repoScheme), "/")
+			r, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown repository %q", name)
+			}
+			if file == "" || path.IsAbs(file) || strings.HasPrefix(path.Clean(file), "../") {
+				return nil, fmt.Errorf("bad path %q in repository %s", file, name)
+			}
+			commit, err := r.fetch(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return r.git(ctx, "show", commit+":"+path.Clean(file))
+		})
+	}
+}
+
+// RepoCommit returns the commit of the repository last fetched into dir by
+// RepoMiddleware, without fetching it.
+func RepoCommit(dir string, r Repo) (string, error) {
+	out, err := runGit(context.Background(), filepath.Join(dir, sha256Hex(r.URL)[:16]), "rev-parse", "--verify", "refs/embedmd/"+r.Name)
+	if err != nil {
+		return "", fmt.Errorf("repository %s wasn't fetched: %v", r.Name, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// fetchedRepo is a repository fetched on first use.
+type fetchedRepo struct {
+	Repo
+	dir string
+
+	mu      sync.Mutex
+	fetched bool
+	commit  string
+	err     error
+}
+
+// fetch fetches the ref of the repository the first time, returning the
+// commit it points to. Fetches stopped by ctx are done again the next time.
+func (r *fetchedRepo) fetch(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.fetched {
+		r.commit, r.err = r.fetchRef(ctx)
+		r.fetched = ctx.Err() == nil
+	}
+	return r.commit, r.err
+}
+
+// fetchRef fetches the ref of the repository, returning the commit it points
+// to.
+func (r *fetchedRepo) fetchRef(ctx context.Context) (string, error) {
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return "", err
+	}
+	ref := r.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := r.git(ctx, "init", "-q", "--bare"); err != nil {
+		return "", err
+	}
+	// As a promisor remote, git show fetches the missing files from it.
+	// Servers not supporting partial clones send them all instead.
+	for _, kv := range [][2]string{
+		{"remote.origin.url", r.URL},
+		{"remote.origin.promisor", "true"},
+		{"remote.origin.partialclonefilter", "blob:none"},
+	} {
+		if _, err := r.git(ctx, "config", kv[0], kv[1]); err != nil {
+			return "", err
+		}
+	}
+	// Each repository has its own ref, as several of them, or several runs,
+	// can share the directory.
+	local := "refs/embedmd/" + r.Name
+	if _, err := r.git(ctx, "fetch", "-q", "--depth", "1", "--filter=blob:none", "origin", "+"+ref+":"+local); err != nil {
+		return "", fmt.Errorf("could not fetch %s of repository %s: %v", ref, r.Name, err)
+	}
+	out, err := r.git(ctx, "rev-parse", local)
+	return strings.TrimSpace(string(out)), err
+}
+
+// git runs git in the directory of the repository.
+func (r *fetchedRepo) git(ctx context.Context, args ...string) ([]byte, error) {
+	return runGit(ctx, r.dir, args...)
+}
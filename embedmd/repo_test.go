@@ -0,0 +1,157 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRepo(t *testing.T) {
+	forges := []Forge{{Host: "git.example.com", Kind: "bitbucket"}}
+	tc := []struct {
+		name, spec string
+		repo       Repo
+		err        string
+	}{
+		{name: "examples", spec: "git.example.com/DOCS/examples@main",
+			repo: Repo{Name: "examples", URL: "https://git.example.com/scm/DOCS/examples.git", Ref: "main"}},
+		{name: "examples", spec: "gitlab.com/group/sub/examples",
+			repo: Repo{Name: "examples", URL: "https://gitlab.com/group/sub/examples"}},
+		{name: "examples", spec: "github.com/org/examples@main",
+			repo: Repo{Name: "examples", URL: "https://github.com/org/examples", Ref: "main"}},
+		{name: "examples", spec: "https://git.example.com/examples.git",
+			repo: Repo{Name: "examples", URL: "https://git.example.com/examples.git"}},
+		{name: "examples", spec: "git@github.com:org/examples.git@v1.2.0",
+			repo: Repo{Name: "examples", URL: "git@github.com:org/examples.git", Ref: "v1.2.0"}},
+		{name: "tools", spec: "../tools@feature/x",
+			repo: Repo{Name: "tools", URL: "../tools@feature/x"}},
+		{name: "bad/name", spec: "github.com/org/examples", err: `bad repository name "bad/name"`},
+		{name: "examples", spec: "@main", err: "missing URL of repository examples"},
+	}
+	for _, tt := range tc {
+		r, err := ParseRepo(tt.name, tt.spec, forges...)
+		if !eqErr(t, tt.spec, err, tt.err) {
+			continue
+		}
+		if r != tt.repo {
+			t.Errorf("case [%s]: expected %+v; got %+v", tt.spec, tt.repo, r)
+		}
+	}
+}
+
+func TestRepoMiddleware(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	src := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", src, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	git("config", "uploadpack.allowFilter", "true")
+	if err := os.MkdirAll(filepath.Join(src, "cmd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "cmd", "main.go"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "large.bin"), bytes.Repeat([]byte("x"), 1<<20), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "-A")
+	git("commit", "-q", "-m", "v1")
+	git("tag", "v1")
+	git("rm", "-q", "cmd/main.go")
+	git("commit", "-q", "-m", "v2")
+
+	cache := t.TempDir()
+	f := ChainFetcher(FetcherFunc(func(dir, path string) ([]byte, error) {
+		return []byte("local " + path), nil
+	}), RepoMiddleware(cache,
+		Repo{Name: "old", URL: src, Ref: "v1"},
+		Repo{Name: "new", URL: src, Ref: "main"}))
+
+	var out bytes.Buffer
+	in := "[embedmd]:# (repo://old/cmd/main.go /func main/ $)\n"
+	if err := Process(&out, strings.NewReader(in), WithFetcher(f)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := in + "```go\nfunc main() {\n        fmt.Println(\"hello, test\")\n}\n```\n"
+	if out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+
+	// Only the files embedded are fetched.
+	out.Reset()
+	for _, d := range mustReadDir(t, cache) {
+		cmd := exec.Command("git", "-C", filepath.Join(cache, d), "rev-list", "--objects", "--missing=print", "refs/embedmd/old")
+		cmd.Stdout = &out
+		if err := cmd.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if missing := strings.Count(out.String(), "\n?"); missing != 1 {
+		t.Errorf("expected large.bin to be missing from the clone; got %d missing objects:\n%s", missing, out.String())
+	}
+
+	if b, err := f.Fetch("docs", "main.go"); err != nil || string(b) != "local main.go" {
+		t.Errorf("expected local main.go; got %q, %v", b, err)
+	}
+	for path, msg := range map[string]string{
+		"repo://new/cmd/main.go":  "path 'cmd/main.go' does not exist",
+		"repo://other/main.go":    `unknown repository "other"`,
+		"repo://old/../secret.go": `bad path "../secret.go" in repository old`,
+	} {
+		if _, err := f.Fetch("", path); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%s: expected an error containing %q; got %v", path, msg, err)
+		}
+	}
+
+	// Fetches canceled by the context are done again the next time.
+	f = RepoMiddleware(t.TempDir(), Repo{Name: "old", URL: src, Ref: "v1"})(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := FetchContext(ctx, f, "", "repo://old/cmd/main.go"); err != context.Canceled {
+		t.Errorf("expected %v; got %v", context.Canceled, err)
+	}
+	if _, err := f.Fetch("", "repo://old/cmd/main.go"); err != nil {
+		t.Errorf("unexpected error after cancellation: %v", err)
+	}
+
+	_, err := NewFetcher(nil).Fetch("", "repo://old/cmd/main.go")
+	eqErr(t, "no middleware", err, "no repository configured for repo://old/cmd/main.go")
+}
+
+func mustReadDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
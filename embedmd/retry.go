@@ -0,0 +1,124 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WithRetries retries the requests of the fetcher failing with a network
+// error or a transient status, such as 429 Too Many Requests or 503 Service
+// Unavailable, up to n times. It waits backoff before the first retry, and
+// twice as long before each of the next ones, unless the server asks for a
+// given delay with a Retry-After header.
+func WithRetries(n int, backoff time.Duration) FetcherOption {
+	return FetcherOption{func(f *fetcher) { f.retries, f.backoff = n, backoff }}
+}
+
+// WithTimeout fails the requests of the fetcher taking longer than d,
+// including reading the content.
+func WithTimeout(d time.Duration) FetcherOption {
+	return FetcherOption{func(f *fetcher) { f.timeout = d }}
+}
+
+// WithRateLimit limits the requests of the fetcher to perSecond requests per
+// second to each host, waiting as long as needed before sending them. A rate
+// of 0 or less sets no limit.
+func WithRateLimit(perSecond float64) FetcherOption {
+	return FetcherOption{func(f *fetcher) {
+		if perSecond <= 0 {
+			f.limiter = nil
+			return
+		}
+		f.limiter = &hostLimiter{interval: time.Duration(float64(time.Second) / perSecond), next: map[string]time.Time{}}
+	}}
+}
+
+// transientStatus lists the statuses of the responses worth retrying.
+var transientStatus = map[int]bool{
+	http.StatusRequestTimeout:      true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// do sends req, waiting for the rate limit of its host, and retries it as
+// set with WithRetries, unless its context is done. The request is sent
+// again as it is, so it must not have a body.
+func (f *fetcher) do(req *http.Request) (*http.Response, error) {
+	delay := f.backoff
+	for attempt := 0; ; attempt++ {
+		if f.limiter != nil {
+			f.limiter.wait(req.URL.Host)
+		}
+		res, err := f.client.Do(req)
+		if attempt >= f.retries || err == nil && !transientStatus[res.StatusCode] {
+			return res, err
+		}
+		wait := delay
+		if err == nil {
+			if d, ok := retryAfter(res.Header.Get("Retry-After")); ok {
+				wait = d
+			}
+			res.Body.Close()
+		}
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		delay *= 2
+	}
+}
+
+// retryAfter parses the value of a Retry-After header, a number of seconds
+// or a date.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// hostLimiter spaces the requests to each host by at least interval.
+type hostLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	// next holds the earliest time of the next request to each host.
+	next map[string]time.Time
+}
+
+// wait waits until a request can be sent to host.
+func (l *hostLimiter) wait(host string) {
+	l.mu.Lock()
+	now := time.Now()
+	t := l.next[host]
+	if t.Before(now) {
+		t = now
+	}
+	l.next[host] = t.Add(l.interval)
+	l.mu.Unlock()
+	time.Sleep(t.Sub(now))
+}
@@ -0,0 +1,139 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetcher_Retries(t *testing.T) {
+	tc := []struct {
+		name     string
+		failures int
+		status   int
+		retries  int
+		requests int
+		err      string
+	}{
+		{name: "no retries", failures: 1, status: http.StatusServiceUnavailable, requests: 1, err: "status 503 Service Unavailable"},
+		{name: "retried", failures: 2, status: http.StatusServiceUnavailable, retries: 3, requests: 3},
+		{name: "too many failures", failures: 3, status: http.StatusTooManyRequests, retries: 2, requests: 3, err: "status 429 Too Many Requests"},
+		{name: "not transient", failures: 1, status: http.StatusNotFound, retries: 3, requests: 1, err: "status 404 Not Found"},
+	}
+	for _, tt := range tc {
+		requests := 0
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests <= tt.failures {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(tt.status)
+				return
+			}
+			w.Write([]byte("content"))
+		}))
+		f := NewFetcher(s.Client(), WithRetries(tt.retries, time.Hour))
+		b, err := f.Fetch("", s.URL)
+		s.Close()
+		if requests != tt.requests {
+			t.Errorf("case [%s]: expected %d requests, got %d", tt.name, tt.requests, requests)
+		}
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if string(b) != "content" {
+			t.Errorf("case [%s]: expected content, got %q", tt.name, b)
+		}
+	}
+}
+
+func TestFetcher_Backoff(t *testing.T) {
+	requests := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("content"))
+	}))
+	defer s.Close()
+
+	start := time.Now()
+	if _, err := NewFetcher(s.Client(), WithRetries(2, 20*time.Millisecond)).Fetch("", s.URL); err != nil {
+		t.Fatal(err)
+	}
+	// The second retry waits twice as long as the first one.
+	if d := time.Since(start); d < 60*time.Millisecond {
+		t.Errorf("expected retries to wait at least 60ms, waited %v", d)
+	}
+}
+
+func TestFetcher_Timeout(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer s.Close()
+
+	_, err := NewFetcher(s.Client(), WithTimeout(10*time.Millisecond)).Fetch("", s.URL)
+	if err == nil {
+		t.Fatal("expected the request to time out")
+	}
+	// The timeout applies to the fetcher only.
+	if s.Client().Timeout != 0 {
+		t.Errorf("expected the client to be left untouched, got timeout %v", s.Client().Timeout)
+	}
+}
+
+func TestFetcher_RateLimit(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}))
+	defer s.Close()
+
+	f := NewFetcher(s.Client(), WithRateLimit(50))
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := f.Fetch("", s.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Errorf("expected 3 requests at 50 per second to take at least 40ms, took %v", d)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tc := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{in: "", ok: false},
+		{in: "3", want: 3 * time.Second, ok: true},
+		{in: "Mon, 02 Jan 2006 15:04:05 GMT", want: 0, ok: true},
+		{in: "soon", ok: false},
+	}
+	for _, tt := range tc {
+		got, ok := retryAfter(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// WithReviewNotes writes after each block embedded a line telling where its
+// content comes from and whether it changed, for reviewing the output in a
+// preview build. The notes are removed when processing the output again
+// without this option. Only markdown documents get notes.
+func WithReviewNotes() Option {
+	return Option{func(e *embedder) { e.reviewNotes = true }}
+}
+
+// reviewPrefix starts the lines written by WithReviewNotes.
+const reviewPrefix = `<sub class="embedmd-review">`
+
+// reviewNote returns the line describing the block of cmd, whose content
+// changed, or failed to be embedded.
+func reviewNote(cmd *command, changed, failed bool) string {
+	var sources []string
+	for _, c := range append([]*command{cmd}, cmd.stacked...) {
+		switch r := c.region; {
+		case r[0] == 0:
+			sources = append(sources, c.path)
+		case r[0] == r[1]:
+			sources = append(sources, fmt.Sprintf("%s line %d", c.path, r[0]))
+		default:
+			sources = append(sources, fmt.Sprintf("%s lines %d-%d", c.path, r[0], r[1]))
+		}
+	}
+	status := "unchanged"
+	switch {
+	case failed:
+		status = "failed"
+	case cmd.block == nil && len(cmd.trailers) == 0:
+		status = "added"
+	case changed:
+		status = "updated"
+	}
+	return fmt.Sprintf("%sembedmd: %s, %s</sub>\n", reviewPrefix, html.EscapeString(strings.Join(sources, ", ")), status)
+}
@@ -0,0 +1,72 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReviewNotes(t *testing.T) {
+	files := map[string][]byte{
+		"code.go": []byte("package main\n\nfunc main() {\n}\n"),
+		"a&b.go":  []byte("package ab\n"),
+	}
+	tc := []struct {
+		name, in, out string
+		opts          []Option
+	}{
+		{name: "added",
+			in:  "[embedmd]:# (code.go /func main/ /^}/)\n",
+			out: "[embedmd]:# (code.go /func main/ /^}/)\n```go\nfunc main() {\n}\n```\n" + reviewPrefix + "embedmd: code.go lines 3-4, added</sub>\n"},
+		{name: "unchanged",
+			in:  "[embedmd]:# (code.go /package.*/)\n```go\npackage main\n```\n",
+			out: "[embedmd]:# (code.go /package.*/)\n```go\npackage main\n```\n" + reviewPrefix + "embedmd: code.go line 1, unchanged</sub>\n"},
+		{name: "updated and stacked",
+			in:  "[embedmd]:# (code.go /package.*/)\n[embedmd]:# (a&b.go)\n```go\nold\n```\n" + reviewPrefix + "embedmd: code.go, unchanged</sub>\nText.\n",
+			out: "[embedmd]:# (code.go /package.*/)\n[embedmd]:# (a&b.go)\n```go\npackage main\npackage ab\n```\n" + reviewPrefix + "embedmd: code.go line 1, a&amp;b.go line 1, updated</sub>\nText.\n"},
+		{name: "failed",
+			in:   "[embedmd]:# (missing.go)\n",
+			opts: []Option{WithDraft()},
+			out:  "[embedmd]:# (missing.go)\n```go\n⚠ source not found: missing.go\n```\n" + reviewPrefix + "embedmd: missing.go, failed</sub>\n"},
+		{name: "with checksums",
+			in:   "[embedmd]:# (code.go /package.*/)\n",
+			opts: []Option{WithChecksums()},
+			out:  "[embedmd]:# (code.go /package.*/)\n```go\npackage main\n```\n<!-- embedmd checksum " + checksum([]byte("```go\npackage main\n```\n")) + " -->\n" + reviewPrefix + "embedmd: code.go line 1, added</sub>\n"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		opts := append([]Option{WithFetcher(mixedContentProvider{files: files}), WithReviewNotes()}, tt.opts...)
+		if err := Process(&out, strings.NewReader(tt.in), opts...); err != nil {
+			t.Errorf("case [%s]: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected\n%q\ngot\n%q", tt.name, tt.out, got)
+			continue
+		}
+
+		// Without the option, the notes are removed.
+		var stripped bytes.Buffer
+		opts = append([]Option{WithFetcher(mixedContentProvider{files: files})}, tt.opts...)
+		if err := Process(&stripped, strings.NewReader(tt.out), opts...); err != nil {
+			t.Errorf("case [%s]: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if strings.Contains(stripped.String(), reviewPrefix) {
+			t.Errorf("case [%s]: expected the notes to be removed; got\n%s", tt.name, stripped.String())
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A Selector chooses the part of a source embedded by the commands naming it
+// in their select attribute, as select=name or select=name=arg, instead of
+// regular expressions, a line range, a tag, or a symbol. It's given the
+// whole source, with \n line endings.
+type Selector interface {
+	Select(src []byte, arg string) ([]byte, error)
+}
+
+// SelectorFunc is an adapter to use ordinary functions as Selectors.
+type SelectorFunc func(src []byte, arg string) ([]byte, error)
+
+// Select calls f(src, arg).
+func (f SelectorFunc) Select(src []byte, arg string) ([]byte, error) { return f(src, arg) }
+
+// WithSelector registers s as the selector with the given name.
+func WithSelector(name string, s Selector) Option {
+	return Option{func(e *embedder) {
+		if e.selectors == nil {
+			e.selectors = map[string]Selector{}
+		}
+		e.selectors[name] = s
+	}}
+}
+
+// parseSelect parses the value of a select attribute, as name or name=arg.
+func parseSelect(cmd *command, s string) error {
+	name, arg, _ := strings.Cut(strings.TrimSpace(s), "=")
+	if !validTag.MatchString(name) {
+		return fmt.Errorf("select should be a name with an optional =argument, got %q", s)
+	}
+	cmd.selector, cmd.selectorArg = name, arg
+	return nil
+}
+
+// resolveSelector sets the Selector of cmd, if it names one.
+func (e *embedder) resolveSelector(cmd *command) error {
+	if cmd.selector == "" {
+		return nil
+	}
+	s, ok := e.selectors[cmd.selector]
+	if !ok {
+		return fmt.Errorf("unknown selector %q", cmd.selector)
+	}
+	cmd.selectWith = s
+	return nil
+}
+
+// A Renderer writes the content embedded by commands in markdown documents,
+// instead of a fenced code block, e.g. as an HTML figure. Its output is
+// written between markers so it's replaced when processed again. It's given
+// the Embed of the command, with the Content embedded, but not its Source.
+type Renderer interface {
+	Render(e *Embed) ([]byte, error)
+}
+
+// RendererFunc is an adapter to use ordinary functions as Renderers.
+type RendererFunc func(e *Embed) ([]byte, error)
+
+// Render calls f(e).
+func (f RendererFunc) Render(e *Embed) ([]byte, error) { return f(e) }
+
+// WithRenderer renders with r the content of the commands that would
+// otherwise be written as fenced code blocks.
+func WithRenderer(r Renderer) Option {
+	return Option{func(e *embedder) { e.renderer = r }}
+}
+
+// writeRendered writes the embedded content b with the renderer set with
+// WithRenderer.
+func (e *embedder) writeRendered(w io.Writer, cmd *command, b []byte) error {
+	embed := e.newEmbed(cmd)
+	embed.Content = b
+	out, err := e.renderer.Render(embed)
+	if err != nil {
+		return fmt.Errorf("could not render %s: %w", cmd.path, err)
+	}
+	w.Write(out) //nolint:errcheck
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		fmt.Fprintln(w)
+	}
+	return nil
+}
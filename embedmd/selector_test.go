@@ -0,0 +1,102 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSelector(t *testing.T) {
+	files := map[string][]byte{
+		"code.go": []byte("package main\n\n// START\nfunc main() {}\n// END\n"),
+	}
+	tc := []struct {
+		name, in, out string
+		err           string
+	}{
+		{name: "between markers", in: "[embedmd]:# (code.go select=between=START,END)\n",
+			out: "[embedmd]:# (code.go select=between=START,END)\n```go\nfunc main() {}\n```\n"},
+		{name: "failing", in: "[embedmd]:# (code.go select=between=BEGIN,END)\n",
+			err: "1: could not extract content from code.go: no line matches BEGIN"},
+		{name: "unknown", in: "[embedmd]:# (code.go select=outline)\n", err: "1: unknown selector \"outline\""},
+		{name: "with regexps", in: "[embedmd]:# (code.go select=between /func/)\n",
+			err: "1: cannot use a selector with a symbol, a tag, a line range, or regular expressions"},
+		{name: "bad name", in: "[embedmd]:# (code.go select==x)\n",
+			err: "1: select should be a name with an optional =argument, got \"=x\""},
+	}
+	between := SelectorFunc(func(src []byte, arg string) ([]byte, error) {
+		start, end, _ := strings.Cut(arg, ",")
+		lines := bytes.SplitAfter(src, []byte("\n"))
+		from := -1
+		for i, l := range lines {
+			switch {
+			case from < 0 && bytes.Contains(l, []byte(start)):
+				from = i + 1
+			case from >= 0 && bytes.Contains(l, []byte(end)):
+				return bytes.Join(lines[from:i], nil), nil
+			}
+		}
+		if from < 0 {
+			return nil, fmt.Errorf("no line matches %s", start)
+		}
+		return nil, fmt.Errorf("no line matches %s", end)
+	})
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}),
+			WithSelector("between", between))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
+
+func TestRenderer(t *testing.T) {
+	files := map[string][]byte{"code.go": []byte("func main() {}\n")}
+	figure := RendererFunc(func(e *Embed) ([]byte, error) {
+		if e.Lang == "text" {
+			return nil, errors.New("nothing to highlight")
+		}
+		return fmt.Appendf(nil, "<figure><pre lang=%q>%s</pre><figcaption>%s</figcaption></figure>", e.Lang, bytes.TrimSpace(e.Content), e.Path), nil
+	})
+	tc := []struct {
+		name, in, out string
+		err           string
+	}{
+		{name: "rendered", in: "[embedmd]:# (code.go)\n",
+			out: "[embedmd]:# (code.go)\n<!-- embedmd block start -->\n<figure><pre lang=\"go\">func main() {}</pre><figcaption>code.go</figcaption></figure>\n<!-- embedmd block end -->\n"},
+		{name: "rendered again", in: "[embedmd]:# (code.go)\n<!-- embedmd block start -->\n<figure>old</figure>\n<!-- embedmd block end -->\n",
+			out: "[embedmd]:# (code.go)\n<!-- embedmd block start -->\n<figure><pre lang=\"go\">func main() {}</pre><figcaption>code.go</figcaption></figure>\n<!-- embedmd block end -->\n"},
+		{name: "not fenced", in: "[embedmd]:# (code.go none)\n",
+			out: "[embedmd]:# (code.go none)\n<!-- embedmd block start -->\nfunc main() {}\n<!-- embedmd block end -->\n"},
+		{name: "failing", in: "[embedmd]:# (code.go text)\n", err: "1: could not render code.go: nothing to highlight"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}), WithRenderer(figure))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// WithRunnableCommands wraps every embedded console block in an HTML element
+// whose data-commands attribute holds the commands of the session, as a JSON
+// array of strings, without their prompts or output, so the docs site can
+// offer to run them. Only use it with renderers that accept raw HTML in
+// markdown.
+func WithRunnableCommands() Option {
+	return Option{func(e *embedder) { e.runnableCommands = true }}
+}
+
+// runnable returns the commands of the code block b of cmd set with
+// WithRunnableCommands, or nil if it isn't a console session.
+func (e *embedder) runnable(cmd *command, b []byte) []string {
+	if !e.runnableCommands || !consoleLangs[strings.ToLower(cmd.lang)] {
+		return nil
+	}
+	return sessionCommands(string(b))
+}
+
+// writeSessionStart opens the HTML element holding the commands of a console
+// block. The blank line lets markdown renderers parse the code fence inside
+// of it.
+func writeSessionStart(w io.Writer, cmds []string) {
+	b, _ := json.Marshal(cmds)
+	fmt.Fprintf(w, "<div class=\"embedmd-session\" data-commands=\"%s\">\n\n", html.EscapeString(string(b)))
+}
+
+func writeSessionEnd(w io.Writer) {
+	fmt.Fprint(w, "\n</div>\n")
+}
+
+// heredoc matches the redirections starting a here-document, capturing its
+// delimiter, and not here-strings.
+var heredoc = regexp.MustCompile(`(?:^|[^<])<<-?\s*['"]?([A-Za-z_]\w*)['"]?`)
+
+// sessionCommands returns the commands of the console session s, the lines
+// following a "$ " prompt, without the prompts and the output of the
+// commands. A command goes on over the next lines when it ends with a
+// backslash, when they start with the "> " secondary prompt, which is
+// removed, and until the end of its here-documents.
+func sessionCommands(s string) []string {
+	var cmds []string
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line, ok := strings.CutPrefix(lines[i], "$ ")
+		if !ok {
+			continue
+		}
+		cmd := []string{line}
+		delim := heredocDelim(line)
+		for ; i+1 < len(lines); i++ {
+			next, prompted := strings.CutPrefix(lines[i+1], "> ")
+			if delim == "" && !prompted && !strings.HasSuffix(cmd[len(cmd)-1], "\\") {
+				break
+			}
+			cmd = append(cmd, next)
+			switch {
+			case delim == "":
+				delim = heredocDelim(next)
+			case strings.TrimLeft(next, "\t") == delim:
+				delim = ""
+			}
+		}
+		cmds = append(cmds, strings.Join(cmd, "\n"))
+	}
+	return cmds
+}
+
+// heredocDelim returns the delimiter of the here-document started by line,
+// if any.
+func heredocDelim(line string) string {
+	if m := heredoc.FindStringSubmatch(line); m != nil {
+		return m[1]
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSessionCommands(t *testing.T) {
+	tc := []struct {
+		name, in string
+		want     []string
+	}{
+		{name: "commands and output", in: "$ go build\n$ ./app\nlistening on :8080\n",
+			want: []string{"go build", "./app"}},
+		{name: "no prompts", in: "go build\n", want: nil},
+		{name: "backslash", in: "$ docker run \\\n  -p 8080:8080 \\\n  app\nstarted\n",
+			want: []string{"docker run \\\n  -p 8080:8080 \\\n  app"}},
+		{name: "secondary prompt", in: "$ for i in 1 2; do\n> echo $i\n> done\n1\n2\n",
+			want: []string{"for i in 1 2; do\necho $i\ndone"}},
+		{name: "here-document", in: "$ cat <<'EOF' > config.yaml\nport: 8080\n\nEOF\n$ cat config.yaml\nport: 8080\n",
+			want: []string{"cat <<'EOF' > config.yaml\nport: 8080\n\nEOF", "cat config.yaml"}},
+		{name: "indented here-document", in: "$ cat <<-END\n\tline\n\tEND\nline\n",
+			want: []string{"cat <<-END\n\tline\n\tEND"}},
+		{name: "here-string", in: "$ grep a <<< abc\nabc\n", want: []string{"grep a <<< abc"}},
+	}
+	for _, tt := range tc {
+		if got := sessionCommands(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("case [%s]: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestRunnableCommands(t *testing.T) {
+	files := map[string][]byte{
+		"session.txt": []byte("$ echo \"hi\"\nhi\n"),
+		"code.go":     []byte("package main\n"),
+	}
+	in := "[embedmd]:# (session.txt console)\n\n[embedmd]:# (code.go)\n"
+	want := "[embedmd]:# (session.txt console)\n" +
+		"<!-- embedmd block start -->\n" +
+		"<div class=\"embedmd-session\" data-commands=\"[&#34;echo \\&#34;hi\\&#34;&#34;]\">\n\n" +
+		"```console\n$ echo \"hi\"\nhi\n```\n" +
+		"\n</div>\n" +
+		"<!-- embedmd block end -->\n" +
+		"\n" +
+		"[embedmd]:# (code.go)\n" +
+		"```go\npackage main\n```\n"
+
+	var out bytes.Buffer
+	opts := []Option{WithFetcher(mixedContentProvider{files: files}), WithRunnableCommands()}
+	if err := Process(&out, strings.NewReader(in), opts...); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want {
+		t.Errorf("expected output\n%s\ngot\n%s", want, out.String())
+	}
+
+	// Processing the output again leaves it as it is.
+	var again bytes.Buffer
+	if err := Process(&again, strings.NewReader(want), opts...); err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != want {
+		t.Errorf("expected reprocessed output\n%s\ngot\n%s", want, again.String())
+	}
+}
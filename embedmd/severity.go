@@ -0,0 +1,175 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// A Severity is how a finding is reported when checking files.
+type Severity int
+
+const (
+	// SeverityError fails the check.
+	SeverityError Severity = iota
+	// SeverityWarning reports the finding as a warning only.
+	SeverityWarning
+	// SeverityIgnore doesn't report the finding at all.
+	SeverityIgnore
+)
+
+var severityNames = []string{"error", "warning", "ignore"}
+
+func (s Severity) String() string { return severityNames[s] }
+
+// ParseSeverity parses a severity: error, warning, or ignore.
+func ParseSeverity(s string) (Severity, error) {
+	for i, name := range severityNames {
+		if s == name {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q, should be one of %s", s, strings.Join(severityNames, ", "))
+}
+
+// The findings whose severity can be changed.
+const (
+	// FindingStale is a block whose content is out of date.
+	FindingStale = "stale"
+	// FindingMaxAge is a block not refreshed within its maxage.
+	FindingMaxAge = "maxage"
+	// FindingVersions is content differing across the versions set with
+	// WithVersions.
+	FindingVersions = "versions"
+)
+
+var findings = []string{FindingStale, FindingMaxAge, FindingVersions}
+
+// WithCheck processes the markdown to check whether it's up to date. Stale
+// blocks whose severity is lowered, with WithSeverity or a suppression
+// comment, are kept as they are rather than updated, with a warning unless
+// they are ignored, so they don't count as changes.
+//
+// A suppression comment on the line before a command lowers the severity of
+// the given findings for its block to a warning:
+//
+//	<!-- embedmd:ignore-next stale maxage -->
+func WithCheck() Option {
+	return Option{func(e *embedder) { e.check = true }}
+}
+
+// WithSeverity sets the severity of a finding, FindingStale,
+// FindingMaxAge, or FindingVersions, in every block.
+func WithSeverity(finding string, s Severity) Option {
+	return Option{func(e *embedder) {
+		if e.severities == nil {
+			e.severities = map[string]Severity{}
+		}
+		e.severities[finding] = s
+	}}
+}
+
+func (e *embedder) validateSeverities() error {
+	for _, f := range slices.Sorted(maps.Keys(e.severities)) {
+		if err := checkFinding(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkFinding(f string) error {
+	for _, known := range findings {
+		if f == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown finding %q, should be one of %s", f, strings.Join(findings, ", "))
+}
+
+var suppressionComment = regexp.MustCompile(`^\s*<!--\s*embedmd:ignore-next\s+(.*?)\s*-->\s*$`)
+
+// suppressions returns the findings suppressed by the comments in b, by the
+// line of the command they precede.
+func suppressions(b []byte) (map[int][]string, error) {
+	suppressed := map[int][]string{}
+	for i, line := range strings.Split(string(b), "\n") {
+		m := suppressionComment.FindStringSubmatch(strings.TrimSuffix(line, "\r"))
+		if m == nil {
+			continue
+		}
+		fs := strings.FieldsFunc(m[1], func(r rune) bool { return r == ' ' || r == ',' })
+		for _, f := range fs {
+			if err := checkFinding(f); err != nil {
+				return nil, &lineError{i + 1, err}
+			}
+		}
+		// Lines are numbered from 1, and the command is on the next one.
+		suppressed[i+2] = fs
+	}
+	return suppressed, nil
+}
+
+// severity returns the severity of the finding in the block of cmd.
+func (e *embedder) severity(cmd *command, finding string) Severity {
+	s, ok := e.severities[finding]
+	if !ok {
+		s = SeverityError
+	}
+	for _, f := range e.suppressed[cmd.line] {
+		if f == finding && s == SeverityError {
+			s = SeverityWarning
+		}
+	}
+	return s
+}
+
+// WithQuarantine quarantines the blocks for which q returns true, given the
+// fingerprint of their command, as known-broken embeds being fixed: their
+// failures are reported as warnings, and the blocks kept as they are. q is
+// only called for blocks failing, because their content can't be embedded
+// or, when checking, because they are stale or not refreshed within their
+// maxage.
+func WithQuarantine(q func(fingerprint string) bool) Option {
+	return Option{func(e *embedder) { e.quarantine = q }}
+}
+
+// quarantined reports whether the failures of the block of cmd are
+// reported as warnings.
+func (e *embedder) quarantined(cmd *command) bool {
+	return e.quarantine != nil && e.quarantine(fingerprint(cmd))
+}
+
+// keepDowngraded keeps the previous block of cmd, which is stale, when
+// checking with a lower severity for stale blocks, reporting whether it did.
+func (e *embedder) keepDowngraded(w io.Writer, cmd *command) (bool, error) {
+	if !e.check {
+		return false, nil
+	}
+	switch e.severity(cmd, FindingStale) {
+	case SeverityError:
+		if !e.quarantined(cmd) {
+			return false, nil
+		}
+		e.warnf(cmd, "block is stale, quarantined")
+	case SeverityWarning:
+		e.warnf(cmd, "block is stale, reported as a warning only")
+	}
+	return true, keepBlock(w, cmd)
+}
@@ -0,0 +1,175 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSeverity(t *testing.T) {
+	cmd := "[embedmd]:# (code.go)\n"
+	old := "```go\nold content\n```\n"
+	block := "```go\n" + content + "```\n"
+	suppress := "<!-- embedmd:ignore-next stale -->\n"
+	now := func() time.Time { return time.Date(2026, 10, 15, 13, 0, 0, 0, time.UTC) }
+	aged := "[embedmd]:# (code.go maxage=90d)\n" + block + refreshedPrefix + "2026-07-01 -->\n"
+
+	tc := []struct {
+		name     string
+		in       string
+		check    bool
+		opts     []Option
+		out      string
+		warnings []string
+		err      string
+	}{
+		{
+			name:  "stale block fails",
+			in:    cmd + old,
+			check: true,
+			out:   cmd + block,
+		},
+		{
+			name:     "suppressed stale block",
+			in:       suppress + cmd + old + cmd + old,
+			check:    true,
+			out:      suppress + cmd + old + cmd + block,
+			warnings: []string{"2: block is stale, reported as a warning only"},
+		},
+		{
+			name:  "suppression only applies when checking",
+			in:    suppress + cmd + old,
+			out:   suppress + cmd + block,
+			check: false,
+		},
+		{
+			name:     "stale blocks downgraded",
+			in:       cmd + old,
+			check:    true,
+			opts:     []Option{WithSeverity(FindingStale, SeverityWarning)},
+			out:      cmd + old,
+			warnings: []string{"1: block is stale, reported as a warning only"},
+		},
+		{
+			name:  "stale blocks ignored",
+			in:    suppress + cmd + old,
+			check: true,
+			opts:  []Option{WithSeverity(FindingStale, SeverityIgnore)},
+			out:   suppress + cmd + old,
+		},
+		{
+			name:  "up to date block",
+			in:    suppress + cmd + block,
+			check: true,
+			out:   suppress + cmd + block,
+		},
+		{
+			name:     "suppressed maxage",
+			in:       "<!-- embedmd:ignore-next maxage, stale -->\n" + aged,
+			check:    true,
+			opts:     []Option{WithMaxAgeErrors()},
+			out:      "<!-- embedmd:ignore-next maxage, stale -->\n" + aged,
+			warnings: []string{"2: block not refreshed within maxage=90d, since 2026-07-01, review it and refresh it"},
+		},
+		{
+			name:  "maxage ignored",
+			in:    aged,
+			opts:  []Option{WithMaxAgeErrors(), WithSeverity(FindingMaxAge, SeverityIgnore)},
+			out:   aged,
+			check: true,
+		},
+		{
+			name:     "quarantined stale block",
+			in:       cmd + old,
+			check:    true,
+			opts:     []Option{WithQuarantine(func(fp string) bool { return fp == fingerprint(&command{args: "(code.go)"}) })},
+			out:      cmd + old,
+			warnings: []string{"1: block is stale, quarantined"},
+		},
+		{
+			name:     "quarantined maxage",
+			in:       aged,
+			check:    true,
+			opts:     []Option{WithMaxAgeErrors(), WithQuarantine(func(string) bool { return true })},
+			out:      aged,
+			warnings: []string{"1: block not refreshed within maxage=90d, since 2026-07-01, review it and refresh it, quarantined"},
+		},
+		{
+			name:     "quarantined failure",
+			in:       "[embedmd]:# (missing.go)\n" + old,
+			opts:     []Option{WithQuarantine(func(string) bool { return true })},
+			out:      "[embedmd]:# (missing.go)\n" + old,
+			warnings: []string{"1: could not read missing.go: file does not exist, quarantined"},
+		},
+		{
+			name:  "other blocks quarantined",
+			in:    "[embedmd]:# (missing.go)\n" + old,
+			check: true,
+			opts:  []Option{WithQuarantine(func(fp string) bool { return fp == fingerprint(&command{args: "(code.go)"}) })},
+			err:   "1: could not read missing.go: file does not exist",
+		},
+		{
+			name: "unknown suppressed finding",
+			in:   "<!-- embedmd:ignore-next drift -->\n" + cmd,
+			err:  `1: unknown finding "drift", should be one of stale, maxage, versions`,
+		},
+		{
+			name: "unknown finding",
+			in:   cmd,
+			opts: []Option{WithSeverity("drift", SeverityWarning)},
+			err:  `unknown finding "drift", should be one of stale, maxage, versions`,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var warnings []string
+			opts := []Option{
+				WithFetcher(mixedContentProvider{files: map[string][]byte{"code.go": []byte(content)}}),
+				WithWarnings(func(line int, msg string) {
+					warnings = append(warnings, fmt.Sprintf("%d: %s", line, msg))
+				}),
+				{func(e *embedder) { e.now = now }},
+			}
+			if tt.check {
+				opts = append(opts, WithCheck())
+			}
+			err := Process(&out, strings.NewReader(tt.in), append(opts, tt.opts...)...)
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if got := out.String(); got != tt.out {
+				t.Errorf("expected output\n%s\ngot\n%s", tt.out, got)
+			}
+			if fmt.Sprint(warnings) != fmt.Sprint(tt.warnings) {
+				t.Errorf("expected warnings %q; got %q", tt.warnings, warnings)
+			}
+		})
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for _, s := range []Severity{SeverityError, SeverityWarning, SeverityIgnore} {
+		if got, err := ParseSeverity(s.String()); err != nil || got != s {
+			t.Errorf("expected %v to parse back; got %v, %v", s, got, err)
+		}
+	}
+	_, err := ParseSeverity("fatal")
+	eqErr(t, "unknown severity", err, `unknown severity "fatal", should be one of error, warning, ignore`)
+}
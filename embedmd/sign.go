@@ -0,0 +1,266 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AWSCredentials are the credentials used to sign requests to AWS.
+type AWSCredentials struct {
+	AccessKeyID, SecretAccessKey string
+	// SessionToken is only needed for temporary credentials.
+	SessionToken string
+	// Region is used for the hosts that don't include one, such as
+	// s3.amazonaws.com. It defaults to us-east-1.
+	Region string
+}
+
+// AWSCredentialsFromEnv returns the credentials set in the standard AWS
+// environment variables.
+func AWSCredentialsFromEnv() (AWSCredentials, error) {
+	c := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Region:          os.Getenv("AWS_REGION"),
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return c, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	return c, nil
+}
+
+// SigV4Middleware signs the requests to *.amazonaws.com URLs with AWS
+// Signature Version 4, so content can be embedded from private API Gateway
+// endpoints or S3 buckets without presigned URLs. The service and region
+// are taken from the host name. The options are as in AuthMiddleware.
+func SigV4Middleware(creds AWSCredentials, client *http.Client, opts ...FetcherOption) Middleware {
+	return AuthMiddleware("*.amazonaws.com/**", client, func(r *http.Request) error {
+		service, region := awsScope(r.URL.Hostname(), creds.Region)
+		return signV4(r, creds, service, region, time.Now())
+	}, opts...)
+}
+
+// awsScope returns the service and region of an AWS host name, as in
+// bucket.s3.eu-west-1.amazonaws.com or id.execute-api.us-east-2.amazonaws.com.
+func awsScope(host, defaultRegion string) (service, region string) {
+	labels := strings.Split(strings.TrimSuffix(host, ".amazonaws.com"), ".")
+	if defaultRegion == "" {
+		defaultRegion = "us-east-1"
+	}
+	if len(labels) == 1 {
+		return labels[0], defaultRegion
+	}
+	service, region = labels[len(labels)-2], labels[len(labels)-1]
+	// Hosts with no region, as in bucket.s3.amazonaws.com.
+	if service != "s3" && region == "s3" {
+		return "s3", defaultRegion
+	}
+	return service, region
+}
+
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signV4 adds the AWS Signature Version 4 headers to r, a request without a
+// body.
+func signV4(r *http.Request, creds AWSCredentials, service, region string, now time.Time) error {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	r.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	if service == "s3" {
+		r.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	}
+
+	headers := map[string]string{"host": r.URL.Host}
+	for name, values := range r.Header {
+		name = strings.ToLower(name)
+		if name == "host" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		r.Method, path, canonicalQuery(r.URL.Query()),
+		canonicalHeaders.String(), signedHeaders, emptySHA256,
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex(canonical)}, "\n")
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	r.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// canonicalQuery returns the query sorted by key and value, encoded as
+// required by AWS.
+func canonicalQuery(q url.Values) string {
+	var pairs []string
+	for k, vs := range q {
+		for _, v := range vs {
+			pairs = append(pairs, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data)) //nolint:errcheck
+	return h.Sum(nil)
+}
+
+// metadataURL is the URL of the Google Cloud metadata server, replaced by
+// tests.
+var metadataURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/"
+
+// GoogleMiddleware adds Google credentials to the requests to Google
+// endpoints: an OAuth access token for *.googleapis.com, such as Cloud
+// Storage, and an OIDC identity token for the services behind IAM, on
+// *.run.app and *.cloudfunctions.net. The access token is read from the
+// GOOGLE_OAUTH_ACCESS_TOKEN environment variable when set, and otherwise both
+// tokens are requested from the metadata server. The options are as in
+// AuthMiddleware.
+func GoogleMiddleware(client *http.Client, opts ...FetcherOption) Middleware {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	tokens := &googleTokens{client: client, cache: map[string]googleToken{}}
+	authorize := func(r *http.Request) error {
+		token, err := tokens.get(r.URL)
+		if err != nil {
+			return err
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+	return ChainMiddleware(
+		AuthMiddleware("*.googleapis.com/**", client, authorize, opts...),
+		AuthMiddleware("*.run.app/**", client, authorize, opts...),
+		AuthMiddleware("*.cloudfunctions.net/**", client, authorize, opts...),
+	)
+}
+
+type googleToken struct {
+	value   string
+	expires time.Time
+}
+
+// googleTokens caches the tokens obtained from the metadata server.
+type googleTokens struct {
+	client *http.Client
+	mu     sync.Mutex
+	cache  map[string]googleToken
+}
+
+// get returns the token for the given URL: an access token for Google APIs
+// or an identity token with the URL origin as audience otherwise.
+func (g *googleTokens) get(u *url.URL) (string, error) {
+	path := "identity?audience=" + url.QueryEscape(u.Scheme+"://"+u.Host)
+	if strings.HasSuffix(u.Hostname(), ".googleapis.com") {
+		if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+			return token, nil
+		}
+		path = "token"
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if t, ok := g.cache[path]; ok && time.Now().Before(t.expires) {
+		return t.value, nil
+	}
+	t, err := g.fetch(path)
+	if err != nil {
+		return "", fmt.Errorf("could not get a Google token: %v", err)
+	}
+	g.cache[path] = t
+	return t.value, nil
+}
+
+func (g *googleTokens) fetch(path string) (googleToken, error) {
+	req, err := http.NewRequest("GET", metadataURL+path, nil)
+	if err != nil {
+		return googleToken{}, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	res, err := g.client.Do(req)
+	if err != nil {
+		return googleToken{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return googleToken{}, fmt.Errorf("metadata server: status %s", res.Status)
+	}
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return googleToken{}, err
+	}
+	if !strings.HasPrefix(path, "token") {
+		// Identity tokens are returned as is, and valid for an hour.
+		return googleToken{strings.TrimSpace(string(b)), time.Now().Add(50 * time.Minute)}, nil
+	}
+	var t struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(b, &t); err != nil {
+		return googleToken{}, fmt.Errorf("metadata server: %v", err)
+	}
+	return googleToken{t.AccessToken, time.Now().Add(time.Duration(t.ExpiresIn)*time.Second - time.Minute)}, nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The expected signatures come from the AWS Signature Version 4 test suite.
+func TestSignV4(t *testing.T) {
+	creds := AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	tc := []struct {
+		name, url, auth string
+	}{
+		{name: "get-vanilla",
+			url: "https://example.amazonaws.com/",
+			auth: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{name: "get-vanilla-query-order-key-case",
+			url: "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			auth: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	}
+	for _, tt := range tc {
+		r, err := http.NewRequest("GET", tt.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := signV4(r, creds, "service", "us-east-1", now); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Header.Get("Authorization"); got != tt.auth {
+			t.Errorf("case [%s] expected\n%s\ngot\n%s", tt.name, tt.auth, got)
+		}
+	}
+}
+
+func TestAWSScope(t *testing.T) {
+	tc := []struct {
+		host, service, region string
+	}{
+		{"abc123.execute-api.eu-west-1.amazonaws.com", "execute-api", "eu-west-1"},
+		{"bucket.s3.us-west-2.amazonaws.com", "s3", "us-west-2"},
+		{"s3.us-west-2.amazonaws.com", "s3", "us-west-2"},
+		{"bucket.s3.amazonaws.com", "s3", "ap-south-1"},
+		{"s3.amazonaws.com", "s3", "ap-south-1"},
+	}
+	for _, tt := range tc {
+		service, region := awsScope(tt.host, "ap-south-1")
+		if service != tt.service || region != tt.region {
+			t.Errorf("expected %s to be %s in %s; got %s in %s", tt.host, tt.service, tt.region, service, region)
+		}
+	}
+}
+
+// roundTripper answers every request with the Authorization header it has.
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func echoAuthorization(r *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(r.Header.Get("Authorization"))),
+	}, nil
+}
+
+func TestSigV4Middleware(t *testing.T) {
+	client := &http.Client{Transport: roundTripper(echoAuthorization)}
+	other := FetcherFunc(func(dir, path string) ([]byte, error) { return []byte("not signed"), nil })
+	f := ChainFetcher(other, SigV4Middleware(AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, client))
+
+	b, err := f.Fetch("", "https://bucket.s3.eu-west-1.amazonaws.com/docs/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(string(b), "/eu-west-1/s3/aws4_request") {
+		t.Errorf("unexpected authorization %q", b)
+	}
+	if b, _ := f.Fetch("", "https://example.com/main.go"); string(b) != "not signed" {
+		t.Errorf("expected other hosts not to be signed; got %q", b)
+	}
+}
+
+func TestGoogleMiddleware(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing header", http.StatusForbidden)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/token") {
+			fmt.Fprint(w, `{"access_token": "access", "expires_in": 3600}`)
+			return
+		}
+		fmt.Fprintf(w, "identity for %s\n", r.URL.Query().Get("audience"))
+	}))
+	defer ts.Close()
+	defer func(u string) { metadataURL = u }(metadataURL)
+	metadataURL = ts.URL + "/"
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "")
+
+	client := &http.Client{Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host == strings.TrimPrefix(ts.URL, "http://") {
+			return http.DefaultTransport.RoundTrip(r)
+		}
+		return echoAuthorization(r)
+	})}
+	f := ChainFetcher(NewFetcher(client), GoogleMiddleware(client))
+
+	tc := []struct {
+		path, auth string
+	}{
+		{"https://storage.googleapis.com/bucket/main.go", "Bearer access"},
+		{"https://storage.googleapis.com/bucket/other.go", "Bearer access"},
+		{"https://docs-abc.a.run.app/main.go", "Bearer identity for https://docs-abc.a.run.app"},
+		{"https://example.com/main.go", ""},
+	}
+	for _, tt := range tc {
+		b, err := f.Fetch("", tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.auth {
+			t.Errorf("expected %s to be fetched with %q; got %q", tt.path, tt.auth, b)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests to the metadata server; got %d", requests)
+	}
+
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "from-env")
+	if b, _ := f.Fetch("", "https://storage.googleapis.com/bucket/main.go"); string(b) != "Bearer from-env" {
+		t.Errorf("expected the token from the environment; got %q", b)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"io"
+)
+
+// A MappedRegion is a region of a source embedded at some lines of the
+// output.
+type MappedRegion struct {
+	SourceRegion
+	// OutStart and OutEnd are the first and last lines of the region in the
+	// output.
+	OutStart, OutEnd int
+}
+
+// WithSourceMap calls f with the lines of the output where each command
+// embedded a region of its source, in order, so tools can link embedded
+// code back to it. Content that isn't in the output as embedded, such as
+// placeholders or snippets moved to shared files, isn't mapped.
+func WithSourceMap(f func(MappedRegion)) Option {
+	return Option{func(e *embedder) { e.sourceMap = f }}
+}
+
+// lineCounter counts the lines written to w.
+type lineCounter struct {
+	w     io.Writer
+	lines int
+}
+
+func (c *lineCounter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.lines += bytes.Count(b[:n], []byte("\n"))
+	return n, err
+}
+
+// mapSources reports the regions embedded by cmd and the commands stacked
+// on it, with the content b rendered as block, written after the given
+// number of lines of the output.
+func (e *embedder) mapSources(cmd *command, block, b []byte, written int) {
+	if len(b) == 0 || cmd.include != "" {
+		return
+	}
+	line := written + 1
+	// Indented code blocks start with the content, other blocks contain it
+	// as is.
+	if i := bytes.Index(block, b); i >= 0 {
+		line += bytes.Count(block[:i], []byte("\n"))
+	} else if !cmd.indented {
+		return
+	}
+	for _, c := range append([]*command{cmd}, cmd.stacked...) {
+		if c.stitched {
+			// The separator line precedes the content.
+			line++
+		}
+		if c.embeddedLines == 0 {
+			continue
+		}
+		e.sourceMap(MappedRegion{SourceRegion{c.source(), c.region[0], c.region[1]}, line, line + c.embeddedLines - 1})
+		line += c.embeddedLines
+	}
+}
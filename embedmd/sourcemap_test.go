@@ -0,0 +1,56 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSourceMap(t *testing.T) {
+	files := map[string][]byte{"code.go": []byte(content), "other.go": []byte("package other\n")}
+	in := "# Title\n" +
+		"[embedmd]:# (other.go)\n```go\npackage other\n```\n" +
+		"Text\n" +
+		"[embedmd]:# (code.go /func main/ $)\n" +
+		"[embedmd]:# (other.go)\n" +
+		"Draft\n" +
+		"[embedmd]:# (missing.go)\n" +
+		"Text\n" +
+		"[embedmd]:# (code.go /fmt.Println/)\n```go\nold\n```\n"
+	var regions []MappedRegion
+	var out bytes.Buffer
+	err := Process(&out, strings.NewReader(in), WithFetcher(mixedContentProvider{files: files}), WithDraft(),
+		WithSourceMap(func(r MappedRegion) { regions = append(regions, r) }))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []MappedRegion{
+		{SourceRegion{Source{2, "other.go", "(other.go)", ""}, 1, 1}, 4, 4},
+		{SourceRegion{Source{7, "code.go", "(code.go /func main/ $)", "/func main/ $"}, 6, 8}, 10, 12},
+		{SourceRegion{Source{8, "other.go", "(other.go)", ""}, 1, 1}, 13, 13},
+		{SourceRegion{Source{12, "code.go", "(code.go /fmt.Println/)", "/fmt.Println/"}, 7, 7}, 23, 23},
+	}
+	if !reflect.DeepEqual(regions, want) {
+		t.Errorf("expected %+v; got %+v\n%s", want, regions, out.String())
+	}
+	lines := strings.Split(out.String(), "\n")
+	for _, r := range regions {
+		if got := lines[r.OutStart-1]; r.Path == "code.go" && !strings.Contains(got, "func main") && !strings.Contains(got, "fmt.Println") {
+			t.Errorf("line %d of the output is %q, not embedded from %s", r.OutStart, got, r.Path)
+		}
+	}
+}
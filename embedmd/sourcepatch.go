@@ -0,0 +1,69 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import "bytes"
+
+// A SourcePatch is the change to a source implementing the edit made by hand
+// to the block embedding it.
+type SourcePatch struct {
+	// Line is the line of the command, and Path the path of the source, as
+	// written in the command, relative to the base directory.
+	Line int
+	Path string
+	// Before is the content of the source, and After the content with the
+	// edit made.
+	Before, After []byte
+}
+
+// WithSourcePatches calls f with the change to the source of each block
+// edited by hand, as told by the checksums recorded with WithChecksums, when
+// its source is unchanged since, so the edit can be suggested to the authors
+// of the source. Only the blocks embedding a local file as is, such as a
+// function with a typo fixed, can be patched.
+func WithSourcePatches(f func(SourcePatch)) Option {
+	return Option{func(e *embedder) { e.sourcePatches = f }}
+}
+
+// patchSource reports the patch to the source of cmd implementing the edit
+// of its block, rendered as block from the content b when generated now.
+func (e *embedder) patchSource(cmd *command, block, b []byte) {
+	if len(cmd.stacked) > 0 || checksum(block) != cmd.checksum || len(b) == 0 ||
+		isURL(cmd.path) || isGitPath(cmd.path) || isRepoPath(cmd.path) || isExecPath(cmd.path) {
+		return
+	}
+	// The edit must be made to the content, not to what's around it.
+	i := bytes.Index(block, b)
+	if i < 0 {
+		return
+	}
+	prefix, suffix := block[:i], block[i+len(b):]
+	if !bytes.HasPrefix(cmd.block, prefix) || !bytes.HasSuffix(cmd.block[len(prefix):], suffix) {
+		return
+	}
+	edited := cmd.block[len(prefix) : len(cmd.block)-len(suffix)]
+
+	src, err := e.fetch(cmd.path)
+	if err != nil {
+		return
+	}
+	src = bytes.ReplaceAll(src, []byte("\r\n"), []byte("\n"))
+	// The content must be in the source as is, once only.
+	j := bytes.Index(src, b)
+	if j < 0 || bytes.LastIndex(src, b) != j {
+		return
+	}
+	after := append(append(append([]byte{}, src[:j]...), edited...), src[j+len(b):]...)
+	e.sourcePatches(SourcePatch{Line: cmd.line, Path: cmd.path, Before: src, After: after})
+}
@@ -0,0 +1,73 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSourcePatches(t *testing.T) {
+	src := "package main\n\nfunc main() {\n\tprintln(\"helo\")\n}\n"
+	files := map[string][]byte{"code.go": []byte(src), "twice.go": []byte("x\nx\n"), "if.go": []byte("if x {\n\ta()\n\tb()\n}\n")}
+	generate := func(cmd string) string {
+		t.Helper()
+		var out bytes.Buffer
+		if err := Process(&out, strings.NewReader(cmd), WithFetcher(mixedContentProvider{files: files}), WithChecksums()); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+	tc := []struct {
+		name, in string
+		edit     [2]string
+		after    string
+	}{
+		{name: "typo", in: "[embedmd]:# (code.go /func main/ $)\n", edit: [2]string{"helo", "hello"},
+			after: "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n"},
+		{name: "added line", in: "[embedmd]:# (code.go /func main/ $)\n", edit: [2]string{"}\n", "\tprintln(2)\n}\n"},
+			after: "package main\n\nfunc main() {\n\tprintln(\"helo\")\n\tprintln(2)\n}\n"},
+		{name: "fence edited", in: "[embedmd]:# (code.go /func main/ $)\n", edit: [2]string{"```go", "```golang"}},
+		{name: "transformed", in: "[embedmd]:# (if.go /\\ta/ /\\tb.*/ transform=dedent)\n", edit: [2]string{"a()", "c()"}},
+		{name: "ambiguous", in: "[embedmd]:# (twice.go#L1)\n", edit: [2]string{"x\n```", "y\n```"}},
+		{name: "unedited", in: "[embedmd]:# (code.go)\n"},
+	}
+	for _, tt := range tc {
+		doc := generate(tt.in)
+		if tt.edit[0] != "" {
+			doc = strings.Replace(doc, tt.edit[0], tt.edit[1], 1)
+		}
+		var patches []SourcePatch
+		err := Process(&bytes.Buffer{}, strings.NewReader(doc), WithFetcher(mixedContentProvider{files: files}), WithChecksums(),
+			WithSourcePatches(func(p SourcePatch) { patches = append(patches, p) }))
+		if err != nil {
+			t.Errorf("case [%s]: %v", tt.name, err)
+			continue
+		}
+		if tt.after == "" {
+			if len(patches) > 0 {
+				t.Errorf("case [%s]: expected no patch, got %q", tt.name, patches[0].After)
+			}
+			continue
+		}
+		if len(patches) != 1 {
+			t.Errorf("case [%s]: expected one patch, got %d", tt.name, len(patches))
+			continue
+		}
+		if p := patches[0]; p.Line != 1 || p.Path != "code.go" || string(p.Before) != src || string(p.After) != tt.after {
+			t.Errorf("case [%s]: unexpected patch %+v", tt.name, p)
+		}
+	}
+}
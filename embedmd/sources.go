@@ -0,0 +1,76 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import "io"
+
+// A Source is the file or URL embedded by a command.
+type Source struct {
+	// Line is the line of the command.
+	Line int
+	// Path is the path or URL of the source, with variables and aliases
+	// expanded.
+	Path string
+	// Args is the argument list of the command, in parentheses, as written.
+	Args string
+	// Selection is the part of the source embedded, as written in the
+	// command: its line range or heading, as in #L10-L42, its tag, symbol,
+	// or selector, as in tag=main, or its regular expressions. It's empty
+	// when the whole source is embedded.
+	Selection string
+}
+
+// Sources returns the sources embedded by the commands in the markdown read
+// from in, in order, without fetching them. Commands left untouched, such
+// as those in front matter, are ignored.
+func Sources(in io.Reader, opts ...Option) ([]Source, error) {
+	e, b, err := newEmbedder(in, opts)
+	if err != nil {
+		return nil, err
+	}
+	var sources []Source
+	err = e.eachCommand(b, func(c *command) error {
+		sources = append(sources, c.source())
+		return nil
+	})
+	return sources, err
+}
+
+// source returns the source embedded by cmd.
+func (cmd *command) source() Source {
+	return Source{cmd.line, cmd.path, cmd.args, cmd.selection()}
+}
+
+// selection returns the part of its source cmd embeds, as written in it.
+func (cmd *command) selection() string {
+	switch {
+	case cmd.lines != nil:
+		return "#" + cmd.lines.String()
+	case cmd.heading != "":
+		return "#" + cmd.heading
+	case cmd.tag != "":
+		return "tag=" + cmd.tag
+	case cmd.symbol != "":
+		return "symbol=" + cmd.symbol
+	case cmd.selector != "" && cmd.selectorArg != "":
+		return "select=" + cmd.selector + "=" + cmd.selectorArg
+	case cmd.selector != "":
+		return "select=" + cmd.selector
+	case cmd.start != nil && cmd.end != nil:
+		return *cmd.start + " " + *cmd.end
+	case cmd.start != nil:
+		return *cmd.start
+	}
+	return ""
+}
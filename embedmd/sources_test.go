@@ -0,0 +1,74 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSources(t *testing.T) {
+	tc := []struct {
+		name string
+		in   string
+		opts []Option
+		want []Source
+		err  string
+	}{
+		{
+			name: "files and URLs",
+			in: "# Title\n[embedmd]:# (code.go)\n```go\nold\n```\n\n" +
+				"[embedmd]:# (https://example.com/main.go go /func main/ /^}/)\n",
+			want: []Source{{2, "code.go", "(code.go)", ""}, {7, "https://example.com/main.go", "(https://example.com/main.go go /func main/ /^}/)", "/func main/ /^}/"}},
+		},
+		{
+			name: "stacked commands",
+			in:   "[embedmd]:# (a.go)\n[embedmd]:# (b.go)\n",
+			want: []Source{{1, "a.go", "(a.go)", ""}, {2, "b.go", "(b.go)", ""}},
+		},
+		{
+			name: "aliases",
+			in:   "[embedmd]:# (@ex/main.go)\n",
+			opts: []Option{WithAlias("@ex", "https://example.com/go")},
+			want: []Source{{1, "https://example.com/go/main.go", "(@ex/main.go)", ""}},
+		},
+		{
+			name: "selections",
+			in:   "[embedmd]:# (a.go#L2-L4)\n\n[embedmd]:# (b.md#usage)\n\n[embedmd]:# (c.go tag=main + c.go go:func=Serve)\n",
+			want: []Source{{1, "a.go", "(a.go#L2-L4)", "#L2-L4"}, {3, "b.md", "(b.md#usage)", "#usage"}, {5, "c.go", "(c.go tag=main + c.go go:func=Serve)", "tag=main"}, {5, "c.go", "(c.go tag=main + c.go go:func=Serve)", "symbol=Serve"}},
+		},
+		{
+			name: "commands in code blocks and front matter",
+			in:   "---\ntitle: x\n[embedmd]:# (a.go)\n---\n```\n[embedmd]:# (b.go)\n```\n",
+		},
+		{
+			name: "unknown alias",
+			in:   "\n[embedmd]:# (@ex/main.go)\n",
+			err:  "2: unknown alias @ex",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Sources(strings.NewReader(tt.in), tt.opts...)
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected sources %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
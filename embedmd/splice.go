@@ -0,0 +1,220 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// parseInclude parses the arguments of an include directive, which splices
+// the markdown of a file, or a section of it, in the document as is.
+func parseInclude(args []string) (*command, error) {
+	if slices.Contains(args, "+") {
+		return nil, errors.New("include cannot stitch regions")
+	}
+	cmd, err := parseArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if !cmd.inferredLang && !isExecPath(cmd.path) {
+		return nil, errors.New("include takes no language, it splices markdown")
+	}
+	cmd.lang, cmd.inferredLang, cmd.useFence, cmd.splice = "none", false, false, true
+	return cmd, nil
+}
+
+// extractSection returns the section of the markdown in b starting on the
+// line matching start, up to the line matching end, excluded, or without end
+// up to the next heading of the same or a higher level.
+func extractSection(b []byte, start string, end *string) ([]byte, error) {
+	if start != "" {
+		re, err := compileSlashed(start)
+		if err != nil {
+			return nil, err
+		}
+		loc := re.FindIndex(b)
+		if loc == nil {
+			return nil, fmt.Errorf("could not match %q", start)
+		}
+		b = b[bytes.LastIndexByte(b[:loc[0]], '\n')+1:]
+	}
+	// The end is searched after the first line, which could match it too.
+	first := len(b)
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		first = i + 1
+	}
+	if end != nil {
+		if *end == "$" {
+			return b, nil
+		}
+		re, err := compileSlashed(*end)
+		if err != nil {
+			return nil, err
+		}
+		loc := re.FindIndex(b[first:])
+		if loc == nil {
+			return nil, fmt.Errorf("could not match %q", *end)
+		}
+		return b[:bytes.LastIndexByte(b[:first+loc[0]], '\n')+1], nil
+	}
+
+	level := headingLevel(string(b[:first]))
+	if level == 0 {
+		return nil, fmt.Errorf("%q should match a heading, or be followed by the regular expression matching the end of the section", start)
+	}
+	var fence string
+	for i := first; i < len(b); {
+		next := len(b)
+		if j := bytes.IndexByte(b[i:], '\n'); j >= 0 {
+			next = i + j + 1
+		}
+		line := strings.TrimSpace(string(b[i:next]))
+		switch {
+		case fence != "":
+			// Lines in code blocks aren't headings.
+			if strings.HasPrefix(line, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(line, "```"), strings.HasPrefix(line, "~~~"):
+			fence = line[:3]
+		default:
+			if l := headingLevel(line); l > 0 && l <= level {
+				return b[:i], nil
+			}
+		}
+		i = next
+	}
+	return b, nil
+}
+
+// linkTarget matches the target of inline links, which isn't part of the
+// anchors of headings.
+var linkTarget = regexp.MustCompile(`\]\([^)]*\)`)
+
+// markdownExts are the extensions of the markdown files whose sections can
+// be embedded by the anchor of their heading.
+var markdownExts = map[string]bool{".md": true, ".markdown": true, ".mdx": true}
+
+// cutHeading removes the anchor of a heading at the end of the path of a
+// markdown file, if any, returning it too.
+func cutHeading(p string) (string, string) {
+	i := strings.LastIndexByte(p, '#')
+	if i < 0 || !markdownExts[strings.ToLower(path.Ext(p[:i]))] {
+		return p, ""
+	}
+	return p[:i], p[i+1:]
+}
+
+// extractHeading returns the section of the markdown in b under the heading
+// with the given anchor, up to the next heading of the same or a higher
+// level.
+func extractHeading(b []byte, anchor string) ([]byte, error) {
+	var (
+		fence   string
+		anchors = map[string]int{}
+	)
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	start := frontMatterEnd(markdownLines(b))
+	if start > 0 {
+		start++
+	}
+	offset := 0
+	for i, l := range lines {
+		if i < start {
+			offset += len(l)
+			continue
+		}
+		line := strings.TrimSpace(string(l))
+		switch {
+		case fence != "":
+			if strings.HasPrefix(line, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(line, "```"), strings.HasPrefix(line, "~~~"):
+			fence = line[:3]
+		case headingLevel(line) > 0:
+			// Headings with the same anchor are told apart by a number, as
+			// GitHub does.
+			a := headingAnchor(line)
+			if n := anchors[a]; n > 0 {
+				anchors[a] = n + 1
+				a = fmt.Sprintf("%s-%d", a, n)
+			} else {
+				anchors[a] = 1
+			}
+			if a == anchor {
+				return extractSection(b[offset:], "", nil)
+			}
+		}
+		offset += len(l)
+	}
+	return nil, fmt.Errorf("could not find a heading with the anchor #%s", anchor)
+}
+
+// headingAnchor returns the anchor of the ATX heading on the line, as
+// GitHub generates it: its text in lower case, without punctuation, and with
+// hyphens for spaces.
+func headingAnchor(line string) string {
+	text := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+	text = linkTarget.ReplaceAllString(text, "]")
+	// A closing sequence of #s isn't part of the text.
+	if t := strings.TrimRight(text, "#"); t != text && (t == "" || strings.HasSuffix(t, " ")) {
+		text = strings.TrimSpace(t)
+	}
+	var sb strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '_', r == '-':
+			sb.WriteRune(r)
+		case r == ' ':
+			sb.WriteRune('-')
+		}
+	}
+	return sb.String()
+}
+
+// headingLevel returns the level of the ATX heading on the line, or zero if
+// it's not one.
+func headingLevel(line string) int {
+	line = strings.TrimRight(strings.TrimLeft(line, " "), "\r\n")
+	n := len(line) - len(strings.TrimLeft(line, "#"))
+	if n == 0 || n > 6 || len(line) > n && line[n] != ' ' && line[n] != '\t' {
+		return 0
+	}
+	return n
+}
+
+// withoutFrontMatter returns the markdown in b without its front matter.
+func withoutFrontMatter(b []byte) []byte {
+	lines := markdownLines(b)
+	end := frontMatterEnd(lines)
+	if end == 0 {
+		return b
+	}
+	for range end + 1 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			return nil
+		}
+		b = b[i+1:]
+	}
+	return bytes.TrimLeft(b, "\r\n")
+}
@@ -0,0 +1,125 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHeading(t *testing.T) {
+	readme := "---\ntitle: README\n---\n\n# Tool\n\n## Installation\n\nRun:\n\n~~~sh\n# not a heading\n~~~\n\n### From source\n\nBuild.\n\n## Usage\n\nRun it.\n\n## Usage\n\nAgain.\n"
+	files := map[string][]byte{"README.md": []byte(readme)}
+	tc := []struct {
+		name, in, out, err string
+	}{
+		{name: "section",
+			in:  "[embedmd]:# (README.md#installation md)\n",
+			out: "[embedmd]:# (README.md#installation md)\n```md\n## Installation\n\nRun:\n\n~~~sh\n# not a heading\n~~~\n\n### From source\n\nBuild.\n\n```\n"},
+		{name: "subsection",
+			in:  "[embedmd]:# (README.md#from-source md)\n",
+			out: "[embedmd]:# (README.md#from-source md)\n```md\n### From source\n\nBuild.\n\n```\n"},
+		{name: "duplicate heading",
+			in:  "[embedmd]:# (include README.md#usage-1)\n",
+			out: "[embedmd]:# (include README.md#usage-1)\n<!-- embedmd block start -->\n## Usage\n\nAgain.\n<!-- embedmd block end -->\n"},
+		{name: "heading in code",
+			in:  "[embedmd]:# (README.md#not-a-heading md)\n",
+			err: "1: could not extract content from README.md: could not find a heading with the anchor #not-a-heading"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
+
+func TestHeadingAnchor(t *testing.T) {
+	tc := []struct{ line, anchor string }{
+		{"# Installation", "installation"},
+		{"## Set up `embedmd`!", "set-up-embedmd"},
+		{"### What's new in v1.2?", "whats-new-in-v12"},
+		{"## [Go](https://go.dev) snake_case ##", "go-snake_case"},
+		{"# C# #", "c"},
+	}
+	for _, tt := range tc {
+		if got := headingAnchor(tt.line); got != tt.anchor {
+			t.Errorf("%q: expected anchor %q; got %q", tt.line, tt.anchor, got)
+		}
+	}
+}
+
+func TestInclude(t *testing.T) {
+	changelog := "---\ntitle: Changelog\n---\n\n# Changelog\n\n## v1.4\n\nNew.\n\n```sh\n## not a heading\n```\n\n### Fixes\n\nFix.\n\n## v1.3\n\nOld.\n"
+	files := map[string][]byte{"CHANGELOG.md": []byte(changelog), "INSTALL": []byte("Run `make`.")}
+	section := "## v1.4\n\nNew.\n\n```sh\n## not a heading\n```\n\n### Fixes\n\nFix.\n\n"
+	tc := []struct {
+		name, in, out, err string
+	}{
+		{name: "whole file",
+			in:  "[embedmd]:# (include CHANGELOG.md)\n",
+			out: "[embedmd]:# (include CHANGELOG.md)\n<!-- embedmd block start -->\n" + changelog[len("---\ntitle: Changelog\n---\n\n"):] + "<!-- embedmd block end -->\n"},
+		{name: "section",
+			in:  "[embedmd]:# (include CHANGELOG.md /## v1.4/)\n",
+			out: "[embedmd]:# (include CHANGELOG.md /## v1.4/)\n<!-- embedmd block start -->\n" + section + "<!-- embedmd block end -->\n"},
+		{name: "section up to a line",
+			in:  "[embedmd]:# (include CHANGELOG.md /## v1.4/ /## v1.3/)\n",
+			out: "[embedmd]:# (include CHANGELOG.md /## v1.4/ /## v1.3/)\n<!-- embedmd block start -->\n" + section + "<!-- embedmd block end -->\n"},
+		{name: "last section",
+			in:  "[embedmd]:# (include CHANGELOG.md /^### Fixes/)\n",
+			out: "[embedmd]:# (include CHANGELOG.md /^### Fixes/)\n<!-- embedmd block start -->\n### Fixes\n\nFix.\n\n<!-- embedmd block end -->\n"},
+		{name: "to the end",
+			in:  "[embedmd]:# (include CHANGELOG.md /## v1.3/ $)\n",
+			out: "[embedmd]:# (include CHANGELOG.md /## v1.3/ $)\n<!-- embedmd block start -->\n## v1.3\n\nOld.\n<!-- embedmd block end -->\n"},
+		{name: "without extension",
+			in:  "[embedmd]:# (include INSTALL)\n",
+			out: "[embedmd]:# (include INSTALL)\n<!-- embedmd block start -->\nRun `make`.\n<!-- embedmd block end -->\n"},
+		{name: "not a heading",
+			in:  "[embedmd]:# (include CHANGELOG.md /New/)\n",
+			err: "1: could not extract content from CHANGELOG.md: \"/New/\" should match a heading, or be followed by the regular expression matching the end of the section"},
+		{name: "no match",
+			in:  "[embedmd]:# (include CHANGELOG.md /## v1.4/ /## v0/)\n",
+			err: "1: could not extract content from CHANGELOG.md: could not match \"/## v0/\""},
+		{name: "language",
+			in:  "[embedmd]:# (include CHANGELOG.md markdown)\n",
+			err: "1: include takes no language, it splices markdown"},
+		{name: "stitched",
+			in:  "[embedmd]:# (include CHANGELOG.md + CHANGELOG.md)\n",
+			err: "1: include cannot stitch regions"},
+		{name: "missing file",
+			in:  "[embedmd]:# (include)\n",
+			err: "1: missing file name"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected\n%q\ngot\n%q", tt.name, tt.out, got)
+			continue
+		}
+		// Processing the output again changes nothing.
+		var again bytes.Buffer
+		if err := Process(&again, strings.NewReader(tt.out), WithFetcher(mixedContentProvider{files: files})); err != nil || again.String() != tt.out {
+			t.Errorf("case [%s]: expected no changes processing again; got %v\n%s", tt.name, err, again.String())
+		}
+	}
+}
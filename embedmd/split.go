@@ -0,0 +1,101 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// parseSplit parses the value of the split attribute.
+func parseSplit(val string) (int, error) {
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("split should be a positive number, got %q", val)
+	}
+	return n, nil
+}
+
+// chunks splits the code block b of cmd in chunks of as many lines as set
+// with the split attribute, or returns nil if it fits in one.
+func chunks(cmd *command, b []byte) [][]byte {
+	if cmd.split == 0 || !cmd.useFence || cmd.include != "" {
+		return nil
+	}
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= cmd.split {
+		return nil
+	}
+	var res [][]byte
+	for len(lines) > 0 {
+		n := min(cmd.split, len(lines))
+		res = append(res, bytes.Join(lines[:n], nil))
+		lines = lines[n:]
+	}
+	return res
+}
+
+// writeChunks writes each chunk of a code block split with the split
+// attribute in its own code fence, captioned as the continuation of the
+// previous one, so long listings break across pages when converted to PDF
+// instead of running off the bottom of the page. Line numbers continue from
+// one chunk to the next, and highlighted lines follow the chunk they're in.
+func (e *embedder) writeChunks(w io.Writer, cmd *command, chunks [][]byte) {
+	start := cmd.lineStart
+	if start == 0 {
+		start = max(cmd.region[0], 1)
+	}
+	offset := 0
+	for i, b := range chunks {
+		if i > 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintf(w, "*%s*\n\n", continuedCaption(cmd.caption))
+		}
+		n := bytes.Count(b, []byte("\n"))
+		c := *cmd
+		if c.linenos {
+			c.lineStart = start + offset
+		}
+		c.highlights = shiftRanges(cmd.highlights, offset, n)
+		e.writeFenced(w, &c, b)
+		offset += n
+	}
+}
+
+// continuedCaption returns the caption of the chunks of a split code block
+// following the first one.
+func continuedCaption(caption string) string {
+	if caption == "" {
+		return "(continued)"
+	}
+	return caption + " (continued)"
+}
+
+// shiftRanges returns the ranges of hl within the n lines following the
+// first offset ones, numbered from the first of them.
+func shiftRanges(hl []lineRange, offset, n int) []lineRange {
+	var res []lineRange
+	for _, r := range hl {
+		from, to := max(r.from-offset, 1), min(r.to-offset, n)
+		if from <= to {
+			res = append(res, lineRange{from, to})
+		}
+	}
+	return res
+}
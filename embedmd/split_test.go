@@ -0,0 +1,58 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	files := map[string][]byte{
+		"code.go": []byte("package main\n\nfunc main() {\n\tprintln(1)\n\tprintln(2)\n}\n"),
+	}
+	tc := []struct {
+		name, in, out string
+		err           string
+	}{
+		{name: "split", in: "[embedmd]:# (code.go /func main/ $ split=2)\n",
+			out: "[embedmd]:# (code.go /func main/ $ split=2)\n<!-- embedmd block start -->\n```go\nfunc main() {\n\tprintln(1)\n```\n\n*(continued)*\n\n```go\n\tprintln(2)\n}\n```\n<!-- embedmd block end -->\n"},
+		{name: "captioned", in: "[embedmd]:# (code.go /func main/ $ split=3 caption=Main)\n",
+			out: "[embedmd]:# (code.go /func main/ $ split=3 caption=Main)\n<!-- embedmd block start -->\n*Main*\n\n```go\nfunc main() {\n\tprintln(1)\n\tprintln(2)\n```\n\n*Main (continued)*\n\n```go\n}\n```\n<!-- embedmd block end -->\n"},
+		{name: "numbered", in: "[embedmd]:# (code.go /func main/ $ split=2 linenos=true hl=2-3)\n",
+			out: "[embedmd]:# (code.go /func main/ $ split=2 linenos=true hl=2-3)\n<!-- embedmd block start -->\n```go {2} showLineNumbers=3\nfunc main() {\n\tprintln(1)\n```\n\n*(continued)*\n\n```go {1} showLineNumbers=5\n\tprintln(2)\n}\n```\n<!-- embedmd block end -->\n"},
+		{name: "short enough", in: "[embedmd]:# (code.go /func main/ $ split=4)\n",
+			out: "[embedmd]:# (code.go /func main/ $ split=4)\n```go\nfunc main() {\n\tprintln(1)\n\tprintln(2)\n}\n```\n"},
+		{name: "bad split", in: "[embedmd]:# (code.go split=none)\n", err: "1: split should be a positive number, got \"none\""},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+			continue
+		}
+		// Processing the output again leaves it as it is.
+		out.Reset()
+		if err := Process(&out, strings.NewReader(tt.out), WithFetcher(mixedContentProvider{files: files})); err != nil {
+			t.Errorf("case [%s]: reprocessing: %v", tt.name, err)
+		} else if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected reprocessed output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
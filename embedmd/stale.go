@@ -0,0 +1,55 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// WithKeepStaleOnError keeps the block generated by a previous run, with a
+// warning, when the remote source of a command can't be fetched, rather than
+// failing. Errors with local files are still fatal.
+func WithKeepStaleOnError() Option {
+	return Option{func(e *embedder) { e.keepStale = true }}
+}
+
+// WithStaleMarkers adds a comment after the blocks kept by
+// WithKeepStaleOnError, which is removed once they are updated again.
+func WithStaleMarkers() Option {
+	return Option{func(e *embedder) { e.markStale = true }}
+}
+
+// staleMarker follows the blocks whose source couldn't be fetched.
+const staleMarker = "<!-- embedmd stale -->"
+
+// keepStaleBlock keeps the previous block of cmd when err comes from fetching a
+// remote source, reporting whether it did.
+func (e *embedder) keepStaleBlock(w io.Writer, cmd *command, err error) (bool, error) {
+	var fe *fetchError
+	if !e.keepStale || cmd.block == nil || !errors.As(err, &fe) || !isURL(fe.cmd.path) {
+		return false, nil
+	}
+	e.warnf(fe.cmd, "%v, keeping the previous content", fe)
+	if err := keepBlock(w, cmd); err != nil {
+		return true, err
+	}
+	// Trailing comments aren't recognized after loose fences.
+	if !e.markStale || cmd.looseFence {
+		return true, nil
+	}
+	_, err = fmt.Fprintln(w, staleMarker)
+	return true, err
+}
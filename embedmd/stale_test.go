@@ -0,0 +1,109 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestKeepStaleOnError(t *testing.T) {
+	const url = "https://example.com/down.go"
+	cmd := "[embedmd]:# (" + url + ")\n"
+	old := "```go\nold content\n```\n"
+	block := "```go\n" + content + "```\n"
+
+	tc := []struct {
+		name     string
+		in       string
+		urls     map[string][]byte
+		mark     bool
+		out      string
+		warnings []string
+		err      string
+	}{
+		{
+			name:     "previous content kept",
+			in:       cmd + old + "Yay!\n",
+			out:      cmd + old + "Yay!\n",
+			warnings: []string{"1: could not read " + url + ": status Not Found, keeping the previous content"},
+		},
+		{
+			name:     "marked as stale",
+			in:       cmd + old + "Yay!\n",
+			mark:     true,
+			out:      cmd + old + staleMarker + "\nYay!\n",
+			warnings: []string{"1: could not read " + url + ": status Not Found, keeping the previous content"},
+		},
+		{
+			name:     "still stale",
+			in:       cmd + old + staleMarker + "\n",
+			mark:     true,
+			out:      cmd + old + staleMarker + "\n",
+			warnings: []string{"1: could not read " + url + ": status Not Found, keeping the previous content"},
+		},
+		{
+			name: "marker removed once updated",
+			in:   cmd + old + staleMarker + "\nYay!\n",
+			urls: map[string][]byte{url: []byte(content)},
+			mark: true,
+			out:  cmd + block + "Yay!\n",
+		},
+		{
+			name:     "stacked command",
+			in:       "[embedmd]:# (code.go)\n" + cmd + old,
+			out:      "[embedmd]:# (code.go)\n" + cmd + old,
+			warnings: []string{"2: could not read " + url + ": status Not Found, keeping the previous content"},
+		},
+		{
+			name: "no previous content",
+			in:   cmd + "Yay!\n",
+			err:  "1: could not read " + url + ": status Not Found",
+		},
+		{
+			name: "local files still fail",
+			in:   "[embedmd]:# (missing.go)\n" + old,
+			err:  "1: could not read missing.go: file does not exist",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var warnings []string
+			opts := []Option{
+				WithFetcher(mixedContentProvider{files: map[string][]byte{"code.go": []byte(content)}, urls: tt.urls}),
+				WithKeepStaleOnError(),
+				WithWarnings(func(line int, msg string) {
+					warnings = append(warnings, fmt.Sprintf("%d: %s", line, msg))
+				}),
+			}
+			if tt.mark {
+				opts = append(opts, WithStaleMarkers())
+			}
+			err := Process(&out, strings.NewReader(tt.in), opts...)
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if got := out.String(); got != tt.out {
+				t.Errorf("expected output\n%s\ngot\n%s", tt.out, got)
+			}
+			if fmt.Sprint(warnings) != fmt.Sprint(tt.warnings) {
+				t.Errorf("expected warnings %q; got %q", tt.warnings, warnings)
+			}
+		})
+	}
+}
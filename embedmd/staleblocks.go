@@ -0,0 +1,65 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import "bytes"
+
+// A StaleBlock is a block whose embedded content is out of date.
+type StaleBlock struct {
+	// Line is the line of the command of the block.
+	Line int
+	// Command is the argument list of the command, in parentheses, as
+	// written.
+	Command string
+	// Added is set when the command had no block yet.
+	Added bool
+	// Sources are the regions embedded in the block, one per command, in
+	// order.
+	Sources []SourceRegion
+}
+
+// A SourceRegion is the part of a source embedded by a command.
+type SourceRegion struct {
+	Source
+	// Start and End are the first and last lines of the region in the
+	// source, or 0 if its lines can't be located, as when they aren't
+	// contiguous in the source.
+	Start, End int
+}
+
+// WithStaleBlocks calls f for every block whose content is out of date, so
+// checks can report the regions of the sources behind them.
+func WithStaleBlocks(f func(StaleBlock)) Option {
+	return Option{func(e *embedder) { e.staleBlocks = f }}
+}
+
+// reportStale reports the block of cmd as stale.
+func (e *embedder) reportStale(cmd *command) {
+	block := StaleBlock{Line: cmd.line, Command: cmd.args, Added: cmd.block == nil && len(cmd.trailers) == 0}
+	for _, c := range append([]*command{cmd}, cmd.stacked...) {
+		block.Sources = append(block.Sources, SourceRegion{c.source(), c.region[0], c.region[1]})
+	}
+	e.staleBlocks(block)
+}
+
+// regionLines returns the first and last lines of the region in src, or 0
+// if it isn't found.
+func regionLines(src, region []byte) [2]int {
+	i := bytes.Index(src, region)
+	if i < 0 || len(region) == 0 {
+		return [2]int{}
+	}
+	start := bytes.Count(src[:i], []byte("\n")) + 1
+	return [2]int{start, start + bytes.Count(bytes.TrimSuffix(region, []byte("\n")), []byte("\n"))}
+}
@@ -0,0 +1,74 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStaleBlocks(t *testing.T) {
+	files := map[string][]byte{"code.go": []byte(content), "other.go": []byte("package other\n")}
+	in := "# Up to date\n" +
+		"[embedmd]:# (other.go)\n```go\npackage other\n```\n" +
+		"# Stale\n" +
+		"[embedmd]:# (code.go /func main/ $)\n" +
+		"[embedmd]:# (other.go)\n```go\nold\n```\n" +
+		"[embedmd]:# (code.go /fmt.Println/)\n```go\nold\n```\n" +
+		"\n# New\n" +
+		"[embedmd]:# (other.go)\n"
+	var blocks []StaleBlock
+	var out bytes.Buffer
+	err := Process(&out, strings.NewReader(in), WithFetcher(mixedContentProvider{files: files}),
+		WithStaleBlocks(func(b StaleBlock) { blocks = append(blocks, b) }))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []StaleBlock{
+		{Line: 7, Command: "(code.go /func main/ $)", Sources: []SourceRegion{
+			{Source: Source{Line: 7, Path: "code.go", Args: "(code.go /func main/ $)", Selection: "/func main/ $"}, Start: 6, End: 8},
+			{Source: Source{Line: 8, Path: "other.go", Args: "(other.go)"}, Start: 1, End: 1},
+		}},
+		{Line: 12, Command: "(code.go /fmt.Println/)", Sources: []SourceRegion{
+			{Source: Source{Line: 12, Path: "code.go", Args: "(code.go /fmt.Println/)", Selection: "/fmt.Println/"}, Start: 7, End: 7},
+		}},
+		{Line: 18, Command: "(other.go)", Added: true, Sources: []SourceRegion{
+			{Source: Source{Line: 18, Path: "other.go", Args: "(other.go)"}, Start: 1, End: 1},
+		}},
+	}
+	if !reflect.DeepEqual(blocks, want) {
+		t.Errorf("expected %+v; got %+v", want, blocks)
+	}
+}
+
+func TestRegionLines(t *testing.T) {
+	src := []byte("a\nb\nc\nd\n")
+	tc := []struct {
+		region string
+		want   [2]int
+	}{
+		{"a\n", [2]int{1, 1}},
+		{"b\nc\n", [2]int{2, 3}},
+		{"d", [2]int{4, 4}},
+		{"x\n", [2]int{}},
+		{"", [2]int{}},
+	}
+	for _, tt := range tc {
+		if got := regionLines(src, []byte(tt.region)); got != tt.want {
+			t.Errorf("%q: expected %v; got %v", tt.region, tt.want, got)
+		}
+	}
+}
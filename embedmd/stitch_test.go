@@ -0,0 +1,68 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStitch(t *testing.T) {
+	files := map[string][]byte{
+		"a.go": []byte("func New() {\n}\n\nfunc Run() {\n}\n\nfunc Close() {\n}\n"),
+		"b.go": []byte("var x = 1\n"),
+	}
+	tc := []struct {
+		name, in, out string
+		err           string
+	}{
+		{name: "same file", in: "[embedmd]:# (a.go /func New/ /^}/ + a.go /func Close/ /^}/)\n",
+			out: "[embedmd]:# (a.go /func New/ /^}/ + a.go /func Close/ /^}/)\n```go\nfunc New() {\n}\n\nfunc Close() {\n}\n```\n"},
+		{name: "several files", in: "[embedmd]:# (a.go /func Run/ /^}/ + b.go + a.go#L1-L2)\n",
+			out: "[embedmd]:# (a.go /func Run/ /^}/ + b.go + a.go#L1-L2)\n```go\nfunc Run() {\n}\n\nvar x = 1\n\nfunc New() {\n}\n```\n"},
+		{name: "separator", in: "[embedmd]:# (a.go /func New/ /^}/ sep=\"// ...\" + b.go)\n",
+			out: "[embedmd]:# (a.go /func New/ /^}/ sep=\"// ...\" + b.go)\n```go\nfunc New() {\n}\n// ...\nvar x = 1\n```\n"},
+		{name: "stacked", in: "[embedmd]:# (b.go)\n[embedmd]:# (a.go /func New/ /^}/ + b.go)\n",
+			out: "[embedmd]:# (b.go)\n[embedmd]:# (a.go /func New/ /^}/ + b.go)\n```go\nvar x = 1\nfunc New() {\n}\n\nvar x = 1\n```\n"},
+		{name: "regular expression with +", in: "[embedmd]:# (a.go /func +New/ /^}/)\n",
+			out: "[embedmd]:# (a.go /func +New/ /^}/)\n```go\nfunc New() {\n}\n```\n"},
+		{name: "missing part", in: "[embedmd]:# (a.go +)\n", err: "1: missing file name"},
+		{name: "missing source", in: "[embedmd]:# (a.go + c.go)\n", err: "1: could not read c.go: file does not exist"},
+		{name: "bad separator", in: "[embedmd]:# (a.go sep=\"\" + b.go)\n", err: "1: sep should be a single line of text, got \"\""},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
+
+func TestPinStitched(t *testing.T) {
+	urls := map[string][]byte{"https://example.com/a.sh": []byte("a\n"), "https://example.com/b.sh": []byte("b\n")}
+	in := "[embedmd]:# (https://example.com/a.sh + https://example.com/b.sh)\n"
+	var out bytes.Buffer
+	if err := Process(&out, strings.NewReader(in), WithFetcher(mixedContentProvider{urls: urls}), WithPin()); err != nil {
+		t.Fatal(err)
+	}
+	want := "[embedmd]:# (https://example.com/a.sh sha256=" + sha256Hex("a\n") + " + https://example.com/b.sh sha256=" + sha256Hex("b\n") + ")\n```sh\na\n\nb\n```\n"
+	if got := out.String(); got != want {
+		t.Errorf("expected output\n%q\ngot\n%q", want, got)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A Store keeps the content fetched from URLs in a directory shared by every
+// run on a machine, whatever the repository or user, as the Go module cache
+// does. Content is addressed by its SHA-256 hash, which is verified whenever
+// it's read, and each URL records the hash of the content last fetched.
+type Store struct {
+	// Dir is the directory of the store, created if needed.
+	Dir string
+	// TTL is how long the content fetched from a URL is used before it's
+	// fetched again.
+	TTL time.Duration
+}
+
+// DefaultStoreDir returns the directory of the store in the user cache
+// directory.
+func DefaultStoreDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "embedmd", "store"), nil
+}
+
+// StoreMiddleware serves the content of URLs from the store while it's
+// fresh, and adds the content fetched otherwise. Files are always fetched.
+func StoreMiddleware(s *Store) Middleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherContextFunc(func(ctx context.Context, dir, path string) ([]byte, error) {
+			if !isURL(path) {
+				return FetchContext(ctx, next, dir, path)
+			}
+			if b, ok := s.lookup(path); ok {
+				return b, nil
+			}
+			b, err := FetchContext(ctx, next, dir, path)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.add(path, b); err != nil {
+				return nil, fmt.Errorf("could not store content: %v", err)
+			}
+			return b, nil
+		})
+	}
+}
+
+func (s *Store) blobPath(hash string) string {
+	return filepath.Join(s.Dir, "blobs", hash[:2], hash)
+}
+
+func (s *Store) urlPath(url string) string {
+	hash := sha256Hex(url)
+	return filepath.Join(s.Dir, "urls", hash[:2], hash)
+}
+
+// lookup returns the fresh content stored for the URL, if any. Content that
+// doesn't match its hash is removed.
+func (s *Store) lookup(url string) ([]byte, bool) {
+	index := s.urlPath(url)
+	info, err := os.Stat(index)
+	if err != nil || time.Since(info.ModTime()) > s.TTL {
+		return nil, false
+	}
+	h, err := os.ReadFile(index)
+	hash := strings.TrimSpace(string(h))
+	if err != nil || len(hash) != sha256.Size*2 {
+		return nil, false
+	}
+	blob := s.blobPath(hash)
+	b, err := os.ReadFile(blob)
+	if err != nil {
+		return nil, false
+	}
+	if sha256Hex(string(b)) != hash {
+		os.Remove(blob)
+		return nil, false
+	}
+	// The access time is kept for the garbage collection.
+	now := time.Now()
+	os.Chtimes(blob, now, now)
+	return b, true
+}
+
+// add stores the content fetched from the URL.
+func (s *Store) add(url string, b []byte) error {
+	hash := sha256Hex(string(b))
+	if err := writeAtomic(s.blobPath(hash), b, 0644); err != nil {
+		return err
+	}
+	return writeAtomic(s.urlPath(url), []byte(hash+"\n"), 0644)
+}
+
+// writeAtomic writes the file at path with the given permissions, so
+// concurrent readers never see it partially written.
+func writeAtomic(path string, b []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// GC removes the content of the store not used for longer than maxAge, then
+// the least recently used until it holds at most maxBytes, ignoring each
+// limit if 0. It returns the number of bytes removed.
+func (s *Store) GC(maxAge time.Duration, maxBytes int64) (int64, error) {
+	type blob struct {
+		path string
+		size int64
+		used time.Time
+	}
+	var blobs []blob
+	var total int64
+	err := filepath.WalkDir(filepath.Join(s.Dir, "blobs"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, blob{path, info.Size(), info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].used.Before(blobs[j].used) })
+
+	var removed int64
+	for _, b := range blobs {
+		old := maxAge > 0 && time.Since(b.used) > maxAge
+		big := maxBytes > 0 && total-removed > maxBytes
+		if !old && !big {
+			break
+		}
+		if err := os.Remove(b.path); err != nil {
+			return removed, err
+		}
+		removed += b.size
+	}
+
+	// The URLs whose content was removed are fetched again, so their
+	// entries are dropped too.
+	err = filepath.WalkDir(filepath.Join(s.Dir, "urls"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		h, err := os.ReadFile(path)
+		hash := strings.TrimSpace(string(h))
+		if err != nil || len(hash) != sha256.Size*2 {
+			return os.Remove(path)
+		}
+		if _, err := os.Stat(s.blobPath(hash)); errors.Is(err, fs.ErrNotExist) {
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return removed, err
+	}
+	return removed, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreMiddleware(t *testing.T) {
+	const url = "https://example.com/main.go"
+	s := &Store{Dir: t.TempDir(), TTL: time.Hour}
+	fetches := 0
+	content := "package main\n"
+	f := ChainFetcher(FetcherFunc(func(dir, path string) ([]byte, error) {
+		fetches++
+		if path == "https://example.com/down.go" {
+			return nil, errors.New("status 503")
+		}
+		return []byte(content), nil
+	}), StoreMiddleware(s))
+
+	fetch := func(path, want string, wantFetches int) {
+		t.Helper()
+		b, err := f.Fetch("docs", path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(b) != want {
+			t.Errorf("expected %q; got %q", want, b)
+		}
+		if fetches != wantFetches {
+			t.Errorf("expected %d fetches; got %d", wantFetches, fetches)
+		}
+	}
+
+	fetch(url, "package main\n", 1)
+	fetch(url, "package main\n", 1)
+	fetch("main.go", "package main\n", 2)
+	fetch("main.go", "package main\n", 3)
+	if _, err := f.Fetch("", "https://example.com/down.go"); err == nil {
+		t.Errorf("expected an error fetching down.go")
+	}
+	fetches--
+
+	// A corrupted blob is discarded and fetched again.
+	blob := s.blobPath(sha256Hex(content))
+	if err := os.WriteFile(blob, []byte("package evil\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fetch(url, "package main\n", 4)
+	fetch(url, "package main\n", 4)
+
+	// Stale content is fetched again.
+	content = "package main // v2\n"
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(s.urlPath(url), old, old); err != nil {
+		t.Fatal(err)
+	}
+	fetch(url, "package main // v2\n", 5)
+	fetch(url, "package main // v2\n", 5)
+}
+
+func TestStoreGC(t *testing.T) {
+	s := &Store{Dir: t.TempDir(), TTL: time.Hour}
+	add := func(url, content string, age time.Duration) {
+		t.Helper()
+		if err := s.add(url, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		used := time.Now().Add(-age)
+		if err := os.Chtimes(s.blobPath(sha256Hex(content)), used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("https://example.com/old", "old content\n", 48*time.Hour)
+	add("https://example.com/lru", "least recently used\n", 2*time.Hour)
+	add("https://example.com/new", "new content\n", 0)
+
+	removed, err := s.GC(24*time.Hour, int64(len("new content\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(len("old content\n") + len("least recently used\n")); removed != want {
+		t.Errorf("expected %d bytes removed; got %d", want, removed)
+	}
+	for url, kept := range map[string]bool{
+		"https://example.com/old": false,
+		"https://example.com/lru": false,
+		"https://example.com/new": true,
+	} {
+		if _, ok := s.lookup(url); ok != kept {
+			t.Errorf("expected %s kept: %v; got %v", url, kept, ok)
+		}
+		_, err := os.Stat(s.urlPath(url))
+		if got := err == nil; got != kept {
+			t.Errorf("expected entry of %s kept: %v; got %v", url, kept, got)
+		}
+	}
+
+	if removed, err := (&Store{Dir: filepath.Join(t.TempDir(), "none")}).GC(time.Hour, 0); err != nil || removed != 0 {
+		t.Errorf("expected nothing removed from a missing store; got %d, %v", removed, err)
+	}
+}
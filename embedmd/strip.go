@@ -0,0 +1,47 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"io"
+)
+
+// WithStrip removes the blocks generated after commands instead of
+// embedding them, so the markdown can be stored without them and embedded
+// on build. Nothing is fetched. The checksum and refresh date recorded after
+// blocks are kept, so embedding the stripped markdown again restores it as
+// it was when the sources didn't change. Code blocks fenced differently from
+// what embedmd generates and indented code blocks are kept, as they can't be
+// told apart from code written by hand once removed.
+func WithStrip() Option {
+	return Option{func(e *embedder) { e.strip = true }}
+}
+
+// stripBlock writes what is left of the block of cmd once stripped.
+func stripBlock(w io.Writer, cmd *command) error {
+	if cmd.looseFence || cmd.indented {
+		return keepBlock(w, cmd)
+	}
+	if cmd.checksum != "" {
+		if _, err := io.WriteString(w, checksumTrailer(cmd.checksum, cmd.fingerprint)); err != nil {
+			return err
+		}
+	}
+	if cmd.refreshed != "" {
+		_, err := fmt.Fprintf(w, "%s%s -->\n", refreshedPrefix, cmd.refreshed)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStrip(t *testing.T) {
+	files := map[string][]byte{"a.go": []byte("var a = 1\n"), "b.go": []byte("var b = 2\n")}
+	embed := func(doc string, opts ...Option) string {
+		t.Helper()
+		opts = append([]Option{
+			WithFetcher(mixedContentProvider{files: files}),
+			WithChecksums(),
+			{func(e *embedder) { e.now = func() time.Time { return time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC) } }},
+		}, opts...)
+		var out bytes.Buffer
+		if err := Process(&out, strings.NewReader(doc), opts...); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	doc := "# Doc\n\n" +
+		"[embedmd]:# (a.go)\n```go\nvar a = 1\n```\n\n" +
+		"[embedmd]:# (b.go caption=B maxage=90d)\n[embedmd]:# (a.go)\n<!-- embedmd block start -->\n*B*\n\n```go\nvar b = 2\nvar a = 1\n```\n" +
+		"<!-- embedmd block end -->\n<!-- embedmd refreshed 2026-09-01 -->\nText.\n" +
+		"[embedmd]:# (a.go)\n~~~go\nby hand\n~~~\n\n" +
+		"[embedmd]:# (b.go)\n    var b = 2\n\nEnd.\n"
+	// Embedding adds the checksums of the blocks.
+	doc = embed(doc)
+
+	stripped := embed(doc, WithStrip())
+	want := "# Doc\n\n" +
+		"[embedmd]:# (a.go)\n" + checksumLine(t, doc, 0) + "\n" +
+		"[embedmd]:# (b.go caption=B maxage=90d)\n[embedmd]:# (a.go)\n" + checksumLine(t, doc, 1) + "<!-- embedmd refreshed 2026-09-01 -->\nText.\n" +
+		"[embedmd]:# (a.go)\n" + checksumLine(t, doc, 2) + "~~~go\nby hand\n~~~\n\n" +
+		"[embedmd]:# (b.go)\n    var b = 2\n" + checksumLine(t, doc, 3) + "\nEnd.\n"
+	if stripped != want {
+		t.Errorf("expected stripped markdown\n%q\ngot\n%q", want, stripped)
+	}
+	if again := embed(stripped); again != doc {
+		t.Errorf("expected embedding the stripped markdown to restore\n%q\ngot\n%q", doc, again)
+	}
+
+	// Stripped blocks whose source changed are refreshed.
+	files["a.go"] = []byte("var a = 3\n")
+	if got := embed(stripped); !strings.Contains(got, "<!-- embedmd refreshed 2026-10-15 -->") {
+		t.Errorf("expected the changed block to be refreshed today; got\n%s", got)
+	}
+}
+
+// checksumLine returns the i-th checksum comment of doc.
+func checksumLine(t *testing.T, doc string, i int) string {
+	t.Helper()
+	var lines []string
+	for _, l := range strings.SplitAfter(doc, "\n") {
+		if strings.HasPrefix(l, checksumPrefix) {
+			lines = append(lines, l)
+		}
+	}
+	if i >= len(lines) {
+		t.Fatalf("no checksum %d in\n%s", i, doc)
+	}
+	return lines[i]
+}
@@ -0,0 +1,120 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SubmoduleMiddleware explains the local files not found because they're in
+// a git submodule that isn't initialized, naming the submodule to fetch, or
+// initializes the submodule and fetches the file again if init is set.
+func SubmoduleMiddleware(init bool) Middleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherContextFunc(func(ctx context.Context, dir, path string) ([]byte, error) {
+			b, err := FetchContext(ctx, next, dir, path)
+			if isURL(path) || isGitPath(path) || isRepoPath(path) {
+				return b, err
+			}
+			// Submodules holding submodules are initialized one at a time.
+			tried := map[string]bool{}
+			for err != nil && errors.Is(err, fs.ErrNotExist) {
+				top, sub, ok := uninitializedSubmodule(ctx, dir, path)
+				if !ok || tried[sub] {
+					break
+				}
+				if !init {
+					return nil, fmt.Errorf("%s is in the submodule %s, which isn't initialized: run git submodule update --init -- %s in %s", path, sub, sub, top)
+				}
+				tried[sub] = true
+				if _, ierr := runGit(ctx, top, "submodule", "update", "--init", "--", sub); ierr != nil {
+					return nil, fmt.Errorf("could not initialize the submodule %s of %s: %v", sub, path, ierr)
+				}
+				b, err = FetchContext(ctx, next, dir, path)
+			}
+			return b, err
+		})
+	}
+}
+
+// uninitializedSubmodule returns the root of the repository of the local
+// file at path, relative to dir, and the path of the submodule holding it if
+// it isn't initialized.
+func uninitializedSubmodule(ctx context.Context, dir, path string) (top, sub string, ok bool) {
+	if archive, _, inArchive := cutArchive(path); inArchive {
+		path = archive
+	}
+	file := filepath.FromSlash(path)
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return "", "", false
+	}
+	// The directory of an uninitialized submodule is there, but empty, so
+	// git finds the superproject from its closest existing parent.
+	existing := filepath.Dir(file)
+	for {
+		if _, err := os.Stat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return "", "", false
+		}
+		existing = parent
+	}
+	out, err := runGit(ctx, existing, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", "", false
+	}
+	top = strings.TrimSpace(string(out))
+	// git reports the root with symbolic links resolved.
+	real, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", "", false
+	}
+	rest, err := filepath.Rel(existing, file)
+	if err != nil {
+		return "", "", false
+	}
+	rel, err := filepath.Rel(top, filepath.Join(real, rest))
+	if err != nil {
+		return "", "", false
+	}
+	rel = filepath.ToSlash(rel)
+	out, err = runGit(ctx, top, "submodule", "status")
+	if err != nil {
+		return "", "", false
+	}
+	// Lines of uninitialized submodules start with -, followed by the commit
+	// and the path of the submodule.
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.HasPrefix(line, "-") {
+			continue
+		}
+		if p := fields[1]; strings.HasPrefix(rel, p+"/") {
+			return top, p, true
+		}
+	}
+	return "", "", false
+}
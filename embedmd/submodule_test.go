@@ -0,0 +1,78 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSubmoduleMiddleware(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	// Submodules are cloned from local paths in the test.
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+	root := t.TempDir()
+	git := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	lib, super, clone := filepath.Join(root, "lib"), filepath.Join(root, "super"), filepath.Join(root, "clone")
+	for _, dir := range []string{lib, super} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		git(dir, "init", "-q")
+	}
+	if err := os.WriteFile(filepath.Join(lib, "lib.go"), []byte("package lib\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git(lib, "add", ".")
+	git(lib, "commit", "-qm", "lib")
+	git(super, "submodule", "add", "-q", lib, "third_party/lib")
+	git(super, "commit", "-qm", "super")
+	git(root, "clone", "-q", super, clone)
+
+	docs := filepath.Join(clone, "docs")
+	if err := os.MkdirAll(docs, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ChainFetcher(NewFetcher(nil), SubmoduleMiddleware(false)).Fetch(docs, "../third_party/lib/lib.go")
+	top, _ := filepath.EvalSymlinks(clone)
+	eqErr(t, "uninitialized", err, "../third_party/lib/lib.go is in the submodule third_party/lib, which isn't initialized: run git submodule update --init -- third_party/lib in "+top)
+
+	_, err = ChainFetcher(NewFetcher(nil), SubmoduleMiddleware(false)).Fetch(docs, "missing.go")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a missing file outside of submodules to be reported as such, got %v", err)
+	}
+
+	b, err := ChainFetcher(NewFetcher(nil), SubmoduleMiddleware(true)).Fetch(docs, "../third_party/lib/lib.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(b)); got != "package lib" {
+		t.Errorf("expected the file of the initialized submodule, got %q", got)
+	}
+}
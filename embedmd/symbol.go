@@ -0,0 +1,157 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"slices"
+)
+
+// symbolKinds are the attributes selecting a Go symbol of a given kind, by
+// the kind of declaration they match. The symbol attribute matches any.
+var symbolKinds = map[string]string{
+	"symbol":   "",
+	"go:func":  "func",
+	"go:type":  "type",
+	"go:const": "const",
+	"go:var":   "var",
+}
+
+// validSymbol matches the names of Go symbols, and of methods as
+// Type.Method.
+var validSymbol = regexp.MustCompile(`^[\pL_][\pL\pN_]*(\.[\pL_][\pL\pN_]*)?$`)
+
+// extractSymbol returns the declaration of the Go symbol name of the given
+// kind, or any kind if empty, in the Go source b, with its doc comment.
+// Methods are named as Type.Method.
+func extractSymbol(b []byte, kind, name string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", b, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse Go source: %v", err)
+	}
+	start, end := token.NoPos, token.NoPos
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if (kind == "" || kind == "func") && funcName(d) == name {
+				start, end = declStart(d.Doc, d.Pos()), d.End()
+			}
+		case *ast.GenDecl:
+			if kind != "" && kind != d.Tok.String() {
+				continue
+			}
+			for _, spec := range d.Specs {
+				doc, names := specNames(spec)
+				if !slices.Contains(names, name) {
+					continue
+				}
+				// Symbols declared on their own are embedded with the
+				// keyword declaring them, and those in groups alone.
+				start, end = declStart(doc, spec.Pos()), specEnd(spec)
+				if !d.Lparen.IsValid() {
+					start, end = declStart(d.Doc, d.Pos()), d.End()
+				}
+			}
+		}
+		if start.IsValid() {
+			break
+		}
+	}
+	if !start.IsValid() {
+		if kind == "" {
+			return nil, fmt.Errorf("could not find %s", name)
+		}
+		return nil, fmt.Errorf("could not find %s %s", kind, name)
+	}
+	return wholeLines(b, fset.Position(start).Offset, fset.Position(end).Offset), nil
+}
+
+// funcName returns the name of a function, or Type.Method for methods.
+func funcName(d *ast.FuncDecl) string {
+	if d.Recv == nil || len(d.Recv.List) == 0 {
+		return d.Name.Name
+	}
+	t := d.Recv.List[0].Type
+	for {
+		switch x := t.(type) {
+		case *ast.StarExpr:
+			t = x.X
+			continue
+		case *ast.IndexExpr:
+			t = x.X
+			continue
+		case *ast.IndexListExpr:
+			t = x.X
+			continue
+		case *ast.Ident:
+			return x.Name + "." + d.Name.Name
+		}
+		return d.Name.Name
+	}
+}
+
+// specNames returns the doc comment and the names declared by spec.
+func specNames(spec ast.Spec) (*ast.CommentGroup, []string) {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		return s.Doc, []string{s.Name.Name}
+	case *ast.ValueSpec:
+		var names []string
+		for _, n := range s.Names {
+			names = append(names, n.Name)
+		}
+		return s.Doc, names
+	}
+	return nil, nil
+}
+
+// specEnd returns the end of spec, including its line comment.
+func specEnd(spec ast.Spec) token.Pos {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		if s.Comment != nil {
+			return s.Comment.End()
+		}
+	case *ast.ValueSpec:
+		if s.Comment != nil {
+			return s.Comment.End()
+		}
+	}
+	return spec.End()
+}
+
+// declStart returns where a declaration at pos starts, with its doc comment.
+func declStart(doc *ast.CommentGroup, pos token.Pos) token.Pos {
+	if doc != nil {
+		return doc.Pos()
+	}
+	return pos
+}
+
+// wholeLines returns the lines of b containing the bytes from start to end.
+func wholeLines(b []byte, start, end int) []byte {
+	start = bytes.LastIndexByte(b[:start], '\n') + 1
+	if i := bytes.IndexByte(b[end:], '\n'); i >= 0 {
+		end += i + 1
+	} else {
+		end = len(b)
+	}
+	return b[start:end]
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import "testing"
+
+func TestExtractSymbol(t *testing.T) {
+	src := `package server
+
+import "net/http"
+
+// Version is the version of the server.
+const Version = "1.0"
+
+const (
+	// Port is the default port.
+	Port = 8080
+	Host = "localhost" // where to listen
+)
+
+// Server serves requests.
+type Server[T any] struct {
+	name string
+}
+
+// HandleRequest handles a request.
+func HandleRequest(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server[T]) Name() string { return s.name }
+
+var handler, fallback = HandleRequest, HandleRequest
+`
+	tc := []struct {
+		name, kind, symbol, out, err string
+	}{
+		{name: "func", kind: "func", symbol: "HandleRequest",
+			out: "// HandleRequest handles a request.\nfunc HandleRequest(w http.ResponseWriter, r *http.Request) {\n\tw.WriteHeader(http.StatusOK)\n}\n"},
+		{name: "method of a generic type", symbol: "Server.Name",
+			out: "func (s *Server[T]) Name() string { return s.name }\n"},
+		{name: "type", kind: "type", symbol: "Server",
+			out: "// Server serves requests.\ntype Server[T any] struct {\n\tname string\n}\n"},
+		{name: "const", kind: "const", symbol: "Version",
+			out: "// Version is the version of the server.\nconst Version = \"1.0\"\n"},
+		{name: "const in a group", symbol: "Port",
+			out: "\t// Port is the default port.\n\tPort = 8080\n"},
+		{name: "line comment", kind: "const", symbol: "Host",
+			out: "\tHost = \"localhost\" // where to listen\n"},
+		{name: "one of several vars", kind: "var", symbol: "fallback",
+			out: "var handler, fallback = HandleRequest, HandleRequest\n"},
+		{name: "wrong kind", kind: "type", symbol: "HandleRequest", err: "could not find type HandleRequest"},
+		{name: "missing", symbol: "Missing", err: "could not find Missing"},
+	}
+	for _, tt := range tc {
+		b, err := extractSymbol([]byte(src), tt.kind, tt.symbol)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if string(b) != tt.out {
+			t.Errorf("case [%s]: expected\n%q\ngot\n%q", tt.name, tt.out, b)
+		}
+	}
+
+	_, err := extractSymbol([]byte("not go"), "", "main")
+	eqErr(t, "not go", err, "could not parse Go source: 1:1: expected 'package', found not")
+}
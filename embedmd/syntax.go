@@ -0,0 +1,102 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A Syntax is a form of the commands in markdown files.
+type Syntax string
+
+const (
+	// LinkSyntax is the form of link reference definitions, which renderers
+	// hide: [embedmd]:# (file.go /start/ /end/).
+	LinkSyntax Syntax = "link"
+	// CommentSyntax is the form of HTML comments, for renderers choking on
+	// link reference definitions: <!-- embedmd: file.go /start/ /end/ -->.
+	CommentSyntax Syntax = "comment"
+)
+
+// WithSyntaxes sets the forms of the commands recognized, LinkSyntax only
+// by default.
+func WithSyntaxes(syntaxes ...Syntax) Option {
+	return Option{func(e *embedder) { e.syntaxes = syntaxes }}
+}
+
+func (e *embedder) validateSyntaxes() error {
+	for _, s := range e.syntaxes {
+		if s != LinkSyntax && s != CommentSyntax {
+			return fmt.Errorf("bad syntax %q, should be link or comment", s)
+		}
+	}
+	return nil
+}
+
+// linkPrefix starts the commands in the link syntax.
+const linkPrefix = "[embedmd]:#"
+
+// commentCommand matches commands in HTML comments. The blank after the
+// colon tells them apart from markers such as embedmd:begin.
+var commentCommand = regexp.MustCompile(`^<!--\s*embedmd:\s+(.*?)\s*-->\s*$`)
+
+// commandArgs returns the parenthesized arguments of the command in line,
+// in one of the syntaxes, or ok false if it's not a command.
+func commandArgs(line string, syntaxes []Syntax) (args string, ok bool) {
+	args, _, ok = commandArgsAt(line, syntaxes)
+	return args, ok
+}
+
+// commandArgsAt works as commandArgs, also returning the offset of the
+// arguments in line. Arguments written without parenthesis start one byte
+// after it, where the opening one would be.
+func commandArgsAt(line string, syntaxes []Syntax) (args string, offset int, ok bool) {
+	for _, s := range syntaxes {
+		var re *regexp.Regexp
+		switch s {
+		case LinkSyntax:
+			if strings.HasPrefix(line, linkPrefix) {
+				return line[len(linkPrefix):], len(linkPrefix), true
+			}
+		case CommentSyntax:
+			re = commentCommand
+		case rstSyntax:
+			re = rstCommand
+		case asciidocSyntax:
+			re = asciidocCommand
+		}
+		if re == nil {
+			continue
+		}
+		if m := re.FindStringSubmatchIndex(line); m != nil {
+			args = line[m[2]:m[3]]
+			if p := parenthesized(args); p != args {
+				return p, m[2] - 1, true
+			}
+			return args, m[2], true
+		}
+	}
+	return "", 0, false
+}
+
+// parenthesized returns the arguments of a command in a comment in
+// parenthesis, which are optional there.
+func parenthesized(args string) string {
+	if strings.HasPrefix(args, "(") && strings.HasSuffix(args, ")") {
+		return args
+	}
+	return "(" + args + ")"
+}
@@ -0,0 +1,89 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSyntaxes(t *testing.T) {
+	files := map[string][]byte{"code.go": []byte(content), "other.go": []byte("package other\n")}
+	fenced := "```go\nfunc main() {\n        fmt.Println(\"hello, test\")\n}\n```\n"
+	both := []Syntax{LinkSyntax, CommentSyntax}
+	tc := []struct {
+		name, in, out string
+		syntaxes      []Syntax
+		err           string
+	}{
+		{
+			name: "link by default",
+			in:   "[embedmd]:# (code.go /func main/ $)\n",
+			out:  "[embedmd]:# (code.go /func main/ $)\n" + fenced,
+		},
+		{
+			name:     "comment",
+			in:       "<!-- embedmd: code.go /func main/ $ -->\n```go\nold\n```\n",
+			out:      "<!-- embedmd: code.go /func main/ $ -->\n" + fenced,
+			syntaxes: []Syntax{CommentSyntax},
+		},
+		{
+			name:     "comment with parentheses",
+			in:       "<!--embedmd: (code.go /func main/ $)-->\n",
+			out:      "<!--embedmd: (code.go /func main/ $)-->\n" + fenced,
+			syntaxes: []Syntax{CommentSyntax},
+		},
+		{
+			name:     "link not recognized",
+			in:       "[embedmd]:# (code.go /func main/ $)\n",
+			out:      "[embedmd]:# (code.go /func main/ $)\n",
+			syntaxes: []Syntax{CommentSyntax},
+		},
+		{
+			name:     "stacked",
+			in:       "[embedmd]:# (other.go)\n<!-- embedmd: other.go -->\n",
+			out:      "[embedmd]:# (other.go)\n<!-- embedmd: other.go -->\n```go\npackage other\npackage other\n```\n",
+			syntaxes: both,
+		},
+		{
+			name:     "nested",
+			in:       "- <!-- embedmd: other.go -->\n",
+			out:      "- <!-- embedmd: other.go -->\n  ```go\n  package other\n  ```\n",
+			syntaxes: both,
+		},
+		{
+			name:     "region markers",
+			in:       "<!-- embedmd:begin x -->\n<!-- embedmd:end x -->\n",
+			out:      "<!-- embedmd:begin x -->\n<!-- embedmd:end x -->\n",
+			syntaxes: both,
+		},
+		{
+			name:     "bad syntax",
+			in:       "[embedmd]:# (other.go)\n",
+			syntaxes: []Syntax{"wiki"},
+			err:      "bad syntax \"wiki\", should be link or comment",
+		},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}), WithSyntaxes(tt.syntaxes...))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// commentSyntaxes are the delimiters of line comments, and block comments
+// written on a single line, in common languages.
+var commentSyntaxes = [][2]string{
+	{"//", ""},      // C, Go, Java, JavaScript, Rust, ...
+	{"#", ""},       // Python, Ruby, shell, YAML, ...
+	{"--", ""},      // SQL, Haskell, Lua
+	{";", ""},       // Lisp, assembly, INI
+	{"%", ""},       // TeX, Erlang, MATLAB
+	{"'", ""},       // Visual Basic
+	{"/*", "*/"},    // C, CSS
+	{"<!--", "-->"}, // HTML, XML, Markdown
+	{"(*", "*)"},    // OCaml, Pascal
+	{"{/*", "*/}"},  // JSX
+}
+
+// tagMarker matches the comment lines beginning or ending tagged regions,
+// such as "// embedmd:begin config", capturing the kind and the name.
+var tagMarker = func() *regexp.Regexp {
+	var alts []string
+	for _, c := range commentSyntaxes {
+		alt := regexp.QuoteMeta(c[0]) + `+[ \t]*embedmd:(begin|end)[ \t]+([\w.-]+)[ \t]*`
+		if c[1] != "" {
+			alt += regexp.QuoteMeta(c[1])
+		}
+		alts = append(alts, alt)
+	}
+	return regexp.MustCompile(`^[ \t]*(?:` + strings.Join(alts, "|") + `)[ \t]*$`)
+}()
+
+// validTag matches the names of tags.
+var validTag = regexp.MustCompile(`^[\w.-]+$`)
+
+// marker returns the kind, begin or end, and the name of the tag marker on
+// line, if it is one.
+func marker(line []byte) (kind, name string, ok bool) {
+	m := tagMarker.FindSubmatch(bytes.TrimRight(line, "\r\n"))
+	if m == nil {
+		return "", "", false
+	}
+	// Only one of the alternatives matched.
+	for i := 1; i < len(m); i += 2 {
+		if m[i] != nil {
+			return string(m[i]), string(m[i+1]), true
+		}
+	}
+	return "", "", false
+}
+
+// extractTag returns the lines of b between the markers of the region named
+// tag, without the lines of any marker.
+func extractTag(b []byte, tag string) ([]byte, error) {
+	var out []byte
+	in, found, ended := false, false, false
+	for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+		kind, name, ok := marker(line)
+		switch {
+		case !ok:
+			if in {
+				out = append(out, line...)
+			}
+		case name != tag:
+		case kind == "begin" && found:
+			return nil, fmt.Errorf("tag %q begins more than once", tag)
+		case kind == "begin":
+			in, found = true, true
+		case kind == "end" && in:
+			in, ended = false, true
+		}
+	}
+	switch {
+	case !found:
+		return nil, fmt.Errorf("could not find tag %q", tag)
+	case !ended:
+		return nil, fmt.Errorf("tag %q has no end marker", tag)
+	}
+	return out, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExtractTag(t *testing.T) {
+	tc := []struct {
+		name, in, tag, out, err string
+	}{
+		{
+			name: "go",
+			in:   "package main\n\n// embedmd:begin config\nvar x = 1\n// embedmd:end config\n",
+			tag:  "config",
+			out:  "var x = 1\n",
+		},
+		{
+			name: "indented python",
+			in:   "def f():\n    # embedmd:begin body\n    return 1\n    # embedmd:end body\n",
+			tag:  "body",
+			out:  "    return 1\n",
+		},
+		{
+			name: "block comments",
+			in:   "/* embedmd:begin rule */\na { color: red; }\n/* embedmd:end rule */\n",
+			tag:  "rule",
+			out:  "a { color: red; }\n",
+		},
+		{
+			name: "html",
+			in:   "<ul>\n  <!-- embedmd:begin item-1 -->\n  <li>One</li>\n  <!--embedmd:end item-1-->\n</ul>\n",
+			tag:  "item-1",
+			out:  "  <li>One</li>\n",
+		},
+		{
+			name: "sql with CRLF",
+			in:   "-- embedmd:begin q\r\nSELECT 1;\r\n-- embedmd:end q\r\n",
+			tag:  "q",
+			out:  "SELECT 1;\r\n",
+		},
+		{
+			name: "nested tags are stripped",
+			in:   "// embedmd:begin all\na\n// embedmd:begin part\nb\n// embedmd:end part\n// embedmd:end all\n",
+			tag:  "all",
+			out:  "a\nb\n",
+		},
+		{
+			name: "marker in a string is code",
+			in:   "// embedmd:begin s\nx := \"// embedmd:end s\"\n// embedmd:end s\n",
+			tag:  "s",
+			out:  "x := \"// embedmd:end s\"\n",
+		},
+		{
+			name: "missing tag",
+			in:   "// embedmd:begin config\n// embedmd:end config\n",
+			tag:  "other",
+			err:  `could not find tag "other"`,
+		},
+		{
+			name: "missing end",
+			in:   "// embedmd:begin config\nvar x = 1\n",
+			tag:  "config",
+			err:  `tag "config" has no end marker`,
+		},
+		{
+			name: "repeated tag",
+			in:   "# embedmd:begin a\n# embedmd:end a\n# embedmd:begin a\n# embedmd:end a\n",
+			tag:  "a",
+			err:  `tag "a" begins more than once`,
+		},
+	}
+	for _, tt := range tc {
+		b, err := extractTag([]byte(tt.in), tt.tag)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if string(b) != tt.out {
+			t.Errorf("case [%s]: expected %q; got %q", tt.name, tt.out, b)
+		}
+	}
+}
+
+func TestProcessTag(t *testing.T) {
+	src := "package main\n\n// embedmd:begin main\nfunc main() {}\n// embedmd:end main\n"
+	var out bytes.Buffer
+	err := Process(&out, strings.NewReader("[embedmd]:# (main.go tag=main)\n"),
+		WithFetcher(mixedContentProvider{files: map[string][]byte{"main.go": []byte(src)}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[embedmd]:# (main.go tag=main)\n```go\nfunc main() {}\n```\n"; out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+}
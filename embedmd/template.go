@@ -0,0 +1,104 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// WithTemplateRegions leaves untouched the commands inside the regions of
+// templates whose content must be kept verbatim: Liquid and Jinja raw tags,
+// from {% raw %} to {% endraw %}, and paired Hugo shortcodes, such as
+// {{< highlight go >}} to {{< /highlight >}}.
+//
+// Commands in front matter, delimited by --- or +++ lines at the start of
+// the file, are always left untouched.
+func WithTemplateRegions() Option {
+	return Option{func(e *embedder) { e.templateRegions = true }}
+}
+
+var (
+	rawTag       = regexp.MustCompile(`\{%-?\s*raw\s*-?%\}`)
+	endRawTag    = regexp.MustCompile(`\{%-?\s*endraw\s*-?%\}`)
+	shortcodeTag = regexp.MustCompile(`\{\{([<%])\s*(/?)\s*([\w./-]+)`)
+)
+
+// skippedLines returns the numbers of the lines of the markdown in b whose
+// commands are left untouched.
+func skippedLines(b []byte, templates bool) map[int]bool {
+	lines := markdownLines(b)
+	skip := map[int]bool{}
+	add := func(start, end int) {
+		for i := start; i <= end; i++ {
+			skip[i+1] = true
+		}
+	}
+
+	if end := frontMatterEnd(lines); end > 0 {
+		add(0, end)
+	}
+	if !templates {
+		return skip
+	}
+	for i, line := range lines {
+		if rawTag.MatchString(line) {
+			if end := findLine(lines, i, endRawTag.MatchString); end > 0 {
+				add(i, end)
+			}
+		}
+		for _, m := range shortcodeTag.FindAllStringSubmatch(line, -1) {
+			if m[2] == "/" {
+				continue
+			}
+			closing := regexp.MustCompile(`\{\{` + regexp.QuoteMeta(m[1]) + `\s*/\s*` + regexp.QuoteMeta(m[3]) + `\s*[>%]\}\}`)
+			if end := findLine(lines, i, closing.MatchString); end > 0 {
+				add(i, end)
+			}
+		}
+	}
+	return skip
+}
+
+// findLine returns the index of the first line after the given one matching
+// f, or zero if none does.
+func findLine(lines []string, after int, f func(string) bool) int {
+	for i := after + 1; i < len(lines); i++ {
+		if f(lines[i]) {
+			return i
+		}
+	}
+	return 0
+}
+
+// keepBlock writes the block previously generated for cmd unchanged.
+func keepBlock(w io.Writer, cmd *command) error {
+	if _, err := w.Write(cmd.block); err != nil {
+		return err
+	}
+	if cmd.looseFence {
+		return writeTrailers(w, cmd)
+	}
+	if cmd.checksum != "" {
+		if _, err := io.WriteString(w, checksumTrailer(cmd.checksum, cmd.fingerprint)); err != nil {
+			return err
+		}
+	}
+	if cmd.refreshed != "" {
+		_, err := fmt.Fprintf(w, "%s%s -->\n", refreshedPrefix, cmd.refreshed)
+		return err
+	}
+	return nil
+}
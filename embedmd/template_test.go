@@ -0,0 +1,103 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTemplates(t *testing.T) {
+	const block = "```go\nvar x = 1\n```\n"
+	const stale = "```go\nold\n```\n"
+	tc := []struct {
+		name      string
+		in        string
+		templates bool
+		out       string
+	}{
+		{
+			name: "template syntax is passed through",
+			in: "{% include note.html %}\n{{ page.title | upcase }}\n{%- if x -%}\n" +
+				"{{< figure src=\"a.png\" >}}\n{{% notice %}}\n{# a jinja comment #}\n" +
+				"[embedmd]:# (x.go)\n" + stale +
+				"{{ '{{' }}\n{%- endif -%}\n",
+			out: "{% include note.html %}\n{{ page.title | upcase }}\n{%- if x -%}\n" +
+				"{{< figure src=\"a.png\" >}}\n{{% notice %}}\n{# a jinja comment #}\n" +
+				"[embedmd]:# (x.go)\n" + block +
+				"{{ '{{' }}\n{%- endif -%}\n",
+		},
+		{
+			name: "commands in YAML front matter",
+			in:   "---\ntitle: x\n[embedmd]:# (x.go)\n" + stale + "---\n[embedmd]:# (x.go)\n" + stale,
+			out:  "---\ntitle: x\n[embedmd]:# (x.go)\n" + stale + "---\n[embedmd]:# (x.go)\n" + block,
+		},
+		{
+			name: "commands in TOML front matter",
+			in:   "+++\n[embedmd]:# (x.go)\n+++\n[embedmd]:# (x.go)\n",
+			out:  "+++\n[embedmd]:# (x.go)\n+++\n[embedmd]:# (x.go)\n" + block,
+		},
+		{
+			name: "unclosed front matter is a thematic break",
+			in:   "---\n[embedmd]:# (x.go)\n",
+			out:  "---\n[embedmd]:# (x.go)\n" + block,
+		},
+		{
+			name: "raw regions without the option",
+			in:   "{% raw %}\n[embedmd]:# (x.go)\n" + stale + "{% endraw %}\n",
+			out:  "{% raw %}\n[embedmd]:# (x.go)\n" + block + "{% endraw %}\n",
+		},
+		{
+			name:      "raw regions",
+			in:        "{%- raw -%}\n[embedmd]:# (x.go)\n" + stale + "{%- endraw -%}\n[embedmd]:# (x.go)\n" + stale,
+			templates: true,
+			out:       "{%- raw -%}\n[embedmd]:# (x.go)\n" + stale + "{%- endraw -%}\n[embedmd]:# (x.go)\n" + block,
+		},
+		{
+			name:      "paired shortcodes",
+			in:        "{{< tabs >}}\n[embedmd]:# (x.go)\n" + stale + "{{</ tabs >}}\n{{% note %}}\n[embedmd]:# (x.go)\n{{% /note %}}\n",
+			templates: true,
+			out:       "{{< tabs >}}\n[embedmd]:# (x.go)\n" + stale + "{{</ tabs >}}\n{{% note %}}\n[embedmd]:# (x.go)\n{{% /note %}}\n",
+		},
+		{
+			name:      "unpaired shortcodes",
+			in:        "{{< figure src=\"a.png\" >}}\n[embedmd]:# (x.go)\n" + stale,
+			templates: true,
+			out:       "{{< figure src=\"a.png\" >}}\n[embedmd]:# (x.go)\n" + block,
+		},
+		{
+			name:      "checksums are kept",
+			in:        "{% raw %}\n[embedmd]:# (x.go)\n" + stale + "<!-- embedmd checksum 0123456789ab -->\n{% endraw %}\n",
+			templates: true,
+			out:       "{% raw %}\n[embedmd]:# (x.go)\n" + stale + "<!-- embedmd checksum 0123456789ab -->\n{% endraw %}\n",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			opts := []Option{WithFetcher(mixedContentProvider{files: map[string][]byte{"x.go": []byte("var x = 1\n")}})}
+			if tt.templates {
+				opts = append(opts, WithTemplateRegions())
+			}
+			if err := Process(&out, strings.NewReader(tt.in), opts...); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.out {
+				t.Errorf("expected output\n%s\ngot\n%s", tt.out, got)
+			}
+		})
+	}
+}
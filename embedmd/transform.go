@@ -0,0 +1,166 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Transform rewrites the content embedded by the commands listing it in
+// their transform attribute, as transform=name or transform=name=arg, before
+// it's rendered. Transforms listed together, separated by commas, are
+// applied in order, each to the output of the previous one.
+type Transform interface {
+	Transform(b []byte, arg string) ([]byte, error)
+}
+
+// TransformFunc is an adapter to use ordinary functions as Transforms.
+type TransformFunc func(b []byte, arg string) ([]byte, error)
+
+// Transform calls f(b, arg).
+func (f TransformFunc) Transform(b []byte, arg string) ([]byte, error) { return f(b, arg) }
+
+// WithTransform registers t as the transform with the given name, replacing
+// the built-in one with that name, if any: dedent, trimblank, or tabwidth.
+func WithTransform(name string, t Transform) Option {
+	return Option{func(e *embedder) {
+		if e.transforms == nil {
+			e.transforms = map[string]Transform{}
+		}
+		e.transforms[name] = t
+	}}
+}
+
+// builtinTransforms are the transforms available to every command.
+var builtinTransforms = map[string]Transform{
+	"dedent":    TransformFunc(dedent),
+	"trimblank": TransformFunc(trimBlank),
+	"tabwidth":  TransformFunc(expandTabs),
+}
+
+// transformStep is a transform applied by a command, with its argument.
+type transformStep struct {
+	name, arg string
+}
+
+// parseTransforms parses the value of a transform attribute, a comma
+// separated list of names, each optionally followed by = and an argument.
+func parseTransforms(s string) ([]transformStep, error) {
+	var steps []transformStep
+	for _, f := range strings.Split(s, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(f), "=")
+		if !validTag.MatchString(name) {
+			return nil, fmt.Errorf("transform should be a comma separated list of names, each with an optional =argument, got %q", s)
+		}
+		steps = append(steps, transformStep{name, arg})
+	}
+	return steps, nil
+}
+
+// transform applies the transforms of cmd to b, in order.
+func (e *embedder) transform(cmd *command, b []byte) ([]byte, error) {
+	for _, s := range cmd.transforms {
+		t, ok := e.transforms[s.name]
+		if !ok {
+			if t, ok = builtinTransforms[s.name]; !ok {
+				return nil, fmt.Errorf("unknown transform %q", s.name)
+			}
+		}
+		var err error
+		if b, err = t.Transform(b, s.arg); err != nil {
+			return nil, fmt.Errorf("could not apply transform %s to %s: %w", s.name, cmd.path, err)
+		}
+	}
+	return b, nil
+}
+
+var errNoArg = errors.New("takes no argument")
+
+// dedent removes the indentation shared by all the non-blank lines of b.
+func dedent(b []byte, arg string) ([]byte, error) {
+	if arg != "" {
+		return nil, errNoArg
+	}
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	var prefix []byte
+	first := true
+	for _, l := range lines {
+		if len(bytes.TrimSpace(l)) == 0 {
+			continue
+		}
+		indent := l[:len(l)-len(bytes.TrimLeft(l, " \t"))]
+		if first {
+			prefix, first = indent, false
+			continue
+		}
+		n := 0
+		for n < len(prefix) && n < len(indent) && prefix[n] == indent[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	for i, l := range lines {
+		if len(bytes.TrimSpace(l)) == 0 {
+			lines[i] = bytes.TrimLeft(l, " \t")
+		} else {
+			lines[i] = l[len(prefix):]
+		}
+	}
+	return bytes.Join(lines, nil), nil
+}
+
+// trimBlank removes the blank lines at the start and end of b.
+func trimBlank(b []byte, arg string) ([]byte, error) {
+	if arg != "" {
+		return nil, errNoArg
+	}
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	for len(lines) > 0 && len(bytes.TrimSpace(lines[0])) == 0 {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && len(bytes.TrimSpace(lines[len(lines)-1])) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return bytes.Join(lines, nil), nil
+}
+
+// expandTabs replaces the tabs in b with spaces, up to the next multiple of
+// the tab width given as argument.
+func expandTabs(b []byte, arg string) ([]byte, error) {
+	width, err := strconv.Atoi(arg)
+	if err != nil || width < 1 {
+		return nil, fmt.Errorf("tab width should be a positive number, got %q", arg)
+	}
+	var out bytes.Buffer
+	col := 0
+	for _, r := range string(b) {
+		switch r {
+		case '\t':
+			n := width - col%width
+			out.WriteString(strings.Repeat(" ", n))
+			col += n
+		case '\n':
+			out.WriteRune(r)
+			col = 0
+		default:
+			out.WriteRune(r)
+			col++
+		}
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	files := map[string][]byte{
+		"code.go":  []byte("package main\n\nfunc main() {\n\tif true {\n\t\tprintln(1)\n\n\t\tprintln(2)\n\t}\n}\n"),
+		"blank.go": []byte("\n\n  x := 1\n\n"),
+	}
+	tc := []struct {
+		name, in, out string
+		err           string
+	}{
+		{name: "dedent", in: "[embedmd]:# (code.go /\\tif/ /^\\t}/ transform=dedent)\n",
+			out: "[embedmd]:# (code.go /\\tif/ /^\\t}/ transform=dedent)\n```go\nif true {\n\tprintln(1)\n\n\tprintln(2)\n}\n```\n"},
+		{name: "tab width", in: "[embedmd]:# (code.go /\\tif/ /^\\t}/ transform=tabwidth=2)\n",
+			out: "[embedmd]:# (code.go /\\tif/ /^\\t}/ transform=tabwidth=2)\n```go\n  if true {\n    println(1)\n\n    println(2)\n  }\n```\n"},
+		{name: "chained", in: "[embedmd]:# (blank.go transform=trimblank,dedent)\n",
+			out: "[embedmd]:# (blank.go transform=trimblank,dedent)\n```go\nx := 1\n```\n"},
+		{name: "custom", in: "[embedmd]:# (code.go /func main/ /^}/ transform=redact=println)\n",
+			out: "[embedmd]:# (code.go /func main/ /^}/ transform=redact=println)\n```go\nfunc main() {\n\tif true {\n\t\t███(1)\n\n\t\t███(2)\n\t}\n}\n```\n"},
+		{name: "unknown", in: "[embedmd]:# (code.go transform=shout)\n", err: "1: unknown transform \"shout\""},
+		{name: "bad argument", in: "[embedmd]:# (code.go transform=tabwidth=0)\n",
+			err: "1: could not apply transform tabwidth to code.go: tab width should be a positive number, got \"0\""},
+		{name: "unexpected argument", in: "[embedmd]:# (code.go transform=dedent=2)\n",
+			err: "1: could not apply transform dedent to code.go: takes no argument"},
+		{name: "bad list", in: "[embedmd]:# (code.go transform=dedent,)\n",
+			err: "1: transform should be a comma separated list of names, each with an optional =argument, got \"dedent,\""},
+	}
+	redact := TransformFunc(func(b []byte, arg string) ([]byte, error) {
+		if arg == "" {
+			return nil, errors.New("missing word")
+		}
+		return bytes.ReplaceAll(b, []byte(arg), []byte("███")), nil
+	})
+	for _, tt := range tc {
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), WithFetcher(mixedContentProvider{files: files}),
+			WithTransform("redact", redact))
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Lines that look like commands but aren't recognized as such are ignored,
+// so the blocks after them silently go stale. These regular expressions
+// match the link reference definitions, and comments, whose label is close
+// to embedmd.
+var (
+	nearLinkCommand    = regexp.MustCompile(`^\[([^\]]*)\]\s*:\s*#?\s*(.*?)\s*$`)
+	nearCommentCommand = regexp.MustCompile(`^<!--\s*([A-Za-z_-]+)\s*:\s+(.*?)\s*-->\s*$`)
+)
+
+// typo is a line that looks like a mistyped command.
+type typo struct {
+	line, column int
+	// fix is the line written as a command.
+	fix string
+}
+
+// typos returns the lines of the markdown b, outside of code blocks and of
+// the blocks generated by embedmd, that look like mistyped commands.
+func (e *embedder) typos(b []byte) []typo {
+	if e.literal() {
+		return nil
+	}
+	syntaxes := e.syntaxes
+	if len(syntaxes) == 0 {
+		syntaxes = []Syntax{LinkSyntax}
+	}
+	var typos []typo
+	var inBlock func(string) bool
+	for i, line := range markdownLines(b) {
+		switch {
+		case inBlock != nil:
+			if inBlock(line) {
+				inBlock = nil
+			}
+			continue
+		case e.skipped[i+1]:
+			continue
+		case strings.HasPrefix(line, "<!-- embedmd block start"):
+			inBlock = func(l string) bool { return strings.HasPrefix(l, "<!-- embedmd block end") }
+			continue
+		}
+		prefix, _ := containerPrefix(line)
+		rest := line[len(prefix):]
+		if fence := openingFence(rest); fence != "" {
+			inBlock = func(l string) bool { return closesFence(strings.TrimLeft(l, " >"), fence) }
+			continue
+		}
+		if _, ok := commandArgs(rest, syntaxes); ok {
+			continue
+		}
+		if fix, ok := fixCommand(rest, syntaxes); ok {
+			typos = append(typos, typo{i + 1, len(prefix) + 1, prefix + fix})
+		}
+	}
+	return typos
+}
+
+// warnTypos warns about the lines of the markdown b looking like mistyped
+// commands.
+func (e *embedder) warnTypos(b []byte) {
+	if e.warnings == nil {
+		return
+	}
+	for _, t := range e.typos(b) {
+		e.warnings(t.line, typoMessage(t))
+	}
+}
+
+func typoMessage(t typo) string {
+	return fmt.Sprintf("not an embedmd command, so it is ignored; did you mean %q?", t.fix)
+}
+
+// fixCommand returns line written as a command in one of the syntaxes, if it
+// looks like a mistyped one.
+func fixCommand(line string, syntaxes []Syntax) (string, bool) {
+	for _, s := range syntaxes {
+		switch s {
+		case LinkSyntax:
+			m := nearLinkCommand.FindStringSubmatch(line)
+			if m == nil || m[2] == "" || !nearEmbedmd(m[1], true) {
+				continue
+			}
+			// Other labels close to embedmd are only taken for commands
+			// when followed by arguments, so links such as
+			// [embedded]: https://example.com aren't.
+			if normalizedLabel(m[1]) != "embedmd" && !strings.ContainsAny(m[2][:1], "([{") {
+				continue
+			}
+			return "[embedmd]:# " + parenthesizedArgs(m[2]), true
+		case CommentSyntax:
+			m := nearCommentCommand.FindStringSubmatch(line)
+			if m == nil || m[2] == "" || !nearEmbedmd(m[1], false) {
+				continue
+			}
+			return "<!-- embedmd: " + m[2] + " -->", true
+		}
+	}
+	return "", false
+}
+
+// parenthesizedArgs returns the arguments of a mistyped command in
+// parenthesis, replacing brackets or braces, and closing them if needed.
+func parenthesizedArgs(args string) string {
+	switch args[0] {
+	case '(':
+		if !strings.HasSuffix(args, ")") {
+			args += ")"
+		}
+		return args
+	case '[', '{':
+		closing := map[byte]string{'[': "]", '{': "}"}[args[0]]
+		return "(" + strings.TrimSuffix(args[1:], closing) + ")"
+	}
+	return "(" + args + ")"
+}
+
+// normalizedLabel returns label in lower case without separators.
+func normalizedLabel(label string) string {
+	return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.ToLower(label))
+}
+
+// nearEmbedmd reports whether label is embedmd with a typo: at most two
+// letters added, removed, replaced, or swapped, or a different case or
+// separators. With exact, embedmd itself is near, as the rest of the line
+// is then what's mistyped.
+func nearEmbedmd(label string, exact bool) bool {
+	if label == "embedmd" {
+		return exact
+	}
+	n := normalizedLabel(label)
+	return len(n) >= 5 && editDistance(n, "embedmd") <= 2
+}
+
+// editDistance returns the optimal string alignment distance between a and
+// b: the number of bytes inserted, deleted, replaced, or transposed to turn
+// one into the other.
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
@@ -0,0 +1,67 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTypos(t *testing.T) {
+	tc := []struct {
+		name     string
+		in       string
+		syntaxes []Syntax
+		warnings []string
+	}{
+		{name: "command", in: "[embedmd]:# (code.go)\n"},
+		{name: "blank before the hash", in: "[embedmd]: # (code.go)\n",
+			warnings: []string{`1: not an embedmd command, so it is ignored; did you mean "[embedmd]:# (code.go)"?`}},
+		{name: "missing hash", in: "[embedmd]: (code.go /a/ /b/)\n",
+			warnings: []string{`1: not an embedmd command, so it is ignored; did you mean "[embedmd]:# (code.go /a/ /b/)"?`}},
+		{name: "swapped letters", in: "text\n\n[embdemd]:# (code.go)\n",
+			warnings: []string{`3: not an embedmd command, so it is ignored; did you mean "[embedmd]:# (code.go)"?`}},
+		{name: "case", in: "[EmbedMD]:# (code.go)\n",
+			warnings: []string{`1: not an embedmd command, so it is ignored; did you mean "[embedmd]:# (code.go)"?`}},
+		{name: "brackets", in: "[embedmd]: # [code.go]\n",
+			warnings: []string{`1: not an embedmd command, so it is ignored; did you mean "[embedmd]:# (code.go)"?`}},
+		{name: "unclosed", in: "[embedmd] :# (code.go\n",
+			warnings: []string{`1: not an embedmd command, so it is ignored; did you mean "[embedmd]:# (code.go)"?`}},
+		{name: "nested", in: "> [embedmd]: # (code.go)\n",
+			warnings: []string{`1: not an embedmd command, so it is ignored; did you mean "> [embedmd]:# (code.go)"?`}},
+		{name: "comment", in: "<!-- embdmd: code.go -->\n", syntaxes: []Syntax{CommentSyntax},
+			warnings: []string{`1: not an embedmd command, so it is ignored; did you mean "<!-- embedmd: code.go -->"?`}},
+		{name: "comments not recognized", in: "<!-- embdmd: code.go -->\n"},
+		{name: "other link", in: "[embedded]: https://example.com\n[embed]: #anchor\n"},
+		{name: "unrelated label", in: "[example]:# (code.go)\n"},
+		{name: "code block", in: "```markdown\n[embedmd]: # (code.go)\n```\n"},
+		{name: "generated block", in: "<!-- embedmd block start -->\n[embdemd]:# (code.go)\n<!-- embedmd block end -->\n"},
+	}
+	for _, tt := range tc {
+		var out bytes.Buffer
+		var warnings []string
+		err := Process(&out, strings.NewReader(tt.in),
+			WithFetcher(mixedContentProvider{files: map[string][]byte{"code.go": []byte("package main\n")}}),
+			WithSyntaxes(append([]Syntax{LinkSyntax}, tt.syntaxes...)...),
+			WithWarnings(func(line int, msg string) { warnings = append(warnings, fmt.Sprintf("%d: %s", line, msg)) }))
+		if err != nil {
+			t.Errorf("case [%s]: %v", tt.name, err)
+		}
+		if fmt.Sprint(warnings) != fmt.Sprint(tt.warnings) {
+			t.Errorf("case [%s]: expected warnings %q; got %q", tt.name, tt.warnings, warnings)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// A Version is a version of the sources documented, such as a supported
+// major version or the main branch.
+type Version struct {
+	// Name is the name of the version, such as v2.
+	Name string
+	// Ref is the git revision of the sources at the version, such as a tag
+	// or a branch.
+	Ref string
+}
+
+// WithVersions checks that the content embedded from every local file is
+// the same at each of the given versions, as when a single docs tree
+// documents several supported versions. Content differing across versions
+// is reported as a FindingVersions, an error unless its severity is lowered
+// with WithSeverity, or with a suppression comment before the commands
+// expected to differ.
+func WithVersions(versions ...Version) Option {
+	return Option{func(e *embedder) { e.versions = append(e.versions, versions...) }}
+}
+
+// atRevision returns the path fetched to embed the local file at path as it
+// is at the revision rev.
+func atRevision(path, rev string) string {
+	if rev == "" || isURL(path) || isGitPath(path) || isRepoPath(path) || isExecPath(path) {
+		return path
+	}
+	return gitScheme + path + "@" + rev
+}
+
+// checkVersions reports, as a FindingVersions, the content embedded by cmd,
+// in the block of top, that differs across the versions set with
+// WithVersions, or can't be embedded at one of them.
+func (e *embedder) checkVersions(cmd, top *command) error {
+	if len(e.versions) < 2 || atRevision(cmd.path, "HEAD") == cmd.path {
+		return nil
+	}
+	sev := e.severity(top, FindingVersions)
+	if sev == SeverityIgnore {
+		return nil
+	}
+	var first []byte
+	var msg string
+	for i, v := range e.versions {
+		b, err := FetchContext(e.context(), e.Fetcher, e.baseDir, atRevision(cmd.path, v.Ref))
+		if err == nil {
+			b, err = extractContent(cmd, bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n")))
+		}
+		if err != nil {
+			msg = fmt.Sprintf("cannot embed %s at version %s: %v", cmd.path, v.Name, err)
+			break
+		}
+		if i == 0 {
+			first = b
+		} else if !bytes.Equal(b, first) {
+			msg = fmt.Sprintf("%s embeds different content at versions %s and %s", cmd.path, e.versions[0].Name, v.Name)
+			break
+		}
+	}
+	switch {
+	case msg == "":
+		return nil
+	case sev == SeverityError:
+		return errors.New(msg)
+	}
+	e.warnf(top, "%s", msg)
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestVersions(t *testing.T) {
+	files := map[string]string{
+		"code.go":            "package main\n\nfunc main() {}\n\nfunc Run() {}\n",
+		"git://code.go@v1":   "package main\n\nfunc main() {}\n\nfunc Run(n int) {}\n",
+		"git://code.go@main": "package main\n\nfunc main() {}\n\nfunc Run() {}\n",
+		"git://code.go@v0":   "package main\n",
+	}
+	fetcher := FetcherFunc(func(dir, path string) ([]byte, error) {
+		if b, ok := files[path]; ok {
+			return []byte(b), nil
+		}
+		return nil, os.ErrNotExist
+	})
+	v0, v1, main := Version{"v0", "v0"}, Version{"v1", "v1"}, Version{"main", "main"}
+	tc := []struct {
+		name, in, out string
+		versions      []Version
+		warns         []string
+		err           string
+	}{
+		{name: "same", in: "[embedmd]:# (code.go /func main/ /}/)\n", versions: []Version{v1, main},
+			out: "[embedmd]:# (code.go /func main/ /}/)\n```go\nfunc main() {}\n```\n"},
+		{name: "different", in: "[embedmd]:# (code.go /func Run/ $)\n", versions: []Version{v1, main},
+			err: "1: code.go embeds different content at versions v1 and main"},
+		{name: "missing at a version", in: "[embedmd]:# (code.go /func main/ /}/)\n", versions: []Version{v0, main},
+			err: "1: cannot embed code.go at version v0: could not match \"/func main/\""},
+		{name: "expected to differ", in: "<!-- embedmd:ignore-next versions -->\n[embedmd]:# (code.go /func Run/ $)\n", versions: []Version{v1, main},
+			out:   "<!-- embedmd:ignore-next versions -->\n[embedmd]:# (code.go /func Run/ $)\n```go\nfunc Run() {}\n```\n",
+			warns: []string{"2: code.go embeds different content at versions v1 and main"}},
+	}
+	for _, tt := range tc {
+		var warns []string
+		opts := []Option{
+			WithFetcher(fetcher),
+			WithVersions(tt.versions...),
+			WithWarnings(func(line int, msg string) { warns = append(warns, fmt.Sprintf("%d: %s", line, msg)) }),
+		}
+		var out bytes.Buffer
+		err := Process(&out, strings.NewReader(tt.in), opts...)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected output\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+		if fmt.Sprint(warns) != fmt.Sprint(tt.warns) {
+			t.Errorf("case [%s]: expected warnings %q, got %q", tt.name, tt.warns, warns)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// A Workspace is a temporary directory where the intermediate files needed
+// to generate content, such as command outputs or downloads, are staged
+// during a run. The directory is only created when first used, and removed
+// by Close unless Keep is set.
+type Workspace struct {
+	// MaxBytes limits the total size of the files written to the workspace.
+	// Zero means no limit.
+	MaxBytes int64
+	// Keep leaves the directory in place when closing the workspace, which
+	// helps debugging.
+	Keep bool
+
+	mu   sync.Mutex
+	dir  string
+	size int64
+}
+
+// WithWorkspace sets the workspace used to stage intermediate files.
+func WithWorkspace(w *Workspace) Option {
+	return Option{func(e *embedder) { e.workspace = w }}
+}
+
+// Dir returns the directory of the workspace, creating it if needed.
+func (w *Workspace) Dir() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.mkdir()
+}
+
+func (w *Workspace) mkdir() (string, error) {
+	if w.dir != "" {
+		return w.dir, nil
+	}
+	dir, err := os.MkdirTemp("", "embedmd-")
+	if err != nil {
+		return "", fmt.Errorf("could not create workspace: %v", err)
+	}
+	w.dir = dir
+	return dir, nil
+}
+
+// WriteFile writes data to the file with the given slash separated name in
+// the workspace, returning its path. It fails if the file would make the
+// workspace exceed MaxBytes.
+func (w *Workspace) WriteFile(name string, data []byte) (string, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return "", fmt.Errorf("bad workspace file name %q", name)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.MaxBytes > 0 && w.size+int64(len(data)) > w.MaxBytes {
+		return "", fmt.Errorf("writing %s would exceed the workspace limit of %d bytes", name, w.MaxBytes)
+	}
+	dir, err := w.mkdir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+	w.size += int64(len(data))
+	return path, nil
+}
+
+// Close removes the workspace directory, unless Keep is set. It returns the
+// path of the directory that was kept, if any.
+func (w *Workspace) Close() (kept string, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.dir == "" {
+		return "", nil
+	}
+	if w.Keep {
+		return w.dir, nil
+	}
+	err = os.RemoveAll(w.dir)
+	w.dir, w.size = "", 0
+	return "", err
+}
@@ -0,0 +1,71 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspace(t *testing.T) {
+	w := &Workspace{MaxBytes: 10}
+	if kept, err := w.Close(); kept != "" || err != nil {
+		t.Fatalf("closing an unused workspace: %q, %v", kept, err)
+	}
+
+	path, err := w.WriteFile("out/a.txt", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := w.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "out", "a.txt"); path != want {
+		t.Errorf("expected path %s; got %s", want, path)
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "hello" {
+		t.Errorf("expected file with hello; got %q, %v", b, err)
+	}
+
+	eqErr(t, "over the limit", func() error { _, err := w.WriteFile("b.txt", []byte("world!")); return err }(),
+		"writing b.txt would exceed the workspace limit of 10 bytes")
+	for _, name := range []string{"", ".", "../x", "/x", "a//b"} {
+		_, err := w.WriteFile(name, nil)
+		eqErr(t, name, err, "bad workspace file name \""+name+"\"")
+	}
+
+	if kept, err := w.Close(); kept != "" || err != nil {
+		t.Fatalf("closing the workspace: %q, %v", kept, err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed; got %v", dir, err)
+	}
+}
+
+func TestWorkspaceKeep(t *testing.T) {
+	w := &Workspace{Keep: true}
+	dir, err := w.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if kept, err := w.Close(); kept != dir || err != nil {
+		t.Fatalf("expected %s to be kept; got %q, %v", dir, kept, err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("expected %s to be kept; got %v", dir, err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// runExplain implements the explain command, printing what a command
+// selects in its source, to debug its regular expressions.
+func runExplain(args []string) int {
+	fs := flag.NewFlagSet("embedmd explain", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd explain [flags] '(file.go /start/ /end/)' | file.md:line\n")
+		fs.PrintDefaults()
+	}
+	o := newCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := setup(fs, o); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	opts, err := o.embedOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	cmd, docOpts, err := explainedCommand(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	x, err := embedmd.Explain(cmd, append(docOpts, opts...)...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	printExplanation(stdout, x)
+	if x.Err != nil {
+		return 1
+	}
+	return 0
+}
+
+// explainedCommand returns the command given to explain, either as is or as
+// file.md:line, the line of a document holding it, with the options
+// resolving its paths from the document.
+func explainedCommand(arg string) (string, []embedmd.Option, error) {
+	i := strings.LastIndex(arg, ":")
+	line, err := strconv.Atoi(arg[i+1:])
+	if i < 0 || err != nil || !isDocument(arg[:i]) {
+		return arg, nil, nil
+	}
+	path := arg[:i]
+	b, err := readFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	lines := strings.Split(string(b), "\n")
+	if line < 1 || line > len(lines) {
+		return "", nil, fmt.Errorf("%s has no line %d", filepath.ToSlash(path), line)
+	}
+	opts := []embedmd.Option{embedmd.WithBaseDir(filepath.Dir(path)), embedmd.WithFormat(embedmd.FormatOf(path))}
+	return strings.TrimSuffix(lines[line-1], "\r"), opts, nil
+}
+
+// printExplanation prints what the explained command selects, and why its
+// regular expressions match nothing, when they don't.
+func printExplanation(w io.Writer, x *embedmd.Explanation) {
+	fmt.Fprintf(w, "source: %s (%d lines)\n", x.Path, strings.Count(string(x.Source), "\n"))
+	for i, m := range x.Matches {
+		name := "start"
+		if i > 0 || m.From > 0 {
+			name = "end"
+		}
+		fmt.Fprintf(w, "%s %s: ", name, m.Expr)
+		if m.From > 0 {
+			fmt.Fprintf(w, "searched from line %d, ", strings.Count(string(x.Source[:m.From]), "\n")+1)
+		}
+		switch len(m.Lines) {
+		case 0:
+			fmt.Fprintln(w, "no match")
+		case 1:
+			fmt.Fprintf(w, "matches bytes %d-%d, on line %d\n", m.Start, m.End, m.Lines[0])
+		default:
+			lines := make([]string, len(m.Lines))
+			for i, l := range m.Lines {
+				lines[i] = strconv.Itoa(l)
+			}
+			fmt.Fprintf(w, "matches bytes %d-%d, on line %d, first of %d matches on lines %s\n", m.Start, m.End, m.Lines[0], len(m.Lines), strings.Join(lines, ", "))
+		}
+		for _, h := range m.Hints {
+			fmt.Fprintf(w, "  hint: %s\n", h)
+		}
+	}
+	switch {
+	case x.FromLine > 0 && x.FromLine == x.ToLine:
+		fmt.Fprintf(w, "selected: line %d\n", x.FromLine)
+	case x.FromLine > 0:
+		fmt.Fprintf(w, "selected: lines %d-%d\n", x.FromLine, x.ToLine)
+	}
+	if x.Err != nil {
+		fmt.Fprintf(w, "error: %v\n", x.Err)
+		return
+	}
+	fmt.Fprintf(w, "content:\n%s", x.Content)
+}
@@ -0,0 +1,66 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExplainCommand(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("code.go", "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n")
+	write("doc.md", "# Doc\n\n[embedmd]:# (code.go /func main/ /^ }/)\n\n[embedmd]:# (code.go /fmt/)\n")
+
+	var out bytes.Buffer
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	stdout, stderr = &out, &out
+
+	if code := runExplain([]string{filepath.Join(dir, "doc.md") + ":3"}); code != 1 {
+		t.Errorf("expected exit code 1; got %d", code)
+	}
+	want := "source: code.go (7 lines)\n" +
+		"start /func main/: matches bytes 28-37, on line 5\n" +
+		"end /^ }/: searched from line 5, no match\n" +
+		"error: could not extract content from code.go: could not match \"/^ }/\"\n"
+	if out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+
+	out.Reset()
+	if code := runExplain([]string{filepath.Join(dir, "doc.md") + ":5"}); code != 0 {
+		t.Errorf("expected exit code 0; got %d", code)
+	}
+	want = "source: code.go (7 lines)\n" +
+		"start /fmt/: matches bytes 22-25, on line 3, first of 2 matches on lines 3, 6\n" +
+		"selected: line 3\n" +
+		"content:\nfmt\n"
+	if out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+
+	out.Reset()
+	if code := runExplain([]string{filepath.Join(dir, "doc.md") + ":9"}); code != 2 || out.String() != filepath.ToSlash(filepath.Join(dir, "doc.md"))+" has no line 9\n" {
+		t.Errorf("expected an error for a missing line; got %d, %q", code, out.String())
+	}
+}
@@ -0,0 +1,825 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// options holds the values of the command line flags.
+type options struct {
+	rewrite, doDiff, printVersion bool
+	check, strip                  bool
+	config, profile               string
+	planPath, applyPath           string
+	reportHTML, reportJSON        string
+	suggestPatches                string
+	summary                       bool
+	suggestCommit, byOwner        bool
+	staged, watch, stdin          bool
+	watchDebounce                 time.Duration
+	codeowners, ownerDir          string
+	notify, notifyLink            string
+	fileIssues, shard             string
+	dedupe, dedupeDir, dedupePath string
+
+	stripLicense, requireAttribution stringList
+	defaults, aliases, env           stringList
+	workers, owners, severities      stringList
+	allow, deny, repos, forges       stringList
+	include, exclude, inputs         stringList
+	allowURLs, tokens, languages     stringList
+	allowExec, execEnv, credentials  stringList
+	execDirs                         stringList
+	execTimeout, execCPU             time.Duration
+	execMemory                       int64
+	execNetwork                      bool
+	skipLabels, onlyLabels           stringList
+	platform                         string
+	baseDir, fence, annotationStyle  string
+	copyButtons                      string
+	copyWithoutPrompts               bool
+	runnableCommands                 bool
+	stampOut                         string
+	ariaLabels, lintA11y, checksums  bool
+	managed                          bool
+	incremental                      bool
+	extractCache                     bool
+	extractCacheDir                  string
+	lintAnchors                      bool
+	normalizeFences, fenceIndented   bool
+	editLinks, attribution           bool
+	editRef, syntax                  string
+	templateRegions, keepTemp        bool
+	tempLimit                        int64
+	lfsLimit                         int64
+	initSubmodules                   bool
+	signAWS, googleAuth              bool
+	strictContentType                bool
+	charset                          string
+	ipv4, ipv6                       bool
+	unixSocket, socks5, proxy        string
+	caFile, clientCert, clientKey    string
+	insecureSkipVerify               bool
+	httpsOnly, publicOnly            bool
+	maxFetchSize                     int64
+	contentTypes                     stringList
+	keepStale, markStale, draft      bool
+	annotate                         bool
+	store, refresh                   bool
+	storeDir                         string
+	storeTTL                         time.Duration
+	noCache, recheck                 bool
+	cacheDir                         string
+	cacheTTL                         time.Duration
+	retries                          int
+	retryBackoff, timeout            time.Duration
+	rateLimitWait                    time.Duration
+	rateLimit                        float64
+	langConfidence                   float64
+	maxSelection                     int
+	sourceArchive                    string
+	pin, verify                      bool
+	lint                             bool
+	lintFormat                       string
+	docVersions                      stringList
+	checkVersions                    bool
+	versionsOut                      string
+	deterministic                    bool
+	quarantine                       string
+	quarantineDays                   int
+	now, dumpState                   string
+
+	// clock is the time used as the current time if not zero, set by now.
+	clock time.Time
+	// sources tells where each flag set comes from, as resolveFlags.
+	sources map[string]string
+
+	// stamp records the inputs of the run when stampOut is set.
+	stamp *stamp
+	// lock records the remote sources of the run when lockPath is set.
+	lockPath string
+	frozen   bool
+	lock     *lockFile
+	// root, if set, confines the local files fetched to its tree, for the
+	// documents of workers.
+	root string
+}
+
+// cliOnly lists the flags that can't be set from the config file.
+var cliOnly = map[string]bool{
+	"w": true, "d": true, "v": true, "config": true, "profile": true, "resume": true, "force": true,
+	"plan": true, "apply": true, "refresh": true, "report-html": true, "check": true,
+	"report-json": true, "shard": true, "strip": true, "staged": true, "watch": true,
+	"stdin": true, "stdin-path": true, "dump-state": true,
+	// Only those running embedmd choose the programs it runs.
+	"allow-exec": true, "exec-env": true, "exec-dir": true, "exec-network": true,
+}
+
+// runOnly lists the flags choosing what the main command does with the files
+// it embeds, which subcommands don't define.
+var runOnly = map[string]bool{
+	"w": true, "d": true, "check": true, "v": true, "plan": true, "apply": true, "workers": true, "keep-temp": true, "temp-limit": true,
+	"report-html": true, "suggest-patches": true, "report-json": true, "by-owner": true,
+	"codeowners": true, "owner": true, "owner-dir": true, "notify": true, "notify-link": true,
+	"file-issues": true, "shard": true, "include": true, "exclude": true, "input": true,
+	"dedupe": true, "dedupe-dir": true, "dedupe-path": true, "lint": true, "lint-format": true,
+	"versions-out": true, "quarantine": true, "quarantine-days": true, "dump-state": true,
+	"verify": true, "stamp-out": true, "word-diff": true, "summary": true, "color": true,
+	"journal": true, "resume": true, "transactional": true, "source-map": true,
+	"verify-idempotent": true, "suggest-commit": true, "i": true, "stdin": true,
+	"stdin-path": true, "staged": true, "watch": true, "watch-debounce": true,
+	"require-clean": true, "force": true,
+}
+
+// noEnv lists the flags that can't be set from the environment.
+var noEnv = map[string]bool{"w": true, "d": true, "v": true, "resume": true, "force": true, "plan": true, "apply": true, "refresh": true, "report-html": true, "check": true, "report-json": true, "strip": true, "staged": true, "watch": true, "stdin": true, "stdin-path": true, "dump-state": true, "allow-exec": true, "exec-env": true, "exec-dir": true, "exec-network": true}
+
+// newFlags defines the embedmd flags in fs, returning the options they set.
+func newFlags(fs *flag.FlagSet) *options {
+	o := new(options)
+	fs.BoolVar(&o.rewrite, "w", false, "write result to (markdown) file instead of stdout")
+	fs.BoolVar(&o.doDiff, "d", false, "display diffs instead of rewriting files")
+	fs.BoolVar(&o.check, "check", false, "like -d, for CI: print the diffs of the files out of date and list them, exiting with status 2 if any")
+	fs.BoolVar(&o.strip, "strip", false, "remove the blocks generated after commands instead of embedding them, keeping what's needed to embed them again as they were")
+	fs.BoolVar(&o.printVersion, "v", false, "display embedmd version")
+	fs.StringVar(&o.planPath, "plan", "", "write the changes rewriting the files would make to this file, or - for stdout, instead of rewriting them")
+	fs.StringVar(&o.applyPath, "apply", "", "rewrite the files as recorded in this plan, written by -plan")
+	fs.Var(&o.workers, "workers", "with -plan, URL of a worker planning the files, started with 'embedmd worker' (repeatable)")
+	fs.StringVar(&o.reportHTML, "report-html", "", "write an HTML report with the side by side diff of the pending changes to this file, instead of rewriting them")
+	fs.StringVar(&o.suggestPatches, "suggest-patches", "", "with -checksums, write to this file the patches to the sources implementing the edits made by hand to the blocks embedding them")
+	fs.StringVar(&o.reportJSON, "report-json", "", "with -d, write the stale blocks, with the last authors of their commands and sources from git blame, to this JSON file")
+	fs.BoolVar(&o.byOwner, "by-owner", false, "with -d, list the stale files grouped by their owners in CODEOWNERS instead of printing their diffs")
+	fs.StringVar(&o.codeowners, "codeowners", "", "CODEOWNERS file used by -by-owner, defaults to the first found in "+strings.Join(codeownersFiles, ", "))
+	fs.Var(&o.owners, "owner", "with -by-owner, owners of the files matching a pattern, as 'pattern owner ...', overriding CODEOWNERS (repeatable)")
+	fs.StringVar(&o.ownerDir, "owner-dir", "", "with -by-owner, write the diffs of the stale files of each owner to a file in this directory")
+	fs.StringVar(&o.notify, "notify", "", "with -d, post a summary of the run to this Slack or Teams compatible webhook URL")
+	fs.StringVar(&o.notifyLink, "notify-link", "", "link to the report of the run, e.g. a CI artifact, included in the -notify summary")
+	fs.StringVar(&o.fileIssues, "file-issues", "", "with -d, open or update an issue with the diff of each stale file, mentioning its owners in CODEOWNERS, in this GitHub or GitLab project, as host/owner/repo")
+	fs.StringVar(&o.shard, "shard", "", "only process the shard i of n, as i/n, of the files found, so n parallel jobs process every file once")
+	fs.Var(&o.include, "include", "only process the files found in directories or by glob patterns that match this gitignore style pattern (repeatable)")
+	fs.Var(&o.exclude, "exclude", "skip the files found in directories or by glob patterns that match this gitignore style pattern (repeatable)")
+	fs.Var(&o.inputs, "input", "file, directory, or glob pattern processed when none is given as argument (repeatable)")
+	fs.StringVar(&o.baseDir, "base-dir", "", "directory relative paths in commands are resolved from, instead of the directory of each file")
+	fs.StringVar(&o.config, "config", "", "config file, defaults to the closest "+configFile+" in the current directory or its parents")
+	fs.StringVar(&o.profile, "profile", "", "profile of the config file to use")
+	fs.Var(&o.stripLicense, "strip-license", "strip license headers from sources matching the pattern (repeatable)")
+	fs.Var(&o.requireAttribution, "require-attribution", "require a caption on embeds of sources matching the pattern (repeatable)")
+	fs.Var(&o.defaults, "defaults", "default attributes for sources matching a pattern, as 'pattern key=value ...' (repeatable)")
+	fs.Var(&o.aliases, "alias", "alias for a path prefix in commands, as '@name=path' (repeatable)")
+	fs.Var(&o.env, "env", "variable referenced as ${NAME} in the paths and attributes of commands, as NAME to read it from the environment or NAME=value (repeatable)")
+	fs.Var(&o.repos, "repo", "git repository embedded with repo://name/path, as 'name=github.com/org/repo@ref' (repeatable)")
+	fs.Var(&o.forges, "forge", "self-hosted forge, as 'host=kind [raw=template] [clone=template] [wiki=template]', where kind is github, gitlab, gitea, bitbucket, or bitbucket-cloud (repeatable)")
+	fs.Var(&o.allowExec, "allow-exec", "allow commands such as cmd:\"kubectl explain deployment\" to embed the output of the programs whose command line starts with this one (repeatable)")
+	fs.Var(&o.execEnv, "exec-env", "environment variable passed to the programs run with -allow-exec, besides PATH, HOME, and the locale (repeatable)")
+	fs.DurationVar(&o.execTimeout, "exec-timeout", time.Minute, "how long the programs run with -allow-exec can run")
+	fs.Var(&o.execDirs, "exec-dir", "directory under which the programs run with -allow-exec can run, instead of the current one (repeatable)")
+	fs.BoolVar(&o.execNetwork, "exec-network", false, "let the programs run with -allow-exec use the network, which they can only be cut from on Linux")
+	fs.DurationVar(&o.execCPU, "exec-cpu", 0, "CPU time the programs run with -allow-exec can use, -exec-timeout if 0")
+	fs.Int64Var(&o.execMemory, "exec-memory", 2048, "memory in MiB the programs run with -allow-exec can use")
+	fs.Var(&o.allowURLs, "allow-url", "only fetch the URLs matching this pattern, as in -strip-license, after forge pages are mapped to raw URLs (repeatable)")
+	fs.Var(&o.tokens, "token", "bearer token sent to the URLs matching a pattern, as 'pattern=ENV_VAR', read from the environment variable ENV_VAR (repeatable)")
+	fs.Var(&o.credentials, "credential", "credential sent to the hosts matching a pattern, as 'host=ENV_VAR [header=name]', read from the environment variable ENV_VAR, as a bearer token unless a header is given (repeatable)")
+	fs.Var(&o.allow, "policy-allow", "only allow the commands for which one of these CEL expressions is true (repeatable)")
+	fs.Var(&o.deny, "policy-deny", "deny the commands for which this CEL expression is true (repeatable)")
+	fs.BoolVar(&o.ariaLabels, "aria-labels", false, "wrap embedded code in HTML regions labeled for screen readers")
+	fs.StringVar(&o.copyButtons, "copy-buttons", "", "wrap embedded code in the HTML of a copy to clipboard button, in the markup of bootstrap or clipboardjs")
+	fs.BoolVar(&o.copyWithoutPrompts, "copy-without-prompts", false, "with -copy-buttons, copy only the commands of console blocks, without their $ prompts and output")
+	fs.BoolVar(&o.runnableCommands, "runnable-commands", false, "wrap embedded console blocks in HTML holding their commands, without prompts or output, in a data-commands attribute")
+	fs.BoolVar(&o.lintA11y, "lint-a11y", false, "warn about embedded code without a caption")
+	fs.BoolVar(&o.lintAnchors, "lint-anchors", false, "warn about regular expressions that could select the wrong lines as sources change, suggesting stronger ones")
+	fs.BoolVar(&o.checksums, "checksums", false, "add a checksum after embedded blocks to detect hand edits")
+	fs.BoolVar(&o.managed, "managed", false, "wrap the embedded blocks between comments, with their checksums, keeping the edits made by hand to them merged with the changes of their sources, and failing when both change the same lines, unless -force")
+	fs.BoolVar(&o.extractCache, "extract-cache", false, "cache the code extracted from local files, reusing it while the files and the commands are unchanged, unless -force")
+	fs.StringVar(&o.extractCacheDir, "extract-cache-dir", "", "directory of the extract cache, defaults to embedmd/extract in the user cache directory")
+	fs.BoolVar(&o.incremental, "incremental", false, "with -checksums, only embed again the blocks whose commands changed since they were generated, without fetching the sources of the others")
+	fs.Var(&o.languages, "lang", "language of the code embedded from files with an extension or a name, as 'ext=lang' or 'name=lang', when commands don't set it (repeatable)")
+	fs.IntVar(&o.maxSelection, "max-selection", 1000, "warn about commands selecting more lines than this of their source, quoting the first and last ones, without limit if 0")
+	fs.Float64Var(&o.langConfidence, "lang-confidence", 0.5, "minimum confidence, from 0 to 1, of the language detected from the content of files without an extension, failing the commands below it")
+	fs.StringVar(&o.syntax, "syntax", "link", "forms of the commands recognized, comma separated: link for [embedmd]:# (args), comment for <!-- embedmd: args -->")
+	fs.StringVar(&o.fence, "fence", "", "fence of the code blocks generated, at least three backticks or tildes, instead of ```, or mdx for <CodeBlock> components")
+	fs.StringVar(&o.annotationStyle, "annotation-style", "", "syntax of the line numbers and highlighted lines set with linenos and hl in fences: docusaurus (default), hugo, or mkdocs")
+	fs.StringVar(&o.dedupe, "dedupe", "", "with -w, replace the code blocks repeated across files with references to shared files, in the include syntax of jekyll or mkdocs")
+	fs.StringVar(&o.dedupeDir, "dedupe-dir", "", "with -dedupe, directory of the shared files, defaults to _includes/embedmd for jekyll and snippets for mkdocs")
+	fs.StringVar(&o.dedupePath, "dedupe-path", "", "with -dedupe, path of the shared files in references, when the renderer doesn't resolve -dedupe-dir as is")
+	fs.BoolVar(&o.editLinks, "edit-links", false, "add a link after each embedded block to edit its sources on the forge of the origin remote of the repository")
+	fs.StringVar(&o.editRef, "edit-ref", "", "with -edit-links, branch edited by the links, instead of the branch checked out")
+	fs.BoolVar(&o.attribution, "attribution", false, "link the footers of the blocks embedded with attribution=link to the commit checked out, on the forge of the origin remote of the repository")
+	fs.BoolVar(&o.normalizeFences, "normalize-fences", false, "replace indented or tilde fenced code blocks after commands")
+	fs.BoolVar(&o.fenceIndented, "fence-indented", false, "convert indented code blocks after commands to fenced ones")
+	fs.BoolVar(&o.templateRegions, "template-regions", false, "leave commands in Liquid or Jinja raw regions and paired Hugo shortcodes untouched")
+	fs.BoolVar(&o.keepTemp, "keep-temp", false, "keep the temporary workspace of the run, for debugging")
+	fs.Int64Var(&o.tempLimit, "temp-limit", 256, "maximum size in MiB of the temporary workspace, 0 for no limit")
+	fs.BoolVar(&o.initSubmodules, "init-submodules", false, "initialize the git submodules holding the files embedded that aren't initialized, instead of failing")
+	fs.Int64Var(&o.lfsLimit, "lfs-max-size", 10, "maximum size in MiB of the Git LFS objects embedded in place of their pointers, 0 for no limit")
+	fs.BoolVar(&o.signAWS, "sign-aws", false, "sign requests to *.amazonaws.com with the AWS credentials of the environment")
+	fs.BoolVar(&o.googleAuth, "google-auth", false, "add Google credentials to requests to Google APIs, Cloud Run, and Cloud Functions")
+	fs.BoolVar(&o.strictContentType, "strict-content-type", false, "fail instead of warning when a URL returns an HTML page rather than code")
+	fs.StringVar(&o.charset, "charset", "", "charset of remote content, overriding the one declared by the server")
+	fs.BoolVar(&o.ipv4, "ipv4", false, "only connect to servers over IPv4")
+	fs.BoolVar(&o.ipv6, "ipv6", false, "only connect to servers over IPv6")
+	fs.StringVar(&o.unixSocket, "unix-socket", "", "send every request to the unix socket at this path")
+	fs.StringVar(&o.socks5, "socks5", "", "send requests through the SOCKS5 proxy at this URL, as socks5://host:port")
+	fs.StringVar(&o.proxy, "proxy", "", "send requests through the proxy at this URL, as http://, https://, or socks5://host:port, instead of the one of the environment")
+	fs.StringVar(&o.caFile, "ca-file", "", "PEM bundle of the certificate authorities to trust in addition to those of the system")
+	fs.StringVar(&o.clientCert, "client-cert", "", "PEM file of the client certificate presented to the servers asking for one, with -client-key")
+	fs.StringVar(&o.clientKey, "client-key", "", "PEM file of the key of -client-cert")
+	fs.BoolVar(&o.insecureSkipVerify, "insecure-skip-verify", false, "accept any server certificate, for testing only")
+	fs.BoolVar(&o.httpsOnly, "https-only", false, "refuse to fetch http:// URLs, including through redirects")
+	fs.BoolVar(&o.publicOnly, "public-only", false, "refuse to connect to loopback, private, and link-local addresses")
+	fs.Int64Var(&o.maxFetchSize, "max-fetch-size", 0, "maximum size in MiB of the content of a URL, without limit if 0")
+	fs.Var(&o.contentTypes, "content-type", "only embed URLs returning this media type, such as text/plain or text/*, or without a Content-Type (repeatable)")
+	fs.BoolVar(&o.keepStale, "keep-stale-on-error", false, "keep the previous content, with a warning, when a remote source can't be fetched")
+	fs.BoolVar(&o.markStale, "mark-stale", false, "with -keep-stale-on-error, add a comment after the blocks that were kept")
+	fs.BoolVar(&o.draft, "draft", false, "embed a placeholder for the sources that can't be found instead of failing")
+	fs.BoolVar(&o.annotate, "annotate", false, "write after each embedded block a note of its sources and whether it changed, for review in a preview build, removed by runs without it")
+	fs.Var(&o.skipLabels, "skip-label", "keep as is the blocks of commands with this label, set with the label attribute (repeatable)")
+	fs.Var(&o.onlyLabels, "only-label", "keep as is the blocks of commands without any of these labels (repeatable)")
+	fs.StringVar(&o.platform, "platform", "", "render the docs for this platform, posix, windows, or the GOOS of a Unix-like system, selecting the commands with its goos and normalizing their content")
+	fs.StringVar(&o.lockPath, "lockfile", "", "record the URL, revision, and hash of the remote sources embedded in this lock file, such as embedmd.lock")
+	fs.BoolVar(&o.frozen, "frozen", false, "with -lockfile, fail if a remote source embedded doesn't match the lock file, which is left unchanged")
+	fs.StringVar(&o.stampOut, "stamp-out", "", "write the files and URLs read by the run, with their hashes, to this JSON file")
+	fs.BoolVar(&o.store, "store", false, "keep remote content in a store shared by every run on the machine")
+	fs.StringVar(&o.storeDir, "store-dir", "", "directory of the store, defaults to embedmd/store in the user cache directory")
+	fs.DurationVar(&o.storeTTL, "store-ttl", 24*time.Hour, "how long remote content is served from the store before it's fetched again")
+	fs.StringVar(&o.sourceArchive, "source-archive", "", "read the files embedded from this zip, tar, or gzipped tar archive instead of the disk, or from stdin if -")
+	fs.BoolVar(&o.noCache, "no-cache", false, "fetch remote content without the HTTP cache")
+	fs.StringVar(&o.cacheDir, "cache-dir", "", "directory of the HTTP cache, defaults to embedmd/http in the user cache directory")
+	fs.DurationVar(&o.cacheTTL, "cache-ttl", 0, "how long cached remote content is used without asking the server whether it changed")
+	fs.BoolVar(&o.recheck, "recheck", true, "with -d, fetch again without caches the remote content of stale blocks before reporting them, when -cache-ttl or -store is set")
+	fs.IntVar(&o.retries, "retries", 0, "number of times requests failing with a network error or a transient status are retried")
+	fs.DurationVar(&o.retryBackoff, "retry-backoff", time.Second, "with -retries, how long to wait before the first retry, doubled before each of the next ones")
+	fs.DurationVar(&o.timeout, "timeout", 0, "how long each request for remote content can take, without limit if 0")
+	fs.Float64Var(&o.rateLimit, "rate-limit", 0, "maximum number of requests per second to each host, without limit if 0")
+	fs.DurationVar(&o.rateLimitWait, "rate-limit-wait", time.Minute, "how long requests wait for the rate limit announced by their host, e.g. in X-RateLimit headers, to reset once exhausted, failing if it resets later")
+	fs.Var(&o.severities, "severity", "with -d, severity of a finding, as 'finding=level', where finding is stale, maxage, or versions and level is error, warning, or ignore (repeatable)")
+	fs.BoolVar(&o.pin, "pin", false, "record the sha256 checksum of the content of the commands embedding a URL, and update those already pinned")
+	fs.BoolVar(&o.verify, "verify", false, "only check that the content of the commands pinned with sha256 still matches, without changing anything")
+	fs.BoolVar(&o.lint, "lint", false, "only check the commands, without fetching their sources, reporting malformed ones and missing files as file:line:column")
+	fs.StringVar(&o.lintFormat, "lint-format", "text", "with -lint, format of the problems reported: text or json")
+	fs.Var(&o.docVersions, "doc-version", "version of the sources documented, as 'name=ref', where ref is a git revision such as a tag or a branch (repeatable)")
+	fs.BoolVar(&o.checkVersions, "check-versions", false, "fail if content embedded from a local file differs across the versions set with -doc-version")
+	fs.StringVar(&o.versionsOut, "versions-out", "", "render the docs for each version set with -doc-version in a directory of this directory named after the version")
+	fs.BoolVar(&o.deterministic, "deterministic", false, "make the run reproducible, for bug reports: use -now as the current time, and fetch remote content without the HTTP cache and the store, and code without the extract cache")
+	fs.StringVar(&o.now, "now", "", "use this time, as a 2006-01-02 date, an RFC 3339 time, or Unix seconds, as the current time, as when checking maxage, defaulting to SOURCE_DATE_EPOCH with -deterministic")
+	fs.StringVar(&o.dumpState, "dump-state", "", "write the flags set and the commands of the files, anonymized, to this JSON file, or - for stdout, to attach to a bug report, instead of processing the files")
+	fs.StringVar(&o.quarantine, "quarantine", "", "JSON file of known-broken embeds, by file and command fingerprint, whose failures are reported as warnings until their expiry date")
+	fs.IntVar(&o.quarantineDays, "quarantine-days", 0, "with -quarantine, add the embeds failing to the file, quarantined for this many days, instead of failing")
+	fs.BoolVar(&o.refresh, "refresh", false, "record today as the refresh date of the blocks with a maxage attribute")
+	fs.BoolVar(&wordDiffs, "word-diff", false, "with -d, show changed words inside of changed lines")
+	fs.BoolVar(&o.summary, "summary", false, "with -d, print a status line for each command whose block would change instead of the diffs")
+	fs.StringVar(&colorMode, "color", "auto", "colorize the output: auto, always, or never")
+	fs.StringVar(&journalPath, "journal", "", "journal recording the progress of -w -resume runs on several files, in the user cache directory by default")
+	fs.BoolVar(&resume, "resume", false, "with -w, skip the files rewritten by an interrupted run")
+	fs.BoolVar(&keepGoing, "keep-going", false, "process all the files and commands when some fail, keeping the blocks that fail as they are and reporting all the errors, and exit with 1 if blocks changed, 3 for commands that can't be parsed, 4 for sources that can't be read, and 5 for other command errors")
+	fs.BoolVar(&transactional, "transactional", false, "with -w, only rewrite the files once all of them have been processed without errors")
+	fs.BoolVar(&sourceMaps, "source-map", false, "with -w, write next to each file a JSON map of the lines embedded in it to the source lines they come from, as file"+sourceMapExt)
+	fs.BoolVar(&verifyIdempotent, "verify-idempotent", false, "process the output of each file again, failing if that changes it, which is a bug of embedmd")
+	fs.BoolVar(&o.suggestCommit, "suggest-commit", false, "with -w, print a commit message listing the files rewritten and the source changes behind them")
+	fs.BoolVar(&interactive, "i", false, "with -w and -checksums, keep the blocks edited by hand, asking how to resolve those whose source changed too")
+	fs.BoolVar(&o.stdin, "stdin", false, "read the document from the standard input and write the result to the standard output, even if -input is set")
+	fs.StringVar(&stdinPath, "stdin-path", "", "path of the document read from the standard input, which isn't read, naming it in messages and setting its format and the default -base-dir")
+	fs.BoolVar(&o.staged, "staged", false, "process the documents staged in git instead of files, staging them again once rewritten by -w")
+	fs.BoolVar(&o.watch, "watch", false, "with -w, keep running, embedding again the documents whose local sources change")
+	fs.DurationVar(&o.watchDebounce, "watch-debounce", 300*time.Millisecond, "with -watch, how long files must stay unchanged before embedding again")
+	fs.BoolVar(&requireClean, "require-clean", false, "with -w, refuse to rewrite files with uncommitted changes")
+	fs.BoolVar(&force, "force", false, "rewrite files with uncommitted changes despite -require-clean, extract the code again despite -extract-cache, and with -managed, overwrite the edits made by hand to blocks whose sources changed the same lines")
+	return o
+}
+
+// newCommandFlags defines in fs the flags of a subcommand, those of embedmd
+// but the runOnly ones, returning the options they set.
+func newCommandFlags(fs *flag.FlagSet) *options {
+	all := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	o := newFlags(all)
+	all.VisitAll(func(f *flag.Flag) {
+		if !runOnly[f.Name] {
+			fs.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	return o
+}
+
+// embedOptions returns the options for embedmd.Process set by the flags.
+func (o *options) embedOptions() ([]embedmd.Option, error) {
+	f, err := o.fetcher()
+	if err != nil {
+		return nil, err
+	}
+	opts := []embedmd.Option{embedmd.WithFetcher(f), embedmd.WithContext(interruptContext)}
+	if o.syntax != "link" {
+		var syntaxes []embedmd.Syntax
+		for _, s := range strings.Split(o.syntax, ",") {
+			syntaxes = append(syntaxes, embedmd.Syntax(strings.TrimSpace(s)))
+		}
+		opts = append(opts, embedmd.WithSyntaxes(syntaxes...))
+	}
+	if o.strip {
+		opts = append(opts, embedmd.WithStrip())
+	}
+	if !o.clock.IsZero() {
+		opts = append(opts, embedmd.WithNow(o.clock))
+	}
+	if o.baseDir != "" {
+		opts = append(opts, embedmd.WithBaseDir(o.baseDir))
+	}
+	for _, p := range o.stripLicense {
+		opts = append(opts, embedmd.WithLicenseRules(embedmd.LicenseRule{Pattern: p, Strip: true}))
+	}
+	for _, p := range o.requireAttribution {
+		opts = append(opts, embedmd.WithLicenseRules(embedmd.LicenseRule{Pattern: p, RequireCaption: true}))
+	}
+	for _, d := range o.defaults {
+		pattern, attrs, _ := strings.Cut(strings.TrimSpace(d), " ")
+		opts = append(opts, embedmd.WithDefaults(pattern, attrs))
+	}
+	for _, a := range o.aliases {
+		name, target, _ := strings.Cut(a, "=")
+		opts = append(opts, embedmd.WithAlias(strings.TrimSpace(name), strings.TrimSpace(target)))
+	}
+	if len(o.env) > 0 {
+		opts = append(opts, embedmd.WithEnv(envVars(o.env)))
+	}
+	forges, err := o.forgeList()
+	if err != nil {
+		return nil, err
+	}
+	if len(forges) > 0 {
+		opts = append(opts, embedmd.WithForges(forges...))
+	}
+	if o.editLinks {
+		l, err := o.editLinkConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, embedmd.WithEditLinks(l))
+	}
+	if o.attribution {
+		a, err := attributionConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, embedmd.WithAttribution(a))
+	}
+	for _, expr := range o.allow {
+		opts = append(opts, embedmd.WithPolicy(embedmd.PolicyRule{Expr: expr}))
+	}
+	for _, expr := range o.deny {
+		opts = append(opts, embedmd.WithPolicy(embedmd.PolicyRule{Expr: expr, Deny: true}))
+	}
+	if o.ariaLabels {
+		opts = append(opts, embedmd.WithAriaLabels())
+	}
+	if o.copyButtons != "" {
+		opts = append(opts, embedmd.WithCopyButtons(embedmd.CopyButtons{Dialect: o.copyButtons, StripPrompts: o.copyWithoutPrompts}))
+	}
+	if o.pin {
+		opts = append(opts, embedmd.WithPin())
+	}
+	if o.checkVersions {
+		versions, err := o.versions()
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) < 2 {
+			return nil, fmt.Errorf("error: -check-versions needs at least two versions set with -doc-version")
+		}
+		opts = append(opts, embedmd.WithVersions(versions...))
+	}
+	if o.runnableCommands {
+		opts = append(opts, embedmd.WithRunnableCommands())
+	}
+	if o.lintA11y {
+		opts = append(opts, embedmd.WithA11yLint())
+	}
+	if o.lintAnchors {
+		opts = append(opts, embedmd.WithAnchorLint())
+	}
+	if o.checksums {
+		opts = append(opts, embedmd.WithChecksums())
+	}
+	if o.managed {
+		opts = append(opts, embedmd.WithManagedBlocks())
+		if force {
+			opts = append(opts, embedmd.WithConflictResolver(func(c embedmd.Conflict) ([]byte, error) { return c.Generated, nil }))
+		}
+	}
+	if o.incremental {
+		opts = append(opts, embedmd.WithIncremental())
+	}
+	if o.extractCache {
+		dir := o.extractCacheDir
+		if dir == "" {
+			// Without a user cache directory, code is extracted uncached.
+			dir, _ = embedmd.DefaultExtractCacheDir()
+		}
+		if dir != "" {
+			opts = append(opts, embedmd.WithExtractCache(&embedmd.ExtractCache{Dir: dir, Refresh: force}))
+		}
+	}
+	if len(o.allowExec) > 0 {
+		opts = append(opts, embedmd.WithExec(o.allowExec...), embedmd.WithExecEnv(o.execEnv...), embedmd.WithExecLimits(o.execTimeout, 0),
+			embedmd.WithExecDirs(o.execDirs...), embedmd.WithExecResources(o.execCPU, o.execMemory<<20))
+		if o.execNetwork {
+			opts = append(opts, embedmd.WithExecNetwork())
+		}
+	}
+	for _, l := range o.languages {
+		ext, lang, _ := strings.Cut(l, "=")
+		opts = append(opts, embedmd.WithLanguage(strings.TrimSpace(ext), strings.TrimSpace(lang)))
+	}
+	if o.maxSelection > 0 {
+		opts = append(opts, embedmd.WithSelectionLimit(o.maxSelection))
+	}
+	if o.langConfidence != 0.5 {
+		opts = append(opts, embedmd.WithLanguageConfidence(o.langConfidence))
+	}
+	if o.fence != "" {
+		opts = append(opts, embedmd.WithFence(o.fence))
+	}
+	if o.annotationStyle != "" {
+		opts = append(opts, embedmd.WithAnnotationStyle(o.annotationStyle))
+	}
+	if o.normalizeFences {
+		opts = append(opts, embedmd.WithNormalizedFences())
+	}
+	if o.fenceIndented {
+		opts = append(opts, embedmd.WithFencedIndentedBlocks())
+	}
+	if o.templateRegions {
+		opts = append(opts, embedmd.WithTemplateRegions())
+	}
+	if o.strictContentType {
+		opts = append(opts, embedmd.WithStrictContentType())
+	}
+	if o.keepStale {
+		opts = append(opts, embedmd.WithKeepStaleOnError())
+	}
+	if o.markStale {
+		opts = append(opts, embedmd.WithStaleMarkers())
+	}
+	if o.draft {
+		opts = append(opts, embedmd.WithDraft())
+	}
+	if keepGoing {
+		opts = append(opts, embedmd.WithKeepGoing())
+	}
+	if o.annotate {
+		opts = append(opts, embedmd.WithReviewNotes())
+	}
+	if len(o.skipLabels) > 0 {
+		opts = append(opts, embedmd.WithSkipLabels(o.skipLabels...))
+	}
+	if len(o.onlyLabels) > 0 {
+		opts = append(opts, embedmd.WithOnlyLabels(o.onlyLabels...))
+	}
+	if o.platform != "" {
+		opts = append(opts, embedmd.WithPlatform(o.platform))
+	}
+	if o.refresh {
+		opts = append(opts, embedmd.WithRefresh())
+	}
+	for _, v := range o.severities {
+		finding, level, _ := strings.Cut(v, "=")
+		sev, err := embedmd.ParseSeverity(strings.TrimSpace(level))
+		if err != nil {
+			return nil, fmt.Errorf("error: -severity: %v", err)
+		}
+		opts = append(opts, embedmd.WithSeverity(strings.TrimSpace(finding), sev))
+	}
+	if o.doDiff {
+		opts = append(opts, embedmd.WithMaxAgeErrors(), embedmd.WithCheck())
+	}
+	if o.doDiff && o.recheck && (o.store || !o.noCache && o.cacheTTL > 0) {
+		f, err := o.uncachedFetcher()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, embedmd.WithRecheck(f))
+	}
+	return opts, nil
+}
+
+// uncachedFetcher returns the fetcher of the flags without the HTTP cache
+// and the store, which doesn't record the sources it fetches either.
+func (o *options) uncachedFetcher() (embedmd.Fetcher, error) {
+	u := *o
+	u.noCache, u.store = true, false
+	u.stampOut, u.lockPath = "", ""
+	return u.fetcher()
+}
+
+// fetcher returns the fetcher with the middleware enabled by the flags.
+func (o *options) fetcher() (embedmd.Fetcher, error) {
+	fopts, err := o.fetcherOptions()
+	if err != nil {
+		return nil, err
+	}
+	if o.ipv4 && o.ipv6 {
+		return nil, fmt.Errorf("error: cannot use -ipv4 and -ipv6 simultaneously")
+	}
+	if o.proxy != "" && o.socks5 != "" {
+		return nil, fmt.Errorf("error: cannot use -proxy and -socks5 simultaneously")
+	}
+	client, err := o.network().Client()
+	if err != nil {
+		return nil, fmt.Errorf("error: %v", err)
+	}
+	rateBudget.MaxWait = o.rateLimitWait
+	client = rateBudget.Client(client)
+	var mw []embedmd.Middleware
+	if o.signAWS {
+		creds, err := embedmd.AWSCredentialsFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("error: -sign-aws: %v", err)
+		}
+		mw = append(mw, embedmd.SigV4Middleware(creds, client, fopts...))
+	}
+	if o.googleAuth {
+		mw = append(mw, embedmd.GoogleMiddleware(client, fopts...))
+	}
+	if o.store {
+		dir, err := o.storeDirectory()
+		if err != nil {
+			return nil, fmt.Errorf("error: -store: %v", err)
+		}
+		mw = append([]embedmd.Middleware{embedmd.StoreMiddleware(&embedmd.Store{Dir: dir, TTL: o.storeTTL})}, mw...)
+	}
+	if o.lockPath != "" {
+		if o.lock, err = loadLock(o.lockPath, o.frozen); err != nil {
+			return nil, fmt.Errorf("error: -lockfile: %v", err)
+		}
+		mw = append([]embedmd.Middleware{o.lock.middleware()}, mw...)
+	}
+	forges, err := o.forgeList()
+	if err != nil {
+		return nil, err
+	}
+	mw = append(mw, embedmd.LFSMiddleware(client, o.lfsLimit<<20, forges...))
+	if len(o.repos) > 0 {
+		var repos []embedmd.Repo
+		for _, v := range o.repos {
+			name, spec, _ := strings.Cut(v, "=")
+			r, err := embedmd.ParseRepo(strings.TrimSpace(name), strings.TrimSpace(spec), forges...)
+			if err != nil {
+				return nil, fmt.Errorf("error: -repo: %v", err)
+			}
+			repos = append(repos, r)
+		}
+		dir, err := o.storeDirectory()
+		if err != nil {
+			return nil, fmt.Errorf("error: -repo: %v", err)
+		}
+		mw = append(mw, embedmd.RepoMiddleware(filepath.Join(dir, "repos"), repos...))
+		if o.lock != nil {
+			o.lock.repoDir = filepath.Join(dir, "repos")
+			for _, r := range repos {
+				o.lock.repos[r.Name] = r
+			}
+		}
+	}
+	if len(o.allowURLs) > 0 {
+		mw = append([]embedmd.Middleware{embedmd.AllowMiddleware(o.allowURLs...)}, mw...)
+	}
+	if o.stampOut != "" {
+		o.stamp = newStamp()
+		mw = append([]embedmd.Middleware{o.stamp.middleware()}, mw...)
+	}
+	for _, t := range o.tokens {
+		pattern, env, _ := strings.Cut(t, "=")
+		token := os.Getenv(strings.TrimSpace(env))
+		if token == "" {
+			return nil, fmt.Errorf("error: -token: environment variable %s of %s is not set", strings.TrimSpace(env), strings.TrimSpace(pattern))
+		}
+		mw = append(mw, embedmd.AuthMiddleware(strings.TrimSpace(pattern), client, func(r *http.Request) error {
+			embedmd.TokenCredential(r.URL.Hostname(), token, forges...).Set(r.Header)
+			return nil
+		}, fopts...))
+	}
+	if o.sourceArchive != "" {
+		fsys, err := o.archiveFS()
+		if err != nil {
+			return nil, err
+		}
+		mw = append(mw, embedmd.FSMiddleware(fsys))
+	}
+	mw = append(mw, embedmd.SubmoduleMiddleware(o.initSubmodules))
+	if o.root != "" {
+		mw = append([]embedmd.Middleware{embedmd.RootMiddleware(o.root)}, mw...)
+	}
+	return embedmd.ChainFetcher(embedmd.NewFetcher(client, fopts...), mw...), nil
+}
+
+// fetcherOptions returns the options of the fetchers of URLs set by the
+// flags, which apply to the authenticated ones too.
+func (o *options) fetcherOptions() ([]embedmd.FetcherOption, error) {
+	creds, err := o.credentialProvider()
+	if err != nil {
+		return nil, err
+	}
+	opts := []embedmd.FetcherOption{embedmd.WithCredentials(creds)}
+	if o.charset != "" {
+		opts = append(opts, embedmd.WithCharset(o.charset))
+	}
+	if o.retries > 0 {
+		opts = append(opts, embedmd.WithRetries(o.retries, o.retryBackoff))
+	}
+	if o.timeout > 0 {
+		opts = append(opts, embedmd.WithTimeout(o.timeout))
+	}
+	if o.rateLimit > 0 {
+		opts = append(opts, embedmd.WithRateLimit(o.rateLimit))
+	}
+	if o.maxFetchSize > 0 {
+		opts = append(opts, embedmd.WithMaxSize(o.maxFetchSize<<20))
+	}
+	if len(o.contentTypes) > 0 {
+		opts = append(opts, embedmd.WithContentTypes(o.contentTypes...))
+	}
+	if !o.noCache {
+		dir := o.cacheDir
+		if dir == "" {
+			// Without a user cache directory, content is fetched uncached.
+			dir, _ = embedmd.DefaultHTTPCacheDir()
+		}
+		if dir != "" {
+			opts = append(opts, embedmd.WithHTTPCache(&embedmd.HTTPCache{Dir: dir, TTL: o.cacheTTL}))
+		}
+	}
+	return opts, nil
+}
+
+// versions returns the versions set with -doc-version.
+func (o *options) versions() ([]embedmd.Version, error) {
+	var versions []embedmd.Version
+	seen := map[string]bool{}
+	for _, v := range o.docVersions {
+		name, ref, _ := strings.Cut(v, "=")
+		name, ref = strings.TrimSpace(name), strings.TrimSpace(ref)
+		if name == "" || ref == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+			return nil, fmt.Errorf("error: -doc-version: bad version %q, should be name=ref", v)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("error: -doc-version: version %s is set twice", name)
+		}
+		seen[name] = true
+		versions = append(versions, embedmd.Version{Name: name, Ref: ref})
+	}
+	return versions, nil
+}
+
+// archiveFS reads the archive set with -source-archive.
+func (o *options) archiveFS() (fs.FS, error) {
+	var b []byte
+	var err error
+	if o.sourceArchive == "-" {
+		b, err = io.ReadAll(stdin)
+	} else {
+		b, err = os.ReadFile(o.sourceArchive)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error: -source-archive: %v", err)
+	}
+	fsys, err := embedmd.ArchiveFS(b)
+	if err != nil {
+		return nil, fmt.Errorf("error: -source-archive: %v", err)
+	}
+	return fsys, nil
+}
+
+// credentialProvider returns the provider of the credentials set with
+// -credential, falling back to the ones found in the environment.
+func (o *options) credentialProvider() (embedmd.CredentialProvider, error) {
+	hosts := embedmd.HostCredentials{}
+	for _, v := range o.credentials {
+		host, rest, _ := strings.Cut(v, "=")
+		host = strings.TrimSpace(host)
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("error: -credential: missing environment variable of %s", host)
+		}
+		token := os.Getenv(fields[0])
+		if token == "" {
+			return nil, fmt.Errorf("error: -credential: environment variable %s of %s is not set", fields[0], host)
+		}
+		c := embedmd.BearerToken(token)
+		for _, field := range fields[1:] {
+			key, name, _ := strings.Cut(field, "=")
+			if key != "header" || name == "" {
+				return nil, fmt.Errorf("error: -credential: unknown setting %q of %s, should be header=name", field, host)
+			}
+			c = embedmd.Credential{Header: name, Value: token}
+		}
+		hosts[host] = c
+	}
+	forges, err := o.forgeList()
+	if err != nil {
+		return nil, err
+	}
+	return embedmd.ForgeCredentials(embedmd.ChainCredentials(hosts, embedmd.EnvCredentials()), forges...), nil
+}
+
+// forgeList returns the forges set with -forge.
+func (o *options) forgeList() ([]embedmd.Forge, error) {
+	var forges []embedmd.Forge
+	for _, v := range o.forges {
+		host, rest, _ := strings.Cut(v, "=")
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("error: -forge: missing kind of forge %s", strings.TrimSpace(host))
+		}
+		f := embedmd.Forge{Host: strings.TrimSpace(host), Kind: fields[0]}
+		for _, field := range fields[1:] {
+			switch key, template, _ := strings.Cut(field, "="); key {
+			case "raw":
+				f.RawURL = template
+			case "clone":
+				f.CloneURL = template
+			case "wiki":
+				f.WikiURL = template
+			default:
+				return nil, fmt.Errorf("error: -forge: unknown template %q of forge %s, should be raw, clone, or wiki", key, f.Host)
+			}
+		}
+		forges = append(forges, f)
+	}
+	return forges, nil
+}
+
+// network returns the connection settings of the fetcher.
+func (o *options) network() embedmd.Network {
+	n := embedmd.Network{
+		UnixSocket:         o.unixSocket,
+		Proxy:              cmp.Or(o.proxy, o.socks5),
+		HTTPSOnly:          o.httpsOnly,
+		PublicOnly:         o.publicOnly,
+		CAFile:             o.caFile,
+		CertFile:           o.clientCert,
+		KeyFile:            o.clientKey,
+		InsecureSkipVerify: o.insecureSkipVerify,
+	}
+	switch {
+	case o.ipv4:
+		n.IPVersion = 4
+	case o.ipv6:
+		n.IPVersion = 6
+	}
+	return n
+}
+
+// storeDirectory returns the directory of the store of remote content.
+func (o *options) storeDirectory() (string, error) {
+	if o.storeDir != "" {
+		return o.storeDir, nil
+	}
+	return embedmd.DefaultStoreDir()
+}
+
+// workspace returns the temporary workspace of the run.
+func (o *options) workspace() *embedmd.Workspace {
+	return &embedmd.Workspace{MaxBytes: o.tempLimit << 20, Keep: o.keepTemp}
+}
+
+// stringList is a flag.Value collecting the values of a repeated flag.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+// envVars returns the variables allowed with -env: those set as NAME=value,
+// and those named that are set in the environment.
+func envVars(names []string) map[string]string {
+	vars := map[string]string{}
+	for _, n := range names {
+		name, val, ok := strings.Cut(n, "=")
+		if !ok {
+			if val, ok = os.LookupEnv(name); !ok {
+				continue
+			}
+		}
+		vars[strings.TrimSpace(name)] = val
+	}
+	return vars
+}
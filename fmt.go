@@ -0,0 +1,93 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// runFmt implements the fmt command, rewriting the commands of files in
+// canonical form, or listing the files whose commands aren't with -l.
+func runFmt(args []string) int {
+	fs := flag.NewFlagSet("embedmd fmt", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd fmt [flags] [file.md ...]\n")
+		fs.PrintDefaults()
+	}
+	o := newCommandFlags(fs)
+	list := fs.Bool("l", false, "list the files whose commands aren't in canonical form instead of rewriting them, failing if there are any")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := setup(fs, o); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	opts, err := o.embedOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if fs.NArg() == 0 {
+		var out bytes.Buffer
+		if err := embedmd.FormatCommands(&out, stdin, opts...); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		stdout.Write(out.Bytes()) //nolint:errcheck
+		return 0
+	}
+	unformatted := false
+	for _, path := range fs.Args() {
+		changed, err := formatFile(path, !*list, opts...)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if changed && *list {
+			fmt.Fprintln(stdout, path)
+			unformatted = true
+		}
+	}
+	if unformatted {
+		return 1
+	}
+	return 0
+}
+
+// formatFile formats the commands of the file at path, rewriting it if
+// rewrite is set, and reports whether they weren't in canonical form.
+func formatFile(path string, rewrite bool, opts ...embedmd.Option) (bool, error) {
+	b, err := readFile(path)
+	if err != nil {
+		return false, err
+	}
+	opts = append([]embedmd.Option{embedmd.WithFormat(embedmd.FormatOf(path))}, opts...)
+	var out bytes.Buffer
+	if err := embedmd.FormatCommands(&out, bytes.NewReader(b), opts...); err != nil {
+		return false, fmt.Errorf("%s:%v", path, err)
+	}
+	if bytes.Equal(out.Bytes(), b) {
+		return false, nil
+	}
+	if !rewrite {
+		return true, nil
+	}
+	return true, rewriteFile(path, out.Bytes())
+}
@@ -0,0 +1,73 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFmtCommand(t *testing.T) {
+	dir := t.TempDir()
+	messy := filepath.Join(dir, "messy.md")
+	clean := filepath.Join(dir, "clean.md")
+	files := map[string]string{
+		messy: "# Doc\n\n[embedmd]:# ( ./code.go  lang=go /func/ )\n```go\nold\n```\n",
+		clean: "[embedmd]:# (code.go /func/ lang=go)\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	var out, errs bytes.Buffer
+	stdout, stderr = &out, &errs
+
+	if code := runFmt([]string{"-l", messy, clean}); code != 1 || out.String() != messy+"\n" {
+		t.Errorf("-l: expected status 1 listing %s; got %d, %q, %s", messy, code, out.String(), errs.String())
+	}
+	if b, _ := os.ReadFile(messy); string(b) != files[messy] {
+		t.Errorf("-l rewrote %s: %q", messy, b)
+	}
+
+	// The flags of the main command choosing what to do with the files
+	// aren't those of fmt.
+	if code := runFmt([]string{"-d", messy}); code != 2 {
+		t.Errorf("-d: expected status 2; got %d", code)
+	}
+	if b, _ := os.ReadFile(messy); string(b) != files[messy] {
+		t.Errorf("-d rewrote %s: %q", messy, b)
+	}
+
+	out.Reset()
+	if code := runFmt([]string{messy, clean}); code != 0 {
+		t.Fatalf("expected status 0; got %d, %s", code, errs.String())
+	}
+	want := "# Doc\n\n[embedmd]:# (code.go /func/ lang=go)\n```go\nold\n```\n"
+	if b, _ := os.ReadFile(messy); string(b) != want {
+		t.Errorf("expected formatted document\n%q\ngot\n%q", want, b)
+	}
+
+	defer func(r io.Reader) { stdin = r }(stdin)
+	stdin = strings.NewReader("[embedmd]:# (code.go   caption=\"x\")\n")
+	out.Reset()
+	if code := runFmt(nil); code != 0 || out.String() != "[embedmd]:# (code.go caption=x)\n" {
+		t.Errorf("stdin: expected formatted document; got %d, %q, %s", code, out.String(), errs.String())
+	}
+}
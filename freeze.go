@@ -0,0 +1,84 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// runFreeze implements the freeze command, setting the freeze attribute of
+// the commands at the given lines of markdown files, so their blocks are kept
+// as is, or removing it with -undo.
+func runFreeze(args []string) int {
+	fs := flag.NewFlagSet("embedmd freeze", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd freeze [flags] file.md:line ...\n")
+		fs.PrintDefaults()
+	}
+	o := newCommandFlags(fs)
+	undo := fs.Bool("undo", false, "unfreeze the commands rather than freezing them")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := setup(fs, o); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	opts, err := o.embedOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	for _, arg := range fs.Args() {
+		if err := freeze(arg, !*undo, opts...); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// freeze sets the freeze attribute of the command at loc, as file.md:line,
+// or removes it if frozen is false.
+func freeze(loc string, frozen bool, opts ...embedmd.Option) error {
+	i := strings.LastIndex(loc, ":")
+	line, err := strconv.Atoi(loc[i+1:])
+	if i < 0 || err != nil || line < 1 {
+		return fmt.Errorf("bad location %q, should be file.md:line", loc)
+	}
+	path := loc[:i]
+	b, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := embedmd.Freeze(&out, bytes.NewReader(b), line, frozen, opts...); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if bytes.Equal(out.Bytes(), b) {
+		return nil
+	}
+	return os.WriteFile(path, out.Bytes(), 0644)
+}
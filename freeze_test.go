@@ -0,0 +1,52 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFreezeCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "doc.md")
+	if err := os.WriteFile(path, []byte("[embedmd]:# (code.go)\n```go\nold\n```\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := freeze(path+":1", true); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[embedmd]:# (code.go freeze=true)\n```go\nold\n```\n"; string(b) != want {
+		t.Errorf("expected frozen document\n%q\ngot\n%q", want, b)
+	}
+
+	if err := freeze(path+":1", false); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ = os.ReadFile(path); string(b) != "[embedmd]:# (code.go)\n```go\nold\n```\n" {
+		t.Errorf("expected unfrozen document, got %q", b)
+	}
+
+	for _, loc := range []string{path, path + ":x", path + ":0"} {
+		if err := freeze(loc, true); err == nil || err.Error() != "bad location \""+loc+"\", should be file.md:line" {
+			t.Errorf("freeze(%q): expected bad location error, got %v", loc, err)
+		}
+	}
+}
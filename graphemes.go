@@ -0,0 +1,129 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// graphemes splits s into grapheme clusters, the characters as perceived by
+// users, so that nothing is ever inserted in the middle of one. It follows
+// the main Unicode segmentation rules: combining marks, variation selectors,
+// emoji modifiers and zero width joiner sequences, regional indicator pairs,
+// Hangul syllables, and \r\n are kept together.
+func graphemes(s string) []string {
+	var gs []string
+	start := 0
+	var prev rune = -1
+	ri := 0 // regional indicators in the current cluster.
+	for i, r := range s {
+		if i > start && !joins(prev, r, ri) {
+			gs = append(gs, s[start:i])
+			start, ri = i, 0
+		}
+		if isRegionalIndicator(r) {
+			ri++
+		}
+		prev = r
+	}
+	if start < len(s) {
+		gs = append(gs, s[start:])
+	}
+	return gs
+}
+
+const zwj = '\u200d'
+
+// joins reports whether r belongs to the same grapheme cluster as prev,
+// given the number of regional indicators already in the cluster.
+func joins(prev, r rune, ri int) bool {
+	switch {
+	case prev == '\r':
+		return r == '\n'
+	case prev == '\n' || r == '\r' || r == '\n':
+		return false
+	case unicode.Is(unicode.M, r) || r == zwj || isEmojiModifier(r) || isTag(r):
+		return true
+	case prev == zwj:
+		return true
+	case isRegionalIndicator(r):
+		return ri == 1 && isRegionalIndicator(prev)
+	}
+	return joinsHangul(hangulType(prev), hangulType(r))
+}
+
+func isRegionalIndicator(r rune) bool { return r >= 0x1F1E6 && r <= 0x1F1FF }
+func isEmojiModifier(r rune) bool     { return r >= 0x1F3FB && r <= 0x1F3FF }
+func isTag(r rune) bool               { return r >= 0xE0020 && r <= 0xE007F }
+
+// Hangul syllable types, as defined by Unicode.
+const (
+	hangulNone = iota
+	hangulL
+	hangulV
+	hangulT
+	hangulLV
+	hangulLVT
+)
+
+func hangulType(r rune) int {
+	switch {
+	case r >= 0x1100 && r <= 0x115F, r >= 0xA960 && r <= 0xA97C:
+		return hangulL
+	case r >= 0x1160 && r <= 0x11A7, r >= 0xD7B0 && r <= 0xD7C6:
+		return hangulV
+	case r >= 0x11A8 && r <= 0x11FF, r >= 0xD7CB && r <= 0xD7FB:
+		return hangulT
+	case r >= 0xAC00 && r <= 0xD7A3:
+		if (r-0xAC00)%28 == 0 {
+			return hangulLV
+		}
+		return hangulLVT
+	}
+	return hangulNone
+}
+
+func joinsHangul(prev, next int) bool {
+	switch prev {
+	case hangulL:
+		return next == hangulL || next == hangulV || next == hangulLV || next == hangulLVT
+	case hangulLV, hangulV:
+		return next == hangulV || next == hangulT
+	case hangulLVT, hangulT:
+		return next == hangulT
+	}
+	return false
+}
+
+// wideRanges are the East Asian Wide and Fullwidth ranges.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F}, {0x2E80, 0x303E}, {0x3041, 0x33FF}, {0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF}, {0xA000, 0xA4CF}, {0xA960, 0xA97F}, {0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF}, {0xFE10, 0xFE19}, {0xFE30, 0xFE6F}, {0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6}, {0x1F300, 0x1F64F}, {0x1F900, 0x1F9FF}, {0x20000, 0x2FFFD},
+	{0x30000, 0x3FFFD},
+}
+
+// isWide reports whether the grapheme cluster g takes two columns in a
+// terminal.
+func isWide(g string) bool {
+	r, _ := utf8.DecodeRuneInString(g)
+	for _, wr := range wideRanges {
+		if r >= wr[0] && r <= wr[1] {
+			return true
+		}
+	}
+	return false
+}
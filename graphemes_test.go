@@ -0,0 +1,56 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGraphemes(t *testing.T) {
+	tc := []struct {
+		name string
+		in   string
+		out  []string
+	}{
+		{name: "ascii", in: "ab c", out: []string{"a", "b", " ", "c"}},
+		{name: "combining marks", in: "e\u0301a\u0308x", out: []string{"e\u0301", "a\u0308", "x"}},
+		{name: "crlf", in: "a\r\nb\n\n", out: []string{"a", "\r\n", "b", "\n", "\n"}},
+		{name: "emoji with modifier", in: "\U0001F44D\U0001F3FD!", out: []string{"\U0001F44D\U0001F3FD", "!"}},
+		{name: "zwj sequence", in: "\U0001F469\u200d\U0001F4BBx", out: []string{"\U0001F469\u200d\U0001F4BB", "x"}},
+		{name: "variation selector", in: "\u263a\ufe0fa", out: []string{"\u263a\ufe0f", "a"}},
+		{name: "flags", in: "\U0001F1EF\U0001F1F5\U0001F1EB\U0001F1F7\U0001F1E9", out: []string{"\U0001F1EF\U0001F1F5", "\U0001F1EB\U0001F1F7", "\U0001F1E9"}},
+		{name: "hangul jamo", in: "\u1100\u1161\u11a8\uac00", out: []string{"\u1100\u1161\u11a8", "\uac00"}},
+		{name: "cjk", in: "日本語", out: []string{"日", "本", "語"}},
+	}
+
+	for _, tt := range tc {
+		if got := graphemes(tt.in); !reflect.DeepEqual(got, tt.out) {
+			t.Errorf("case [%s] expected %q; got %q", tt.name, tt.out, got)
+		}
+	}
+}
+
+func TestIsWide(t *testing.T) {
+	for _, g := range []string{"日", "가", "Ａ", "😀", "𠀋"} {
+		if !isWide(g) {
+			t.Errorf("expected %q to be wide", g)
+		}
+	}
+	for _, g := range []string{"a", "é", "→", "ｱ", ""} {
+		if isWide(g) {
+			t.Errorf("expected %q not to be wide", g)
+		}
+	}
+}
@@ -0,0 +1,439 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// The grpc command serves the processor to long-lived clients in any
+// language, with the service of proto/embedmd.proto. It speaks gRPC over the
+// HTTP/2 of net/http, which is only served with TLS. Clients send the token
+// in grpcTokenEnv as a bearer one, and the sources read are confined to the
+// root of the server, as for workers.
+
+// grpcService prefixes the paths of the methods of the service.
+const grpcService = "/embedmd.v1.Embedmd/"
+
+// grpcTokenEnv is the environment variable holding the token of the clients.
+const grpcTokenEnv = "EMBEDMD_GRPC_TOKEN"
+
+const (
+	// grpcMaxMessage limits the size of the messages received, as the
+	// default of gRPC servers.
+	grpcMaxMessage = 4 << 20
+	// grpcChunkSize is the size of the chunks processed documents are
+	// streamed back in.
+	grpcChunkSize = 64 << 10
+)
+
+// The gRPC status codes answered.
+const (
+	grpcOK                = 0
+	grpcCanceled          = 1
+	grpcUnknown           = 2
+	grpcInvalidArgument   = 3
+	grpcDeadlineExceeded  = 4
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcUnauthenticated   = 16
+)
+
+// grpcError is an error answered with its gRPC status code.
+type grpcError struct {
+	code int
+	msg  string
+}
+
+func (e *grpcError) Error() string { return e.msg }
+
+// runGRPC implements the grpc command, serving gRPC requests until it's
+// stopped.
+func runGRPC(args []string) int {
+	fs := flag.NewFlagSet("embedmd grpc", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd grpc -tls-cert file -tls-key file [flags]\n")
+		fs.PrintDefaults()
+	}
+	o := newCommandFlags(fs)
+	listen := fs.String("listen", "localhost:7879", "address to serve gRPC requests on")
+	root := fs.String("root", ".", "root of the checkout the paths of the requests are relative to")
+	cert := fs.String("tls-cert", "", "PEM file of the certificate of the server")
+	key := fs.String("tls-key", "", "PEM file of the key of -tls-cert")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+	if err := setup(fs, o); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *cert == "" || *key == "" {
+		fmt.Fprintln(stderr, "error: -tls-cert and -tls-key are required, as gRPC is served over HTTP/2 with TLS")
+		return 2
+	}
+	token := os.Getenv(grpcTokenEnv)
+	if token == "" {
+		fmt.Fprintf(stderr, "error: set the token of the clients in %s\n", grpcTokenEnv)
+		return 2
+	}
+	o.root = *root
+	opts, err := o.embedOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	fmt.Fprintf(stderr, "serving gRPC requests on %s\n", *listen)
+	srv := &http.Server{Addr: *listen, Handler: grpcHandler(*root, token, opts...)}
+	if err := srv.ListenAndServeTLS(*cert, *key); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// grpcServer implements the methods of the service, for documents under
+// root processed with opts.
+type grpcServer struct {
+	root string
+	opts []embedmd.Option
+}
+
+// grpcHandler returns the handler of the gRPC requests sent with token,
+// whose documents are under root. The fetcher of opts is expected to confine
+// their sources to root.
+func grpcHandler(root, token string, opts ...embedmd.Option) http.Handler {
+	s := &grpcServer{root: root, opts: opts}
+	methods := map[string]func(context.Context, *grpcStream) error{
+		"Process": s.process,
+		"Plan":    s.plan,
+		"Explain": s.explain,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ct, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+		if r.Method != http.MethodPost || r.ProtoMajor != 2 || (ct != "application/grpc" && ct != "application/grpc+proto") {
+			http.Error(w, "only gRPC requests are served", http.StatusUnsupportedMediaType)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		ctx := r.Context()
+		if d, ok := grpcTimeout(r.Header.Get("Grpc-Timeout")); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d)
+			defer cancel()
+		}
+		st := &grpcStream{w: w, r: bufio.NewReader(r.Body)}
+		name, ok := strings.CutPrefix(r.URL.Path, grpcService)
+		method := methods[name]
+		var err error
+		switch {
+		case subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1:
+			err = &grpcError{grpcUnauthenticated, "missing or bad token"}
+		case !ok || method == nil:
+			err = &grpcError{grpcUnimplemented, fmt.Sprintf("unknown method %s", r.URL.Path)}
+		default:
+			err = method(ctx, st)
+		}
+		st.finish(ctx, err)
+	})
+}
+
+// grpcStream reads the messages of a request and writes those of its
+// response, framed as in gRPC.
+type grpcStream struct {
+	w http.ResponseWriter
+	r io.Reader
+}
+
+// recv returns the next message of the request, or io.EOF after the last.
+func (s *grpcStream) recv() ([]byte, error) {
+	var h [5]byte
+	if _, err := io.ReadFull(s.r, h[:]); err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, &grpcError{grpcInvalidArgument, fmt.Sprintf("could not read message: %v", err)}
+	}
+	if h[0] != 0 {
+		return nil, &grpcError{grpcUnimplemented, "compressed messages are not supported"}
+	}
+	n := binary.BigEndian.Uint32(h[1:])
+	if n > grpcMaxMessage {
+		return nil, &grpcError{grpcResourceExhausted, fmt.Sprintf("message of %d bytes is larger than %d", n, grpcMaxMessage)}
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(s.r, b); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, fmt.Sprintf("could not read message: %v", err)}
+	}
+	return b, nil
+}
+
+// send writes the message m of the response, flushing it to the client.
+func (s *grpcStream) send(m []byte) error {
+	var h [5]byte
+	binary.BigEndian.PutUint32(h[1:], uint32(len(m)))
+	if _, err := s.w.Write(append(h[:], m...)); err != nil {
+		return err
+	}
+	return http.NewResponseController(s.w).Flush()
+}
+
+// finish ends the response with the status of err, the error of the method
+// run with ctx, in its trailers.
+func (s *grpcStream) finish(ctx context.Context, err error) {
+	code, msg := grpcOK, ""
+	var gerr *grpcError
+	switch {
+	case err == nil:
+	case errors.As(err, &gerr):
+		code, msg = gerr.code, gerr.msg
+	case ctx.Err() == context.DeadlineExceeded:
+		code, msg = grpcDeadlineExceeded, err.Error()
+	case ctx.Err() != nil:
+		code, msg = grpcCanceled, err.Error()
+	default:
+		code, msg = grpcUnknown, err.Error()
+	}
+	s.w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		s.w.Header().Set(http.TrailerPrefix+"Grpc-Message", grpcMessage(msg))
+	}
+}
+
+// recvOne returns the single message of the request of a unary method.
+func (s *grpcStream) recvOne() ([]byte, error) {
+	b, err := s.recv()
+	if err == io.EOF {
+		return nil, &grpcError{grpcInvalidArgument, "missing request message"}
+	}
+	return b, err
+}
+
+// process implements Process, streaming the processed document back in
+// chunks, the last of which holds the warnings.
+func (s *grpcServer) process(ctx context.Context, st *grpcStream) error {
+	var path string
+	var in []byte
+	for first := true; ; first = false {
+		b, err := st.recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var p, content string
+		if err := decodeStrings("Document", b, &p, &content); err != nil {
+			return err
+		}
+		if first {
+			path = p
+		}
+		in = append(in, content...)
+	}
+	dir, err := s.documentDir(path)
+	if err != nil {
+		return err
+	}
+	var warnings []workWarning
+	opts := append([]embedmd.Option{
+		embedmd.WithContext(ctx),
+		embedmd.WithBaseDir(dir),
+		embedmd.WithFormat(embedmd.FormatOf(path)),
+		embedmd.WithWarnings(func(line int, msg string) {
+			warnings = append(warnings, workWarning{line, msg})
+		}),
+	}, s.opts...)
+	var out bytes.Buffer
+	if err := embedmd.Process(&out, bytes.NewReader(in), opts...); err != nil {
+		return err
+	}
+	for {
+		var m protoMessage
+		m.bytes(1, out.Next(grpcChunkSize))
+		if out.Len() == 0 {
+			encodeWarnings(&m, 2, warnings)
+		}
+		if err := st.send(m); err != nil {
+			return err
+		}
+		if out.Len() == 0 {
+			return nil
+		}
+	}
+}
+
+// plan implements Plan.
+func (s *grpcServer) plan(ctx context.Context, st *grpcStream) error {
+	b, err := st.recvOne()
+	if err != nil {
+		return err
+	}
+	var path, content string
+	if err := decodeStrings("Document", b, &path, &content); err != nil {
+		return err
+	}
+	dir, err := s.documentDir(path)
+	if err != nil {
+		return err
+	}
+	var warnings []workWarning
+	opts := append([]embedmd.Option{
+		embedmd.WithContext(ctx),
+		embedmd.WithBaseDir(dir),
+		embedmd.WithWarnings(func(line int, msg string) {
+			warnings = append(warnings, workWarning{line, msg})
+		}),
+	}, s.opts...)
+	f, err := planContent(path, []byte(content), opts...)
+	if err != nil {
+		return err
+	}
+	var m protoMessage
+	if f != nil {
+		m.bool(1, true)
+		m.string(2, f.Base)
+		m.string(3, f.Content)
+		m.string(4, f.Diff)
+	}
+	encodeWarnings(&m, 5, warnings)
+	return st.send(m)
+}
+
+// explain implements Explain.
+func (s *grpcServer) explain(ctx context.Context, st *grpcStream) error {
+	b, err := st.recvOne()
+	if err != nil {
+		return err
+	}
+	var cmd, path string
+	if err := decodeStrings("ExplainRequest", b, &cmd, &path); err != nil {
+		return err
+	}
+	// Without a document, the source is relative to the root.
+	dir := s.root
+	if path != "" {
+		if dir, err = s.documentDir(path); err != nil {
+			return err
+		}
+	}
+	opts := []embedmd.Option{embedmd.WithContext(ctx), embedmd.WithBaseDir(dir), embedmd.WithFormat(embedmd.FormatOf(path))}
+	x, err := embedmd.Explain(cmd, append(opts, s.opts...)...)
+	if err != nil {
+		return err
+	}
+	var m protoMessage
+	m.string(1, x.Path)
+	for _, match := range x.Matches {
+		var mm protoMessage
+		mm.string(1, match.Expr)
+		mm.int32(2, match.From)
+		mm.int32(3, match.Start)
+		mm.int32(4, match.End)
+		mm.packed(5, match.Lines)
+		for _, h := range match.Hints {
+			mm.embedded(6, []byte(h))
+		}
+		m.embedded(2, mm)
+	}
+	m.int32(3, x.FromLine)
+	m.int32(4, x.ToLine)
+	m.bytes(5, x.Content)
+	if x.Err != nil {
+		m.string(6, x.Err.Error())
+	}
+	return st.send(m)
+}
+
+// documentDir returns the directory of the document at the slash separated
+// path, relative to the root.
+func (s *grpcServer) documentDir(path string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(path)) || !isDocument(path) {
+		return "", &grpcError{grpcInvalidArgument, fmt.Sprintf("%q is not a document relative to the root", path)}
+	}
+	return filepath.Join(s.root, filepath.Dir(filepath.FromSlash(path))), nil
+}
+
+// encodeWarnings appends the warnings to the repeated Warning field of m.
+func encodeWarnings(m *protoMessage, field int, warnings []workWarning) {
+	for _, w := range warnings {
+		var wm protoMessage
+		wm.int32(1, w.Line)
+		wm.string(2, w.Message)
+		m.embedded(field, wm)
+	}
+}
+
+// decodeStrings decodes the message b, of the named type, whose string or
+// bytes fields, numbered from 1, are vs.
+func decodeStrings(name string, b []byte, vs ...*string) error {
+	err := protoFields(b, func(field, wire int, _ uint64, data []byte) error {
+		if field >= 1 && field <= len(vs) && wire == protoBytes {
+			*vs[field-1] = string(data)
+		}
+		return nil
+	})
+	if err != nil {
+		return &grpcError{grpcInvalidArgument, fmt.Sprintf("bad %s: %v", name, err)}
+	}
+	return nil
+}
+
+// grpcTimeout returns the timeout of the grpc-timeout header h, a number
+// followed by its unit.
+func grpcTimeout(h string) (time.Duration, bool) {
+	units := map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second, 'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond}
+	if len(h) < 2 {
+		return 0, false
+	}
+	unit, ok := units[h[len(h)-1]]
+	n, err := strconv.ParseInt(h[:len(h)-1], 10, 64)
+	if !ok || err != nil || n < 0 || n > math.MaxInt64/int64(unit) {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// grpcMessage percent-encodes msg for the grpc-message trailer.
+func grpcMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if c := msg[i]; c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,225 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// grpcCall calls the method of the gRPC server with the given messages,
+// returning those of the response, and its status and message.
+func grpcCall(t *testing.T, ts *httptest.Server, method, token string, msgs ...[]byte) ([][]byte, string, string) {
+	t.Helper()
+	var body bytes.Buffer
+	for _, m := range msgs {
+		var h [5]byte
+		binary.BigEndian.PutUint32(h[1:], uint32(len(m)))
+		body.Write(h[:])
+		body.Write(m)
+	}
+	req, err := http.NewRequest("POST", ts.URL+grpcService+method, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("Authorization", "Bearer "+token)
+	r, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replies [][]byte
+	for len(b) >= 5 {
+		n := binary.BigEndian.Uint32(b[1:5])
+		replies, b = append(replies, b[5:5+n]), b[5+n:]
+	}
+	return replies, r.Trailer.Get("Grpc-Status"), r.Trailer.Get("Grpc-Message")
+}
+
+// protoDecode returns the fields of the message b by number, as their
+// numbers or their contents.
+func protoDecode(t *testing.T, b []byte) map[int][]any {
+	t.Helper()
+	fields := map[int][]any{}
+	err := protoFields(b, func(field, wire int, n uint64, data []byte) error {
+		if wire == protoVarint {
+			fields[field] = append(fields[field], int(int32(n)))
+		} else {
+			fields[field] = append(fields[field], string(data))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fields
+}
+
+func grpcTestServer(t *testing.T) (*httptest.Server, string) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "docs", "code.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(grpcHandler(root, "secret", workerOptions(t, root)...))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	return ts, root
+}
+
+func TestGRPC(t *testing.T) {
+	ts, _ := grpcTestServer(t)
+	defer ts.Close()
+
+	// The document is streamed in two chunks, only the first naming it.
+	var first, second protoMessage
+	first.string(1, "docs/a.md")
+	first.string(2, "# Code\n[embedmd]:# (code.go")
+	second.string(2, " /func/ $)\n")
+	replies, status, msg := grpcCall(t, ts, "Process", "secret", first, second)
+	if status != "0" || len(replies) != 1 {
+		t.Fatalf("expected a single message and status 0; got %d messages, status %q: %s", len(replies), status, msg)
+	}
+	want := "# Code\n[embedmd]:# (code.go /func/ $)\n```go\nfunc main() {}\n```\n"
+	if got := protoDecode(t, replies[0])[1]; !reflect.DeepEqual(got, []any{want}) {
+		t.Errorf("expected processed document %q; got %q", want, got)
+	}
+
+	var doc protoMessage
+	doc.string(1, "docs/a.md")
+	doc.string(2, "[embedmd]:# (code.go)\n")
+	replies, status, msg = grpcCall(t, ts, "Plan", "secret", doc)
+	if status != "0" || len(replies) != 1 {
+		t.Fatalf("expected a single message and status 0; got %d messages, status %q: %s", len(replies), status, msg)
+	}
+	plan := protoDecode(t, replies[0])
+	if !reflect.DeepEqual(plan[1], []any{1}) || !strings.Contains(plan[4][0].(string), "+package main") {
+		t.Errorf("expected a planned change; got %q", plan)
+	}
+
+	var x protoMessage
+	x.string(1, "(code.go /nope/)")
+	x.string(2, "docs/a.md")
+	replies, status, msg = grpcCall(t, ts, "Explain", "secret", x)
+	if status != "0" || len(replies) != 1 {
+		t.Fatalf("expected a single message and status 0; got %d messages, status %q: %s", len(replies), status, msg)
+	}
+	expl := protoDecode(t, replies[0])
+	match := protoDecode(t, []byte(expl[2][0].(string)))
+	if !reflect.DeepEqual(match[1], []any{"/nope/"}) || !reflect.DeepEqual(match[3], []any{-1}) {
+		t.Errorf("expected /nope/ to match nothing; got %q", match)
+	}
+	if len(expl[6]) != 1 {
+		t.Errorf("expected the error of the explanation; got %q", expl)
+	}
+}
+
+func TestGRPC_Errors(t *testing.T) {
+	ts, root := grpcTestServer(t)
+	defer ts.Close()
+
+	doc := func(path, content string) []byte {
+		var m protoMessage
+		m.string(1, path)
+		m.string(2, content)
+		return m
+	}
+	tc := []struct {
+		name, method, token string
+		msgs                [][]byte
+		status, msg         string
+	}{
+		{name: "no token", method: "Plan", msgs: [][]byte{doc("a.md", "")},
+			status: "16", msg: "missing or bad token"},
+		{name: "unknown method", method: "Render", token: "secret",
+			status: "12", msg: "unknown method " + grpcService + "Render"},
+		{name: "no message", method: "Plan", token: "secret",
+			status: "3", msg: "missing request message"},
+		{name: "bad message", method: "Plan", token: "secret", msgs: [][]byte{{0x0a, 0x05}},
+			status: "3", msg: "bad Document: bad length of field 1"},
+		{name: "outside of the root", method: "Process", token: "secret", msgs: [][]byte{doc("../a.md", "")},
+			status: "3", msg: `"../a.md" is not a document relative to the root`},
+		{name: "source outside of the root", method: "Plan", token: "secret", msgs: [][]byte{doc("a.md", "[embedmd]:# (../secret.txt)\n")},
+			status: "2", msg: "1: could not read ../secret.txt: ../secret.txt is outside of " + grpcMessage(root)},
+	}
+	for _, tt := range tc {
+		replies, status, msg := grpcCall(t, ts, tt.method, tt.token, tt.msgs...)
+		if len(replies) != 0 || status != tt.status || msg != tt.msg {
+			t.Errorf("case [%s]: expected status %s %q; got %d messages, status %s %q", tt.name, tt.status, tt.msg, len(replies), status, msg)
+		}
+	}
+
+	// Other requests than those of gRPC are refused.
+	r, err := ts.Client().Get(ts.URL + grpcService + "Plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("expected status %d for a GET request; got %d", http.StatusUnsupportedMediaType, r.StatusCode)
+	}
+}
+
+func TestProtoMessage(t *testing.T) {
+	var m protoMessage
+	m.string(1, "a")
+	m.int32(2, -1)
+	m.bool(3, true)
+	m.packed(4, []int{1, 300})
+	m.string(5, "")
+	m.int32(6, 0)
+	var lines []int
+	err := protoFields(m, func(field, wire int, n uint64, data []byte) error {
+		switch field {
+		case 2:
+			lines = append(lines, int(int32(n)))
+		case 4:
+			for len(data) > 0 {
+				v, i := binary.Uvarint(data)
+				lines, data = append(lines, int(v)), data[i:]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{-1, 1, 300}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("expected numbers %v; got %v", want, lines)
+	}
+	// Default values are left out, and negative numbers take 10 bytes.
+	if want := []byte{0x0a, 1, 'a', 0x10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0x18, 1, 0x22, 3, 1, 0xac, 0x02}; !bytes.Equal(m, want) {
+		t.Errorf("expected encoding % x; got % x", want, []byte(m))
+	}
+
+	if err := protoFields([]byte{0x0b}, func(int, int, uint64, []byte) error { return nil }); err == nil {
+		t.Errorf("expected an error for the wire type of groups")
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hookMarker is the line identifying the git hooks installed by embedmd,
+// which can be replaced without -force.
+const hookMarker = "# Installed by embedmd hook install."
+
+// runHook implements the hook command, whose install subcommand installs a
+// git pre-commit hook running embedmd on the staged documents.
+func runHook(args []string) int {
+	fs := flag.NewFlagSet("embedmd hook install", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd hook install [flags] [-- embedmd flags]\n")
+		fs.PrintDefaults()
+	}
+	check := fs.Bool("check", false, "fail commits with stale documents instead of embedding them again and staging the result")
+	replace := fs.Bool("force", false, "replace an existing pre-commit hook not installed by embedmd")
+	if len(args) == 0 || args[0] != "install" {
+		fs.Usage()
+		return 2
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	path, err := installHook(*check, *replace, fs.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "installed %s\n", filepath.ToSlash(path))
+	return 0
+}
+
+// installHook writes the pre-commit hook of the current repository, running
+// embedmd with -staged and -w, or -check, followed by the given flags. It
+// returns the path of the hook.
+func installHook(check, replace bool, flags []string) (string, error) {
+	out, err := runGit("rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.FromSlash(strings.TrimSpace(string(out)))
+	path := filepath.Join(dir, "pre-commit")
+	if b, err := os.ReadFile(path); err == nil && !replace && !bytes.Contains(b, []byte(hookMarker)) {
+		return "", fmt.Errorf("%s already exists, use -force to replace it", filepath.ToSlash(path))
+	}
+	cmd := []string{"embedmd", "-staged", "-w"}
+	if check {
+		cmd[2] = "-check"
+	}
+	for _, f := range flags {
+		cmd = append(cmd, shellQuote(f))
+	}
+	script := "#!/bin/sh\n" + hookMarker + "\nexec " + strings.Join(cmd, " ") + "\n"
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		return "", err
+	}
+	// WriteFile keeps the mode of an existing file.
+	return path, os.Chmod(path, 0755)
+}
+
+// shellQuote quotes s for sh if it has characters sh would interpret.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=./,:@+") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// stagedPaths returns the documents added, copied, modified, or renamed in
+// the git index, relative to the current directory and selected by the
+// include and exclude patterns.
+func stagedPaths(include, exclude []string) ([]string, error) {
+	out, err := runGit("diff", "--cached", "--name-only", "--diff-filter=ACMR", "--relative", "-z")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" && isDocument(name) && selected(name, include, exclude) {
+			paths = append(paths, filepath.FromSlash(name))
+		}
+	}
+	return paths, nil
+}
+
+// checkUnstaged returns an error for the first of the given files with
+// unstaged changes, which staging the rewritten file would commit.
+func checkUnstaged(paths []string) error {
+	for _, path := range paths {
+		status, err := gitStatus(path)
+		if err != nil {
+			return fmt.Errorf("%s:%v", filepath.ToSlash(path), err)
+		}
+		// The second column is the status in the working tree.
+		if len(status) > 1 && status[1] != ' ' {
+			return fmt.Errorf("%s: has unstaged changes, stage or stash them to run embedmd -staged -w", filepath.ToSlash(path))
+		}
+	}
+	return nil
+}
+
+// restage stages again the given files changed since their content in
+// before.
+func restage(before map[string][]byte, paths []string) error {
+	var changed []string
+	for _, path := range paths {
+		after, err := readFile(path)
+		if err != nil {
+			return fmt.Errorf("%s:%v", filepath.ToSlash(path), err)
+		}
+		if !bytes.Equal(after, before[path]) {
+			changed = append(changed, path)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	_, err := runGit(append([]string{"add", "--"}, changed...)...)
+	return err
+}
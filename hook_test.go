@@ -0,0 +1,153 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStaged(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	write("code.go", "v1\n")
+	write("a.md", "# A\n")
+	write("b.md", "# B\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "Add the docs")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := "[embedmd]:# (code.go)\n"
+	write("a.md", doc)
+	write("b.md", "# B, unstaged\n")
+	write("code.go", "v2\n")
+	git("add", "a.md", "code.go")
+	paths, err := stagedPaths(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.md"}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("expected staged paths %v; got %v", want, paths)
+	}
+	if paths, err := stagedPaths(nil, []string{"*.md"}); err != nil || len(paths) > 0 {
+		t.Errorf("expected no staged paths excluding *.md; got %v, %v", paths, err)
+	}
+	if err := checkUnstaged(paths); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	before := map[string][]byte{"a.md": []byte(doc)}
+	if _, err := processFile("a.md", true, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := restage(before, paths); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := git("show", ":a.md"), doc+"```go\nv2\n```\n"; got != want {
+		t.Errorf("expected staged content\n%s\ngot\n%s", want, got)
+	}
+	if got := git("status", "--porcelain"); got != "M  a.md\n M b.md\nM  code.go\n" {
+		t.Errorf("unexpected status after staging again:\n%s", got)
+	}
+
+	write("a.md", "# edited\n")
+	err = checkUnstaged(paths)
+	eqErr(t, "unstaged", err, "a.md: has unstaged changes, stage or stash them to run embedmd -staged -w")
+}
+
+func TestInstallHook(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	cmd := exec.Command("git", "init", "-q")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := installHook(false, false, []string{"-checksums", "-exclude", "sample dir/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(".git", "hooks", "pre-commit"); path != want {
+		t.Errorf("expected hook at %s; got %s", want, path)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "#!/bin/sh\n" + hookMarker + "\nexec embedmd -staged -w -checksums -exclude 'sample dir/'\n"
+	if string(b) != want {
+		t.Errorf("expected hook\n%s\ngot\n%s", want, b)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Errorf("expected an executable hook; got %v, %v", info.Mode(), err)
+	}
+
+	// Its own hook is replaced, not others.
+	if _, err := installHook(true, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(path); !strings.HasSuffix(string(b), "\nexec embedmd -staged -check\n") {
+		t.Errorf("expected the hook to be replaced; got\n%s", b)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nmake lint\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	_, err = installHook(false, false, nil)
+	eqErr(t, "existing hook", err, ".git/hooks/pre-commit already exists, use -force to replace it")
+	if _, err := installHook(false, true, nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// verifyIdempotent is set to process the output of each file again, failing
+// if it changes, as processing must be a fixed point.
+var verifyIdempotent bool
+
+// checkIdempotent processes out, the output of processing a file with opts,
+// again, failing with the diff of the changes if there are any. The options
+// must not report anything, as the second pass would report it twice.
+func checkIdempotent(out []byte, opts ...embedmd.Option) error {
+	var again bytes.Buffer
+	if err := embedmd.Process(&again, bytes.NewReader(out), opts...); err != nil {
+		return fmt.Errorf("processing the output again failed: %v", err)
+	}
+	if bytes.Equal(out, again.Bytes()) {
+		return nil
+	}
+	d, err := diff(string(out), again.String())
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%d: processing is not idempotent, processing the output again changes it:\n%s", firstChange(out, again.Bytes()), strings.TrimSuffix(d, "\n"))
+}
+
+// firstChange returns the number of the first line of a that differs in b.
+func firstChange(a, b []byte) int {
+	line := 1
+	for i := 0; i < len(a) && i < len(b) && a[i] == b[i]; i++ {
+		if a[i] == '\n' {
+			line++
+		}
+	}
+	return line
+}
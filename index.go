@@ -0,0 +1,169 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// The comments between which the index is written in its markdown file.
+const (
+	indexStart = "<!-- embedmd index start -->"
+	indexEnd   = "<!-- embedmd index end -->"
+)
+
+// runIndex implements the index command, writing a table of the sources
+// embedded by the documents in the given paths, with each document and line
+// embedding them.
+func runIndex(args []string) int {
+	fs := flag.NewFlagSet("embedmd index", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd index [flags] [path ...]\n")
+		fs.PrintDefaults()
+	}
+	o := newCommandFlags(fs)
+	out := fs.String("o", "", "markdown file the table is written to, between "+indexStart+" and "+indexEnd+", instead of the standard output")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := setup(fs, o); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	opts, err := o.embedOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	entries, err := indexEntries(paths, o.baseDir, opts...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *out == "" {
+		fmt.Fprint(stdout, indexTable(entries, "."))
+		return 0
+	}
+	if err := writeIndex(*out, indexTable(entries, filepath.Dir(*out))); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	return 0
+}
+
+// An indexEntry is a source embedded by a document.
+type indexEntry struct {
+	source, selection string
+	doc               string
+	line              int
+}
+
+// indexEntries returns the sources embedded by the documents in paths,
+// sorted by source, with local paths resolved from baseDir, or the directory
+// of their document if empty.
+func indexEntries(paths []string, baseDir string, opts ...embedmd.Option) ([]indexEntry, error) {
+	docs, err := markdownFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	var entries []indexEntry
+	for _, doc := range docs {
+		found, err := docSources(doc, opts...)
+		if err != nil {
+			return nil, err
+		}
+		dir := baseDir
+		if dir == "" {
+			dir = filepath.Dir(doc)
+		}
+		for _, s := range found {
+			src := s.Path
+			if !strings.Contains(src, ":") && !filepath.IsAbs(src) {
+				src = filepath.ToSlash(filepath.Join(dir, filepath.FromSlash(src)))
+			}
+			entries = append(entries, indexEntry{src, s.Selection, filepath.ToSlash(doc), s.Line})
+		}
+	}
+	slices.SortFunc(entries, func(a, b indexEntry) int {
+		return cmp.Or(
+			strings.Compare(a.source, b.source),
+			strings.Compare(a.selection, b.selection),
+			strings.Compare(a.doc, b.doc),
+			cmp.Compare(a.line, b.line),
+		)
+	})
+	return entries, nil
+}
+
+// indexTable returns the markdown table of entries, linking to the documents
+// relative to dir.
+func indexTable(entries []indexEntry, dir string) string {
+	var b strings.Builder
+	b.WriteString("| Source | Selection | Embedded in |\n| --- | --- | --- |\n")
+	for _, e := range entries {
+		link := e.doc
+		if rel, err := filepath.Rel(dir, filepath.FromSlash(e.doc)); err == nil {
+			link = filepath.ToSlash(rel)
+		}
+		selection := "whole source"
+		if e.selection != "" {
+			selection = tableCode(e.selection)
+		}
+		fmt.Fprintf(&b, "| %s | %s | [%s:%d](%s) |\n", tableCode(e.source), selection, e.doc, e.line, link)
+	}
+	return b.String()
+}
+
+// tableCode returns s as code in a markdown table cell.
+func tableCode(s string) string {
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
+
+// writeIndex writes table to the markdown file at path between its index
+// comments, creating the file with them if it doesn't exist.
+func writeIndex(path, table string) error {
+	b, err := readFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return os.WriteFile(path, []byte(indexStart+"\n"+table+indexEnd+"\n"), 0644)
+	}
+	if err != nil {
+		return err
+	}
+	start := bytes.Index(b, []byte(indexStart+"\n"))
+	end := bytes.Index(b, []byte(indexEnd))
+	if start < 0 || end < start {
+		return fmt.Errorf("%s: missing %s and %s comments to write the index between", path, indexStart, indexEnd)
+	}
+	start += len(indexStart) + 1
+	out := slices.Concat(b[:start], []byte(table), b[end:])
+	if bytes.Equal(out, b) {
+		return nil
+	}
+	return rewriteFile(path, out)
+}
@@ -0,0 +1,81 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIndexCommand(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"docs/a.md": "[embedmd]:# (../code.go /func main/ /^}/)\n\n[embedmd]:# (https://example.com/x.go)\n",
+		"docs/b.md": "[embedmd]:# (../code.go#L1-L3)\n\n[embedmd]:# (../code.go /func main/ /^}/)\n",
+		"INDEX.md":  "# Index\n\n" + indexStart + "\nstale\n" + indexEnd + "\n\nMore.\n",
+	}
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	var out, errs bytes.Buffer
+	stdout, stderr = &out, &errs
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	table := "| Source | Selection | Embedded in |\n| --- | --- | --- |\n" +
+		"| `code.go` | `#L1-L3` | [docs/b.md:1](docs/b.md) |\n" +
+		"| `code.go` | `/func main/ /^}/` | [docs/a.md:1](docs/a.md) |\n" +
+		"| `code.go` | `/func main/ /^}/` | [docs/b.md:3](docs/b.md) |\n" +
+		"| `https://example.com/x.go` | whole source | [docs/a.md:3](docs/a.md) |\n"
+	if code := runIndex([]string{"docs"}); code != 0 || out.String() != table {
+		t.Errorf("expected status 0 and table\n%s\ngot %d\n%s%s", table, code, out.String(), errs.String())
+	}
+
+	if code := runIndex([]string{"-o", "INDEX.md", "docs"}); code != 0 {
+		t.Fatalf("expected status 0; got %d, %s", code, errs.String())
+	}
+	want := "# Index\n\n" + indexStart + "\n" + table + indexEnd + "\n\nMore.\n"
+	if b, _ := os.ReadFile("INDEX.md"); string(b) != want {
+		t.Errorf("expected index\n%s\ngot\n%s", want, b)
+	}
+
+	if code := runIndex([]string{"-o", "docs/index.md", "docs"}); code != 0 {
+		t.Fatalf("expected status 0; got %d, %s", code, errs.String())
+	}
+	if b, _ := os.ReadFile("docs/index.md"); !strings.HasPrefix(string(b), indexStart+"\n") || !strings.Contains(string(b), "[docs/a.md:1](a.md)") {
+		t.Errorf("expected new index linking to documents from docs; got\n%s", b)
+	}
+
+	errs.Reset()
+	if code := runIndex([]string{"-o", "docs/a.md", "docs"}); code != 2 || !strings.Contains(errs.String(), "missing "+indexStart) {
+		t.Errorf("expected status 2 and missing comments error; got %d, %s", code, errs.String())
+	}
+}
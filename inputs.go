@@ -0,0 +1,125 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// expandPaths returns the files named by the arguments: directories, and
+// paths ending with /... as in Go packages, stand for the documents they
+// contain at any depth, and glob patterns, where ** matches any number
+// of directories, for the files they match. Files found that way must match
+// one of the include patterns, if any, and none of the exclude ones, which
+// follow the gitignore rules. Other arguments are kept as they are.
+func expandPaths(args, include, exclude []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		var found []string
+		var err error
+		switch {
+		case arg == "..." || strings.HasSuffix(arg, "/..."):
+			found, err = markdownFiles([]string{filepath.FromSlash(cmp.Or(strings.TrimSuffix(strings.TrimSuffix(arg, "..."), "/"), "."))})
+		case strings.ContainsAny(arg, "*?["):
+			found, err = globFiles(arg)
+			if err == nil && len(found) == 0 {
+				err = fmt.Errorf("error: %s matches no files", arg)
+			}
+		default:
+			info, serr := os.Stat(arg)
+			if serr != nil || !info.IsDir() {
+				paths = append(paths, arg)
+				continue
+			}
+			found, err = markdownFiles([]string{arg})
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range found {
+			if selected(filepath.ToSlash(filepath.Clean(p)), include, exclude) {
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// isDocument reports whether the file is a document embedmd processes, in
+// markdown, reStructuredText, AsciiDoc, or a Jupyter notebook.
+func isDocument(path string) bool {
+	switch filepath.Ext(path) {
+	case ".md", ".rst", ".adoc", ".asciidoc", ".ipynb":
+		return true
+	}
+	return false
+}
+
+// globFiles returns the files matching the slash separated glob pattern, in
+// lexical order, skipping hidden directories.
+func globFiles(pattern string) ([]string, error) {
+	elems := strings.Split(pattern, "/")
+	i := 0
+	for i < len(elems)-1 && !strings.ContainsAny(elems[i], "*?[") {
+		i++
+	}
+	root := filepath.FromSlash(cmp.Or(strings.Join(elems[:i], "/"), "."))
+	if _, err := filepath.Match(elems[len(elems)-1], ""); err != nil {
+		return nil, fmt.Errorf("error: bad pattern %s: %v", pattern, err)
+	}
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case d.IsDir() && path != root && strings.HasPrefix(d.Name(), "."):
+			return filepath.SkipDir
+		case d.IsDir():
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if matchElems(elems[i:], strings.Split(filepath.ToSlash(rel), "/")) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// selected reports whether the file matches one of the include patterns, if
+// any, and none of the exclude ones.
+func selected(name string, include, exclude []string) bool {
+	for _, p := range exclude {
+		if gitignoreMatch(p, name) {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, p := range include {
+		if gitignoreMatch(p, name) {
+			return true
+		}
+	}
+	return false
+}
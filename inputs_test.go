@@ -0,0 +1,78 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"README.md", "docs/a.md", "docs/b.txt", "docs/api/c.md", "docs/api/drafts/d.md",
+		"docs/.hidden/e.md", "other/f.md", "other/g.adoc",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	tc := []struct {
+		name             string
+		args             []string
+		include, exclude []string
+		want             string
+		err              string
+	}{
+		{name: "files", args: []string{"README.md", "docs/b.txt", "missing.md"}, want: "README.md docs/b.txt missing.md"},
+		{name: "recursive", args: []string{"./docs/..."}, want: "docs/a.md docs/api/c.md docs/api/drafts/d.md"},
+		{name: "current directory", args: []string{"..."}, want: "README.md docs/a.md docs/api/c.md docs/api/drafts/d.md other/f.md other/g.adoc"},
+		{name: "directory", args: []string{"docs/api"}, want: "docs/api/c.md docs/api/drafts/d.md"},
+		{name: "glob", args: []string{"docs/**/*.md"}, want: "docs/a.md docs/api/c.md docs/api/drafts/d.md"},
+		{name: "glob in directory", args: []string{"docs/*/*.md"}, want: "docs/api/c.md"},
+		{name: "glob of any file", args: []string{"docs/*"}, want: "docs/a.md docs/b.txt"},
+		{name: "exclude", args: []string{"..."}, exclude: []string{"drafts/", "README.md"}, want: "docs/a.md docs/api/c.md other/f.md other/g.adoc"},
+		{name: "include", args: []string{"./..."}, include: []string{"docs/api/"}, want: "docs/api/c.md docs/api/drafts/d.md"},
+		{name: "explicit files are kept", args: []string{"README.md"}, exclude: []string{"*.md"}, want: "README.md"},
+		{name: "no match", args: []string{"docs/**/*.rst"}, err: "error: docs/**/*.rst matches no files"},
+		{name: "bad pattern", args: []string{"docs/[.md"}, err: "error: bad pattern docs/[.md: syntax error in pattern"},
+	}
+	for _, tt := range tc {
+		paths, err := expandPaths(tt.args, tt.include, tt.exclude)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		for i, p := range paths {
+			paths[i] = filepath.ToSlash(p)
+		}
+		if got := strings.Join(paths, " "); got != tt.want {
+			t.Errorf("case [%s]: expected %s; got %s", tt.name, tt.want, got)
+		}
+	}
+}
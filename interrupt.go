@@ -0,0 +1,70 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+var (
+	// interrupted is set when an interrupt is received, so no more files are
+	// processed.
+	interrupted atomic.Bool
+	// interruptContext is canceled when an interrupt is received, so the
+	// files being processed are left untouched.
+	interruptContext, cancelInterrupt = context.WithCancel(context.Background())
+	// writing is held while a file is being written, so a second interrupt
+	// doesn't leave it half written.
+	writing sync.Mutex
+)
+
+// handleInterrupts stops processing files on the first interrupt, leaving
+// the current one untouched unless it's being written, and exits on the
+// second one as soon as no file is being written.
+func handleInterrupts() {
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-c
+		interrupted.Store(true)
+		cancelInterrupt()
+		fmt.Fprintln(stderr, "interrupted: stopping, interrupt again to stop now")
+		<-c
+		writing.Lock()
+		fmt.Fprintln(stderr, "interrupted: stopped")
+		os.Exit(130)
+	}()
+}
+
+// interruptedError reports the files that were and weren't processed when
+// a run is interrupted.
+type interruptedError struct {
+	done, pending []string
+}
+
+func (e *interruptedError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "interrupted: processed %d of %d files", len(e.done), len(e.done)+len(e.pending))
+	for _, p := range e.pending {
+		fmt.Fprintf(&b, "\n  not processed: %s", p)
+	}
+	return b.String()
+}
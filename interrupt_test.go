@@ -0,0 +1,79 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+func TestInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for _, name := range []string{"a.md", "b.md"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("# "+name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	defer interrupted.Store(false)
+	interrupted.Store(true)
+	_, err := embed(paths, false, true)
+	want := "interrupted: processed 0 of 2 files\n" +
+		"  not processed: " + paths[0] + "\n" +
+		"  not processed: " + paths[1]
+	if err == nil || err.Error() != want {
+		t.Errorf("expected error %q; got %v", want, err)
+	}
+}
+
+func TestInterruptedProcessing(t *testing.T) {
+	dir := t.TempDir()
+	doc := "[embedmd]:# (code.go)\n"
+	var paths []string
+	for _, name := range []string{"a.md", "b.md"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	// The interrupt is received while the first file is being processed.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer interrupted.Store(false)
+	f := embedmd.FetcherFunc(func(dir, path string) ([]byte, error) {
+		interrupted.Store(true)
+		cancel()
+		return []byte("package main\n"), nil
+	})
+	_, err := embed(paths, true, false, embedmd.WithFetcher(f), embedmd.WithContext(ctx))
+	want := "interrupted: processed 0 of 2 files\n" +
+		"  not processed: " + paths[0] + "\n" +
+		"  not processed: " + paths[1]
+	if err == nil || err.Error() != want {
+		t.Errorf("expected error %q; got %v", want, err)
+	}
+	for _, path := range paths {
+		if b, _ := os.ReadFile(path); string(b) != doc {
+			t.Errorf("expected %s untouched; got %q", path, b)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// issueLabel labels the issues filed by embedmd, so they can be found again.
+const issueLabel = "embedmd"
+
+// fingerprintPrefix starts the comment identifying the stale document an
+// issue is about, in its body.
+const fingerprintPrefix = "<!-- embedmd fingerprint "
+
+// issueTracker files issues about stale documents in a GitHub or GitLab
+// project.
+type issueTracker struct {
+	// kind is github or gitlab, and api the base URL of its REST API.
+	kind, api string
+	// project is the path of the project, such as org/repo.
+	project string
+	token   string
+	client  *http.Client
+}
+
+// newIssueTracker returns the tracker of the project given as host/path,
+// e.g. github.com/org/repo, using the kind of the given forges for hosts
+// other than github.com and gitlab.com. The token is read from GITHUB_TOKEN
+// or GITLAB_TOKEN.
+func newIssueTracker(project string, forges []embedmd.Forge) (*issueTracker, error) {
+	host, path, _ := strings.Cut(strings.Trim(project, "/"), "/")
+	if host == "" || !strings.Contains(path, "/") {
+		return nil, fmt.Errorf("error: -file-issues: bad project %q, should be host/owner/repo", project)
+	}
+	kind := map[string]string{"github.com": "github", "gitlab.com": "gitlab"}[host]
+	for _, f := range forges {
+		if f.Host == host {
+			kind = f.Kind
+		}
+	}
+	t := &issueTracker{kind: kind, project: path, client: http.DefaultClient}
+	var env string
+	switch {
+	case host == "github.com":
+		t.api, env = "https://api.github.com", "GITHUB_TOKEN"
+	case kind == "github":
+		t.api, env = "https://"+host+"/api/v3", "GITHUB_TOKEN"
+	case kind == "gitlab":
+		t.api, env = "https://"+host+"/api/v4", "GITLAB_TOKEN"
+	case kind == "":
+		return nil, fmt.Errorf("error: -file-issues: unknown forge %s, set its kind with -forge", host)
+	default:
+		return nil, fmt.Errorf("error: -file-issues: cannot file issues on %s forges, only on github and gitlab ones", kind)
+	}
+	if t.token = os.Getenv(env); t.token == "" {
+		return nil, fmt.Errorf("error: -file-issues: %s is not set", env)
+	}
+	return t, nil
+}
+
+// fileIssues checks the given files, opening or updating an issue for each
+// of the stale ones, with its diff and mentioning its owners as given by
+// rules. It reports whether any file was stale.
+func fileIssues(paths []string, t *issueTracker, rules []ownerRule, opts ...embedmd.Option) (bool, error) {
+	planned, err := planFiles(paths, opts...)
+	if err != nil {
+		return false, err
+	}
+	stale := false
+	for i, f := range planned {
+		summary.record(paths[i], f != nil)
+		if f == nil {
+			continue
+		}
+		stale = true
+		name, err := repoPath(paths[i])
+		if err != nil {
+			return false, err
+		}
+		action, link, err := t.file(name, f.Diff, ownersOf(rules, name))
+		if err != nil {
+			return false, fmt.Errorf("could not file issue for %s: %v", name, err)
+		}
+		fmt.Fprintf(stdout, "%s %s: %s\n", action, name, link)
+	}
+	return stale, nil
+}
+
+// fingerprint identifies the issues about the document at the given slash
+// separated path, whatever its content.
+func fingerprint(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:8])
+}
+
+// issueBody returns the body of the issue about the stale document name.
+func issueBody(name, diff string, owners []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "The code embedded in `%s` is out of date with its sources. Run `embedmd -w %s` to update it.\n\n", name, name)
+	var mentions []string
+	for _, o := range owners {
+		// Only users and teams can be mentioned, not emails.
+		if strings.HasPrefix(o, "@") {
+			mentions = append(mentions, o)
+		}
+	}
+	if len(mentions) > 0 {
+		fmt.Fprintf(&b, "Owners: %s\n\n", strings.Join(mentions, " "))
+	}
+	fmt.Fprintf(&b, "```diff\n%s```\n\n", diff)
+	fmt.Fprintf(&b, "%s%s -->\n", fingerprintPrefix, fingerprint(name))
+	return b.String()
+}
+
+// trackedIssue is an open issue filed by embedmd, in the fields common to
+// GitHub and GitLab.
+type trackedIssue struct {
+	id         int
+	link, body string
+}
+
+// file opens an issue about the stale document name, or updates the open
+// one with the same fingerprint. It returns what was done, opened, updated,
+// or unchanged, and the link to the issue.
+func (t *issueTracker) file(name, diff string, owners []string) (action, link string, err error) {
+	body := issueBody(name, diff, owners)
+	issues, err := t.openIssues()
+	if err != nil {
+		return "", "", err
+	}
+	mark := fingerprintPrefix + fingerprint(name) + " -->"
+	for _, is := range issues {
+		switch {
+		case !strings.Contains(is.body, mark):
+			continue
+		case is.body == body:
+			return "unchanged", is.link, nil
+		}
+		return "updated", is.link, t.update(is.id, body)
+	}
+	link, err = t.open("embedmd: "+name+" is out of date", body)
+	return "opened", link, err
+}
+
+// openIssues returns the open issues labeled by embedmd.
+func (t *issueTracker) openIssues() ([]trackedIssue, error) {
+	var issues []trackedIssue
+	for page := 1; ; page++ {
+		q := url.Values{"labels": {issueLabel}, "per_page": {"100"}, "page": {strconv.Itoa(page)}}
+		var found []struct {
+			Number  int    `json:"number"`
+			IID     int    `json:"iid"`
+			HTMLURL string `json:"html_url"`
+			WebURL  string `json:"web_url"`
+			Body    string `json:"body"`
+			Desc    string `json:"description"`
+		}
+		if t.kind == "gitlab" {
+			q.Set("state", "opened")
+		} else {
+			q.Set("state", "open")
+		}
+		if err := t.call("GET", t.issuesURL()+"?"+q.Encode(), nil, &found); err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return issues, nil
+		}
+		for _, f := range found {
+			if t.kind == "gitlab" {
+				issues = append(issues, trackedIssue{f.IID, f.WebURL, f.Desc})
+			} else {
+				issues = append(issues, trackedIssue{f.Number, f.HTMLURL, f.Body})
+			}
+		}
+	}
+}
+
+// open opens an issue, returning its link.
+func (t *issueTracker) open(title, body string) (string, error) {
+	var req any = map[string]any{"title": title, "body": body, "labels": []string{issueLabel}}
+	if t.kind == "gitlab" {
+		req = map[string]any{"title": title, "description": body, "labels": issueLabel}
+	}
+	var created struct {
+		HTMLURL string `json:"html_url"`
+		WebURL  string `json:"web_url"`
+	}
+	if err := t.call("POST", t.issuesURL(), req, &created); err != nil {
+		return "", err
+	}
+	if t.kind == "gitlab" {
+		return created.WebURL, nil
+	}
+	return created.HTMLURL, nil
+}
+
+// update replaces the body of the issue with the given id.
+func (t *issueTracker) update(id int, body string) error {
+	u := t.issuesURL() + "/" + strconv.Itoa(id)
+	if t.kind == "gitlab" {
+		return t.call("PUT", u, map[string]any{"description": body}, nil)
+	}
+	return t.call("PATCH", u, map[string]any{"body": body}, nil)
+}
+
+// issuesURL returns the URL of the issues of the project.
+func (t *issueTracker) issuesURL() string {
+	if t.kind == "gitlab" {
+		return t.api + "/projects/" + url.PathEscape(t.project) + "/issues"
+	}
+	return t.api + "/repos/" + t.project + "/issues"
+}
+
+// call sends a request with the JSON encoding of in, if not nil, decoding
+// the response into out, if not nil.
+func (t *issueTracker) call(method, u string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.kind == "gitlab" {
+		req.Header.Set("PRIVATE-TOKEN", t.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+	}
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: %s", method, u, res.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// issueTracker returns the tracker of the project set with -file-issues.
+func (o *options) issueTracker() (*issueTracker, error) {
+	forges, err := o.forgeList()
+	if err != nil {
+		return nil, err
+	}
+	return newIssueTracker(o.fileIssues, forges)
+}
+
+// issueOwners returns the rules giving the owners mentioned in issues, which
+// are only required when set with -codeowners or -owner.
+func (o *options) issueOwners() ([]ownerRule, error) {
+	rules, err := loadOwners(o.codeowners, o.owners)
+	if err != nil && o.codeowners == "" && len(o.owners) == 0 {
+		return nil, nil
+	}
+	return rules, err
+}
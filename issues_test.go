@@ -0,0 +1,118 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// fakeTracker serves the issues API of GitHub or GitLab from memory.
+type fakeTracker struct {
+	kind   string
+	issues []map[string]any
+}
+
+func (f *fakeTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, body := "number", "body"
+	if f.kind == "gitlab" {
+		id, body = "iid", "description"
+	}
+	var req map[string]any
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&req) //nolint:errcheck
+	}
+	switch {
+	case r.Method == "GET" && r.URL.Query().Get("page") == "1":
+		json.NewEncoder(w).Encode(f.issues) //nolint:errcheck
+	case r.Method == "GET":
+		fmt.Fprint(w, "[]")
+	case r.Method == "POST":
+		n := len(f.issues) + 1
+		issue := map[string]any{id: n, body: req[body], "html_url": fmt.Sprintf("https://issues/%d", n), "web_url": fmt.Sprintf("https://issues/%d", n)}
+		f.issues = append(f.issues, issue)
+		json.NewEncoder(w).Encode(issue) //nolint:errcheck
+	default:
+		n := 0
+		fmt.Sscanf(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], "%d", &n)
+		f.issues[n-1][body] = req[body]
+	}
+}
+
+func TestFileIssue(t *testing.T) {
+	for _, kind := range []string{"github", "gitlab"} {
+		f := &fakeTracker{kind: kind}
+		ts := httptest.NewServer(f)
+		tracker := &issueTracker{kind: kind, api: ts.URL, project: "org/repo", token: "secret", client: ts.Client()}
+
+		steps := []struct {
+			diff, action string
+		}{
+			{diff: "-old\n+new\n", action: "opened"},
+			{diff: "-old\n+new\n", action: "unchanged"},
+			{diff: "-old\n+newer\n", action: "updated"},
+		}
+		for _, s := range steps {
+			action, link, err := tracker.file("docs/a.md", s.diff, []string{"@org/docs", "docs@example.com"})
+			if err != nil {
+				t.Fatalf("%s: %v", kind, err)
+			}
+			if action != s.action || link != "https://issues/1" {
+				t.Errorf("%s: expected %s https://issues/1; got %s %s", kind, s.action, action, link)
+			}
+		}
+		action, link, err := tracker.file("docs/b.md", "-b\n", nil)
+		if err != nil || action != "opened" || link != "https://issues/2" {
+			t.Errorf("%s: expected a second issue to be opened; got %s %s, %v", kind, action, link, err)
+		}
+		ts.Close()
+	}
+}
+
+func TestIssueBody(t *testing.T) {
+	got := issueBody("docs/a.md", "-old\n+new\n", []string{"@org/docs", "docs@example.com"})
+	want := "The code embedded in `docs/a.md` is out of date with its sources. Run `embedmd -w docs/a.md` to update it.\n\n" +
+		"Owners: @org/docs\n\n```diff\n-old\n+new\n```\n\n" + fingerprintPrefix + fingerprint("docs/a.md") + " -->\n"
+	if got != want {
+		t.Errorf("expected body\n%q\ngot\n%q", want, got)
+	}
+}
+
+func TestNewIssueTracker(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "gh")
+	t.Setenv("GITLAB_TOKEN", "")
+	forges := []embedmd.Forge{{Host: "ghe.example.com", Kind: "github"}, {Host: "git.example.com", Kind: "gitea"}}
+	tc := []struct {
+		project, api, err string
+	}{
+		{project: "github.com/org/repo", api: "https://api.github.com"},
+		{project: "ghe.example.com/org/repo", api: "https://ghe.example.com/api/v3"},
+		{project: "gitlab.com/group/project", err: "error: -file-issues: GITLAB_TOKEN is not set"},
+		{project: "git.example.com/org/repo", err: "error: -file-issues: cannot file issues on gitea forges, only on github and gitlab ones"},
+		{project: "example.com/org/repo", err: "error: -file-issues: unknown forge example.com, set its kind with -forge"},
+		{project: "github.com/org", err: `error: -file-issues: bad project "github.com/org", should be host/owner/repo`},
+	}
+	for _, tt := range tc {
+		tracker, err := newIssueTracker(tt.project, forges)
+		if eqErr(t, tt.project, err, tt.err) && tracker.api != tt.api {
+			t.Errorf("case [%s]: expected API %s; got %s", tt.project, tt.api, tracker.api)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The journal records the files rewritten by a run with -resume, with the
+// hash of their new content, so if the run is interrupted, running it again
+// with -resume skips the files that were already rewritten and haven't
+// changed since. The journal is removed when the run ends, unless it was
+// interrupted.
+var (
+	// journalPath is the path of the journal, in the user cache directory
+	// if empty.
+	journalPath string
+	resume      bool
+)
+
+type journal struct {
+	f    *os.File
+	path string
+	done map[string]string
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// defaultJournalPath returns the path of the journal of the runs in the
+// current directory, in the user cache directory.
+func defaultJournalPath() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("%v, set the journal with -journal", err)
+	}
+	return filepath.Join(dir, "embedmd", "journal", hashBytes([]byte(wd))[:16]), nil
+}
+
+// openJournal opens the journal, loading its entries, when resuming, or
+// returns nil otherwise.
+func openJournal() (*journal, error) {
+	if !resume {
+		return nil, nil
+	}
+	path := journalPath
+	if path == "" {
+		var err error
+		if path, err = defaultJournalPath(); err != nil {
+			return nil, fmt.Errorf("could not locate journal: %v", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("could not create journal: %v", err)
+		}
+	}
+	j := &journal{path: path, done: map[string]string{}}
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("could not read journal: %v", err)
+	}
+	s := bufio.NewScanner(strings.NewReader(string(b)))
+	for s.Scan() {
+		hash, file, ok := strings.Cut(s.Text(), " ")
+		if !ok {
+			return nil, fmt.Errorf("%s: corrupted journal entry %q", path, s.Text())
+		}
+		j.done[file] = hash
+	}
+	if j.f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		return nil, fmt.Errorf("could not open journal: %v", err)
+	}
+	return j, nil
+}
+
+// completed reports whether the file was rewritten by a previous run and
+// still has the content it was rewritten with.
+func (j *journal) completed(path string) bool {
+	if j == nil {
+		return false
+	}
+	hash, ok := j.done[filepath.Clean(path)]
+	if !ok {
+		return false
+	}
+	b, err := readFile(path)
+	return err == nil && hashBytes(b) == hash
+}
+
+// record adds the file and its new content to the journal, syncing it to
+// disk so it survives crashes.
+func (j *journal) record(path string, content []byte) error {
+	if j == nil {
+		return nil
+	}
+	if _, err := fmt.Fprintf(j.f, "%s %s\n", hashBytes(content), filepath.Clean(path)); err != nil {
+		return fmt.Errorf("could not write journal: %v", err)
+	}
+	return j.f.Sync()
+}
+
+// finish closes the journal, removing it unless it's kept to resume the
+// run, which didn't complete.
+func (j *journal) finish(keep bool) error {
+	if j == nil {
+		return nil
+	}
+	if err := j.f.Close(); err != nil {
+		return err
+	}
+	if keep {
+		return nil
+	}
+	return os.Remove(j.path)
+}
@@ -0,0 +1,172 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+func TestResume(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	defer func(p string, r bool) { journalPath, resume = p, r }(journalPath, resume)
+	journalPath = filepath.Join(dir, "journal")
+
+	write("a.go", "package a\n")
+	write("a.md", "[embedmd]:# (a.go)\n")
+	write("b.md", "[embedmd]:# (b.go)\n")
+	paths := []string{filepath.Join(dir, "a.md"), filepath.Join(dir, "b.md")}
+
+	// Without -resume, there's no journal.
+	resume = false
+	if _, err := embed(paths, true, false); err == nil {
+		t.Fatalf("expected the run to fail, since b.go doesn't exist")
+	}
+	if _, err := os.Stat(journalPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no journal without -resume: %v", err)
+	}
+
+	// The first run is interrupted while processing b.md.
+	write("a.md", "[embedmd]:# (a.go)\n")
+	resume = true
+	defer interrupted.Store(false)
+	f := embedmd.FetcherFunc(func(dir, path string) ([]byte, error) {
+		if path == "b.go" {
+			interrupted.Store(true)
+			return nil, errors.New("canceled")
+		}
+		return os.ReadFile(filepath.Join(dir, path))
+	})
+	ierr := (*interruptedError)(nil)
+	if _, err := embed(paths, true, false, embedmd.WithFetcher(f)); !errors.As(err, &ierr) {
+		t.Fatalf("expected the first run to be interrupted, got %v", err)
+	}
+	interrupted.Store(false)
+	if want := "[embedmd]:# (a.go)\n```go\npackage a\n```\n"; read("a.md") != want {
+		t.Fatalf("expected a.md to be rewritten, got %q", read("a.md"))
+	}
+	if _, err := os.Stat(journalPath); err != nil {
+		t.Fatalf("expected the journal to be kept after an interruption: %v", err)
+	}
+
+	// Resuming skips a.md, which hasn't changed since it was rewritten.
+	write("a.go", "package changed\n")
+	write("b.go", "package b\n")
+	if _, err := embed(paths, true, false); err != nil {
+		t.Fatalf("expected the resumed run to succeed: %v", err)
+	}
+	if want := "[embedmd]:# (a.go)\n```go\npackage a\n```\n"; read("a.md") != want {
+		t.Errorf("expected a.md to be skipped, got %q", read("a.md"))
+	}
+	if want := "[embedmd]:# (b.go)\n```go\npackage b\n```\n"; read("b.md") != want {
+		t.Errorf("expected b.md to be rewritten, got %q", read("b.md"))
+	}
+	if _, err := os.Stat(journalPath); !os.IsNotExist(err) {
+		t.Errorf("expected the journal to be removed after a complete run: %v", err)
+	}
+
+	// Failing runs keep the journal, until a run completes.
+	write("c.md", "[embedmd]:# (c.go)\n")
+	cpaths := []string{filepath.Join(dir, "a.md"), filepath.Join(dir, "c.md")}
+	if _, err := embed(cpaths, true, false); err == nil {
+		t.Fatalf("expected the run to fail, since c.go doesn't exist")
+	}
+	if _, err := os.Stat(journalPath); err != nil {
+		t.Errorf("expected the journal to be kept after a failed run: %v", err)
+	}
+	write("c.go", "package c\n")
+	if _, err := embed(cpaths, true, false); err != nil {
+		t.Fatalf("expected the resumed run to succeed: %v", err)
+	}
+	if _, err := os.Stat(journalPath); !os.IsNotExist(err) {
+		t.Errorf("expected the journal to be removed after a complete run: %v", err)
+	}
+
+	// By default, the journal is in the user cache directory.
+	journalPath = ""
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	t.Setenv("HOME", filepath.Join(dir, "home"))
+	if p, err := defaultJournalPath(); err != nil || !strings.HasPrefix(p, dir) {
+		t.Errorf("expected the journal in the cache directory under %s, got %q, %v", dir, p, err)
+	}
+
+	if _, err := embed(paths, false, true); err == nil || err.Error() != "error: -resume can only be used with -w" {
+		t.Errorf("expected an error using -resume without -w, got %v", err)
+	}
+}
+
+func TestTransactional(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	defer func(tr bool) { transactional = tr }(transactional)
+	transactional = true
+
+	write("a.go", "package a\n")
+	write("a.md", "[embedmd]:# (a.go)\n")
+	write("b.md", "[embedmd]:# (b.go)\n")
+	write("c.md", "Nothing to embed\n")
+	paths := []string{filepath.Join(dir, "a.md"), filepath.Join(dir, "b.md"), filepath.Join(dir, "c.md")}
+
+	// Nothing is written, since b.go doesn't exist.
+	if _, err := embed(paths, true, false); err == nil {
+		t.Fatalf("expected the run to fail")
+	}
+	if got := read("a.md"); got != "[embedmd]:# (a.go)\n" {
+		t.Errorf("expected a.md to be left untouched, got %q", got)
+	}
+
+	write("b.go", "package b\n")
+	if _, err := embed(paths, true, false); err != nil {
+		t.Fatalf("expected the run to succeed: %v", err)
+	}
+	if want := "[embedmd]:# (a.go)\n```go\npackage a\n```\n"; read("a.md") != want {
+		t.Errorf("expected a.md to be rewritten, got %q", read("a.md"))
+	}
+	if want := "[embedmd]:# (b.go)\n```go\npackage b\n```\n"; read("b.md") != want {
+		t.Errorf("expected b.md to be rewritten, got %q", read("b.md"))
+	}
+	if got := read("c.md"); got != "Nothing to embed\n" {
+		t.Errorf("expected c.md to be left untouched, got %q", got)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// keepGoing is set to process all the files, reporting the errors of all
+// their commands instead of stopping at the first one.
+var keepGoing bool
+
+// The exit statuses of the runs with -keep-going, so CI scripts can tell
+// stale documents from broken ones. When several kinds of errors are found,
+// the status is that of the first in this order.
+const (
+	exitChanged = 1
+	exitError   = 2
+	exitParse   = 3
+	exitFetch   = 4
+	exitCommand = 5
+)
+
+// fileError is the error processing a file.
+type fileError struct {
+	path string
+	err  error
+}
+
+// fileErrors are the errors of the files that failed, in the order they
+// were processed.
+type fileErrors []fileError
+
+// Error lists the errors of the commands of each file on their own lines,
+// grouped by file.
+func (errs fileErrors) Error() string {
+	var msgs []string
+	for _, fe := range errs {
+		path := filepath.ToSlash(fe.path)
+		var cmdErrs embedmd.Errors
+		if !errors.As(fe.err, &cmdErrs) {
+			msgs = append(msgs, fmt.Sprintf("%s:%v", path, fe.err))
+			continue
+		}
+		for _, err := range cmdErrs {
+			msgs = append(msgs, fmt.Sprintf("%s:%v", path, err))
+		}
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (errs fileErrors) Unwrap() []error {
+	unwrapped := make([]error, len(errs))
+	for i, fe := range errs {
+		unwrapped[i] = fe.err
+	}
+	return unwrapped
+}
+
+// exitCode returns the exit status of a run with -keep-going failing with
+// err.
+func exitCode(err error) int {
+	all := []error{err}
+	var errs fileErrors
+	if errors.As(err, &errs) {
+		all = errs.Unwrap()
+	}
+	found := map[int]bool{}
+	for _, err := range all {
+		var cmdErrs embedmd.Errors
+		if !errors.As(err, &cmdErrs) {
+			return exitError
+		}
+		for _, cmdErr := range cmdErrs {
+			switch cmdErr.Kind {
+			case embedmd.ParseError:
+				found[exitParse] = true
+			case embedmd.FetchError:
+				found[exitFetch] = true
+			default:
+				found[exitCommand] = true
+			}
+		}
+	}
+	for _, code := range []int{exitParse, exitFetch, exitCommand} {
+		if found[code] {
+			return code
+		}
+	}
+	return exitError
+}
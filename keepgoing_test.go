@@ -0,0 +1,87 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+func TestKeepGoingFlag(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.md":    "[embedmd]:# (code.go captoin=x)\n\n[embedmd]:# (missing.go)\n",
+		"b.md":    "[embedmd]:# (code.go)\n",
+		"c.md":    "[embedmd]:# (code.go /nothing/)\n",
+		"code.go": "package main\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(v bool) { keepGoing = v }(keepGoing)
+	keepGoing = true
+	defer func(o io.Writer) { stdout = o }(stdout)
+	stdout = io.Discard
+
+	paths := []string{filepath.Join(dir, "a.md"), filepath.Join(dir, "b.md"), filepath.Join(dir, "c.md")}
+	_, err := embed(paths, true, false, embedmd.WithKeepGoing())
+	a, c := filepath.ToSlash(paths[0]), filepath.ToSlash(paths[2])
+	want := a + ":1: unknown attribute \"captoin\"\n" +
+		a + ":3: could not read missing.go: open " + filepath.Join(dir, "missing.go") + ": no such file or directory\n" +
+		c + ":1: could not extract content from code.go: could not match \"/nothing/\""
+	if err == nil || err.Error() != want {
+		t.Fatalf("expected errors\n%s\ngot\n%v", want, err)
+	}
+	if got := exitCode(err); got != exitParse {
+		t.Errorf("expected exit code %d; got %d", exitParse, got)
+	}
+	// The files without errors are rewritten.
+	if b, err := os.ReadFile(paths[1]); err != nil || string(b) != "[embedmd]:# (code.go)\n```go\npackage main\n```\n" {
+		t.Errorf("expected b.md to be rewritten; got %q, %v", b, err)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	errs := func(kinds ...embedmd.ErrorKind) embedmd.Errors {
+		var errs embedmd.Errors
+		for i, k := range kinds {
+			errs = append(errs, &embedmd.Error{Line: i + 1, Kind: k, Err: errors.New("failed")})
+		}
+		return errs
+	}
+	tc := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"parse", fileErrors{{"a.md", errs(embedmd.FetchError, embedmd.ParseError)}}, exitParse},
+		{"fetch", fileErrors{{"a.md", errs(embedmd.CommandError)}, {"b.md", errs(embedmd.FetchError)}}, exitFetch},
+		{"command", fileErrors{{"a.md", errs(embedmd.CommandError)}}, exitCommand},
+		{"stdin", errs(embedmd.FetchError), exitFetch},
+		{"other", fileErrors{{"a.md", errs(embedmd.ParseError)}, {"b.md", errors.New("not a markdown file")}}, exitError},
+		{"usage", errors.New("error: bad flag"), exitError},
+	}
+	for _, tt := range tc {
+		if got := exitCode(tt.err); got != tt.code {
+			t.Errorf("case [%s]: expected exit code %d; got %d", tt.name, tt.code, got)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// lintDiagnostic is a problem found by -lint in a document, as written with
+// -lint-format json.
+type lintDiagnostic struct {
+	File string `json:"file"`
+	embedmd.Diagnostic
+}
+
+// lintFiles prints the problems found in the commands of the documents, or
+// of the standard input without files, in the given format, text or json,
+// reporting whether there's any.
+func lintFiles(paths []string, format string, opts ...embedmd.Option) (bool, error) {
+	var found []lintDiagnostic
+	if len(paths) == 0 {
+		diags, err := embedmd.Lint(stdin, opts...)
+		if err != nil {
+			return false, fmt.Errorf("<stdin>:%v", err)
+		}
+		for _, d := range diags {
+			found = append(found, lintDiagnostic{"<stdin>", d})
+		}
+	}
+	for _, path := range paths {
+		b, err := readFile(path)
+		if err != nil {
+			return false, err
+		}
+		popts := append([]embedmd.Option{embedmd.WithBaseDir(filepath.Dir(path)), embedmd.WithFormat(embedmd.FormatOf(path))}, opts...)
+		diags, err := embedmd.Lint(bytes.NewReader(b), popts...)
+		if err != nil {
+			return false, fmt.Errorf("%s:%v", filepath.ToSlash(path), err)
+		}
+		for _, d := range diags {
+			found = append(found, lintDiagnostic{filepath.ToSlash(path), d})
+		}
+	}
+	if format == "json" {
+		if found == nil {
+			found = []lintDiagnostic{}
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return len(found) > 0, enc.Encode(found)
+	}
+	for _, d := range found {
+		fmt.Fprintf(stdout, "%s:%d:%d: %s\n", d.File, d.Line, d.Column, d.Message)
+	}
+	return len(found) > 0, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLintFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "code.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ok := filepath.Join(dir, "ok.md")
+	if err := os.WriteFile(ok, []byte("[embedmd]:# (code.go)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad.md")
+	if err := os.WriteFile(bad, []byte("# Title\n[embedmd]:# (code.go captoin=x)\n[embedmd]:# (gone.go)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(o io.Writer) { stdout = o }(stdout)
+	var out bytes.Buffer
+	stdout = &out
+
+	found, err := lintFiles([]string{ok}, "text")
+	if err != nil || found || out.Len() > 0 {
+		t.Errorf("expected no problems in %s; got %v, %v, %q", ok, found, err, out.String())
+	}
+
+	found, err = lintFiles([]string{ok, bad}, "text")
+	if err != nil || !found {
+		t.Fatalf("expected problems in %s; got %v, %v", bad, found, err)
+	}
+	name := filepath.ToSlash(bad)
+	want := name + `:2:22: unknown attribute "captoin"` + "\n" + name + ":3:14: could not find gone.go\n"
+	if out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+
+	out.Reset()
+	if _, err := lintFiles([]string{bad}, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var diags []lintDiagnostic
+	if err := json.Unmarshal(out.Bytes(), &diags); err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 2 || diags[1].File != name || diags[1].Line != 3 || diags[1].Column != 14 || diags[1].Message != "could not find gone.go" {
+		t.Errorf("unexpected diagnostics %+v", diags)
+	}
+}
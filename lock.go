@@ -0,0 +1,219 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// A lockFile records the remote sources embedded, resolved to their URL and
+// revision, with the hash of their content, so builds of the docs can be
+// reproduced: with -frozen, the sources fetched must match it.
+type lockFile struct {
+	path   string
+	frozen bool
+	// repos are the repositories of repo:// paths, fetched into repoDir.
+	repos   map[string]embedmd.Repo
+	repoDir string
+
+	mu     sync.Mutex
+	locked map[string]lockEntry
+	seen   map[string]lockEntry
+}
+
+// lockEntry is a source as locked, by the path commands embed it with.
+type lockEntry struct {
+	Source   string `json:"source"`
+	URL      string `json:"url"`
+	Revision string `json:"revision,omitempty"`
+	SHA256   string `json:"sha256"`
+}
+
+// lockVersion is the version of the lock file format.
+const lockVersion = 1
+
+// loadLock reads the lock file at path, which must exist if frozen is set.
+func loadLock(path string, frozen bool) (*lockFile, error) {
+	l := &lockFile{path: path, frozen: frozen, repos: map[string]embedmd.Repo{}, locked: map[string]lockEntry{}, seen: map[string]lockEntry{}}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !frozen {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m struct {
+		Version int         `json:"version"`
+		Sources []lockEntry `json:"sources"`
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("bad lock file %s: %v", path, err)
+	}
+	if m.Version != lockVersion {
+		return nil, fmt.Errorf("bad lock file %s: unknown version %d", path, m.Version)
+	}
+	for _, e := range m.Sources {
+		l.locked[e.Source] = e
+	}
+	return l, nil
+}
+
+// middleware returns the middleware recording the remote sources fetched,
+// and checking them against the lock file when frozen.
+func (l *lockFile) middleware() embedmd.Middleware {
+	return func(next embedmd.Fetcher) embedmd.Fetcher {
+		return embedmd.FetcherContextFunc(func(ctx context.Context, dir, path string) ([]byte, error) {
+			b, err := embedmd.FetchContext(ctx, next, dir, path)
+			if err != nil || !isRemote(path) {
+				return b, err
+			}
+			e, err := l.resolve(dir, path)
+			if err != nil {
+				return nil, fmt.Errorf("could not lock %s: %v", path, err)
+			}
+			e.SHA256 = hashBytes(b)
+			if err := l.add(e); err != nil {
+				return nil, err
+			}
+			return b, nil
+		})
+	}
+}
+
+// isRemote reports whether path is a URL, or the path of a file at a git
+// revision or in a repository.
+func isRemote(path string) bool {
+	for _, scheme := range []string{"http://", "https://", "git://", "repo://"} {
+		if strings.HasPrefix(path, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the entry of the source at path, relative to dir, without
+// its hash.
+func (l *lockFile) resolve(dir, path string) (lockEntry, error) {
+	switch {
+	case strings.HasPrefix(path, "git://"):
+		spec := strings.TrimPrefix(path, "git://")
+		i := strings.LastIndex(spec, "@")
+		if i < 0 {
+			return lockEntry{}, fmt.Errorf("missing revision")
+		}
+		file, rev := filepath.FromSlash(spec[:i]), spec[i+1:]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		out, err := exec.Command("git", "-C", filepath.Dir(file), "rev-parse", "--verify", "--quiet", rev+"^{commit}").Output()
+		if err != nil {
+			return lockEntry{}, fmt.Errorf("unknown revision %q", rev)
+		}
+		// Files are named relative to the lock file, wherever it's used from.
+		rel, err := relativeTo(filepath.Dir(l.path), file)
+		if err != nil {
+			return lockEntry{}, err
+		}
+		return lockEntry{Source: "git://" + rel + "@" + rev, URL: rel, Revision: strings.TrimSpace(string(out))}, nil
+	case strings.HasPrefix(path, "repo://"):
+		name, file, _ := strings.Cut(strings.TrimPrefix(path, "repo://"), "/")
+		r, ok := l.repos[name]
+		if !ok {
+			return lockEntry{}, fmt.Errorf("unknown repository %q", name)
+		}
+		commit, err := embedmd.RepoCommit(l.repoDir, r)
+		if err != nil {
+			return lockEntry{}, err
+		}
+		return lockEntry{Source: path, URL: r.URL + "/" + file, Revision: commit}, nil
+	}
+	return lockEntry{Source: path, URL: path}, nil
+}
+
+// relativeTo returns the slash separated path of file relative to dir.
+func relativeTo(dir, file string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absDir, absFile)
+	return filepath.ToSlash(rel), err
+}
+
+// add records the source e, failing if frozen and it doesn't match the lock
+// file.
+func (l *lockFile) add(e lockEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seen[e.Source] = e
+	if !l.frozen {
+		return nil
+	}
+	locked, ok := l.locked[e.Source]
+	switch {
+	case !ok:
+		return fmt.Errorf("%s is not locked in %s", e.Source, l.path)
+	case locked.URL != e.URL || locked.Revision != e.Revision:
+		return fmt.Errorf("%s resolves to %s, locked at %s in %s", e.Source, strings.TrimSuffix(e.URL+"@"+e.Revision, "@"),
+			strings.TrimSuffix(locked.URL+"@"+locked.Revision, "@"), l.path)
+	case locked.SHA256 != e.SHA256:
+		return fmt.Errorf("%s changed since it was locked in %s", e.Source, l.path)
+	}
+	return nil
+}
+
+// write writes the lock file, with the sources fetched by the run replacing
+// those locked, unless frozen or no source was ever locked.
+func (l *lockFile) write() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.frozen || len(l.seen) == 0 && len(l.locked) == 0 {
+		return nil
+	}
+	for k, e := range l.seen {
+		l.locked[k] = e
+	}
+	m := struct {
+		Version int         `json:"version"`
+		Sources []lockEntry `json:"sources"`
+	}{Version: lockVersion, Sources: []lockEntry{}}
+	keys := make([]string, 0, len(l.locked))
+	for k := range l.locked {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		m.Sources = append(m.Sources, l.locked[k])
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.path, append(b, '\n'), 0666)
+}
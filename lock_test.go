@@ -0,0 +1,134 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+func TestLockFile(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		out, err := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q")
+	if err := os.WriteFile(filepath.Join(dir, "code.go"), []byte("package v1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("commit", "-qm", "v1")
+	git("tag", "v1")
+	v1 := git("rev-parse", "HEAD")
+
+	remote := "package remote\n"
+	fetch := func(l *lockFile) embedmd.Fetcher {
+		return embedmd.ChainFetcher(embedmd.FetcherFunc(func(dir, path string) ([]byte, error) {
+			if strings.HasPrefix(path, "https://") {
+				return []byte(remote), nil
+			}
+			return embedmd.NewFetcher(nil).Fetch(dir, path)
+		}), l.middleware())
+	}
+	in := "[embedmd]:# (git://code.go@v1)\n[embedmd]:# (https://example.com/x.go)\n[embedmd]:# (code.go)\n"
+	run := func(l *lockFile) error {
+		return embedmd.Process(&bytes.Buffer{}, strings.NewReader(in), embedmd.WithFetcher(fetch(l)), embedmd.WithBaseDir(dir))
+	}
+
+	path := filepath.Join(dir, "embedmd.lock")
+	if _, err := loadLock(path, true); err == nil {
+		t.Error("expected an error loading a missing lock file when frozen")
+	}
+	l, err := loadLock(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run(l); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.write(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "version": 1,
+  "sources": [
+    {
+      "source": "git://code.go@v1",
+      "url": "code.go",
+      "revision": "` + v1 + `",
+      "sha256": "` + hashBytes([]byte("package v1\n")) + `"
+    },
+    {
+      "source": "https://example.com/x.go",
+      "url": "https://example.com/x.go",
+      "sha256": "` + hashBytes([]byte(remote)) + `"
+    }
+  ]
+}
+`
+	if string(b) != want {
+		t.Errorf("expected lock file\n%s\ngot\n%s", want, b)
+	}
+
+	frozen := func() error {
+		t.Helper()
+		l, err := loadLock(path, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = run(l)
+		if werr := l.write(); werr != nil {
+			t.Fatal(werr)
+		}
+		return err
+	}
+	if err := frozen(); err != nil {
+		t.Errorf("expected the sources to match the lock file, got %v", err)
+	}
+
+	remote = "package changed\n"
+	eqErr(t, "changed", frozen(), "2: could not read https://example.com/x.go: https://example.com/x.go changed since it was locked in "+path)
+	remote = "package remote\n"
+
+	if err := os.WriteFile(filepath.Join(dir, "code.go"), []byte("package v2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("commit", "-qam", "v2")
+	git("tag", "-f", "v1")
+	eqErr(t, "moved", frozen(), "1: could not read git://code.go@v1: git://code.go@v1 resolves to code.go@"+git("rev-parse", "HEAD")+", locked at code.go@"+v1+" in "+path)
+
+	in = "[embedmd]:# (https://example.com/y.go)\n"
+	eqErr(t, "not locked", frozen(), "1: could not read https://example.com/y.go: https://example.com/y.go is not locked in "+path)
+	if b2, _ := os.ReadFile(path); !bytes.Equal(b, b2) {
+		t.Errorf("expected the lock file to be left unchanged when frozen, got\n%s", b2)
+	}
+}
@@ -0,0 +1,501 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// The severities of LSP diagnostics.
+const (
+	severityError   = 1
+	severityWarning = 2
+)
+
+// lspMessage is a JSON-RPC request, response, or notification read by the
+// lsp command. Notifications have no ID.
+type lspMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// lspResponse is the response to a request, with either a result, null if
+// none, or an error.
+type lspResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+	Error   *lspError       `json:"error,omitempty"`
+}
+
+// lspError is the error of a response, with one of the codes of JSON-RPC.
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *lspError) Error() string { return e.Message }
+
+// The codes of the errors of responses.
+const (
+	lspParseError     = -32700
+	lspMethodNotFound = -32601
+	lspInvalidParams  = -32602
+	lspInternalError  = -32603
+)
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+// lspParams holds the fields of the parameters of the requests and
+// notifications handled.
+type lspParams struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+	Position lspPosition `json:"position"`
+	Range    lspRange    `json:"range"`
+}
+
+// lspServer is the language server of the lsp command, serving a single
+// client.
+type lspServer struct {
+	out  io.Writer
+	opts []embedmd.Option
+	// docs holds the text of the open documents, by URI.
+	docs     map[string]string
+	shutdown bool
+}
+
+func lspUsage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd lsp [flags]\n")
+		fs.PrintDefaults()
+	}
+}
+
+// runLSP implements the lsp command, a language server for editors speaking
+// the Language Server Protocol on the standard input and output. It reports
+// the problems found by -lint as diagnostics while documents are edited,
+// and those of processing them once opened or saved, shows the content a
+// command embeds when hovering it, and offers to embed it again.
+func runLSP(args []string) int {
+	fs := flag.NewFlagSet("embedmd lsp", flag.ContinueOnError)
+	fs.Usage = lspUsage(fs)
+	o := newCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+	if err := setup(fs, o); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	opts, err := o.embedOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	s := &lspServer{out: stdout, opts: opts, docs: map[string]string{}}
+	if err := s.serve(stdin); err != nil {
+		fmt.Fprintf(stderr, "error: lsp: %v\n", err)
+		return 1
+	}
+	if !s.shutdown {
+		// Exiting without a shutdown request is an error for the protocol.
+		return 1
+	}
+	return 0
+}
+
+// serve handles the messages read from r until the exit notification. The
+// messages that can't be handled are answered with an error, or logged for
+// notifications, and the next ones are still served.
+func (s *lspServer) serve(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		b, err := readLSPMessage(br)
+		if err != nil {
+			return err
+		}
+		var m lspMessage
+		if err := json.Unmarshal(b, &m); err != nil {
+			// Without the ID of the message, the error is answered with a
+			// null one.
+			res := lspResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &lspError{Code: lspParseError, Message: fmt.Sprintf("bad message: %v", err)}}
+			if err := s.send(res); err != nil {
+				return err
+			}
+			continue
+		}
+		if m.Method == "exit" {
+			return nil
+		}
+		var result any
+		var params lspParams
+		if len(m.Params) > 0 {
+			if perr := json.Unmarshal(m.Params, &params); perr != nil {
+				err = &lspError{Code: lspInvalidParams, Message: fmt.Sprintf("bad parameters of %s: %v", m.Method, perr)}
+			}
+		}
+		if err == nil {
+			result, err = s.handle(m.Method, params)
+		}
+		if m.ID == nil {
+			// Notifications have no response.
+			if err != nil {
+				fmt.Fprintf(stderr, "lsp: %v\n", err)
+			}
+			continue
+		}
+		res := lspResponse{JSONRPC: "2.0", ID: m.ID, Result: result}
+		if err != nil {
+			var lerr *lspError
+			if !errors.As(err, &lerr) {
+				lerr = &lspError{Code: lspInternalError, Message: err.Error()}
+			}
+			res.Result, res.Error = nil, lerr
+		}
+		if err := s.send(res); err != nil {
+			return err
+		}
+	}
+}
+
+// handle handles the message with the given method and parameters,
+// returning its result.
+func (s *lspServer) handle(method string, p lspParams) (any, error) {
+	uri := p.TextDocument.URI
+	switch method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":   map[string]any{"openClose": true, "change": 1, "save": true},
+				"hoverProvider":      true,
+				"codeActionProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "embedmd", "version": version},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		s.docs[uri] = p.TextDocument.Text
+		return nil, s.publish(uri, true)
+	case "textDocument/didChange":
+		if len(p.ContentChanges) > 0 {
+			s.docs[uri] = p.ContentChanges[len(p.ContentChanges)-1].Text
+		}
+		// Documents are only processed when saved, as that fetches their
+		// sources.
+		return nil, s.publish(uri, false)
+	case "textDocument/didSave":
+		return nil, s.publish(uri, true)
+	case "textDocument/didClose":
+		delete(s.docs, uri)
+		return nil, s.send(lspNotification("textDocument/publishDiagnostics", map[string]any{"uri": uri, "diagnostics": []lspDiagnostic{}}))
+	case "textDocument/hover":
+		return s.hover(uri, p.Position.Line+1)
+	case "textDocument/codeAction":
+		return s.codeActions(uri, p.Range.Start.Line+1)
+	case "initialized":
+		return nil, nil
+	}
+	return nil, &lspError{Code: lspMethodNotFound, Message: fmt.Sprintf("method %s not found", method)}
+}
+
+// lspNotification returns a notification of the given method.
+func lspNotification(method string, params any) any {
+	return struct {
+		JSONRPC string `json:"jsonrpc"`
+		Method  string `json:"method"`
+		Params  any    `json:"params"`
+	}{"2.0", method, params}
+}
+
+// send writes the message v to the client.
+func (s *lspServer) send(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(b), b)
+	return err
+}
+
+// readLSPMessage reads the content of the next message from r, after its
+// headers.
+func readLSPMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, val, _ := strings.Cut(line, ":")
+		if strings.EqualFold(name, "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(val)); err != nil {
+				return nil, fmt.Errorf("bad Content-Length %q", val)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("message without Content-Length")
+	}
+	b := make([]byte, length)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
+
+// uriPath returns the path of the file of a document.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// errorLine matches the errors of the commands at a line.
+var errorLine = regexp.MustCompile(`^(\d+): `)
+
+// publish sends the diagnostics of the document at uri: the problems found
+// by Lint and, when processed, its warnings and error.
+func (s *lspServer) publish(uri string, process bool) error {
+	text, ok := s.docs[uri]
+	if !ok {
+		return nil
+	}
+	path := uriPath(uri)
+	opts := append([]embedmd.Option{embedmd.WithBaseDir(filepath.Dir(path)), embedmd.WithFormat(embedmd.FormatOf(path))}, s.opts...)
+	diags := []lspDiagnostic{}
+	found, err := embedmd.Lint(strings.NewReader(text), opts...)
+	if err != nil {
+		diags = append(diags, lineDiagnostic(text, 1, 1, severityError, err.Error()))
+	}
+	for _, d := range found {
+		diags = append(diags, lineDiagnostic(text, d.Line, d.Column, severityError, d.Message))
+	}
+	if process && err == nil && len(found) == 0 {
+		warn := embedmd.WithWarnings(func(line int, msg string) {
+			diags = append(diags, lineDiagnostic(text, line, 1, severityWarning, msg))
+		})
+		p, err := embedmd.NewProcessor(append(s.opts, warn)...)
+		if err == nil {
+			_, _, err = p.ProcessBytes(path, []byte(text))
+		}
+		if err != nil {
+			line, msg := 1, err.Error()
+			if m := errorLine.FindStringSubmatch(msg); m != nil {
+				line, _ = strconv.Atoi(m[1])
+				msg = msg[len(m[0]):]
+			}
+			diags = append(diags, lineDiagnostic(text, line, 1, severityError, msg))
+		}
+	}
+	return s.send(lspNotification("textDocument/publishDiagnostics", map[string]any{"uri": uri, "diagnostics": diags}))
+}
+
+// lineDiagnostic returns a diagnostic spanning the given line of text from
+// column col, both numbered from 1 and columns counting bytes, to its end.
+func lineDiagnostic(text string, line, col, severity int, msg string) lspDiagnostic {
+	lines := strings.Split(text, "\n")
+	line = min(max(line, 1), len(lines))
+	l := strings.TrimSuffix(lines[line-1], "\r")
+	col = min(max(col, 1), len(l)+1)
+	return lspDiagnostic{
+		Range: lspRange{
+			Start: lspPosition{line - 1, utf16Len(l[:col-1])},
+			End:   lspPosition{line - 1, utf16Len(l)},
+		},
+		Severity: severity,
+		Source:   "embedmd",
+		Message:  msg,
+	}
+}
+
+// utf16Len returns the length of s in UTF-16 code units, in which the
+// protocol counts characters.
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// hover returns the content embedded by the command at the given line of
+// the document at uri, if any, in the markdown of a hover.
+func (s *lspServer) hover(uri string, line int) (any, error) {
+	text, ok := s.docs[uri]
+	if !ok {
+		return nil, nil
+	}
+	var regions []embedmd.MappedRegion
+	p, err := embedmd.NewProcessor(append(s.opts, embedmd.WithSourceMap(func(r embedmd.MappedRegion) {
+		if r.Line == line {
+			regions = append(regions, r)
+		}
+	}))...)
+	if err != nil {
+		return nil, nil
+	}
+	out, _, err := p.ProcessBytes(uriPath(uri), []byte(text))
+	if err != nil || len(regions) == 0 {
+		// Errors are reported as diagnostics.
+		return nil, nil
+	}
+	outLines := strings.SplitAfter(string(out), "\n")
+	var b strings.Builder
+	for _, r := range regions {
+		switch {
+		case r.Start > 0 && r.Start != r.End:
+			fmt.Fprintf(&b, "%s, lines %d to %d:\n\n", r.Path, r.Start, r.End)
+		case r.Start > 0:
+			fmt.Fprintf(&b, "%s, line %d:\n\n", r.Path, r.Start)
+		default:
+			fmt.Fprintf(&b, "%s:\n\n", r.Path)
+		}
+		content := strings.Join(outLines[r.OutStart-1:r.OutEnd], "")
+		fence := "```"
+		for strings.Contains(content, fence) {
+			fence += "`"
+		}
+		fmt.Fprintf(&b, "%s\n%s%s\n", fence, content, fence)
+	}
+	return map[string]any{"contents": map[string]string{"kind": "markdown", "value": b.String()}}, nil
+}
+
+// codeActions returns the action embedding again the block under the given
+// line of the document at uri, if it's out of date.
+func (s *lspServer) codeActions(uri string, line int) (any, error) {
+	actions := []any{}
+	text, ok := s.docs[uri]
+	if !ok {
+		return actions, nil
+	}
+	path := uriPath(uri)
+	opts := append([]embedmd.Option{embedmd.WithBaseDir(filepath.Dir(path)), embedmd.WithFormat(embedmd.FormatOf(path))}, s.opts...)
+	sources, err := embedmd.Sources(strings.NewReader(text), opts...)
+	if err != nil {
+		return actions, nil
+	}
+	var cmdLines []int
+	for _, src := range sources {
+		cmdLines = append(cmdLines, src.Line)
+	}
+	first, last, ok := commandRun(cmdLines, line)
+	if !ok {
+		return actions, nil
+	}
+	p, err := embedmd.NewProcessor(s.opts...)
+	if err != nil {
+		return actions, nil
+	}
+	_, edits, err := p.ProcessBytes(path, []byte(text))
+	if err != nil {
+		return actions, nil
+	}
+	var changes []lspTextEdit
+	end := last
+	for _, e := range edits {
+		start := offsetPosition(text, e.Start)
+		// The block of the run is that of the last command before the edit.
+		if f, _, ok := commandRun(cmdLines, start.Line+1); !ok || f != first {
+			continue
+		}
+		changes = append(changes, lspTextEdit{Range: lspRange{start, offsetPosition(text, e.End)}, NewText: e.New})
+		end = max(end, offsetPosition(text, e.End).Line)
+	}
+	if len(changes) == 0 || line > end {
+		return actions, nil
+	}
+	actions = append(actions, map[string]any{
+		"title": fmt.Sprintf("Embed again the block of line %d", first),
+		"kind":  "quickfix",
+		"edit":  map[string]any{"changes": map[string][]lspTextEdit{uri: changes}},
+	})
+	return actions, nil
+}
+
+// commandRun returns the first and last lines of the run of commands on
+// consecutive lines, among those at cmdLines, sharing the block the given
+// line is in or above.
+func commandRun(cmdLines []int, line int) (first, last int, ok bool) {
+	i, found := slices.BinarySearch(cmdLines, line)
+	if !found {
+		i--
+	}
+	if i < 0 {
+		return 0, 0, false
+	}
+	first, last = i, i
+	for first > 0 && cmdLines[first-1] == cmdLines[first]-1 {
+		first--
+	}
+	for last+1 < len(cmdLines) && cmdLines[last+1] == cmdLines[last]+1 {
+		last++
+	}
+	return cmdLines[first], cmdLines[last], true
+}
+
+// offsetPosition returns the position of the byte at offset off of text.
+func offsetPosition(text string, off int) lspPosition {
+	before := text[:off]
+	line := strings.Count(before, "\n")
+	return lspPosition{line, utf16Len(before[strings.LastIndexByte(before, '\n')+1:])}
+}
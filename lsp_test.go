@@ -0,0 +1,194 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// lspRequests returns the framed messages of the given requests, numbered
+// from 1, with notifications, those with a method ending with !, unnumbered.
+func lspRequests(t *testing.T, msgs ...any) string {
+	var b strings.Builder
+	id := 0
+	for i := 0; i < len(msgs); i += 2 {
+		method, params := msgs[i].(string), msgs[i+1]
+		m := map[string]any{"jsonrpc": "2.0", "method": strings.TrimSuffix(method, "!"), "params": params}
+		if !strings.HasSuffix(method, "!") {
+			id++
+			m["id"] = id
+		}
+		j, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n%s", len(j), j)
+	}
+	return b.String()
+}
+
+// lspReplies returns the messages written by the server, by their ID or,
+// for notifications, method, the last one winning.
+func lspReplies(t *testing.T, out *bytes.Buffer) map[string]json.RawMessage {
+	replies := map[string]json.RawMessage{}
+	r := bufio.NewReader(out)
+	for {
+		b, err := readLSPMessage(r)
+		if err != nil {
+			return replies
+		}
+		var m struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			Result json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatalf("bad message %s: %v", b, err)
+		}
+		if m.Method != "" {
+			replies[m.Method] = m.Params
+		} else {
+			replies[string(m.ID)] = m.Result
+		}
+	}
+}
+
+func TestLSP(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "code.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	uri := (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(dir, "doc.md"))}).String()
+	doc := func(text string) map[string]any {
+		return map[string]any{"uri": uri, "text": text}
+	}
+	text := "# Title\n\n[embedmd]:# (code.go /func/ $)\n```go\nold\n```\n\nText\n"
+	in := lspRequests(t,
+		"initialize", map[string]any{},
+		"initialized!", map[string]any{},
+		"textDocument/didOpen!", map[string]any{"textDocument": doc(text)},
+		"textDocument/hover", map[string]any{"textDocument": doc(""), "position": map[string]int{"line": 2, "character": 3}},
+		"textDocument/hover", map[string]any{"textDocument": doc(""), "position": map[string]int{"line": 0, "character": 0}},
+		"textDocument/codeAction", map[string]any{"textDocument": doc(""), "range": map[string]any{"start": map[string]int{"line": 4}, "end": map[string]int{"line": 4}}},
+		"textDocument/codeAction", map[string]any{"textDocument": doc(""), "range": map[string]any{"start": map[string]int{"line": 7}, "end": map[string]int{"line": 7}}},
+		"textDocument/didChange!", map[string]any{"textDocument": doc(""), "contentChanges": []map[string]string{{"text": "[embedmd]:# (code.go captoin=x)\n"}}},
+		"shutdown", nil,
+		"exit!", nil,
+	)
+	var out bytes.Buffer
+	s := &lspServer{out: &out, docs: map[string]string{}}
+	if err := s.serve(strings.NewReader(in)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !s.shutdown {
+		t.Errorf("expected the server to be shut down")
+	}
+	replies := lspReplies(t, &out)
+
+	if got := string(replies["1"]); !strings.Contains(got, `"hoverProvider":true`) || !strings.Contains(got, `"codeActionProvider":true`) {
+		t.Errorf("expected hovers and code actions in the capabilities; got %s", got)
+	}
+	want := `{"contents":{"kind":"markdown","value":"code.go, line 3:\n\n` + "```" + `\nfunc main() {}\n` + "```" + `\n"}}`
+	if got := string(replies["2"]); got != want {
+		t.Errorf("expected hover\n%s\ngot\n%s", want, got)
+	}
+	if got := string(replies["3"]); got != "null" {
+		t.Errorf("expected no hover outside commands; got %s", got)
+	}
+	want = `[{"edit":{"changes":{"` + uri + `":[{"range":{"start":{"line":4,"character":0},"end":{"line":5,"character":0}},"newText":"func main() {}\n"}]}},"kind":"quickfix","title":"Embed again the block of line 3"}]`
+	if got := string(replies["4"]); got != want {
+		t.Errorf("expected code actions\n%s\ngot\n%s", want, got)
+	}
+	if got := string(replies["5"]); got != "[]" {
+		t.Errorf("expected no code actions below the block; got %s", got)
+	}
+	want = `{"diagnostics":[{"range":{"start":{"line":0,"character":21},"end":{"line":0,"character":31}},"severity":1,"source":"embedmd","message":"unknown attribute \"captoin\""}],"uri":"` + uri + `"}`
+	if got := string(replies["textDocument/publishDiagnostics"]); got != want {
+		t.Errorf("expected diagnostics\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestLSPErrors(t *testing.T) {
+	in := "Content-Length: 5\r\n\r\n{bad}" + lspRequests(t,
+		"textDocument/hover", map[string]any{"position": "nowhere"},
+		"textDocument/didOpen!", map[string]any{"textDocument": "nothing"},
+		"textDocument/rename", map[string]any{},
+		"initialize", map[string]any{},
+		"exit!", nil,
+	)
+	var out, errs bytes.Buffer
+	defer func(w io.Writer) { stderr = w }(stderr)
+	stderr = &errs
+	s := &lspServer{out: &out, docs: map[string]string{}}
+	if err := s.serve(strings.NewReader(in)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var codes []string
+	r := bufio.NewReader(&out)
+	for {
+		b, err := readLSPMessage(r)
+		if err != nil {
+			break
+		}
+		var m lspResponse
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatalf("bad message %s: %v", b, err)
+		}
+		code := "ok"
+		if m.Error != nil {
+			code = fmt.Sprint(m.Error.Code)
+		}
+		codes = append(codes, string(m.ID)+" "+code)
+	}
+	// The server answers the requests after those it can't handle.
+	if want := []string{"null -32700", "1 -32602", "2 -32601", "3 ok"}; !slices.Equal(codes, want) {
+		t.Errorf("expected the responses %q; got %q", want, codes)
+	}
+	if want := "lsp: bad parameters of textDocument/didOpen"; !strings.Contains(errs.String(), want) {
+		t.Errorf("expected %q to be logged; got %q", want, errs.String())
+	}
+}
+
+func TestLSPProcessDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "code.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	uri := (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(dir, "doc.md"))}).String()
+	in := lspRequests(t,
+		"textDocument/didOpen!", map[string]any{"textDocument": map[string]any{"uri": uri, "text": "# Title\n[embedmd]:# (code.go /func nope/)\n"}},
+		"exit!", nil,
+	)
+	var out bytes.Buffer
+	s := &lspServer{out: &out, docs: map[string]string{}}
+	if err := s.serve(strings.NewReader(in)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"diagnostics":[{"range":{"start":{"line":1,"character":0},"end":{"line":1,"character":33}},"severity":1,"source":"embedmd","message":"could not extract content from code.go: could not match \"/func nope/\""}],"uri":"` + uri + `"}`
+	if got := string(lspReplies(t, &out)["textDocument/publishDiagnostics"]); got != want {
+		t.Errorf("expected diagnostics\n%s\ngot\n%s", want, got)
+	}
+}
@@ -19,29 +19,32 @@
 // markdown is rendered, so they can be kept in the file as pointers
 // to the origin of the embedded text.
 //
-// The command receives a list of markdown files, if none is given it
-// reads from the standard input.
+// The command receives a list of markdown, reStructuredText, AsciiDoc, or
+// notebook files, or globs matching them; if none is given it reads from the
+// standard input. It writes the result to the standard output, unless -w
+// rewrites the files in place or -d prints the difference with what would
+// have been written. Many more flags control fetching, checking, reporting,
+// and the output; embedmd -help lists them all.
 //
-// embedmd supports two flags:
-// -d: will print the difference of the input file with what the output
+// Subcommands, such as embedmd fmt, embedmd doctor, and embedmd worker, do
+// other work on the same files, and embedmd grpc serves the processor to
+// long-lived clients; embedmd <command> -help describes each of them.
 //
-//	would have been if executed.
-//
-// -w: rewrites the given files rather than writing the output to the standard
-//
-//	output.
-//
-// For more information on the format of the commands, read the documentation
-// of the github.com/seanblong/embedmd/embedmd package.
+// For more information on the flags, subcommands, and config file, read the
+// README, and on the format of the commands, the documentation of the
+// github.com/seanblong/embedmd/embedmd package.
 package main
 
 import (
 	"bytes"
+	"cmp"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/pmezard/go-difflib/difflib"
 	"github.com/seanblong/embedmd/embedmd"
@@ -55,34 +58,419 @@ func usage() {
 	flag.PrintDefaults()
 }
 
+// subcommands are run when their name is the first argument.
+var subcommands = map[string]func(args []string) int{
+	"compare":     runCompare,
+	"config":      runConfig,
+	"confluence":  runConfluence,
+	"doctor":      runDoctor,
+	"explain":     runExplain,
+	"fmt":         runFmt,
+	"grpc":        runGRPC,
+	"lsp":         runLSP,
+	"freeze":      runFreeze,
+	"hook":        runHook,
+	"index":       runIndex,
+	"merge":       runMerge,
+	"notion":      runNotion,
+	"ping":        runPing,
+	"render":      runRender,
+	"scaffold":    runScaffold,
+	"simulate":    runSimulate,
+	"snippet":     runSnippet,
+	"stats":       runStats,
+	"store":       runStore,
+	"verify-site": runVerifySite,
+	"worker":      runWorker,
+}
+
 func main() {
-	rewrite := flag.Bool("w", false, "write result to (markdown) file instead of stdout")
-	doDiff := flag.Bool("d", false, "display diffs instead of rewriting files")
-	printVersion := flag.Bool("v", false, "display embedmd version")
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
+	o := newFlags(flag.CommandLine)
 	flag.Usage = usage
 	flag.Parse()
+	handleInterrupts()
 
-	if *printVersion {
+	if err := setup(flag.CommandLine, o); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if o.check {
+		o.doDiff = true
+	}
+	// Without arguments, the inputs set with -input are processed, unless
+	// -staged selects the documents staged in git or -stdin reads the
+	// standard input.
+	args := flag.Args()
+	if len(args) == 0 && !o.staged && !o.stdin {
+		args = o.inputs
+	}
+	if err := checkModes(o, args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if o.printVersion {
 		fmt.Println("embedmd version: " + version)
 		return
 	}
 
-	diff, err := embed(flag.Args(), *rewrite, *doDiff)
+	var paths []string
+	var err error
+	if o.staged {
+		paths, err = stagedPaths(o.include, o.exclude)
+	} else {
+		paths, err = expandPaths(args, o.include, o.exclude)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if o.staged && len(paths) == 0 {
+		return
+	}
+	if o.staged && o.rewrite {
+		if err := checkUnstaged(paths); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if o.shard != "" {
+		if paths, err = shardPaths(paths, o.shard); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if len(paths) == 0 {
+			// The shard has nothing to check, but its report is still
+			// expected by the merge step.
+			if o.reportJSON != "" {
+				if err := (&staleReport{}).write(o.reportJSON); err != nil {
+					fmt.Fprintf(os.Stderr, "could not write report: %v\n", err)
+					os.Exit(2)
+				}
+			}
+			return
+		}
+	}
+	if len(paths) == 0 && len(args) > 0 {
+		// Without this, the standard input would be read instead.
+		fmt.Fprintln(os.Stderr, "warning: no markdown files found")
+		return
+	}
+	opts, err := o.embedOptions()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if o.dedupe != "" {
+		if opts, err = o.includeOptions(paths, opts...); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if o.stamp != nil {
+		// The documents are inputs too, as they were before being rewritten.
+		for _, path := range paths {
+			if b, err := readFile(path); err == nil {
+				o.stamp.add(path, b)
+			}
+		}
+	}
+	var before map[string][]byte
+	if o.suggestCommit || o.staged && o.rewrite {
+		before = map[string][]byte{}
+		for _, path := range paths {
+			if b, err := readFile(path); err == nil {
+				before[path] = b
+			}
+		}
+	}
+	if o.reportJSON != "" {
+		blockReport = &staleReport{}
+	}
+	if o.summary {
+		changeSummary = &changeCounter{}
+	}
+	if o.quarantine != "" {
+		if quarantineList, err = loadQuarantine(o.quarantine, o.quarantineDays, cmp.Or(o.clock, time.Now())); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if o.suggestPatches != "" {
+		sourcePatches = &patchSet{}
+	}
+	ws := o.workspace()
+	opts = append(opts, embedmd.WithWorkspace(ws))
+	var diff bool
+	switch {
+	case o.dumpState != "":
+		err = dumpState(o.dumpState, flag.CommandLine, o.sources, paths, opts...)
+	case o.applyPath != "":
+		err = applyPlan(o.applyPath)
+	case o.planPath != "":
+		err = writePlan(o.planPath, paths, o.workers, opts...)
+	case o.fileIssues != "":
+		var t *issueTracker
+		var rules []ownerRule
+		if t, err = o.issueTracker(); err == nil {
+			if rules, err = o.issueOwners(); err == nil {
+				diff, err = fileIssues(paths, t, rules, opts...)
+			}
+		}
+	case o.byOwner:
+		var rules []ownerRule
+		if rules, err = loadOwners(o.codeowners, o.owners); err == nil {
+			diff, err = checkByOwner(paths, rules, o.ownerDir, opts...)
+		}
+	case o.reportHTML != "":
+		diff, err = writeReport(o.reportHTML, paths, opts...)
+	case o.verify:
+		diff, err = verifyPins(paths, opts...)
+	case o.lint:
+		diff, err = lintFiles(paths, o.lintFormat, opts...)
+	case o.versionsOut != "":
+		var versions []embedmd.Version
+		var f embedmd.Fetcher
+		if versions, err = o.versions(); err == nil {
+			if f, err = o.fetcher(); err == nil {
+				err = renderVersions(o.versionsOut, paths, versions, f, opts...)
+			}
+		}
+	case o.watch:
+		if diff, err = embed(paths, true, false, opts...); err == nil {
+			err = watch(paths, o.watchDebounce, opts...)
+		}
+	default:
+		diff, err = embed(paths, o.rewrite, o.doDiff, opts...)
+	}
+	closeWorkspace(ws)
+	summary.limits = rateBudget.Limits()
+	warnQuotas(os.Stderr, summary.limits)
+	if o.notify != "" {
+		// Failing to notify doesn't change the outcome of the check.
+		if nerr := notify(o.notify, summary.message(err, o.notifyLink)); nerr != nil {
+			fmt.Fprintf(os.Stderr, "could not notify: %v\n", nerr)
+		}
+	}
+	if ierr := (*interruptedError)(nil); errors.As(err, &ierr) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(130)
+	}
 	if err != nil {
+		if useColor(os.Stderr) {
+			err = fmt.Errorf("%s%v%s", ansiRed, err, ansiReset)
+		}
 		fmt.Fprintln(os.Stderr, err)
+		if keepGoing {
+			os.Exit(exitCode(err))
+		}
 		os.Exit(2)
 	}
-	if diff && *doDiff {
+	if o.stamp != nil {
+		if err := o.stamp.write(o.stampOut); err != nil {
+			fmt.Fprintf(os.Stderr, "could not write stamp: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if o.lock != nil {
+		if err := o.lock.write(); err != nil {
+			fmt.Fprintf(os.Stderr, "could not write lock file: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if quarantineList != nil {
+		if err := quarantineList.write(); err != nil {
+			fmt.Fprintf(os.Stderr, "could not write quarantine: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if o.staged && o.rewrite {
+		if err := restage(before, paths); err != nil {
+			fmt.Fprintf(os.Stderr, "could not stage the rewritten files: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if o.suggestCommit {
+		if err := suggestCommit(stdout, before, paths, opts...); err != nil {
+			fmt.Fprintf(os.Stderr, "could not suggest a commit message: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if blockReport != nil {
+		if err := blockReport.write(o.reportJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "could not write report: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if sourcePatches != nil {
+		if err := sourcePatches.write(o.suggestPatches); err != nil {
+			fmt.Fprintf(os.Stderr, "could not write patches: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if changeSummary != nil {
+		changeSummary.total()
+	}
+	if diff && o.check {
+		reportStale(summary.stale)
+	}
+	if diff && (o.doDiff || o.reportHTML != "" || o.verify || o.lint) {
+		if keepGoing {
+			os.Exit(exitChanged)
+		}
 		os.Exit(2)
 	}
 }
 
+// setup completes the flags parsed in fs with the environment and the
+// config file, and validates the resulting options.
+func setup(fs *flag.FlagSet, o *options) error {
+	source, err := resolveFlags(fs)
+	if err != nil {
+		return err
+	}
+	o.sources = source
+	if err := o.setClock(); err != nil {
+		return err
+	}
+	return validColorMode(colorMode)
+}
+
+// checkModes rejects flags selecting more than one way to run.
+func checkModes(o *options, args []string) error {
+	switch {
+	case o.check && o.rewrite:
+		return fmt.Errorf("error: cannot use -check with -w")
+	case o.planPath != "" && (o.rewrite || o.doDiff):
+		return fmt.Errorf("error: cannot use -plan with -w or -d")
+	case o.versionsOut != "" && (o.rewrite || o.doDiff || o.planPath != "" || o.applyPath != "" || len(args) == 0):
+		return fmt.Errorf("error: -versions-out can only be used on files, without -w, -d, -plan, or -apply")
+	case o.dumpState != "" && (o.rewrite || o.doDiff || o.stdin || o.watch || o.planPath != "" || o.applyPath != ""):
+		return fmt.Errorf("error: -dump-state can only be used without -w, -d, -stdin, -watch, -plan, or -apply")
+	case o.extractCache && o.sourceArchive != "":
+		return fmt.Errorf("error: -extract-cache can only be used without -source-archive, as it tells unchanged files from the disk")
+	case o.langConfidence < 0 || o.langConfidence > 1:
+		return fmt.Errorf("error: bad -lang-confidence %v, should be between 0 and 1", o.langConfidence)
+	case o.quarantineDays != 0 && (o.quarantine == "" || o.quarantineDays < 0):
+		return fmt.Errorf("error: -quarantine-days can only be a positive number of days, used with -quarantine")
+	case o.verify && (o.rewrite || o.doDiff || o.pin || o.planPath != ""):
+		return fmt.Errorf("error: cannot use -verify with -w, -d, -pin, or -plan")
+	case o.lint && (o.rewrite || o.doDiff || o.verify || o.planPath != "" || o.applyPath != "" || o.strip):
+		return fmt.Errorf("error: cannot use -lint with -w, -d, -verify, -plan, -apply, or -strip")
+	case o.lintFormat != "" && o.lintFormat != "text" && o.lintFormat != "json":
+		return fmt.Errorf("error: bad -lint-format %q, should be text or json", o.lintFormat)
+	case o.applyPath != "" && (o.rewrite || o.doDiff || o.planPath != ""):
+		return fmt.Errorf("error: cannot use -apply with -w, -d, or -plan")
+	case o.reportHTML != "" && (o.rewrite || o.doDiff || o.planPath != "" || o.applyPath != ""):
+		return fmt.Errorf("error: cannot use -report-html with -w, -d, -plan, or -apply")
+	case o.reportHTML != "" && len(args) == 0:
+		return fmt.Errorf("error: cannot use -report-html with standard input")
+	case o.suggestCommit && !o.rewrite:
+		return fmt.Errorf("error: -suggest-commit can only be used with -w")
+	case o.reportJSON != "" && (!o.doDiff || o.byOwner || len(args) == 0):
+		return fmt.Errorf("error: -report-json can only be used with -d on files, without -by-owner")
+	case o.summary && (!o.doDiff || o.reportJSON != "" || o.reportHTML != "" || o.byOwner || o.fileIssues != "" || wordDiffs):
+		return fmt.Errorf("error: -summary can only be used with -d, without -report-json, -by-owner, -file-issues, or -word-diff")
+	case o.byOwner && !o.doDiff:
+		return fmt.Errorf("error: -by-owner can only be used with -d")
+	case o.ownerDir != "" && !o.byOwner:
+		return fmt.Errorf("error: -owner-dir can only be used with -by-owner")
+	case o.fileIssues != "" && (!o.doDiff || o.byOwner || len(args) == 0):
+		return fmt.Errorf("error: -file-issues can only be used with -d on files, without -by-owner")
+	case o.strip && (o.check || o.planPath != "" || o.applyPath != "" || o.reportHTML != "" || o.reportJSON != "" ||
+		o.byOwner || o.fileIssues != "" || o.dedupe != "" || o.refresh):
+		return fmt.Errorf("error: -strip can only be used with -w or -d")
+	case o.dedupe != "" && (!o.rewrite || len(args) == 0 || o.shard != ""):
+		return fmt.Errorf("error: -dedupe can only be used with -w on files, without -shard")
+	case (o.dedupeDir != "" || o.dedupePath != "") && o.dedupe == "":
+		return fmt.Errorf("error: -dedupe-dir and -dedupe-path can only be used with -dedupe")
+	case o.shard != "" && (len(args) == 0 || o.applyPath != ""):
+		return fmt.Errorf("error: -shard can only be used on files, without -apply")
+	case o.suggestPatches != "" && (!o.checksums || len(args) == 0 || o.planPath != "" || o.applyPath != ""):
+		return fmt.Errorf("error: -suggest-patches can only be used with -checksums on files, without -plan or -apply")
+	case interactive && (!o.rewrite || !o.checksums && !o.managed || len(args) == 0 || transactional || o.sourceArchive == "-"):
+		return fmt.Errorf("error: -i can only be used with -w and -checksums or -managed on files, without -transactional or -source-archive -")
+	case sourceMaps && (!o.rewrite || len(args) == 0 || transactional || o.strip):
+		return fmt.Errorf("error: -source-map can only be used with -w on files, without -transactional or -strip")
+	case keepGoing && transactional:
+		return fmt.Errorf("error: -keep-going can only be used without -transactional, which rewrites nothing when a file fails")
+	case o.editRef != "" && !o.editLinks:
+		return fmt.Errorf("error: -edit-ref can only be used with -edit-links")
+	case o.sourceArchive == "-" && len(args) == 0:
+		return fmt.Errorf("error: -source-archive - can only be used on files, as the markdown is read from stdin otherwise")
+	case o.copyWithoutPrompts && o.copyButtons == "":
+		return fmt.Errorf("error: -copy-without-prompts can only be used with -copy-buttons")
+	case o.notify != "" && !o.doDiff:
+		return fmt.Errorf("error: -notify can only be used with -d")
+	case o.frozen && o.lockPath == "":
+		return fmt.Errorf("error: -frozen can only be used with -lockfile")
+	case len(o.workers) > 0 && o.planPath == "":
+		return fmt.Errorf("error: -workers can only be used with -plan")
+	case o.staged && (len(args) > 0 || !o.rewrite && !o.doDiff || o.planPath != "" || o.applyPath != "" || o.shard != ""):
+		return fmt.Errorf("error: -staged can only be used with -w, -d, or -check, without files, -plan, -apply, or -shard")
+	case o.watch && (!o.rewrite || len(args) == 0 || o.staged || o.planPath != "" || o.strip || o.suggestCommit):
+		return fmt.Errorf("error: -watch can only be used with -w on files, without -staged, -plan, -strip, or -suggest-commit")
+	case o.stdin && (len(args) > 0 || o.rewrite || o.staged || o.watch || o.planPath != "" || o.applyPath != ""):
+		return fmt.Errorf("error: -stdin can only be used without files, -w, -staged, -watch, -plan, or -apply")
+	case stdinPath != "" && len(args) > 0:
+		return fmt.Errorf("error: -stdin-path can only be used with standard input")
+	case o.annotate && (o.doDiff || o.strip || o.planPath != "" || o.staged):
+		return fmt.Errorf("error: -annotate cannot be used with -d, -check, -strip, -plan, or -staged")
+	case o.applyPath != "" && len(args) > 0:
+		return fmt.Errorf("error: -apply takes no files, they are listed in the plan")
+	}
+	return nil
+}
+
+// reportStale lists the files found out of date by -check, and how to fix
+// them.
+func reportStale(paths []string) {
+	fmt.Fprintf(stderr, "%d files out of date, run embedmd -w to update them:\n", len(paths))
+	for _, p := range paths {
+		fmt.Fprintf(stderr, "  %s\n", p)
+	}
+}
+
+// closeWorkspace removes the temporary workspace, or reports where it was
+// kept.
+func closeWorkspace(ws *embedmd.Workspace) {
+	kept, err := ws.Close()
+	if err != nil {
+		fmt.Fprintf(stderr, "could not remove temporary workspace: %v\n", err)
+	}
+	if kept != "" {
+		fmt.Fprintf(stderr, "temporary workspace kept in %s\n", kept)
+	}
+}
+
 var (
 	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
 	stdin  io.Reader = os.Stdin
 )
 
-func embed(paths []string, rewrite, doDiff bool) (foundDiff bool, err error) {
+// warnings returns an option printing the warnings found in the given file.
+func warnings(path string) embedmd.Option {
+	return embedmd.WithWarnings(func(line int, msg string) { printWarning(path, line, msg) })
+}
+
+// printWarning prints a warning found at the given line of a file.
+func printWarning(path string, line int, msg string) {
+	label := "warning:"
+	if useColor(stderr) {
+		label = ansiYellow + label + ansiReset
+	}
+	fmt.Fprintf(stderr, "%s:%d: %s %s\n", filepath.ToSlash(path), line, label, msg)
+}
+
+func embed(paths []string, rewrite, doDiff bool, opts ...embedmd.Option) (foundDiff bool, err error) {
 	if rewrite && doDiff {
 		return false, fmt.Errorf("error: cannot use -w and -d simultaneously")
 	}
@@ -91,32 +479,169 @@ func embed(paths []string, rewrite, doDiff bool) (foundDiff bool, err error) {
 		if rewrite {
 			return false, fmt.Errorf("error: cannot use -w with standard input")
 		}
+		name := cmp.Or(stdinPath, "<stdin>")
+		opts = append([]embedmd.Option{warnings(name)}, opts...)
+		if stdinPath != "" {
+			// The document is named after a file that is never read, and
+			// options given later, such as -base-dir, take precedence.
+			opts = append([]embedmd.Option{embedmd.WithBaseDir(filepath.Dir(stdinPath)), embedmd.WithFormat(embedmd.FormatOf(stdinPath))}, opts...)
+		}
 		if !doDiff {
-			return false, embedmd.Process(stdout, stdin)
+			// Nothing is written when a command fails, so pipelines don't
+			// go on with a truncated document.
+			var out bytes.Buffer
+			if err := embedmd.Process(&out, stdin, opts...); err != nil {
+				return false, err
+			}
+			_, err := stdout.Write(out.Bytes())
+			return false, err
 		}
 
+		var changes []embedmd.StaleBlock
+		if changeSummary != nil {
+			opts = append(opts, collectChanges(&changes))
+		}
 		var out, in bytes.Buffer
-		if err := embedmd.Process(&out, io.TeeReader(stdin, &in)); err != nil {
+		if err := embedmd.Process(&out, io.TeeReader(stdin, &in), opts...); err != nil {
 			return false, err
 		}
 		d, err := diff(in.String(), out.String())
 		if err != nil || len(d) == 0 {
 			return false, err
 		}
+		summary.record(name, true)
+		if changeSummary != nil {
+			changeSummary.print(name, changes)
+			return true, nil
+		}
 		fmt.Fprintf(stdout, "%s", d)
 		return true, nil
 	}
 
-	for _, path := range paths {
-		d, err := processFile(path, rewrite, doDiff)
+	if resume && !rewrite {
+		return false, fmt.Errorf("error: -resume can only be used with -w")
+	}
+	if rewrite && requireClean && !force {
+		if err := checkClean(paths); err != nil {
+			return false, err
+		}
+	}
+	if rewrite && len(paths) > 1 {
+		return false, rewriteAll(paths, opts...)
+	}
+
+	var errs fileErrors
+	for i, path := range paths {
+		if interrupted.Load() {
+			return foundDiff, &interruptedError{done: paths[:i], pending: paths[i:]}
+		}
+		d, err := processFile(path, rewrite, doDiff, opts...)
+		if err != nil && interrupted.Load() {
+			// The file was left untouched.
+			return foundDiff, &interruptedError{done: paths[:i], pending: paths[i:]}
+		}
+		if err != nil && keepGoing {
+			errs = append(errs, fileError{path, err})
+			continue
+		}
 		if err != nil {
-			return false, fmt.Errorf("%s:%v", path, err)
+			return false, fmt.Errorf("%s:%v", filepath.ToSlash(path), err)
 		}
+		summary.record(path, d)
 		foundDiff = foundDiff || d
 	}
+	if len(errs) > 0 {
+		return foundDiff, errs
+	}
 	return foundDiff, nil
 }
 
+// rewriteAll rewrites the given files, keeping track of the progress in the
+// journal with -resume so the run can be resumed if it doesn't complete.
+func rewriteAll(paths []string, opts ...embedmd.Option) (err error) {
+	j, err := openJournal()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Only complete runs remove the journal: interrupted or failed ones
+		// can be resumed.
+		if ferr := j.finish(err != nil); err == nil && ferr != nil {
+			err = fmt.Errorf("could not close journal: %v", ferr)
+		}
+	}()
+
+	var planned map[string]*plannedFile
+	if transactional {
+		if planned, err = planPending(j, paths, opts...); err != nil {
+			return err
+		}
+	}
+
+	var errs fileErrors
+	for i, path := range paths {
+		if interrupted.Load() {
+			return &interruptedError{done: paths[:i], pending: paths[i:]}
+		}
+		if j.completed(path) {
+			continue
+		}
+		if transactional {
+			if f := planned[path]; f != nil {
+				err = rewriteFile(path, []byte(f.Content))
+			}
+		} else {
+			_, err = processFile(path, true, false, opts...)
+		}
+		if err != nil && interrupted.Load() {
+			return &interruptedError{done: paths[:i], pending: paths[i:]}
+		}
+		if err != nil && keepGoing {
+			// The file isn't completed, so -resume processes it again.
+			errs = append(errs, fileError{path, err})
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%v", filepath.ToSlash(path), err)
+		}
+		b, err := readFile(path)
+		if err != nil {
+			return fmt.Errorf("%s:%v", filepath.ToSlash(path), err)
+		}
+		if err := j.record(path, b); err != nil {
+			return err
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// transactional is set to rewrite files only once all of them have been
+// processed without errors.
+var transactional bool
+
+// planPending returns the changes planned for the files not completed yet
+// according to the journal, by path.
+func planPending(j *journal, paths []string, opts ...embedmd.Option) (map[string]*plannedFile, error) {
+	var pending []string
+	for _, path := range paths {
+		if !j.completed(path) {
+			pending = append(pending, path)
+		}
+	}
+	files, err := planFiles(pending, opts...)
+	if err != nil {
+		return nil, err
+	}
+	planned := map[string]*plannedFile{}
+	for i, path := range pending {
+		planned[path] = files[i]
+	}
+	return planned, nil
+}
+
 type file interface {
 	io.ReadCloser
 	io.WriterAt
@@ -137,9 +662,9 @@ func readFile(path string) ([]byte, error) {
 	return io.ReadAll(f)
 }
 
-func processFile(path string, rewrite, doDiff bool) (foundDiff bool, err error) {
-	if filepath.Ext(path) != ".md" {
-		return false, fmt.Errorf("not a markdown file")
+func processFile(path string, rewrite, doDiff bool, opts ...embedmd.Option) (foundDiff bool, err error) {
+	if !isDocument(path) {
+		return false, fmt.Errorf("not a markdown, reStructuredText, AsciiDoc, or notebook file")
 	}
 
 	f, err := openFile(path)
@@ -149,9 +674,43 @@ func processFile(path string, rewrite, doDiff bool) (foundDiff bool, err error)
 	defer f.Close()
 
 	buf := new(bytes.Buffer)
-	if err := embedmd.Process(buf, f, embedmd.WithBaseDir(filepath.Dir(path))); err != nil {
+	opts = append([]embedmd.Option{embedmd.WithBaseDir(filepath.Dir(path)), embedmd.WithFormat(embedmd.FormatOf(path))}, opts...)
+	// The second pass checking idempotency reports nothing.
+	again := opts
+	opts = append([]embedmd.Option{warnings(path)}, opts...)
+	if blockReport != nil {
+		opts = append(opts, blockReport.collect(path))
+	}
+	if sourcePatches != nil {
+		opts = append(opts, sourcePatches.collect(path))
+	}
+	if quarantineList != nil {
+		opts = append(opts, quarantineList.option(path))
+	}
+	var changes []embedmd.StaleBlock
+	if doDiff && changeSummary != nil {
+		opts = append(opts, collectChanges(&changes))
+	}
+	var m *sourceMap
+	if rewrite && interactive {
+		opts = append(opts, askConflicts(path))
+	}
+	if rewrite && sourceMaps {
+		m = &sourceMap{}
+		opts = append(opts, m.collect())
+	}
+	in, err := io.ReadAll(f)
+	if err != nil {
+		return false, err
+	}
+	if err := embedmd.Process(buf, bytes.NewReader(in), opts...); err != nil {
 		return false, err
 	}
+	if verifyIdempotent {
+		if err := checkIdempotent(buf.Bytes(), again...); err != nil {
+			return false, err
+		}
+	}
 
 	if doDiff {
 		f, err := readFile(path)
@@ -162,16 +721,28 @@ func processFile(path string, rewrite, doDiff bool) (foundDiff bool, err error)
 		if err != nil || len(data) == 0 {
 			return false, err
 		}
+		if changeSummary != nil {
+			changeSummary.print(path, changes)
+			return true, nil
+		}
 		fmt.Fprintf(stdout, "%s", data)
 		return true, nil
 	}
 
 	if rewrite {
-		n, err := f.WriteAt(buf.Bytes(), 0)
-		if err != nil {
-			return false, fmt.Errorf("could not write: %v", err)
+		// Unchanged files are left untouched, keeping their modification
+		// time.
+		if !bytes.Equal(in, buf.Bytes()) {
+			if err := overwrite(path, f, buf.Bytes()); err != nil {
+				return false, err
+			}
 		}
-		return false, f.Truncate(int64(n))
+		if m != nil {
+			if err := m.write(path); err != nil {
+				return false, fmt.Errorf("could not write source map: %v", err)
+			}
+		}
+		return false, nil
 	}
 
 	_, err = io.Copy(stdout, buf)
@@ -182,10 +753,47 @@ func processFile(path string, rewrite, doDiff bool) (foundDiff bool, err error)
 	return false, nil
 }
 
+// overwrite replaces the content of the file at path, open as f, with b,
+// without being interrupted. Files on disk are replaced atomically, keeping
+// their permissions.
+func overwrite(path string, f file, b []byte) error {
+	writing.Lock()
+	defer writing.Unlock()
+	if _, ok := f.(*os.File); ok {
+		if _, err := embedmd.WriteFile(path, b); err != nil {
+			return fmt.Errorf("could not write: %v", err)
+		}
+		return nil
+	}
+	n, err := f.WriteAt(b, 0)
+	if err != nil {
+		return fmt.Errorf("could not write: %v", err)
+	}
+	return f.Truncate(int64(n))
+}
+
+// stdinPath, if set, is the path the document read from the standard input
+// is named after, in messages and to tell its format and base directory.
+var stdinPath string
+
+// wordDiffs is set to show word level diffs.
+var wordDiffs bool
+
 func diff(a, b string) (string, error) {
-	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+	d, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
 		A:       difflib.SplitLines(a),
 		B:       difflib.SplitLines(b),
 		Context: 3,
 	})
+	if err != nil {
+		return d, err
+	}
+	color := useColor(stdout)
+	switch {
+	case wordDiffs:
+		return wordDiff(d, color), nil
+	case color:
+		return colorDiff(d), nil
+	}
+	return d, nil
 }
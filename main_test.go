@@ -14,11 +14,22 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
+	"flag"
+	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/seanblong/embedmd/embedmd"
 )
 
 func TestEmbedStreams(t *testing.T) {
@@ -81,6 +92,43 @@ func TestEmbedStreams(t *testing.T) {
 	}
 }
 
+func TestEmbedStdinPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "docs", "code.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tc := []struct {
+		name, path, in, out string
+		err                 string
+	}{
+		{name: "relative to the path", path: filepath.Join(dir, "docs", "README.md"),
+			in: "[embedmd]:# (code.go)\n", out: "[embedmd]:# (code.go)\n```go\npackage main\n```\n"},
+		{name: "format of the path", path: filepath.Join(dir, "docs", "index.rst"),
+			in: ".. embedmd: code.go\n", out: ".. embedmd: code.go\n\n.. code-block:: go\n\n   package main\n"},
+		{name: "nothing written on failure", path: filepath.Join(dir, "README.md"),
+			in: "# Title\n\n[embedmd]:# (code.go)\n", err: "3: could not read code.go: open " + filepath.Join(dir, "code.go") + ": no such file or directory"},
+	}
+
+	defer func(r io.Reader, w io.Writer, p string) { stdin, stdout, stdinPath = r, w, p }(stdin, stdout, stdinPath)
+	for _, tt := range tc {
+		stdin = strings.NewReader(tt.in)
+		buf := &bytes.Buffer{}
+		stdout = buf
+		stdinPath = tt.path
+		_, err := embed(nil, false, false)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := buf.String(); tt.out != got {
+			t.Errorf("case [%s] expected output\n%q\n; got\n%q", tt.name, tt.out, got)
+		}
+	}
+}
+
 func TestEmbedFiles(t *testing.T) {
 	tc := []struct {
 		name string
@@ -122,6 +170,63 @@ func TestEmbedFiles(t *testing.T) {
 	}
 }
 
+func TestSeverityFlag(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "code.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	doc := filepath.Join(dir, "docs.md")
+	if err := os.WriteFile(doc, []byte("[embedmd]:# (code.go)\n```go\nold\n```\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	var out, errOut bytes.Buffer
+	stdout, stderr = &out, &errOut
+
+	tc := []struct {
+		name  string
+		args  []string
+		stale bool
+		err   string
+	}{
+		{name: "stale by default", args: []string{"-d"}, stale: true},
+		{name: "downgraded", args: []string{"-d", "-severity", "stale=warning"}},
+		{name: "bad level", args: []string{"-d", "-severity", "stale=fatal"}, err: `error: -severity: unknown severity "fatal", should be one of error, warning, ignore`},
+	}
+	for _, tt := range tc {
+		fs := flag.NewFlagSet("embedmd", flag.ContinueOnError)
+		o := newFlags(fs)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		opts, err := o.embedOptions()
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		stale, err := embed([]string{doc}, false, true, opts...)
+		if err != nil {
+			t.Errorf("case [%s]: %v", tt.name, err)
+		}
+		if stale != tt.stale {
+			t.Errorf("case [%s]: expected stale %v; got %v", tt.name, tt.stale, stale)
+		}
+	}
+	if !strings.Contains(errOut.String(), "docs.md:1: warning: block is stale, reported as a warning only") {
+		t.Errorf("expected a warning for the downgraded block; got %q", errOut.String())
+	}
+}
+
+func TestReportStale(t *testing.T) {
+	defer func(e io.Writer) { stderr = e }(stderr)
+	var out bytes.Buffer
+	stderr = &out
+	reportStale([]string{"docs/a.md", "README.md"})
+	want := "2 files out of date, run embedmd -w to update them:\n  docs/a.md\n  README.md\n"
+	if out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+}
+
 func eqErr(t *testing.T, id string, err error, msg string) bool {
 	if err == nil && msg == "" {
 		return true
@@ -147,3 +252,365 @@ func (f *fakeFile) Truncate(int64) error                        { return nil }
 func newFakeFile(s string) *fakeFile {
 	return &fakeFile{ReadCloser: io.NopCloser(strings.NewReader(s))}
 }
+
+func TestForgeFlag(t *testing.T) {
+	tc := []struct {
+		name   string
+		args   []string
+		forges []embedmd.Forge
+		err    string
+	}{
+		{name: "kind", args: []string{"-forge", "ghe.example.com=github"},
+			forges: []embedmd.Forge{{Host: "ghe.example.com", Kind: "github"}}},
+		{name: "templates",
+			args: []string{"-forge", "git.example.com=gitea raw=https://cdn.example.com/{repo}/{path} clone=ssh://git.example.com/{owner}/{repo}"},
+			forges: []embedmd.Forge{{Host: "git.example.com", Kind: "gitea",
+				RawURL: "https://cdn.example.com/{repo}/{path}", CloneURL: "ssh://git.example.com/{owner}/{repo}"}}},
+		{name: "wiki", args: []string{"-forge", "ghe.example.com=github wiki=https://ghe.example.com/raw/wiki/{owner}/{repo}/{path}"},
+			forges: []embedmd.Forge{{Host: "ghe.example.com", Kind: "github", WikiURL: "https://ghe.example.com/raw/wiki/{owner}/{repo}/{path}"}}},
+		{name: "missing kind", args: []string{"-forge", "git.example.com="},
+			err: "error: -forge: missing kind of forge git.example.com"},
+		{name: "unknown template", args: []string{"-forge", "git.example.com=gitea api=x"},
+			err: `error: -forge: unknown template "api" of forge git.example.com, should be raw, clone, or wiki`},
+	}
+	for _, tt := range tc {
+		fs := flag.NewFlagSet("embedmd", flag.ContinueOnError)
+		o := newFlags(fs)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		forges, err := o.forgeList()
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if !slices.Equal(forges, tt.forges) {
+			t.Errorf("case [%s]: expected %v; got %v", tt.name, tt.forges, forges)
+		}
+	}
+}
+
+func TestTokenFlag(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, r.Header.Get("Authorization"))
+	}))
+	defer ts.Close()
+	host := strings.TrimPrefix(ts.URL, "http://")
+	t.Setenv("EMBEDMD_TEST_TOKEN", "secret")
+
+	tc := []struct {
+		name string
+		args []string
+		out  string
+		err  string
+	}{
+		{name: "matching", args: []string{"-token", host + "/private/**=EMBEDMD_TEST_TOKEN"}, out: "Bearer secret\n"},
+		{name: "not matching", args: []string{"-token", "example.com/**=EMBEDMD_TEST_TOKEN"}, out: "\n"},
+		{name: "unset variable", args: []string{"-token", host + "/**=EMBEDMD_TEST_UNSET"},
+			err: "error: -token: environment variable EMBEDMD_TEST_UNSET of " + host + "/** is not set"},
+	}
+	for _, tt := range tc {
+		fs := flag.NewFlagSet("embedmd", flag.ContinueOnError)
+		o := newFlags(fs)
+		if err := fs.Parse(append(tt.args, "-no-cache")); err != nil {
+			t.Fatal(err)
+		}
+		f, err := o.fetcher()
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		b, err := f.Fetch("", ts.URL+"/private/a.go")
+		if err != nil {
+			t.Errorf("case [%s]: %v", tt.name, err)
+			continue
+		}
+		if string(b) != tt.out {
+			t.Errorf("case [%s]: expected %q; got %q", tt.name, tt.out, b)
+		}
+	}
+}
+
+func TestCredentialFlag(t *testing.T) {
+	t.Setenv("EMBEDMD_TEST_TOKEN", "secret")
+	tc := []struct {
+		name string
+		args []string
+		want embedmd.Credential
+		err  string
+	}{
+		{name: "bearer", args: []string{"-credential", "git.example.com=EMBEDMD_TEST_TOKEN"}, want: embedmd.BearerToken("secret")},
+		{name: "header", args: []string{"-credential", "git.example.com=EMBEDMD_TEST_TOKEN header=X-Api-Key"},
+			want: embedmd.Credential{Header: "X-Api-Key", Value: "secret"}},
+		{name: "unset", args: []string{"-credential", "git.example.com=EMBEDMD_TEST_UNSET"},
+			err: "error: -credential: environment variable EMBEDMD_TEST_UNSET of git.example.com is not set"},
+		{name: "unknown setting", args: []string{"-credential", "git.example.com=EMBEDMD_TEST_TOKEN scheme=Basic"},
+			err: `error: -credential: unknown setting "scheme=Basic" of git.example.com, should be header=name`},
+	}
+	for _, tt := range tc {
+		fs := flag.NewFlagSet("embedmd", flag.ContinueOnError)
+		o := newFlags(fs)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		creds, err := o.credentialProvider()
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if c, ok := creds.Credential("git.example.com"); !ok || c != tt.want {
+			t.Errorf("case [%s]: expected %v; got %v", tt.name, tt.want, c)
+		}
+	}
+}
+
+func TestSourceArchiveFlag(t *testing.T) {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	w, err := zw.Create("src/a.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(w, "package a\n")
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	defer func(r io.Reader) { stdin = r }(stdin)
+	stdin = &b
+
+	fs := flag.NewFlagSet("embedmd", flag.ContinueOnError)
+	o := newFlags(fs)
+	if err := fs.Parse([]string{"-source-archive", "-", "-no-cache"}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := o.fetcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.Fetch("docs", "../src/a.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "package a\n" {
+		t.Errorf("expected the file of the archive, got %q", got)
+	}
+}
+
+func TestSyntaxFlag(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	in := "[embedmd]:# (a.go)\n\n<!-- embedmd: a.go -->\n"
+	tc := []struct {
+		name string
+		args []string
+		out  string
+		err  string
+	}{
+		{name: "comment", args: []string{"-syntax", "comment"}, out: "[embedmd]:# (a.go)\n\n<!-- embedmd: a.go -->\n```go\npackage a\n```\n"},
+		{name: "both", args: []string{"-syntax", "link, comment"},
+			out: "[embedmd]:# (a.go)\n```go\npackage a\n```\n\n<!-- embedmd: a.go -->\n```go\npackage a\n```\n"},
+		{name: "unknown", args: []string{"-syntax", "link,wiki"}, err: "bad syntax \"wiki\", should be link or comment"},
+	}
+	for _, tt := range tc {
+		fs := flag.NewFlagSet("embedmd", flag.ContinueOnError)
+		o := newFlags(fs)
+		if err := fs.Parse(append(tt.args, "-no-cache", "-base-dir", dir)); err != nil {
+			t.Fatal(err)
+		}
+		opts, err := o.embedOptions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		err = embedmd.Process(&out, strings.NewReader(in), opts...)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if got := out.String(); got != tt.out {
+			t.Errorf("case [%s]: expected\n%q\ngot\n%q", tt.name, tt.out, got)
+		}
+	}
+}
+
+func TestRewriteKeepsFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "code.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fresh := filepath.Join(dir, "fresh.md")
+	if err := os.WriteFile(fresh, []byte("[embedmd]:# (code.go)\n```go\npackage main\n```\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	stale := filepath.Join(dir, "stale.md")
+	if err := os.WriteFile(stale, []byte("[embedmd]:# (code.go)\n```go\nold\n```\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, path := range []string{fresh, stale} {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := embed([]string{fresh, stale}, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(fresh); err != nil {
+		t.Fatal(err)
+	} else if !fi.ModTime().Equal(old) {
+		t.Errorf("expected %s to be left untouched; modified at %v", fresh, fi.ModTime())
+	}
+	fi, err := os.Stat(stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("expected %s to keep mode 0600; got %v", stale, fi.Mode().Perm())
+	}
+	b, err := os.ReadFile(stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[embedmd]:# (code.go)\n```go\npackage main\n```\n"; string(b) != want {
+		t.Errorf("expected %s to be rewritten to %q; got %q", stale, want, b)
+	}
+}
+
+func TestVerifyIdempotent(t *testing.T) {
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "doc.md")
+	if err := os.WriteFile(doc, []byte("# Doc\n\n[embedmd]:# (code.go)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(v bool) { verifyIdempotent = v }(verifyIdempotent)
+	verifyIdempotent = true
+	defer func(o io.Writer) { stdout = o }(stdout)
+	stdout = io.Discard
+
+	stable := embedmd.FetcherFunc(func(_, _ string) ([]byte, error) { return []byte("package main\n"), nil })
+	if _, err := embed([]string{doc}, false, false, embedmd.WithFetcher(stable)); err != nil {
+		t.Errorf("stable content: %v", err)
+	}
+
+	n := 0
+	changing := embedmd.FetcherFunc(func(_, _ string) ([]byte, error) {
+		n++
+		return []byte(fmt.Sprintf("const n = %d\n", n)), nil
+	})
+	_, err := embed([]string{doc}, false, false, embedmd.WithFetcher(changing))
+	want := filepath.ToSlash(doc) + ":5: processing is not idempotent, processing the output again changes it:\n"
+	if err == nil || !strings.HasPrefix(err.Error(), want) || !strings.Contains(err.Error(), "+const n = 2") {
+		t.Errorf("changing content: expected error starting with %q; got %v", want, err)
+	}
+}
+
+func TestRecheckFlag(t *testing.T) {
+	content := "v1\n"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, content)
+	}))
+	defer ts.Close()
+	cache := t.TempDir()
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "docs.md")
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	stdout, stderr = io.Discard, io.Discard
+
+	run := func(args ...string) bool {
+		fs := flag.NewFlagSet("embedmd", flag.ContinueOnError)
+		o := newFlags(fs)
+		if err := fs.Parse(append([]string{"-cache-dir", cache, "-cache-ttl", "1h"}, args...)); err != nil {
+			t.Fatal(err)
+		}
+		opts, err := o.embedOptions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stale, err := embed([]string{doc}, o.rewrite, o.doDiff, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stale
+	}
+	// The cache holds v1, while the docs were updated to v2 elsewhere.
+	if err := os.WriteFile(doc, []byte("[embedmd]:# ("+ts.URL+"/code.go)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run("-w")
+	content = "v2\n"
+	if err := os.WriteFile(doc, []byte("[embedmd]:# ("+ts.URL+"/code.go)\n```go\nv2\n```\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !run("-d", "-recheck=false") {
+		t.Errorf("expected the cached content to be reported stale without -recheck")
+	}
+	if run("-d") {
+		t.Errorf("expected the content fetched again to be up to date")
+	}
+}
+
+func TestDocumentFormats(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "code.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	docs := map[string]struct{ in, out string }{
+		"index.rst": {
+			in:  ".. embedmd: code.go\n",
+			out: ".. embedmd: code.go\n\n.. code-block:: go\n\n   package main\n"},
+		"index.adoc": {
+			in:  "// embedmd: code.go\n",
+			out: "// embedmd: code.go\n[source,go]\n----\npackage main\n----\n"},
+		"tutorial.ipynb": {
+			in: `{"cells": [{"cell_type": "markdown", "metadata": {}, "source": ["[embedmd]:# (code.go)"]}], "metadata": {}, "nbformat": 4, "nbformat_minor": 4}`,
+			out: "{\n \"cells\": [\n  {\n   \"cell_type\": \"markdown\",\n   \"metadata\": {},\n   \"source\": [\n    \"[embedmd]:# (code.go)\"\n   ]\n  },\n" +
+				"  {\n   \"cell_type\": \"code\",\n   \"execution_count\": null,\n   \"metadata\": {},\n   \"outputs\": [],\n   \"source\": [\n    \"package main\"\n   ]\n  }\n ],\n" +
+				" \"metadata\": {},\n \"nbformat\": 4,\n \"nbformat_minor\": 4\n}\n"},
+	}
+	for name, doc := range docs {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(doc.in), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := embed([]string{path}, true, false); err != nil {
+			t.Errorf("case [%s]: %v", name, err)
+			continue
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != doc.out {
+			t.Errorf("case [%s]: expected\n%q\ngot\n%q", name, doc.out, b)
+		}
+	}
+}
+
+func TestSignedFetchesUseNetwork(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets not supported: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "signed: %v", strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256"))
+	}))
+	server.Listener = l
+	server.Start()
+	defer server.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := newFlags(fs)
+	if err := fs.Parse([]string{"-sign-aws", "-unix-socket", socket, "-no-cache"}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := o.fetcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := f.Fetch("", "http://bucket.s3.amazonaws.com/main.go")
+	if err != nil || string(b) != "signed: true" {
+		t.Errorf("expected the signed request to be sent to the unix socket; got %q, %v", b, err)
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// This file parses the subset of markdown published to other platforms, such
+// as Confluence and Notion, by the commands converting it to their formats.
+
+// mdKind is the kind of a block of markdown.
+type mdKind int
+
+const (
+	mdParagraph mdKind = iota
+	mdHeading
+	mdBulleted
+	mdNumbered
+	mdQuote
+	mdRule
+	mdCode
+)
+
+// mdBlock is a block of markdown, as parsed by parseBlocks.
+type mdBlock struct {
+	kind mdKind
+	// level is the level of headings.
+	level int
+	// text is the inline markdown of headings, paragraphs, and list items.
+	text string
+	// lang and code are the language and the content of code blocks.
+	lang, code string
+	// children holds the blocks of quotes and the code blocks of list items.
+	children []mdBlock
+}
+
+var (
+	mdHeadingLine = regexp.MustCompile(`^ {0,3}(#{1,6})[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*$`)
+	mdRuleLine    = regexp.MustCompile(`^ {0,3}(?:-(?:[ \t]*-){2,}|\*(?:[ \t]*\*){2,}|_(?:[ \t]*_){2,})[ \t]*$`)
+	mdItemLine    = regexp.MustCompile(`^[ \t]*(?:([-*+])|\d{1,9}[.)])[ \t]+(.*)$`)
+	mdFenceLine   = regexp.MustCompile("^([ \t]*)(`{3,}|~{3,})[ \t]*([^ \t`{]*)")
+	mdRefDefLine  = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:`)
+	mdCommentLine = regexp.MustCompile(`^[ \t]*<!--`)
+	mdQuoteLine   = regexp.MustCompile(`^ {0,3}> ?`)
+)
+
+// parseBlocks parses the blocks of the markdown md, returning the text of its
+// first level one heading, which is removed to title the page, separately.
+// Comments and link reference definitions, such as commands, are dropped.
+func parseBlocks(md string) (title string, blocks []mdBlock) {
+	return parseBlocksTitled(md, true)
+}
+
+func parseBlocksTitled(md string, titled bool) (title string, blocks []mdBlock) {
+	var para []string
+	// item is the index of the list item the text is added to, if any.
+	item := -1
+	flushPara := func() {
+		if len(para) == 0 {
+			return
+		}
+		text := strings.Join(para, " ")
+		if item >= 0 {
+			blocks[item].text = strings.TrimSpace(blocks[item].text + " " + text)
+		} else {
+			blocks = append(blocks, mdBlock{kind: mdParagraph, text: text})
+		}
+		para = nil
+	}
+	closeList := func() {
+		flushPara()
+		item = -1
+	}
+
+	lines := strings.Split(strings.TrimSuffix(md, "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimLeft(line, " \t")
+		indent := len(line) - len(trimmed)
+		switch m := mdFenceLine.FindStringSubmatch(line); {
+		case m != nil && (item >= 0 || indent < 4):
+			// Fences nested in list items are part of the item.
+			if item >= 0 && indent == 0 {
+				closeList()
+			}
+			flushPara()
+			var code []string
+			for i++; i < len(lines); i++ {
+				l := strings.TrimLeft(lines[i], " \t")
+				if strings.HasPrefix(l, m[2]) && strings.Trim(l, m[2][:1]+" \t") == "" {
+					break
+				}
+				code = append(code, cutIndent(lines[i], len(m[1])))
+			}
+			b := mdBlock{kind: mdCode, lang: m[3], code: strings.Join(code, "\n")}
+			if item >= 0 {
+				blocks[item].children = append(blocks[item].children, b)
+			} else {
+				blocks = append(blocks, b)
+			}
+		case trimmed == "":
+			flushPara()
+		case mdCommentLine.MatchString(line):
+			for !strings.Contains(lines[i], "-->") && i+1 < len(lines) {
+				i++
+			}
+		case mdRefDefLine.MatchString(line) && len(para) == 0:
+		case mdQuoteLine.MatchString(line):
+			closeList()
+			var quoted []string
+			for ; i < len(lines) && mdQuoteLine.MatchString(lines[i]); i++ {
+				quoted = append(quoted, mdQuoteLine.ReplaceAllString(lines[i], ""))
+			}
+			i--
+			_, inner := parseBlocksTitled(strings.Join(quoted, "\n"), false)
+			blocks = append(blocks, mdBlock{kind: mdQuote, children: inner})
+		case mdHeadingLine.MatchString(line):
+			closeList()
+			m := mdHeadingLine.FindStringSubmatch(line)
+			if len(m[1]) == 1 && titled && title == "" {
+				title = m[2]
+				continue
+			}
+			blocks = append(blocks, mdBlock{kind: mdHeading, level: len(m[1]), text: m[2]})
+		case mdRuleLine.MatchString(line):
+			closeList()
+			blocks = append(blocks, mdBlock{kind: mdRule})
+		case mdItemLine.MatchString(line):
+			m := mdItemLine.FindStringSubmatch(line)
+			kind := mdNumbered
+			if m[1] != "" {
+				kind = mdBulleted
+			}
+			closeList()
+			blocks = append(blocks, mdBlock{kind: kind})
+			item, para = len(blocks)-1, []string{m[2]}
+		case indent >= 4 && item < 0 && len(para) == 0:
+			var code []string
+			for ; i < len(lines) && (strings.TrimSpace(lines[i]) == "" || strings.HasPrefix(lines[i], "    ") || strings.HasPrefix(lines[i], "\t")); i++ {
+				code = append(code, cutIndent(lines[i], 4))
+			}
+			i--
+			for len(code) > 0 && strings.TrimSpace(code[len(code)-1]) == "" {
+				code = code[:len(code)-1]
+			}
+			blocks = append(blocks, mdBlock{kind: mdCode, code: strings.Join(code, "\n")})
+		default:
+			// Text after a blank line, without indentation, ends lists.
+			if item >= 0 && indent == 0 && len(para) == 0 {
+				closeList()
+			}
+			para = append(para, strings.TrimSpace(line))
+		}
+	}
+	closeList()
+	return title, blocks
+}
+
+// cutIndent removes up to n leading blanks from line.
+func cutIndent(line string, n int) string {
+	i := 0
+	for i < n && i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+	return line[i:]
+}
+
+// mdSpan is a run of inline markdown text with the same formatting.
+type mdSpan struct {
+	text                   string
+	code, strong, emphasis bool
+	// link is the URL the text links to, or the URL of the image if image is
+	// set.
+	link  string
+	image bool
+}
+
+var (
+	mdInline   = regexp.MustCompile("`+[^`]+`+|!?\\[([^\\]]*)\\]\\(([^)\\s]*)(?:\\s+\"[^\"]*\")?\\)|<(https?://[^>\\s]+)>")
+	mdStrong   = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*|__(\S(?:.*?\S)?)__`)
+	mdEmphasis = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*|\b_(\S(?:.*?\S)?)_\b`)
+)
+
+// parseInline parses the inline markdown s into spans: code, links, images,
+// and strong and emphasized text.
+func parseInline(s string) []mdSpan {
+	var spans []mdSpan
+	for {
+		loc := mdInline.FindStringSubmatchIndex(s)
+		if loc == nil {
+			return append(spans, emphasized(s, "")...)
+		}
+		spans = append(spans, emphasized(s[:loc[0]], "")...)
+		switch tok := s[loc[0]:loc[1]]; {
+		case tok[0] == '`':
+			spans = append(spans, mdSpan{text: strings.TrimSpace(strings.Trim(tok, "`")), code: true})
+		case tok[0] == '<':
+			spans = append(spans, mdSpan{text: s[loc[6]:loc[7]], link: s[loc[6]:loc[7]]})
+		case tok[0] == '!':
+			spans = append(spans, mdSpan{text: s[loc[2]:loc[3]], link: s[loc[4]:loc[5]], image: true})
+		default:
+			spans = append(spans, emphasized(s[loc[2]:loc[3]], s[loc[4]:loc[5]])...)
+		}
+		s = s[loc[1]:]
+	}
+}
+
+// emphasized splits s into spans of strong, emphasized, and plain text, all
+// linking to link if set.
+func emphasized(s, link string) []mdSpan {
+	var spans []mdSpan
+	plain := func(s string) {
+		for len(s) > 0 {
+			m := mdEmphasis.FindStringSubmatchIndex(s)
+			if m == nil {
+				spans = append(spans, mdSpan{text: s, link: link})
+				return
+			}
+			if m[0] > 0 {
+				spans = append(spans, mdSpan{text: s[:m[0]], link: link})
+			}
+			spans = append(spans, mdSpan{text: submatch(s, m), emphasis: true, link: link})
+			s = s[m[1]:]
+		}
+	}
+	for len(s) > 0 {
+		m := mdStrong.FindStringSubmatchIndex(s)
+		if m == nil {
+			plain(s)
+			break
+		}
+		plain(s[:m[0]])
+		spans = append(spans, mdSpan{text: submatch(s, m), strong: true, link: link})
+		s = s[m[1]:]
+	}
+	return spans
+}
+
+// submatch returns the text of the group of the alternatives of a match that
+// matched.
+func submatch(s string, m []int) string {
+	if m[2] >= 0 {
+		return s[m[2]:m[3]]
+	}
+	return s[m[4]:m[5]]
+}
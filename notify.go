@@ -0,0 +1,88 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// runSummary summarizes a run checking files with -d.
+type runSummary struct {
+	checked int
+	stale   []string
+	// limits are the rate limits announced by the hosts fetched from.
+	limits []embedmd.RateLimit
+}
+
+// summary is the summary of the current run.
+var summary runSummary
+
+// record adds a checked file to the summary.
+func (s *runSummary) record(path string, stale bool) {
+	s.checked++
+	if stale {
+		s.stale = append(s.stale, filepath.ToSlash(path))
+	}
+}
+
+// message returns the text of the notification for the run, which failed
+// with err if not nil, linking to the report at link if not empty.
+func (s *runSummary) message(err error, link string) string {
+	var b strings.Builder
+	switch {
+	case err != nil:
+		fmt.Fprintf(&b, "embedmd failed after checking %d files: %v\n", s.checked, err)
+	case len(s.stale) == 0:
+		fmt.Fprintf(&b, "embedmd: all %d files are up to date\n", s.checked)
+	default:
+		fmt.Fprintf(&b, "embedmd: %d of %d files are stale\n", len(s.stale), s.checked)
+	}
+	for _, path := range s.stale {
+		fmt.Fprintf(&b, "• %s\n", path)
+	}
+	for _, l := range s.limits {
+		fmt.Fprintf(&b, "Rate limit: %s\n", quota(l))
+	}
+	if link != "" {
+		fmt.Fprintf(&b, "Report: %s\n", link)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// notify posts the given text to a webhook, in the {"text": ...} payload
+// accepted by both Slack and Microsoft Teams incoming webhooks.
+func notify(url, text string) error {
+	b, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{text})
+	if err != nil {
+		return err
+	}
+	res, err := http.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook replied %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+func TestSummaryMessage(t *testing.T) {
+	tc := []struct {
+		name    string
+		summary runSummary
+		err     error
+		link    string
+		text    string
+	}{
+		{name: "up to date", summary: runSummary{checked: 3}, text: "embedmd: all 3 files are up to date"},
+		{
+			name:    "stale",
+			summary: runSummary{checked: 3, stale: []string{"docs/a.md", "b.md"}},
+			link:    "https://ci.example.com/artifacts/report.html",
+			text:    "embedmd: 2 of 3 files are stale\n• docs/a.md\n• b.md\nReport: https://ci.example.com/artifacts/report.html",
+		},
+		{
+			name: "rate limits",
+			summary: runSummary{checked: 2, limits: []embedmd.RateLimit{
+				{Host: "api.github.com", Limit: 60, Remaining: 13, Reset: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)},
+			}},
+			text: "embedmd: all 2 files are up to date\nRate limit: 13 of 60 requests to api.github.com left until 15:04:05",
+		},
+		{
+			name:    "failed",
+			summary: runSummary{checked: 1},
+			err:     errors.New("docs/b.md:2: could not read code.go: not found"),
+			text:    "embedmd failed after checking 1 files: docs/b.md:2: could not read code.go: not found",
+		},
+	}
+	for _, tt := range tc {
+		if text := tt.summary.message(tt.err, tt.link); text != tt.text {
+			t.Errorf("case [%s]: expected\n%s\ngot\n%s", tt.name, tt.text, text)
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	var got struct{ Text string }
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("expected a JSON payload; got %s", r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("bad payload: %v", err)
+		}
+		if got.Text == "fail" {
+			http.Error(w, "invalid_payload", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	if err := notify(srv.URL, "embedmd: all 3 files are up to date"); err != nil {
+		t.Fatalf("could not notify: %v", err)
+	}
+	if got.Text != "embedmd: all 3 files are up to date" {
+		t.Errorf("expected the summary to be posted; got %q", got.Text)
+	}
+	eqErr(t, "rejected", notify(srv.URL, "fail"), "webhook replied 400 Bad Request")
+}
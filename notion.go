@@ -0,0 +1,550 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// notionVersion is the version of the Notion API the requests are written
+// for.
+const notionVersion = "2022-06-28"
+
+// runNotion implements the notion command, syncing the markdown files listed
+// in a mapping file to the Notion pages they're mapped to, with the code
+// their commands embed today.
+func runNotion(args []string) int {
+	fs := flag.NewFlagSet("embedmd notion", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd notion -mapping file [flags]\n")
+		fs.PrintDefaults()
+	}
+	mapping := fs.String("mapping", "", "YAML file mapping the markdown files, relative to it, to the IDs or URLs of their Notion pages")
+	apiURL := fs.String("api-url", "https://api.notion.com", "URL of the Notion API")
+	tokenEnv := fs.String("token-env", "NOTION_TOKEN", "environment variable holding the token of the Notion integration the pages are shared with")
+	dryRun := fs.Bool("dry-run", false, "report the pages that would be updated, without changing them")
+	o := newCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := setup(fs, o); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	switch {
+	case *mapping == "":
+		fmt.Fprintln(stderr, "error: missing -mapping")
+		return 2
+	case fs.NArg() > 0:
+		fmt.Fprintln(stderr, "error: the files synced are listed in the mapping file")
+		return 2
+	}
+	token := os.Getenv(*tokenEnv)
+	if token == "" {
+		fmt.Fprintf(stderr, "error: environment variable %s of the Notion token is not set\n", *tokenEnv)
+		return 2
+	}
+	pages, err := loadNotionMapping(*mapping)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	opts, err := o.embedOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	client, err := o.network().Client()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	n := &notion{base: strings.TrimSuffix(*apiURL, "/"), token: token, client: client}
+
+	results := make([]publishResult, len(pages))
+	for i, p := range pages {
+		results[i] = n.sync(p.doc, p.id, *dryRun, opts...)
+	}
+	return reportPublished(results, *dryRun)
+}
+
+// notionPage maps a markdown file to the ID of its Notion page.
+type notionPage struct{ doc, id string }
+
+// loadNotionMapping reads the mapping file at path, listing the pages synced
+// under pages:
+//
+//	pages:
+//	  docs/usage.md: 1429989fe8ac4effbc8f57f56486db54
+//	  docs/faq.md: https://www.notion.so/acme/FAQ-5e1f3b2c9d8a4f0e8b7c6d5e4f3a2b1c
+func loadNotionMapping(path string) ([]notionPage, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := parseNotionMapping(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", path, err)
+	}
+	for i := range pages {
+		pages[i].doc = filepath.Join(filepath.Dir(path), filepath.FromSlash(pages[i].doc))
+	}
+	return pages, nil
+}
+
+func parseNotionMapping(doc string) ([]notionPage, error) {
+	root, err := parseYAML(doc)
+	if err != nil {
+		return nil, err
+	}
+	if root.kind != yamlMapping {
+		return nil, errorAt(root, "expected a mapping, found a %v", root.kind)
+	}
+	var pages []notionPage
+	for _, p := range root.pairs {
+		if p.key.value != "pages" {
+			return nil, errorAt(p.key, "unknown field %q", p.key.value)
+		}
+		if p.value.kind != yamlMapping {
+			return nil, errorAt(p.value, "pages should be a mapping, found a %v", p.value.kind)
+		}
+		for _, pp := range p.value.pairs {
+			if pp.value.kind != yamlScalar {
+				return nil, errorAt(pp.value, "page of %s should be a scalar, found a %v", pp.key.value, pp.value.kind)
+			}
+			id, ok := notionPageID(pp.value.value)
+			if !ok {
+				return nil, errorAt(pp.value, "bad Notion page %q of %s, should be its ID or URL", pp.value.value, pp.key.value)
+			}
+			pages = append(pages, notionPage{doc: pp.key.value, id: id})
+		}
+	}
+	return pages, nil
+}
+
+// notionPageID returns the ID of the page given by its ID, with or without
+// dashes, or its URL, which ends with the ID.
+func notionPageID(s string) (string, bool) {
+	if u, err := url.Parse(s); err == nil && u.Host != "" {
+		s = u.Path[strings.LastIndex(u.Path, "/")+1:]
+	}
+	s = strings.ReplaceAll(s, "-", "")
+	if len(s) < 32 {
+		return "", false
+	}
+	id := strings.ToLower(s[len(s)-32:])
+	if strings.Trim(id, "0123456789abcdef") != "" {
+		return "", false
+	}
+	return id, true
+}
+
+// notion syncs pages through the API of Notion.
+type notion struct {
+	base, token string
+	client      *http.Client
+}
+
+// sync replaces the content of the page id with the blocks of doc, unless
+// they're already the same.
+func (n *notion) sync(doc, id string, dryRun bool, opts ...embedmd.Option) publishResult {
+	r := publishResult{doc: doc, title: id}
+	in, err := os.ReadFile(doc)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	opts = append([]embedmd.Option{embedmd.WithBaseDir(filepath.Dir(doc)), warnings(doc)}, opts...)
+	var out bytes.Buffer
+	if err := embedmd.Process(&out, bytes.NewReader(in), opts...); err != nil {
+		r.err = fmt.Errorf("%s:%v", filepath.ToSlash(doc), err)
+		return r
+	}
+	// Notion pages have their own title, so the first heading is kept.
+	_, md := parseBlocksTitled(out.String(), false)
+	blocks := notionBlocks(md)
+
+	old, err := n.children(id)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	if notionSummary(old) == notionSummary(blocks) {
+		r.action = "unchanged"
+		return r
+	}
+	r.action = "updated"
+	if !dryRun {
+		r.err = n.replace(id, old, blocks)
+	}
+	return r
+}
+
+// children returns the blocks of the page or block id, with their own
+// children.
+func (n *notion) children(id string) ([]notionBlock, error) {
+	var blocks []notionBlock
+	cursor := ""
+	for {
+		q := url.Values{"page_size": {"100"}}
+		if cursor != "" {
+			q.Set("start_cursor", cursor)
+		}
+		var page struct {
+			Results    []notionBlock `json:"results"`
+			HasMore    bool          `json:"has_more"`
+			NextCursor string        `json:"next_cursor"`
+		}
+		if err := n.do("GET", "/v1/blocks/"+id+"/children?"+q.Encode(), nil, &page); err != nil {
+			return nil, fmt.Errorf("could not read blocks of %s: %v", id, err)
+		}
+		blocks = append(blocks, page.Results...)
+		if !page.HasMore {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	for i, b := range blocks {
+		if !b.HasChildren {
+			continue
+		}
+		var err error
+		if blocks[i].Children, err = n.children(b.ID); err != nil {
+			return nil, err
+		}
+	}
+	return blocks, nil
+}
+
+// replace appends the new blocks to the page id, as many as the API accepts
+// at once, then deletes the old ones. If an append fails, the blocks already
+// appended are deleted instead, leaving the page as it was.
+func (n *notion) replace(id string, old, blocks []notionBlock) error {
+	var added []notionBlock
+	for len(blocks) > 0 {
+		chunk := blocks[:min(len(blocks), 100)]
+		blocks = blocks[len(chunk):]
+		body := map[string]any{"children": chunk}
+		var res struct {
+			Results []notionBlock `json:"results"`
+		}
+		if err := n.do("PATCH", "/v1/blocks/"+id+"/children", body, &res); err != nil {
+			err = fmt.Errorf("could not append blocks to %s: %v", id, err)
+			if derr := n.delete(added); derr != nil {
+				err = fmt.Errorf("%v; %v", err, derr)
+			}
+			return err
+		}
+		added = append(added, res.Results...)
+	}
+	return n.delete(old)
+}
+
+// delete deletes the blocks.
+func (n *notion) delete(blocks []notionBlock) error {
+	for _, b := range blocks {
+		if err := n.do("DELETE", "/v1/blocks/"+b.ID, nil, nil); err != nil {
+			return fmt.Errorf("could not delete block %s: %v", b.ID, err)
+		}
+	}
+	return nil
+}
+
+// do sends a request to the API with the JSON encoding of in, if not nil,
+// and decodes the response into out, if not nil.
+func (n *notion) do(method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, n.base+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+n.token)
+	req.Header.Set("Notion-Version", notionVersion)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		// Errors of the API explain what was wrong with the request.
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.NewDecoder(res.Body).Decode(&e) == nil && e.Message != "" {
+			return fmt.Errorf("status %s: %s", res.Status, e.Message)
+		}
+		return fmt.Errorf("status %s", res.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// notionBlock is a block of a Notion page, as sent to and returned by its
+// API, where the content is found under the type of the block.
+type notionBlock struct {
+	ID          string
+	Type        string
+	HasChildren bool
+	Text        []notionText
+	// Language is the language of code blocks.
+	Language string
+	Children []notionBlock
+}
+
+// notionText is a run of rich text of a block.
+type notionText struct {
+	Type string `json:"type"`
+	Text struct {
+		Content string `json:"content"`
+		Link    *struct {
+			URL string `json:"url"`
+		} `json:"link,omitempty"`
+	} `json:"text"`
+	Annotations struct {
+		Bold   bool `json:"bold"`
+		Italic bool `json:"italic"`
+		Code   bool `json:"code"`
+	} `json:"annotations"`
+}
+
+// notionContent is the content of a block, under its type.
+type notionContent struct {
+	RichText []notionText  `json:"rich_text,omitempty"`
+	Language string        `json:"language,omitempty"`
+	Children []notionBlock `json:"children,omitempty"`
+}
+
+func (b notionBlock) MarshalJSON() ([]byte, error) {
+	content := map[string]any{}
+	if b.Type != "divider" {
+		content["rich_text"] = append([]notionText{}, b.Text...)
+	}
+	if b.Language != "" {
+		content["language"] = b.Language
+	}
+	if len(b.Children) > 0 {
+		content["children"] = b.Children
+	}
+	return json.Marshal(map[string]any{"object": "block", "type": b.Type, b.Type: content})
+}
+
+func (b *notionBlock) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	var head struct {
+		ID          string `json:"id"`
+		Type        string `json:"type"`
+		HasChildren bool   `json:"has_children"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return err
+	}
+	var content notionContent
+	if raw, ok := fields[head.Type]; ok {
+		if err := json.Unmarshal(raw, &content); err != nil {
+			return err
+		}
+	}
+	*b = notionBlock{ID: head.ID, Type: head.Type, HasChildren: head.HasChildren, Text: content.RichText, Language: content.Language}
+	return nil
+}
+
+// notionSummary returns the content of blocks that's compared to tell
+// whether a page is up to date: their types, text, formatting, and links.
+func notionSummary(blocks []notionBlock) string {
+	var b strings.Builder
+	var write func(blocks []notionBlock, depth int)
+	write = func(blocks []notionBlock, depth int) {
+		for _, bl := range blocks {
+			fmt.Fprintf(&b, "%s%s %s:", strings.Repeat("\t", depth), bl.Type, bl.Language)
+			for _, t := range bl.Text {
+				link := ""
+				if t.Text.Link != nil {
+					link = t.Text.Link.URL
+				}
+				fmt.Fprintf(&b, " %q%v%v%v%s", t.Text.Content, t.Annotations.Bold, t.Annotations.Italic, t.Annotations.Code, link)
+			}
+			b.WriteString("\n")
+			write(bl.Children, depth+1)
+		}
+	}
+	write(blocks, 0)
+	return b.String()
+}
+
+// notionMaxText is the maximum length of the content of a run of rich text.
+const notionMaxText = 2000
+
+// notionBlocks converts markdown blocks to Notion blocks, code blocks
+// becoming code blocks tagged with their language.
+func notionBlocks(blocks []mdBlock) []notionBlock {
+	var out []notionBlock
+	for _, bl := range blocks {
+		var nb notionBlock
+		switch bl.kind {
+		case mdParagraph:
+			nb = notionBlock{Type: "paragraph", Text: notionRichText(bl.text)}
+		case mdHeading:
+			nb = notionBlock{Type: fmt.Sprintf("heading_%d", min(bl.level, 3)), Text: notionRichText(bl.text)}
+		case mdBulleted:
+			nb = notionBlock{Type: "bulleted_list_item", Text: notionRichText(bl.text), Children: notionBlocks(bl.children)}
+		case mdNumbered:
+			nb = notionBlock{Type: "numbered_list_item", Text: notionRichText(bl.text), Children: notionBlocks(bl.children)}
+		case mdQuote:
+			// The text of quotes is their first paragraph, followed by their
+			// other blocks.
+			children := bl.children
+			nb = notionBlock{Type: "quote"}
+			if len(children) > 0 && children[0].kind == mdParagraph {
+				nb.Text, children = notionRichText(children[0].text), children[1:]
+			}
+			nb.Children = notionBlocks(children)
+		case mdRule:
+			nb = notionBlock{Type: "divider"}
+		case mdCode:
+			nb = notionBlock{Type: "code", Language: notionLanguage(bl.lang)}
+			for _, chunk := range splitText(bl.code, notionMaxText) {
+				nb.Text = append(nb.Text, newNotionText(chunk))
+			}
+		}
+		out = append(out, nb)
+	}
+	return out
+}
+
+// notionRichText converts inline markdown to rich text. Links are kept if
+// absolute, as Notion only accepts those, and images are linked.
+func notionRichText(s string) []notionText {
+	var rich []notionText
+	for _, sp := range parseInline(s) {
+		for _, chunk := range splitText(sp.text, notionMaxText) {
+			t := newNotionText(chunk)
+			t.Annotations.Bold, t.Annotations.Italic, t.Annotations.Code = sp.strong, sp.emphasis, sp.code
+			if u, err := url.Parse(sp.link); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+				t.Text.Link = &struct {
+					URL string `json:"url"`
+				}{sp.link}
+			}
+			rich = append(rich, t)
+		}
+	}
+	return rich
+}
+
+func newNotionText(content string) notionText {
+	t := notionText{Type: "text"}
+	t.Text.Content = content
+	return t
+}
+
+// splitText splits s in chunks of at most n runes.
+func splitText(s string, n int) []string {
+	var chunks []string
+	for r := []rune(s); len(r) > 0; {
+		k := min(len(r), n)
+		chunks = append(chunks, string(r[:k]))
+		r = r[k:]
+	}
+	return chunks
+}
+
+// notionLanguages maps the languages of fenced code blocks to the names of
+// those highlighted by Notion, when they differ.
+var notionLanguages = map[string]string{
+	"":           "plain text",
+	"text":       "plain text",
+	"txt":        "plain text",
+	"sh":         "shell",
+	"console":    "shell",
+	"zsh":        "shell",
+	"js":         "javascript",
+	"jsx":        "javascript",
+	"ts":         "typescript",
+	"tsx":        "typescript",
+	"py":         "python",
+	"rb":         "ruby",
+	"rs":         "rust",
+	"kt":         "kotlin",
+	"cpp":        "c++",
+	"cc":         "c++",
+	"cs":         "c#",
+	"csharp":     "c#",
+	"fs":         "f#",
+	"yml":        "yaml",
+	"md":         "markdown",
+	"dockerfile": "docker",
+	"proto":      "protobuf",
+	"ps1":        "powershell",
+	"objc":       "objective-c",
+	"tex":        "latex",
+	"hs":         "haskell",
+	"ex":         "elixir",
+	"exs":        "elixir",
+	"erl":        "erlang",
+	"ml":         "ocaml",
+	"pl":         "perl",
+	"clj":        "clojure",
+	"make":       "makefile",
+	"wasm":       "webassembly",
+}
+
+// notionKnownLanguages are the languages Notion highlights.
+var notionKnownLanguages = strings.Fields(`abap arduino bash basic c clojure coffeescript c++ c# css dart
+	diff docker elixir elm erlang flow fortran f# gherkin glsl go graphql groovy haskell html java
+	javascript json julia kotlin latex less lisp livescript lua makefile markdown markup matlab
+	mermaid nix objective-c ocaml pascal perl php powershell prolog protobuf python r reason ruby
+	rust sass scala scheme scss shell sql swift typescript verilog vhdl xml yaml`)
+
+// notionLanguage returns the language Notion highlights code in lang as, or
+// plain text if it doesn't know it.
+func notionLanguage(lang string) string {
+	lang = strings.ToLower(lang)
+	if l, ok := notionLanguages[lang]; ok {
+		return l
+	}
+	for _, l := range notionKnownLanguages {
+		if l == lang {
+			return l
+		}
+	}
+	return "plain text"
+}
@@ -0,0 +1,223 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseNotionMapping(t *testing.T) {
+	tc := []struct {
+		name, in string
+		pages    []notionPage
+		err      string
+	}{
+		{name: "ids and urls", in: "pages:\n  a.md: 1429989F-e8ac-4eff-bc8f-57f56486db54\n  docs/b.md: https://www.notion.so/acme/FAQ-5e1f3b2c9d8a4f0e8b7c6d5e4f3a2b1c?pvs=4\n",
+			pages: []notionPage{{"a.md", "1429989fe8ac4effbc8f57f56486db54"}, {"docs/b.md", "5e1f3b2c9d8a4f0e8b7c6d5e4f3a2b1c"}}},
+		{name: "bad id", in: "pages:\n  a.md: FAQ\n", err: "2:9: bad Notion page \"FAQ\" of a.md, should be its ID or URL"},
+		{name: "unknown field", in: "page:\n  a.md: x\n", err: "1:1: unknown field \"page\""},
+		{name: "not a mapping", in: "pages: [a.md]\n", err: "1:8: pages should be a mapping, found a sequence"},
+	}
+	for _, tt := range tc {
+		pages, err := parseNotionMapping(tt.in)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if fmt.Sprint(pages) != fmt.Sprint(tt.pages) {
+			t.Errorf("case [%s]: expected %v, got %v", tt.name, tt.pages, pages)
+		}
+	}
+}
+
+func TestRunNotion(t *testing.T) {
+	const pageID = "1429989fe8ac4effbc8f57f56486db54"
+	var mu sync.Mutex
+	children := map[string][]map[string]any{}
+	var changes []string
+	nextID := 0
+	failAppend := 0
+	var store func(parent string, blocks []map[string]any)
+	store = func(parent string, blocks []map[string]any) {
+		for _, b := range blocks {
+			nextID++
+			id := "b" + strconv.Itoa(nextID)
+			b["id"] = id
+			content := b[b["type"].(string)].(map[string]any)
+			if nested, ok := content["children"].([]any); ok {
+				var kids []map[string]any
+				for _, k := range nested {
+					kids = append(kids, k.(map[string]any))
+				}
+				store(id, kids)
+				delete(content, "children")
+				b["has_children"] = true
+			}
+			children[parent] = append(children[parent], b)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("Notion-Version") != notionVersion {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"object":"error","message":"API token is invalid."}`)
+			return
+		}
+		parent, ok := strings.CutPrefix(r.URL.Path, "/v1/blocks/")
+		parent, isChildren := strings.CutSuffix(parent, "/children")
+		switch {
+		case !ok:
+			http.NotFound(w, r)
+		case r.Method == "GET" && isChildren:
+			// Pages of two blocks exercise the pagination.
+			start, _ := strconv.Atoi(r.URL.Query().Get("start_cursor"))
+			blocks := children[parent]
+			end := min(start+2, len(blocks))
+			res := map[string]any{"results": append([]map[string]any{}, blocks[start:end]...), "has_more": end < len(blocks), "next_cursor": strconv.Itoa(end)}
+			json.NewEncoder(w).Encode(res)
+		case r.Method == "PATCH" && isChildren:
+			var req struct {
+				Children []map[string]any `json:"children"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if failAppend--; failAppend == 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"object":"error","message":"body failed validation."}`)
+				return
+			}
+			store(parent, req.Children)
+			changes = append(changes, "append "+strconv.Itoa(len(req.Children)))
+			json.NewEncoder(w).Encode(map[string]any{"results": req.Children})
+		case r.Method == "DELETE":
+			for p, blocks := range children {
+				for i, b := range blocks {
+					if b["id"] == parent {
+						children[p] = append(blocks[:i:i], blocks[i+1:]...)
+					}
+				}
+			}
+			changes = append(changes, "delete "+parent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"code.go":       "package main\n\nfunc main() {\n\tprintln(\"v1\")\n}\n",
+		"docs/usage.md": "# Usage\n\nRun **it**:\n\n1. Build:\n   [embedmd]:# (../code.go /func main/ $)\n",
+		"notion.yaml":   "pages:\n  docs/usage.md: https://www.notion.so/Usage-" + pageID + "\n",
+		configFile:      "version: 1\n",
+	}
+	write := func(name, content string) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		write(name, content)
+	}
+	// The page starts with a block written by hand, which is replaced.
+	children[pageID] = []map[string]any{{"id": "old", "type": "paragraph", "paragraph": map[string]any{"rich_text": []any{}}}}
+	t.Setenv("TEST_NOTION_TOKEN", "secret")
+
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	run := func(extra ...string) (int, string) {
+		mu.Lock()
+		changes = nil
+		mu.Unlock()
+		var out bytes.Buffer
+		stdout, stderr = &out, &out
+		args := append([]string{"-config", filepath.Join(dir, configFile), "-mapping", filepath.Join(dir, "notion.yaml"), "-api-url", server.URL,
+			"-token-env", "TEST_NOTION_TOKEN", "-no-cache"}, extra...)
+		code := runNotion(args)
+		return code, strings.ReplaceAll(out.String(), filepath.ToSlash(dir)+"/", "")
+	}
+	blockTypes := func(blocks []map[string]any) string {
+		var types []string
+		for _, b := range blocks {
+			types = append(types, b["type"].(string))
+		}
+		return strings.Join(types, ",")
+	}
+
+	code, out := run()
+	if code != 0 || !strings.Contains(out, "docs/usage.md: page \""+pageID+"\" updated\n") || strings.Join(changes, ",") != "append 3,delete old" {
+		t.Errorf("first run: got code %d, changes %v, and output\n%s", code, changes, out)
+	}
+	if got := blockTypes(children[pageID]); got != "heading_1,paragraph,numbered_list_item" {
+		t.Errorf("expected the blocks of the doc, got %s", got)
+	}
+	item := children[pageID][2]["id"].(string)
+	if len(children[item]) != 1 {
+		t.Fatalf("expected the code block in the list item, got %v", children[item])
+	}
+	b, _ := json.Marshal(children[item][0])
+	if want := `"code":{"language":"go","rich_text":[{"annotations":{"bold":false,"code":false,"italic":false},"text":{"content":"func main() {\n\tprintln(\"v1\")\n}"},"type":"text"}]}`; !strings.Contains(string(b), want) {
+		t.Errorf("expected the embedded code in a go code block, got %s", b)
+	}
+
+	code, out = run()
+	if code != 0 || !strings.HasSuffix(out, "0 pages created, 0 updated, 1 unchanged, 0 failed\n") || len(changes) != 0 {
+		t.Errorf("second run: expected no change, got code %d, changes %v, and output\n%s", code, changes, out)
+	}
+
+	write("code.go", "package main\n\nfunc main() {\n\tprintln(\"v2\")\n}\n")
+	code, out = run("-dry-run")
+	if code != 0 || !strings.Contains(out, "would be updated\n") || len(changes) != 0 {
+		t.Errorf("dry run: expected the page to be reported only, got code %d, changes %v, and output\n%s", code, changes, out)
+	}
+	code, out = run()
+	if code != 0 || len(changes) != 4 || blockTypes(children[pageID]) != "heading_1,paragraph,numbered_list_item" {
+		t.Errorf("third run: expected the blocks replaced, got code %d, changes %v, and output\n%s", code, changes, out)
+	}
+
+	// A failed append leaves the page as it was, deleting the blocks
+	// appended before it.
+	before := blockTypes(children[pageID])
+	write("docs/usage.md", "# Usage\n\n"+strings.Repeat("Paragraph.\n\n", 150))
+	mu.Lock()
+	failAppend = 2
+	mu.Unlock()
+	code, out = run()
+	if code != 1 || !strings.Contains(out, "could not append blocks to "+pageID+": status 400 Bad Request: body failed validation.\n") ||
+		len(changes) != 101 || changes[0] != "append 100" || !strings.HasPrefix(changes[100], "delete ") {
+		t.Errorf("failed append: got code %d, changes %v, and output\n%s", code, changes, out)
+	}
+	if got := blockTypes(children[pageID]); got != before {
+		t.Errorf("failed append: expected the blocks %s, got %s", before, got)
+	}
+
+	t.Setenv("TEST_NOTION_TOKEN", "wrong")
+	code, out = run()
+	if code != 1 || !strings.Contains(out, "could not read blocks of "+pageID+": status 401 Unauthorized: API token is invalid.\n") {
+		t.Errorf("expected a failure with a wrong token, got code %d and output\n%s", code, out)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// codeownersFiles are the places where a CODEOWNERS file is looked up, as
+// in GitHub and GitLab, relative to the root of the repository.
+var codeownersFiles = []string{"CODEOWNERS", ".github/CODEOWNERS", ".gitlab/CODEOWNERS", "docs/CODEOWNERS"}
+
+// unowned is the group of the files without owners.
+const unowned = "(unowned)"
+
+// An ownerRule gives the owners of the files matching a CODEOWNERS pattern.
+type ownerRule struct {
+	pattern string
+	owners  []string
+}
+
+// loadOwners returns the rules of the CODEOWNERS file at path, or of the
+// first one found in the current directory if path is empty, followed by
+// the extra rules, written as 'pattern owner ...'.
+func loadOwners(path string, extra []string) ([]ownerRule, error) {
+	var rules []ownerRule
+	if path == "" {
+		for _, p := range codeownersFiles {
+			if _, err := os.Stat(p); err == nil {
+				path = p
+				break
+			}
+		}
+	}
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rules = parseOwners(string(b))
+	}
+	for _, r := range extra {
+		rules = append(rules, parseOwners(r)...)
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no CODEOWNERS file found in %s, and no -owner given", strings.Join(codeownersFiles, ", "))
+	}
+	return rules, nil
+}
+
+// parseOwners parses the rules of a CODEOWNERS file.
+func parseOwners(s string) []ownerRule {
+	var rules []ownerRule
+	for _, line := range strings.Split(s, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		rules = append(rules, ownerRule{pattern: fields[0], owners: fields[1:]})
+	}
+	return rules
+}
+
+// ownersOf returns the owners of the file with the given slash separated
+// name, relative to the root of the repository. As in CODEOWNERS files, the
+// last matching rule wins.
+func ownersOf(rules []ownerRule, name string) []string {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if gitignoreMatch(rules[i].pattern, name) {
+			return rules[i].owners
+		}
+	}
+	return nil
+}
+
+// ownerMatch reports whether name matches the CODEOWNERS pattern, which
+// follows the gitignore rules: patterns without a slash but at the end match
+// at any depth, and patterns matching a directory match everything in it.
+func gitignoreMatch(pattern, name string) bool {
+	dir := strings.HasSuffix(pattern, "/")
+	p := strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(p, "/")
+	elems := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	if !anchored {
+		elems = append([]string{"**"}, elems...)
+	}
+	if dir {
+		elems = append(elems, "*")
+	}
+	return matchElems(append(elems, "**"), strings.Split(name, "/"))
+}
+
+// matchElems reports whether the path elements in name match those of the
+// pattern, where ** matches zero or more elements.
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// checkByOwner checks the given files as -d does, but lists the stale ones
+// grouped by owner rather than printing their diffs. The diffs for each
+// owner are written to a file in dir, unless dir is empty. It reports
+// whether any file was stale.
+func checkByOwner(paths []string, rules []ownerRule, dir string, opts ...embedmd.Option) (bool, error) {
+	if len(paths) == 0 {
+		return false, fmt.Errorf("error: cannot use -by-owner with standard input")
+	}
+	planned, err := planFiles(paths, opts...)
+	if err != nil {
+		return false, err
+	}
+	groups := map[string][]*plannedFile{}
+	for i, f := range planned {
+		summary.record(paths[i], f != nil)
+		if f == nil {
+			continue
+		}
+		name, err := repoPath(paths[i])
+		if err != nil {
+			return false, err
+		}
+		owners := ownersOf(rules, name)
+		if len(owners) == 0 {
+			owners = []string{unowned}
+		}
+		for _, o := range owners {
+			groups[o] = append(groups[o], f)
+		}
+	}
+	owners := make([]string, 0, len(groups))
+	for o := range groups {
+		owners = append(owners, o)
+	}
+	sort.Strings(owners)
+
+	for _, o := range owners {
+		fmt.Fprintln(stdout, o)
+		var diffs strings.Builder
+		for _, f := range groups[o] {
+			fmt.Fprintf(stdout, "  %s\n", f.Path)
+			diffs.WriteString(f.Diff)
+		}
+		if dir == "" {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			return false, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, ownerFile(o)), []byte(diffs.String()), 0666); err != nil {
+			return false, err
+		}
+	}
+	return len(owners) > 0, nil
+}
+
+// repoPath returns the slash separated path of the given file relative to
+// the current directory, which is taken as the root of the repository.
+func repoPath(p string) (string, error) {
+	if !filepath.IsAbs(p) {
+		return filepath.ToSlash(filepath.Clean(p)), nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(wd, p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// ownerFile returns the name of the file with the diffs of an owner, such
+// as org_docs-team.diff for @org/docs-team.
+func ownerFile(owner string) string {
+	if owner == unowned {
+		return "unowned.diff"
+	}
+	name := strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, strings.TrimPrefix(owner, "@"))
+	return name + ".diff"
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOwnersOf(t *testing.T) {
+	rules := parseOwners(`# Default owners
+*       @org/everyone
+*.md    @org/writers # docs
+/api/   @org/api
+docs/internal/ @org/internal alice@example.com
+/docs/generated/*.md
+`)
+	tc := []struct {
+		name   string
+		owners []string
+	}{
+		{name: "main.go", owners: []string{"@org/everyone"}},
+		{name: "README.md", owners: []string{"@org/writers"}},
+		{name: "docs/guide/intro.md", owners: []string{"@org/writers"}},
+		{name: "api/v1/handler.go", owners: []string{"@org/api"}},
+		{name: "api/README.md", owners: []string{"@org/api"}},
+		{name: "pkg/api/handler.go", owners: []string{"@org/everyone"}},
+		{name: "docs/internal/a/b.md", owners: []string{"@org/internal", "alice@example.com"}},
+		{name: "docs/generated/api.md", owners: []string{}},
+	}
+	for _, tt := range tc {
+		if owners := ownersOf(rules, tt.name); !reflect.DeepEqual(owners, tt.owners) {
+			t.Errorf("case [%s]: expected owners %v; got %v", tt.name, tt.owners, owners)
+		}
+	}
+}
+
+func TestCheckByOwner(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		configFile:           "version: 1\n",
+		"code.go":            "package main\n",
+		"docs/api/a.md":      "[embedmd]:# (../../code.go)\n",
+		"docs/guide/b.md":    "[embedmd]:# (../../code.go)\n",
+		"docs/fresh.md":      "# Nothing to embed\n",
+		"notes/c.md":         "[embedmd]:# (../code.go)\n",
+		".github/CODEOWNERS": "docs/ @org/docs\ndocs/api/ @org/api @org/docs\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rules, err := loadOwners("", []string{"notes/ @org/notes"})
+	if err != nil {
+		t.Fatalf("could not load owners: %v", err)
+	}
+	defer func(w io.Writer) { stdout = w }(stdout)
+	var out bytes.Buffer
+	stdout = &out
+
+	paths := []string{"docs/api/a.md", "docs/guide/b.md", "docs/fresh.md", "notes/c.md"}
+	stale, err := checkByOwner(paths, rules, "owners", nil...)
+	if err != nil {
+		t.Fatalf("could not check: %v", err)
+	}
+	if !stale {
+		t.Errorf("expected stale files to be reported")
+	}
+	want := "@org/api\n  docs/api/a.md\n@org/docs\n  docs/api/a.md\n  docs/guide/b.md\n@org/notes\n  notes/c.md\n"
+	if out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+
+	b, err := os.ReadFile(filepath.Join("owners", "org_docs.diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte("+++ b/docs/api/a.md")) || !bytes.Contains(b, []byte("+++ b/docs/guide/b.md")) {
+		t.Errorf("expected the diffs of the files of @org/docs; got\n%s", b)
+	}
+	if _, err := os.Stat(filepath.Join("owners", "unowned.diff")); err == nil {
+		t.Errorf("no file should be written for unowned files")
+	}
+
+	if err := os.Remove(filepath.Join(".github", "CODEOWNERS")); err != nil {
+		t.Fatal(err)
+	}
+	_, err = loadOwners("", nil)
+	eqErr(t, "no owners", err, "no CODEOWNERS file found in CODEOWNERS, .github/CODEOWNERS, .gitlab/CODEOWNERS, docs/CODEOWNERS, and no -owner given")
+}
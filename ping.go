@@ -0,0 +1,258 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// pingParallelism is the number of sources checked at once.
+const pingParallelism = 8
+
+// runPing implements the ping command, checking that the remote sources
+// embedded by the given markdown files, or those found in the given
+// directories, can be fetched.
+func runPing(args []string) int {
+	fs := flag.NewFlagSet("embedmd ping", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd ping [flags] [path ...]\n")
+		fs.PrintDefaults()
+	}
+	o := newCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := setup(fs, o); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	sources, err := remoteSources(paths, o)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	client, err := o.network().Client()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	creds, err := o.credentialProvider()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	results := make([]pingResult, len(sources))
+	var wg sync.WaitGroup
+	sem := make(chan bool, pingParallelism)
+	for i, s := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- true
+			defer func() { <-sem }()
+			results[i] = ping(client, creds, s)
+		}()
+	}
+	wg.Wait()
+	return reportPings(results)
+}
+
+// remoteSource is a URL embedded by a markdown file.
+type remoteSource struct {
+	url string
+	// at is the location of the first command embedding it.
+	at string
+}
+
+// remoteSources returns the URLs embedded by the markdown files in paths,
+// once each and in order.
+func remoteSources(paths []string, o *options) ([]remoteSource, error) {
+	opts, err := o.embedOptions()
+	if err != nil {
+		return nil, err
+	}
+	docs, err := markdownFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	var sources []remoteSource
+	seen := map[string]bool{}
+	for _, path := range docs {
+		found, err := docSources(path, opts...)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range found {
+			if !strings.HasPrefix(s.Path, "http://") && !strings.HasPrefix(s.Path, "https://") || seen[s.Path] {
+				continue
+			}
+			seen[s.Path] = true
+			sources = append(sources, remoteSource{s.Path, fmt.Sprintf("%s:%d", filepath.ToSlash(path), s.Line)})
+		}
+	}
+	return sources, nil
+}
+
+// docSources returns the sources embedded by the document.
+func docSources(path string, opts ...embedmd.Option) ([]embedmd.Source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	found, err := embedmd.Sources(f, append([]embedmd.Option{embedmd.WithFormat(embedmd.FormatOf(path))}, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", filepath.ToSlash(path), err)
+	}
+	return found, nil
+}
+
+// markdownFiles returns the markdown files in paths, walking directories
+// except for hidden ones.
+func markdownFiles(paths []string) ([]string, error) {
+	var docs []string
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			switch {
+			case err != nil:
+				return err
+			case d.IsDir() && path != root && strings.HasPrefix(d.Name(), "."):
+				return filepath.SkipDir
+			case !d.IsDir() && isDocument(path):
+				docs = append(docs, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+// pingResult is the outcome of fetching the headers of a source.
+type pingResult struct {
+	remoteSource
+	status       string
+	dns, latency time.Duration
+	redirects    []string
+	err          error
+}
+
+// ping fetches the headers of the source, following redirects, with the
+// credential provided for its host.
+func ping(client *http.Client, creds embedmd.CredentialProvider, s remoteSource) pingResult {
+	r := pingResult{remoteSource: s}
+	req, err := http.NewRequest("GET", s.url, nil)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	if c, ok := creds.Credential(req.URL.Hostname()); ok {
+		c.Set(req.Header)
+	}
+	var dnsStart time.Time
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:  func(httptrace.DNSDoneInfo) { r.dns += time.Since(dnsStart) },
+	}))
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		r.redirects = append(r.redirects, fmt.Sprintf("%s to %s", req.Response.Status, req.URL))
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		// The headers of the first request are copied, so its credential
+		// is replaced with the one of the new host.
+		if host := via[0].URL.Hostname(); req.URL.Hostname() != host {
+			if c, ok := creds.Credential(host); ok {
+				req.Header.Del(cmp.Or(c.Header, "Authorization"))
+			}
+			if c, ok := creds.Credential(req.URL.Hostname()); ok {
+				c.Set(req.Header)
+			}
+		}
+		return nil
+	}
+
+	start := time.Now()
+	res, err := c.Do(req)
+	r.latency = time.Since(start)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	res.Body.Close()
+	r.status = res.Status
+	if res.StatusCode != http.StatusOK {
+		r.err = fmt.Errorf("status %s", res.Status)
+	}
+	return r
+}
+
+// reportPings prints the results and a summary of the latencies, returning
+// the exit code of the command.
+func reportPings(results []pingResult) int {
+	failed := 0
+	var latencies []time.Duration
+	for _, r := range results {
+		label := r.status
+		if r.err != nil {
+			failed++
+			label = "error: " + r.err.Error()
+		}
+		fmt.Fprintf(stdout, "%s (%s)\n  %s in %v, dns %v\n", r.url, r.at, label, ms(r.latency), ms(r.dns))
+		for _, redirect := range r.redirects {
+			fmt.Fprintf(stdout, "  redirected: %s\n", redirect)
+		}
+		latencies = append(latencies, r.latency)
+	}
+	if len(latencies) == 0 {
+		fmt.Fprintln(stdout, "no remote sources found")
+		return 0
+	}
+	slices.Sort(latencies)
+	fmt.Fprintf(stdout, "%d remote sources, %d failed, latency min %v, median %v, max %v\n",
+		len(results), failed, ms(latencies[0]), ms(latencies[len(latencies)/2]), ms(latencies[len(latencies)-1]))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// ms rounds d to milliseconds for display.
+func ms(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
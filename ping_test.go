@@ -0,0 +1,78 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestRunPing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok.go":
+		case "/moved.go":
+			http.Redirect(w, r, "/ok.go", http.StatusMovedPermanently)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"README.md": "[embedmd]:# (" + server.URL + "/ok.go)\n\n[embedmd]:# (local.go)\n",
+		"docs/a.md": "# A\n\n[embedmd]:# (" + server.URL + "/moved.go)\n[embedmd]:# (" + server.URL + "/ok.go)\n",
+		"docs/b.md": "[embedmd]:# (" + server.URL + "/missing.go)\n",
+		".git/c.md": "[embedmd]:# (" + server.URL + "/ignored.go)\n",
+		configFile:  "version: 1\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	var out bytes.Buffer
+	stdout, stderr = &out, &out
+	code := runPing([]string{"-config", filepath.Join(dir, configFile), dir})
+	if code != 1 {
+		t.Errorf("expected exit code 1; got %d", code)
+	}
+
+	got := regexp.MustCompile(`[0-9.]+[mµn]?s\b`).ReplaceAllString(out.String(), "T")
+	got = regexp.MustCompile(regexp.QuoteMeta(filepath.ToSlash(dir))).ReplaceAllString(got, "DIR")
+	want := server.URL + "/ok.go (DIR/README.md:1)\n" +
+		"  200 OK in T, dns T\n" +
+		server.URL + "/moved.go (DIR/docs/a.md:3)\n" +
+		"  200 OK in T, dns T\n" +
+		"  redirected: 301 Moved Permanently to " + server.URL + "/ok.go\n" +
+		server.URL + "/missing.go (DIR/docs/b.md:1)\n" +
+		"  error: status 404 Not Found in T, dns T\n" +
+		"3 remote sources, 1 failed, latency min T, median T, max T\n"
+	if got != want {
+		t.Errorf("expected output\n%s\ngot\n%s", want, got)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// verifyPins prints the commands of the markdown files, or of the standard
+// input without files, whose source no longer matches the checksum pinned
+// with their sha256 attribute, reporting whether there's any.
+func verifyPins(paths []string, opts ...embedmd.Option) (bool, error) {
+	if len(paths) == 0 {
+		return verifyDocPins("<stdin>", stdin, opts...)
+	}
+	found := false
+	for _, path := range paths {
+		b, err := readFile(path)
+		if err != nil {
+			return found, err
+		}
+		more, err := verifyDocPins(filepath.ToSlash(path), bytes.NewReader(b), append([]embedmd.Option{embedmd.WithBaseDir(filepath.Dir(path)), embedmd.WithFormat(embedmd.FormatOf(path))}, opts...)...)
+		if err != nil {
+			return found, err
+		}
+		found = found || more
+	}
+	return found, nil
+}
+
+// verifyDocPins prints the mismatches of the markdown read from in, named
+// name, reporting whether there's any.
+func verifyDocPins(name string, in io.Reader, opts ...embedmd.Option) (bool, error) {
+	mismatches, err := embedmd.VerifyPins(in, opts...)
+	if err != nil {
+		return false, fmt.Errorf("%s:%v", name, err)
+	}
+	for _, m := range mismatches {
+		fmt.Fprintf(stdout, "%s:%d: %s changed, pinned sha256 %s, got %s\n", name, m.Line, m.Path, m.Pinned, m.Got)
+	}
+	return len(mismatches) > 0, nil
+}
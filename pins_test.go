@@ -0,0 +1,54 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyPins(t *testing.T) {
+	dir := t.TempDir()
+	const zeroSum = "0000000000000000000000000000000000000000000000000000000000000000"
+	files := map[string]string{
+		"a.go": "package a\n",
+		"doc.md": "[embedmd]:# (a.go sha256=7b39baa38a2ec2b8d111bbbd8e448e80226477ab40105d9d2123d4dc18067438)\n\n" +
+			"[embedmd]:# (a.go sha256=" + zeroSum + ")\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(o io.Writer) { stdout = o }(stdout)
+	var out bytes.Buffer
+	stdout = &out
+
+	doc := filepath.Join(dir, "doc.md")
+	found, err := verifyPins([]string{doc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Errorf("expected a mismatch to be found")
+	}
+	want := filepath.ToSlash(doc) + ":3: a.go changed, pinned sha256 " + zeroSum +
+		", got 7b39baa38a2ec2b8d111bbbd8e448e80226477ab40105d9d2123d4dc18067438\n"
+	if got := out.String(); got != want {
+		t.Errorf("expected output\n%s\ngot\n%s", want, got)
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// A plan records the changes that rewriting a set of files would make, so
+// they can be reviewed and applied later, possibly on another machine with
+// the same checkout.
+type plan struct {
+	Version int           `json:"version"`
+	Files   []plannedFile `json:"files"`
+}
+
+// planVersion is the version of the plan format.
+const planVersion = 1
+
+// plannedFile is the change planned for a file.
+type plannedFile struct {
+	Path string `json:"path"`
+	// Base is the hash of the content the change was planned from.
+	Base    string `json:"base"`
+	Content string `json:"content"`
+	Diff    string `json:"diff"`
+}
+
+// writePlan writes to out, or the standard output if out is -, the plan
+// rewriting the given files. Files that wouldn't change are left out. The
+// files are planned by the given workers if any, or locally otherwise.
+func writePlan(out string, paths, workers []string, opts ...embedmd.Option) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("error: cannot use -plan with standard input")
+	}
+	paths, err := localPaths(paths)
+	if err != nil {
+		return err
+	}
+	var planned []*plannedFile
+	if len(workers) > 0 {
+		token := os.Getenv(workerTokenEnv)
+		if token == "" {
+			return fmt.Errorf("error: set the token shared with the workers in %s", workerTokenEnv)
+		}
+		planned, err = planOnWorkers(paths, workers, token)
+	} else {
+		planned, err = planFiles(paths, opts...)
+	}
+	if err != nil {
+		return err
+	}
+	p := plan{Version: planVersion, Files: []plannedFile{}}
+	for _, f := range planned {
+		if f != nil {
+			p.Files = append(p.Files, *f)
+		}
+	}
+
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if out == "-" {
+		_, err = stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(out, b, 0666)
+}
+
+// localPaths returns the paths relative to the current directory, as the
+// plan is applied from the same directory, failing for those outside of it.
+func localPaths(paths []string) ([]string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	local := make([]string, len(paths))
+	for i, path := range paths {
+		if local[i], err = filepath.Rel(wd, path); !filepath.IsAbs(path) {
+			local[i], err = filepath.Clean(path), nil
+		}
+		if err != nil || !filepath.IsLocal(local[i]) {
+			return nil, fmt.Errorf("%s: cannot plan files outside of the current directory", filepath.ToSlash(path))
+		}
+	}
+	return local, nil
+}
+
+// planFiles returns the changes planned for the given files, nil for those
+// that wouldn't change.
+func planFiles(paths []string, opts ...embedmd.Option) ([]*plannedFile, error) {
+	planned := make([]*plannedFile, len(paths))
+	for i, path := range paths {
+		if interrupted.Load() {
+			return nil, &interruptedError{done: paths[:i], pending: paths[i:]}
+		}
+		f, err := planFile(path, opts...)
+		if err != nil && interrupted.Load() {
+			return nil, &interruptedError{done: paths[:i], pending: paths[i:]}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%v", filepath.ToSlash(path), err)
+		}
+		planned[i] = f
+	}
+	return planned, nil
+}
+
+// planFile returns the change planned for the file, or nil if it wouldn't
+// change.
+func planFile(path string, opts ...embedmd.Option) (*plannedFile, error) {
+	if !isDocument(path) {
+		return nil, fmt.Errorf("not a markdown, reStructuredText, AsciiDoc, or notebook file")
+	}
+	in, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]embedmd.Option{embedmd.WithBaseDir(filepath.Dir(path)), warnings(path)}, opts...)
+	return planContent(path, in, opts...)
+}
+
+// planContent returns the change planned for the file at path, whose content
+// is in, or nil if it wouldn't change.
+func planContent(path string, in []byte, opts ...embedmd.Option) (*plannedFile, error) {
+	opts = append([]embedmd.Option{embedmd.WithFormat(embedmd.FormatOf(path))}, opts...)
+	var out bytes.Buffer
+	if err := embedmd.Process(&out, bytes.NewReader(in), opts...); err != nil {
+		return nil, err
+	}
+	if bytes.Equal(in, out.Bytes()) {
+		return nil, nil
+	}
+	name := filepath.ToSlash(path)
+	d, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(in)),
+		B:        difflib.SplitLines(out.String()),
+		FromFile: "a/" + name,
+		ToFile:   "b/" + name,
+		Context:  3,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &plannedFile{Path: name, Base: hashBytes(in), Content: out.String(), Diff: d}, nil
+}
+
+// applyPlan rewrites the files as recorded in the plan at the given path.
+// Nothing is written unless every file is still as it was when planned.
+func applyPlan(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read plan: %v", err)
+	}
+	var p plan
+	if err := json.Unmarshal(b, &p); err != nil {
+		return fmt.Errorf("%s: bad plan: %v", path, err)
+	}
+	if p.Version != planVersion {
+		return fmt.Errorf("%s: unsupported plan version %d", path, p.Version)
+	}
+
+	for _, f := range p.Files {
+		// Plans may come from elsewhere, so they only rewrite documents
+		// under the current directory.
+		if name := filepath.FromSlash(f.Path); !filepath.IsLocal(name) || !isDocument(name) {
+			return fmt.Errorf("%s: bad plan: %s is not a document under the current directory", path, f.Path)
+		}
+	}
+	for _, f := range p.Files {
+		b, err := readFile(filepath.FromSlash(f.Path))
+		if err != nil {
+			return fmt.Errorf("%s:%v", f.Path, err)
+		}
+		if hashBytes(b) != f.Base {
+			return fmt.Errorf("%s: changed since the plan was made, plan again", f.Path)
+		}
+	}
+	for i, f := range p.Files {
+		if interrupted.Load() {
+			return &interruptedError{done: planPaths(p.Files[:i]), pending: planPaths(p.Files[i:])}
+		}
+		if err := rewriteFile(filepath.FromSlash(f.Path), []byte(f.Content)); err != nil {
+			return fmt.Errorf("%s:%v", f.Path, err)
+		}
+	}
+	return nil
+}
+
+func planPaths(files []plannedFile) []string {
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.Path
+	}
+	return paths
+}
+
+// rewriteFile replaces the content of the file at path with b.
+func rewriteFile(path string, b []byte) error {
+	f, err := openFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return overwrite(path, f, b)
+}
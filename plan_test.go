@@ -0,0 +1,175 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlanAndApply(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("code.go", "package main\n")
+	doc := write("doc.md", "# Doc\n[embedmd]:# (code.go)\n")
+	write("same.md", "# Nothing to embed\n")
+	planPath := filepath.Join(dir, "plan.json")
+
+	// Plans record the paths relative to the current directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePlan(planPath, []string{"doc.md", "same.md"}, nil); err != nil {
+		t.Fatalf("could not plan: %v", err)
+	}
+	b, err := os.ReadFile(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "# Doc\n[embedmd]:# (code.go)\n" {
+		t.Errorf("planning rewrote %s:\n%s", doc, b)
+	}
+
+	b, err = os.ReadFile(planPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p plan
+	if err := json.Unmarshal(b, &p); err != nil {
+		t.Fatalf("bad plan: %v", err)
+	}
+	if len(p.Files) != 1 || p.Files[0].Path != "doc.md" {
+		t.Fatalf("expected a plan for %s only; got %+v", doc, p.Files)
+	}
+	if d := p.Files[0].Diff; !strings.Contains(d, "+package main\n") || !strings.Contains(d, "+++ b/") {
+		t.Errorf("expected the diff adding the code; got\n%s", d)
+	}
+
+	if err := applyPlan(planPath); err != nil {
+		t.Fatalf("could not apply: %v", err)
+	}
+	b, err = os.ReadFile(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Doc\n[embedmd]:# (code.go)\n```go\npackage main\n```\n"
+	if string(b) != want {
+		t.Errorf("expected applied content\n%s\ngot\n%s", want, b)
+	}
+
+	// The file changed, so applying the plan again is rejected.
+	err = applyPlan(planPath)
+	eqErr(t, "stale plan", err, "doc.md: changed since the plan was made, plan again")
+
+	err = writePlan(planPath, []string{filepath.Join(t.TempDir(), "other.md")}, nil)
+	if err == nil || !strings.HasSuffix(err.Error(), "other.md: cannot plan files outside of the current directory") {
+		t.Errorf("expected an error planning a file outside of the current directory; got %v", err)
+	}
+}
+
+func TestApplyPlanErrors(t *testing.T) {
+	dir := t.TempDir()
+	tc := []struct {
+		name, plan, err string
+	}{
+		{name: "not JSON", plan: "files:", err: "bad plan: invalid character 'i' in literal false (expecting 'a')"},
+		{name: "unsupported version", plan: `{"version": 2}`, err: "unsupported plan version 2"},
+		{name: "parent path", plan: `{"version": 1, "files": [{"path": "../doc.md"}]}`, err: "bad plan: ../doc.md is not a document under the current directory"},
+		{name: "absolute path", plan: `{"version": 1, "files": [{"path": "/etc/doc.md"}]}`, err: "bad plan: /etc/doc.md is not a document under the current directory"},
+		{name: "not a document", plan: `{"version": 1, "files": [{"path": "main.go"}]}`, err: "bad plan: main.go is not a document under the current directory"},
+	}
+	for _, tt := range tc {
+		path := filepath.Join(dir, "plan.json")
+		if err := os.WriteFile(path, []byte(tt.plan), 0644); err != nil {
+			t.Fatal(err)
+		}
+		eqErr(t, tt.name, applyPlan(path), path+": "+tt.err)
+	}
+}
+
+func TestCheckModes(t *testing.T) {
+	tc := []struct {
+		name string
+		o    options
+		args []string
+		// sourceMaps sets -source-map, interactive -i, and keepGoing
+		// -keep-going with -transactional.
+		sourceMaps, interactive, keepGoing bool
+		err                                string
+	}{
+		{name: "plan", o: options{planPath: "p.json"}, args: []string{"a.md"}},
+		{name: "apply", o: options{applyPath: "p.json"}},
+		{name: "plan and rewrite", o: options{planPath: "p.json", rewrite: true}, err: "error: cannot use -plan with -w or -d"},
+		{name: "apply and plan", o: options{planPath: "p.json", applyPath: "p.json"}, err: "error: cannot use -apply with -w, -d, or -plan"},
+		{name: "report", o: options{reportHTML: "r.html"}, args: []string{"a.md"}},
+		{name: "report and rewrite", o: options{reportHTML: "r.html", rewrite: true}, args: []string{"a.md"}, err: "error: cannot use -report-html with -w, -d, -plan, or -apply"},
+		{name: "report of stdin", o: options{reportHTML: "r.html"}, err: "error: cannot use -report-html with standard input"},
+		{name: "suggest commit without rewriting", o: options{suggestCommit: true}, args: []string{"a.md"}, err: "error: -suggest-commit can only be used with -w"},
+		{name: "by owner without diff", o: options{byOwner: true}, args: []string{"a.md"}, err: "error: -by-owner can only be used with -d"},
+		{name: "notify without diff", o: options{notify: "https://hooks.example.com"}, args: []string{"a.md"}, err: "error: -notify can only be used with -d"},
+		{name: "check and rewrite", o: options{check: true, doDiff: true, rewrite: true}, args: []string{"a.md"}, err: "error: cannot use -check with -w"},
+		{name: "source map without rewriting", o: options{doDiff: true}, args: []string{"a.md"}, sourceMaps: true, err: "error: -source-map can only be used with -w on files, without -transactional or -strip"},
+		{name: "source map", o: options{rewrite: true}, args: []string{"a.md"}, sourceMaps: true},
+		{name: "summary without diff", o: options{summary: true}, args: []string{"a.md"}, err: "error: -summary can only be used with -d, without -report-json, -by-owner, -file-issues, or -word-diff"},
+		{name: "summary", o: options{summary: true, doDiff: true}, args: []string{"a.md"}},
+		{name: "versions out on stdin", o: options{versionsOut: "site"}, err: "error: -versions-out can only be used on files, without -w, -d, -plan, or -apply"},
+		{name: "versions out", o: options{versionsOut: "site"}, args: []string{"a.md"}},
+		{name: "verify and rewrite", o: options{verify: true, rewrite: true}, args: []string{"a.md"}, err: "error: cannot use -verify with -w, -d, -pin, or -plan"},
+		{name: "verify", o: options{verify: true}, args: []string{"a.md"}},
+		{name: "lint and rewrite", o: options{lint: true, rewrite: true}, args: []string{"a.md"}, err: "error: cannot use -lint with -w, -d, -verify, -plan, -apply, or -strip"},
+		{name: "lint", o: options{lint: true, lintFormat: "json"}, args: []string{"a.md"}},
+		{name: "stdin", o: options{stdin: true, doDiff: true}},
+		{name: "stdin with files", o: options{stdin: true}, args: []string{"a.md"}, err: "error: -stdin can only be used without files, -w, -staged, -watch, -plan, or -apply"},
+		{name: "stdin and rewrite", o: options{stdin: true, rewrite: true}, err: "error: -stdin can only be used without files, -w, -staged, -watch, -plan, or -apply"},
+		{name: "bad lint format", o: options{lint: true, lintFormat: "xml"}, args: []string{"a.md"}, err: `error: bad -lint-format "xml", should be text or json`},
+		{name: "quarantine days without quarantine", o: options{quarantineDays: 30}, args: []string{"a.md"}, err: "error: -quarantine-days can only be a positive number of days, used with -quarantine"},
+		{name: "lang confidence out of range", o: options{langConfidence: 1.5}, args: []string{"a.md"}, err: "error: bad -lang-confidence 1.5, should be between 0 and 1"},
+		{name: "extract cache with source archive", o: options{extractCache: true, sourceArchive: "src.zip"}, args: []string{"a.md"}, err: "error: -extract-cache can only be used without -source-archive, as it tells unchanged files from the disk"},
+		{name: "dump state", o: options{dumpState: "state.json"}, args: []string{"a.md"}},
+		{name: "dump state and rewrite", o: options{dumpState: "state.json", rewrite: true}, args: []string{"a.md"}, err: "error: -dump-state can only be used without -w, -d, -stdin, -watch, -plan, or -apply"},
+		{name: "archive from stdin", o: options{sourceArchive: "-"}, err: "error: -source-archive - can only be used on files, as the markdown is read from stdin otherwise"},
+		{name: "archive from stdin on files", o: options{sourceArchive: "-"}, args: []string{"a.md"}},
+		{name: "copy without prompts alone", o: options{copyWithoutPrompts: true}, args: []string{"a.md"}, err: "error: -copy-without-prompts can only be used with -copy-buttons"},
+		{name: "edit ref without edit links", o: options{editRef: "main"}, args: []string{"a.md"}, err: "error: -edit-ref can only be used with -edit-links"},
+		{name: "apply with files", o: options{applyPath: "p.json"}, args: []string{"a.md"}, err: "error: -apply takes no files, they are listed in the plan"},
+		{name: "interactive with managed blocks", o: options{rewrite: true, managed: true}, args: []string{"a.md"}, interactive: true},
+		{name: "keep going transactionally", o: options{rewrite: true}, args: []string{"a.md", "b.md"}, keepGoing: true, err: "error: -keep-going can only be used without -transactional, which rewrites nothing when a file fails"},
+		{name: "interactive without checksums", o: options{rewrite: true}, args: []string{"a.md"}, interactive: true, err: "error: -i can only be used with -w and -checksums or -managed on files, without -transactional or -source-archive -"},
+		{name: "interactive", o: options{rewrite: true, checksums: true}, args: []string{"a.md"}, interactive: true},
+		{name: "suggest patches without checksums", o: options{suggestPatches: "fix.patch"}, args: []string{"a.md"}, err: "error: -suggest-patches can only be used with -checksums on files, without -plan or -apply"},
+		{name: "suggest patches", o: options{suggestPatches: "fix.patch", checksums: true, doDiff: true}, args: []string{"a.md"}},
+		{name: "frozen without lock file", o: options{frozen: true}, args: []string{"a.md"}, err: "error: -frozen can only be used with -lockfile"},
+		{name: "frozen", o: options{frozen: true, lockPath: "embedmd.lock"}, args: []string{"a.md"}},
+	}
+	defer func() { sourceMaps, interactive, keepGoing, transactional = false, false, false, false }()
+	for _, tt := range tc {
+		sourceMaps, interactive = tt.sourceMaps, tt.interactive
+		keepGoing, transactional = tt.keepGoing, tt.keepGoing
+		eqErr(t, tt.name, checkModes(&tt.o, tt.args), tt.err)
+	}
+}
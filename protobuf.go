@@ -0,0 +1,131 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The messages of the gRPC service, described in proto/embedmd.proto, are
+// encoded by hand, as embedmd doesn't depend on the protobuf runtime. Fields
+// are written with the wire types of their proto3 types, and the fields of
+// other types, or unknown ones, are skipped when read.
+
+// The wire types of protobuf fields.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// protoMessage appends the fields of a message. Fields holding the default
+// value of their type are left out, as in proto3.
+type protoMessage []byte
+
+func (m *protoMessage) tag(field, wire int) {
+	*m = binary.AppendUvarint(*m, uint64(field)<<3|uint64(wire))
+}
+
+func (m *protoMessage) int32(field, v int) {
+	if v != 0 {
+		m.tag(field, protoVarint)
+		// Negative numbers are sign extended to 64 bits.
+		*m = binary.AppendUvarint(*m, uint64(int64(int32(v))))
+	}
+}
+
+func (m *protoMessage) bool(field int, v bool) {
+	if v {
+		m.tag(field, protoVarint)
+		*m = append(*m, 1)
+	}
+}
+
+func (m *protoMessage) bytes(field int, v []byte) {
+	if len(v) > 0 {
+		m.embedded(field, v)
+	}
+}
+
+func (m *protoMessage) string(field int, v string) {
+	m.bytes(field, []byte(v))
+}
+
+// embedded appends the encoded message v, even if empty, as an element of a
+// repeated field.
+func (m *protoMessage) embedded(field int, v []byte) {
+	m.tag(field, protoBytes)
+	*m = binary.AppendUvarint(*m, uint64(len(v)))
+	*m = append(*m, v...)
+}
+
+// packed appends the numbers of a repeated int32 field.
+func (m *protoMessage) packed(field int, vs []int) {
+	if len(vs) == 0 {
+		return
+	}
+	var p []byte
+	for _, v := range vs {
+		p = binary.AppendUvarint(p, uint64(int64(int32(v))))
+	}
+	m.embedded(field, p)
+}
+
+// protoFields calls f with the number, the wire type, and the value of each
+// field of the message b: the number of varints, and the content of
+// length-delimited fields. Fixed size fields are skipped.
+func protoFields(b []byte, f func(field, wire int, n uint64, data []byte) error) error {
+	for len(b) > 0 {
+		tag, i := binary.Uvarint(b)
+		if i <= 0 {
+			return errors.New("bad field tag")
+		}
+		b = b[i:]
+		field, wire := int(tag>>3), int(tag&7)
+		var n uint64
+		var data []byte
+		switch wire {
+		case protoVarint:
+			if n, i = binary.Uvarint(b); i <= 0 {
+				return fmt.Errorf("bad varint in field %d", field)
+			}
+			b = b[i:]
+		case protoBytes:
+			l, i := binary.Uvarint(b)
+			if i <= 0 || l > uint64(len(b)-i) {
+				return fmt.Errorf("bad length of field %d", field)
+			}
+			data, b = b[i:i+int(l)], b[i+int(l):]
+		case protoFixed64, protoFixed32:
+			size := 8
+			if wire == protoFixed32 {
+				size = 4
+			}
+			if len(b) < size {
+				return fmt.Errorf("truncated field %d", field)
+			}
+			b = b[size:]
+			continue
+		default:
+			return fmt.Errorf("unsupported wire type %d of field %d", wire, field)
+		}
+		if err := f(field, wire, n, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
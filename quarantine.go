@@ -0,0 +1,128 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// quarantineList holds the embeds quarantined with -quarantine, if set.
+var quarantineList *quarantine
+
+// A quarantine lists known-broken embeds, by their file and the fingerprint
+// of their command, whose failures are reported as warnings until their
+// expiry date, so checks can be adopted on docs that don't pass them yet.
+type quarantine struct {
+	path string
+	// today is the date the entries expire against.
+	today string
+	// addDays, if set, quarantines the failing embeds not listed yet, for
+	// this many days.
+	addDays int
+
+	mu      sync.Mutex
+	entries []quarantineEntry
+	added   bool
+}
+
+type quarantineEntry struct {
+	File        string `json:"file"`
+	Fingerprint string `json:"fingerprint"`
+	// Expires is the last day the embed is quarantined, as 2006-01-02.
+	Expires string `json:"expires"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type quarantineFile struct {
+	Version int               `json:"version"`
+	Embeds  []quarantineEntry `json:"embeds"`
+}
+
+// quarantineVersion is the version of the quarantine file format.
+const quarantineVersion = 1
+
+// loadQuarantine reads the quarantine file at path, which must exist unless
+// embeds are added to it, warning about the entries expired at now.
+func loadQuarantine(path string, addDays int, now time.Time) (*quarantine, error) {
+	q := &quarantine{path: path, today: now.UTC().Format(time.DateOnly), addDays: addDays}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && addDays > 0 {
+		return q, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error: -quarantine: %v", err)
+	}
+	var f quarantineFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("error: %s: %v", path, err)
+	}
+	if f.Version != quarantineVersion {
+		return nil, fmt.Errorf("error: %s: unsupported version %d, should be %d", path, f.Version, quarantineVersion)
+	}
+	for _, e := range f.Embeds {
+		if _, err := time.Parse(time.DateOnly, e.Expires); err != nil || e.File == "" || e.Fingerprint == "" {
+			return nil, fmt.Errorf("error: %s: embeds need a file, a fingerprint, and an expiry date as 2006-01-02, got %+v", path, e)
+		}
+		if e.Expires < q.today {
+			fmt.Fprintf(stderr, "warning: %s: the quarantine of %s %s expired on %s, its failures are errors again\n", path, e.File, e.Fingerprint, e.Expires)
+		}
+	}
+	q.entries = f.Embeds
+	return q, nil
+}
+
+// option returns the option quarantining the embeds of the file at path.
+func (q *quarantine) option(path string) embedmd.Option {
+	file := filepath.ToSlash(filepath.Clean(path))
+	return embedmd.WithQuarantine(func(fp string) bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		for _, e := range q.entries {
+			if e.File == file && e.Fingerprint == fp {
+				return e.Expires >= q.today
+			}
+		}
+		if q.addDays == 0 {
+			return false
+		}
+		today, _ := time.Parse(time.DateOnly, q.today)
+		q.entries = append(q.entries, quarantineEntry{File: file, Fingerprint: fp, Expires: today.AddDate(0, 0, q.addDays).Format(time.DateOnly)})
+		q.added = true
+		return true
+	})
+}
+
+// write writes the quarantine file back if embeds were added to it, sorted
+// by file.
+func (q *quarantine) write() error {
+	if !q.added {
+		return nil
+	}
+	slices.SortStableFunc(q.entries, func(a, b quarantineEntry) int { return strings.Compare(a.File, b.File) })
+	b, err := json.MarshalIndent(quarantineFile{quarantineVersion, q.entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(q.path, append(b, '\n'), 0666)
+}
@@ -0,0 +1,140 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+func TestQuarantine(t *testing.T) {
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	defer func() { quarantineList = nil }()
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("code.go", "package main\n")
+	write("a.md", "[embedmd]:# (code.go)\n```go\nold\n```\n\n[embedmd]:# (missing.go)\n")
+	write("b.md", "[embedmd]:# (missing.go)\n")
+	now := time.Date(2026, 10, 15, 13, 0, 0, 0, time.UTC)
+	check := func() (bool, error) {
+		stdout = io.Discard
+		return embed([]string{"a.md", "b.md"}, false, true, embedmd.WithMaxAgeErrors(), embedmd.WithCheck())
+	}
+
+	var errs bytes.Buffer
+	stderr = &errs
+	if _, err := check(); err == nil {
+		t.Fatalf("expected the check to fail without quarantine")
+	}
+
+	// The embeds failing are added to the quarantine.
+	var err error
+	if quarantineList, err = loadQuarantine("quarantine.json", 30, now); err != nil {
+		t.Fatal(err)
+	}
+	if diff, err := check(); diff || err != nil {
+		t.Fatalf("expected the failing embeds to be quarantined; got %v, %v", diff, err)
+	}
+	if err := quarantineList.write(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile("quarantine.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fingerprints of (code.go) and (missing.go).
+	const code, missing = "2df4b33950a2", "4f80b57df799"
+	want := `{
+  "version": 1,
+  "embeds": [
+    {
+      "file": "a.md",
+      "fingerprint": "` + code + `",
+      "expires": "2026-11-14"
+    },
+    {
+      "file": "a.md",
+      "fingerprint": "` + missing + `",
+      "expires": "2026-11-14"
+    },
+    {
+      "file": "b.md",
+      "fingerprint": "` + missing + `",
+      "expires": "2026-11-14"
+    }
+  ]
+}
+`
+	if string(b) != want {
+		t.Errorf("expected quarantine\n%s\ngot\n%s", want, b)
+	}
+	if !strings.Contains(errs.String(), "a.md:1: warning: block is stale, quarantined\n") ||
+		!strings.Contains(errs.String(), "b.md:1: warning: could not read missing.go: open missing.go: no such file or directory, quarantined\n") {
+		t.Errorf("expected quarantine warnings; got\n%s", errs.String())
+	}
+
+	// Once expired, failures are errors again.
+	errs.Reset()
+	if quarantineList, err = loadQuarantine("quarantine.json", 0, now.AddDate(0, 0, 31)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := check(); err == nil {
+		t.Errorf("expected the check to fail once the quarantine expired")
+	}
+	if !strings.Contains(errs.String(), "warning: quarantine.json: the quarantine of b.md "+missing+" expired on 2026-11-14, its failures are errors again\n") {
+		t.Errorf("expected expiry warnings; got\n%s", errs.String())
+	}
+}
+
+func TestLoadQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 15, 13, 0, 0, 0, time.UTC)
+	tc := []struct {
+		name, content, err string
+	}{
+		{name: "missing", err: "error: -quarantine: open "},
+		{name: "bad version", content: `{"version": 2}`, err: "unsupported version 2, should be 1"},
+		{name: "no expiry date", content: `{"version": 1, "embeds": [{"file": "a.md", "fingerprint": "3f2a9c1b7d4e"}]}`,
+			err: "embeds need a file, a fingerprint, and an expiry date as 2006-01-02, got {File:a.md Fingerprint:3f2a9c1b7d4e Expires: Reason:}"},
+	}
+	for _, tt := range tc {
+		path := filepath.Join(dir, tt.name+".json")
+		if tt.content != "" {
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err := loadQuarantine(path, 0, now)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("case [%s]: expected error containing %q; got %v", tt.name, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// rateBudget keeps the requests of all the fetchers of the run within the
+// rate limits announced by the hosts.
+var rateBudget = &embedmd.RateBudget{}
+
+// quota describes the requests left by the rate limit l, as in "13 of 60
+// requests to api.github.com left until 15:04:05".
+func quota(l embedmd.RateLimit) string {
+	if l.Limit == 0 {
+		return fmt.Sprintf("%d requests to %s left until %s", l.Remaining, l.Host, l.Reset.Format(time.TimeOnly))
+	}
+	return fmt.Sprintf("%d of %d requests to %s left until %s", l.Remaining, l.Limit, l.Host, l.Reset.Format(time.TimeOnly))
+}
+
+// warnQuotas warns about the hosts with less than a tenth of their rate
+// limit left, as the next runs may wait or fail.
+func warnQuotas(w io.Writer, limits []embedmd.RateLimit) {
+	for _, l := range limits {
+		if l.Remaining*10 < l.Limit || l.Limit == 0 && l.Remaining == 0 {
+			fmt.Fprintf(w, "warning: only %s\n", quota(l))
+		}
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// runRender implements the render command, converting the given markdown
+// files, with the code their commands embed today, to man pages or wrapped
+// plain text.
+func runRender(args []string) int {
+	fs := flag.NewFlagSet("embedmd render", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd render -format man|text [flags] path ...\n")
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "", "format of the output: man for troff man pages, or text for plain text")
+	section := fs.String("section", "1", "with -format man, section of the manual the pages belong to")
+	width := fs.Int("width", 80, "with -format text, column text is wrapped at")
+	outDir := fs.String("out-dir", "", "directory the output is written to, named after each file with the section or .txt as extension, instead of the standard output")
+	o := newCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := setup(fs, o); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	switch {
+	case *format != "man" && *format != "text":
+		fmt.Fprintf(stderr, "error: bad -format %q, should be man or text\n", *format)
+		return 2
+	case *width < 20:
+		fmt.Fprintln(stderr, "error: -width should be at least 20")
+		return 2
+	case fs.NArg() == 0:
+		fs.Usage()
+		return 2
+	}
+	opts, err := o.embedOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	failed := false
+	for _, doc := range fs.Args() {
+		out, err := renderDoc(doc, *format, *section, *width, opts...)
+		if err == nil && *outDir != "" {
+			ext := "." + *section
+			if *format == "text" {
+				ext = ".txt"
+			}
+			name := strings.TrimSuffix(filepath.Base(doc), filepath.Ext(doc)) + ext
+			err = os.WriteFile(filepath.Join(*outDir, name), out, 0644)
+		} else if err == nil {
+			_, err = stdout.Write(out)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", filepath.ToSlash(doc), err)
+			failed = true
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// renderDoc processes the markdown file doc and converts it to format.
+func renderDoc(doc, format, section string, width int, opts ...embedmd.Option) ([]byte, error) {
+	in, err := os.ReadFile(doc)
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]embedmd.Option{embedmd.WithBaseDir(filepath.Dir(doc)), warnings(doc)}, opts...)
+	var out bytes.Buffer
+	if err := embedmd.Process(&out, bytes.NewReader(in), opts...); err != nil {
+		return nil, err
+	}
+	if format == "man" {
+		title, blocks := parseBlocks(out.String())
+		if title == "" {
+			title = strings.TrimSuffix(filepath.Base(doc), filepath.Ext(doc))
+		}
+		return []byte(manPage(title, section, blocks)), nil
+	}
+	_, blocks := parseBlocksTitled(out.String(), false)
+	return []byte(plainText(blocks, width)), nil
+}
+
+// manPage returns the troff source of the man page titled title, whose
+// first word names the page, in the given section of the manual. Titles such
+// as "name - description" give the NAME section of the page.
+func manPage(title, section string, blocks []mdBlock) string {
+	var b strings.Builder
+	// Titles such as "embedmd(1) - embed code" name the page embedmd.
+	name := strings.TrimSpace(plainInline(title))
+	if i := strings.IndexAny(name, " ("); i > 0 {
+		name = name[:i]
+	}
+	fmt.Fprintf(&b, ".TH %s %s\n", manEscape(strings.ToUpper(name)), manEscape(section))
+	if _, desc, ok := strings.Cut(plainInline(title), " - "); ok {
+		fmt.Fprintf(&b, ".SH NAME\n%s \\- %s\n", manEscape(name), manEscape(strings.TrimSpace(desc)))
+	}
+	writeMan(&b, blocks)
+	return b.String()
+}
+
+// writeMan writes the blocks as troff with the macros of man pages.
+func writeMan(b *strings.Builder, blocks []mdBlock) {
+	n := 0
+	for _, bl := range blocks {
+		if bl.kind != mdNumbered {
+			n = 0
+		}
+		switch bl.kind {
+		case mdParagraph:
+			fmt.Fprintf(b, ".PP\n%s\n", manLine(manInline(bl.text)))
+		case mdHeading:
+			// Sections are in capitals, by convention, without formatting.
+			if bl.level <= 2 {
+				fmt.Fprintf(b, ".SH %s\n", manEscape(strings.ToUpper(plainInline(bl.text))))
+			} else {
+				fmt.Fprintf(b, ".SS %s\n", manInline(bl.text))
+			}
+		case mdBulleted, mdNumbered:
+			if bl.kind == mdBulleted {
+				b.WriteString(`.IP \(bu 2` + "\n")
+			} else {
+				n++
+				fmt.Fprintf(b, ".IP %d. 4\n", n)
+			}
+			fmt.Fprintf(b, "%s\n", manLine(manInline(bl.text)))
+			if len(bl.children) > 0 {
+				b.WriteString(".RS\n")
+				writeMan(b, bl.children)
+				b.WriteString(".RE\n")
+			}
+		case mdQuote:
+			b.WriteString(".RS 4\n")
+			writeMan(b, bl.children)
+			b.WriteString(".RE\n")
+		case mdRule:
+			b.WriteString(".sp\n")
+		case mdCode:
+			b.WriteString(".PP\n.RS 4\n.nf\n")
+			for _, l := range strings.Split(bl.code, "\n") {
+				fmt.Fprintf(b, "%s\n", manLine(manEscape(l)))
+			}
+			b.WriteString(".fi\n.RE\n")
+		}
+	}
+}
+
+// manEscape escapes the characters troff would interpret in text.
+func manEscape(s string) string {
+	return strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+}
+
+// manLine keeps lines starting with a control character from being read as
+// requests.
+func manLine(s string) string {
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		return `\&` + s
+	}
+	return s
+}
+
+// manInline converts inline markdown to troff, with bold code and strong
+// text and italic emphasis, and the URLs of links after their text.
+func manInline(s string) string {
+	var b strings.Builder
+	for _, sp := range parseInline(s) {
+		text := manEscape(sp.text)
+		switch {
+		case sp.code || sp.strong:
+			text = `\fB` + text + `\fR`
+		case sp.emphasis:
+			text = `\fI` + text + `\fR`
+		}
+		b.WriteString(text)
+		if sp.link != "" && sp.link != sp.text {
+			fmt.Fprintf(&b, " <%s>", manEscape(sp.link))
+		}
+	}
+	return b.String()
+}
+
+// plainInline returns the text of inline markdown, without formatting, and
+// the URLs of links after their text.
+func plainInline(s string) string {
+	var b strings.Builder
+	for _, sp := range parseInline(s) {
+		b.WriteString(sp.text)
+		if sp.link != "" && sp.link != sp.text {
+			fmt.Fprintf(&b, " <%s>", sp.link)
+		}
+	}
+	return b.String()
+}
+
+// plainText returns the blocks as plain text, wrapped at width columns.
+// Headings are underlined and code is indented, not wrapped.
+func plainText(blocks []mdBlock, width int) string {
+	var b strings.Builder
+	writePlain(&b, blocks, "", width)
+	return b.String()
+}
+
+// writePlain writes the blocks as plain text, each line starting with
+// prefix.
+func writePlain(b *strings.Builder, blocks []mdBlock, prefix string, width int) {
+	n := 0
+	for i, bl := range blocks {
+		if bl.kind != mdNumbered {
+			n = 0
+		}
+		// Blocks are separated by blank lines, except items of the same list.
+		if i > 0 && !(bl.kind == blocks[i-1].kind && (bl.kind == mdBulleted || bl.kind == mdNumbered)) {
+			b.WriteString(strings.TrimRight(prefix, " ") + "\n")
+		}
+		switch bl.kind {
+		case mdParagraph:
+			writeWrapped(b, plainInline(bl.text), prefix, prefix, width)
+		case mdHeading:
+			text := plainInline(bl.text)
+			fmt.Fprintf(b, "%s%s\n", prefix, text)
+			if bl.level <= 2 {
+				fmt.Fprintf(b, "%s%s\n", prefix, strings.Repeat(map[int]string{1: "=", 2: "-"}[bl.level], displayWidth(text)))
+			}
+		case mdBulleted, mdNumbered:
+			marker := "* "
+			if bl.kind == mdNumbered {
+				n++
+				marker = strconv.Itoa(n) + ". "
+			}
+			indent := prefix + strings.Repeat(" ", len(marker))
+			writeWrapped(b, plainInline(bl.text), prefix+marker, indent, width)
+			for _, c := range bl.children {
+				b.WriteString(strings.TrimRight(indent, " ") + "\n")
+				writePlain(b, []mdBlock{c}, indent, width)
+			}
+		case mdQuote:
+			writePlain(b, bl.children, prefix+"> ", width)
+		case mdRule:
+			fmt.Fprintf(b, "%s%s\n", prefix, strings.Repeat("-", max(width-displayWidth(prefix), 3)))
+		case mdCode:
+			for _, l := range strings.Split(bl.code, "\n") {
+				fmt.Fprintf(b, "%s\n", strings.TrimRight(prefix+"    "+l, " \t"))
+			}
+		}
+	}
+}
+
+// writeWrapped writes the words of text in lines of at most width columns,
+// the first starting with first and the others with rest. Words longer than
+// a line are written alone.
+func writeWrapped(b *strings.Builder, text, first, rest string, width int) {
+	line, col := first, displayWidth(first)
+	empty := true
+	for _, w := range strings.Fields(text) {
+		ww := displayWidth(w)
+		if !empty && col+1+ww > width {
+			fmt.Fprintf(b, "%s\n", line)
+			line, col, empty = rest, displayWidth(rest), true
+		}
+		if !empty {
+			line, col = line+" ", col+1
+		}
+		line, col, empty = line+w, col+ww, false
+	}
+	fmt.Fprintf(b, "%s\n", strings.TrimRight(line, " "))
+}
+
+// displayWidth returns the number of columns s takes in a terminal.
+func displayWidth(s string) int {
+	n := 0
+	for _, g := range graphemes(s) {
+		n++
+		if isWide(g) {
+			n++
+		}
+	}
+	return n
+}
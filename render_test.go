@@ -0,0 +1,99 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const renderDocument = "# tool(1) - do things\n\n## Synopsis\n\n`tool -v` runs **all** the *commands*, see [the docs](https://x.dev).\n\n" +
+	"1. A first item long enough to be wrapped.\n2. Build:\n   ```sh\n   .configure\n   ```\n\n> quoted\n\n---\n"
+
+func TestManPage(t *testing.T) {
+	title, blocks := parseBlocks(renderDocument)
+	got := manPage(title, "1", blocks)
+	want := ".TH TOOL 1\n.SH NAME\ntool \\- do things\n.SH SYNOPSIS\n.PP\n" +
+		"\\fBtool \\-v\\fR runs \\fBall\\fR the \\fIcommands\\fR, see the docs <https://x.dev>.\n" +
+		".IP 1. 4\nA first item long enough to be wrapped.\n.IP 2. 4\nBuild:\n.RS\n.PP\n.RS 4\n.nf\n\\&.configure\n.fi\n.RE\n.RE\n" +
+		".RS 4\n.PP\nquoted\n.RE\n.sp\n"
+	if got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	_, blocks := parseBlocksTitled(renderDocument, false)
+	got := plainText(blocks, 30)
+	want := "tool(1) - do things\n===================\n\nSynopsis\n--------\n\n" +
+		"tool -v runs all the commands,\nsee the docs <https://x.dev>.\n\n" +
+		"1. A first item long enough to\n   be wrapped.\n2. Build:\n\n       .configure\n\n> quoted\n\n" +
+		"------------------------------\n"
+	if got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+	if got := plainText([]mdBlock{{kind: mdParagraph, text: "漢字漢字漢字 漢字"}}, 15); got != "漢字漢字漢字\n漢字\n" {
+		t.Errorf("expected wide characters to take two columns, got %q", got)
+	}
+}
+
+func TestRunRender(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":  "package main\n\nfunc main() {}\n",
+		"tool.md":  "# tool - do things\n\n[embedmd]:# (main.go /func main/ $)\n",
+		configFile: "version: 1\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	var buf bytes.Buffer
+	stdout, stderr = &buf, &buf
+	args := []string{"-config", filepath.Join(dir, configFile), "-no-cache"}
+	if code := runRender(append(args, "-format", "text", filepath.Join(dir, "tool.md"))); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, buf.String())
+	}
+	if want := "tool - do things\n================\n\n    func main() {}\n"; buf.String() != want {
+		t.Errorf("expected text\n%q\ngot\n%q", want, buf.String())
+	}
+
+	buf.Reset()
+	if code := runRender(append(args, "-format", "man", "-section", "8", "-out-dir", out, filepath.Join(dir, "tool.md"), filepath.Join(dir, "missing.md"))); code != 1 {
+		t.Errorf("expected exit code 1 for the missing file, got %d", code)
+	}
+	if !strings.Contains(buf.String(), "missing.md: open ") {
+		t.Errorf("expected the missing file to be reported, got %s", buf.String())
+	}
+	man, err := os.ReadFile(filepath.Join(out, "tool.8"))
+	if want := ".TH TOOL 8\n.SH NAME\ntool \\- do things\n.PP\n.RS 4\n.nf\nfunc main() {}\n.fi\n.RE\n"; err != nil || string(man) != want {
+		t.Errorf("expected man page\n%q\ngot\n%q (%v)", want, man, err)
+	}
+
+	buf.Reset()
+	if code := runRender(append(args, "-format", "html", filepath.Join(dir, "tool.md"))); code != 2 || !strings.Contains(buf.String(), "error: bad -format \"html\", should be man or text") {
+		t.Errorf("expected a usage error for the bad format, got %d: %s", code, buf.String())
+	}
+}
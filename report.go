@@ -0,0 +1,131 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"html/template"
+	"os"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// reportRow is a row of a side-by-side diff, with a line of the old content
+// on the left and of the new content on the right. Line numbers are 0 for
+// missing lines, and a row with only Skip set separates the hunks.
+type reportRow struct {
+	OldLine, NewLine int
+	Old, New         string
+	Kind             string
+	Skip             bool
+}
+
+// reportFile is the diff of a file in the report.
+type reportFile struct {
+	Path string
+	Rows []reportRow
+}
+
+// writeReport writes to out an HTML report with the side by side diff of
+// each file that processing would change, without rewriting them, and
+// reports whether there were any.
+func writeReport(out string, paths []string, opts ...embedmd.Option) (bool, error) {
+	planned, err := planFiles(paths, opts...)
+	if err != nil {
+		return false, err
+	}
+	var files []reportFile
+	for i, f := range planned {
+		if f == nil {
+			continue
+		}
+		in, err := readFile(paths[i])
+		if err != nil {
+			return false, err
+		}
+		files = append(files, reportFile{f.Path, sideBySide(string(in), f.Content)})
+	}
+
+	w, err := os.Create(out)
+	if err != nil {
+		return false, err
+	}
+	err = reportTemplate.Execute(w, files)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return len(files) > 0, err
+}
+
+// sideBySide returns the rows of the side by side diff from a to b, with
+// three lines of context around the changes.
+func sideBySide(a, b string) []reportRow {
+	al, bl := lines(a), lines(b)
+	var rows []reportRow
+	for i, group := range difflib.NewMatcher(al, bl).GetGroupedOpCodes(3) {
+		if i > 0 || group[0].I1 > 0 || group[0].J1 > 0 {
+			rows = append(rows, reportRow{Skip: true})
+		}
+		for _, op := range group {
+			n := max(op.I2-op.I1, op.J2-op.J1)
+			for k := 0; k < n; k++ {
+				row := reportRow{Kind: map[byte]string{'e': "equal", 'r': "replace", 'd': "delete", 'i': "insert"}[op.Tag]}
+				if op.I1+k < op.I2 {
+					row.OldLine, row.Old = op.I1+k+1, al[op.I1+k]
+				}
+				if op.J1+k < op.J2 {
+					row.NewLine, row.New = op.J1+k+1, bl[op.J1+k]
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+// lines splits s in lines, without their line endings.
+func lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>embedmd report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; table-layout: fixed; margin-bottom: 2em; }
+td { font-family: monospace; white-space: pre-wrap; word-break: break-all; vertical-align: top; padding: 0 .5em; }
+td.num { width: 3em; color: #888; text-align: right; user-select: none; }
+tr.skip td { background: #eef; color: #888; text-align: center; }
+tr.delete td.old, tr.replace td.old { background: #fdd; }
+tr.insert td.new, tr.replace td.new { background: #dfd; }
+</style>
+</head>
+<body>
+<h1>embedmd report</h1>
+{{if not .}}<p>No pending changes.</p>{{else}}<p>{{len .}} files with pending changes.</p>{{end}}
+{{range .}}<h2>{{.Path}}</h2>
+<table>
+{{range .Rows}}{{if .Skip}}<tr class="skip"><td colspan="4">&hellip;</td></tr>
+{{else}}<tr class="{{.Kind}}"><td class="num">{{if .OldLine}}{{.OldLine}}{{end}}</td><td class="old">{{.Old}}</td><td class="num">{{if .NewLine}}{{.NewLine}}{{end}}</td><td class="new">{{.New}}</td></tr>
+{{end}}{{end}}</table>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,112 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteReport(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("code.go", "if a < b {}\n")
+	doc := write("doc.md", "# Doc\n[embedmd]:# (code.go)\n")
+	same := write("same.md", "# Nothing to embed\n")
+	out := filepath.Join(dir, "report.html")
+
+	changed, err := writeReport(out, []string{doc, same})
+	if err != nil {
+		t.Fatalf("could not write report: %v", err)
+	}
+	if !changed {
+		t.Errorf("expected pending changes to be reported")
+	}
+	b, err := os.ReadFile(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "# Doc\n[embedmd]:# (code.go)\n" {
+		t.Errorf("reporting rewrote %s:\n%s", doc, b)
+	}
+
+	b, err = os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := string(b)
+	for _, want := range []string{
+		"<h2>" + filepath.ToSlash(doc) + "</h2>",
+		`<td class="new">if a &lt; b {}</td>`,
+		"1 files with pending changes",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("expected report to contain %q; got\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, filepath.ToSlash(same)) {
+		t.Errorf("unchanged %s should not be in the report", same)
+	}
+
+	changed, err = writeReport(out, []string{same})
+	if err != nil {
+		t.Fatalf("could not write report: %v", err)
+	}
+	if changed {
+		t.Errorf("expected no pending changes")
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	tc := []struct {
+		name string
+		a, b string
+		rows []reportRow
+	}{
+		{name: "equal", a: "a\n", b: "a\n"},
+		{
+			name: "replace",
+			a:    "a\nb\n", b: "a\nc\nd\n",
+			rows: []reportRow{
+				{OldLine: 1, NewLine: 1, Old: "a", New: "a", Kind: "equal"},
+				{OldLine: 2, NewLine: 2, Old: "b", New: "c", Kind: "replace"},
+				{NewLine: 3, New: "d", Kind: "replace"},
+			},
+		},
+		{
+			name: "hunks",
+			a:    "1\n2\n3\n4\n5\n", b: "2\n3\n4\n5\n",
+			rows: []reportRow{
+				{OldLine: 1, Old: "1", Kind: "delete"},
+				{OldLine: 2, NewLine: 1, Old: "2", New: "2", Kind: "equal"},
+				{OldLine: 3, NewLine: 2, Old: "3", New: "3", Kind: "equal"},
+				{OldLine: 4, NewLine: 3, Old: "4", New: "4", Kind: "equal"},
+			},
+		},
+	}
+	for _, tt := range tc {
+		if rows := sideBySide(tt.a, tt.b); !reflect.DeepEqual(rows, tt.rows) {
+			t.Errorf("case [%s]: expected rows\n%+v\ngot\n%+v", tt.name, tt.rows, rows)
+		}
+	}
+}
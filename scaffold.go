@@ -0,0 +1,349 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// docsDirective starts the comments declaring where a Go declaration is
+// documented, as //docs:embed docfile section.
+const docsDirective = "//docs:embed"
+
+// runScaffold implements the scaffold command, adding to markdown files the
+// commands embedding the Go declarations annotated with //docs:embed, in the
+// sections they name.
+func runScaffold(args []string) int {
+	fs := flag.NewFlagSet("embedmd scaffold", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd scaffold -from-source pattern [flags]\n")
+		fs.PrintDefaults()
+	}
+	var patterns stringList
+	fs.Var(&patterns, "from-source", "Go files to scan, as a file, a directory, or a directory followed by /... for its subdirectories too (repeatable)")
+	dryRun := fs.Bool("n", false, "print the commands that would be added without changing any file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if len(patterns) == 0 || fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+	files, err := goSources(patterns)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	var anns []docsAnnotation
+	for _, f := range files {
+		found, err := docsAnnotations(f)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		anns = append(anns, found...)
+	}
+	if len(anns) == 0 {
+		fmt.Fprintf(stdout, "no %s annotations found\n", docsDirective)
+		return 0
+	}
+	added, err := scaffold(anns, *dryRun)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if added > 0 && !*dryRun {
+		fmt.Fprintln(stdout, "run embedmd -w on these documents to embed the code")
+	}
+	return 0
+}
+
+// docsAnnotation is a //docs:embed annotation of a Go declaration.
+type docsAnnotation struct {
+	// pos is the position of the annotation, as file:line.
+	pos string
+	// source is the file of the declaration, and attr the attribute
+	// selecting it, such as go:func=Name.
+	source, attr string
+	// doc is the markdown file documenting it, relative to the current
+	// directory, and section the heading of the section it's embedded in.
+	doc, section string
+}
+
+// goSources returns the Go files named by the patterns: files, directories,
+// and directories followed by /... standing for their subdirectories too,
+// skipping hidden, testdata, and vendor directories.
+func goSources(patterns []string) ([]string, error) {
+	var files []string
+	for _, p := range patterns {
+		root, recursive := strings.CutSuffix(p, "...")
+		root = filepath.FromSlash(cmp.Or(strings.TrimSuffix(root, "/"), "."))
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, fmt.Errorf("error: -from-source: %v", err)
+		}
+		if !info.IsDir() {
+			if recursive || filepath.Ext(root) != ".go" {
+				return nil, fmt.Errorf("error: -from-source: %s is not a Go file or a directory", p)
+			}
+			files = append(files, root)
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			switch {
+			case err != nil:
+				return err
+			case d.IsDir() && path != root && (!recursive || strings.HasPrefix(d.Name(), ".") || d.Name() == "testdata" || d.Name() == "vendor"):
+				return filepath.SkipDir
+			case !d.IsDir() && filepath.Ext(path) == ".go":
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error: -from-source: %v", err)
+		}
+	}
+	slices.Sort(files)
+	return slices.Compact(files), nil
+}
+
+// docsAnnotations returns the //docs:embed annotations of the Go file, which
+// must be in the doc comments of declarations.
+func docsAnnotations(file string) ([]docsAnnotation, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("error: %v", err)
+	}
+	var anns []docsAnnotation
+	attached := map[*ast.Comment]bool{}
+	annotate := func(doc *ast.CommentGroup, attr string) error {
+		if doc == nil {
+			return nil
+		}
+		for _, c := range doc.List {
+			rest, ok := cutDocsDirective(c.Text)
+			if !ok {
+				continue
+			}
+			attached[c] = true
+			pos := fmt.Sprintf("%s:%d", filepath.ToSlash(file), fset.Position(c.Pos()).Line)
+			doc, section, _ := strings.Cut(strings.TrimSpace(rest), " ")
+			section = strings.TrimSpace(section)
+			if doc == "" || section == "" {
+				return fmt.Errorf("%s: %s needs a markdown file and the heading of a section", pos, docsDirective)
+			}
+			anns = append(anns, docsAnnotation{pos: pos, source: file, attr: attr, doc: filepath.Clean(filepath.FromSlash(doc)), section: section})
+		}
+		return nil
+	}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				name = receiverType(d.Recv.List[0].Type) + "." + name
+			}
+			if err := annotate(d.Doc, "go:func="+name); err != nil {
+				return nil, err
+			}
+		case *ast.GenDecl:
+			kind := map[token.Token]string{token.TYPE: "go:type", token.CONST: "go:const", token.VAR: "go:var"}[d.Tok]
+			if kind == "" {
+				continue
+			}
+			for i, spec := range d.Specs {
+				var name string
+				var doc *ast.CommentGroup
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					name, doc = s.Name.Name, s.Doc
+				case *ast.ValueSpec:
+					name, doc = s.Names[0].Name, s.Doc
+				}
+				if i == 0 && d.Lparen == token.NoPos {
+					// The doc comment of a declaration without parentheses
+					// belongs to the declaration rather than its spec.
+					doc = d.Doc
+				}
+				if err := annotate(doc, kind+"="+name); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	for _, g := range f.Comments {
+		for _, c := range g.List {
+			if _, ok := cutDocsDirective(c.Text); attached[c] || !ok {
+				continue
+			}
+			return nil, fmt.Errorf("%s:%d: %s must be in the doc comment of a declaration, or of a spec of a group", filepath.ToSlash(file), fset.Position(c.Pos()).Line, docsDirective)
+		}
+	}
+	return anns, nil
+}
+
+// cutDocsDirective returns the arguments of the comment if it's a
+// //docs:embed annotation.
+func cutDocsDirective(comment string) (string, bool) {
+	rest, ok := strings.CutPrefix(comment, docsDirective)
+	if !ok || rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return "", false
+	}
+	return rest, true
+}
+
+// receiverType returns the name of the type of a method receiver.
+func receiverType(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.StarExpr:
+		return receiverType(t.X)
+	case *ast.IndexExpr:
+		return receiverType(t.X)
+	case *ast.IndexListExpr:
+		return receiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// scaffold adds the commands of the annotations missing from their markdown
+// files, creating the files and sections needed, and returns how many were
+// added. With dryRun, no file is changed.
+func scaffold(anns []docsAnnotation, dryRun bool) (int, error) {
+	var docs []string
+	byDoc := map[string][]docsAnnotation{}
+	for _, a := range anns {
+		if _, ok := byDoc[a.doc]; !ok {
+			docs = append(docs, a.doc)
+		}
+		byDoc[a.doc] = append(byDoc[a.doc], a)
+	}
+	added := 0
+	for _, doc := range docs {
+		b, err := os.ReadFile(doc)
+		if err != nil && !os.IsNotExist(err) {
+			return added, fmt.Errorf("error: %v", err)
+		}
+		md := string(b)
+		var changes []string
+		for _, a := range byDoc[doc] {
+			rel, err := filepath.Rel(filepath.Dir(doc), a.source)
+			if err != nil {
+				return added, fmt.Errorf("%s: %v", a.pos, err)
+			}
+			cmd := fmt.Sprintf("[embedmd]:# (%s %s)", filepath.ToSlash(rel), a.attr)
+			var ok bool
+			if md, ok = addToSection(md, a.section, cmd); ok {
+				changes = append(changes, fmt.Sprintf("%s under %q", cmd, a.section))
+			}
+		}
+		verb := "added"
+		if dryRun {
+			verb = "would add"
+		}
+		for _, c := range changes {
+			fmt.Fprintf(stdout, "%s: %s %s\n", filepath.ToSlash(doc), verb, c)
+		}
+		if len(changes) == 0 || dryRun {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(doc), 0o755); err != nil {
+			return added, fmt.Errorf("error: %v", err)
+		}
+		if err := os.WriteFile(doc, []byte(md), 0o644); err != nil {
+			return added, fmt.Errorf("error: %v", err)
+		}
+		added += len(changes)
+	}
+	return added, nil
+}
+
+// atxHeading matches the markdown headings written with #.
+var atxHeading = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+
+// addToSection returns md with cmd added at the end of the section with the
+// given heading, created at the end of md if missing, unless the section
+// already has a command with the same arguments.
+func addToSection(md, section, cmd string) (string, bool) {
+	lines := strings.Split(strings.TrimRight(md, "\n"), "\n")
+	if md == "" {
+		lines = nil
+	}
+	start, end, level := -1, len(lines), 0
+	fence := ""
+	for i, l := range lines {
+		trimmed := strings.TrimLeft(l, " ")
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1])) == "" {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, trimmed[:1]))]
+			continue
+		}
+		m := atxHeading.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+		if start >= 0 && len(m[1]) <= level {
+			end = i
+			break
+		}
+		if start < 0 && strings.EqualFold(strings.TrimSpace(m[2]), section) {
+			start, level = i, len(m[1])
+		}
+	}
+	if start < 0 {
+		heading := "## " + section
+		if len(lines) == 0 {
+			heading = "# " + section
+		} else {
+			lines = append(lines, "")
+		}
+		lines = append(lines, heading, "", cmd)
+		return strings.Join(lines, "\n") + "\n", true
+	}
+	args := strings.TrimPrefix(cmd, "[embedmd]:# ")
+	for _, l := range lines[start:end] {
+		if strings.HasPrefix(l, "[embedmd]:#") && strings.TrimSpace(strings.TrimPrefix(l, "[embedmd]:#")) == args {
+			return md, false
+		}
+	}
+	last := end
+	for last > start+1 && strings.TrimSpace(lines[last-1]) == "" {
+		last--
+	}
+	insert := []string{"", cmd}
+	if last < len(lines) {
+		insert = append(insert, "")
+	}
+	lines = slices.Concat(lines[:last], insert, lines[end:])
+	return strings.Join(lines, "\n") + "\n", true
+}
@@ -0,0 +1,141 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const scaffoldSource = `package server
+
+// Server serves the docs.
+//
+//docs:embed docs/api.md Serving
+type Server struct{}
+
+//docs:embed docs/api.md Serving
+func (s *Server) Serve() error { return nil }
+
+const (
+	// Port is the default port.
+	//docs:embed docs/config.md Defaults
+	Port = 8080
+)
+`
+
+func TestScaffold(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	var out bytes.Buffer
+	stdout, stderr = &out, &out
+
+	if err := os.MkdirAll(filepath.Join("pkg", "server"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("pkg", "server", "server.go"), []byte(scaffoldSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll("docs", 0755); err != nil {
+		t.Fatal(err)
+	}
+	api := "# API\n\n## Serving\n\nIntro.\n\n[embedmd]:# (../pkg/server/server.go go:type=Server)\n\n## Other\n"
+	if err := os.WriteFile(filepath.Join("docs", "api.md"), []byte(api), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := runScaffold([]string{"-from-source", "pkg/...", "-n"}); code != 0 {
+		t.Fatalf("expected exit code 0; got %d: %s", code, out.String())
+	}
+	if b, _ := os.ReadFile(filepath.Join("docs", "api.md")); string(b) != api {
+		t.Errorf("-n changed docs/api.md:\n%s", b)
+	}
+
+	out.Reset()
+	if code := runScaffold([]string{"-from-source", "pkg/..."}); code != 0 {
+		t.Fatalf("expected exit code 0; got %d: %s", code, out.String())
+	}
+	want := `docs/api.md: added [embedmd]:# (../pkg/server/server.go go:func=Server.Serve) under "Serving"
+docs/config.md: added [embedmd]:# (../pkg/server/server.go go:const=Port) under "Defaults"
+run embedmd -w on these documents to embed the code
+`
+	if out.String() != want {
+		t.Errorf("expected output:\n%s\ngot:\n%s", want, out.String())
+	}
+	b, _ := os.ReadFile(filepath.Join("docs", "api.md"))
+	wantAPI := "# API\n\n## Serving\n\nIntro.\n\n[embedmd]:# (../pkg/server/server.go go:type=Server)\n\n[embedmd]:# (../pkg/server/server.go go:func=Server.Serve)\n\n## Other\n"
+	if string(b) != wantAPI {
+		t.Errorf("expected docs/api.md:\n%s\ngot:\n%s", wantAPI, b)
+	}
+	b, _ = os.ReadFile(filepath.Join("docs", "config.md"))
+	if want := "# Defaults\n\n[embedmd]:# (../pkg/server/server.go go:const=Port)\n"; string(b) != want {
+		t.Errorf("expected docs/config.md:\n%s\ngot:\n%s", want, b)
+	}
+
+	// The commands are only added once.
+	out.Reset()
+	if code := runScaffold([]string{"-from-source", "pkg/server"}); code != 0 {
+		t.Fatalf("expected exit code 0; got %d: %s", code, out.String())
+	}
+	if out.String() != "" {
+		t.Errorf("expected no output; got:\n%s", out.String())
+	}
+}
+
+func TestScaffold_Misplaced(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	src := "package main\n\nfunc main() {\n\t//docs:embed README.md Usage\n}\n"
+	if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := docsAnnotations(file)
+	if err == nil || !strings.HasSuffix(err.Error(), "main.go:4: //docs:embed must be in the doc comment of a declaration, or of a spec of a group") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestAddToSection(t *testing.T) {
+	const cmd = "[embedmd]:# (a.go go:func=A)"
+	tc := []struct {
+		name, md, section, want string
+	}{
+		{name: "new document", section: "API", want: "# API\n\n" + cmd + "\n"},
+		{name: "new section", md: "# Title\n\nText.\n", section: "API", want: "# Title\n\nText.\n\n## API\n\n" + cmd + "\n"},
+		{name: "end of document", md: "# Title\n\n## API\n\nText.\n\n", section: "api", want: "# Title\n\n## API\n\nText.\n\n" + cmd + "\n"},
+		{name: "before subsection end", md: "## API\n\n### Sub\n\nText.\n\n## Next\n", section: "API", want: "## API\n\n### Sub\n\nText.\n\n" + cmd + "\n\n## Next\n"},
+		{name: "heading in fence", md: "# T\n\n```\n## API\n```\n", section: "API", want: "# T\n\n```\n## API\n```\n\n## API\n\n" + cmd + "\n"},
+		{name: "already there", md: "## API\n\n" + cmd + "\n```go\nfunc A() {}\n```\n", section: "API", want: "## API\n\n" + cmd + "\n```go\nfunc A() {}\n```\n"},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := addToSection(tt.md, tt.section, cmd)
+			if got != tt.want {
+				t.Errorf("expected:\n%q\ngot:\n%q", tt.want, got)
+			}
+		})
+	}
+}
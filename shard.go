@@ -0,0 +1,110 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// shardPaths returns the paths in the shard i/n, numbered from 1, as set
+// with -shard. Files are assigned to shards by a hash of their path, so
+// every job of a CI run agrees on them, and adding files doesn't move the
+// others to another shard.
+func shardPaths(paths []string, shard string) ([]string, error) {
+	i, n, err := parseShard(shard)
+	if err != nil {
+		return nil, err
+	}
+	var in []string
+	for _, p := range paths {
+		h := fnv.New32a()
+		h.Write([]byte(filepath.ToSlash(filepath.Clean(p)))) //nolint:errcheck
+		if int(h.Sum32()%uint32(n)) == i-1 {
+			in = append(in, p)
+		}
+	}
+	return in, nil
+}
+
+// parseShard parses a shard written as i/n.
+func parseShard(shard string) (i, n int, err error) {
+	is, ns, ok := strings.Cut(shard, "/")
+	i, ierr := strconv.Atoi(is)
+	n, nerr := strconv.Atoi(ns)
+	if !ok || ierr != nil || nerr != nil || i < 1 || i > n {
+		return 0, 0, fmt.Errorf("error: bad -shard %q, should be i/n with i from 1 to n", shard)
+	}
+	return i, n, nil
+}
+
+// runMerge implements the merge command, combining the reports written by
+// -report-json in the shards of a run into one.
+func runMerge(args []string) int {
+	fs := flag.NewFlagSet("embedmd merge", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd merge -o file report.json ...\n")
+		fs.PrintDefaults()
+	}
+	out := fs.String("o", "", "file the combined report is written to")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *out == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	merged, err := mergeReports(fs.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if err := merged.write(*out); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// mergeReports returns the report with the stale blocks of the reports at
+// the given paths, sorted by file and line.
+func mergeReports(paths []string) (*staleReport, error) {
+	merged := &staleReport{}
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var r staleReport
+		if err := json.Unmarshal(b, &r); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if r.Version != 1 {
+			return nil, fmt.Errorf("%s: unsupported report version %d", path, r.Version)
+		}
+		merged.Blocks = append(merged.Blocks, r.Blocks...)
+	}
+	slices.SortStableFunc(merged.Blocks, func(a, b reportedBlock) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line))
+	})
+	return merged, nil
+}
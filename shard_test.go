@@ -0,0 +1,95 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestShardPaths(t *testing.T) {
+	var paths []string
+	for i := 0; i < 100; i++ {
+		paths = append(paths, fmt.Sprintf("docs/%d/README.md", i))
+	}
+	var all []string
+	for i := 1; i <= 3; i++ {
+		shard, err := shardPaths(paths, fmt.Sprintf("%d/3", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(shard) == 0 {
+			t.Errorf("expected shard %d/3 to have files", i)
+		}
+		// Adding files doesn't move the others.
+		more, err := shardPaths(append([]string{"new.md"}, paths...), fmt.Sprintf("%d/3", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		more = slices.DeleteFunc(more, func(p string) bool { return p == "new.md" })
+		if !slices.Equal(more, shard) {
+			t.Errorf("expected shard %d/3 to keep its files when adding one", i)
+		}
+		all = append(all, shard...)
+	}
+	slices.Sort(all)
+	want := slices.Clone(paths)
+	slices.Sort(want)
+	if !slices.Equal(all, want) {
+		t.Errorf("expected the shards to have every file once; got %v", all)
+	}
+
+	for _, bad := range []string{"0/3", "4/3", "1", "a/b", "1/0"} {
+		_, err := shardPaths(paths, bad)
+		eqErr(t, bad, err, fmt.Sprintf("error: bad -shard %q, should be i/n with i from 1 to n", bad))
+	}
+}
+
+func TestMergeReports(t *testing.T) {
+	dir := t.TempDir()
+	reports := []string{
+		`{"version": 1, "stale": [{"file": "b.md", "line": 3, "sources": []}, {"file": "a.md", "line": 9, "sources": []}]}`,
+		`{"version": 1, "stale": []}`,
+		`{"version": 1, "stale": [{"file": "a.md", "line": 2, "sources": [{"path": "a.go", "line": 2}]}]}`,
+	}
+	var paths []string
+	for i, r := range reports {
+		p := filepath.Join(dir, fmt.Sprintf("%d.json", i))
+		if err := os.WriteFile(p, []byte(r), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	merged, err := mergeReports(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, b := range merged.Blocks {
+		got = append(got, fmt.Sprintf("%s:%d", b.File, b.Line))
+	}
+	if want := []string{"a.md:2", "a.md:9", "b.md:3"}; !slices.Equal(got, want) {
+		t.Errorf("expected blocks %v; got %v", want, got)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"version": 2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = mergeReports([]string{bad})
+	eqErr(t, "bad version", err, bad+": unsupported report version 2")
+}
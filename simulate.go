@@ -0,0 +1,140 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// runSimulate implements the simulate command, reporting the embeds of the
+// given markdown files that would change or break if the local sources were
+// those of another git ref, without modifying the files.
+func runSimulate(args []string) int {
+	fs := flag.NewFlagSet("embedmd simulate", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd simulate -source-ref ref [flags] [path ...]\n")
+		fs.PrintDefaults()
+	}
+	o := newCommandFlags(fs)
+	ref := fs.String("source-ref", "", "git ref to read the local sources from, such as a branch")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := setup(fs, o); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *ref == "" {
+		fmt.Fprintln(stderr, "error: -source-ref is required")
+		return 2
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	opts, err := o.embedOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	f, err := o.fetcher()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if _, err := runGit("rev-parse", "--verify", "--quiet", *ref+"^{commit}"); err != nil {
+		fmt.Fprintf(stderr, "error: unknown -source-ref %q\n", *ref)
+		return 2
+	}
+	docs, err := markdownFiles(paths)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	code := 0
+	var changed, broken int
+	for _, doc := range docs {
+		r, err := simulate(doc, refFetcher(*ref, f), opts...)
+		name := filepath.ToSlash(doc)
+		switch {
+		case err != nil:
+			code = 1
+			fmt.Fprintf(stderr, "%s:%v\n", name, err)
+		case r.err != nil:
+			broken++
+			fmt.Fprintf(stdout, "would break: %s:%v\n", name, r.err)
+		case r.diff != "":
+			changed++
+			fmt.Fprintf(stdout, "would change: %s\n%s", name, r.diff)
+		}
+	}
+	fmt.Fprintf(stdout, "%d of %d files would change, %d would break with the sources of %s\n", changed, len(docs), broken, *ref)
+	if broken > 0 {
+		code = 1
+	}
+	return code
+}
+
+// refFetcher returns a fetcher reading local sources from the git ref, and
+// fetching URLs with f. Paths are relative to the current directory.
+func refFetcher(ref string, f embedmd.Fetcher) embedmd.Fetcher {
+	return embedmd.FetcherContextFunc(func(ctx context.Context, dir, p string) ([]byte, error) {
+		if strings.Contains(p, "://") {
+			return embedmd.FetchContext(ctx, f, dir, p)
+		}
+		rel, err := repoPath(filepath.Join(dir, filepath.FromSlash(p)))
+		if err != nil {
+			return nil, err
+		}
+		return runGit("show", ref+":./"+rel)
+	})
+}
+
+// simulation is the outcome of processing a document with other sources.
+type simulation struct {
+	// diff is the change to the document embedding the current sources, and
+	// err the error processing it, if any.
+	diff string
+	err  error
+}
+
+// simulate processes the document with the current sources and with those
+// provided by f, comparing the results. Documents that are already stale
+// aren't reported as changed unless the other sources change them further.
+func simulate(doc string, f embedmd.Fetcher, opts ...embedmd.Option) (simulation, error) {
+	in, err := readFile(doc)
+	if err != nil {
+		return simulation{}, err
+	}
+	opts = append(opts, embedmd.WithBaseDir(filepath.Dir(doc)), embedmd.WithWarnings(func(int, string) {}))
+	var cur bytes.Buffer
+	if err := embedmd.Process(&cur, bytes.NewReader(in), opts...); err != nil {
+		return simulation{}, err
+	}
+	var next bytes.Buffer
+	if err := embedmd.Process(&next, bytes.NewReader(in), append(opts, embedmd.WithFetcher(f))...); err != nil {
+		return simulation{err: err}, nil
+	}
+	d, err := diff(cur.String(), next.String())
+	return simulation{diff: d}, err
+}
@@ -0,0 +1,111 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunSimulate(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(files map[string]string) {
+		t.Helper()
+		for name, content := range files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	git("init", "-q")
+	write(map[string]string{
+		"a.go":      "func A() {}\n",
+		"b.go":      "func B() {}\n",
+		"c.go":      "func C() {}\n",
+		"docs/a.md": "[embedmd]:# (../a.go)\n```go\nfunc A() {}\n```\n",
+		"docs/b.md": "[embedmd]:# (../b.go /func B/ $)\n```go\nfunc B() {}\n```\n",
+		"docs/c.md": "[embedmd]:# (../c.go)\n```go\nfunc C() {}\n```\n",
+	})
+	git("add", "-A")
+	git("commit", "-q", "-m", "base")
+	git("checkout", "-q", "-b", "refactor")
+	write(map[string]string{"a.go": "func A(ctx context.Context) {}\n", "b.go": "func Renamed() {}\n"})
+	git("commit", "-q", "-am", "refactor")
+	git("checkout", "-q", "-")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configFile, []byte("version: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	var out bytes.Buffer
+	stdout, stderr = &out, &out
+
+	if code := runSimulate([]string{"-source-ref", "refactor", "docs"}); code != 1 {
+		t.Errorf("expected exit code 1; got %d", code)
+	}
+	want := `would change: docs/a.md
+@@ -1,5 +1,5 @@
+ [embedmd]:# (../a.go)
+ ` + "```go" + `
+-func A() {}
++func A(ctx context.Context) {}
+ ` + "```" + `
+ 
+would break: docs/b.md:1: could not extract content from ../b.go: could not match "/func B/"
+1 of 3 files would change, 1 would break with the sources of refactor
+`
+	if out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+
+	out.Reset()
+	if code := runSimulate([]string{"-source-ref", "missing"}); code != 2 {
+		t.Errorf("expected exit code 2; got %d", code)
+	}
+	if want := "error: unknown -source-ref \"missing\"\n"; out.String() != want {
+		t.Errorf("expected %q; got %q", want, out.String())
+	}
+	if b, _ := os.ReadFile("docs/a.md"); string(b) != "[embedmd]:# (../a.go)\n```go\nfunc A() {}\n```\n" {
+		t.Errorf("docs/a.md was modified: %q", b)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// runSnippet implements the snippet command, printing a command embedding
+// the given lines of a file, with regular expressions matching them.
+func runSnippet(args []string) int {
+	fs := flag.NewFlagSet("embedmd snippet", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd snippet -file path -lines from-to [flags]\n")
+		fs.PrintDefaults()
+	}
+	file := fs.String("file", "", "file to embed from")
+	lines := fs.String("lines", "", "lines to embed, as from-to or a single line, numbered from 1")
+	doc := fs.String("doc", "", "markdown file the command is for, so the path is relative to it")
+	lang := fs.String("lang", "", "language of the code, required if it can't be told from the file extension")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" || *lines == "" || fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+	cmd, err := snippet(*file, *lines, *doc, *lang)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	fmt.Fprintln(stdout, cmd)
+	return 0
+}
+
+// snippet returns the command embedding the given lines of file, from the
+// markdown file doc if not empty.
+func snippet(file, lines, doc, lang string) (string, error) {
+	from, to, err := parseLines(lines)
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	src := strings.ReplaceAll(string(b), "\r\n", "\n")
+
+	path := filepath.ToSlash(file)
+	if doc != "" {
+		rel, err := filepath.Rel(filepath.Dir(doc), file)
+		if err != nil {
+			return "", err
+		}
+		path = filepath.ToSlash(rel)
+	}
+	args := []string{path}
+	if lang != "" {
+		args = append(args, lang)
+	} else if filepath.Ext(file) == "" {
+		return "", fmt.Errorf("%s has no extension, use -lang to set the language", file)
+	}
+	anchors, err := embedmd.SuggestAnchors(src, from, to)
+	if err != nil {
+		return "", err
+	}
+	if anchors == nil {
+		// No regular expression selects the lines, so they are embedded by
+		// number.
+		args[0] += fmt.Sprintf("#L%d", from)
+		if to > from {
+			args[0] += fmt.Sprintf("-L%d", to)
+		}
+	}
+	args = append(args, anchors...)
+	return "[embedmd]:# (" + strings.Join(args, " ") + ")", nil
+}
+
+// parseLines parses a range of lines, as from-to or a single line.
+func parseLines(s string) (from, to int, err error) {
+	a, b, ok := strings.Cut(s, "-")
+	from, err = strconv.Atoi(a)
+	to = from
+	if err == nil && ok {
+		to, err = strconv.Atoi(b)
+	}
+	if err != nil || from < 1 || to < from {
+		return 0, 0, fmt.Errorf("bad line range %q, should be from-to with 1 <= from <= to", s)
+	}
+	return from, to, nil
+}
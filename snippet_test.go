@@ -0,0 +1,112 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+const snippetSource = `package main
+
+import "fmt"
+
+func hello() {
+	fmt.Println("hello")
+}
+
+func main() {
+	hello()
+	fmt.Println("hello")
+}
+`
+
+func TestSnippet(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "pkg", "main.go")
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(snippetSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	doc := filepath.Join(dir, "docs", "README.md")
+
+	tc := []struct {
+		name  string
+		lines string
+		lang  string
+		cmd   string
+		err   string
+	}{
+		{name: "function", lines: "5-7", cmd: `(../pkg/main.go /^func hello\(\)/ /^\}.*/)`},
+		{name: "blank lines around", lines: "4-8", cmd: `(../pkg/main.go /^func hello\(\)/ /^\}.*/)`},
+		{name: "until the end", lines: "9-12", cmd: `(../pkg/main.go /^func main\(\)/ $)`},
+		{name: "indented end", lines: "9-10", cmd: `(../pkg/main.go /^func main\(\)/ /^[[:blank:]]*hello\(\).*/)`},
+		{name: "single line", lines: "6", cmd: `(../pkg/main.go /^[[:blank:]]*fmt\.Println\("hello"\).*\n/)`},
+		{name: "not unique", lines: "11", cmd: `(../pkg/main.go#L11)`},
+		{name: "end not unique", lines: "5-11", cmd: `(../pkg/main.go#L5-L11)`},
+		{name: "language", lines: "3", lang: "golang", cmd: `(../pkg/main.go golang /^import "fmt".*\n/)`},
+		{name: "out of range", lines: "9-13", err: "line range 9-13 is out of range, the file has 12 lines"},
+		{name: "blank", lines: "8", err: "lines to embed are blank"},
+		{name: "bad range", lines: "7-5", err: `bad line range "7-5", should be from-to with 1 <= from <= to`},
+	}
+	for _, tt := range tc {
+		cmd, err := snippet(file, tt.lines, doc, tt.lang)
+		if !eqErr(t, tt.name, err, tt.err) {
+			continue
+		}
+		if want := "[embedmd]:# " + tt.cmd; cmd != want {
+			t.Errorf("case [%s]: expected\n%s\ngot\n%s", tt.name, want, cmd)
+			continue
+		}
+
+		// The command embeds the selected lines.
+		var out bytes.Buffer
+		fetcher := fakeFetcher{"../pkg/main.go": snippetSource}
+		if err := embedmd.Process(&out, strings.NewReader(cmd+"\n"), embedmd.WithFetcher(fetcher)); err != nil {
+			t.Errorf("case [%s]: could not embed: %v", tt.name, err)
+			continue
+		}
+		from, to, _ := parseLines(tt.lines)
+		lines := strings.SplitAfter(snippetSource, "\n")
+		selected := strings.Trim(strings.Join(lines[from-1:to], ""), "\n")
+		if !strings.Contains(out.String(), "\n"+selected+"\n```") {
+			t.Errorf("case [%s]: expected the command to embed\n%s\ngot\n%s", tt.name, selected, out.String())
+		}
+	}
+}
+
+type fakeFetcher map[string]string
+
+func (f fakeFetcher) Fetch(dir, path string) ([]byte, error) {
+	if s, ok := f[path]; ok {
+		return []byte(s), nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func TestRunSnippetUsage(t *testing.T) {
+	defer func(o io.Writer) { stdout = o }(stdout)
+	stdout = io.Discard
+	if code := runSnippet([]string{"-lines", "1-2"}); code != 2 {
+		t.Errorf("expected exit code 2 without -file; got %d", code)
+	}
+}
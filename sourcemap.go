@@ -0,0 +1,74 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// sourceMaps is set to write a source map next to each file rewritten.
+var sourceMaps bool
+
+// sourceMapExt is appended to the path of a file to name its source map.
+const sourceMapExt = ".embedmap.json"
+
+// sourceMap records, for each range of lines embedded in a file, the region
+// of the source it comes from, so tools like search or "edit this code"
+// links can point back to it.
+type sourceMap struct {
+	Version  int           `json:"version"`
+	File     string        `json:"file"`
+	Mappings []mappedLines `json:"mappings"`
+}
+
+// mappedLines is a range of lines of the file embedded from a source.
+type mappedLines struct {
+	Start  int          `json:"start"`
+	End    int          `json:"end"`
+	Source mappedSource `json:"source"`
+}
+
+// mappedSource is the region of a source embedded by the command at Line,
+// with Path as written in the command.
+type mappedSource struct {
+	Path  string `json:"path"`
+	Line  int    `json:"line"`
+	Start int    `json:"start,omitempty"`
+	End   int    `json:"end,omitempty"`
+}
+
+// collect returns the option recording the lines embedded in the file.
+func (m *sourceMap) collect() embedmd.Option {
+	return embedmd.WithSourceMap(func(r embedmd.MappedRegion) {
+		m.Mappings = append(m.Mappings, mappedLines{r.OutStart, r.OutEnd, mappedSource{r.Path, r.Line, r.Start, r.End}})
+	})
+}
+
+// write writes the source map of the file at path next to it.
+func (m *sourceMap) write(path string) error {
+	m.Version = 1
+	m.File = filepath.ToSlash(path)
+	if m.Mappings == nil {
+		m.Mappings = []mappedLines{}
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+sourceMapExt, append(b, '\n'), 0644)
+}
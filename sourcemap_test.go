@@ -0,0 +1,67 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSourceMap(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"code.go":   "package main\n\nfunc main() {\n\tprintln(1)\n}\n",
+		"docs/a.md": "# Title\n\n[embedmd]:# (../code.go /func main/ $)\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { sourceMaps = false }()
+	sourceMaps = true
+
+	if _, err := embed([]string{"docs/a.md"}, true, false); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile("docs/a.md.embedmap.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got sourceMap
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("bad source map: %v\n%s", err, b)
+	}
+	want := sourceMap{Version: 1, File: "docs/a.md", Mappings: []mappedLines{
+		{Start: 5, End: 7, Source: mappedSource{Path: "../code.go", Line: 3, Start: 3, End: 5}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v; got\n%s", want, b)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// sourcePatches collects the patches to sources for -suggest-patches, if
+// set.
+var sourcePatches *patchSet
+
+// patchSet holds the unified diffs of the patches to sources implementing
+// the edits made by hand to the blocks embedding them.
+type patchSet struct {
+	diffs []string
+	mu    sync.Mutex
+}
+
+// collect returns the option recording the patches suggested by the blocks
+// of the file at path.
+func (s *patchSet) collect(path string) embedmd.Option {
+	return embedmd.WithSourcePatches(func(p embedmd.SourcePatch) {
+		src := p.Path
+		if !filepath.IsAbs(src) {
+			src = filepath.Join(filepath.Dir(path), filepath.FromSlash(src))
+		}
+		src = filepath.ToSlash(filepath.Clean(src))
+		d, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        sourceLines(p.Before),
+			B:        sourceLines(p.After),
+			FromFile: "a/" + strings.TrimPrefix(src, "/"),
+			ToFile:   "b/" + strings.TrimPrefix(src, "/"),
+			Context:  3,
+		})
+		if err != nil || d == "" {
+			return
+		}
+		s.mu.Lock()
+		s.diffs = append(s.diffs, d)
+		s.mu.Unlock()
+	})
+}
+
+// write writes the patches to the file at path, one after the other.
+func (s *patchSet) write(path string) error {
+	return os.WriteFile(path, []byte(strings.Join(s.diffs, "")), 0644)
+}
+
+// sourceLines returns the lines of the source b, with their newline, for
+// diffs applied as patches: unlike difflib.SplitLines, no line is added
+// after the last newline.
+func sourceLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	return lines
+}
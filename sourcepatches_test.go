@@ -0,0 +1,69 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+func TestSuggestPatches(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "code.go"), []byte("package main\n\nfunc main() {\n\tprintln(\"helo\")\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var generated bytes.Buffer
+	err := embedmd.Process(&generated, strings.NewReader("[embedmd]:# (../code.go /func main/ $)\n"),
+		embedmd.WithBaseDir(filepath.Join(dir, "docs")), embedmd.WithChecksums())
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := filepath.Join(dir, "docs", "README.md")
+	if err := os.WriteFile(doc, []byte(strings.Replace(generated.String(), "helo", "hello", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	defer func() { sourcePatches = nil }()
+	sourcePatches = &patchSet{}
+	if _, err := processFile("docs/README.md", false, true, embedmd.WithChecksums()); err != nil {
+		t.Fatal(err)
+	}
+	if err := sourcePatches.write("fix.patch"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "fix.patch"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--- a/code.go\n+++ b/code.go\n@@ -1,5 +1,5 @@\n package main\n \n func main() {\n-\tprintln(\"helo\")\n+\tprintln(\"hello\")\n }\n"
+	if string(b) != want {
+		t.Errorf("expected patch\n%s\ngot\n%s", want, b)
+	}
+}
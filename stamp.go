@@ -0,0 +1,99 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// A stamp records the inputs consumed by a run, written with -stamp-out so
+// build systems can declare them as dependencies of the generated docs.
+type stamp struct {
+	mu     sync.Mutex
+	inputs map[string]stampInput
+}
+
+// stampInput is a file or URL read by a run, with the hash of its content.
+type stampInput struct {
+	Path   string `json:"path,omitempty"`
+	URL    string `json:"url,omitempty"`
+	SHA256 string `json:"sha256"`
+}
+
+// stampVersion is the version of the stamp format.
+const stampVersion = 1
+
+func newStamp() *stamp { return &stamp{inputs: map[string]stampInput{}} }
+
+// add records that the file or URL at path was read with content b.
+func (s *stamp) add(path string, b []byte) {
+	in := stampInput{SHA256: hashBytes(b)}
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		in.URL = path
+	} else {
+		in.Path = filepath.ToSlash(filepath.Clean(path))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inputs[in.Path+in.URL] = in
+}
+
+// middleware returns the middleware recording the content fetched.
+func (s *stamp) middleware() embedmd.Middleware {
+	return func(next embedmd.Fetcher) embedmd.Fetcher {
+		return embedmd.FetcherContextFunc(func(ctx context.Context, dir, path string) ([]byte, error) {
+			b, err := embedmd.FetchContext(ctx, next, dir, path)
+			if err != nil {
+				return nil, err
+			}
+			resolved := path
+			if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") && !filepath.IsAbs(path) {
+				resolved = filepath.Join(dir, filepath.FromSlash(path))
+			}
+			s.add(resolved, b)
+			return b, nil
+		})
+	}
+}
+
+// write writes the inputs recorded, sorted, to the file at path.
+func (s *stamp) write(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := struct {
+		Version int          `json:"version"`
+		Inputs  []stampInput `json:"inputs"`
+	}{Version: stampVersion, Inputs: []stampInput{}}
+	keys := make([]string, 0, len(s.inputs))
+	for k := range s.inputs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		m.Inputs = append(m.Inputs, s.inputs[k])
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0666)
+}
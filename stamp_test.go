@@ -0,0 +1,75 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+func TestStamp(t *testing.T) {
+	s := newStamp()
+	s.add("docs/README.md", []byte("[embedmd]:# (../code.go)\n"))
+	f := embedmd.ChainFetcher(embedmd.FetcherFunc(func(dir, path string) ([]byte, error) {
+		if path == "missing.go" {
+			return nil, os.ErrNotExist
+		}
+		return []byte("package main\n"), nil
+	}), s.middleware())
+
+	var out bytes.Buffer
+	in := "[embedmd]:# (../code.go)\n[embedmd]:# (https://example.com/x.go)\n\n[embedmd]:# (../code.go)\n"
+	if err := embedmd.Process(&out, strings.NewReader(in), embedmd.WithFetcher(f), embedmd.WithBaseDir("docs")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Fetch("docs", "missing.go"); err == nil {
+		t.Fatal("expected an error fetching missing.go")
+	}
+
+	path := filepath.Join(t.TempDir(), "stamp.json")
+	if err := s.write(path); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := hashBytes([]byte("package main\n"))
+	want := `{
+  "version": 1,
+  "inputs": [
+    {
+      "path": "code.go",
+      "sha256": "` + code + `"
+    },
+    {
+      "path": "docs/README.md",
+      "sha256": "` + hashBytes([]byte("[embedmd]:# (../code.go)\n")) + `"
+    },
+    {
+      "url": "https://example.com/x.go",
+      "sha256": "` + code + `"
+    }
+  ]
+}
+`
+	if string(b) != want {
+		t.Errorf("expected stamp\n%s\ngot\n%s", want, b)
+	}
+}
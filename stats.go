@@ -0,0 +1,283 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// runStats implements the stats command, measuring how often the documents
+// in the given paths went stale in the git history, and for how long.
+func runStats(args []string) int {
+	fs := flag.NewFlagSet("embedmd stats", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd stats [flags] [path ...]\n")
+		fs.PrintDefaults()
+	}
+	o := newCommandFlags(fs)
+	history := fs.Bool("history", false, "check every commit of the history rather than only HEAD")
+	maxCommits := fs.Int("max-commits", 1000, "with -history, check at most this many of the latest commits")
+	format := fs.String("format", "csv", "output format: csv or json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := setup(fs, o); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *format != "csv" && *format != "json" {
+		fmt.Fprintf(stderr, "error: bad -format %q, must be csv or json\n", *format)
+		return 2
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	opts, err := o.embedOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	n := 1
+	if *history {
+		n = *maxCommits
+	}
+	commits, err := gitCommits(n)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	stats, err := driftStats(commits, paths, opts...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if *format == "json" {
+		err = writeStatsJSON(stats)
+	} else {
+		err = writeStatsCSV(stats)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// runGit runs git with the given arguments, returning its output.
+var runGit = func(args ...string) ([]byte, error) {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(ee.Stderr)))
+		}
+		return nil, fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return out, nil
+}
+
+// commit is a commit of the history.
+type commit struct {
+	hash string
+	time time.Time
+}
+
+// gitCommits returns the latest n commits reachable from HEAD, oldest first.
+func gitCommits(n int) ([]commit, error) {
+	out, err := runGit("log", "--first-parent", "--reverse", "-n", strconv.Itoa(n), "--format=%H %ct")
+	if err != nil {
+		return nil, err
+	}
+	var commits []commit
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		hash, ts, ok := strings.Cut(line, " ")
+		sec, err := strconv.ParseInt(ts, 10, 64)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("git log: unexpected line %q", line)
+		}
+		commits = append(commits, commit{hash, time.Unix(sec, 0)})
+	}
+	return commits, nil
+}
+
+// docStats are the drift statistics of a document.
+type docStats struct {
+	Path string `json:"path"`
+	// Checked is the number of commits where the document was checked,
+	// Stale the number where it was stale, and Errors the number where it
+	// couldn't be processed, e.g. as it embeds URLs.
+	Checked int `json:"checked"`
+	Stale   int `json:"stale"`
+	Errors  int `json:"errors"`
+	// Episodes is the number of times the document went stale, staying
+	// stale for MeanStaleHours on average and MaxStaleHours at most.
+	Episodes       int     `json:"episodes"`
+	MeanStaleHours float64 `json:"mean_stale_hours"`
+	MaxStaleHours  float64 `json:"max_stale_hours"`
+	// StaleNow is set if the document is stale at the last commit.
+	StaleNow bool `json:"stale_now"`
+
+	staleSince time.Time
+	total      time.Duration
+}
+
+// driftStats checks the markdown files in paths at each commit, returning
+// their statistics ordered by path. A document is stale at a commit when
+// processing it with the sources of the commit would change it.
+func driftStats(commits []commit, paths []string, opts ...embedmd.Option) ([]*docStats, error) {
+	byPath := map[string]*docStats{}
+	var order []string
+	for _, c := range commits {
+		args := append([]string{"ls-tree", "-r", "--name-only", "--full-name", c.hash, "--"}, paths...)
+		out, err := runGit(args...)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range strings.Fields(string(out)) {
+			if path.Ext(doc) != ".md" {
+				continue
+			}
+			s := byPath[doc]
+			if s == nil {
+				s = &docStats{Path: doc}
+				byPath[doc] = s
+				order = append(order, doc)
+			}
+			stale, err := staleAt(c, doc, opts...)
+			s.record(c.time, stale, err)
+		}
+	}
+	if len(commits) > 0 {
+		last := commits[len(commits)-1].time
+		for _, s := range byPath {
+			s.finish(last)
+		}
+	}
+
+	slices.Sort(order)
+	stats := make([]*docStats, len(order))
+	for i, doc := range order {
+		stats[i] = byPath[doc]
+	}
+	return stats, nil
+}
+
+// staleAt reports whether the document would change if processed with the
+// sources of the commit. Remote sources aren't fetched, as their content at
+// the time of the commit is unknown.
+func staleAt(c commit, doc string, opts ...embedmd.Option) (bool, error) {
+	in, err := gitShow(c, doc)
+	if err != nil {
+		return false, err
+	}
+	fetcher := embedmd.FetcherFunc(func(dir, p string) ([]byte, error) {
+		if strings.Contains(p, "://") {
+			return nil, errors.New("remote sources aren't checked in the history")
+		}
+		return gitShow(c, path.Join(dir, p))
+	})
+	var out bytes.Buffer
+	opts = append(opts, embedmd.WithBaseDir(path.Dir(doc)), embedmd.WithFetcher(fetcher),
+		embedmd.WithWarnings(func(int, string) {}))
+	if err := embedmd.Process(&out, bytes.NewReader(in), opts...); err != nil {
+		return false, err
+	}
+	return !bytes.Equal(in, out.Bytes()), nil
+}
+
+// gitShow returns the content of the file at the given path from the root of
+// the repository at the commit.
+func gitShow(c commit, p string) ([]byte, error) {
+	return runGit("show", c.hash+":"+path.Clean(p))
+}
+
+// record adds the state of the document at a commit made at t.
+func (s *docStats) record(t time.Time, stale bool, err error) {
+	s.Checked++
+	switch {
+	case err != nil:
+		s.Errors++
+	case stale:
+		s.Stale++
+		if s.staleSince.IsZero() {
+			s.staleSince = t
+			s.Episodes++
+		}
+	case !s.staleSince.IsZero():
+		s.addEpisode(t.Sub(s.staleSince))
+		s.staleSince = time.Time{}
+	}
+}
+
+// finish ends the statistics at the last commit, made at t, counting the
+// document still stale as stale until then.
+func (s *docStats) finish(t time.Time) {
+	if s.staleSince.IsZero() {
+		return
+	}
+	s.StaleNow = true
+	s.addEpisode(t.Sub(s.staleSince))
+}
+
+func (s *docStats) addEpisode(d time.Duration) {
+	s.total += d
+	s.MeanStaleHours = s.total.Hours() / float64(s.Episodes)
+	s.MaxStaleHours = max(s.MaxStaleHours, d.Hours())
+}
+
+func writeStatsJSON(stats []*docStats) error {
+	if stats == nil {
+		stats = []*docStats{}
+	}
+	b, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "%s\n", b)
+	return err
+}
+
+func writeStatsCSV(stats []*docStats) error {
+	w := csv.NewWriter(stdout)
+	w.Write([]string{"path", "checked", "stale", "errors", "episodes", "mean_stale_hours", "max_stale_hours", "stale_now"})
+	for _, s := range stats {
+		w.Write([]string{
+			s.Path,
+			strconv.Itoa(s.Checked),
+			strconv.Itoa(s.Stale),
+			strconv.Itoa(s.Errors),
+			strconv.Itoa(s.Episodes),
+			strconv.FormatFloat(s.MeanStaleHours, 'f', 1, 64),
+			strconv.FormatFloat(s.MaxStaleHours, 'f', 1, 64),
+			strconv.FormatBool(s.StaleNow),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,110 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunStats(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(date string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_COMMITTER_DATE="+date, "GIT_AUTHOR_DATE="+date)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	commit := func(date string, files map[string]string) {
+		t.Helper()
+		for name, content := range files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		git(date, "add", "-A")
+		git(date, "commit", "-q", "--allow-empty", "-m", date)
+	}
+	doc := func(code string) string { return "[embedmd]:# (../code.go)\n```go\n" + code + "```\n" }
+
+	git("2026-01-01T00:00:00Z", "init", "-q")
+	commit("2026-01-01T00:00:00Z", map[string]string{"code.go": "v1\n", "docs/a.md": doc("v1\n"), "docs/b.md": doc("v1\n")})
+	commit("2026-01-02T00:00:00Z", map[string]string{"code.go": "v2\n", "docs/a.md": doc("v2\n")})
+	commit("2026-01-03T00:00:00Z", map[string]string{"docs/c.md": "[embedmd]:# (https://example.com/x.go)\n"})
+	commit("2026-01-03T12:00:00Z", map[string]string{"docs/b.md": doc("v2\n")})
+	commit("2026-01-04T00:00:00Z", map[string]string{"code.go": "v3\n"})
+	commit("2026-01-05T00:00:00Z", map[string]string{"README.md": "Not in the docs\n"})
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configFile, []byte("version: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	var out bytes.Buffer
+	stdout, stderr = &out, &out
+
+	if code := runStats([]string{"-history", "docs"}); code != 0 {
+		t.Fatalf("expected exit code 0; got %d: %s", code, out.String())
+	}
+	want := "path,checked,stale,errors,episodes,mean_stale_hours,max_stale_hours,stale_now\n" +
+		"docs/a.md,6,2,0,1,24.0,24.0,true\n" +
+		"docs/b.md,6,4,0,2,30.0,36.0,true\n" +
+		"docs/c.md,4,0,4,0,0.0,0.0,false\n"
+	if out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+
+	out.Reset()
+	if code := runStats([]string{"-format", "json", "docs/a.md"}); code != 0 {
+		t.Fatalf("expected exit code 0; got %d: %s", code, out.String())
+	}
+	wantJSON := `[
+  {
+    "path": "docs/a.md",
+    "checked": 1,
+    "stale": 1,
+    "errors": 0,
+    "episodes": 1,
+    "mean_stale_hours": 0,
+    "max_stale_hours": 0,
+    "stale_now": true
+  }
+]
+`
+	if out.String() != wantJSON {
+		t.Errorf("expected\n%s\ngot\n%s", wantJSON, out.String())
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// runStore implements the store command, maintaining the store of remote
+// content enabled by -store.
+func runStore(args []string) int {
+	if len(args) == 0 || args[0] != "gc" {
+		storeUsage()
+		return 2
+	}
+	fs := flag.NewFlagSet("embedmd store gc", flag.ContinueOnError)
+	o := newCommandFlags(fs)
+	maxAge := fs.Duration("max-age", 30*24*time.Hour, "remove the content not used for longer than this, 0 for no limit")
+	maxSize := fs.Int64("max-size", 1024, "then remove the least recently used content until the store holds at most this many MiB, 0 for no limit")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if err := setup(fs, o); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	dir, err := o.storeDirectory()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	removed, err := (&embedmd.Store{Dir: dir}).GC(*maxAge, *maxSize<<20)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "removed %d bytes from %s\n", removed, dir)
+	return 0
+}
+
+func storeUsage() {
+	fmt.Fprintf(os.Stderr, `usage: embedmd store gc [flags]
+
+Removes the content not used for a while from the store of remote content,
+then the least recently used until it's small enough.
+`)
+}
@@ -0,0 +1,54 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunStore(t *testing.T) {
+	dir := t.TempDir()
+	blob := filepath.Join(dir, "store", "blobs", "ab", "abcd")
+	if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(blob, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, configFile)
+	if err := os.WriteFile(config, []byte("version: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	var out bytes.Buffer
+	stdout, stderr = &out, &out
+	store := filepath.Join(dir, "store")
+	if code := runStore([]string{"gc", "-config", config, "-store-dir", store, "-max-size", "0", "-max-age", "1ns"}); code != 0 {
+		t.Fatalf("expected exit code 0; got %d: %s", code, out.String())
+	}
+	if want := "removed 7 bytes from " + store + "\n"; out.String() != want {
+		t.Errorf("expected output %q; got %q", want, out.String())
+	}
+	if _, err := os.Stat(blob); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed; got %v", blob, err)
+	}
+	if code := runStore(nil); code != 2 {
+		t.Errorf("expected exit code 2 without a command; got %d", code)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"io"
+
+	v1 "github.com/seanblong/embedmd/embedmd"
+)
+
+// Process runs the commands of the markdown read from in, writing it to out
+// with the content they embed.
+//
+// Deprecated: use NewProcessor and Processor.Process, which reports bad
+// options when the Processor is created, before any document is processed.
+func Process(out io.Writer, in io.Reader, opts ...Option) error {
+	return v1.Process(out, in, v1Options(opts)...)
+}
+
+// A Result is the outcome of processing a markdown file.
+//
+// Deprecated: Processor.ProcessFile reports whether the file changed.
+type Result struct {
+	Changed bool
+}
+
+// ProcessFile processes the document at path and rewrites it if its content
+// changed, unless WithDryRun is set.
+//
+// Deprecated: use NewProcessor and Processor.ProcessFile.
+func ProcessFile(path string, opts ...Option) (Result, error) {
+	r, err := v1.ProcessFile(path, v1Options(opts)...)
+	return Result{Changed: r.Changed}, err
+}
@@ -0,0 +1,99 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package embedmd is the stable API of embedmd, for programs embedding code
+// in markdown documents, such as static site generators and doc linters.
+//
+// Unlike the github.com/seanblong/embedmd/embedmd package, which follows the
+// command and changes with it, this package follows semantic versioning: the
+// types, functions, and interfaces it exports, and their behavior, only
+// change in backward compatible ways within v2. Interfaces implemented by
+// programs, such as Fetcher, Selector, Transform, and Renderer, never get new
+// methods, and new behavior is added with new options.
+//
+// Names deprecated in v2 are kept until v3, with a Deprecated comment telling
+// what replaces them.
+package embedmd
+
+import (
+	"io"
+
+	v1 "github.com/seanblong/embedmd/embedmd"
+)
+
+// A Processor embeds code in markdown documents with the options it was
+// created with. It can be used by several goroutines at once.
+type Processor interface {
+	// Process runs the commands of the markdown read from in, writing it
+	// to out with the content they embed, relative paths being resolved
+	// from the base directory set with WithBaseDir, if any.
+	Process(out io.Writer, in io.Reader) error
+	// ProcessFile processes the document at path, resolving relative paths
+	// from its directory, and rewrites it if its content changed, unless
+	// WithDryRun is set. It reports whether the content changed.
+	ProcessFile(path string) (changed bool, err error)
+}
+
+// NewProcessor returns a Processor with the given options, failing if they
+// are invalid.
+func NewProcessor(opts ...Option) (Processor, error) {
+	p, err := v1.NewProcessor(v1Options(opts)...)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// An Option configures a Processor.
+type Option struct{ opts []v1.Option }
+
+// v1Options returns the options of the embedder implementing opts.
+func v1Options(opts []Option) []v1.Option {
+	var out []v1.Option
+	for _, o := range opts {
+		out = append(out, o.opts...)
+	}
+	return out
+}
+
+// WithFetcher fetches the sources of the commands with f, instead of reading
+// files from the disk and URLs with http.DefaultClient.
+func WithFetcher(f Fetcher) Option {
+	return Option{[]v1.Option{v1.WithFetcher(f)}}
+}
+
+// WithBaseDir resolves the relative paths of the sources from dir, instead of
+// the current directory. ProcessFile resolves them from the directory of the
+// document.
+func WithBaseDir(dir string) Option {
+	return Option{[]v1.Option{v1.WithBaseDir(dir)}}
+}
+
+// WithFence writes code blocks between the given fence, such as ~~~, instead
+// of ```.
+func WithFence(fence string) Option {
+	return Option{[]v1.Option{v1.WithFence(fence)}}
+}
+
+// WithDryRun runs the commands, reporting their errors and warnings, without
+// changing the markdown: Process writes it as it was read, and ProcessFile
+// reports whether the document would change without rewriting it.
+func WithDryRun() Option {
+	return Option{[]v1.Option{v1.WithDryRun()}}
+}
+
+// WithWarnings calls f with the line of the command and the message of each
+// warning, such as a URL returning an HTML page instead of code.
+func WithWarnings(f func(line int, msg string)) Option {
+	return Option{[]v1.Option{v1.WithWarnings(f)}}
+}
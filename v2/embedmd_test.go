@@ -0,0 +1,146 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestProcessor(t *testing.T) {
+	files := fstest.MapFS{
+		"code.go": {Data: []byte("package main\n\n// START\nfunc main() {\n\tprintln(\"hi\")\n}\n// END\n")},
+	}
+	between := SelectorFunc(func(src []byte, arg string) ([]byte, error) {
+		start, end, _ := strings.Cut(arg, ",")
+		_, rest, ok := bytes.Cut(src, []byte(start+"\n"))
+		if !ok {
+			return nil, fmt.Errorf("%s not found", start)
+		}
+		b, _, _ := bytes.Cut(rest, []byte("// "+end))
+		return b, nil
+	})
+	shout := TransformFunc(func(b []byte, _ string) ([]byte, error) { return bytes.ToUpper(b), nil })
+	figure := RendererFunc(func(b Block) ([]byte, error) {
+		return fmt.Appendf(nil, "<pre lang=%q title=%q>\n%s</pre>\n", b.Lang, b.Path, b.Content), nil
+	})
+
+	tc := []struct {
+		name string
+		opts []Option
+		in   string
+		out  string
+		err  string
+	}{
+		{
+			name: "fence",
+			opts: []Option{WithFence("~~~")},
+			in:   "[embedmd]:# (code.go /func/ /^}/)\n",
+			out:  "[embedmd]:# (code.go /func/ /^}/)\n~~~go\nfunc main() {\n\tprintln(\"hi\")\n}\n~~~\n",
+		},
+		{
+			name: "selector and transform",
+			opts: []Option{WithSelector("between", between), WithTransform("shout", shout)},
+			in:   "[embedmd]:# (code.go select=between=START,END transform=shout)\n",
+			out:  "[embedmd]:# (code.go select=between=START,END transform=shout)\n```go\nFUNC MAIN() {\n\tPRINTLN(\"HI\")\n}\n```\n",
+		},
+		{
+			name: "renderer",
+			opts: []Option{WithRenderer(figure)},
+			in:   "[embedmd]:# (code.go /func/ /^}/)\n",
+			out:  "[embedmd]:# (code.go /func/ /^}/)\n<!-- embedmd block start -->\n<pre lang=\"go\" title=\"code.go\">\nfunc main() {\n\tprintln(\"hi\")\n}\n</pre>\n<!-- embedmd block end -->\n",
+		},
+		{
+			name: "dry run",
+			opts: []Option{WithDryRun()},
+			in:   "[embedmd]:# (code.go /func/ /^}/)\n",
+			out:  "[embedmd]:# (code.go /func/ /^}/)\n",
+		},
+		{
+			name: "missing file",
+			in:   "[embedmd]:# (other.go)\n",
+			err:  "1: could not read other.go: ",
+		},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProcessor(append(tt.opts, WithFetcher(NewFSFetcher(files)))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			err = p.Process(&out, strings.NewReader(tt.in))
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Fatalf("expected error starting with %q; got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.out {
+				t.Errorf("expected output\n%q\ngot\n%q", tt.out, out.String())
+			}
+		})
+	}
+}
+
+func TestProcessorFile(t *testing.T) {
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "README.md")
+	if err := os.WriteFile(filepath.Join(dir, "code.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(doc, []byte("[embedmd]:# (code.go)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewProcessor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, false} {
+		changed, err := p.ProcessFile(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != want {
+			t.Errorf("run %d: expected changed %v; got %v", i+1, want, changed)
+		}
+	}
+	b, err := os.ReadFile(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[embedmd]:# (code.go)\n```go\npackage main\n```\n"; string(b) != want {
+		t.Errorf("expected %q; got %q", want, b)
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	files := fstest.MapFS{"code.go": {Data: []byte("package main\n")}}
+	var out bytes.Buffer
+	if err := Process(&out, strings.NewReader("[embedmd]:# (code.go)\n"), WithFetcher(NewFSFetcher(files))); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[embedmd]:# (code.go)\n```go\npackage main\n```\n"; out.String() != want {
+		t.Errorf("expected %q; got %q", want, out.String())
+	}
+}
@@ -0,0 +1,11 @@
+module github.com/seanblong/embedmd/v2
+
+go 1.23.4
+
+require github.com/seanblong/embedmd v0.0.0
+
+// The v2 API builds on the embedmd package of this repository, which has no
+// tagged release including it yet.
+replace github.com/seanblong/embedmd => ../
+
+require github.com/pmezard/go-difflib v1.0.0 // indirect
@@ -0,0 +1,114 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedmd
+
+import (
+	"io/fs"
+	"net/http"
+
+	v1 "github.com/seanblong/embedmd/embedmd"
+)
+
+// A Fetcher fetches the sources of the commands: files, whose relative paths
+// are resolved from dir, and URLs.
+type Fetcher interface {
+	Fetch(dir, path string) ([]byte, error)
+}
+
+// FetcherFunc is an adapter to use ordinary functions as Fetchers.
+type FetcherFunc func(dir, path string) ([]byte, error)
+
+// Fetch calls f(dir, path).
+func (f FetcherFunc) Fetch(dir, path string) ([]byte, error) { return f(dir, path) }
+
+// NewFetcher returns the Fetcher reading files from the disk and URLs with
+// client, or http.DefaultClient if nil, decoding remote content to UTF-8.
+func NewFetcher(client *http.Client) Fetcher {
+	return v1.NewFetcher(client)
+}
+
+// NewFSFetcher returns the Fetcher reading files from fsys, such as an
+// embed.FS, and failing to fetch URLs.
+func NewFSFetcher(fsys fs.FS) Fetcher {
+	return v1.NewFSFetcher(fsys)
+}
+
+// A Selector chooses the part of a source embedded by the commands naming it
+// in their select attribute, as select=name or select=name=arg, instead of
+// regular expressions, a line range, a tag, or a symbol. It's given the
+// whole source, with \n line endings.
+type Selector interface {
+	Select(src []byte, arg string) ([]byte, error)
+}
+
+// SelectorFunc is an adapter to use ordinary functions as Selectors.
+type SelectorFunc func(src []byte, arg string) ([]byte, error)
+
+// Select calls f(src, arg).
+func (f SelectorFunc) Select(src []byte, arg string) ([]byte, error) { return f(src, arg) }
+
+// WithSelector registers s as the selector with the given name.
+func WithSelector(name string, s Selector) Option {
+	return Option{[]v1.Option{v1.WithSelector(name, s)}}
+}
+
+// A Transform rewrites the content embedded by the commands naming it in
+// their transform attribute, as transform=name or transform=name=arg, before
+// it's rendered. Transforms listed together, separated by commas, are
+// applied in order.
+type Transform interface {
+	Transform(b []byte, arg string) ([]byte, error)
+}
+
+// TransformFunc is an adapter to use ordinary functions as Transforms.
+type TransformFunc func(b []byte, arg string) ([]byte, error)
+
+// Transform calls f(b, arg).
+func (f TransformFunc) Transform(b []byte, arg string) ([]byte, error) { return f(b, arg) }
+
+// WithTransform registers t as the transform with the given name, replacing
+// the built-in one with that name, if any.
+func WithTransform(name string, t Transform) Option {
+	return Option{[]v1.Option{v1.WithTransform(name, t)}}
+}
+
+// A Block is the content embedded by a command, given to Renderers.
+type Block struct {
+	// Path is the path or URL of the source, and Lang the language of the
+	// content.
+	Path, Lang string
+	// Content is the content embedded, ending with a newline.
+	Content []byte
+}
+
+// A Renderer writes the content embedded by commands instead of a fenced
+// code block, e.g. as an HTML figure. Its output is written between markers
+// so it's replaced when the document is processed again.
+type Renderer interface {
+	Render(b Block) ([]byte, error)
+}
+
+// RendererFunc is an adapter to use ordinary functions as Renderers.
+type RendererFunc func(b Block) ([]byte, error)
+
+// Render calls f(b).
+func (f RendererFunc) Render(b Block) ([]byte, error) { return f(b) }
+
+// WithRenderer renders with r the content of the commands that would
+// otherwise be written as fenced code blocks.
+func WithRenderer(r Renderer) Option {
+	return Option{[]v1.Option{v1.WithRenderer(v1.RendererFunc(func(e *v1.Embed) ([]byte, error) {
+		return r.Render(Block{Path: e.Path, Lang: e.Lang, Content: e.Content})
+	}))}}
+}
@@ -0,0 +1,316 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// runVerifySite implements the verify-site command, checking that the pages
+// published from the given markdown files, or those found in the given
+// directories, show the code their sources embed today.
+func runVerifySite(args []string) int {
+	fs := flag.NewFlagSet("embedmd verify-site", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd verify-site -base-url url [flags] [path ...]\n")
+		fs.PrintDefaults()
+	}
+	baseURL := fs.String("base-url", "", "URL the site is published at")
+	siteRoot := fs.String("site-root", ".", "directory of the markdown files published at the base URL")
+	pageExt := fs.String("page-ext", ".html", "replaces the .md extension of the files in the URLs of their pages, / for pretty URLs such as /usage/")
+	o := newCommandFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := setup(fs, o); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *baseURL == "" {
+		fmt.Fprintln(stderr, "error: missing -base-url")
+		return 2
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{*siteRoot}
+	}
+
+	docs, err := markdownFiles(paths)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	opts, err := o.embedOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	client, err := o.network().Client()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	creds, err := o.credentialProvider()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	results := make([]pageResult, len(docs))
+	var wg sync.WaitGroup
+	sem := make(chan bool, pingParallelism)
+	for i, doc := range docs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- true
+			defer func() { <-sem }()
+			results[i] = verifyPage(client, creds, doc, pageURL(*baseURL, *siteRoot, *pageExt, doc), opts...)
+		}()
+	}
+	wg.Wait()
+	return reportPages(results)
+}
+
+// pageURL returns the URL of the page published from the markdown file doc,
+// found in the site root.
+func pageURL(base, root, ext, doc string) string {
+	rel, err := filepath.Rel(root, doc)
+	if err != nil {
+		rel = doc
+	}
+	p := strings.TrimSuffix(filepath.ToSlash(rel), ".md")
+	if ext == "/" {
+		// Index pages are published at the URL of their directory.
+		if name := p[strings.LastIndex(p, "/")+1:]; name == "index" || strings.EqualFold(name, "readme") {
+			p = strings.TrimSuffix(p, name)
+		} else {
+			p += "/"
+		}
+	} else {
+		p += ext
+	}
+	segs := strings.Split(p, "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.Join(segs, "/")
+}
+
+// pageResult is the outcome of verifying the page of a markdown file.
+type pageResult struct {
+	doc, url string
+	// stale lists the blocks the page doesn't show as they are embedded now.
+	stale []staleOnPage
+	err   error
+}
+
+// staleOnPage is a block out of date on a published page.
+type staleOnPage struct {
+	line int
+	path string
+}
+
+// expectedBlock is code the page of a markdown file should show.
+type expectedBlock struct {
+	source embedmd.Source
+	code   string
+	// checksum is the checksum recorded after the block, with -checksums.
+	checksum string
+}
+
+// verifyPage checks that the page at link, published from doc, shows the
+// code the commands of doc embed.
+func verifyPage(client *http.Client, creds embedmd.CredentialProvider, doc, link string, opts ...embedmd.Option) pageResult {
+	r := pageResult{doc: doc, url: link}
+	blocks, err := expectedBlocks(doc, opts...)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	if len(blocks) == 0 {
+		return r
+	}
+	page, err := fetchPage(client, creds, link)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	published := pageChecksums(page)
+	text := normalizeCode(pageText(page))
+	for _, b := range blocks {
+		ok := strings.Contains(text, normalizeCode(b.code))
+		// Checksums, when the site keeps the comments recording them,
+		// tell blocks apart exactly.
+		if b.checksum != "" && len(published) > 0 {
+			ok = published[b.checksum]
+		}
+		if !ok {
+			r.stale = append(r.stale, staleOnPage{b.source.Line, b.source.Path})
+		}
+	}
+	return r
+}
+
+// expectedBlocks returns the code embedded in doc by its commands, as they
+// would embed it now.
+func expectedBlocks(doc string, opts ...embedmd.Option) ([]expectedBlock, error) {
+	in, err := os.ReadFile(doc)
+	if err != nil {
+		return nil, err
+	}
+	var regions []embedmd.MappedRegion
+	opts = append([]embedmd.Option{embedmd.WithBaseDir(filepath.Dir(doc)), warnings(doc)}, opts...)
+	opts = append(opts, embedmd.WithSourceMap(func(r embedmd.MappedRegion) { regions = append(regions, r) }))
+	var out bytes.Buffer
+	if err := embedmd.Process(&out, bytes.NewReader(in), opts...); err != nil {
+		return nil, fmt.Errorf("%s:%v", filepath.ToSlash(doc), err)
+	}
+	lines := strings.SplitAfter(out.String(), "\n")
+	var blocks []expectedBlock
+	code := false
+	for i, r := range regions {
+		// Content embedded without a fence, with the language none, is
+		// rendered as markdown, so it can't be found in the page. Stacked
+		// regions are in the block of the previous one.
+		if i == 0 || regions[i-1].OutEnd+1 != r.OutStart {
+			code = r.OutStart > 1 && openingFence(lines[r.OutStart-2]) || strings.HasPrefix(lines[r.OutStart-1], "    ")
+		}
+		if !code {
+			continue
+		}
+		b := expectedBlock{source: r.Source, code: strings.Join(lines[r.OutStart-1:r.OutEnd], "")}
+		// The checksum follows the last region of the block, after its
+		// closing fence or marker.
+		last := i == len(regions)-1 || regions[i+1].OutStart > r.OutEnd+1
+		for _, l := range lines[r.OutEnd:min(r.OutEnd+3, len(lines))] {
+			if sum, ok := cutChecksum(l); ok && last {
+				b.checksum = sum
+				break
+			}
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks, nil
+}
+
+// openingFence reports whether the line opens a fenced code block.
+func openingFence(line string) bool {
+	line = strings.TrimLeft(line, " ")
+	return strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~")
+}
+
+// checksumComment matches the comments recording the checksums of blocks.
+var checksumComment = regexp.MustCompile(`<!-- embedmd checksum ([0-9a-f]+) -->`)
+
+// cutChecksum returns the checksum recorded by the line, if any.
+func cutChecksum(line string) (string, bool) {
+	m := checksumComment.FindStringSubmatch(line)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// pageChecksums returns the checksums of the blocks recorded in the page.
+func pageChecksums(page []byte) map[string]bool {
+	sums := map[string]bool{}
+	for _, m := range checksumComment.FindAllSubmatch(page, -1) {
+		sums[string(m[1])] = true
+	}
+	return sums
+}
+
+// fetchPage returns the content of the page at link.
+func fetchPage(client *http.Client, creds embedmd.CredentialProvider, link string) ([]byte, error) {
+	req, err := http.NewRequest("GET", link, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := creds.Credential(req.URL.Hostname()); ok {
+		c.Set(req.Header)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
+
+var (
+	htmlComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlTag     = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankRuns   = regexp.MustCompile(`\s+`)
+)
+
+// pageText returns the text of the page, without its tags, such as those
+// of syntax highlighting.
+func pageText(page []byte) string {
+	s := htmlComment.ReplaceAllString(string(page), "")
+	return html.UnescapeString(htmlTag.ReplaceAllString(s, ""))
+}
+
+// normalizeCode collapses the blanks of code, which renderers and syntax
+// highlighters don't all keep as is.
+func normalizeCode(s string) string {
+	return strings.TrimSpace(blankRuns.ReplaceAllString(s, " "))
+}
+
+// reportPages prints the stale blocks of each page, returning the exit code
+// of the command: 1 if a page couldn't be verified, 2 if any is stale.
+func reportPages(results []pageResult) int {
+	failed, stale := 0, 0
+	for _, r := range results {
+		doc := filepath.ToSlash(r.doc)
+		if r.err != nil {
+			failed++
+			fmt.Fprintf(stderr, "%s: could not verify %s: %v\n", doc, r.url, r.err)
+			continue
+		}
+		if len(r.stale) > 0 {
+			stale++
+		}
+		for _, s := range r.stale {
+			fmt.Fprintf(stdout, "%s:%d: %s is out of date on %s\n", doc, s.line, s.path, r.url)
+		}
+	}
+	fmt.Fprintf(stdout, "%d pages verified, %d out of date, %d failed\n", len(results)-failed, stale, failed)
+	switch {
+	case failed > 0:
+		return 1
+	case stale > 0:
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,114 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunVerifySite(t *testing.T) {
+	pages := map[string]string{
+		// Highlighting splits the code in spans and escapes it.
+		"/fresh.html": `<pre><code><span class="kw">func</span> main() {` + "\n\t" + `println(&quot;v2&quot;)` + "\n}\n</code></pre>",
+		"/stale.html": "<pre><code>func main() {\n\tprintln(\"v1\")\n}\n</code></pre>",
+		// The checksum of the block, kept as a comment, is the one of v1.
+		"/sums/checked/": "<pre><code>func main() {\n\tprintln(\"v2\")\n}\n</code></pre>\n<!-- embedmd checksum 000000000000 -->\n",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, page)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"code.go":              "package main\n\nfunc main() {\n\tprintln(\"v2\")\n}\n",
+		"site/fresh.md":        "[embedmd]:# (../code.go /func main/ $)\n",
+		"site/stale.md":        "# Stale\n\n[embedmd]:# (../code.go /func main/ $)\n",
+		"site/sums/checked.md": "[embedmd]:# (../../code.go /func main/ $)\n",
+		"site/missing.md":      "[embedmd]:# (../code.go)\n",
+		"site/text.md":         "No commands, so the page isn't fetched.\n",
+		configFile:             "version: 1\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	var out bytes.Buffer
+	stdout, stderr = &out, &out
+	site := filepath.Join(dir, "site")
+	code := runVerifySite([]string{"-config", filepath.Join(dir, configFile), "-base-url", server.URL, "-site-root", site, "-checksums", "-no-cache",
+		filepath.Join(site, "fresh.md"), filepath.Join(site, "stale.md"), filepath.Join(site, "missing.md"), filepath.Join(site, "text.md")})
+	if code != 1 {
+		t.Errorf("expected exit code 1 for the missing page; got %d", code)
+	}
+	got := strings.ReplaceAll(out.String(), filepath.ToSlash(site)+"/", "")
+	for _, want := range []string{
+		"stale.md:3: ../code.go is out of date on " + server.URL + "/stale.html\n",
+		"missing.md: could not verify " + server.URL + "/missing.html: status 404 Not Found\n",
+		"3 pages verified, 1 out of date, 1 failed\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q; got\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "fresh.md:") {
+		t.Errorf("expected fresh.md to be up to date; got\n%s", got)
+	}
+
+	out.Reset()
+	code = runVerifySite([]string{"-config", filepath.Join(dir, configFile), "-base-url", server.URL, "-site-root", site, "-page-ext", "/", "-checksums", "-no-cache",
+		filepath.Join(site, "sums")})
+	if code != 2 {
+		t.Errorf("expected exit code 2 for the stale checksum; got %d\n%s", code, out.String())
+	}
+	if got := out.String(); !strings.Contains(got, "checked.md:1: ../../code.go is out of date on "+server.URL+"/sums/checked/\n") {
+		t.Errorf("expected the checksum to be out of date; got\n%s", got)
+	}
+}
+
+func TestPageURL(t *testing.T) {
+	tc := []struct {
+		ext, doc, want string
+	}{
+		{".html", "docs/usage.md", "https://docs.example.com/usage.html"},
+		{"/", "docs/guide/usage.md", "https://docs.example.com/guide/usage/"},
+		{"/", "docs/guide/index.md", "https://docs.example.com/guide/"},
+		{"/", "docs/README.md", "https://docs.example.com/"},
+		{".html", "docs/a b.md", "https://docs.example.com/a%20b.html"},
+	}
+	for _, tt := range tc {
+		if got := pageURL("https://docs.example.com/", "docs", tt.ext, filepath.FromSlash(tt.doc)); got != tt.want {
+			t.Errorf("page of %s with %q: expected %s; got %s", tt.doc, tt.ext, tt.want, got)
+		}
+	}
+}
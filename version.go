@@ -0,0 +1,135 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// experimental lists the flags whose behavior may still change. A warning is
+// printed when they are set in a project declaring the embedmd version it
+// requires, since different versions may produce different results.
+var experimental = map[string]bool{
+	"aria-labels":       true,
+	"copy-buttons":      true,
+	"runnable-commands": true,
+	"lint-a11y":         true,
+	"lint-anchors":      true,
+	"defaults":          true,
+	"alias":             true,
+}
+
+// parseVersion parses versions like v1.2.3, ignoring pre-release and build
+// suffixes. Missing minor and patch numbers are zero.
+func parseVersion(v string) ([3]int, error) {
+	var parsed [3]int
+	s := strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return parsed, fmt.Errorf("bad version %q", v)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return parsed, fmt.Errorf("bad version %q", v)
+		}
+		parsed[i] = n
+	}
+	return parsed, nil
+}
+
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionConstraint is a comma separated list of comparisons, all of which
+// must be satisfied, e.g. ">=2.3, <3".
+type versionConstraint []struct {
+	op string
+	v  [3]int
+}
+
+func parseConstraint(s string) (versionConstraint, error) {
+	var c versionConstraint
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		op := ""
+		for _, o := range []string{">=", "<=", "==", ">", "<", "="} {
+			if strings.HasPrefix(part, o) {
+				op = o
+				break
+			}
+		}
+		v, err := parseVersion(part[len(op):])
+		if err != nil {
+			return nil, err
+		}
+		if op == "" || op == "==" {
+			op = "="
+		}
+		c = append(c, struct {
+			op string
+			v  [3]int
+		}{op, v})
+	}
+	return c, nil
+}
+
+func (c versionConstraint) allows(v [3]int) bool {
+	for _, cmp := range c {
+		r := compareVersions(v, cmp.v)
+		ok := map[string]bool{
+			">=": r >= 0, "<=": r <= 0, ">": r > 0, "<": r < 0, "=": r == 0,
+		}[cmp.op]
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// checkRequires returns an error if the version of embedmd doesn't satisfy
+// the given constraint. Development builds, whose version is unknown, are
+// always accepted.
+func checkRequires(n *yamlNode) error {
+	if n.kind != yamlScalar {
+		return errorAt(n, "requires should be a version constraint, found a %v", n.kind)
+	}
+	c, err := parseConstraint(n.value)
+	if err != nil {
+		return errorAt(n, "requires: %v", err)
+	}
+	v, err := parseVersion(version)
+	if err != nil {
+		return nil
+	}
+	if !c.allows(v) {
+		return errorAt(n, "this project requires embedmd %s, but this is version %s; "+
+			"upgrade with: go install github.com/seanblong/embedmd@latest", n.value, version)
+	}
+	return nil
+}
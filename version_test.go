@@ -0,0 +1,94 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestVersionConstraints(t *testing.T) {
+	tc := []struct {
+		constraint, version string
+		allowed             bool
+	}{
+		{">=2.3", "2.3.0", true},
+		{">=2.3", "v2.10.1", true},
+		{">=2.3", "2.2.9", false},
+		{">=2.3, <3", "3.0.0", false},
+		{">=2.3, <3", "2.9.0-rc1", true},
+		{"1.2.3", "1.2.3", true},
+		{"=1.2", "1.2.1", false},
+		{">1", "1.0.1", true},
+		{"<=1", "1.0.0", true},
+	}
+	for _, tt := range tc {
+		c, err := parseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("parsing %q: %v", tt.constraint, err)
+		}
+		v, err := parseVersion(tt.version)
+		if err != nil {
+			t.Fatalf("parsing %q: %v", tt.version, err)
+		}
+		if got := c.allows(v); got != tt.allowed {
+			t.Errorf("%q allows %q = %v; want %v", tt.constraint, tt.version, got, tt.allowed)
+		}
+	}
+
+	if _, err := parseConstraint(">=two"); err == nil {
+		t.Errorf("expected an error for a bad constraint")
+	}
+}
+
+func TestConfigRequires(t *testing.T) {
+	defer func(v string) { version = v }(version)
+
+	tc := []struct {
+		name, version, doc, err string
+	}{
+		{name: "satisfied", version: "v2.4.0", doc: "version: 1\nrequires: \">=2.3\"\n"},
+		{name: "development build", version: "unknown", doc: "version: 1\nrequires: \">=2.3\"\n"},
+		{name: "too old", version: "v2.2.0", doc: "version: 1\nrequires: \">=2.3\"\n",
+			err: "2:11: this project requires embedmd >=2.3, but this is version v2.2.0; " +
+				"upgrade with: go install github.com/seanblong/embedmd@latest"},
+		{name: "bad constraint", version: "v2.4.0", doc: "version: 1\nrequires: latest\n",
+			err: `2:11: requires: bad version "latest"`},
+	}
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			version = tt.version
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			newFlags(fs)
+			_, err := parseConfig(fs, tt.doc)
+			eqErr(t, tt.name, err, tt.err)
+		})
+	}
+}
+
+func TestWarnExperimental(t *testing.T) {
+	defer func(e io.Writer) { stderr = e }(stderr)
+	var buf bytes.Buffer
+	stderr = &buf
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	newFlags(fs)
+	warnExperimental(fs, map[string]string{"alias": "flag", "color": "flag"})
+	if got := buf.String(); !strings.Contains(got, "-alias (set by flag) is experimental") || strings.Contains(got, "color") {
+		t.Errorf("unexpected warnings %q", got)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// renderVersions renders the markdown files for each version, embedding the
+// local files as they are at its git revision and fetching URLs with f, into
+// the directory of dir named after the version, at the same relative paths.
+func renderVersions(dir string, paths []string, versions []embedmd.Version, f embedmd.Fetcher, opts ...embedmd.Option) error {
+	if len(versions) == 0 {
+		return fmt.Errorf("error: -versions-out needs the versions set with -doc-version")
+	}
+	for _, path := range paths {
+		if !filepath.IsLocal(path) {
+			return fmt.Errorf("error: -versions-out: %s is outside of the current directory", filepath.ToSlash(path))
+		}
+	}
+	for _, v := range versions {
+		vopts := append(opts[:len(opts):len(opts)], embedmd.WithFetcher(refFetcher(v.Ref, f)))
+		for _, path := range paths {
+			if err := renderVersion(filepath.Join(dir, v.Name, path), path, v.Name, vopts...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// renderVersion renders the markdown file at path for the named version into
+// the file at out.
+func renderVersion(out, path, version string, opts ...embedmd.Option) error {
+	b, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	opts = append([]embedmd.Option{embedmd.WithBaseDir(filepath.Dir(path)), embedmd.WithFormat(embedmd.FormatOf(path)), warnings(path)}, opts...)
+	var rendered bytes.Buffer
+	if err := embedmd.Process(&rendered, bytes.NewReader(b), opts...); err != nil {
+		return fmt.Errorf("%s (version %s):%v", filepath.ToSlash(path), version, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(out, rendered.Bytes(), 0644)
+}
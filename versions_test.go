@@ -0,0 +1,84 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+func TestRenderVersions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	write("a.go", "func A() {}\n")
+	write("docs/a.md", "[embedmd]:# (../a.go)\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "v1")
+	git("tag", "v1.0.0")
+	write("a.go", "func A(n int) {}\n")
+	git("commit", "-q", "-am", "v2")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	versions := []embedmd.Version{{Name: "v1", Ref: "v1.0.0"}, {Name: "main", Ref: "HEAD"}}
+	if err := renderVersions("site", []string{"docs/a.md"}, versions, embedmd.NewFetcher(nil)); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"site/v1/docs/a.md":   "[embedmd]:# (../a.go)\n```go\nfunc A() {}\n```\n",
+		"site/main/docs/a.md": "[embedmd]:# (../a.go)\n```go\nfunc A(n int) {}\n```\n",
+	} {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("expected %s\n%s\ngot\n%s", path, want, b)
+		}
+	}
+
+	if err := renderVersions("site", []string{"../a.md"}, versions, embedmd.NewFetcher(nil)); err == nil {
+		t.Errorf("expected an error rendering a file outside of the current directory")
+	}
+}
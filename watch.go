@@ -0,0 +1,205 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// pollInterval is how often -watch checks whether the files it watches
+// changed.
+var pollInterval = 100 * time.Millisecond
+
+// watcher embeds again the documents embedding the local files that change,
+// and the documents edited, once no file changed for the debounce duration.
+type watcher struct {
+	docs     []string
+	debounce time.Duration
+	opts     []embedmd.Option
+	// dependents maps each local source to the documents embedding it.
+	dependents map[string][]string
+	// stamps are the last seen states of the watched files.
+	stamps map[string]fileStamp
+}
+
+// fileStamp is the state of a watched file, zero if it doesn't exist.
+type fileStamp struct {
+	mod  time.Time
+	size int64
+}
+
+// watch embeds again the given documents when the local files they embed,
+// or the documents themselves, change, until interrupted.
+func watch(docs []string, debounce time.Duration, opts ...embedmd.Option) error {
+	w := newWatcher(docs, debounce, opts...)
+	fmt.Fprintf(stderr, "watching %d files embedded in %d documents, interrupt to stop\n", len(w.dependents), len(docs))
+	w.run()
+	return nil
+}
+
+// newWatcher returns a watcher of the given documents and their sources.
+func newWatcher(docs []string, debounce time.Duration, opts ...embedmd.Option) *watcher {
+	w := &watcher{docs: docs, debounce: debounce, opts: opts, dependents: map[string][]string{}, stamps: map[string]fileStamp{}}
+	for _, doc := range docs {
+		w.index(doc)
+		w.stamps[doc] = stampOf(doc)
+	}
+	return w
+}
+
+// run waits for changes and embeds again the documents they affect, until
+// interrupted.
+func (w *watcher) run() {
+	pending := map[string]bool{}
+	var last time.Time
+	for !interrupted.Load() {
+		time.Sleep(pollInterval)
+		if changed := w.changed(); len(changed) > 0 {
+			for _, f := range changed {
+				pending[f] = true
+			}
+			last = time.Now()
+		}
+		if len(pending) > 0 && time.Since(last) >= w.debounce {
+			var changed []string
+			for f := range pending {
+				changed = append(changed, f)
+			}
+			slices.Sort(changed)
+			w.update(changed)
+			clear(pending)
+		}
+	}
+}
+
+// index records the local sources embedded by the document, replacing
+// those recorded before. Documents that can't be read or parsed embed
+// nothing until fixed, which processing them reports.
+func (w *watcher) index(doc string) {
+	for src, docs := range w.dependents {
+		if docs = slices.DeleteFunc(docs, func(d string) bool { return d == doc }); len(docs) == 0 {
+			delete(w.dependents, src)
+		} else {
+			w.dependents[src] = docs
+		}
+	}
+	b, err := readFile(doc)
+	if err != nil {
+		return
+	}
+	opts := append([]embedmd.Option{embedmd.WithBaseDir(filepath.Dir(doc)), embedmd.WithFormat(embedmd.FormatOf(doc))}, w.opts...)
+	sources, err := embedmd.Sources(bytes.NewReader(b), opts...)
+	if err != nil {
+		return
+	}
+	for _, s := range sources {
+		if strings.HasPrefix(s.Path, "http://") || strings.HasPrefix(s.Path, "https://") {
+			continue
+		}
+		src := filepath.Join(filepath.Dir(doc), filepath.FromSlash(s.Path))
+		if !slices.Contains(w.dependents[src], doc) {
+			w.dependents[src] = append(w.dependents[src], doc)
+		}
+		if _, ok := w.stamps[src]; !ok {
+			w.stamps[src] = stampOf(src)
+		}
+	}
+}
+
+// changed returns the watched files that changed since last seen.
+func (w *watcher) changed() []string {
+	var changed []string
+	for f, old := range w.stamps {
+		_, embedded := w.dependents[f]
+		if !embedded && !slices.Contains(w.docs, f) {
+			// No document embeds it anymore.
+			delete(w.stamps, f)
+			continue
+		}
+		if s := stampOf(f); s != old {
+			w.stamps[f] = s
+			changed = append(changed, f)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// update embeds again the documents affected by the changed files, and
+// prints a line summing up what it did, as in
+// "code.go: rewrote docs/api.md, 1 up to date".
+func (w *watcher) update(changed []string) {
+	var affected []string
+	for _, f := range changed {
+		if slices.Contains(w.docs, f) {
+			affected = append(affected, f)
+		}
+		affected = append(affected, w.dependents[f]...)
+	}
+	slices.Sort(affected)
+	affected = slices.Compact(affected)
+
+	var rewritten []string
+	var upToDate, failed int
+	for _, doc := range affected {
+		before, _ := readFile(doc)
+		if _, err := processFile(doc, true, false, w.opts...); err != nil {
+			fmt.Fprintf(stderr, "%s:%v\n", filepath.ToSlash(doc), err)
+			failed++
+		} else if after, _ := readFile(doc); !bytes.Equal(before, after) {
+			rewritten = append(rewritten, filepath.ToSlash(doc))
+		} else {
+			upToDate++
+		}
+		w.index(doc)
+		// Rewriting the document isn't a change to act on.
+		w.stamps[doc] = stampOf(doc)
+	}
+
+	names := make([]string, len(changed))
+	for i, f := range changed {
+		names[i] = filepath.ToSlash(f)
+	}
+	var parts []string
+	if len(rewritten) > 0 {
+		parts = append(parts, "rewrote "+strings.Join(rewritten, ", "))
+	}
+	if upToDate > 0 {
+		parts = append(parts, fmt.Sprintf("%d up to date", upToDate))
+	}
+	if failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", failed))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "no documents embed it")
+	}
+	fmt.Fprintf(stdout, "%s: %s\n", strings.Join(names, ", "), strings.Join(parts, ", "))
+}
+
+// stampOf returns the state of the file at path.
+func stampOf(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{info.ModTime(), info.Size()}
+}
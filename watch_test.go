@@ -0,0 +1,103 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, mod time.Time) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Modification times could be too coarse to see the change.
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	then := time.Now().Add(-time.Hour)
+	write("code.go", "v1\n", then)
+	write("util.go", "u1\n", then)
+	write("a.md", "[embedmd]:# (code.go)\n", then)
+	write("b.md", "[embedmd]:# (util.go)\n", then)
+	write("c.md", "[embedmd]:# (code.go)\n```go\nv2\n```\n", then)
+
+	var out bytes.Buffer
+	defer func(o, e io.Writer) { stdout, stderr = o, e }(stdout, stderr)
+	stdout, stderr = &out, &out
+
+	docs := []string{filepath.Join(dir, "a.md"), filepath.Join(dir, "b.md"), filepath.Join(dir, "c.md")}
+	w := newWatcher(docs, 0)
+	want := map[string][]string{
+		filepath.Join(dir, "code.go"): {docs[0], docs[2]},
+		filepath.Join(dir, "util.go"): {docs[1]},
+	}
+	if !reflect.DeepEqual(w.dependents, want) {
+		t.Fatalf("expected dependents %v; got %v", want, w.dependents)
+	}
+	if changed := w.changed(); len(changed) > 0 {
+		t.Fatalf("expected no changes; got %v", changed)
+	}
+
+	write("code.go", "v2\n", time.Now())
+	changed := w.changed()
+	if want := []string{filepath.Join(dir, "code.go")}; !reflect.DeepEqual(changed, want) {
+		t.Fatalf("expected changes %v; got %v", want, changed)
+	}
+	w.update(changed)
+	if got, want := out.String(), filepath.ToSlash(filepath.Join(dir, "code.go"))+": rewrote "+filepath.ToSlash(docs[0])+", 1 up to date\n"; got != want {
+		t.Errorf("expected summary %q; got %q", want, got)
+	}
+	if got, want := read("a.md"), "[embedmd]:# (code.go)\n```go\nv2\n```\n"; got != want {
+		t.Errorf("expected a.md\n%s\ngot\n%s", want, got)
+	}
+	if got, want := read("b.md"), "[embedmd]:# (util.go)\n"; got != want {
+		t.Errorf("expected b.md untouched; got\n%s", got)
+	}
+	if changed := w.changed(); len(changed) > 0 {
+		t.Errorf("expected no changes after rewriting; got %v", changed)
+	}
+
+	// Editing a document indexes it again.
+	out.Reset()
+	write("b.md", "[embedmd]:# (code.go)\n", time.Now())
+	w.update(w.changed())
+	if got, want := read("b.md"), "[embedmd]:# (code.go)\n```go\nv2\n```\n"; got != want {
+		t.Errorf("expected b.md\n%s\ngot\n%s", want, got)
+	}
+	if _, ok := w.dependents[filepath.Join(dir, "util.go")]; ok {
+		t.Errorf("expected util.go not to be watched anymore")
+	}
+	if got := w.dependents[filepath.Join(dir, "code.go")]; len(got) != 3 {
+		t.Errorf("expected code.go embedded by 3 documents; got %v", got)
+	}
+}
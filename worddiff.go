@@ -0,0 +1,154 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// wordDiff rewrites a unified diff so each group of removed lines directly
+// followed by added lines is shown as a single group of lines prefixed by ~,
+// where removed words are marked as [-word-] and added words as {+word+}.
+// When color is set, ANSI colors are used instead of the markers.
+func wordDiff(unified string, color bool) string {
+	lines := strings.SplitAfter(unified, "\n")
+	var b strings.Builder
+	for i := 0; i < len(lines); {
+		del := run(lines[i:], '-')
+		add := run(lines[i+len(del):], '+')
+		switch {
+		case len(del) > 0 && len(add) > 0:
+			b.WriteString(mergeWords(del, add, color))
+			i += len(del) + len(add)
+		case len(del) > 0:
+			b.WriteString(strings.Join(del, ""))
+			i += len(del)
+		default:
+			b.WriteString(lines[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// run returns the prefix of lines starting with the given diff marker.
+func run(lines []string, marker byte) []string {
+	n := 0
+	for n < len(lines) && len(lines[n]) > 0 && lines[n][0] == marker {
+		n++
+	}
+	return lines[:n]
+}
+
+func mergeWords(del, add []string, color bool) string {
+	trim := func(lines []string) string {
+		var b strings.Builder
+		for _, l := range lines {
+			b.WriteString(l[1:])
+		}
+		return b.String()
+	}
+	a, b := splitWords(trim(del)), splitWords(trim(add))
+
+	open, close := [2]string{"[-", "{+"}, [2]string{"-]", "+}"}
+	if color {
+		open, close = [2]string{ansiRed, ansiGreen}, [2]string{ansiReset, ansiReset}
+	}
+
+	var out strings.Builder
+	m := difflib.NewMatcherWithJunk(a, b, false, nil)
+	for _, op := range m.GetOpCodes() {
+		if op.Tag == 'e' {
+			out.WriteString(strings.Join(a[op.I1:op.I2], ""))
+			continue
+		}
+		if op.Tag == 'r' || op.Tag == 'd' {
+			out.WriteString(mark(a[op.I1:op.I2], open[0], close[0]))
+		}
+		if op.Tag == 'r' || op.Tag == 'i' {
+			out.WriteString(mark(b[op.J1:op.J2], open[1], close[1]))
+		}
+	}
+
+	merged := strings.SplitAfter(out.String(), "\n")
+	if merged[len(merged)-1] == "" {
+		merged = merged[:len(merged)-1]
+	}
+	for i := range merged {
+		merged[i] = "~" + merged[i]
+	}
+	return strings.Join(merged, "")
+}
+
+// mark surrounds the given words with open and close, keeping line breaks
+// outside of the markers so every output line is balanced.
+func mark(words []string, open, close string) string {
+	var b strings.Builder
+	for i, line := range strings.SplitAfter(strings.Join(words, ""), "\n") {
+		if i > 0 && line == "" {
+			break
+		}
+		text := strings.TrimSuffix(line, "\n")
+		if text != "" {
+			b.WriteString(open + text + close)
+		}
+		if len(text) < len(line) {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// splitWords splits s into runs of letters and digits, runs of blanks,
+// and single characters for everything else. Characters are grapheme
+// clusters, so combining marks stay with the letters they modify, and wide
+// characters, as used by CJK scripts that don't separate words with blanks,
+// are words on their own.
+func splitWords(s string) []string {
+	class := func(g string) int {
+		r, _ := utf8.DecodeRuneInString(g)
+		switch {
+		case r == '\n' || r == '\r':
+			return 0
+		case isWide(g):
+			return 3
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		default:
+			return 3
+		}
+	}
+
+	var words []string
+	start, i, prev := 0, 0, -1
+	for _, g := range graphemes(s) {
+		c := class(g)
+		if i > start && (c != prev || c == 0 || c == 3) {
+			words = append(words, s[start:i])
+			start = i
+		}
+		prev = c
+		i += len(g)
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
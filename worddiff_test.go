@@ -0,0 +1,52 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestWordDiff(t *testing.T) {
+	tc := []struct {
+		name    string
+		in, out string
+		color   bool
+	}{
+		{name: "changed word",
+			in:  "@@ -1,2 +1,2 @@\n a\n-fmt.Println(\"hello\")\n+fmt.Println(\"hullo\")\n",
+			out: "@@ -1,2 +1,2 @@\n a\n~fmt.Println(\"[-hello-]{+hullo+}\")\n"},
+		{name: "colored",
+			in:    "@@ -1 +1 @@\n-a b\n+a c\n",
+			out:   "@@ -1 +1 @@\n~a " + ansiRed + "b" + ansiReset + ansiGreen + "c" + ansiReset + "\n",
+			color: true},
+		{name: "only additions",
+			in:  "@@ -1 +1,2 @@\n a\n+b\n",
+			out: "@@ -1 +1,2 @@\n a\n+b\n"},
+		{name: "only deletions",
+			in:  "@@ -1,2 +1 @@\n a\n-b\n",
+			out: "@@ -1,2 +1 @@\n a\n-b\n"},
+		{name: "several lines",
+			in:  "@@ -1,2 +1,2 @@\n-one two\n-three\n+one 2\n+three\n",
+			out: "@@ -1,2 +1,2 @@\n~one [-two-]{+2+}\n~three\n"},
+		{name: "added line inside of a group",
+			in:  "@@ -1 +1,2 @@\n-one\n+one\n+two\n",
+			out: "@@ -1 +1,2 @@\n~one\n~{+two+}\n"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wordDiff(tt.in, tt.color); got != tt.out {
+				t.Errorf("case [%s]: expected\n%q\ngot\n%q", tt.name, tt.out, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+// Workers plan files on behalf of a coordinator running -plan with
+// -workers, so heavy extraction can be spread across machines sharing the
+// same checkout. The coordinator sends the content of each file with its
+// path relative to the root of the checkout, and the worker answers with the
+// planned change, fetching the sources from its own copy. Both share the
+// token in workerTokenEnv, and workers only read the files under their root.
+
+// workRequest asks a worker to plan a file.
+type workRequest struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// workResponse is the answer of a worker, with the planned change, or none
+// if the file wouldn't change.
+type workResponse struct {
+	File     *plannedFile  `json:"file,omitempty"`
+	Warnings []workWarning `json:"warnings,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// workWarning is a warning found by a worker.
+type workWarning struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// workerPath is the endpoint of the workers.
+const workerPath = "/v1/plan"
+
+// workerTokenEnv is the environment variable holding the token shared by the
+// coordinator and its workers.
+const workerTokenEnv = "EMBEDMD_WORKER_TOKEN"
+
+// runWorker implements the worker command, serving plan requests until it's
+// stopped.
+func runWorker(args []string) int {
+	fs := flag.NewFlagSet("embedmd worker", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: embedmd worker [flags]\n")
+		fs.PrintDefaults()
+	}
+	o := newCommandFlags(fs)
+	listen := fs.String("listen", "localhost:7878", "address to serve plan requests on")
+	root := fs.String("root", ".", "root of the checkout the paths of the requests are relative to")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := setup(fs, o); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	token := os.Getenv(workerTokenEnv)
+	if token == "" {
+		fmt.Fprintf(stderr, "error: set the token shared with the coordinator in %s\n", workerTokenEnv)
+		return 2
+	}
+	o.root = *root
+	opts, err := o.embedOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	fmt.Fprintf(stderr, "serving plan requests on %s\n", *listen)
+	if err := http.ListenAndServe(*listen, workerHandler(*root, token, opts...)); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// workerHandler returns the handler planning the files sent with token to a
+// worker, whose sources are resolved from root. The fetcher of opts is
+// expected to confine them to root.
+func workerHandler(root, token string, opts ...embedmd.Option) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+workerPath, func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			replyWork(w, http.StatusUnauthorized, workResponse{Error: "missing or bad token"})
+			return
+		}
+		var req workRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			replyWork(w, http.StatusBadRequest, workResponse{Error: fmt.Sprintf("bad request: %v", err)})
+			return
+		}
+		if !filepath.IsLocal(filepath.FromSlash(req.Path)) || !isDocument(req.Path) {
+			replyWork(w, http.StatusBadRequest, workResponse{Error: fmt.Sprintf("%s: not a document relative to the root", req.Path)})
+			return
+		}
+
+		var res workResponse
+		dir := filepath.Join(root, filepath.Dir(filepath.FromSlash(req.Path)))
+		opts := append([]embedmd.Option{
+			embedmd.WithBaseDir(dir),
+			embedmd.WithWarnings(func(line int, msg string) {
+				res.Warnings = append(res.Warnings, workWarning{line, msg})
+			}),
+		}, opts...)
+		f, err := planContent(req.Path, []byte(req.Content), opts...)
+		if err != nil {
+			res.Error = err.Error()
+			replyWork(w, http.StatusUnprocessableEntity, res)
+			return
+		}
+		res.File = f
+		replyWork(w, http.StatusOK, res)
+	})
+	return mux
+}
+
+func replyWork(w http.ResponseWriter, status int, res workResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+// planOnWorkers returns the changes planned by the given workers for the
+// files, nil for those that wouldn't change. Each worker plans one file at a
+// time, authenticating with token.
+func planOnWorkers(paths, workers []string, token string) ([]*plannedFile, error) {
+	planned := make([]*plannedFile, len(paths))
+	errs := make([]error, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for _, worker := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				planned[i], errs[i] = planOnWorker(worker, token, paths[i])
+			}
+		}()
+	}
+	sent := 0
+	for ; sent < len(paths) && !interrupted.Load(); sent++ {
+		jobs <- sent
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, err := range errs[:sent] {
+		if err != nil && interrupted.Load() {
+			// The request was canceled.
+			return nil, &interruptedError{done: paths[:i], pending: paths[i:]}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%v", filepath.ToSlash(paths[i]), err)
+		}
+	}
+	if sent < len(paths) {
+		return nil, &interruptedError{done: paths[:sent], pending: paths[sent:]}
+	}
+	return planned, nil
+}
+
+// planOnWorker returns the change planned by the worker for the file at
+// path, printing its warnings. The request is canceled on interrupts.
+func planOnWorker(worker, token, path string) (*plannedFile, error) {
+	if !isDocument(path) {
+		return nil, fmt.Errorf("not a markdown, reStructuredText, AsciiDoc, or notebook file")
+	}
+	in, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(workRequest{Path: filepath.ToSlash(path), Content: string(in)})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(interruptContext, "POST", worker+workerPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%v (on %s)", err, worker)
+	}
+	var res workResponse
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, fmt.Errorf("bad response with status %s (on %s)", r.Status, worker)
+	}
+	for _, w := range res.Warnings {
+		printWarning(path, w.Line, w.Message)
+	}
+	if res.Error != "" {
+		return nil, fmt.Errorf("%s (on %s)", res.Error, worker)
+	}
+	return res.File, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/seanblong/embedmd/embedmd"
+)
+
+func TestPlanOnWorkers(t *testing.T) {
+	// The worker has its own checkout with the sources.
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "docs", "code.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(workerHandler(root, "secret", workerOptions(t, root)...))
+	defer server.Close()
+
+	// The coordinator only needs the docs.
+	coordinator := t.TempDir()
+	docs := map[string]string{
+		"a.md": "[embedmd]:# (code.go)\n",
+		"b.md": "Nothing to embed\n",
+		"c.md": "[embedmd]:# (missing.go)\n",
+		"d.md": "[embedmd]:# (code.go)\n```go\nold\n",
+		"e.md": "[embedmd]:# (../../secret.txt)\n",
+	}
+	if err := os.MkdirAll(filepath.Join(coordinator, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range docs {
+		if err := os.WriteFile(filepath.Join(coordinator, "docs", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(coordinator); err != nil {
+		t.Fatal(err)
+	}
+
+	planned, err := planOnWorkers([]string{"docs/a.md", "docs/b.md"}, []string{server.URL, server.URL}, "secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(planned) != 2 || planned[0] == nil || planned[1] != nil {
+		t.Fatalf("expected a change for docs/a.md only; got %v", planned)
+	}
+	if want := "[embedmd]:# (code.go)\n```go\npackage main\n```\n"; planned[0].Content != want {
+		t.Errorf("expected planned content\n%s\ngot\n%s", want, planned[0].Content)
+	}
+
+	_, err = planOnWorkers([]string{"docs/a.md", "docs/c.md"}, []string{server.URL}, "secret")
+	eqErr(t, "missing source", err, "docs/c.md:1: could not read missing.go: open "+
+		filepath.Join(root, "docs", "missing.go")+": no such file or directory (on "+server.URL+")")
+
+	_, err = planOnWorkers([]string{"docs/d.md"}, []string{server.URL}, "secret")
+	eqErr(t, "bad markdown", err, "docs/d.md:3: unbalanced code section (on "+server.URL+")")
+
+	_, err = planOnWorkers([]string{"docs/e.md"}, []string{server.URL}, "secret")
+	eqErr(t, "outside of the root", err, "docs/e.md:1: could not read ../../secret.txt: ../../secret.txt is outside of "+root+" (on "+server.URL+")")
+
+	_, err = planOnWorkers([]string{"docs/a.md"}, []string{server.URL}, "wrong")
+	eqErr(t, "bad token", err, "docs/a.md:missing or bad token (on "+server.URL+")")
+}
+
+// workerOptions returns the options of a worker serving the files of root.
+func workerOptions(t *testing.T, root string) []embedmd.Option {
+	t.Helper()
+	o := newCommandFlags(flag.NewFlagSet("embedmd worker", flag.ContinueOnError))
+	o.root = root
+	opts, err := o.embedOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+func TestWorkerHandler_BadRequests(t *testing.T) {
+	root := t.TempDir()
+	server := httptest.NewServer(workerHandler(root, "secret", workerOptions(t, root)...))
+	defer server.Close()
+
+	tc := []struct {
+		name, body string
+		noToken    bool
+		status     int
+		err        string
+	}{
+		{name: "no token", noToken: true, body: `{"path": "doc.md"}`, status: http.StatusUnauthorized, err: "missing or bad token"},
+		{name: "not JSON", body: "{", status: http.StatusBadRequest, err: "bad request: unexpected EOF"},
+		{name: "absolute path", body: `{"path": "/etc/doc.md"}`, status: http.StatusBadRequest, err: "/etc/doc.md: not a document relative to the root"},
+		{name: "outside of the root", body: `{"path": "../doc.md"}`, status: http.StatusBadRequest, err: "../doc.md: not a document relative to the root"},
+		{name: "not markdown", body: `{"path": "main.go"}`, status: http.StatusBadRequest, err: "main.go: not a document relative to the root"},
+	}
+	for _, tt := range tc {
+		req, err := http.NewRequest("POST", server.URL+workerPath, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tt.noToken {
+			req.Header.Set("Authorization", "Bearer secret")
+		}
+		r, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(r.Body)
+		r.Body.Close()
+		if r.StatusCode != tt.status || !bytes.Contains(b, []byte(`"error":"`+tt.err+`"`)) {
+			t.Errorf("case [%s]: expected status %d and error %q; got %s %s", tt.name, tt.status, tt.err, r.Status, b)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// This file implements the subset of YAML used by the config file: block
+// mappings and sequences, flow sequences on a single line, plain and quoted
+// scalars, and comments. Every node records its position so errors can
+// point to the offending line and column.
+
+type yamlKind int
+
+const (
+	yamlNull yamlKind = iota
+	yamlScalar
+	yamlMapping
+	yamlSequence
+)
+
+func (k yamlKind) String() string {
+	return [...]string{"null", "scalar", "mapping", "sequence"}[k]
+}
+
+type yamlNode struct {
+	kind      yamlKind
+	line, col int
+	value     string      // scalars
+	pairs     []yamlPair  // mappings
+	items     []*yamlNode // sequences
+}
+
+type yamlPair struct{ key, value *yamlNode }
+
+// yamlError is an error at a given position of the document.
+type yamlError struct {
+	line, col int
+	msg       string
+}
+
+func (e *yamlError) Error() string { return fmt.Sprintf("%d:%d: %s", e.line, e.col, e.msg) }
+
+func errorAt(n *yamlNode, format string, args ...interface{}) error {
+	return &yamlError{n.line, n.col, fmt.Sprintf(format, args...)}
+}
+
+type yamlLine struct {
+	num, indent int
+	text        string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAML parses the given document, returning its root node.
+func parseYAML(doc string) (*yamlNode, error) {
+	p := new(yamlParser)
+	for i, l := range strings.Split(doc, "\n") {
+		l = strings.TrimRight(stripComment(l), " \t\r")
+		text := strings.TrimLeft(l, " ")
+		if text == "" || text == "---" {
+			continue
+		}
+		if text[0] == '\t' {
+			return nil, &yamlError{i + 1, len(l) - len(text) + 1, "tabs are not allowed for indentation"}
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(l) - len(text), text: text})
+	}
+	if len(p.lines) == 0 {
+		return &yamlNode{kind: yamlNull, line: 1, col: 1}, nil
+	}
+
+	n, err := p.parseBlock(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		return nil, &yamlError{l.num, l.indent + 1, "unexpected indentation"}
+	}
+	return n, nil
+}
+
+// stripComment removes a trailing comment from the line.
+func stripComment(l string) string {
+	var quote byte
+	for i := 0; i < len(l); i++ {
+		switch c := l[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || l[i-1] == ' ' || l[i-1] == '\t'):
+			return l[:i]
+		}
+	}
+	return l
+}
+
+func isSequenceItem(text string) bool { return text == "-" || strings.HasPrefix(text, "- ") }
+
+func (p *yamlParser) parseBlock(indent int) (*yamlNode, error) {
+	if isSequenceItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseMapping(indent int) (*yamlNode, error) {
+	first := p.lines[p.pos]
+	n := &yamlNode{kind: yamlMapping, line: first.num, col: indent + 1}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && !isSequenceItem(p.lines[p.pos].text) {
+		l := p.lines[p.pos]
+		key, rest, ok := splitKey(l.text)
+		if !ok {
+			return nil, &yamlError{l.num, indent + 1, fmt.Sprintf("expected a key followed by a colon, found %q", l.text)}
+		}
+		k, err := parseScalar(key, l.num, indent+1)
+		if err != nil {
+			return nil, err
+		}
+		for _, pair := range n.pairs {
+			if pair.key.value == k.value {
+				return nil, errorAt(k, "duplicated key %q", k.value)
+			}
+		}
+		p.pos++
+
+		var v *yamlNode
+		if rest != "" {
+			v, err = parseFlow(rest, l.num, l.indent+len(l.text)-len(rest)+1)
+		} else {
+			v, err = p.parseNested(indent, true, l.num, l.indent+len(l.text)+1)
+		}
+		if err != nil {
+			return nil, err
+		}
+		n.pairs = append(n.pairs, yamlPair{k, v})
+	}
+	return n, nil
+}
+
+// parseNested parses the value of a key or sequence item without inline
+// content, which is either a nested block or null. When compact is set, a
+// sequence with the same indentation as the parent is accepted.
+func (p *yamlParser) parseNested(indent int, compact bool, line, col int) (*yamlNode, error) {
+	if p.pos < len(p.lines) {
+		next := p.lines[p.pos]
+		if next.indent > indent || (compact && next.indent == indent && isSequenceItem(next.text)) {
+			return p.parseBlock(next.indent)
+		}
+	}
+	return &yamlNode{kind: yamlNull, line: line, col: col}, nil
+}
+
+func (p *yamlParser) parseSequence(indent int) (*yamlNode, error) {
+	first := p.lines[p.pos]
+	n := &yamlNode{kind: yamlSequence, line: first.num, col: indent + 1}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSequenceItem(p.lines[p.pos].text) {
+		l := p.lines[p.pos]
+		rest := strings.TrimLeft(l.text[1:], " ")
+		col := l.indent + len(l.text) - len(rest) + 1
+
+		var item *yamlNode
+		var err error
+		switch _, _, isKey := splitKey(rest); {
+		case rest == "":
+			p.pos++
+			item, err = p.parseNested(indent, false, l.num, col)
+		case isKey:
+			// A mapping starting in the same line as the item, whose keys are
+			// aligned with the first one.
+			p.lines[p.pos] = yamlLine{num: l.num, indent: col - 1, text: rest}
+			item, err = p.parseMapping(col - 1)
+		default:
+			p.pos++
+			item, err = parseFlow(rest, l.num, col)
+		}
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, item)
+	}
+	return n, nil
+}
+
+// splitKey splits a "key: value" line, returning false if it has no key.
+func splitKey(text string) (key, rest string, ok bool) {
+	i := 0
+	if text != "" && (text[0] == '"' || text[0] == '\'') {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 {
+			return "", "", false
+		}
+		i = end + 2
+	}
+	for ; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return text[:i], strings.TrimLeft(text[i+1:], " "), true
+		}
+	}
+	return "", "", false
+}
+
+// parseFlow parses an inline value: a scalar or a flow sequence.
+func parseFlow(text string, line, col int) (*yamlNode, error) {
+	switch {
+	case text == "{}":
+		return &yamlNode{kind: yamlMapping, line: line, col: col}, nil
+	case text[0] == '{':
+		return nil, &yamlError{line, col, "flow mappings are not supported"}
+	case text[0] != '[':
+		return parseScalar(text, line, col)
+	}
+
+	n := &yamlNode{kind: yamlSequence, line: line, col: col}
+	if text[len(text)-1] != ']' {
+		return nil, &yamlError{line, col, "unterminated flow sequence"}
+	}
+	body := text[1 : len(text)-1]
+	if strings.TrimSpace(body) == "" {
+		return n, nil
+	}
+	start := 0
+	var quote byte
+	for i := 0; i <= len(body); i++ {
+		if i < len(body) {
+			c := body[i]
+			switch {
+			case quote != 0:
+				if c == '\\' && quote == '"' {
+					i++
+				} else if c == quote {
+					quote = 0
+				}
+				continue
+			case c == '"' || c == '\'':
+				quote = c
+				continue
+			case c == '[' || c == '{':
+				return nil, &yamlError{line, col + 1 + i, "nested flow collections are not supported"}
+			case c != ',':
+				continue
+			}
+		}
+		elem := body[start:i]
+		trimmed := strings.TrimSpace(elem)
+		itemCol := col + 1 + start + len(elem) - len(strings.TrimLeft(elem, " "))
+		if trimmed == "" {
+			return nil, &yamlError{line, itemCol, "empty item in flow sequence"}
+		}
+		item, err := parseScalar(trimmed, line, itemCol)
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, item)
+		start = i + 1
+	}
+	return n, nil
+}
+
+func parseScalar(text string, line, col int) (*yamlNode, error) {
+	n := &yamlNode{kind: yamlScalar, line: line, col: col, value: text}
+	switch text[0] {
+	case '"':
+		v, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, &yamlError{line, col, fmt.Sprintf("bad double quoted string %s", text)}
+		}
+		n.value = v
+	case '\'':
+		if len(text) < 2 || text[len(text)-1] != '\'' {
+			return nil, &yamlError{line, col, fmt.Sprintf("bad single quoted string %s", text)}
+		}
+		n.value = strings.ReplaceAll(text[1:len(text)-1], "''", "'")
+	default:
+		if text == "~" || text == "null" {
+			n.kind, n.value = yamlNull, ""
+		}
+	}
+	return n, nil
+}
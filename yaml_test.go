@@ -0,0 +1,97 @@
+// Copyright 2016 Google Inc. All rights reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to writing, software distributed
+// under the License is distributed on a "AS IS" BASIS, WITHOUT WARRANTIES OR
+// CONDITIONS OF ANY KIND, either express or implied.
+//
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// dump returns a compact representation of a node for comparisons.
+func dump(n *yamlNode) string {
+	switch n.kind {
+	case yamlScalar:
+		return fmt.Sprintf("%q", n.value)
+	case yamlMapping:
+		var s []string
+		for _, p := range n.pairs {
+			s = append(s, p.key.value+":"+dump(p.value))
+		}
+		return "{" + strings.Join(s, ",") + "}"
+	case yamlSequence:
+		var s []string
+		for _, i := range n.items {
+			s = append(s, dump(i))
+		}
+		return "[" + strings.Join(s, ",") + "]"
+	}
+	return "null"
+}
+
+func TestParseYAML(t *testing.T) {
+	tc := []struct {
+		name string
+		in   string
+		out  string
+		err  string
+	}{
+		{name: "empty", in: "# just a comment\n", out: "null"},
+		{name: "scalars",
+			in:  "a: 1\nb: \"two # three\" # comment\nc: 'it''s'\nd:\ne: ~\n",
+			out: `{a:"1",b:"two # three",c:"it's",d:null,e:null}`},
+		{name: "nested mapping",
+			in:  "a:\n  b: 1\n  c:\n    d: x\nf: y\n",
+			out: `{a:{b:"1",c:{d:"x"}},f:"y"}`},
+		{name: "sequences",
+			in:  "a:\n  - x\n  - y\nb:\n- z\nc: [1, \"2, 3\", '4']\nd: []\n",
+			out: `{a:["x","y"],b:["z"],c:["1","2, 3","4"],d:[]}`},
+		{name: "sequence of mappings",
+			in:  "- name: a\n  value: 1\n- name: b\n",
+			out: `[{name:"a",value:"1"},{name:"b"}]`},
+		{name: "urls are not keys",
+			in:  "url: https://example.com/a\n",
+			out: `{url:"https://example.com/a"}`},
+		{name: "bad indentation",
+			in:  "a: 1\n    b: 2\n",
+			err: "2:5: unexpected indentation"},
+		{name: "missing colon",
+			in:  "a: 1\nb\n",
+			err: `2:1: expected a key followed by a colon, found "b"`},
+		{name: "duplicated key",
+			in:  "a: 1\na: 2\n",
+			err: `2:1: duplicated key "a"`},
+		{name: "tabs",
+			in:  "a:\n\tb: 1\n",
+			err: "2:1: tabs are not allowed for indentation"},
+		{name: "unterminated flow sequence",
+			in:  "a: [1, 2\n",
+			err: "1:4: unterminated flow sequence"},
+		{name: "empty flow item",
+			in:  "a: [1, , 2]\n",
+			err: "1:8: empty item in flow sequence"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := parseYAML(tt.in)
+			if !eqErr(t, tt.name, err, tt.err) {
+				return
+			}
+			if got := dump(n); got != tt.out {
+				t.Errorf("case [%s]: expected %s; got %s", tt.name, tt.out, got)
+			}
+		})
+	}
+}